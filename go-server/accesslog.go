@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* ---------- HTTP access log ---------- */
+
+// Access log formats (-access-log-format).
+const (
+	accessLogCommon   = "common"
+	accessLogCombined = "combined"
+	accessLogJSON     = "json"
+)
+
+// accessLog writes one line per HTTP request once it is answered, in the
+// Common or Combined Log Format or as JSON. The CLF lines end with the
+// time taken in microseconds, as Apache's %D; for an SSE stream that is
+// how long the stream stayed open.
+type accessLog struct {
+	format string
+	mu     sync.Mutex
+	out    io.Writer
+}
+
+// accessLogger is non-nil when -access-log-format is set; it replaces the
+// [REQUEST] and [RESPONSE] log lines.
+var accessLogger *accessLog
+
+// newAccessLog writes to path, or to standard error when path is empty.
+func newAccessLog(format, path string) (*accessLog, error) {
+	switch format {
+	case accessLogCommon, accessLogCombined, accessLogJSON:
+	default:
+		return nil, fmt.Errorf("unknown format %q (want common, combined or json)", format)
+	}
+	l := &accessLog{format: format, out: os.Stderr}
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+		if err != nil {
+			return nil, err
+		}
+		l.out = f
+	}
+	return l, nil
+}
+
+// accessEntry is a JSON access log line.
+type accessEntry struct {
+	Time       time.Time `json:"time"`
+	Remote     string    `json:"remote"`
+	Method     string    `json:"method"`
+	URI        string    `json:"uri"`
+	Proto      string    `json:"proto"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	DurationMs float64   `json:"duration_ms"`
+	// TTFBMs is the time until the response headers were sent.
+	TTFBMs float64 `json:"ttfb_ms"`
+	// StreamMs is how long an SSE stream stayed open after its headers.
+	StreamMs  *float64 `json:"stream_ms,omitempty"`
+	Session   string   `json:"session,omitempty"`
+	Referer   string   `json:"referer,omitempty"`
+	UserAgent string   `json:"user_agent,omitempty"`
+}
+
+// record logs a finished request. In public demo mode the client address
+// is truncated and the referer and user agent are left out.
+func (l *accessLog) record(r *http.Request, rw *responseWriter, start time.Time) {
+	end := time.Now()
+	headerAt := rw.headerAt
+	if headerAt.IsZero() {
+		headerAt = end
+	}
+	remote, referer, userAgent := r.RemoteAddr, r.Referer(), r.UserAgent()
+	if publicDemo != nil {
+		remote, referer, userAgent = anonymizeAddr(remote), "", ""
+	} else if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+
+	var line []byte
+	if l.format == accessLogJSON {
+		e := accessEntry{
+			Time: start.UTC(), Remote: remote, Method: r.Method, URI: r.RequestURI, Proto: r.Proto,
+			Status: rw.statusCode, Bytes: rw.bytes, DurationMs: roundMs(end.Sub(start)), TTFBMs: roundMs(headerAt.Sub(start)),
+			Session: r.Header.Get(sessionIDHeader), Referer: referer, UserAgent: userAgent,
+		}
+		if e.Session == "" {
+			e.Session = rw.Header().Get(sessionIDHeader)
+		}
+		if strings.HasPrefix(rw.Header().Get("Content-Type"), "text/event-stream") {
+			stream := roundMs(end.Sub(headerAt))
+			e.StreamMs = &stream
+		}
+		line, _ = json.Marshal(e)
+	} else {
+		size := "-"
+		if rw.bytes > 0 {
+			size = strconv.FormatInt(rw.bytes, 10)
+		}
+		s := fmt.Sprintf(`%s - - [%s] "%s %s %s" %d %s`, remote, start.Format("02/Jan/2006:15:04:05 -0700"),
+			clfEscape(r.Method), clfEscape(r.RequestURI), clfEscape(r.Proto), rw.statusCode, size)
+		if l.format == accessLogCombined {
+			s += fmt.Sprintf(` "%s" "%s"`, clfField(referer), clfField(userAgent))
+		}
+		line = fmt.Appendf(nil, "%s %d", s, end.Sub(start).Microseconds())
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(append(line, '\n'))
+}
+
+// clfField is "-" for an empty value, the escaped value otherwise.
+func clfField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return clfEscape(s)
+}
+
+// clfEscape escapes quotes, backslashes and control characters as Apache
+// does, so that a request cannot forge fields or lines.
+func clfEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Content-type adapters (fetch) ---------- */
+
+const (
+	// csvPreviewRows is how many data rows the CSV adapter shows.
+	csvPreviewRows = 20
+	// thumbnailSize bounds the longer side of image thumbnails.
+	thumbnailSize = 128
+	// maxImagePixels guards against small files that decode to huge
+	// images.
+	maxImagePixels = 25_000_000
+)
+
+// adapted is a response body after a content-type adapter ran.
+type adapted struct {
+	Text string
+	// Image is an extra content block, e.g. a thumbnail.
+	Image *mcp.ImageContent
+}
+
+// responseAdapter turns a response body of a given media type into a
+// form that is easier for a model to read. The body has already been
+// decompressed and, for textual types, converted to UTF-8.
+type responseAdapter struct {
+	Name  string
+	Adapt func(body []byte, mediaType, extract string) (adapted, error)
+}
+
+// responseAdapters maps media types to adapters. Keys starting with "+"
+// match structured syntax suffixes, e.g. "+json" for
+// application/problem+json.
+var responseAdapters = map[string]*responseAdapter{}
+
+func registerAdapter(a *responseAdapter, mediaTypes ...string) {
+	for _, mt := range mediaTypes {
+		responseAdapters[mt] = a
+	}
+}
+
+func init() {
+	registerAdapter(&responseAdapter{Name: "json", Adapt: adaptJSON}, "application/json", "text/json", "+json")
+	registerAdapter(&responseAdapter{Name: "html", Adapt: adaptHTML}, "text/html", "application/xhtml+xml")
+	registerAdapter(&responseAdapter{Name: "xml", Adapt: adaptXML}, "application/xml", "text/xml", "+xml")
+	registerAdapter(&responseAdapter{Name: "csv", Adapt: adaptCSV}, "text/csv", "text/tab-separated-values")
+	registerAdapter(&responseAdapter{Name: "image", Adapt: adaptImage}, "image/png", "image/jpeg", "image/gif")
+}
+
+// adapterFor returns the adapter registered for a Content-Type header,
+// or nil.
+func adapterFor(contentType string) (*responseAdapter, string) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, ""
+	}
+	if a, ok := responseAdapters[mediaType]; ok {
+		return a, mediaType
+	}
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		if a, ok := responseAdapters[mediaType[i:]]; ok {
+			return a, mediaType
+		}
+	}
+	return nil, mediaType
+}
+
+// adaptJSON pretty-prints a JSON document.
+func adaptJSON(body []byte, _, _ string) (adapted, error) {
+	var b bytes.Buffer
+	if err := json.Indent(&b, bytes.TrimSpace(body), "", "  "); err != nil {
+		return adapted{}, err
+	}
+	return adapted{Text: b.String()}, nil
+}
+
+// adaptHTML extracts the page content as text or markdown; fetch only
+// applies it when extract asks for one of them.
+func adaptHTML(body []byte, _, extract string) (adapted, error) {
+	text, err := extractHTML(bytes.NewReader(body), extract)
+	return adapted{Text: text}, err
+}
+
+// adaptXML converts an XML document to indented JSON: each element
+// becomes an object with attributes under "@name", text under "#text"
+// and children by name, repeated children as arrays. An element with
+// only text becomes a string.
+func adaptXML(body []byte, _, _ string) (adapted, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	// Charset conversion already happened; accept the declared encoding.
+	dec.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = errors.New("no root element")
+			}
+			return adapted{}, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			root, err := xmlElement(dec, start)
+			if err != nil {
+				return adapted{}, err
+			}
+			out, err := json.MarshalIndent(map[string]any{start.Name.Local: root}, "", "  ")
+			if err != nil {
+				return adapted{}, err
+			}
+			return adapted{Text: string(out)}, nil
+		}
+	}
+}
+
+func xmlElement(dec *xml.Decoder, start xml.StartElement) (any, error) {
+	obj := make(map[string]any)
+	for _, a := range start.Attr {
+		obj["@"+a.Name.Local] = a.Value
+	}
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := xmlElement(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			switch prev := obj[name].(type) {
+			case nil:
+				obj[name] = child
+			case []any:
+				obj[name] = append(prev, child)
+			default:
+				obj[name] = []any{prev, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(obj) == 0 {
+				return s, nil
+			}
+			if s != "" {
+				obj["#text"] = s
+			}
+			return obj, nil
+		}
+	}
+}
+
+// adaptCSV renders the header and the first rows of a CSV or TSV file
+// as a markdown table, with the total row count.
+func adaptCSV(body []byte, mediaType, _ string) (adapted, error) {
+	r := csv.NewReader(bytes.NewReader(body))
+	if mediaType == "text/tab-separated-values" {
+		r.Comma = '\t'
+		r.LazyQuotes = true
+	}
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return adapted{}, err
+	}
+	if len(records) == 0 {
+		return adapted{Text: "(empty table)"}, nil
+	}
+	cell := func(s string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ")
+	}
+	var b strings.Builder
+	header := records[0]
+	row := func(rec []string) {
+		b.WriteString("|")
+		for i := range header {
+			v := ""
+			if i < len(rec) {
+				v = cell(rec[i])
+			}
+			b.WriteString(" " + v + " |")
+		}
+		b.WriteString("\n")
+	}
+	row(header)
+	b.WriteString("|" + strings.Repeat(" --- |", len(header)) + "\n")
+	rows := records[1:]
+	for _, rec := range rows[:min(len(rows), csvPreviewRows)] {
+		row(rec)
+	}
+	fmt.Fprintf(&b, "\n%d rows, %d columns", len(rows), len(header))
+	if len(rows) > csvPreviewRows {
+		fmt.Fprintf(&b, " (first %d shown)", csvPreviewRows)
+	}
+	return adapted{Text: b.String()}, nil
+}
+
+// adaptImage describes an image and attaches a PNG thumbnail.
+func adaptImage(body []byte, _, _ string) (adapted, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		return adapted{}, err
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return adapted{}, fmt.Errorf("image too large to thumbnail (%dx%d)", cfg.Width, cfg.Height)
+	}
+	img, format, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		return adapted{}, err
+	}
+	thumb := thumbnail(img, thumbnailSize)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, thumb); err != nil {
+		return adapted{}, err
+	}
+	b, tb := img.Bounds(), thumb.Bounds()
+	return adapted{
+		Text: fmt.Sprintf("%s image, %dx%d pixels, %d bytes; %dx%d PNG thumbnail attached",
+			format, b.Dx(), b.Dy(), len(body), tb.Dx(), tb.Dy()),
+		Image: &mcp.ImageContent{Data: buf.Bytes(), MIMEType: "image/png"},
+	}, nil
+}
+
+// thumbnail scales img down so that its longer side is at most size,
+// averaging the source pixels that fall into each target pixel.
+func thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	tw, th := size, max(1, h*size/w)
+	if h > w {
+		tw, th = max(1, w*size/h), size
+	}
+	out := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for ty := 0; ty < th; ty++ {
+		y0, y1 := b.Min.Y+ty*h/th, b.Min.Y+(ty+1)*h/th
+		for tx := 0; tx < tw; tx++ {
+			x0, x1 := b.Min.X+tx*w/tw, b.Min.X+(tx+1)*w/tw
+			var r, g, bl, a, n uint64
+			for y := y0; y < max(y1, y0+1); y++ {
+				for x := x0; x < max(x1, x0+1); x++ {
+					cr, cg, cb, ca := img.At(x, y).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			i := out.PixOffset(tx, ty)
+			out.Pix[i+0] = uint8(r / n >> 8)
+			out.Pix[i+1] = uint8(g / n >> 8)
+			out.Pix[i+2] = uint8(bl / n >> 8)
+			out.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return out
+}
+
+// binaryMediaType returns the media type of a body that is binary rather
+// than text, or "" for text. Images, audio, video and fonts are binary
+// whatever their bytes; for other types, and when the response names
+// none, the body is sniffed.
+func binaryMediaType(mediaType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(body))
+	if mediaType == "" {
+		mediaType = sniffed
+	}
+	switch top, _, _ := strings.Cut(mediaType, "/"); top {
+	case "image", "audio", "video", "font":
+		return mediaType
+	}
+	if strings.HasPrefix(sniffed, "text/") {
+		return ""
+	}
+	return mediaType
+}
+
+// binaryContent wraps a binary body in the content block that suits its
+// type: image, audio, or an embedded resource named by the URL.
+func binaryContent(url, mediaType string, body []byte) mcp.Content {
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		return &mcp.ImageContent{Data: body, MIMEType: mediaType}
+	case strings.HasPrefix(mediaType, "audio/"):
+		return &mcp.AudioContent{Data: body, MIMEType: mediaType}
+	}
+	return &mcp.EmbeddedResource{Resource: &mcp.ResourceContents{URI: url, MIMEType: mediaType, Blob: body}}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Anomaly detection ---------- */
+
+const (
+	// maxKnownHosts caps the set of hosts seen so far; when it fills up
+	// it starts over, which at worst re-flags some old hosts as new.
+	maxKnownHosts = 10000
+	// payloadWarmup is how many results of a tool are averaged before
+	// its result sizes are judged.
+	payloadWarmup = 20
+)
+
+// anomalyDetector watches finished tool calls for patterns that deserve
+// an operator's attention on a shared deployment, and publishes an
+// "anomaly" event for each:
+//
+//   - new_domains: a session made requests to NewDomains hosts never
+//     contacted before within NewDomainWindow
+//   - policy_blocked: a session had Blocked calls refused by the egress
+//     policy or an allowlist within BlockedWindow
+//   - large_payload: a result at least PayloadMin bytes and PayloadFactor
+//     times the tool's running mean
+//
+// Each kind is raised at most once per Cooldown for the same session or
+// tool.
+type anomalyDetector struct {
+	NewDomains      int
+	NewDomainWindow time.Duration
+	Blocked         int
+	BlockedWindow   time.Duration
+	PayloadFactor   float64
+	PayloadMin      int
+	Cooldown        time.Duration
+
+	mu         sync.Mutex
+	knownHosts map[string]bool
+	sessions   map[string]*sessionSignals
+	payloads   map[string]*payloadStats
+	raised     map[string]time.Time // kind and subject -> last event
+	counts     map[string]int       // by kind
+}
+
+type sessionSignals struct {
+	newHosts []time.Time
+	blocked  []time.Time
+	lastSeen time.Time
+}
+
+type payloadStats struct {
+	n    int
+	mean float64
+}
+
+// anomalies is non-nil when -anomaly-detection is set.
+var anomalies *anomalyDetector
+
+func newAnomalyDetector() *anomalyDetector {
+	return &anomalyDetector{
+		NewDomains:      10,
+		NewDomainWindow: 5 * time.Minute,
+		Blocked:         5,
+		BlockedWindow:   5 * time.Minute,
+		PayloadFactor:   10,
+		PayloadMin:      256 << 10,
+		Cooldown:        15 * time.Minute,
+		knownHosts:      make(map[string]bool),
+		sessions:        make(map[string]*sessionSignals),
+		payloads:        make(map[string]*payloadStats),
+		raised:          make(map[string]time.Time),
+		counts:          make(map[string]int),
+	}
+}
+
+// callSignals collects what a call did that the detector cares about. The
+// egress policy and allowlists record into it through the call's context.
+type callSignals struct {
+	mu      sync.Mutex
+	hosts   []string
+	blocked bool
+}
+
+type callSignalsKey struct{}
+
+// noteEgressHost records a host the call was allowed to contact.
+func noteEgressHost(ctx context.Context, host string) {
+	if s, ok := ctx.Value(callSignalsKey{}).(*callSignals); ok {
+		s.mu.Lock()
+		s.hosts = append(s.hosts, strings.ToLower(strings.TrimSuffix(host, ".")))
+		s.mu.Unlock()
+	}
+}
+
+// notePolicyBlocked records that a policy refused part of the call.
+func notePolicyBlocked(ctx context.Context) {
+	if s, ok := ctx.Value(callSignalsKey{}).(*callSignals); ok {
+		s.mu.Lock()
+		s.blocked = true
+		s.mu.Unlock()
+	}
+}
+
+// anomalyToolMiddleware feeds every call to the detector.
+func anomalyToolMiddleware(tool *mcp.Tool, next ToolFunc) ToolFunc {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, any, error) {
+		if anomalies == nil {
+			return next(ctx, req)
+		}
+		signals := &callSignals{}
+		res, out, err := next(context.WithValue(ctx, callSignalsKey{}, signals), req)
+		size := 0
+		if res != nil {
+			if data, err := json.Marshal(res); err == nil {
+				size = len(data)
+			}
+		}
+		session := ""
+		if req.Session != nil {
+			session = req.Session.ID()
+		}
+		signals.mu.Lock()
+		hosts, blocked := signals.hosts, signals.blocked
+		signals.mu.Unlock()
+		for _, e := range anomalies.observe(time.Now(), tool.Name, session, hosts, blocked, size) {
+			if session != "" {
+				e.Fields["remote"] = liveSessions.remote(session)
+			}
+			events.publish(e)
+		}
+		return res, out, err
+	}
+}
+
+// observe records one call and returns the anomalies it completes.
+func (d *anomalyDetector) observe(now time.Time, tool, session string, hosts []string, blocked bool, size int) []serverEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var found []serverEvent
+	if session != "" {
+		s := d.sessions[session]
+		if s == nil {
+			d.sweep(now)
+			s = &sessionSignals{}
+			d.sessions[session] = s
+		}
+		s.lastSeen = now
+		for _, h := range hosts {
+			if d.knownHosts[h] {
+				continue
+			}
+			if len(d.knownHosts) >= maxKnownHosts {
+				clear(d.knownHosts)
+			}
+			d.knownHosts[h] = true
+			s.newHosts = append(s.newHosts, now)
+		}
+		s.newHosts = within(s.newHosts, now, d.NewDomainWindow)
+		if len(s.newHosts) >= d.NewDomains && d.raise(now, "new_domains", session) {
+			found = append(found, serverEvent{Kind: "anomaly", Severity: "warning",
+				Message: fmt.Sprintf("session %s contacted %d new hosts within %s", session, len(s.newHosts), d.NewDomainWindow),
+				Fields:  map[string]any{"anomaly": "new_domains", "session": session, "tool": tool, "new_hosts": len(s.newHosts), "window_seconds": d.NewDomainWindow.Seconds()}})
+		}
+		if blocked {
+			s.blocked = append(s.blocked, now)
+		}
+		s.blocked = within(s.blocked, now, d.BlockedWindow)
+		if len(s.blocked) >= d.Blocked && d.raise(now, "policy_blocked", session) {
+			found = append(found, serverEvent{Kind: "anomaly", Severity: "warning",
+				Message: fmt.Sprintf("session %s had %d calls blocked by policy within %s", session, len(s.blocked), d.BlockedWindow),
+				Fields:  map[string]any{"anomaly": "policy_blocked", "session": session, "tool": tool, "blocked_calls": len(s.blocked), "window_seconds": d.BlockedWindow.Seconds()}})
+		}
+	}
+
+	p := d.payloads[tool]
+	if p == nil {
+		p = &payloadStats{}
+		d.payloads[tool] = p
+	}
+	if p.n >= payloadWarmup && size >= d.PayloadMin && float64(size) > d.PayloadFactor*p.mean && d.raise(now, "large_payload", tool) {
+		found = append(found, serverEvent{Kind: "anomaly", Severity: "info",
+			Message: fmt.Sprintf("%s returned %s, %.0f times its mean of %s", tool, formatBytes(int64(size)), float64(size)/p.mean, formatBytes(int64(p.mean))),
+			Fields:  map[string]any{"anomaly": "large_payload", "session": session, "tool": tool, "result_bytes": size, "mean_bytes": int64(p.mean)}})
+	}
+	// A plain mean over the warmup, then a moving average that follows
+	// gradual change.
+	p.n++
+	if p.n <= payloadWarmup {
+		p.mean += (float64(size) - p.mean) / float64(p.n)
+	} else {
+		p.mean += (float64(size) - p.mean) * 0.05
+	}
+	return found
+}
+
+// raise reports whether an anomaly of kind for subject is due, that is
+// not raised within the cooldown, and counts it if so.
+func (d *anomalyDetector) raise(now time.Time, kind, subject string) bool {
+	key := kind + "\x00" + subject
+	if last, ok := d.raised[key]; ok && now.Sub(last) < d.Cooldown {
+		return false
+	}
+	d.raised[key] = now
+	d.counts[kind]++
+	return true
+}
+
+// sweep forgets sessions and cooldowns that can no longer matter.
+func (d *anomalyDetector) sweep(now time.Time) {
+	horizon := max(d.NewDomainWindow, d.BlockedWindow)
+	for id, s := range d.sessions {
+		if now.Sub(s.lastSeen) > horizon {
+			delete(d.sessions, id)
+		}
+	}
+	for key, last := range d.raised {
+		if now.Sub(last) >= d.Cooldown {
+			delete(d.raised, key)
+		}
+	}
+}
+
+// within drops the times older than window before now.
+func within(times []time.Time, now time.Time, window time.Duration) []time.Time {
+	i := 0
+	for i < len(times) && now.Sub(times[i]) > window {
+		i++
+	}
+	return times[i:]
+}
+
+func logAnomaly(e serverEvent) {
+	if e.Kind == "anomaly" {
+		log.Printf("[ANOMALY] %s", e.Message)
+	}
+}
+
+func (d *anomalyDetector) collectMetrics(w *metricsWriter) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	w.family("mcp_anomalies_total", "counter", "Anomalies raised in tool usage, by kind")
+	for _, kind := range []string{"large_payload", "new_domains", "policy_blocked"} {
+		w.sample("mcp_anomalies_total", float64(d.counts[kind]), "anomaly", kind)
+	}
+}
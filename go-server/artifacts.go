@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Artifacts ---------- */
+
+const (
+	artifactURITemplate = "artifact://{kind}/{id}"
+	// maxArtifacts and maxArtifactBytes bound the store, the latter the
+	// artifacts held in memory; the oldest artifacts are dropped first.
+	maxArtifacts     = 50
+	maxArtifactBytes = 32 << 20
+)
+
+// artifactStore keeps tool output that is too large for a tool result,
+// such as a full crawl, as resources the client reads when it needs them.
+// Artifacts live in memory, or for downloads in a temporary file, until
+// the server stops, they are evicted or the janitor expires them.
+type artifactStore struct {
+	mu     sync.Mutex
+	server *mcp.Server
+	items  map[string]*artifact
+	order  []string // URIs, oldest first
+	bytes  int
+	// fileBytes is the size of the file-backed artifacts, which
+	// FileQuota bounds.
+	fileBytes int64
+	FileQuota int64
+}
+
+type artifact struct {
+	resource *mcp.Resource
+	data     []byte
+	file     string // path of a file-backed artifact; data is nil
+	created  time.Time
+}
+
+var artifacts = &artifactStore{items: make(map[string]*artifact), FileQuota: defaultDownloadQuota}
+
+// addArtifacts lets tools on server store artifacts. The template makes
+// the server announce resources before the first artifact exists.
+func addArtifacts(server *mcp.Server) {
+	artifacts.mu.Lock()
+	artifacts.server = server
+	artifacts.mu.Unlock()
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		URITemplate: artifactURITemplate,
+		Name:        "artifact",
+		Title:       "Tool artifact",
+		Description: "Full output stored by a tool call (e.g. crawl_preview); the tool result names the URI",
+	}, artifacts.read)
+}
+
+// add stores data as a new resource and returns its URI, e.g.
+// artifact://crawl/1f2e3d4c5b6a7988.
+func (s *artifactStore) add(kind, title, mimeType string, data []byte) (string, error) {
+	if len(data) > maxArtifactBytes {
+		return "", errors.New("artifact too large")
+	}
+	return s.insert(kind, &artifact{resource: &mcp.Resource{Title: title, MIMEType: mimeType, Size: int64(len(data))}, data: data})
+}
+
+// addFile stores the file at path, which the store then owns and deletes
+// with the artifact, as a new resource and returns its URI.
+func (s *artifactStore) addFile(kind, title, mimeType, path string, size int64) (string, error) {
+	if size > s.FileQuota {
+		os.Remove(path)
+		return "", errors.New("artifact too large")
+	}
+	uri, err := s.insert(kind, &artifact{resource: &mcp.Resource{Title: title, MIMEType: mimeType, Size: size}, file: path})
+	if err != nil {
+		os.Remove(path)
+	}
+	return uri, err
+}
+
+// insert names a and adds it, evicting the oldest artifacts of its kind of
+// storage, memory or file, to make room.
+func (s *artifactStore) insert(kind string, a *artifact) (string, error) {
+	id := make([]byte, 8)
+	rand.Read(id)
+	a.resource.URI = "artifact://" + kind + "/" + hex.EncodeToString(id)
+	a.resource.Name = kind + "-" + hex.EncodeToString(id)
+	a.created = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.server == nil {
+		return "", errors.New("artifacts are not available on this server")
+	}
+	full := func() bool {
+		if a.file != "" {
+			return s.fileBytes+a.resource.Size > s.FileQuota
+		}
+		return s.bytes+len(a.data) > maxArtifactBytes
+	}
+	var evicted []string
+	for i := 0; i < len(s.order) && (len(s.order) >= maxArtifacts || full()); {
+		if len(s.order) < maxArtifacts && (s.items[s.order[i]].file != "") != (a.file != "") {
+			i++
+			continue
+		}
+		evicted = append(evicted, s.order[i])
+		s.remove(i)
+	}
+	if len(evicted) > 0 {
+		s.server.RemoveResources(evicted...)
+	}
+	s.items[a.resource.URI] = a
+	s.order = append(s.order, a.resource.URI)
+	if a.file != "" {
+		s.fileBytes += a.resource.Size
+	} else {
+		s.bytes += len(a.data)
+	}
+	s.server.AddResource(a.resource, s.read)
+	return a.resource.URI, nil
+}
+
+// remove drops s.order[i] and its file, if any. The caller removes the
+// resource from the server.
+func (s *artifactStore) remove(i int) (size int64) {
+	uri := s.order[i]
+	a := s.items[uri]
+	s.order = append(s.order[:i], s.order[i+1:]...)
+	delete(s.items, uri)
+	if a.file != "" {
+		os.Remove(a.file)
+		s.fileBytes -= a.resource.Size
+		return a.resource.Size
+	}
+	s.bytes -= len(a.data)
+	return int64(len(a.data))
+}
+
+// close deletes the files of file-backed artifacts, when the server stops.
+func (s *artifactStore) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.items {
+		if a.file != "" {
+			os.Remove(a.file)
+		}
+	}
+}
+
+// expire removes the artifacts created before cutoff, returning how many
+// and their size.
+func (s *artifactStore) expire(cutoff time.Time) (n, bytes int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var expired []string
+	for len(s.order) > 0 && s.items[s.order[0]].created.Before(cutoff) {
+		expired = append(expired, s.order[0])
+		bytes += int(s.remove(0))
+	}
+	if len(expired) > 0 {
+		s.server.RemoveResources(expired...)
+	}
+	return len(expired), bytes
+}
+
+func (s *artifactStore) read(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	uri := req.Params.URI
+	s.mu.Lock()
+	a := s.items[uri]
+	s.mu.Unlock()
+	if a == nil {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	data := a.data
+	if a.file != "" {
+		var err error
+		if data, err = os.ReadFile(a.file); err != nil {
+			return nil, err
+		}
+	}
+	contents := &mcp.ResourceContents{URI: uri, MIMEType: a.resource.MIMEType}
+	if strings.HasPrefix(a.resource.MIMEType, "text/") || strings.HasSuffix(a.resource.MIMEType, "json") {
+		contents.Text = string(data)
+	} else {
+		contents.Blob = data
+	}
+	return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{contents}}, nil
+}
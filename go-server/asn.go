@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Tool: asn_lookup ---------- */
+
+const (
+	asnProviderRIPEstat = "ripestat"
+	asnProviderCymru    = "cymru"
+	// defaultASNCacheTTL is the default value of -asn-cache-ttl.
+	defaultASNCacheTTL = time.Hour
+	// defaultASNPrefixes and maxASNPrefixes bound max_prefixes.
+	defaultASNPrefixes = 50
+	maxASNPrefixes     = 500
+	// maxASNResponseBytes caps a provider response.
+	maxASNResponseBytes = 4 << 20
+	ripestatBaseURL     = "https://stat.ripe.net/data/"
+	cymruWhoisAddr      = "whois.cymru.com:43"
+)
+
+type ASNLookupArgs struct {
+	Resource     string `json:"resource" jsonschema:"IP address or prefix (e.g. 193.0.6.139 or 193.0.0.0/21)"`
+	Provider     string `json:"provider,omitempty" jsonschema:"ripestat or cymru (default: the server's -asn-provider)"`
+	ListPrefixes bool   `json:"list_prefixes,omitempty" jsonschema:"Also list the prefixes each origin AS announces (ripestat only)"`
+	MaxPrefixes  int    `json:"max_prefixes,omitempty" jsonschema:"Prefixes listed per AS with list_prefixes (default 50, max 500)"`
+}
+
+// ASNOrigin is an AS announcing the resource.
+type ASNOrigin struct {
+	ASN           int64    `json:"asn"`
+	Name          string   `json:"name,omitempty" jsonschema:"AS holder name"`
+	Country       string   `json:"country,omitempty"`
+	Registry      string   `json:"registry,omitempty" jsonschema:"Regional internet registry (cymru)"`
+	Allocated     string   `json:"allocated,omitempty" jsonschema:"Allocation date (cymru)"`
+	Prefixes      []string `json:"prefixes,omitempty" jsonschema:"Prefixes the AS announces, with list_prefixes"`
+	PrefixesTotal int      `json:"prefixes_total,omitempty" jsonschema:"Number of prefixes the AS announces, when listed"`
+}
+
+// ASNLookupResult is the structured output of the asn_lookup tool.
+type ASNLookupResult struct {
+	Resource  string      `json:"resource"`
+	Provider  string      `json:"provider"`
+	Prefix    string      `json:"prefix,omitempty" jsonschema:"BGP prefix announcing the resource; empty when it is not announced"`
+	Origins   []ASNOrigin `json:"origins" jsonschema:"Origin ASes; more than one means the prefix is announced from several ASes (MOAS)"`
+	Cached    bool        `json:"cached" jsonschema:"Served from the server's cache"`
+	FetchedAt string      `json:"fetched_at" jsonschema:"When the provider was asked (RFC 3339)"`
+	Note      string      `json:"note,omitempty"`
+}
+
+// asnSettings are the -asn-provider and -asn-cache-ttl flags.
+var asnSettings = struct {
+	Provider string
+	CacheTTL time.Duration
+}{Provider: asnProviderRIPEstat, CacheTTL: defaultASNCacheTTL}
+
+// asnCache remembers lookups per provider, resource and prefix listing
+// for asnSettings.CacheTTL; routing data changes slowly and both providers
+// ask clients not to hammer them.
+var asnCache = struct {
+	sync.Mutex
+	entries map[string]*asnCacheEntry
+}{entries: make(map[string]*asnCacheEntry)}
+
+type asnCacheEntry struct {
+	result  ASNLookupResult
+	expires time.Time
+}
+
+// parseASNProvider validates a provider name.
+func parseASNProvider(name string) (string, error) {
+	switch p := strings.ToLower(strings.TrimSpace(name)); p {
+	case asnProviderRIPEstat, asnProviderCymru:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown ASN provider %q (want ripestat or cymru)", name)
+	}
+}
+
+func ASNLookupTool(ctx context.Context, req *mcp.CallToolRequest, in ASNLookupArgs) (*mcp.CallToolResult, any, error) {
+	resource := strings.TrimSpace(in.Resource)
+	prefix, err := netip.ParsePrefix(resource)
+	if err != nil {
+		addr, aerr := netip.ParseAddr(resource)
+		if aerr != nil {
+			return errorResult(fmt.Sprintf("resource %q is neither an IP address nor a prefix", in.Resource)), nil, nil
+		}
+		prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		resource = addr.Unmap().String()
+	} else {
+		prefix = prefix.Masked()
+		resource = prefix.String()
+	}
+	provider := asnSettings.Provider
+	if in.Provider != "" {
+		if provider, err = parseASNProvider(in.Provider); err != nil {
+			return errorResult(err.Error()), nil, nil
+		}
+	}
+	maxPrefixes := 0
+	if in.ListPrefixes {
+		maxPrefixes = in.MaxPrefixes
+		if maxPrefixes <= 0 {
+			maxPrefixes = defaultASNPrefixes
+		}
+		maxPrefixes = min(maxPrefixes, maxASNPrefixes)
+	}
+
+	key := fmt.Sprintf("%s|%s|%d", provider, resource, maxPrefixes)
+	asnCache.Lock()
+	entry, ok := asnCache.entries[key]
+	asnCache.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		out := entry.result
+		out.Cached = true
+		setCacheStatus(ctx, "hit")
+		return asnResult(out), out, nil
+	}
+	if asnSettings.CacheTTL > 0 {
+		setCacheStatus(ctx, "miss")
+	}
+
+	out := ASNLookupResult{Resource: resource, Provider: provider, Origins: []ASNOrigin{}, FetchedAt: time.Now().UTC().Format(time.RFC3339)}
+	if provider == asnProviderCymru {
+		err = cymruLookup(ctx, prefix, &out)
+		if in.ListPrefixes {
+			out.Note = "the cymru provider cannot list an AS's prefixes; use provider ripestat"
+		}
+	} else {
+		err = ripestatLookup(ctx, prefix, maxPrefixes, &out)
+	}
+	if err != nil {
+		return errorResult(fmt.Sprintf("%s lookup failed: %v", provider, err)), nil, nil
+	}
+
+	if asnSettings.CacheTTL > 0 {
+		asnCache.Lock()
+		// Keep the cache bounded by dropping expired entries now and then.
+		if len(asnCache.entries) >= 1024 {
+			removeExpiredASN(time.Now())
+		}
+		asnCache.entries[key] = &asnCacheEntry{result: out, expires: time.Now().Add(asnSettings.CacheTTL)}
+		asnCache.Unlock()
+	}
+	return asnResult(out), out, nil
+}
+
+// expireASNCache drops the lookups expired by now, returning how many.
+func expireASNCache(now time.Time) int {
+	asnCache.Lock()
+	defer asnCache.Unlock()
+	return removeExpiredASN(now)
+}
+
+// removeExpiredASN is expireASNCache with asnCache locked.
+func removeExpiredASN(now time.Time) int {
+	n := 0
+	for k, e := range asnCache.entries {
+		if now.After(e.expires) {
+			delete(asnCache.entries, k)
+			n++
+		}
+	}
+	return n
+}
+
+// ripestatLookup asks RIPEstat: network-info maps an address to its
+// prefix and origins (prefix-overview does the same for a prefix), and
+// as-overview and announced-prefixes describe each origin.
+func ripestatLookup(ctx context.Context, prefix netip.Prefix, maxPrefixes int, out *ASNLookupResult) error {
+	var asns []int64
+	if prefix.IsSingleIP() {
+		var info struct {
+			ASNs   []string `json:"asns"`
+			Prefix string   `json:"prefix"`
+		}
+		if err := ripestatGet(ctx, "network-info", prefix.Addr().String(), &info); err != nil {
+			return err
+		}
+		out.Prefix = info.Prefix
+		for _, s := range info.ASNs {
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				asns = append(asns, n)
+			}
+		}
+	} else {
+		var overview struct {
+			Resource  string `json:"resource"`
+			Announced bool   `json:"announced"`
+			ASNs      []struct {
+				ASN    int64  `json:"asn"`
+				Holder string `json:"holder"`
+			} `json:"asns"`
+		}
+		if err := ripestatGet(ctx, "prefix-overview", prefix.String(), &overview); err != nil {
+			return err
+		}
+		if overview.Announced {
+			out.Prefix = overview.Resource
+		}
+		for _, a := range overview.ASNs {
+			asns = append(asns, a.ASN)
+		}
+	}
+
+	for _, asn := range asns {
+		origin := ASNOrigin{ASN: asn}
+		var overview struct {
+			Holder string `json:"holder"`
+		}
+		if err := ripestatGet(ctx, "as-overview", fmt.Sprintf("AS%d", asn), &overview); err != nil {
+			return err
+		}
+		origin.Name = overview.Holder
+		if maxPrefixes > 0 {
+			var announced struct {
+				Prefixes []struct {
+					Prefix string `json:"prefix"`
+				} `json:"prefixes"`
+			}
+			if err := ripestatGet(ctx, "announced-prefixes", fmt.Sprintf("AS%d", asn), &announced); err != nil {
+				return err
+			}
+			origin.PrefixesTotal = len(announced.Prefixes)
+			for _, p := range announced.Prefixes[:min(len(announced.Prefixes), maxPrefixes)] {
+				origin.Prefixes = append(origin.Prefixes, p.Prefix)
+			}
+		}
+		out.Origins = append(out.Origins, origin)
+	}
+	return nil
+}
+
+// ripestatGet fetches one RIPEstat data call and decodes its data member.
+func ripestatGet(ctx context.Context, call, resource string, data any) error {
+	u := ripestatBaseURL + call + "/data.json?" + url.Values{"resource": {resource}, "sourceapp": {"mcp-server-demo-go"}}.Encode()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("User-Agent", fetchUserAgent)
+	httpReq.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxASNResponseBytes))
+	if err != nil {
+		return err
+	}
+	var envelope struct {
+		Status   string          `json:"status"`
+		Messages [][]string      `json:"messages"`
+		Data     json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s: %s", call, resp.Status)
+		}
+		return fmt.Errorf("%s: invalid response: %v", call, err)
+	}
+	if resp.StatusCode != http.StatusOK || envelope.Status != "ok" {
+		msg := resp.Status
+		for _, m := range envelope.Messages {
+			if len(m) == 2 && m[0] == "error" {
+				msg = m[1]
+			}
+		}
+		return fmt.Errorf("%s: %s", call, msg)
+	}
+	return json.Unmarshal(envelope.Data, data)
+}
+
+// cymruLookup asks Team Cymru's whois service in bulk verbose mode. It
+// answers for addresses only, so a prefix is looked up by its first
+// address; the covering BGP prefix comes back with the answer.
+func cymruLookup(ctx context.Context, prefix netip.Prefix, out *ASNLookupResult) error {
+	// Whois is not HTTP, so the call's budget is charged here rather than
+	// by the outbound transport.
+	if b, ok := ctx.Value(callBudgetKey{}).(*callBudget); ok {
+		if err := b.take(); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, httpClient.Timeout)
+	defer cancel()
+	conn, err := dnsResolver.dialContext(ctx, "tcp", cymruWhoisAddr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := fmt.Fprintf(conn, "begin\nverbose\n%s\nend\n", prefix.Addr()); err != nil {
+		return err
+	}
+
+	// AS | IP | BGP Prefix | CC | Registry | Allocated | AS Name
+	scanner := bufio.NewScanner(io.LimitReader(conn, maxASNResponseBytes))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "|")
+		if len(fields) < 7 {
+			continue
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		asn, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			// The header line, or "NA" for unannounced space.
+			continue
+		}
+		out.Prefix = fields[2]
+		out.Origins = append(out.Origins, ASNOrigin{
+			ASN:       asn,
+			Name:      strings.Join(fields[6:], "|"),
+			Country:   fields[3],
+			Registry:  fields[4],
+			Allocated: fields[5],
+		})
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+func asnResult(out ASNLookupResult) *mcp.CallToolResult {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (via %s", out.Resource, out.Provider)
+	if out.Cached {
+		fmt.Fprintf(&b, ", cached from %s", out.FetchedAt)
+	}
+	b.WriteString(")")
+	if out.Prefix == "" && len(out.Origins) == 0 {
+		b.WriteString(": not announced in BGP")
+	} else {
+		fmt.Fprintf(&b, "\nPrefix: %s", out.Prefix)
+	}
+	for _, o := range out.Origins {
+		fmt.Fprintf(&b, "\nAS%d", o.ASN)
+		if o.Name != "" {
+			fmt.Fprintf(&b, " %s", o.Name)
+		}
+		var extra []string
+		for _, s := range []string{o.Country, o.Registry, o.Allocated} {
+			if s != "" {
+				extra = append(extra, s)
+			}
+		}
+		if len(extra) > 0 {
+			fmt.Fprintf(&b, " (%s)", strings.Join(extra, ", "))
+		}
+		if o.PrefixesTotal > 0 {
+			fmt.Fprintf(&b, "\n  announces %d prefixes", o.PrefixesTotal)
+			if len(o.Prefixes) < o.PrefixesTotal {
+				fmt.Fprintf(&b, ", first %d", len(o.Prefixes))
+			}
+			fmt.Fprintf(&b, ": %s", strings.Join(o.Prefixes, ", "))
+		}
+	}
+	if out.Note != "" {
+		fmt.Fprintf(&b, "\nNote: %s", out.Note)
+	}
+	return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: b.String()}}}
+}
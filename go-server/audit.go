@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Audit log ---------- */
+
+const (
+	// defaultAuditRedactFields are the -audit-redact-fields names.
+	defaultAuditRedactFields = "authorization,cookie,password,passwd,secret,token,api_key,apikey,credential,private_key,signature"
+	// maxAuditArgString is how many bytes of a string argument are kept.
+	maxAuditArgString = 256
+	redactedValue     = "[redacted]"
+	// redactedURLValue replaces secrets inside URLs, where it needs no
+	// escaping.
+	redactedURLValue = "REDACTED"
+)
+
+// auditRecord is one line of the audit log, written when a tool call
+// finishes. Arguments are recorded after redaction.
+type auditRecord struct {
+	Time         time.Time      `json:"time"`
+	RequestID    string         `json:"request_id,omitempty"`
+	Session      string         `json:"session,omitempty"`
+	Client       string         `json:"client,omitempty"` // clientInfo name and version
+	Remote       string         `json:"remote,omitempty"`
+	Tool         string         `json:"tool"`
+	Args         map[string]any `json:"args,omitempty"`
+	DurationMs   float64        `json:"duration_ms"`
+	Outcome      string         `json:"outcome"` // as in /debug/calls
+	ResultBytes  int            `json:"result_bytes"`
+	FetchedBytes int64          `json:"fetched_bytes"` // upstream response bytes read
+	Requests     int            `json:"upstream_requests"`
+}
+
+// auditLog appends records as JSON lines to the -audit-log file. Once the
+// file reaches MaxBytes it is renamed to path.1, older files shift up to
+// path.MaxFiles, and the oldest is removed.
+type auditLog struct {
+	MaxBytes int64 // 0: never rotate
+	MaxFiles int
+	// RedactFields are lowercase names: an argument, nested field or URL
+	// query parameter whose name contains one is masked.
+	RedactFields []string
+
+	mu   sync.Mutex
+	path string
+	file *os.File
+	size int64
+}
+
+// audit is non-nil when the audit log is on.
+var audit *auditLog
+
+func openAuditLog(path string) (*auditLog, error) {
+	l := &auditLog{path: path, MaxFiles: 5, RedactFields: strings.Split(defaultAuditRedactFields, ",")}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *auditLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file, l.size = f, info.Size()
+	return nil
+}
+
+func (l *auditLog) write(r auditRecord) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(r); err != nil {
+		return err
+	}
+	line := buf.Bytes()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.MaxBytes > 0 && l.size > 0 && l.size+int64(len(line)) > l.MaxBytes {
+		if err := l.rotate(); err != nil {
+			return fmt.Errorf("rotating: %w", err)
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+// rotate moves the current file to path.1 and starts a new one.
+func (l *auditLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	os.Remove(rotatedAuditPath(l.path, l.MaxFiles))
+	for i := l.MaxFiles - 1; i >= 1; i-- {
+		os.Rename(rotatedAuditPath(l.path, i), rotatedAuditPath(l.path, i+1))
+	}
+	if l.MaxFiles > 0 {
+		if err := os.Rename(l.path, rotatedAuditPath(l.path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(l.path); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+// prune deletes the rotated files last written before cutoff, returning
+// how many and their size.
+func (l *auditLog) prune(cutoff time.Time) (n int, bytes int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, p := range auditFiles(l.path) {
+		if p == l.path {
+			continue
+		}
+		info, err := os.Stat(p)
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if os.Remove(p) == nil {
+			n++
+			bytes += info.Size()
+		}
+	}
+	return n, bytes
+}
+
+func rotatedAuditPath(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
+
+// auditFiles returns the audit log at path and its rotated files that
+// exist, oldest first.
+func auditFiles(path string) []string {
+	var rotated []string
+	for i := 1; ; i++ {
+		p := rotatedAuditPath(path, i)
+		if _, err := os.Stat(p); err != nil {
+			break
+		}
+		rotated = append([]string{p}, rotated...)
+	}
+	return append(rotated, path)
+}
+
+// redactArgs decodes a call's arguments and masks what looks sensitive:
+// fields named like RedactFields, the same in URL query strings, and URL
+// passwords. Long strings are cut short.
+func (l *auditLog) redactArgs(raw json.RawMessage) map[string]any {
+	var args map[string]any
+	if len(raw) == 0 || json.Unmarshal(raw, &args) != nil {
+		return nil
+	}
+	for k, v := range args {
+		args[k] = l.redactArg(k, v)
+	}
+	return args
+}
+
+func (l *auditLog) redactArg(name string, v any) any {
+	if l.sensitive(name) {
+		return redactedValue
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for k, inner := range v {
+			v[k] = l.redactArg(k, inner)
+		}
+	case []any:
+		for i, inner := range v {
+			v[i] = l.redactArg("", inner)
+		}
+	case string:
+		s := l.redactURL(v)
+		if len(s) > maxAuditArgString {
+			s = string(truncateUTF8([]byte(s), maxAuditArgString)) + "…"
+		}
+		return s
+	}
+	return v
+}
+
+// redactURL masks the password and sensitive query parameters of s if it
+// is an absolute URL, and returns s unchanged otherwise.
+func (l *auditLog) redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return s
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redactedURLValue)
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for k := range q {
+			if l.sensitive(k) {
+				q[k] = []string{redactedURLValue}
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+func (l *auditLog) sensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, f := range l.RedactFields {
+		if f != "" && strings.Contains(name, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditToolMiddleware writes an audit record for every call. It runs
+// inside budgetToolMiddleware so that it can read the call's upstream
+// usage.
+func auditToolMiddleware(tool *mcp.Tool, next ToolFunc) ToolFunc {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, any, error) {
+		if audit == nil {
+			return next(ctx, req)
+		}
+		start := time.Now()
+		res, out, err := next(ctx, req)
+		r := auditRecord{Time: start.UTC(), RequestID: callRequestID(ctx), Tool: tool.Name, Args: audit.redactArgs(req.Params.Arguments), DurationMs: roundMs(time.Since(start)), Outcome: callOutcome(ctx, res, err)}
+		if ss := req.Session; ss != nil {
+			r.Session = ss.ID()
+			if params := ss.InitializeParams(); params != nil && params.ClientInfo != nil {
+				r.Client = params.ClientInfo.Name
+				if params.ClientInfo.Version != "" {
+					r.Client += "/" + params.ClientInfo.Version
+				}
+			}
+			r.Remote = liveSessions.remote(ss.ID())
+		}
+		if res != nil {
+			if data, err := json.Marshal(res); err == nil {
+				r.ResultBytes = len(data)
+			}
+		}
+		if b, ok := ctx.Value(callBudgetKey{}).(*callBudget); ok {
+			r.Requests, r.FetchedBytes = b.usage()
+		}
+		if werr := audit.write(r); werr != nil {
+			log.Printf("Audit log: %v", werr)
+		}
+		return res, out, err
+	}
+}
+
+// callOutcome classifies a finished call: ok, error (an error result),
+// failed or cancelled.
+func callOutcome(ctx context.Context, res *mcp.CallToolResult, err error) string {
+	switch {
+	case cancelled(ctx):
+		return "cancelled"
+	case err != nil:
+		return "failed"
+	case res != nil && res.IsError:
+		return "error"
+	}
+	return "ok"
+}
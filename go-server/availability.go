@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Uptime and availability ---------- */
+
+const (
+	uptimeURI = "server://uptime"
+	// availabilityDays is how many UTC days of request counts are kept.
+	availabilityDays = 30
+	// healthySuccessRate is the share of tool calls and HTTP requests
+	// that must succeed today for status_report to call the server
+	// healthy.
+	healthySuccessRate = 0.95
+	uptimeFileName     = "uptime.json"
+)
+
+// availabilityState counts starts and per-day request outcomes. With a
+// state directory the counts survive restarts in uptime.json, which is
+// saved every minute and on a graceful stop; a file still marked running
+// at startup means the previous process did not stop cleanly.
+type availabilityState struct {
+	mu   sync.Mutex
+	path string // empty: kept in memory only
+	rec  uptimeRecord
+}
+
+// uptimeRecord is the content of uptime.json.
+type uptimeRecord struct {
+	FirstStart   time.Time   `json:"first_start"`
+	LastStart    time.Time   `json:"last_start"`
+	LastStop     *time.Time  `json:"last_stop,omitempty"`
+	Starts       int         `json:"starts"`
+	UncleanStops int         `json:"unclean_stops"`
+	Running      bool        `json:"running"`
+	Days         []*dayStats `json:"days"` // oldest first
+}
+
+type dayStats struct {
+	Date         string `json:"date"` // UTC
+	ToolCalls    int    `json:"tool_calls"`
+	ToolFailed   int    `json:"tool_failed"` // protocol errors and panics
+	ToolErrors   int    `json:"tool_errors"` // error results, such as a bad URL
+	HTTPRequests int    `json:"http_requests"`
+	HTTP5xx      int    `json:"http_5xx"`
+}
+
+var availability = &availabilityState{rec: uptimeRecord{FirstStart: serverStarted, LastStart: serverStarted, Starts: 1, Running: true}}
+
+// open loads the counts kept in path and records this start there.
+func (a *availabilityState) open(path string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		var rec uptimeRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if rec.Running {
+			rec.UncleanStops++
+		}
+		rec.Starts++
+		rec.LastStart, rec.Running = serverStarted, true
+		a.rec = rec
+	}
+	a.path = path
+	return a.saveLocked()
+}
+
+func (a *availabilityState) saveLocked() error {
+	if a.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(a.rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := a.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.path)
+}
+
+// start saves the counts every minute.
+func (a *availabilityState) start() {
+	go func() {
+		for range time.Tick(time.Minute) {
+			a.mu.Lock()
+			err := a.saveLocked()
+			a.mu.Unlock()
+			if err != nil {
+				log.Printf("Uptime: %v", err)
+			}
+		}
+	}()
+}
+
+// stop records a graceful stop.
+func (a *availabilityState) stop() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	a.rec.Running, a.rec.LastStop = false, &now
+	if err := a.saveLocked(); err != nil {
+		log.Printf("Uptime: %v", err)
+	}
+}
+
+// todayLocked returns today's counts, starting a new day and dropping
+// the oldest as needed.
+func (a *availabilityState) todayLocked() *dayStats {
+	date := time.Now().UTC().Format(time.DateOnly)
+	if n := len(a.rec.Days); n > 0 && a.rec.Days[n-1].Date == date {
+		return a.rec.Days[n-1]
+	}
+	day := &dayStats{Date: date}
+	a.rec.Days = append(a.rec.Days, day)
+	if len(a.rec.Days) > availabilityDays {
+		a.rec.Days = a.rec.Days[len(a.rec.Days)-availabilityDays:]
+	}
+	return day
+}
+
+// recordCall counts a tool call by its callOutcome.
+func (a *availabilityState) recordCall(outcome string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	day := a.todayLocked()
+	day.ToolCalls++
+	switch outcome {
+	case "failed":
+		day.ToolFailed++
+	case "error":
+		day.ToolErrors++
+	}
+}
+
+// recordHTTP counts an answered HTTP request. Health checks are left out
+// so that probes do not dilute the rates.
+func (a *availabilityState) recordHTTP(path string, status int) {
+	switch path {
+	case "/health", "/healthz", "/livez", "/readyz":
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	day := a.todayLocked()
+	day.HTTPRequests++
+	if status >= 500 {
+		day.HTTP5xx++
+	}
+}
+
+// UptimeReport is the server://uptime resource.
+type UptimeReport struct {
+	Version       string            `json:"version"`
+	Started       time.Time         `json:"started" jsonschema:"Wall-clock time this process started"`
+	UptimeSeconds float64           `json:"uptime_seconds" jsonschema:"Seconds since start on the monotonic clock, unaffected by wall-clock changes"`
+	Uptime        string            `json:"uptime"`
+	Restarts      int               `json:"restarts" jsonschema:"Starts before this one"`
+	UncleanStops  int               `json:"unclean_stops" jsonschema:"Earlier processes that stopped without a graceful shutdown"`
+	FirstStart    time.Time         `json:"first_start"`
+	LastStop      *time.Time        `json:"last_stop,omitempty" jsonschema:"When the previous process stopped gracefully"`
+	Persisted     bool              `json:"persisted" jsonschema:"False when the counts only cover this process (no state directory)"`
+	Days          []DayAvailability `json:"days" jsonschema:"Per UTC day, newest first"`
+}
+
+// DayAvailability is one day of request outcomes.
+type DayAvailability struct {
+	Date            string   `json:"date"`
+	ToolCalls       int      `json:"tool_calls"`
+	ToolFailed      int      `json:"tool_failed" jsonschema:"Calls that failed with a protocol error or panic"`
+	ToolErrors      int      `json:"tool_errors" jsonschema:"Calls that returned an error result, such as an unreachable URL"`
+	ToolSuccessRate *float64 `json:"tool_success_rate,omitempty" jsonschema:"Share of calls that did not fail; error results count as served"`
+	HTTPRequests    int      `json:"http_requests" jsonschema:"HTTP requests other than health checks"`
+	HTTP5xx         int      `json:"http_5xx"`
+	HTTPSuccessRate *float64 `json:"http_success_rate,omitempty"`
+}
+
+func successRate(total, failed int) *float64 {
+	if total == 0 {
+		return nil
+	}
+	r := math.Round(float64(total-failed)/float64(total)*10000) / 10000
+	return &r
+}
+
+func (a *availabilityState) report() UptimeReport {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	uptime := time.Since(serverStarted)
+	rep := UptimeReport{
+		Version: version, Started: serverStarted.UTC(), UptimeSeconds: math.Round(uptime.Seconds()), Uptime: humanDuration(uptime),
+		Restarts: a.rec.Starts - 1, UncleanStops: a.rec.UncleanStops, FirstStart: a.rec.FirstStart.UTC(), LastStop: a.rec.LastStop,
+		Persisted: a.path != "", Days: []DayAvailability{},
+	}
+	for i := len(a.rec.Days) - 1; i >= 0; i-- {
+		d := a.rec.Days[i]
+		rep.Days = append(rep.Days, DayAvailability{
+			Date: d.Date, ToolCalls: d.ToolCalls, ToolFailed: d.ToolFailed, ToolErrors: d.ToolErrors, ToolSuccessRate: successRate(d.ToolCalls, d.ToolFailed),
+			HTTPRequests: d.HTTPRequests, HTTP5xx: d.HTTP5xx, HTTPSuccessRate: successRate(d.HTTPRequests, d.HTTP5xx),
+		})
+	}
+	return rep
+}
+
+func addUptimeResource(server *mcp.Server) {
+	server.AddResource(&mcp.Resource{
+		URI:         uptimeURI,
+		Name:        "uptime",
+		Title:       "Uptime and availability",
+		Description: "Process start time and monotonic uptime, restarts and unclean stops, and per-day tool call and HTTP request success rates for the last 30 days",
+		MIMEType:    "application/json",
+	}, func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		data, err := json.MarshalIndent(availability.report(), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return &mcp.ReadResourceResult{
+			Contents: []*mcp.ResourceContents{{URI: uptimeURI, MIMEType: "application/json", Text: string(data)}},
+		}, nil
+	})
+}
+
+/* ---------- Tool: status_report ---------- */
+
+type StatusReportArgs struct{}
+
+// StatusReportResult is the structured output of the status_report tool.
+type StatusReportResult struct {
+	Healthy       bool                   `json:"healthy"`
+	Summary       string                 `json:"summary"`
+	Problems      []string               `json:"problems,omitempty"`
+	Uptime        string                 `json:"uptime"`
+	UptimeSeconds float64                `json:"uptime_seconds"`
+	Restarts      int                    `json:"restarts"`
+	UncleanStops  int                    `json:"unclean_stops"`
+	Today         *DayAvailability       `json:"today,omitempty"`
+	Readiness     map[string]checkResult `json:"readiness" jsonschema:"The /readyz checks"`
+	Sessions      int                    `json:"sessions" jsonschema:"Open MCP sessions"`
+	OpenCircuits  []string               `json:"open_circuits,omitempty" jsonschema:"Outbound hosts whose circuit breaker is open"`
+}
+
+// StatusReportTool answers "is the server healthy?": it is when the
+// readiness checks pass and at least 95% of today's tool calls and HTTP
+// requests succeeded. Open circuits are reported but, being about
+// upstream hosts, do not make the server unhealthy.
+func StatusReportTool(ctx context.Context, req *mcp.CallToolRequest, in StatusReportArgs) (*mcp.CallToolResult, any, error) {
+	up := availability.report()
+	readiness := ready.run(ctx)
+	out := StatusReportResult{
+		Healthy: true, Uptime: up.Uptime, UptimeSeconds: up.UptimeSeconds, Restarts: up.Restarts, UncleanStops: up.UncleanStops,
+		Readiness: readiness.Checks, Sessions: len(liveSessions.ids()),
+	}
+	for _, name := range sortedKeys(readiness.Checks) {
+		if c := readiness.Checks[name]; c.Status == "failed" {
+			out.Healthy = false
+			out.Problems = append(out.Problems, fmt.Sprintf("readiness check %s failed: %s", name, c.Detail))
+		}
+	}
+	if len(up.Days) > 0 && up.Days[0].Date == time.Now().UTC().Format(time.DateOnly) {
+		today := up.Days[0]
+		out.Today = &today
+		if r := today.ToolSuccessRate; r != nil && *r < healthySuccessRate {
+			out.Healthy = false
+			out.Problems = append(out.Problems, fmt.Sprintf("%.1f%% of today's tool calls failed", (1-*r)*100))
+		}
+		if r := today.HTTPSuccessRate; r != nil && *r < healthySuccessRate {
+			out.Healthy = false
+			out.Problems = append(out.Problems, fmt.Sprintf("%.1f%% of today's HTTP requests got a 5xx answer", (1-*r)*100))
+		}
+	}
+	if breakers != nil {
+		out.OpenCircuits = breakers.openHosts()
+		for _, host := range out.OpenCircuits {
+			out.Problems = append(out.Problems, "circuit open for "+host)
+		}
+	}
+
+	var b strings.Builder
+	if out.Healthy {
+		b.WriteString("Healthy")
+	} else {
+		b.WriteString("Unhealthy")
+	}
+	fmt.Fprintf(&b, ": up %s (%d restarts, %d unclean)", out.Uptime, out.Restarts, out.UncleanStops)
+	if t := out.Today; t != nil && t.ToolSuccessRate != nil {
+		fmt.Fprintf(&b, ", %d tool calls today with %.1f%% success", t.ToolCalls, *t.ToolSuccessRate*100)
+	} else {
+		b.WriteString(", no tool calls today")
+	}
+	fmt.Fprintf(&b, ", %d open sessions", out.Sessions)
+	out.Summary = b.String() + "."
+	text := out.Summary
+	if len(out.Problems) > 0 {
+		text += "\n- " + strings.Join(out.Problems, "\n- ")
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: text}},
+	}, out, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* ---------- Outbound circuit breakers ---------- */
+
+// Breaker states, also exported as the value of mcp_breaker_state.
+const (
+	breakerClosed   = 0
+	breakerHalfOpen = 1
+	breakerOpen     = 2
+)
+
+var breakerStateNames = map[int]string{breakerClosed: "closed", breakerHalfOpen: "half-open", breakerOpen: "open"}
+
+// breakerSet keeps one circuit breaker per outbound host. A host's
+// circuit opens after Failures consecutive failed requests (transport
+// errors or 5xx responses); while open, requests fail immediately. After
+// Cooldown a single probe request is let through: success closes the
+// circuit, failure opens it for another cooldown.
+type breakerSet struct {
+	Failures int
+	Cooldown time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostBreaker
+}
+
+type hostBreaker struct {
+	state     int
+	failures  int // consecutive
+	openUntil time.Time
+	probing   bool
+	trips     int
+	rejected  int
+	lastError string
+}
+
+// breakers guards every request made through httpClient; nil disables it.
+var breakers *breakerSet
+
+func newBreakerSet(failures int, cooldown time.Duration) *breakerSet {
+	return &breakerSet{Failures: failures, Cooldown: cooldown, hosts: make(map[string]*hostBreaker)}
+}
+
+// circuitOpenError is returned for requests to a host whose circuit is
+// open.
+type circuitOpenError struct {
+	Host       string
+	RetryAfter time.Duration
+	LastError  string
+}
+
+func (e *circuitOpenError) Error() string {
+	return fmt.Sprintf("circuit open for %s after repeated failures (last: %s); retry after %ds",
+		e.Host, e.LastError, int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// acquire decides whether a request to host may proceed.
+func (s *breakerSet) acquire(host string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.hosts[host]
+	if b == nil {
+		b = &hostBreaker{}
+		s.hosts[host] = b
+	}
+	switch b.state {
+	case breakerOpen:
+		if wait := time.Until(b.openUntil); wait > 0 {
+			b.rejected++
+			return &circuitOpenError{Host: host, RetryAfter: wait, LastError: b.lastError}
+		}
+		b.state = breakerHalfOpen
+		fallthrough
+	case breakerHalfOpen:
+		if b.probing {
+			b.rejected++
+			return &circuitOpenError{Host: host, RetryAfter: time.Second, LastError: b.lastError}
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record updates host's breaker with the outcome of a request.
+func (s *breakerSet) record(host string, failure error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.hosts[host]
+	if b == nil {
+		return
+	}
+	b.probing = false
+	if failure == nil {
+		b.state, b.failures = breakerClosed, 0
+		if b.trips == 0 {
+			// Only hosts that have tripped are worth remembering.
+			delete(s.hosts, host)
+		}
+		return
+	}
+	b.failures++
+	b.lastError = failure.Error()
+	if b.state == breakerHalfOpen || b.failures >= s.Failures {
+		if b.state != breakerOpen {
+			b.trips++
+		}
+		b.state = breakerOpen
+		b.openUntil = time.Now().Add(s.Cooldown)
+	}
+}
+
+// release ends a request without counting it either way.
+func (s *breakerSet) release(host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b := s.hosts[host]; b != nil {
+		b.probing = false
+	}
+}
+
+// breakerStatus is a snapshot of one host's breaker.
+type breakerStatus struct {
+	Host       string `json:"host"`
+	State      string `json:"state"`
+	Failures   int    `json:"consecutive_failures"`
+	Trips      int    `json:"trips"`
+	Rejected   int    `json:"rejected"`
+	RetryAfter int    `json:"retry_after_s,omitempty"`
+	LastError  string `json:"last_error,omitempty"`
+
+	state int
+}
+
+func (s *breakerSet) snapshot() []breakerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]breakerStatus, 0, len(s.hosts))
+	for _, host := range sortedKeys(s.hosts) {
+		b := s.hosts[host]
+		st := breakerStatus{Host: host, State: breakerStateNames[b.state], Failures: b.failures,
+			Trips: b.trips, Rejected: b.rejected, LastError: b.lastError, state: b.state}
+		if wait := time.Until(b.openUntil); b.state == breakerOpen && wait > 0 {
+			st.RetryAfter = int(math.Ceil(wait.Seconds()))
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// breakersHandler serves GET /admin/breakers: each outbound host's
+// circuit state, failure and trip counts, and its last error.
+func breakersHandler(w http.ResponseWriter, r *http.Request) {
+	writeGatewayJSON(w, http.StatusOK, breakers.snapshot())
+}
+
+// collectMetrics exports breaker state for /metrics.
+func (s *breakerSet) collectMetrics(w *metricsWriter) {
+	snap := s.snapshot()
+	w.family("mcp_breaker_state", "gauge", "Outbound circuit state per host (0 closed, 1 half-open, 2 open)")
+	for _, b := range snap {
+		w.sample("mcp_breaker_state", float64(b.state), "host", b.Host)
+	}
+	w.family("mcp_breaker_trips_total", "counter", "Times the host's circuit opened")
+	for _, b := range snap {
+		w.sample("mcp_breaker_trips_total", float64(b.Trips), "host", b.Host)
+	}
+	w.family("mcp_breaker_rejected_total", "counter", "Requests failed fast because the host's circuit was open")
+	for _, b := range snap {
+		w.sample("mcp_breaker_rejected_total", float64(b.Rejected), "host", b.Host)
+	}
+}
+
+// breakerTransport applies the breakers to every outbound round trip,
+// including each redirect hop.
+type breakerTransport struct {
+	set  *breakerSet
+	next http.RoundTripper
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	if err := t.set.acquire(host); err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil && errors.Is(req.Context().Err(), context.Canceled):
+		// The caller gave up; that says nothing about the host.
+		t.set.release(host)
+	case err != nil:
+		t.set.record(host, err)
+	case resp.StatusCode >= 500:
+		t.set.record(host, errors.New(resp.Status))
+	default:
+		t.set.record(host, nil)
+	}
+	return resp, err
+}
+
+// openHosts lists the hosts whose circuit is open now.
+func (s *breakerSet) openHosts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var hosts []string
+	for _, host := range sortedKeys(s.hosts) {
+		if b := s.hosts[host]; b.state == breakerOpen && time.Now().Before(b.openUntil) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
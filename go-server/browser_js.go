@@ -0,0 +1,156 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall/js"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Browser build (js/wasm) ---------- */
+
+// In a browser the server runs as a Web Worker and talks to its page with
+// postMessage. Tools that need the operating system are not available, and
+// outbound requests go through the browser's Fetch API, so they are subject
+// to CORS.
+const (
+	browserBuild = true
+	defaultMode  = "browser"
+)
+
+// browserUnavailableFlags need a filesystem, processes, raw sockets or
+// DNS, none of which a browser offers. Requests go through the browser's
+// own proxy settings, connections and header limits.
+var browserUnavailableFlags = []string{
+	"fs-root", "enable-exec", "enable-net-diag", "workshop", "snapshot-dir",
+	"audit", "audit-log", "fetch-deny-private", "dns-pins", "fetch-proxy",
+	"fetch-connect-timeout", "fetch-max-header-bytes", "download-max-size",
+	"download-quota", "download-timeout", "fetch-max-conns-per-host",
+	"fetch-max-idle-per-host", "fetch-idle-timeout", "fetch-http2",
+}
+
+// checkBrowserFlags rejects what a browser build cannot do.
+func checkBrowserFlags(mode string, given map[string]bool) error {
+	if mode != "browser" {
+		return fmt.Errorf("-mode %s is not available in a browser build", mode)
+	}
+	for _, name := range browserUnavailableFlags {
+		if given[name] {
+			return fmt.Errorf("-%s is not available in a browser build", name)
+		}
+	}
+	return nil
+}
+
+// notifyReload does nothing: a browser sends no signals.
+func notifyReload(chan<- os.Signal) {}
+
+// browserPendingVar is the global array in which the worker script may
+// queue the messages that arrive before the server is listening.
+const browserPendingVar = "mcpPending"
+
+// browserTransport connects the server to the page that started the
+// worker. Each message the page posts is one JSON-RPC message, as an
+// object or a JSON string; each message the server sends is posted back
+// as an object.
+type browserTransport struct{}
+
+func newBrowserTransport() mcp.Transport { return browserTransport{} }
+
+func (browserTransport) Connect(context.Context) (mcp.Connection, error) {
+	c := &browserConn{ready: make(chan struct{}, 1), done: make(chan struct{})}
+	self := js.Global()
+	// The callback runs on the JavaScript event loop and must not block, so
+	// messages are queued for Read rather than sent on a channel.
+	c.onMessage = js.FuncOf(func(_ js.Value, args []js.Value) any {
+		c.push(args[0].Get("data"))
+		return nil
+	})
+	self.Set("onmessage", c.onMessage)
+	if pending := self.Get(browserPendingVar); pending.InstanceOf(self.Get("Array")) {
+		for i := 0; i < pending.Length(); i++ {
+			c.push(pending.Index(i))
+		}
+		self.Delete(browserPendingVar)
+	}
+	return c, nil
+}
+
+type browserConn struct {
+	onMessage js.Func
+	ready     chan struct{} // signalled when queue gains a message
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu    sync.Mutex
+	queue [][]byte
+}
+
+func (c *browserConn) push(data js.Value) {
+	var raw string
+	if data.Type() == js.TypeString {
+		raw = data.String()
+	} else {
+		raw = js.Global().Get("JSON").Call("stringify", data).String()
+	}
+	c.mu.Lock()
+	c.queue = append(c.queue, []byte(raw))
+	c.mu.Unlock()
+	select {
+	case c.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (c *browserConn) Read(ctx context.Context) (jsonrpc.Message, error) {
+	for {
+		c.mu.Lock()
+		if len(c.queue) > 0 {
+			raw := c.queue[0]
+			c.queue = c.queue[1:]
+			c.mu.Unlock()
+			return jsonrpc.DecodeMessage(raw)
+		}
+		c.mu.Unlock()
+		select {
+		case <-c.ready:
+		case <-c.done:
+			return nil, io.EOF
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c *browserConn) Write(_ context.Context, msg jsonrpc.Message) error {
+	select {
+	case <-c.done:
+		return io.ErrClosedPipe
+	default:
+	}
+	data, err := jsonrpc.EncodeMessage(msg)
+	if err != nil {
+		return err
+	}
+	self := js.Global()
+	self.Call("postMessage", self.Get("JSON").Call("parse", string(data)))
+	return nil
+}
+
+func (c *browserConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		js.Global().Set("onmessage", js.Null())
+		c.onMessage.Release()
+	})
+	return nil
+}
+
+func (c *browserConn) SessionID() string { return "" }
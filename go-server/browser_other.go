@@ -0,0 +1,37 @@
+//go:build !js
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	browserBuild = false
+	defaultMode  = "stdio"
+)
+
+// errBrowserUnsupported is returned by -mode browser outside a js/wasm
+// build.
+var errBrowserUnsupported = errors.New("-mode browser needs a GOOS=js GOARCH=wasm build")
+
+func checkBrowserFlags(string, map[string]bool) error { return nil }
+
+// notifyReload delivers SIGHUP, which reloads -config.
+func notifyReload(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGHUP)
+}
+
+type browserTransport struct{}
+
+func newBrowserTransport() mcp.Transport { return browserTransport{} }
+
+func (browserTransport) Connect(context.Context) (mcp.Connection, error) {
+	return nil, errBrowserUnsupported
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Outbound budget per tool call ---------- */
+
+// callLimits bound the upstream work one tool call may cause, across all
+// of its requests: redirect hops, fallbacks such as url_status's GET after
+// HEAD, and composite tools that fan out. A zero field is unlimited.
+type callLimits struct {
+	Requests int
+	Bytes    int64
+	Time     time.Duration
+}
+
+// callBudgetLimits is configured from the -call-max-* flags.
+var callBudgetLimits = callLimits{Requests: 25, Bytes: 50 << 20, Time: 2 * time.Minute}
+
+// callBudget is the remaining allowance of one tool call.
+type callBudget struct {
+	limits callLimits
+	start  time.Time
+
+	mu       sync.Mutex
+	requests int
+	bytes    int64
+}
+
+type callBudgetKey struct{}
+
+// budgetExceededCount counts rejected requests and cut-off bodies per
+// limit, for /metrics.
+var budgetExceededCount = struct {
+	sync.Mutex
+	byLimit map[string]int
+}{byLimit: make(map[string]int)}
+
+// errBudgetExceeded is wrapped by the errors of requests the budget
+// refused or cut short, so that tools can tell them from upstream errors.
+var errBudgetExceeded = errors.New("tool call budget exceeded")
+
+// budgetExceeded counts and describes a call running into limit.
+func budgetExceeded(limit, max string) error {
+	budgetExceededCount.Lock()
+	budgetExceededCount.byLimit[limit]++
+	budgetExceededCount.Unlock()
+	return fmt.Errorf("%w: at most %s per call", errBudgetExceeded, max)
+}
+
+// budgetToolMiddleware gives each tool call a fresh budget, which every
+// outbound request made with the call's context draws from.
+func budgetToolMiddleware(tool *mcp.Tool, next ToolFunc) ToolFunc {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, any, error) {
+		b := &callBudget{limits: callBudgetLimits, start: time.Now()}
+		return next(context.WithValue(ctx, callBudgetKey{}, b), req)
+	}
+}
+
+// take reserves one request, or fails if the call has used up its
+// requests, bytes or time.
+func (b *callBudget) take() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l := b.limits
+	if l.Requests > 0 && b.requests >= l.Requests {
+		return budgetExceeded("requests", fmt.Sprintf("%d upstream requests", l.Requests))
+	}
+	if l.Bytes > 0 && b.bytes >= l.Bytes {
+		return budgetExceeded("bytes", fmt.Sprintf("%d upstream response bytes", l.Bytes))
+	}
+	if l.Time > 0 && time.Since(b.start) >= l.Time {
+		return budgetExceeded("time", fmt.Sprintf("%s of upstream time", l.Time))
+	}
+	b.requests++
+	return nil
+}
+
+// usage returns the requests made and response bytes read so far.
+func (b *callBudget) usage() (requests int, bytes int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.requests, b.bytes
+}
+
+// read charges n response bytes, failing once the call is over its byte
+// limit.
+func (b *callBudget) read(n int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bytes += int64(n)
+	if l := b.limits.Bytes; l > 0 && b.bytes > l {
+		return budgetExceeded("bytes", fmt.Sprintf("%d upstream response bytes", l))
+	}
+	return nil
+}
+
+// budgetTransport enforces the budget of the tool call a request belongs
+// to. Requests made outside a tool call are not limited.
+type budgetTransport struct {
+	next http.RoundTripper
+}
+
+func (t *budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b, ok := req.Context().Value(callBudgetKey{}).(*callBudget)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+	if err := b.take(); err != nil {
+		return nil, err
+	}
+	cancel := context.CancelFunc(func() {})
+	if b.limits.Time > 0 {
+		// The whole call shares one deadline, so a slow upstream cannot
+		// stretch it by answering just inside each request's timeout.
+		var ctx context.Context
+		ctx, cancel = context.WithDeadline(req.Context(), b.start.Add(b.limits.Time))
+		req = req.WithContext(ctx)
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &budgetBody{ReadCloser: resp.Body, budget: b, cancel: cancel}
+	return resp, nil
+}
+
+// budgetBody charges the bytes read from a response to the budget.
+type budgetBody struct {
+	io.ReadCloser
+	budget *callBudget
+	cancel context.CancelFunc
+	err    error
+}
+
+func (r *budgetBody) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		if r.err = r.budget.read(n); r.err != nil {
+			return n, r.err
+		}
+	}
+	return n, err
+}
+
+func (r *budgetBody) Close() error {
+	defer r.cancel()
+	return r.ReadCloser.Close()
+}
+
+// collectBudgetMetrics exports how often calls ran out of budget.
+func collectBudgetMetrics(w *metricsWriter) {
+	budgetExceededCount.Lock()
+	defer budgetExceededCount.Unlock()
+	w.family("mcp_call_budget_exceeded_total", "counter", "Outbound requests refused or cut short by a tool call's budget, per limit")
+	for _, limit := range sortedKeys(budgetExceededCount.byLimit) {
+		w.sample("mcp_call_budget_exceeded_total", float64(budgetExceededCount.byLimit[limit]), "limit", limit)
+	}
+}
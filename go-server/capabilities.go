@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Experimental capabilities ---------- */
+
+// experimentalFeature is a behavior negotiated per session under
+// capabilities.experimental in initialize. A client turns a feature on
+// with true or an object, and off with false; a feature it does not
+// mention keeps its Default. Features that change what older clients get
+// should ship with Default false, so that only clients asking for them
+// see the change. The initialize result tells the client, for each
+// feature the server has, its version and whether the session got it:
+//
+//	"experimental": {"mcp-demo/signatures": {"version": 1, "enabled": true}}
+type experimentalFeature struct {
+	Key         string
+	Version     int
+	Default     bool
+	Description string
+	// Available reports whether the server runs with the feature at all;
+	// nil means always.
+	Available func() bool
+}
+
+var (
+	featureSignatures = &experimentalFeature{
+		Key: "mcp-demo/signatures", Version: 1, Default: true,
+		Description: "Ed25519 signature in each tool result's _meta",
+		Available:   func() bool { return signingKey != nil },
+	}
+	featurePriority = &experimentalFeature{
+		Key: "mcp-demo/priority", Version: 1, Default: true,
+		Description: "Priority class taken from the tools/call _meta",
+		Available:   func() bool { return scheduler != nil },
+	}
+	featureResultMeta = &experimentalFeature{
+		Key: "mcp-demo/result-meta", Version: 1, Default: true,
+		Description: "Request ID, trace context, cost, cache status and warnings in each tool result's _meta",
+	}
+)
+
+// experimentalFeatures lists the features in the order they are reported.
+var experimentalFeatures = []*experimentalFeature{featureSignatures, featurePriority, featureResultMeta}
+
+func (f *experimentalFeature) available() bool {
+	return f.Available == nil || f.Available()
+}
+
+// requested returns what the client asked for f at initialize, falling
+// back to f's default.
+func (f *experimentalFeature) requested(params *mcp.InitializeParams) bool {
+	if params == nil || params.Capabilities == nil {
+		return f.Default
+	}
+	switch v := params.Capabilities.Experimental[f.Key].(type) {
+	case bool:
+		return v
+	case map[string]any:
+		return true
+	}
+	return f.Default
+}
+
+// featureEnabled reports whether session ss has feature f. Calls without
+// a session, such as those of internal callers, get the default.
+func featureEnabled(ss *mcp.ServerSession, f *experimentalFeature) bool {
+	if !f.available() {
+		return false
+	}
+	if ss == nil {
+		return f.Default
+	}
+	return f.requested(ss.InitializeParams())
+}
+
+// sessionFeatures returns the keys of the features ss has.
+func sessionFeatures(ss *mcp.ServerSession) []string {
+	var keys []string
+	for _, f := range experimentalFeatures {
+		if featureEnabled(ss, f) {
+			keys = append(keys, f.Key)
+		}
+	}
+	return keys
+}
+
+// capabilitiesMiddleware answers initialize with the state of each
+// available feature for the new session.
+func capabilitiesMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		result, err := next(ctx, method, req)
+		init, ok := req.(*mcp.ServerRequest[*mcp.InitializeParams])
+		if method != "initialize" || err != nil || !ok {
+			return result, err
+		}
+		res, ok := result.(*mcp.InitializeResult)
+		if !ok {
+			return result, err
+		}
+		if res.Capabilities == nil {
+			res.Capabilities = &mcp.ServerCapabilities{}
+		}
+		if res.Capabilities.Experimental == nil {
+			res.Capabilities.Experimental = make(map[string]any)
+		}
+		for _, f := range experimentalFeatures {
+			if f.available() {
+				res.Capabilities.Experimental[f.Key] = map[string]any{"version": f.Version, "enabled": f.requested(init.Params), "description": f.Description}
+			}
+		}
+		return result, err
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- MCP logging (notifications/message) ---------- */
+
+// clientLogger sends notifications/message log events for one tool call
+// to the calling session. The SDK answers logging/setLevel and drops
+// events below the level the client set, or all of them until it sets
+// one. A nil logger drops everything, so callers need not check.
+//
+// Events go to the client only; the server's own log (-log-level) is
+// independent of the level a client picks.
+type clientLogger struct {
+	ctx     context.Context
+	session *mcp.ServerSession
+	name    string // the tool, sent as the event's logger
+}
+
+type clientLoggerKey struct{}
+
+// clientLogToolMiddleware gives every call a logger, which handlers and
+// the outbound transport find with clientLog, and logs the call's start
+// and outcome.
+func clientLogToolMiddleware(tool *mcp.Tool, next ToolFunc) ToolFunc {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, any, error) {
+		if req.Session == nil {
+			return next(ctx, req)
+		}
+		// The outcome of a cancelled call is still worth reporting.
+		l := &clientLogger{ctx: context.WithoutCancel(ctx), session: req.Session, name: tool.Name}
+		ctx = context.WithValue(ctx, clientLoggerKey{}, l)
+		l.debug("call started", nil)
+
+		start := time.Now()
+		res, out, err := next(ctx, req)
+		fields := map[string]any{"duration_ms": roundMs(time.Since(start))}
+		switch {
+		case cancelled(ctx):
+			l.info("call cancelled", fields)
+		case err != nil:
+			fields["error"] = err.Error()
+			l.error("call failed", fields)
+		case res != nil && res.IsError:
+			fields["error"] = string(truncateUTF8([]byte(resultText(res)), 500))
+			l.warning("call returned an error result", fields)
+		default:
+			l.info("call finished", fields)
+		}
+		return res, out, err
+	}
+}
+
+// clientLog returns the logger of the tool call ctx belongs to, or nil.
+func clientLog(ctx context.Context) *clientLogger {
+	l, _ := ctx.Value(clientLoggerKey{}).(*clientLogger)
+	return l
+}
+
+func (l *clientLogger) debug(msg string, fields map[string]any)   { l.log("debug", msg, fields) }
+func (l *clientLogger) info(msg string, fields map[string]any)    { l.log("info", msg, fields) }
+func (l *clientLogger) warning(msg string, fields map[string]any) { l.log("warning", msg, fields) }
+func (l *clientLogger) error(msg string, fields map[string]any)   { l.log("error", msg, fields) }
+
+// log sends one event whose data is an object with the message under
+// "message" next to fields.
+func (l *clientLogger) log(level mcp.LoggingLevel, msg string, fields map[string]any) {
+	if l == nil {
+		return
+	}
+	data := map[string]any{"message": msg}
+	for k, v := range fields {
+		data[k] = v
+	}
+	l.session.Log(l.ctx, &mcp.LoggingMessageParams{Level: level, Logger: l.name, Data: data})
+}
+
+// resultText joins the text content of a result.
+func resultText(res *mcp.CallToolResult) string {
+	var s string
+	for _, c := range res.Content {
+		if t, ok := c.(*mcp.TextContent); ok {
+			s += t.Text
+		}
+	}
+	return s
+}
+
+// clientLogTransport logs each upstream request a tool call makes: at
+// debug level when it gets a response, as a warning when it fails,
+// including requests the call budget or a circuit breaker refused.
+type clientLogTransport struct {
+	next http.RoundTripper
+}
+
+func (t *clientLogTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	l := clientLog(req.Context())
+	if l == nil {
+		return t.next.RoundTrip(req)
+	}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	fields := map[string]any{"method": req.Method, "url": req.URL.Redacted(), "duration_ms": roundMs(time.Since(start))}
+	if err != nil {
+		fields["error"] = err.Error()
+		l.warning("upstream request failed", fields)
+		return resp, err
+	}
+	fields["status"] = resp.StatusCode
+	l.debug("upstream request", fields)
+	return resp, nil
+}
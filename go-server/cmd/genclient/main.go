@@ -0,0 +1,265 @@
+// Command genclient generates typed Go bindings for an MCP server's
+// tools. It starts the server over stdio, lists its tools and writes one
+// argument struct, one result struct (for tools with an output schema)
+// and one method per tool. It is run through go:generate in
+// pkg/democlient.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"mcp-demo-server/pkg/mcpclient"
+)
+
+func main() {
+	server := flag.String("server", "go run ../..", "Command that starts the MCP server in stdio mode")
+	pkg := flag.String("package", "democlient", "Package name of the generated file")
+	out := flag.String("out", "client_gen.go", "Output file")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	argv := strings.Fields(*server)
+	if len(argv) == 0 {
+		log.Fatal("-server is empty")
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stderr = os.Stderr
+
+	client, err := mcpclient.Connect(ctx, mcpclient.Options{
+		Transport: &mcp.CommandTransport{Command: cmd},
+		Name:      "genclient",
+	})
+	if err != nil {
+		log.Fatalf("Failed to start %q: %v", *server, err)
+	}
+	defer client.Close()
+
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		log.Fatalf("Failed to list tools: %v", err)
+	}
+
+	src, err := generate(*pkg, tools)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Wrote %d tool bindings to %s", len(tools), *out)
+}
+
+// generator accumulates type declarations; nested object schemas become
+// named structs of their own.
+type generator struct {
+	types bytes.Buffer
+}
+
+func generate(pkg string, tools []*mcp.Tool) ([]byte, error) {
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+
+	g := &generator{}
+	var methods bytes.Buffer
+	for _, tool := range tools {
+		name := goName(tool.Name)
+
+		in, err := toSchema(tool.InputSchema)
+		if err != nil {
+			return nil, fmt.Errorf("%s input schema: %w", tool.Name, err)
+		}
+		argsType := name + "Args"
+		g.declare(argsType, fmt.Sprintf("%s holds the arguments of the %s tool.", argsType, tool.Name), in)
+
+		fmt.Fprintf(&methods, "\n%s", comment(fmt.Sprintf("%s calls the %s tool: %s", name, tool.Name, tool.Description)))
+		if tool.OutputSchema == nil {
+			fmt.Fprintf(&methods, "func (c *Client) %s(ctx context.Context, args %s) (string, error) {\n", name, argsType)
+			fmt.Fprintf(&methods, "\tresult, err := c.CallTool(ctx, %q, args)\n", tool.Name)
+			fmt.Fprintf(&methods, "\tif err != nil {\n\t\treturn \"\", err\n\t}\n")
+			fmt.Fprintf(&methods, "\treturn mcpclient.Text(result), nil\n}\n")
+			continue
+		}
+
+		outSchema, err := toSchema(tool.OutputSchema)
+		if err != nil {
+			return nil, fmt.Errorf("%s output schema: %w", tool.Name, err)
+		}
+		resultType := name + "Result"
+		g.declare(resultType, fmt.Sprintf("%s is the structured result of the %s tool.", resultType, tool.Name), outSchema)
+		fmt.Fprintf(&methods, "func (c *Client) %s(ctx context.Context, args %s) (%s, error) {\n", name, argsType, resultType)
+		fmt.Fprintf(&methods, "\treturn mcpclient.CallToolTyped[%s](ctx, c.Client, %q, args)\n}\n", resultType, tool.Name)
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by genclient; DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	if len(tools) > 0 {
+		fmt.Fprintf(&src, "import (\n\t\"context\"\n\n\t\"mcp-demo-server/pkg/mcpclient\"\n)\n")
+	}
+	src.Write(g.types.Bytes())
+	src.Write(methods.Bytes())
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w\n%s", err, src.Bytes())
+	}
+	return formatted, nil
+}
+
+// declare emits a struct type for an object schema.
+func (g *generator) declare(name, doc string, s *jsonschema.Schema) {
+	required := make(map[string]bool)
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	props := make([]string, 0, len(s.Properties))
+	for p := range s.Properties {
+		props = append(props, p)
+	}
+	sort.Strings(props)
+
+	var body bytes.Buffer
+	for _, p := range props {
+		prop := s.Properties[p]
+		field := goName(p)
+		typ := g.goType(name+field, prop, !required[p])
+		if prop.Description != "" {
+			body.WriteString(indent(comment(prop.Description)))
+		}
+		tag := p
+		if !required[p] {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(&body, "\t%s %s `json:%q`\n", field, typ, tag)
+	}
+	fmt.Fprintf(&g.types, "\n%stype %s struct {\n%s}\n", comment(doc), name, body.String())
+}
+
+// goType maps a property schema to a Go type. Optional booleans become
+// pointers so that an explicit false is still sent.
+func (g *generator) goType(name string, s *jsonschema.Schema, optional bool) string {
+	typ, nullable := schemaType(s)
+	var t string
+	switch typ {
+	case "string":
+		t = "string"
+	case "integer":
+		t = "int"
+	case "number":
+		t = "float64"
+	case "boolean":
+		t = "bool"
+		if optional {
+			return "*bool"
+		}
+	case "array":
+		if s.Items == nil {
+			return "[]any"
+		}
+		return "[]" + g.goType(singular(name), s.Items, false)
+	case "object":
+		switch {
+		case len(s.Properties) > 0:
+			g.declare(name, fmt.Sprintf("%s is a nested object in a tool schema.", name), s)
+			t = name
+		case s.AdditionalProperties != nil && len(s.AdditionalProperties.Properties) == 0:
+			return "map[string]" + g.goType(name+"Value", s.AdditionalProperties, false)
+		default:
+			return "map[string]any"
+		}
+	default:
+		return "any"
+	}
+	if nullable {
+		return "*" + t
+	}
+	return t
+}
+
+// schemaType returns the single non-null type of s and whether null is
+// also allowed.
+func schemaType(s *jsonschema.Schema) (string, bool) {
+	if s.Type != "" {
+		return s.Type, false
+	}
+	var typ string
+	nullable := false
+	for _, t := range s.Types {
+		if t == "null" {
+			nullable = true
+		} else if typ == "" {
+			typ = t
+		} else {
+			return "", nullable
+		}
+	}
+	return typ, nullable
+}
+
+// singular names the element type of an array-typed field.
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "s"):
+		return strings.TrimSuffix(name, "s")
+	}
+	return name + "Item"
+}
+
+func toSchema(v any) (*jsonschema.Schema, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var s jsonschema.Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// initialisms are upper-cased whole when they appear as a name part.
+var initialisms = map[string]bool{"id": true, "url": true, "uri": true, "http": true, "json": true, "ip": true, "utc": true, "tz": true, "ok": true, "mtu": true}
+
+// goName converts snake_case or kebab-case to an exported Go name.
+func goName(s string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == '_' || r == '-' || r == '.' || r == ' ' }) {
+		if initialisms[strings.ToLower(part)] {
+			b.WriteString(strings.ToUpper(part))
+			continue
+		}
+		r := []rune(part)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	return b.String()
+}
+
+func comment(text string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		b.WriteString("// " + line + "\n")
+	}
+	return b.String()
+}
+
+func indent(s string) string {
+	return "\t" + strings.ReplaceAll(strings.TrimSuffix(s, "\n"), "\n", "\n\t") + "\n"
+}
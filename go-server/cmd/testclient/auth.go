@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Environment variables read when the auth flags are not given, which
+// keeps credentials out of the process list and shell history.
+const (
+	headerEnvVar      = "MCP_HEADER" // one header per line
+	bearerTokenEnvVar = "MCP_BEARER_TOKEN"
+	basicAuthEnvVar   = "MCP_BASIC_AUTH"
+)
+
+// authHeaders builds the headers sent with every request to the server
+// from -header ("Name: value"), -bearer-token and -basic-auth
+// ("user:password"), falling back to their environment variables.
+func authHeaders(headers []string, bearerToken, basicAuth string) (http.Header, error) {
+	if len(headers) == 0 {
+		for _, line := range strings.Split(os.Getenv(headerEnvVar), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				headers = append(headers, line)
+			}
+		}
+	}
+	if bearerToken == "" {
+		bearerToken = os.Getenv(bearerTokenEnvVar)
+	}
+	if basicAuth == "" {
+		basicAuth = os.Getenv(basicAuthEnvVar)
+	}
+
+	h := make(http.Header)
+	for _, header := range headers {
+		name, value, ok := strings.Cut(header, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("-header %q: want \"Name: value\"", header)
+		}
+		h.Add(name, strings.TrimSpace(value))
+	}
+	if bearerToken != "" && basicAuth != "" {
+		return nil, fmt.Errorf("-bearer-token and -basic-auth both set the Authorization header; give one")
+	}
+	if (bearerToken != "" || basicAuth != "") && h.Get("Authorization") != "" {
+		return nil, fmt.Errorf("-header sets Authorization, and so does -bearer-token or -basic-auth; give one")
+	}
+	if bearerToken != "" {
+		h.Set("Authorization", "Bearer "+bearerToken)
+	}
+	if basicAuth != "" {
+		if !strings.Contains(basicAuth, ":") {
+			return nil, fmt.Errorf("-basic-auth: want user:password")
+		}
+		h.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(basicAuth)))
+	}
+	return h, nil
+}
+
+// withHeaders adds header to the requests c sends to the host of
+// endpoint: the MCP endpoint, the WebSocket handshake and the key
+// fetches. A redirect to another host does not get them.
+func withHeaders(c *http.Client, endpoint string, header http.Header) *http.Client {
+	if len(header) == 0 {
+		return c
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return c
+	}
+	next := c.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &http.Client{Transport: &headerTransport{host: u.Host, header: header, next: next}}
+}
+
+type headerTransport struct {
+	host   string
+	header http.Header
+	next   http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != t.host {
+		return t.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	for name, values := range t.header {
+		req.Header[name] = values
+	}
+	return t.next.RoundTrip(req)
+}
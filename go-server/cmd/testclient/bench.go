@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mcp-demo-server/pkg/mcpclient"
+)
+
+// Outcomes of a bench call besides outcomeOK and outcomeToolError.
+const (
+	benchTimeout = "timeout" // no answer within -call-timeout
+	benchError   = "error"   // a JSON-RPC or transport error
+)
+
+// benchOptions configure `testclient bench`.
+type benchOptions struct {
+	Tool        string
+	Args        map[string]any
+	Concurrency int
+	Sessions    int
+	Duration    time.Duration
+	CallTimeout time.Duration
+}
+
+// benchWorker is the tally of one worker; workers do not share state
+// while running.
+type benchWorker struct {
+	latencies []time.Duration // of calls answered, tool errors included
+	outcomes  map[string]int
+	lastErr   string
+}
+
+// runBench implements `testclient [flags] bench [bench flags]`: it calls
+// one tool from -concurrency workers for -duration and reports
+// throughput, latency percentiles and error rates.
+func runBench(config Config, args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	tool := fs.String("tool", "echotest", "Tool to call")
+	toolArgs := fs.String("args", `{"message":"bench"}`, "Tool arguments as JSON (the default suits echotest)")
+	concurrency := fs.Int("concurrency", 10, "Calls in flight at once")
+	sessions := fs.Int("sessions", 1, "Sessions the workers share, each its own connection; up to -concurrency")
+	duration := fs.Duration("duration", 10*time.Second, "How long to keep calling")
+	callTimeout := fs.Duration("call-timeout", 10*time.Second, "A call without an answer after this long counts as a timeout")
+	fs.Parse(args)
+
+	opts := benchOptions{
+		Tool:        *tool,
+		Concurrency: *concurrency,
+		Sessions:    *sessions,
+		Duration:    *duration,
+		CallTimeout: *callTimeout,
+	}
+	if err := json.Unmarshal([]byte(*toolArgs), &opts.Args); err != nil {
+		log.Fatalf("Invalid -args: %v", err)
+	}
+	if opts.Concurrency < 1 {
+		log.Fatalf("Invalid -concurrency: must be positive")
+	}
+	if opts.Sessions < 1 || opts.Sessions > opts.Concurrency {
+		log.Fatalf("Invalid -sessions: must be between 1 and -concurrency")
+	}
+	if opts.Duration <= 0 {
+		log.Fatalf("Invalid -duration: must be positive")
+	}
+
+	if err := benchServer(config, opts); err != nil {
+		log.Fatalf("Benchmark failed: %v", err)
+	}
+}
+
+func benchServer(config Config, opts benchOptions) error {
+	fmt.Printf("Connecting %d session(s) to %s...\n", opts.Sessions, config.target())
+	clients := make([]*mcpclient.Client, opts.Sessions)
+	for i := range clients {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+		client, err := connectToServer(ctx, config)
+		cancel()
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		clients[i] = client
+	}
+
+	// One call first, so that a wrong tool or arguments stop the run
+	// instead of being measured.
+	ctx, cancel := context.WithTimeout(context.Background(), opts.CallTimeout)
+	_, err := clients[0].CallTool(ctx, opts.Tool, opts.Args)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("trial call: %w", err)
+	}
+
+	fmt.Printf("Calling %s from %d workers for %s\n", opts.Tool, opts.Concurrency, opts.Duration)
+	ctx, cancel = context.WithTimeout(context.Background(), opts.Duration)
+	defer cancel()
+	var calls atomic.Int64
+	workers := make([]*benchWorker, opts.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range workers {
+		w := &benchWorker{outcomes: make(map[string]int)}
+		workers[i] = w
+		wg.Add(1)
+		go func(client *mcpclient.Client) {
+			defer wg.Done()
+			w.run(ctx, client, opts, &calls)
+		}(clients[i%len(clients)])
+	}
+	go benchProgress(ctx, start, &calls)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	printBenchReport(workers, elapsed)
+	return nil
+}
+
+// run calls the tool until ctx is done. A call cut short by the end of
+// the run is not counted.
+func (w *benchWorker) run(ctx context.Context, client *mcpclient.Client, opts benchOptions, calls *atomic.Int64) {
+	for ctx.Err() == nil {
+		callCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), opts.CallTimeout)
+		stop := context.AfterFunc(ctx, cancel)
+		begin := time.Now()
+		_, err := client.CallTool(callCtx, opts.Tool, opts.Args)
+		latency := time.Since(begin)
+		timedOut := errors.Is(callCtx.Err(), context.DeadlineExceeded)
+		stop()
+		cancel()
+
+		var toolErr *mcpclient.ToolError
+		switch {
+		case err == nil:
+			w.outcomes[outcomeOK]++
+		case errors.As(err, &toolErr):
+			w.outcomes[outcomeToolError]++
+			w.lastErr = err.Error()
+		case timedOut:
+			w.outcomes[benchTimeout]++
+			continue
+		case ctx.Err() != nil:
+			return
+		default:
+			w.outcomes[benchError]++
+			w.lastErr = err.Error()
+			continue
+		}
+		w.latencies = append(w.latencies, latency)
+		calls.Add(1)
+	}
+}
+
+// benchProgress prints the call count every few seconds until ctx is
+// done.
+func benchProgress(ctx context.Context, start time.Time, calls *atomic.Int64) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n := calls.Load()
+			fmt.Printf("  %5s  %d calls, %.0f/s\n", time.Since(start).Round(time.Second), n, float64(n)/time.Since(start).Seconds())
+		}
+	}
+}
+
+func printBenchReport(workers []*benchWorker, elapsed time.Duration) {
+	var latencies []time.Duration
+	outcomes := make(map[string]int)
+	lastErr := ""
+	for _, w := range workers {
+		latencies = append(latencies, w.latencies...)
+		for o, n := range w.outcomes {
+			outcomes[o] += n
+		}
+		if w.lastErr != "" {
+			lastErr = w.lastErr
+		}
+	}
+	total := 0
+	for _, n := range outcomes {
+		total += n
+	}
+
+	fmt.Println("\n=== Benchmark ===")
+	fmt.Printf("Calls:       %d in %s\n", total, elapsed.Round(time.Millisecond))
+	fmt.Printf("Throughput:  %.1f calls/s (answered)\n", float64(len(latencies))/elapsed.Seconds())
+	var parts []string
+	for _, o := range []string{outcomeOK, outcomeToolError, benchTimeout, benchError} {
+		if outcomes[o] > 0 || o == outcomeOK {
+			parts = append(parts, fmt.Sprintf("%s=%d (%.2f%%)", o, outcomes[o], percentOf(outcomes[o], total)))
+		}
+	}
+	fmt.Printf("Outcomes:    %s\n", strings.Join(parts, " "))
+	if lastErr != "" {
+		fmt.Printf("Last error:  %s\n", lastErr)
+	}
+	if len(latencies) == 0 {
+		return
+	}
+
+	slices.Sort(latencies)
+	var sum time.Duration
+	for _, l := range latencies {
+		sum += l
+	}
+	fmt.Printf("Latency:     min %s  mean %s  max %s\n",
+		fmtLatency(latencies[0]), fmtLatency(sum/time.Duration(len(latencies))), fmtLatency(latencies[len(latencies)-1]))
+	fmt.Printf("Percentiles: p50 %s  p90 %s  p95 %s  p99 %s  p99.9 %s\n",
+		fmtLatency(percentile(latencies, 50)), fmtLatency(percentile(latencies, 90)), fmtLatency(percentile(latencies, 95)),
+		fmtLatency(percentile(latencies, 99)), fmtLatency(percentile(latencies, 99.9)))
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(float64(len(sorted))*p/100+0.5) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+func percentOf(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+func fmtLatency(d time.Duration) string {
+	switch {
+	case d < time.Millisecond:
+		return d.Round(time.Microsecond).String()
+	case d < time.Second:
+		return d.Round(10 * time.Microsecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"mcp-demo-server/pkg/mcpclient"
+)
+
+// completionTimeout bounds the tools/list a Tab press may need.
+const completionTimeout = 3 * time.Second
+
+// replCommands are the commands Tab completes at the start of a line.
+var replCommands = []string{
+	"call", "cancel", "echo", "edit", "exit", "export-functions", "fetch", "format", "help",
+	"history", "list", "prompt", "prompts", "quit", "read", "refresh", "replay",
+	"resources", "set", "template", "time", "unset", "unwatch", "watch",
+}
+
+// runCall handles "call <tool> [json args]", calling any tool the server
+// lists without the client knowing it in advance. Without arguments it
+// prompts for them from the tool's input schema.
+func runCall(ctx context.Context, client *mcpclient.Client, parts []string) error {
+	if len(parts) < 2 {
+		return fmt.Errorf("usage: call <tool> [json args] (e.g. call url_status {\"url\":\"https://example.com\"})")
+	}
+	name := parts[1]
+	if _, err := findTool(ctx, client, name); err != nil {
+		return fmt.Errorf("%v (type 'list' for the server's tools)", err)
+	}
+	if len(parts) == 2 {
+		return runToolForm(ctx, client, name)
+	}
+	args := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(strings.Join(parts[2:], " ")), &args); err != nil {
+		return fmt.Errorf("invalid arguments: %v", err)
+	}
+
+	fmt.Printf("\n=== Calling %s ===\n", name)
+	result, err := callTool(ctx, client, name, args)
+	if err != nil {
+		return err
+	}
+	printResult(result)
+	return nil
+}
+
+// replCompleter completes the last word of a REPL line: a command name
+// first, then a tool name where call and cancel expect one, a resource
+// URI after read, a prompt name after prompt and a timezone after time.
+// Names come from the cached listings; timezones from the server.
+func replCompleter(live *keepalive) func(line string) []string {
+	return func(line string) []string {
+		fields := strings.Fields(line)
+		word := ""
+		if len(fields) > 0 && !strings.HasSuffix(line, " ") {
+			word, fields = fields[len(fields)-1], fields[:len(fields)-1]
+		}
+		var names []string
+		switch {
+		case len(fields) == 1 && fields[0] == "time":
+			return timezoneNames(live, word)
+		case len(fields) == 0:
+			names = replCommands
+		case len(fields) == 1 && fields[0] == "call", len(fields) == 2 && fields[0] == "cancel":
+			names = toolNames(live)
+		case len(fields) >= 1 && fields[0] == "watch", len(fields) == 1 && fields[0] == "read":
+			names = listedNames(live, mcpclient.Resources)
+		case len(fields) == 1 && fields[0] == "prompt":
+			names = listedNames(live, mcpclient.Prompts)
+		}
+		var matches []string
+		for _, name := range names {
+			if strings.HasPrefix(name, word) {
+				matches = append(matches, name)
+			}
+		}
+		return matches
+	}
+}
+
+// timezoneNames asks the server to complete the timezone argument of its
+// current_time prompt, which matches any part of a name ("kyiv" finds
+// Europe/Kyiv). A server without the prompt or completions gives none.
+func timezoneNames(live *keepalive, word string) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	client, err := live.Client(ctx)
+	if err != nil {
+		return nil
+	}
+	res, err := client.Session().Complete(ctx, &mcp.CompleteParams{
+		Ref:      &mcp.CompleteReference{Type: "ref/prompt", Name: "current_time"},
+		Argument: mcp.CompleteParamsArgument{Name: "timezone", Value: word},
+	})
+	if err != nil {
+		return nil
+	}
+	return res.Completion.Values
+}
+
+// toolNames returns the server's tool names, sorted, or none when they
+// cannot be listed.
+func toolNames(live *keepalive) []string {
+	return listedNames(live, mcpclient.Tools)
+}
+
+// listedNames returns the names of the server's tools or prompts, or the
+// URIs of its resources, sorted, or none when they cannot be listed.
+func listedNames(live *keepalive, kind mcpclient.ListKind) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	client, err := live.Client(ctx)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	switch kind {
+	case mcpclient.Tools:
+		tools, err := cache.Tools(ctx, client)
+		if err != nil {
+			return nil
+		}
+		for _, tool := range tools {
+			names = append(names, tool.Name)
+		}
+	case mcpclient.Resources:
+		resources, err := cache.Resources(ctx, client)
+		if err != nil {
+			return nil
+		}
+		for _, r := range resources {
+			names = append(names, r.URI)
+		}
+	case mcpclient.Prompts:
+		prompts, err := cache.Prompts(ctx, client)
+		if err != nil {
+			return nil
+		}
+		for _, p := range prompts {
+			names = append(names, p.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
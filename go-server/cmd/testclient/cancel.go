@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"mcp-demo-server/pkg/mcpclient"
+)
+
+// callWithCancel calls a tool and cancels the call after the given delay.
+// Cancelling the context makes the SDK send notifications/cancelled, which
+// stops the handler on the server. cancelled is false when the call
+// finished first.
+func callWithCancel(ctx context.Context, client *mcpclient.Client, name string, args map[string]interface{}, after time.Duration) (result string, cancelled bool, err error) {
+	callCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timer := time.AfterFunc(after, cancel)
+	defer timer.Stop()
+
+	result, err = callTool(callCtx, client, name, args)
+	if err != nil && errors.Is(err, context.Canceled) && ctx.Err() == nil {
+		return "", true, nil
+	}
+	return result, false, err
+}
+
+// runCancel handles "cancel <after> <tool> [json args]": it starts the
+// call, cancels it after the delay and pings the server to show that the
+// session is still usable.
+func runCancel(ctx context.Context, client *mcpclient.Client, parts []string) error {
+	if len(parts) < 3 {
+		return fmt.Errorf("usage: cancel <after> <tool> [json args] (e.g. cancel 2s echotest {\"message\":\"hi\",\"delay_ms\":10000})")
+	}
+	after, err := time.ParseDuration(parts[1])
+	if err != nil {
+		return fmt.Errorf("invalid delay %q: %v", parts[1], err)
+	}
+	args := map[string]interface{}{}
+	if len(parts) > 3 {
+		if err := json.Unmarshal([]byte(strings.Join(parts[3:], " ")), &args); err != nil {
+			return fmt.Errorf("invalid arguments: %v", err)
+		}
+	}
+
+	fmt.Printf("\n=== Calling %s, cancelling after %s ===\n", parts[2], after)
+	start := time.Now()
+	result, cancelled, err := callWithCancel(ctx, client, parts[2], args, after)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		fmt.Printf("Call finished in %s, before the cancellation\n", time.Since(start).Round(time.Millisecond))
+		printResult(result)
+		return nil
+	}
+	fmt.Printf("Cancelled after %s; the server was sent notifications/cancelled\n", time.Since(start).Round(time.Millisecond))
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("ping after cancellation: %w", err)
+	}
+	fmt.Println("Session still answers pings")
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// resolveOverride maps a host (and optionally a port) to a fixed address,
+// like curl's --resolve.
+type resolveOverride struct {
+	host string
+	port string // empty matches any port
+	addr string
+}
+
+// parseResolve parses "host:port:addr" or "host:addr" entries.
+func parseResolve(entries []string) ([]resolveOverride, error) {
+	var out []resolveOverride
+	for _, e := range entries {
+		parts := strings.SplitN(e, ":", 3)
+		switch len(parts) {
+		case 2:
+			out = append(out, resolveOverride{host: parts[0], addr: strings.Trim(parts[1], "[]")})
+		case 3:
+			out = append(out, resolveOverride{host: parts[0], port: parts[1], addr: strings.Trim(parts[2], "[]")})
+		default:
+			return nil, fmt.Errorf("invalid -resolve %q (want host:port:addr or host:addr)", e)
+		}
+	}
+	return out, nil
+}
+
+// newHTTPClient builds the HTTP client used by the Streamable HTTP or
+// WebSocket transport, applying -proxy, -unix-socket, -resolve, -record
+// and the auth headers.
+func newHTTPClient(config Config) (*http.Client, error) {
+	if config.Proxy == "" && config.UnixSocket == "" && len(config.Resolve) == 0 {
+		return withHeaders(recorded(http.DefaultClient), config.ServerURL, config.Header), nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if config.Proxy != "" {
+		proxyURL, err := url.Parse(config.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -proxy: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	overrides, err := parseResolve(config.Resolve)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if config.UnixSocket != "" {
+			return dialer.DialContext(ctx, "unix", config.UnixSocket)
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		for _, o := range overrides {
+			if strings.EqualFold(o.host, host) && (o.port == "" || o.port == port) {
+				addr = net.JoinHostPort(o.addr, port)
+				break
+			}
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	return withHeaders(recorded(&http.Client{Transport: transport}), config.ServerURL, config.Header), nil
+}
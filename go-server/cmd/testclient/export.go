@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"mcp-demo-server/pkg/mcpclient"
+)
+
+// exportFunctions converts the server's tool list into the function
+// declarations used by non-MCP LLM APIs:
+//
+//   - "openai":    [{"type":"function","function":{"name","description","parameters"}}]
+//   - "anthropic": [{"name","description","input_schema"}]
+func exportFunctions(ctx context.Context, client *mcpclient.Client, format string) ([]byte, error) {
+	tools, err := cache.Tools(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []any
+	for _, tool := range tools {
+		schema := exportSchema(tool.InputSchema)
+		switch format {
+		case "openai":
+			out = append(out, map[string]any{
+				"type": "function",
+				"function": map[string]any{
+					"name":        tool.Name,
+					"description": tool.Description,
+					"parameters":  schema,
+				},
+			})
+		case "anthropic":
+			out = append(out, map[string]any{
+				"name":         tool.Name,
+				"description":  tool.Description,
+				"input_schema": schema,
+			})
+		default:
+			return nil, fmt.Errorf("unknown export format %q (want openai or anthropic)", format)
+		}
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// exportSchema normalizes an MCP input schema for other providers: the
+// top level must be an object with a properties map, and the $schema
+// dialect marker is dropped since not every API accepts it.
+func exportSchema(inputSchema any) map[string]any {
+	schema, _ := inputSchema.(map[string]any)
+	out := make(map[string]any, len(schema)+2)
+	for k, v := range schema {
+		if k != "$schema" {
+			out[k] = v
+		}
+	}
+	out["type"] = "object"
+	if _, ok := out["properties"]; !ok {
+		out["properties"] = map[string]any{}
+	}
+	return out
+}
+
+// runExportFunctions implements `export-functions <format> [file]`.
+func runExportFunctions(ctx context.Context, client *mcpclient.Client, parts []string) error {
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("usage: export-functions openai|anthropic [file]")
+	}
+	data, err := exportFunctions(ctx, client, parts[1])
+	if err != nil {
+		return err
+	}
+	if len(parts) == 3 {
+		if err := os.WriteFile(parts[2], append(data, '\n'), 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s function schemas to %s\n", parts[1], parts[2])
+		return nil
+	}
+	printResult(string(data))
+	return nil
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A filter transforms tool output text. Filters are chained with the REPL
+// pipe syntax, e.g. `fetch https://x | json .items[0].name | head 20`.
+type filter func(string) (string, error)
+
+// activeFilters is the pipeline applied by printResult for the command
+// currently being run.
+var activeFilters []filter
+
+// lastResult is the most recent output of printResult, which script
+// expectations check.
+var lastResult string
+
+// printResult prints a tool result, passing it through the active filters.
+func printResult(result string) {
+	lastResult = ""
+	for _, f := range activeFilters {
+		var err error
+		if result, err = f(result); err != nil {
+			fmt.Printf("\nFilter error: %v\n", err)
+			return
+		}
+	}
+	lastResult = result
+	fmt.Println("\n=== Result ===")
+	fmt.Println(result)
+}
+
+// splitPipeline separates a command from its " | " filter stages and
+// expands variables in each. A pipe must be surrounded by spaces and lie
+// outside double quotes, braces and brackets, so that URLs and JSON are
+// left alone. Variables are expanded after the split, so that their
+// values cannot add stages.
+func splitPipeline(line string) (string, []filter, error) {
+	stages := pipelineStages(line)
+	for i, stage := range stages {
+		expanded, err := expandVars(stage)
+		if err != nil {
+			return "", nil, err
+		}
+		stages[i] = expanded
+	}
+	var filters []filter
+	for _, stage := range stages[1:] {
+		f, err := parseFilter(strings.Fields(stage))
+		if err != nil {
+			return "", nil, err
+		}
+		filters = append(filters, f)
+	}
+	return strings.TrimSpace(stages[0]), filters, nil
+}
+
+// pipelineStages splits line at each " | " that is outside double
+// quotes, braces and brackets.
+func pipelineStages(line string) []string {
+	var stages []string
+	quoted := false
+	depth, start := 0, 0
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quoted:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				quoted = false
+			}
+		case c == '"':
+			quoted = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			if depth > 0 {
+				depth--
+			}
+		case depth == 0 && strings.HasPrefix(line[i:], " | "):
+			stages = append(stages, line[start:i])
+			start = i + len(" | ")
+			i = start - 1
+		}
+	}
+	return append(stages, line[start:])
+}
+
+func parseFilter(args []string) (filter, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty filter after |")
+	}
+	switch args[0] {
+	case "json", "jq":
+		path := "."
+		if len(args) > 1 {
+			path = args[1]
+		}
+		steps, err := parseJSONPath(path)
+		if err != nil {
+			return nil, err
+		}
+		return func(s string) (string, error) { return jsonFilter(s, steps) }, nil
+
+	case "grep":
+		invert, fold := false, false
+		rest := args[1:]
+		for len(rest) > 0 && strings.HasPrefix(rest[0], "-") {
+			for _, c := range rest[0][1:] {
+				switch c {
+				case 'v':
+					invert = true
+				case 'i':
+					fold = true
+				default:
+					return nil, fmt.Errorf("grep: unknown flag -%c", c)
+				}
+			}
+			rest = rest[1:]
+		}
+		if len(rest) == 0 {
+			return nil, fmt.Errorf("usage: grep [-v] [-i] <regexp>")
+		}
+		pattern := strings.Join(rest, " ")
+		if fold {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("grep: %w", err)
+		}
+		return func(s string) (string, error) {
+			var out []string
+			for _, line := range strings.Split(s, "\n") {
+				if re.MatchString(line) != invert {
+					out = append(out, line)
+				}
+			}
+			return strings.Join(out, "\n"), nil
+		}, nil
+
+	case "head", "tail":
+		n := 10
+		if len(args) > 1 {
+			var err error
+			if n, err = strconv.Atoi(strings.TrimPrefix(args[1], "-")); err != nil || n < 0 {
+				return nil, fmt.Errorf("usage: %s [lines]", args[0])
+			}
+		}
+		head := args[0] == "head"
+		return func(s string) (string, error) {
+			lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+			if len(lines) > n {
+				if head {
+					lines = lines[:n]
+				} else {
+					lines = lines[len(lines)-n:]
+				}
+			}
+			return strings.Join(lines, "\n"), nil
+		}, nil
+
+	case "wc":
+		return func(s string) (string, error) {
+			lines := strings.Count(s, "\n")
+			if s != "" && !strings.HasSuffix(s, "\n") {
+				lines++
+			}
+			return fmt.Sprintf("lines=%d words=%d bytes=%d", lines, len(strings.Fields(s)), len(s)), nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown filter %q (available: json, grep, head, tail, wc)", args[0])
+}
+
+// A jsonStep is one element of a path like .items[0].name or .items[].id.
+type jsonStep struct {
+	key     string
+	index   int
+	isIndex bool
+	each    bool // [] - apply the rest of the path to every element
+}
+
+func parseJSONPath(path string) ([]jsonStep, error) {
+	if !strings.HasPrefix(path, ".") && !strings.HasPrefix(path, "[") {
+		return nil, fmt.Errorf("json path must start with . or [ (got %q)", path)
+	}
+	var steps []jsonStep
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '.':
+			i++
+			j := i
+			for j < len(path) && path[j] != '.' && path[j] != '[' {
+				j++
+			}
+			if j > i {
+				steps = append(steps, jsonStep{key: path[i:j]})
+			}
+			i = j
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [ in %q", path)
+			}
+			inner := path[i+1 : i+end]
+			if inner == "" {
+				steps = append(steps, jsonStep{each: true})
+			} else if n, err := strconv.Atoi(inner); err == nil {
+				steps = append(steps, jsonStep{index: n, isIndex: true})
+			} else {
+				steps = append(steps, jsonStep{key: strings.Trim(inner, `"'`)})
+			}
+			i += end + 1
+		default:
+			return nil, fmt.Errorf("unexpected %q in json path %q", path[i], path)
+		}
+	}
+	return steps, nil
+}
+
+// jsonFilter evaluates a path against the output. Tools such as fetch
+// print a header block before the body, so when the whole output is not
+// JSON the text after the first blank line is tried.
+func jsonFilter(s string, steps []jsonStep) (string, error) {
+	doc, err := decodeOutput(s)
+	if err != nil {
+		return "", err
+	}
+
+	values, err := evalJSONPath(doc, steps)
+	if err != nil {
+		return "", err
+	}
+	var out []string
+	for _, v := range values {
+		if str, ok := v.(string); ok {
+			out = append(out, str)
+			continue
+		}
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		out = append(out, string(b))
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+// decodeOutput decodes the JSON in tool output, as jsonFilter reads it.
+func decodeOutput(s string) (any, error) {
+	var doc any
+	if err := json.Unmarshal([]byte(s), &doc); err != nil {
+		_, body, found := strings.Cut(s, "\n\n")
+		if !found || json.Unmarshal([]byte(body), &doc) != nil {
+			return nil, fmt.Errorf("json: output is not JSON: %v", err)
+		}
+	}
+	return doc, nil
+}
+
+func evalJSONPath(v any, steps []jsonStep) ([]any, error) {
+	if len(steps) == 0 {
+		return []any{v}, nil
+	}
+	step, rest := steps[0], steps[1:]
+	switch {
+	case step.each:
+		arr, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("json: [] applied to non-array")
+		}
+		var out []any
+		for _, item := range arr {
+			values, err := evalJSONPath(item, rest)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, values...)
+		}
+		return out, nil
+	case step.isIndex:
+		arr, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("json: [%d] applied to non-array", step.index)
+		}
+		i := step.index
+		if i < 0 {
+			i += len(arr)
+		}
+		if i < 0 || i >= len(arr) {
+			return []any{nil}, nil
+		}
+		return evalJSONPath(arr[i], rest)
+	default:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("json: .%s applied to non-object", step.key)
+		}
+		return evalJSONPath(obj[step.key], rest)
+	}
+}
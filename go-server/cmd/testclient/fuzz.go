@@ -0,0 +1,690 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"mcp-demo-server/pkg/mcpclient"
+)
+
+// Outcomes of a fuzz case.
+const (
+	outcomeOK        = "ok"         // a result without isError
+	outcomeToolError = "tool_error" // an isError result
+	outcomeRPCError  = "rpc_error"  // a JSON-RPC error
+	outcomeHang      = "hang"       // no answer within -call-timeout
+	outcomeCrash     = "crash"      // the connection was lost
+	outcomeViolation = "violation"  // a result that breaks the protocol
+)
+
+// fuzzOptions configure `testclient fuzz`.
+type fuzzOptions struct {
+	Report      string
+	Cases       int
+	Seed        uint64
+	Tools       []string
+	CallTimeout time.Duration
+	Destructive bool
+}
+
+// fuzzCase is one set of arguments for a tool. Valid cases match the
+// input schema; invalid ones break it in the way Mutation says.
+type fuzzCase struct {
+	Valid    bool
+	Mutation string
+	Args     any
+}
+
+// fuzzFinding is a case whose outcome points at a bug in the server.
+type fuzzFinding struct {
+	Tool       string          `json:"tool"`
+	Valid      bool            `json:"valid_args"`
+	Mutation   string          `json:"mutation"`
+	Args       json.RawMessage `json:"args"`
+	Outcome    string          `json:"outcome"`
+	Problem    string          `json:"problem"`
+	Detail     string          `json:"detail,omitempty"`
+	DurationMs int64           `json:"duration_ms"`
+}
+
+type fuzzToolReport struct {
+	Tool     string         `json:"tool"`
+	Skipped  string         `json:"skipped,omitempty"`
+	Cases    int            `json:"cases"`
+	Outcomes map[string]int `json:"outcomes,omitempty"`
+	Findings int            `json:"findings"`
+}
+
+// fuzzReport is written to -report.
+type fuzzReport struct {
+	Server     string           `json:"server"`
+	Started    time.Time        `json:"started"`
+	DurationMs int64            `json:"duration_ms"`
+	Seed       uint64           `json:"seed"`
+	Aborted    string           `json:"aborted,omitempty"`
+	Tools      []fuzzToolReport `json:"tools"`
+	Findings   []fuzzFinding    `json:"findings"`
+}
+
+// runFuzz implements `testclient [flags] fuzz [fuzz flags]`: it calls
+// every tool of the server with generated arguments, records crashes,
+// hangs and protocol violations, and writes a report. It exits with
+// status 1 when there are findings, so that it can gate CI.
+func runFuzz(config Config, args []string) {
+	fs := flag.NewFlagSet("fuzz", flag.ExitOnError)
+	report := fs.String("report", "fuzz-report.json", "Write the JSON report to this file")
+	cases := fs.Int("cases", 20, "Cases per tool, about half of them with invalid arguments")
+	seed := fs.Uint64("seed", 0, "Random seed, to replay a run (default: time-based, printed)")
+	tools := fs.String("tools", "", "Comma-separated tools to fuzz (default: all)")
+	callTimeout := fs.Duration("call-timeout", 10*time.Second, "A call without an answer after this long counts as a hang")
+	destructive := fs.Bool("destructive", false, "Also fuzz tools annotated as destructive")
+	fs.Parse(args)
+
+	opts := fuzzOptions{
+		Report:      *report,
+		Cases:       *cases,
+		Seed:        *seed,
+		CallTimeout: *callTimeout,
+		Destructive: *destructive,
+	}
+	if opts.Cases < 1 {
+		log.Fatalf("Invalid -cases: must be positive")
+	}
+	if opts.Seed == 0 {
+		opts.Seed = uint64(time.Now().UnixNano())
+	}
+	for _, name := range strings.Split(*tools, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			opts.Tools = append(opts.Tools, name)
+		}
+	}
+
+	rep, err := fuzzServer(config, opts)
+	if err != nil {
+		log.Fatalf("Fuzzing failed: %v", err)
+	}
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		log.Fatalf("Fuzzing failed: %v", err)
+	}
+	if err := os.WriteFile(opts.Report, append(data, '\n'), 0o644); err != nil {
+		log.Fatalf("Writing report: %v", err)
+	}
+	fmt.Printf("\n%d findings; report written to %s (seed %d)\n", len(rep.Findings), opts.Report, rep.Seed)
+	if len(rep.Findings) > 0 {
+		os.Exit(1)
+	}
+}
+
+// fuzzer holds the connection, which it replaces when a case kills it.
+type fuzzer struct {
+	config Config
+	opts   fuzzOptions
+	rng    *rand.Rand
+	client *mcpclient.Client
+}
+
+func fuzzServer(config Config, opts fuzzOptions) (*fuzzReport, error) {
+	f := &fuzzer{config: config, opts: opts, rng: rand.New(rand.NewPCG(opts.Seed, opts.Seed>>1|1))}
+	ctx := context.Background()
+	fmt.Printf("Connecting to %s...\n", config.target())
+	if err := f.connect(ctx); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if f.client != nil {
+			f.client.Close()
+		}
+	}()
+
+	tools, err := f.client.ListTools(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("tools/list: %w", err)
+	}
+	for _, name := range opts.Tools {
+		if !slices.ContainsFunc(tools, func(t *mcp.Tool) bool { return t.Name == name }) {
+			return nil, fmt.Errorf("tool %q not found on server", name)
+		}
+	}
+
+	if len(opts.Tools) > 0 {
+		tools = slices.DeleteFunc(tools, func(t *mcp.Tool) bool { return !slices.Contains(opts.Tools, t.Name) })
+	}
+
+	rep := &fuzzReport{Server: config.ServerURL, Started: time.Now().UTC(), Seed: opts.Seed, Findings: []fuzzFinding{}}
+	fmt.Printf("Fuzzing %d tools, %d cases each (seed %d)\n\n", len(tools), opts.Cases, opts.Seed)
+	for _, tool := range tools {
+		tr, findings, err := f.fuzzTool(ctx, tool)
+		rep.Tools = append(rep.Tools, tr)
+		rep.Findings = append(rep.Findings, findings...)
+		if err != nil {
+			// The server is gone; report what was found up to here.
+			rep.Aborted = err.Error()
+			fmt.Printf("%-24s %3d cases  %s  findings=%d\nStopped: %v\n", tool.Name, tr.Cases, formatOutcomes(tr.Outcomes), tr.Findings, err)
+			break
+		}
+		if tr.Skipped != "" {
+			fmt.Printf("%-24s skipped: %s\n", tool.Name, tr.Skipped)
+			continue
+		}
+		fmt.Printf("%-24s %3d cases  %s  findings=%d\n", tool.Name, tr.Cases, formatOutcomes(tr.Outcomes), tr.Findings)
+	}
+	rep.DurationMs = time.Since(rep.Started).Milliseconds()
+	return rep, nil
+}
+
+func (f *fuzzer) connect(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, f.config.Timeout)
+	defer cancel()
+	client, err := connectToServer(ctx, f.config)
+	if err != nil {
+		return err
+	}
+	f.client = client
+	return nil
+}
+
+func formatOutcomes(outcomes map[string]int) string {
+	var parts []string
+	for _, o := range []string{outcomeOK, outcomeToolError, outcomeRPCError, outcomeHang, outcomeCrash, outcomeViolation} {
+		if outcomes[o] > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d", o, outcomes[o]))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// fuzzTool runs the cases of one tool.
+func (f *fuzzer) fuzzTool(ctx context.Context, tool *mcp.Tool) (fuzzToolReport, []fuzzFinding, error) {
+	tr := fuzzToolReport{Tool: tool.Name, Outcomes: make(map[string]int)}
+	if a := tool.Annotations; a != nil && !a.ReadOnlyHint && a.DestructiveHint != nil && *a.DestructiveHint && !f.opts.Destructive {
+		tr.Skipped = "annotated as destructive (pass -destructive to include it)"
+		return tr, nil, nil
+	}
+	schema, err := toolSchema(tool)
+	if err != nil {
+		tr.Skipped = err.Error()
+		return tr, nil, nil
+	}
+	var input, output *jsonschema.Resolved
+	if input, err = schema.Resolve(nil); err != nil {
+		tr.Skipped = fmt.Sprintf("input schema does not resolve: %v", err)
+		return tr, nil, nil
+	}
+	if tool.OutputSchema != nil {
+		if output, err = resolveSchema(tool.OutputSchema); err != nil {
+			tr.Skipped = fmt.Sprintf("output schema does not resolve: %v", err)
+			return tr, nil, nil
+		}
+	}
+
+	gen := &argGen{rng: f.rng, root: schema}
+	var findings []fuzzFinding
+	for _, c := range gen.cases(input, f.opts.Cases) {
+		outcome, problem, detail, elapsed, err := f.run(ctx, tool, output, c)
+		tr.Cases++
+		tr.Outcomes[outcome]++
+		if problem == "" {
+			continue
+		}
+		args, _ := json.Marshal(c.Args)
+		findings = append(findings, fuzzFinding{
+			Tool: tool.Name, Valid: c.Valid, Mutation: c.Mutation, Args: args,
+			Outcome: outcome, Problem: problem, Detail: detail, DurationMs: elapsed.Milliseconds(),
+		})
+		tr.Findings++
+		if err != nil {
+			return tr, findings, err
+		}
+	}
+	return tr, findings, nil
+}
+
+// resolveSchema resolves a schema in its wire form.
+func resolveSchema(raw any) (*jsonschema.Resolved, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var schema jsonschema.Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, err
+	}
+	return schema.Resolve(nil)
+}
+
+// run calls the tool with one case and judges the outcome. problem is
+// non-empty for a finding. An error means the server cannot be reached
+// any more after a crash.
+func (f *fuzzer) run(ctx context.Context, tool *mcp.Tool, output *jsonschema.Resolved, c fuzzCase) (outcome, problem, detail string, elapsed time.Duration, err error) {
+	callCtx, cancel := context.WithTimeout(ctx, f.opts.CallTimeout)
+	defer cancel()
+	start := time.Now()
+	result, callErr := f.client.CallTool(callCtx, tool.Name, c.Args)
+	elapsed = time.Since(start)
+
+	var toolErr *mcpclient.ToolError
+	switch {
+	case callErr == nil:
+		if p := checkResult(result, output); p != "" {
+			return outcomeViolation, p, "", elapsed, nil
+		}
+		if !c.Valid {
+			return outcomeOK, "invalid arguments accepted", "", elapsed, nil
+		}
+		return outcomeOK, "", "", elapsed, nil
+	case errors.As(callErr, &toolErr):
+		return outcomeToolError, "", "", elapsed, nil
+	case errors.Is(callCtx.Err(), context.DeadlineExceeded):
+		return outcomeHang, fmt.Sprintf("no answer within %s", f.opts.CallTimeout), "", elapsed, nil
+	}
+
+	// A JSON-RPC error, or a connection that went away with the call.
+	pingCtx, cancelPing := context.WithTimeout(ctx, 5*time.Second)
+	pingErr := f.client.Ping(pingCtx)
+	cancelPing()
+	if pingErr == nil {
+		if c.Valid {
+			return outcomeRPCError, "valid arguments refused with a JSON-RPC error", callErr.Error(), elapsed, nil
+		}
+		return outcomeRPCError, "", "", elapsed, nil
+	}
+	f.client.Close()
+	f.client = nil
+	if err = f.connect(ctx); err != nil {
+		err = fmt.Errorf("server unreachable after the crash: %w", err)
+	}
+	return outcomeCrash, "connection lost", callErr.Error(), elapsed, err
+}
+
+// checkResult reports how a successful result breaks the protocol, or "".
+func checkResult(result *mcp.CallToolResult, output *jsonschema.Resolved) string {
+	if result == nil {
+		return "empty result"
+	}
+	if output == nil {
+		return ""
+	}
+	if result.StructuredContent == nil {
+		return "no structuredContent although the tool declares an outputSchema"
+	}
+	instance, err := jsonRoundTrip(result.StructuredContent)
+	if err != nil {
+		return fmt.Sprintf("structuredContent is not JSON: %v", err)
+	}
+	if err := output.Validate(instance); err != nil {
+		return fmt.Sprintf("structuredContent does not match the outputSchema: %v", err)
+	}
+	return ""
+}
+
+// jsonRoundTrip converts v to the generic form that validation expects.
+func jsonRoundTrip(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
+
+/* ---------- Argument generation ---------- */
+
+// argGen generates arguments from an input schema. Generated cases are
+// checked against the schema, so a valid case is valid and an invalid
+// one invalid even where the generator cannot follow every keyword.
+type argGen struct {
+	rng  *rand.Rand
+	root *jsonschema.Schema
+}
+
+// maxGenTries bounds the attempts at one case before giving up on it.
+const maxGenTries = 20
+
+// cases returns n cases, alternating valid and invalid ones.
+func (g *argGen) cases(input *jsonschema.Resolved, n int) []fuzzCase {
+	var out []fuzzCase
+	for i := 0; i < n; i++ {
+		valid := i%2 == 0
+		for try := 0; try < maxGenTries; try++ {
+			var c fuzzCase
+			if valid {
+				c = g.validCase(i)
+			} else {
+				c = g.invalidCase()
+			}
+			instance, err := jsonRoundTrip(c.Args)
+			if err != nil {
+				continue
+			}
+			if (input.Validate(instance) == nil) == valid {
+				out = append(out, c)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// validCase varies the shape of valid arguments: only the required
+// properties, all of them, large values, or a random mix.
+func (g *argGen) validCase(i int) fuzzCase {
+	switch i / 2 % 4 {
+	case 0:
+		return fuzzCase{Valid: true, Mutation: "required properties only", Args: g.object(g.root, 0, 0)}
+	case 1:
+		return fuzzCase{Valid: true, Mutation: "all properties", Args: g.object(g.root, 0, 1)}
+	case 2:
+		return fuzzCase{Valid: true, Mutation: "boundary values", Args: g.withBoundaries(g.object(g.root, 0, 1))}
+	}
+	return fuzzCase{Valid: true, Mutation: "random", Args: g.object(g.root, 0, 0.5)}
+}
+
+// invalidCase breaks an otherwise valid set of arguments in one way.
+func (g *argGen) invalidCase() fuzzCase {
+	args := g.object(g.root, 0, 0.5)
+	props := sortedKeys(g.root.Properties)
+	var mutations []func() fuzzCase
+	mutations = append(mutations, func() fuzzCase {
+		notObject := []any{[]any{1, 2}, "arguments", 42, true}
+		return fuzzCase{Mutation: "arguments are not an object", Args: notObject[g.rng.IntN(len(notObject))]}
+	})
+	if len(g.root.Required) > 0 {
+		mutations = append(mutations, func() fuzzCase {
+			p := g.root.Required[g.rng.IntN(len(g.root.Required))]
+			delete(args, p)
+			return fuzzCase{Mutation: "missing required " + p, Args: args}
+		})
+	}
+	if ap := g.root.AdditionalProperties; ap != nil && ap.Not != nil {
+		mutations = append(mutations, func() fuzzCase {
+			args["fuzz_unknown_property"] = "x"
+			return fuzzCase{Mutation: "unknown property", Args: args}
+		})
+	}
+	if len(props) > 0 {
+		mutations = append(mutations, func() fuzzCase {
+			p := props[g.rng.IntN(len(props))]
+			v, typ := g.wrongType(g.deref(g.root.Properties[p]))
+			args[p] = v
+			return fuzzCase{Mutation: fmt.Sprintf("%s: %s instead of %s", p, typ, schemaType(g.deref(g.root.Properties[p]))), Args: args}
+		}, func() fuzzCase {
+			p := props[g.rng.IntN(len(props))]
+			v, how := g.outOfRange(g.deref(g.root.Properties[p]))
+			args[p] = v
+			return fuzzCase{Mutation: fmt.Sprintf("%s: %s", p, how), Args: args}
+		})
+	}
+	return mutations[g.rng.IntN(len(mutations))]()
+}
+
+// deref follows a local $ref.
+func (g *argGen) deref(s *jsonschema.Schema) *jsonschema.Schema {
+	for i := 0; s != nil && s.Ref != "" && i < 8; i++ {
+		name, ok := strings.CutPrefix(s.Ref, "#/$defs/")
+		if !ok {
+			name, ok = strings.CutPrefix(s.Ref, "#/definitions/")
+		}
+		next := g.root.Defs[name]
+		if next == nil {
+			next = g.root.Definitions[name]
+		}
+		if !ok || next == nil {
+			return s
+		}
+		s = next
+	}
+	return s
+}
+
+// value generates a value for s. fill is the chance that an optional
+// object property is set.
+func (g *argGen) value(s *jsonschema.Schema, depth int, fill float64) any {
+	s = g.deref(s)
+	if s == nil {
+		return "x"
+	}
+	switch {
+	case s.Const != nil:
+		return *s.Const
+	case len(s.Enum) > 0:
+		return s.Enum[g.rng.IntN(len(s.Enum))]
+	case len(s.AnyOf) > 0:
+		return g.value(s.AnyOf[g.rng.IntN(len(s.AnyOf))], depth, fill)
+	case len(s.OneOf) > 0:
+		return g.value(s.OneOf[g.rng.IntN(len(s.OneOf))], depth, fill)
+	case len(s.Default) > 0 && g.rng.IntN(4) == 0:
+		var v any
+		if json.Unmarshal(s.Default, &v) == nil {
+			return v
+		}
+	case len(s.Examples) > 0 && g.rng.IntN(2) == 0:
+		return s.Examples[g.rng.IntN(len(s.Examples))]
+	}
+	if slices.Contains(s.Types, "null") && g.rng.IntN(8) == 0 {
+		return nil
+	}
+	switch schemaType(s) {
+	case "object":
+		return g.object(s, depth+1, fill)
+	case "array":
+		lo, hi := bounds(s.MinItems, s.MaxItems, 0, 3)
+		items := make([]any, 0, hi)
+		if depth < 4 {
+			for n := lo + g.rng.IntN(hi-lo+1); len(items) < n; {
+				items = append(items, g.value(s.Items, depth+1, fill))
+			}
+		}
+		return items
+	case "integer":
+		return g.integer(s)
+	case "number":
+		lo, hi := numBounds(s, -1e6, 1e6)
+		return lo + g.rng.Float64()*(hi-lo)
+	case "boolean":
+		return g.rng.IntN(2) == 0
+	}
+	return g.str(s)
+}
+
+// object generates an object with its required properties and, with
+// probability fill, each optional one.
+func (g *argGen) object(s *jsonschema.Schema, depth int, fill float64) map[string]any {
+	s = g.deref(s)
+	out := make(map[string]any)
+	if s == nil {
+		return out
+	}
+	for _, p := range sortedKeys(s.Properties) {
+		if slices.Contains(s.Required, p) || (depth < 4 && g.rng.Float64() < fill) {
+			out[p] = g.value(s.Properties[p], depth, fill)
+		}
+	}
+	return out
+}
+
+// interestingInts are tried before random integers.
+var interestingInts = []float64{0, 1, -1, 2, 255, 256, 65535, math.MaxInt32, math.MinInt32, 1 << 53}
+
+func (g *argGen) integer(s *jsonschema.Schema) float64 {
+	lo, hi := numBounds(s, -1e9, 1e9)
+	lo, hi = math.Ceil(lo), math.Floor(hi)
+	if g.rng.IntN(2) == 0 {
+		v := interestingInts[g.rng.IntN(len(interestingInts))]
+		if v >= lo && v <= hi {
+			return v
+		}
+	}
+	if hi <= lo {
+		return lo
+	}
+	return lo + math.Floor(g.rng.Float64()*(hi-lo+1))
+}
+
+// fuzzRunes mix ASCII with characters that trip up parsers and encoders.
+var fuzzRunes = []rune("abcxyzABC0129 _-./:%?&=#@\\\"'<>{}[]\t\n\x00é中🙂\u202e\ufeff")
+
+func (g *argGen) str(s *jsonschema.Schema) string {
+	switch s.Format {
+	case "date-time":
+		return time.Unix(g.rng.Int64N(4e9), 0).UTC().Format(time.RFC3339)
+	case "uri", "url":
+		return "https://example.com/" + g.word(8)
+	case "email":
+		return g.word(6) + "@example.com"
+	}
+	lo, hi := bounds(s.MinLength, s.MaxLength, 0, 16)
+	n := lo + g.rng.IntN(hi-lo+1)
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteRune(fuzzRunes[g.rng.IntN(len(fuzzRunes))])
+	}
+	return b.String()
+}
+
+func (g *argGen) word(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte('a' + g.rng.IntN(26))
+	}
+	return string(b)
+}
+
+// withBoundaries sets the top-level values to the edges of their range:
+// the shortest or longest string (64 KiB when unbounded), the smallest
+// or largest number.
+func (g *argGen) withBoundaries(args map[string]any) map[string]any {
+	for p, v := range args {
+		s := g.deref(g.root.Properties[p])
+		if s == nil || s.Const != nil || len(s.Enum) > 0 {
+			continue
+		}
+		high := g.rng.IntN(2) == 0
+		switch v.(type) {
+		case string:
+			n := 0
+			if s.MinLength != nil {
+				n = *s.MinLength
+			}
+			if high {
+				n = 64 << 10
+				if s.MaxLength != nil {
+					n = *s.MaxLength
+				}
+			}
+			args[p] = strings.Repeat("a", n)
+		case float64:
+			lo, hi := numBounds(s, -1e15, 1e15)
+			if schemaType(s) == "integer" {
+				lo, hi = math.Ceil(lo), math.Floor(hi)
+			}
+			args[p] = lo
+			if high {
+				args[p] = hi
+			}
+		}
+	}
+	return args
+}
+
+// wrongType returns a value that is not of the schema's type.
+func (g *argGen) wrongType(s *jsonschema.Schema) (any, string) {
+	candidates := []struct {
+		typ string
+		v   any
+	}{
+		{"string", "not-a-" + schemaType(s)},
+		{"integer", 12345},
+		{"boolean", true},
+		{"array", []any{"x"}},
+		{"object", map[string]any{"x": 1}},
+		{"null", nil},
+	}
+	for {
+		c := candidates[g.rng.IntN(len(candidates))]
+		if c.typ != schemaType(s) && !slices.Contains(s.Types, c.typ) && (c.typ != "integer" || schemaType(s) != "number") {
+			return c.v, c.typ
+		}
+	}
+}
+
+// outOfRange returns a value of the right type that breaks a constraint,
+// falling back to a wrong type when there is none.
+func (g *argGen) outOfRange(s *jsonschema.Schema) (any, string) {
+	switch {
+	case len(s.Enum) > 0:
+		return "not-in-enum", "value not in enum"
+	case s.Maximum != nil:
+		return *s.Maximum + 1, "above maximum"
+	case s.Minimum != nil:
+		return *s.Minimum - 1, "below minimum"
+	case s.MaxLength != nil:
+		return strings.Repeat("a", *s.MaxLength+1), "too long"
+	case s.MinLength != nil && *s.MinLength > 0:
+		return "", "too short"
+	case s.MaxItems != nil:
+		return make([]any, *s.MaxItems+1), "too many items"
+	}
+	v, typ := g.wrongType(s)
+	return v, typ + " instead of " + schemaType(s)
+}
+
+// bounds returns the range of a count, with defaults for missing bounds.
+func bounds(min, max *int, lo, hi int) (int, int) {
+	if min != nil {
+		lo = *min
+	}
+	if max != nil {
+		hi = *max
+	} else if hi < lo {
+		hi = lo + 3
+	}
+	if hi < lo {
+		hi = lo
+	}
+	return lo, hi
+}
+
+// numBounds returns the range of a number, with defaults for missing
+// bounds.
+func numBounds(s *jsonschema.Schema, lo, hi float64) (float64, float64) {
+	if s.Minimum != nil {
+		lo = *s.Minimum
+	}
+	if s.ExclusiveMinimum != nil {
+		lo = *s.ExclusiveMinimum + 1
+	}
+	if s.Maximum != nil {
+		hi = *s.Maximum
+	}
+	if s.ExclusiveMaximum != nil {
+		hi = *s.ExclusiveMaximum - 1
+	}
+	if hi < lo {
+		hi = lo
+	}
+	return lo, hi
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"mcp-demo-server/pkg/mcpclient"
+)
+
+// maxHistory is how many entries the history file keeps.
+const maxHistory = 1000
+
+// history holds the REPL commands entered, oldest first: those of earlier
+// sessions from the history file, then this session's. Entries are stored
+// before variable expansion, so replays pick up the current variable
+// values.
+var history []string
+
+// historyFile, if open, receives each entry as it is recorded.
+var historyFile *os.File
+
+// defaultHistoryFile returns ~/.mcp_testclient_history, or "" when there
+// is no home directory.
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".mcp_testclient_history")
+}
+
+// loadHistory reads the history file at path, trimming it to the last
+// maxHistory entries, and opens it for recordHistory to append to. A
+// missing file is created on the first entry.
+func loadHistory(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	var entries []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			entries = append(entries, line)
+		}
+	}
+	if len(entries) > maxHistory {
+		entries = entries[len(entries)-maxHistory:]
+		if err := os.WriteFile(path, []byte(strings.Join(entries, "\n")+"\n"), 0o600); err != nil {
+			return err
+		}
+	}
+	// Commands may carry tokens; keep the file private.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	history, historyFile = entries, f
+	return nil
+}
+
+// recordHistory appends a command unless it is itself a history command.
+func recordHistory(line string) {
+	switch strings.ToLower(strings.Fields(line)[0]) {
+	case "history", "replay", "edit":
+		return
+	}
+	history = append(history, line)
+	if historyFile == nil {
+		return
+	}
+	if _, err := fmt.Fprintln(historyFile, line); err != nil {
+		fmt.Printf("Warning: history file: %v (no longer saving history)\n", err)
+		historyFile.Close()
+		historyFile = nil
+	}
+}
+
+// historyEntry returns entry n (1-based); negative n counts back from the
+// most recent entry.
+func historyEntry(n int) (string, error) {
+	if n < 0 {
+		n = len(history) + 1 + n
+	}
+	if n < 1 || n > len(history) {
+		return "", fmt.Errorf("no history entry %d", n)
+	}
+	return history[n-1], nil
+}
+
+// expandHistory resolves csh-style history references: "!!", "!N",
+// "!-N", "!N:s/old/new/" and "^old^new". changed reports whether line was
+// rewritten, in which case the caller echoes the resulting command.
+func expandHistory(line string) (expanded string, changed bool, err error) {
+	if strings.HasPrefix(line, "^") {
+		parts := strings.SplitN(line[1:], "^", 3)
+		if len(parts) < 2 {
+			return "", false, fmt.Errorf("usage: ^old^new")
+		}
+		last, err := historyEntry(-1)
+		if err != nil {
+			return "", false, err
+		}
+		if !strings.Contains(last, parts[0]) {
+			return "", false, fmt.Errorf("%q not found in %q", parts[0], last)
+		}
+		return strings.Replace(last, parts[0], parts[1], 1), true, nil
+	}
+
+	if !strings.HasPrefix(line, "!") || line == "!" {
+		return line, false, nil
+	}
+
+	ref, subst, _ := strings.Cut(line[1:], ":")
+	var n int
+	if ref == "!" {
+		n = -1
+	} else if n, err = strconv.Atoi(ref); err != nil {
+		return "", false, fmt.Errorf("invalid history reference %q", line)
+	}
+	entry, err := historyEntry(n)
+	if err != nil {
+		return "", false, err
+	}
+
+	if subst != "" {
+		// s/old/new/ with any delimiter following the "s".
+		if len(subst) < 2 || subst[0] != 's' {
+			return "", false, fmt.Errorf("unsupported modifier %q (want s/old/new/)", subst)
+		}
+		fields := strings.Split(subst[2:], subst[1:2])
+		if len(fields) < 2 {
+			return "", false, fmt.Errorf("usage: !N:s/old/new/")
+		}
+		if !strings.Contains(entry, fields[0]) {
+			return "", false, fmt.Errorf("%q not found in %q", fields[0], entry)
+		}
+		entry = strings.Replace(entry, fields[0], fields[1], 1)
+	}
+	return entry, true, nil
+}
+
+// printHistory implements `history [N]`, listing the last N entries.
+func printHistory(parts []string) error {
+	start := 0
+	if len(parts) > 1 {
+		n, err := strconv.Atoi(parts[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("usage: history [count]")
+		}
+		start = max(0, len(history)-n)
+	}
+	for i := start; i < len(history); i++ {
+		fmt.Printf("%5d  %s\n", i+1, history[i])
+	}
+	return nil
+}
+
+// replayHistory implements `replay last N` and `replay A-B`, re-running
+// the selected entries in order and stopping at the first failure.
+func replayHistory(ctx context.Context, client *mcpclient.Client, parts []string) error {
+	var from, to int
+	switch {
+	case len(parts) == 3 && parts[1] == "last":
+		n, err := strconv.Atoi(parts[2])
+		if err != nil || n < 1 {
+			return fmt.Errorf("usage: replay last <count>")
+		}
+		from, to = max(1, len(history)-n+1), len(history)
+	case len(parts) == 2:
+		a, b, found := strings.Cut(parts[1], "-")
+		var err1, err2 error
+		from, err1 = strconv.Atoi(a)
+		to, err2 = from, nil
+		if found {
+			to, err2 = strconv.Atoi(b)
+		}
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("usage: replay last <count> | replay <from>-<to>")
+		}
+	default:
+		return fmt.Errorf("usage: replay last <count> | replay <from>-<to>")
+	}
+	if from < 1 || to > len(history) || from > to {
+		return fmt.Errorf("history range %d-%d out of bounds (1-%d)", from, to, len(history))
+	}
+
+	// Copy the range first: replayed commands are not re-recorded, but an
+	// `edit` inside the range would otherwise change what follows.
+	commands := append([]string(nil), history[from-1:to]...)
+	for i, line := range commands {
+		fmt.Printf("\n[replay %d/%d] %s\n", i+1, len(commands), line)
+		if err := handleCommand(ctx, client, line); err != nil {
+			return fmt.Errorf("replay stopped at %q: %w", line, err)
+		}
+	}
+	return nil
+}
+
+// editHistory implements `edit [N]`: the entry (default: the last one) is
+// opened in $EDITOR, and the edited command is recorded and run.
+func editHistory(ctx context.Context, client *mcpclient.Client, parts []string) error {
+	n := -1
+	if len(parts) > 1 {
+		var err error
+		if n, err = strconv.Atoi(parts[1]); err != nil {
+			return fmt.Errorf("usage: edit [N]")
+		}
+	}
+	entry, err := historyEntry(n)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp("", "mcp-edit-*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(entry + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", f.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor failed: %w", err)
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return err
+	}
+	line := strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0])
+	if line == "" {
+		fmt.Println("Empty command, nothing to run")
+		return nil
+	}
+	fmt.Println(line)
+	recordHistory(line)
+	return handleCommand(ctx, client, line)
+}
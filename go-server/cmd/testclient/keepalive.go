@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"mcp-demo-server/pkg/mcpclient"
+)
+
+// keepalive pings the server periodically and records when the session
+// stops answering, so the REPL can reconnect before the next command
+// instead of hanging on a stale session.
+type keepalive struct {
+	config Config
+
+	mu     sync.Mutex
+	client *mcpclient.Client
+	dead   error // non-nil once a ping has failed
+	stop   context.CancelFunc
+}
+
+// startKeepalive begins pinging client every config.PingInterval. A zero
+// interval disables pinging; Client then always returns the original
+// client.
+func startKeepalive(config Config, client *mcpclient.Client) *keepalive {
+	k := &keepalive{config: config, client: client}
+	k.run()
+	return k
+}
+
+func (k *keepalive) run() {
+	if k.config.PingInterval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	k.stop = cancel
+	client := k.client
+
+	go func() {
+		ticker := time.NewTicker(k.config.PingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			pingCtx, cancel := context.WithTimeout(ctx, k.pingTimeout())
+			err := client.Ping(pingCtx)
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				k.mu.Lock()
+				k.dead = err
+				k.mu.Unlock()
+				return
+			}
+		}
+	}()
+}
+
+// pingTimeout bounds a single ping so that a hung connection is detected
+// within one interval.
+func (k *keepalive) pingTimeout() time.Duration {
+	if k.config.PingInterval < k.config.Timeout {
+		return k.config.PingInterval
+	}
+	return k.config.Timeout
+}
+
+// Client returns a usable client. If the last ping failed it warns the
+// user, closes the stale session and reconnects.
+func (k *keepalive) Client(ctx context.Context) (*mcpclient.Client, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.dead == nil {
+		return k.client, nil
+	}
+
+	fmt.Printf("Warning: connection to %s lost (%v); reconnecting...\n", k.config.target(), k.dead)
+	k.client.Close()
+
+	connectCtx, cancel := context.WithTimeout(ctx, k.config.Timeout)
+	defer cancel()
+	client, err := connectToServer(connectCtx, k.config)
+	if err != nil {
+		return nil, fmt.Errorf("reconnect failed: %w", err)
+	}
+	fmt.Println("Reconnected.")
+
+	for _, kind := range []mcpclient.ListKind{mcpclient.Tools, mcpclient.Resources, mcpclient.Prompts} {
+		cache.invalidate(kind)
+	}
+	k.client = client
+	k.dead = nil
+	k.run()
+	return client, nil
+}
+
+// Close stops pinging and closes the current session.
+func (k *keepalive) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.stop != nil {
+		k.stop()
+	}
+	return k.client.Close()
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// lineEditor reads REPL lines. On a terminal it switches to raw mode for
+// the length of each line and edits it in place: the arrow keys move
+// through the line and the history, the usual Emacs keys work, and Tab
+// completes the word before the cursor. Otherwise lines are read from
+// stdin as they come.
+type lineEditor struct {
+	// complete returns the candidates for the last word of line.
+	complete func(line string) []string
+	raw      bool
+}
+
+func newLineEditor(complete func(line string) []string) *lineEditor {
+	return &lineEditor{complete: complete, raw: isTerminal(int(os.Stdin.Fd()))}
+}
+
+// editState is the line being edited and where it is on the screen.
+type editState struct {
+	prompt string // the last line of the prompt, redrawn with the line
+	buf    []rune
+	pos    int // cursor, as an index into buf
+	cols   int // terminal width
+	row    int // cursor row, counted from the prompt's
+}
+
+// Keys the editor acts on besides printable runes.
+const (
+	keyNone = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+	keyWordLeft
+	keyWordRight
+)
+
+// readLine prints prompt and reads a line. ok is false at the end of
+// input.
+func (e *lineEditor) readLine(prompt string) (line string, ok bool) {
+	if !e.raw {
+		fmt.Print(prompt)
+		if !stdin.Scan() {
+			return "", false
+		}
+		return stdin.Text(), true
+	}
+	restore, err := makeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		e.raw = false
+		return e.readLine(prompt)
+	}
+	defer restore()
+
+	s := &editState{cols: termWidth(int(os.Stdout.Fd()))}
+	if s.cols <= 0 {
+		s.cols = 80
+	}
+	if i := strings.LastIndex(prompt, "\n"); i >= 0 {
+		fmt.Print(strings.ReplaceAll(prompt[:i+1], "\n", "\r\n"))
+		prompt = prompt[i+1:]
+	}
+	s.prompt = prompt
+	s.render()
+
+	// recalled indexes the history entry shown; len(history) is the line
+	// being typed, kept in draft while older entries are shown.
+	recalled, draft := len(history), ""
+	recall := func(n int) {
+		if n < 0 || n > len(history) || n == recalled {
+			return
+		}
+		if recalled == len(history) {
+			draft = string(s.buf)
+		}
+		recalled = n
+		if n == len(history) {
+			s.buf = []rune(draft)
+		} else {
+			s.buf = []rune(history[n])
+		}
+		s.pos = len(s.buf)
+		s.render()
+	}
+
+	for {
+		c, ok := readByte()
+		if !ok {
+			return "", false
+		}
+		key := keyNone
+		switch c {
+		case '\r', '\n':
+			s.end()
+			fmt.Print("\r\n")
+			return string(s.buf), true
+		case 3: // Ctrl-C drops the line
+			s.end()
+			fmt.Print("^C\r\n")
+			return "", true
+		case 4: // Ctrl-D ends input on an empty line, else deletes
+			if len(s.buf) == 0 {
+				fmt.Print("\r\n")
+				return "", false
+			}
+			key = keyDelete
+		case 1: // Ctrl-A
+			key = keyHome
+		case 5: // Ctrl-E
+			key = keyEnd
+		case 2: // Ctrl-B
+			key = keyLeft
+		case 6: // Ctrl-F
+			key = keyRight
+		case 16: // Ctrl-P
+			key = keyUp
+		case 14: // Ctrl-N
+			key = keyDown
+		case 11: // Ctrl-K deletes to the end of the line
+			s.buf = s.buf[:s.pos]
+			s.render()
+		case 21: // Ctrl-U deletes to the start of the line
+			s.buf = append(s.buf[:0], s.buf[s.pos:]...)
+			s.pos = 0
+			s.render()
+		case 23: // Ctrl-W deletes the word before the cursor
+			start := wordStart(s.buf, s.pos)
+			s.buf = append(s.buf[:start], s.buf[s.pos:]...)
+			s.pos = start
+			s.render()
+		case 12: // Ctrl-L clears the screen
+			fmt.Print("\x1b[H\x1b[2J")
+			s.row = 0
+			s.render()
+		case 127, '\b':
+			if s.pos > 0 {
+				s.buf = append(s.buf[:s.pos-1], s.buf[s.pos:]...)
+				s.pos--
+				s.render()
+			}
+		case '\t':
+			e.completeWord(s)
+		case 27:
+			key = readEscape()
+		default:
+			if c >= ' ' {
+				r := readRune(c)
+				s.buf = append(s.buf[:s.pos], append([]rune{r}, s.buf[s.pos:]...)...)
+				s.pos++
+				s.render()
+			}
+		}
+
+		switch key {
+		case keyUp:
+			recall(recalled - 1)
+		case keyDown:
+			recall(recalled + 1)
+		case keyLeft:
+			s.move(s.pos - 1)
+		case keyRight:
+			s.move(s.pos + 1)
+		case keyHome:
+			s.move(0)
+		case keyEnd:
+			s.move(len(s.buf))
+		case keyWordLeft:
+			s.move(wordStart(s.buf, s.pos))
+		case keyWordRight:
+			s.move(wordEnd(s.buf, s.pos))
+		case keyDelete:
+			if s.pos < len(s.buf) {
+				s.buf = append(s.buf[:s.pos], s.buf[s.pos+1:]...)
+				s.render()
+			}
+		}
+	}
+}
+
+// render redraws the prompt and line from the prompt's first row and puts
+// the cursor back at pos. Lines longer than the terminal wrap; runes are
+// taken to be one column wide.
+func (s *editState) render() {
+	var b strings.Builder
+	if s.row > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", s.row)
+	}
+	b.WriteString("\r" + s.prompt + string(s.buf) + "\x1b[J")
+
+	width := utf8.RuneCountInString(s.prompt)
+	total := width + len(s.buf)
+	// With the line ending on the last column the terminal has not
+	// wrapped yet; start the next row so the cursor can go there.
+	row := (max(total, 1) - 1) / s.cols
+	if s.pos == len(s.buf) && total > 0 && total%s.cols == 0 {
+		b.WriteString("\r\n")
+		row++
+	}
+	target := (width + s.pos) / s.cols
+	if row > target {
+		fmt.Fprintf(&b, "\x1b[%dA", row-target)
+	}
+	b.WriteString("\r")
+	if col := (width + s.pos) % s.cols; col > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", col)
+	}
+	s.row = target
+	fmt.Print(b.String())
+}
+
+// move puts the cursor at pos, if it is within the line.
+func (s *editState) move(pos int) {
+	if pos >= 0 && pos <= len(s.buf) && pos != s.pos {
+		s.pos = pos
+		s.render()
+	}
+}
+
+// end moves the cursor past the line, so that output starts below it.
+func (s *editState) end() {
+	s.pos = len(s.buf)
+	s.render()
+}
+
+// completeWord completes the word before the cursor. One candidate
+// replaces the word; several replace it with their common prefix, or are
+// listed when that adds nothing. Candidates need not start with the word:
+// the server may match timezone names by any part.
+func (e *lineEditor) completeWord(s *editState) {
+	before := string(s.buf[:s.pos])
+	matches := e.complete(before)
+	if len(matches) == 0 {
+		return
+	}
+	word := []rune(before[strings.LastIndexAny(before, " \t")+1:])
+	completion := matches[0]
+	if len(matches) == 1 {
+		completion += " "
+	}
+	for _, m := range matches[1:] {
+		completion = commonPrefix(completion, m)
+	}
+	if len(matches) == 1 || utf8.RuneCountInString(completion) > len(word) {
+		start := s.pos - len(word)
+		rest := append([]rune(completion), s.buf[s.pos:]...)
+		s.buf = append(s.buf[:start], rest...)
+		s.pos = start + utf8.RuneCountInString(completion)
+		s.render()
+		return
+	}
+	pos := s.pos
+	s.end()
+	fmt.Print("\r\n" + strings.Join(matches, "  ") + "\r\n")
+	s.pos, s.row = pos, 0
+	s.render()
+}
+
+func commonPrefix(a, b string) string {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	for n > 0 && n < len(a) && !utf8.RuneStart(a[n]) {
+		n--
+	}
+	return a[:n]
+}
+
+// wordStart returns where the word before pos starts, skipping the spaces
+// in between.
+func wordStart(buf []rune, pos int) int {
+	for pos > 0 && unicode.IsSpace(buf[pos-1]) {
+		pos--
+	}
+	for pos > 0 && !unicode.IsSpace(buf[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+// wordEnd returns where the word after pos ends.
+func wordEnd(buf []rune, pos int) int {
+	for pos < len(buf) && unicode.IsSpace(buf[pos]) {
+		pos++
+	}
+	for pos < len(buf) && !unicode.IsSpace(buf[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// readByte reads one byte of input. Reads are unbuffered, so that nothing
+// typed ahead is lost to the forms, which read stdin themselves.
+func readByte() (byte, bool) {
+	in := make([]byte, 1)
+	if n, err := os.Stdin.Read(in); n == 0 || err != nil {
+		return 0, false
+	}
+	return in[0], true
+}
+
+// readRune completes the UTF-8 sequence that starts with c.
+func readRune(c byte) rune {
+	b := []byte{c}
+	for !utf8.FullRune(b) {
+		next, ok := readByte()
+		if !ok {
+			break
+		}
+		b = append(b, next)
+	}
+	r, _ := utf8.DecodeRune(b)
+	return r
+}
+
+// readEscape reads the rest of an escape sequence and returns the key it
+// stands for, or keyNone for one the editor does not act on. Terminals
+// send the arrow, Home, End and Delete keys in either the CSI (ESC [) or
+// the SS3 (ESC O) form; Alt-B and Alt-F come as ESC b and ESC f.
+func readEscape() int {
+	c, ok := readByte()
+	if !ok {
+		return keyNone
+	}
+	switch c {
+	case 'b':
+		return keyWordLeft
+	case 'f':
+		return keyWordRight
+	case '[', 'O':
+	default:
+		return keyNone
+	}
+	var seq []byte
+	for {
+		c, ok := readByte()
+		if !ok {
+			return keyNone
+		}
+		seq = append(seq, c)
+		if c >= 0x40 && c <= 0x7e {
+			break
+		}
+	}
+	switch string(seq) {
+	case "A":
+		return keyUp
+	case "B":
+		return keyDown
+	case "C":
+		return keyRight
+	case "D":
+		return keyLeft
+	case "H", "1~", "7~":
+		return keyHome
+	case "F", "4~", "8~":
+		return keyEnd
+	case "3~":
+		return keyDelete
+	case "1;5C", "1;3C":
+		return keyWordRight
+	case "1;5D", "1;3D":
+		return keyWordLeft
+	}
+	return keyNone
+}
@@ -1,17 +1,24 @@
 package main
 
 import (
-	"bufio"
 	"context"
+	"crypto/ecdh"
+	"crypto/ed25519"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"mcp-demo-server/pkg/mcpclient"
+	"mcp-demo-server/pkg/payloadcrypt"
+	"mcp-demo-server/pkg/signature"
 )
 
 const (
@@ -21,40 +28,184 @@ const (
 
 type Config struct {
 	ServerURL string
-	Timeout   time.Duration
+	// Transport is -transport: auto, streamable or sse. WebSocket URLs
+	// always use WebSocket.
+	Transport string
+	// Command, if set, is a server to start and talk to over stdio instead
+	// of ServerURL.
+	Command string
+	// Header is sent with every HTTP request to the server: -header,
+	// -bearer-token and -basic-auth.
+	Header       http.Header
+	Timeout      time.Duration
+	AutoRefresh  bool
+	PingInterval time.Duration
+	Proxy        string
+	UnixSocket   string
+	Resolve      []string
+	// VerifySignatures rejects tool results that are not signed by the
+	// server's key: SigningKey (base64) if set, else the key the server
+	// publishes at /.well-known/mcp-signing-key.
+	VerifySignatures bool
+	SigningKey       string
+	// EncryptPayloads encrypts the session end to end with the server's
+	// X25519 key: EncryptionKey (base64) if set, else the key the server
+	// publishes at /.well-known/mcp-encryption-key. Only a pinned key
+	// protects against a proxy that terminates TLS.
+	EncryptPayloads bool
+	EncryptionKey   string
+	// LogLevel, if set, is sent with logging/setLevel after connecting;
+	// the server's log events are then printed as they arrive.
+	LogLevel string
+}
+
+// target names the server for messages: its command or its URL.
+func (c Config) target() string {
+	if c.Command != "" {
+		return c.Command
+	}
+	return c.ServerURL
 }
 
 func main() {
 	// Parse command-line flags
-	serverURL := flag.String("url", "http://localhost:8080/mcp", "MCP server Streamable HTTP endpoint URL")
+	serverURL := flag.String("url", "http://localhost:8080/mcp", "MCP server endpoint URL: Streamable HTTP or SSE, or WebSocket with ws:// or wss:// (e.g. ws://localhost:8080/ws)")
+	transport := flag.String("transport", transportAuto, "HTTP transport for -url: streamable, sse, or auto to probe the endpoint for one of them")
+	command := flag.String("cmd", "", "Start this server command and talk to it over stdio instead of -url (e.g. \"./server -mode stdio\"; arguments are split on spaces, without a shell)")
+	var headers stringList
+	flag.Var(&headers, "header", "Send this header with every request to the server, as \"Name: value\" (repeatable) [$"+headerEnvVar+", one per line]")
+	bearerToken := flag.String("bearer-token", "", "Send \"Authorization: Bearer <token>\" with every request to the server [$"+bearerTokenEnvVar+"]")
+	basicAuth := flag.String("basic-auth", "", "Send HTTP basic auth as user:password with every request to the server [$"+basicAuthEnvVar+"]")
 	timeout := flag.Duration("timeout", defaultTimeout, "Request timeout duration")
 	interactive := flag.Bool("i", false, "Interactive mode (REPL)")
-	tool := flag.String("tool", "", "Tool name to call (echotest, timeserver, fetch)")
+	historyFile := flag.String("history-file", defaultHistoryFile(), "File the REPL keeps its command history in across sessions (empty disables); Up/Down recall and line editing need a terminal on Linux, macOS or BSD, elsewhere input is read as plain lines")
+	script := flag.String("script", "", "Run the commands in this file, checking their results with expect lines, and exit 1 if any fail")
+	var scriptVars stringList
+	flag.Var(&scriptVars, "var", "Set a variable as name=value before -script or -i runs (repeatable)")
+	output := flag.String("output", outputText, "How tool results, resources and prompts are printed: text, json (the whole result on one line, for jq) or pretty (indented JSON)")
+	tool := flag.String("tool", "", "Tool name to call (see 'list')")
 	args := flag.String("args", "{}", "Tool arguments as JSON string")
+	autoRefresh := flag.Bool("auto-refresh", false, "Re-list tools/resources/prompts when the server reports a change")
+	pingInterval := flag.Duration("ping-interval", 30*time.Second, "Interval between keep-alive pings in interactive mode (0 disables)")
+	proxy := flag.String("proxy", "", "HTTP(S) proxy URL for the server connection (default: HTTP_PROXY/HTTPS_PROXY)")
+	unixSocket := flag.String("unix-socket", "", "Connect to the server over this unix socket instead of TCP")
+	var resolve stringList
+	flag.Var(&resolve, "resolve", "Override DNS as host:port:addr or host:addr (repeatable)")
+	exportFormat := flag.String("export-functions", "", "Print the server's tools as openai or anthropic function schemas and exit")
+	verifySignatures := flag.Bool("verify-signatures", false, "Verify the Ed25519 signature on every tool result (server must run with -sign-responses)")
+	signingKey := flag.String("signing-key", "", "Base64 Ed25519 public key for -verify-signatures (default: fetched from the server's /.well-known/mcp-signing-key)")
+	encryptPayloads := flag.Bool("encrypt-payloads", false, "Encrypt tool calls, listings and results end to end (server must run with -encrypt-payloads)")
+	encryptionKey := flag.String("encryption-key", "", "Base64 X25519 public key for -encrypt-payloads (default: fetched from the server's /.well-known/mcp-encryption-key, which an untrusted proxy could replace)")
+	logLevel := flag.String("log-level", "", "Ask the server for log events at this level and above (debug, info, notice, warning, error, critical, alert, emergency) and print them")
+	cancelAfter := flag.Duration("cancel-after", 0, "With -tool, cancel the call after this long (sends notifications/cancelled)")
+	record := flag.String("record", "", "Record every HTTP request and response of the session to this JSON Lines file, for export-transcript (credentials are left out)")
 	flag.Parse()
 
+	switch *logLevel {
+	case "", "debug", "info", "notice", "warning", "error", "critical", "alert", "emergency":
+	default:
+		log.Fatalf("Invalid -log-level %q", *logLevel)
+	}
+
+	if err := setOutputFormat(*output); err != nil {
+		log.Fatalf("Invalid -output: %v", err)
+	}
+	if *output != outputText && !*interactive {
+		statusOut = os.Stderr
+	}
+	for _, v := range scriptVars {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok {
+			log.Fatalf("Invalid -var %q: want name=value", v)
+		}
+		if err := handleSet([]string{"set", name, value}); err != nil {
+			log.Fatalf("Invalid -var: %v", err)
+		}
+	}
+	switch *transport {
+	case transportAuto, transportStreamable, transportSSE:
+	default:
+		log.Fatalf("Invalid -transport %q (want auto, streamable or sse)", *transport)
+	}
+	if strings.TrimSpace(*command) == "" {
+		*command = ""
+	}
+	header, err := authHeaders(headers, *bearerToken, *basicAuth)
+	if err != nil {
+		log.Fatalf("Invalid auth flags: %v", err)
+	}
+
+	if flag.Arg(0) == "export-transcript" {
+		runExportTranscript(flag.Args()[1:])
+		return
+	}
+	if *record != "" {
+		if u, err := url.Parse(*serverURL); err == nil && (u.Scheme == "ws" || u.Scheme == "wss") {
+			log.Fatalf("-record needs a Streamable HTTP -url; WebSocket messages are not recorded")
+		}
+		if *command != "" || *transport == transportSSE {
+			log.Fatalf("-record needs a Streamable HTTP -url; stdio and SSE messages are not recorded")
+		}
+		// Only Streamable HTTP is recorded, and a probe would be.
+		*transport = transportStreamable
+		var err error
+		if recording, err = openTranscript(*record); err != nil {
+			log.Fatalf("Invalid -record: %v", err)
+		}
+	}
+
 	config := Config{
-		ServerURL: *serverURL,
-		Timeout:   *timeout,
+		ServerURL:    *serverURL,
+		Transport:    *transport,
+		Command:      *command,
+		Header:       header,
+		Timeout:      *timeout,
+		AutoRefresh:  *autoRefresh,
+		PingInterval: *pingInterval,
+		Proxy:        *proxy,
+		UnixSocket:   *unixSocket,
+		Resolve:      resolve,
+
+		VerifySignatures: *verifySignatures,
+		SigningKey:       *signingKey,
+		EncryptPayloads:  *encryptPayloads,
+		EncryptionKey:    *encryptionKey,
+		LogLevel:         *logLevel,
 	}
 
-	if *interactive {
-		runInteractive(config)
+	if flag.Arg(0) == "fuzz" {
+		runFuzz(config, flag.Args()[1:])
+	} else if flag.Arg(0) == "bench" {
+		runBench(config, flag.Args()[1:])
+	} else if *exportFormat != "" {
+		runExport(config, *exportFormat)
+	} else if *script != "" {
+		if !runScript(config, *script) {
+			os.Exit(1)
+		}
+	} else if *interactive {
+		runInteractive(config, *historyFile)
 	} else if *tool != "" {
-		runSingleCommand(config, *tool, *args)
+		runSingleCommand(config, *tool, *args, *cancelAfter)
 	} else {
 		fmt.Println("MCP Test Client")
 		fmt.Println()
 		fmt.Println("Usage:")
 		fmt.Println("  Interactive mode: testclient -i [-url http://localhost:8080/mcp]")
 		fmt.Println("  Single command:   testclient -tool timeserver -args '{\"timezone\":\"Europe/Kyiv\"}'")
+		fmt.Println("  Over stdio:       testclient -cmd \"./server -mode stdio\" -i")
+		fmt.Println("  Run a script:     testclient -script smoke.mcp [-var name=value]")
+		fmt.Println("  Benchmark a tool: testclient [-url ...] bench [-tool echotest] [-concurrency 20] [-duration 30s]")
+		fmt.Println("  Fuzz all tools:   testclient [-url ...] fuzz [-cases 20] [-tools a,b] [-report fuzz-report.json]")
+		fmt.Println("  Record a session: testclient -record session.jsonl -i")
+		fmt.Println("  Export to curl:   testclient export-transcript [-format curl|http] [-o file] session.jsonl")
 		fmt.Println()
 		fmt.Println("Flags:")
 		flag.PrintDefaults()
 	}
 }
 
-func runSingleCommand(config Config, toolName, argsJSON string) {
+func runSingleCommand(config Config, toolName, argsJSON string, cancelAfter time.Duration) {
 	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
 	defer cancel()
 
@@ -65,62 +216,119 @@ func runSingleCommand(config Config, toolName, argsJSON string) {
 	}
 
 	// Connect to server
-	fmt.Printf("Connecting to %s...\n", config.ServerURL)
-	session, err := connectToServer(ctx, config.ServerURL)
+	fmt.Fprintf(statusOut, "Connecting to %s...\n", config.target())
+	client, err := connectToServer(ctx, config)
 	if err != nil {
 		log.Fatalf("Failed to connect: %v", err)
 	}
-	defer session.Close()
+	defer client.Close()
 
 	// Call tool
-	result, err := callTool(ctx, session, toolName, toolArgs)
+	var result string
+	if cancelAfter > 0 {
+		start := time.Now()
+		var cancelled bool
+		result, cancelled, err = callWithCancel(ctx, client, toolName, toolArgs, cancelAfter)
+		if cancelled {
+			fmt.Printf("\n=== Cancelled ===\nCancelled after %s; the server was sent notifications/cancelled\n", time.Since(start).Round(time.Millisecond))
+			return
+		}
+	} else {
+		result, err = callTool(ctx, client, toolName, toolArgs)
+	}
 	if err != nil {
 		log.Fatalf("Tool call failed: %v", err)
 	}
 
 	// Print result
-	fmt.Println("\n=== Result ===")
+	fmt.Fprintln(statusOut, "\n=== Result ===")
 	fmt.Println(result)
 }
 
-func runInteractive(config Config) {
+func runExport(config Config, format string) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+	defer cancel()
+
+	client, err := connectToServer(ctx, config)
+	if err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	data, err := exportFunctions(ctx, client, format)
+	if err != nil {
+		log.Fatalf("Export failed: %v", err)
+	}
+	fmt.Println(string(data))
+}
+
+func runInteractive(config Config, historyFile string) {
 	fmt.Printf("MCP Test Client %s - Interactive Mode\n", version)
-	fmt.Printf("Connecting to %s...\n", config.ServerURL)
+	if historyFile != "" {
+		if err := loadHistory(historyFile); err != nil {
+			log.Printf("History is not saved: %v", err)
+		}
+	}
+	fmt.Printf("Connecting to %s...\n", config.target())
 
 	ctx := context.Background()
-	session, err := connectToServer(ctx, config.ServerURL)
+	client, err := connectToServer(ctx, config)
 	if err != nil {
 		log.Fatalf("Failed to connect: %v", err)
 	}
-	defer session.Close()
+	live := startKeepalive(config, client)
+	defer live.Close()
 
 	fmt.Println("Connected successfully!")
 	fmt.Println()
 	printHelp()
 
-	scanner := bufio.NewScanner(os.Stdin)
+	editor := newLineEditor(replCompleter(live))
 	for {
-		fmt.Print("\nmcp> ")
-		if !scanner.Scan() {
+		line, ok := editor.readLine("\nmcp> ")
+		if !ok {
 			break
 		}
 
-		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
 		}
 
-		if err := handleCommand(ctx, session, line); err != nil {
+		line, changed, err := expandHistory(line)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			continue
+		}
+		if changed {
+			fmt.Println(line)
+		}
+		recordHistory(line)
+
+		client, err := live.Client(ctx)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			continue
+		}
+
+		if err := handleCommand(ctx, client, line); err != nil {
 			fmt.Printf("Error: %v\n", err)
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
+	if err := stdin.Err(); err != nil {
 		log.Printf("Scanner error: %v", err)
 	}
 }
 
-func handleCommand(ctx context.Context, session *mcp.ClientSession, line string) error {
+func handleCommand(ctx context.Context, client *mcpclient.Client, line string) error {
+	line, filters, err := splitPipeline(line)
+	if err != nil {
+		return err
+	}
+	activeFilters = filters
+	defer func() { activeFilters = nil }()
+
 	parts := strings.Fields(line)
 	if len(parts) == 0 {
 		return nil
@@ -139,32 +347,93 @@ func handleCommand(ctx context.Context, session *mcp.ClientSession, line string)
 		return nil
 
 	case "list", "ls":
-		return listTools(ctx, session)
+		return listTools(ctx, client)
+
+	case "refresh":
+		for _, kind := range []mcpclient.ListKind{mcpclient.Tools, mcpclient.Resources, mcpclient.Prompts} {
+			cache.invalidate(kind)
+		}
+		return listTools(ctx, client)
+
+	case "history":
+		return printHistory(parts)
+
+	case "replay":
+		return replayHistory(ctx, client, parts)
+
+	case "edit":
+		return editHistory(ctx, client, parts)
+
+	case "export-functions", "export":
+		return runExportFunctions(ctx, client, parts)
+
+	case "cancel":
+		return runCancel(ctx, client, parts)
+
+	case "set":
+		return handleSet(parts)
+
+	case "unset":
+		if len(parts) != 2 {
+			return fmt.Errorf("usage: unset <name>")
+		}
+		delete(vars, parts[1])
+		return nil
+
+	case "template", "tmpl":
+		if len(parts) != 2 {
+			return fmt.Errorf("usage: template <file.json>")
+		}
+		return runTemplate(ctx, client, parts[1])
+
+	case "call":
+		return runCall(ctx, client, parts)
+
+	case "format", "output":
+		return runFormat(parts)
+
+	case "watch":
+		return runWatch(ctx, client, parts)
+
+	case "unwatch":
+		return runUnwatch(ctx, client)
+
+	case "resources":
+		return listResources(ctx, client)
+
+	case "read":
+		return runRead(ctx, client, parts)
+
+	case "prompts":
+		return listPrompts(ctx, client)
+
+	case "prompt":
+		return runPrompt(ctx, client, parts)
 
 	case "echo", "echotest":
 		if len(parts) < 2 {
-			return fmt.Errorf("usage: echo <message>")
+			return runToolForm(ctx, client, "echotest")
 		}
 		message := strings.Join(parts[1:], " ")
-		return runEchoTest(ctx, session, message)
+		return runEchoTest(ctx, client, message)
 
 	case "time", "timeserver":
 		timezone := ""
 		if len(parts) > 1 {
 			timezone = parts[1]
 		}
-		return runTimeServer(ctx, session, timezone)
+		return runTimeServer(ctx, client, timezone)
 
 	case "fetch":
 		if len(parts) < 2 {
-			return fmt.Errorf("usage: fetch <url> [max_bytes]")
+			return runToolForm(ctx, client, "fetch")
 		}
 		url := parts[1]
 		maxBytes := 0
 		if len(parts) > 2 {
 			fmt.Sscanf(parts[2], "%d", &maxBytes)
 		}
-		return runFetch(ctx, session, url, maxBytes)
+		return runFetch(ctx, client, url, maxBytes)
 
 	default:
 		return fmt.Errorf("unknown command: %s (type 'help' for available commands)", cmd)
@@ -175,48 +444,130 @@ func printHelp() {
 	fmt.Println("Available commands:")
 	fmt.Println("  help, h, ?              Show this help message")
 	fmt.Println("  list, ls                List available tools")
+	fmt.Println("  refresh                 Drop cached listings and re-list tools")
+	fmt.Println("  call <tool> [json]      Call any tool (e.g., call url_status {\"url\":\"https://example.com\"})")
+	fmt.Println("  format [text|json|pretty]  Show or set how results are printed (json and pretty print the whole result)")
+	fmt.Println("  watch [uri ...]         Print server notifications as they arrive: logs (down to debug), progress, list changes and updates of the given resources")
+	fmt.Println("  unwatch                 Stop printing notifications and unsubscribe")
+	fmt.Println("  resources               List resources and resource templates")
+	fmt.Println("  read <uri>              Read a resource (e.g., read server://uptime)")
+	fmt.Println("  prompts                 List prompts and their arguments")
+	fmt.Println("  prompt <name> [args]    Get a prompt; args are JSON or name=value pairs (e.g., prompt current_time timezone=Europe/Kyiv)")
 	fmt.Println("  echo <message>          Test echotest tool")
 	fmt.Println("  time [timezone]         Test timeserver tool (e.g., time Europe/Kyiv)")
 	fmt.Println("  fetch <url> [max_bytes] Test fetch tool (e.g., fetch https://ifconfig.co/json 1024)")
+	fmt.Println("  cancel <after> <tool> [json]  Call a tool and cancel it after a delay (e.g., cancel 2s echotest {\"message\":\"hi\",\"delay_ms\":10000})")
+	fmt.Println("  export-functions openai|anthropic [file]  Export tool schemas for non-MCP LLM APIs")
+	fmt.Println("  history [N]             Show command history (last N entries)")
+	fmt.Println("  !N, !-N, !!             Re-run history entry N, the Nth most recent, or the last")
+	fmt.Println("  !N:s/old/new/, ^old^new Re-run an entry (or the last) with a substitution")
+	fmt.Println("  replay last N | A-B     Re-run the last N entries or entries A through B")
+	fmt.Println("  edit [N]                Edit entry N (default last) in $EDITOR, then run it")
+	fmt.Println("  set [name value]        Set a variable, or list variables (use as $name or ${name})")
+	fmt.Println("  unset <name>            Remove a variable")
+	fmt.Println("  template <file.json>    Call a tool from a {\"tool\":...,\"arguments\":{...}} template")
 	fmt.Println("  quit, exit, q           Exit the client")
+	fmt.Println()
+	fmt.Println("Pipe tool output through filters: <command> | json .path[0].key | grep [-v] [-i] re | head N | tail N | wc")
+	fmt.Println()
+	fmt.Println("Run echo, fetch or call <tool> without arguments to be prompted for each field.")
+	fmt.Println("Tab completes commands, tool names after call and cancel, and timezones after time.")
+	fmt.Println("Up and Down recall earlier commands, also from past sessions; the line edits like a shell's.")
 }
 
-func connectToServer(ctx context.Context, serverURL string) (*mcp.ClientSession, error) {
-	// Create MCP client
-	client := mcp.NewClient(&mcp.Implementation{
-		Name:    "mcp-test-client",
-		Version: version,
-	}, nil)
+func connectToServer(ctx context.Context, config Config) (*mcpclient.Client, error) {
+	httpClient, err := newHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var verifyKey ed25519.PublicKey
+	if config.VerifySignatures {
+		if verifyKey, err = loadVerifyKey(ctx, httpClient, config); err != nil {
+			return nil, fmt.Errorf("signing key: %w", err)
+		}
+		fmt.Fprintf(statusOut, "Verifying tool results against signing key %s\n", signature.KeyID(verifyKey))
+	}
 
-	// Create Streamable HTTP transport
-	transport := &mcp.StreamableClientTransport{
-		Endpoint:   serverURL,
-		MaxRetries: 3,
+	var encryptionKey *ecdh.PublicKey
+	if config.EncryptPayloads {
+		if encryptionKey, err = loadEncryptionKey(ctx, httpClient, config); err != nil {
+			return nil, fmt.Errorf("encryption key: %w", err)
+		}
+		fmt.Fprintf(statusOut, "Encrypting payloads with server key %s\n", payloadcrypt.KeyID(encryptionKey))
 	}
 
-	// Connect to server
-	session, err := client.Connect(ctx, transport, nil)
+	handlers := eventHandlers(listChangedHandlers(config.AutoRefresh), config.LogLevel)
+	transport, err := newTransport(ctx, config, httpClient)
 	if err != nil {
-		return nil, fmt.Errorf("connection failed: %w", err)
+		return nil, err
 	}
+	client, err := mcpclient.Connect(ctx, mcpclient.Options{
+		Endpoint:   config.ServerURL,
+		HTTPClient: httpClient,
+		Transport:  transport,
+		MaxRetries: 3,
+		Name:       "mcp-test-client",
+		Version:    version,
+		Handlers:   handlers,
+		VerifyKey:  verifyKey,
+
+		EncryptionKey: encryptionKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if config.LogLevel != "" {
+		level := mcp.LoggingLevel(config.LogLevel)
+		if err := client.Session().SetLoggingLevel(ctx, &mcp.SetLoggingLevelParams{Level: level}); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("logging/setLevel: %w", err)
+		}
+	}
+	if err := rewatch(ctx, client); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
 
-	return session, nil
+// loadVerifyKey decodes -signing-key or fetches the server's published key.
+func loadVerifyKey(ctx context.Context, httpClient *http.Client, config Config) (ed25519.PublicKey, error) {
+	if config.SigningKey == "" {
+		if config.Command != "" {
+			return nil, fmt.Errorf("a server started with -cmd publishes no key; pass -signing-key")
+		}
+		return signature.Fetch(ctx, httpClient, config.ServerURL)
+	}
+	return signature.PublicKey{Alg: signature.Algorithm, Key: config.SigningKey}.Decode()
+}
+
+// loadEncryptionKey decodes -encryption-key or fetches the server's
+// published key.
+func loadEncryptionKey(ctx context.Context, httpClient *http.Client, config Config) (*ecdh.PublicKey, error) {
+	if config.EncryptionKey == "" {
+		if config.Command != "" {
+			return nil, fmt.Errorf("a server started with -cmd publishes no key; pass -encryption-key")
+		}
+		return payloadcrypt.Fetch(ctx, httpClient, config.ServerURL)
+	}
+	return payloadcrypt.ParsePublicKey(config.EncryptionKey)
 }
 
-func listTools(ctx context.Context, session *mcp.ClientSession) error {
+func listTools(ctx context.Context, client *mcpclient.Client) error {
 	fmt.Println("\n=== Listing available tools ===")
 
-	result, err := session.ListTools(ctx, &mcp.ListToolsParams{})
+	tools, err := cache.Tools(ctx, client)
 	if err != nil {
-		return fmt.Errorf("failed to list tools: %w", err)
+		return err
 	}
 
-	if len(result.Tools) == 0 {
+	if len(tools) == 0 {
 		fmt.Println("No tools available")
 		return nil
 	}
 
-	for i, tool := range result.Tools {
+	for i, tool := range tools {
 		fmt.Printf("%d. %s\n", i+1, tool.Name)
 		if tool.Description != "" {
 			fmt.Printf("   Description: %s\n", tool.Description)
@@ -226,31 +577,11 @@ func listTools(ctx context.Context, session *mcp.ClientSession) error {
 	return nil
 }
 
-func callTool(ctx context.Context, session *mcp.ClientSession, name string, args map[string]interface{}) (string, error) {
-	result, err := session.CallTool(ctx, &mcp.CallToolParams{
-		Name:      name,
-		Arguments: args,
-	})
-	if err != nil {
-		return "", fmt.Errorf("tool call failed: %w", err)
-	}
-
-	if result.IsError {
-		return "", fmt.Errorf("tool returned error")
-	}
-
-	// Extract text content from result
-	var output strings.Builder
-	for _, content := range result.Content {
-		if textContent, ok := content.(*mcp.TextContent); ok {
-			output.WriteString(textContent.Text)
-		}
-	}
-
-	return output.String(), nil
+func callTool(ctx context.Context, client *mcpclient.Client, name string, args map[string]interface{}) (string, error) {
+	return renderResult(client.CallTool(ctx, name, args))
 }
 
-func runEchoTest(ctx context.Context, session *mcp.ClientSession, message string) error {
+func runEchoTest(ctx context.Context, client *mcpclient.Client, message string) error {
 	fmt.Println("\n=== Calling echotest ===")
 	fmt.Printf("Message: %s\n", message)
 
@@ -258,17 +589,16 @@ func runEchoTest(ctx context.Context, session *mcp.ClientSession, message string
 		"message": message,
 	}
 
-	result, err := callTool(ctx, session, "echotest", args)
+	result, err := callTool(ctx, client, "echotest", args)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("\n=== Result ===")
-	fmt.Println(result)
+	printResult(result)
 	return nil
 }
 
-func runTimeServer(ctx context.Context, session *mcp.ClientSession, timezone string) error {
+func runTimeServer(ctx context.Context, client *mcpclient.Client, timezone string) error {
 	fmt.Println("\n=== Calling timeserver ===")
 	if timezone != "" {
 		fmt.Printf("Timezone: %s\n", timezone)
@@ -281,17 +611,16 @@ func runTimeServer(ctx context.Context, session *mcp.ClientSession, timezone str
 		args["timezone"] = timezone
 	}
 
-	result, err := callTool(ctx, session, "timeserver", args)
+	result, err := callTool(ctx, client, "timeserver", args)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("\n=== Result ===")
-	fmt.Println(result)
+	printResult(result)
 	return nil
 }
 
-func runFetch(ctx context.Context, session *mcp.ClientSession, url string, maxBytes int) error {
+func runFetch(ctx context.Context, client *mcpclient.Client, url string, maxBytes int) error {
 	fmt.Println("\n=== Calling fetch ===")
 	fmt.Printf("URL: %s\n", url)
 	if maxBytes > 0 {
@@ -305,12 +634,11 @@ func runFetch(ctx context.Context, session *mcp.ClientSession, url string, maxBy
 		args["max_bytes"] = maxBytes
 	}
 
-	result, err := callTool(ctx, session, "fetch", args)
+	result, err := callTool(ctx, client, "fetch", args)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("\n=== Result ===")
-	fmt.Println(result)
+	printResult(result)
 	return nil
 }
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"mcp-demo-server/pkg/mcpclient"
+)
+
+// listCache holds the most recent tools, resources and prompts listings.
+// A nil slice means the listing is stale and must be fetched again; the
+// *_list_changed notification handlers reset the matching entry.
+type listCache struct {
+	mu        sync.Mutex
+	tools     []*mcp.Tool
+	resources []*mcp.Resource
+	prompts   []*mcp.Prompt
+}
+
+var cache listCache
+
+func (c *listCache) Tools(ctx context.Context, client *mcpclient.Client) ([]*mcp.Tool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tools != nil {
+		return c.tools, nil
+	}
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tools: %w", err)
+	}
+	c.tools = tools
+	return tools, nil
+}
+
+func (c *listCache) Resources(ctx context.Context, client *mcpclient.Client) ([]*mcp.Resource, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.resources != nil {
+		return c.resources, nil
+	}
+	resources, err := client.ListResources(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resources: %w", err)
+	}
+	c.resources = resources
+	return resources, nil
+}
+
+func (c *listCache) Prompts(ctx context.Context, client *mcpclient.Client) ([]*mcp.Prompt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.prompts != nil {
+		return c.prompts, nil
+	}
+	prompts, err := client.ListPrompts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompts: %w", err)
+	}
+	c.prompts = prompts
+	return prompts, nil
+}
+
+// invalidate drops the named listing.
+func (c *listCache) invalidate(kind mcpclient.ListKind) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch kind {
+	case mcpclient.Tools:
+		c.tools = nil
+	case mcpclient.Resources:
+		c.resources = nil
+	case mcpclient.Prompts:
+		c.prompts = nil
+	}
+}
+
+// listChangedHandlers returns notification handlers that keep the cache
+// in sync with the server's list_changed notifications. When autoRefresh
+// is set, the changed listing is re-fetched and printed immediately.
+func listChangedHandlers(autoRefresh bool) mcpclient.Handlers {
+	return mcpclient.Handlers{
+		ListChanged: func(client *mcpclient.Client, kind mcpclient.ListKind) {
+			cache.invalidate(kind)
+			if watchOn() {
+				printEvent("changed", "%s list changed", kind)
+			} else {
+				fmt.Printf("\n[notification] %s list changed\n", kind)
+			}
+			if !autoRefresh {
+				return
+			}
+			// Refresh outside the notification handler so that the session's
+			// read loop is free to deliver the list response.
+			go func() {
+				if err := printListing(context.Background(), client, kind); err != nil {
+					fmt.Printf("Error refreshing %s: %v\n", kind, err)
+				}
+			}()
+		},
+	}
+}
+
+// printListing prints the refreshed listing of the given kind.
+func printListing(ctx context.Context, client *mcpclient.Client, kind mcpclient.ListKind) error {
+	switch kind {
+	case mcpclient.Tools:
+		return listTools(ctx, client)
+	case mcpclient.Resources:
+		return listResources(ctx, client)
+	case mcpclient.Prompts:
+		return listPrompts(ctx, client)
+	}
+	return nil
+}
+
+// printLogMessage prints a notifications/message event. Object data is
+// shown as its "message" followed by the other fields as key=value.
+func printLogMessage(_ *mcpclient.Client, params *mcp.LoggingMessageParams) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[log] %s", params.Level)
+	if params.Logger != "" {
+		fmt.Fprintf(&b, " %s", params.Logger)
+	}
+	b.WriteString(":")
+	if fields, ok := params.Data.(map[string]any); ok {
+		if msg, ok := fields["message"].(string); ok {
+			fmt.Fprintf(&b, " %s", msg)
+		}
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			if k != "message" {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			v, _ := json.Marshal(fields[k])
+			fmt.Fprintf(&b, " %s=%s", k, v)
+		}
+	} else {
+		v, _ := json.Marshal(params.Data)
+		fmt.Fprintf(&b, " %s", v)
+	}
+	fmt.Println(b.String())
+}
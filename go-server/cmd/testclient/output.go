@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"mcp-demo-server/pkg/mcpclient"
+)
+
+// Values of -output and the format command.
+const (
+	outputText   = "text"
+	outputJSON   = "json"
+	outputPretty = "pretty"
+)
+
+// outputFormat is how tool results, resources and prompts are printed:
+// as their text, or as the whole result structure in JSON, on one line or
+// indented.
+var outputFormat = outputText
+
+// statusOut receives connection messages. A single command with JSON
+// output sends them to stderr, leaving stdout to the result for jq.
+var statusOut io.Writer = os.Stdout
+
+func setOutputFormat(format string) error {
+	switch format {
+	case outputText, outputJSON, outputPretty:
+		outputFormat = format
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q (want text, json or pretty)", format)
+}
+
+// renderResult renders a tool result in the output format. In the JSON
+// formats an IsError result is rendered like any other, isError included,
+// rather than returned as an error.
+func renderResult(result *mcp.CallToolResult, err error) (string, error) {
+	var toolErr *mcpclient.ToolError
+	if err != nil && !(outputFormat != outputText && errors.As(err, &toolErr)) {
+		return "", err
+	}
+	if outputFormat == outputText {
+		return mcpclient.Text(result), nil
+	}
+	// isError is left out when false; print it either way, so that a jq
+	// filter on it always finds it.
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("rendering result: %w", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", fmt.Errorf("rendering result: %w", err)
+	}
+	fields["isError"] = result.IsError
+	return renderJSON(fields)
+}
+
+// renderJSON renders v in the JSON output format.
+func renderJSON(v any) (string, error) {
+	var data []byte
+	var err error
+	if outputFormat == outputPretty {
+		data, err = json.MarshalIndent(v, "", "  ")
+	} else {
+		data, err = json.Marshal(v)
+	}
+	if err != nil {
+		return "", fmt.Errorf("rendering result: %w", err)
+	}
+	return string(data), nil
+}
+
+// runFormat handles "format [text|json|pretty]".
+func runFormat(parts []string) error {
+	switch len(parts) {
+	case 1:
+		fmt.Printf("Output format: %s\n", outputFormat)
+		return nil
+	case 2:
+		return setOutputFormat(parts[1])
+	}
+	return fmt.Errorf("usage: format [text|json|pretty]")
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"mcp-demo-server/pkg/mcpclient"
+)
+
+// listResources prints the server's resources and resource templates.
+func listResources(ctx context.Context, client *mcpclient.Client) error {
+	fmt.Println("\n=== Resources ===")
+	resources, err := cache.Resources(ctx, client)
+	if err != nil {
+		return err
+	}
+	if len(resources) == 0 {
+		fmt.Println("No resources available")
+	}
+	for i, r := range resources {
+		fmt.Printf("%d. %s (%s)\n", i+1, r.URI, r.Name)
+		if r.MIMEType != "" {
+			fmt.Printf("   Type: %s\n", r.MIMEType)
+		}
+		if r.Description != "" {
+			fmt.Printf("   Description: %s\n", r.Description)
+		}
+	}
+
+	templates, err := client.ListResourceTemplates(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list resource templates: %w", err)
+	}
+	if len(templates) == 0 {
+		return nil
+	}
+	fmt.Println("\n=== Resource templates ===")
+	for i, t := range templates {
+		fmt.Printf("%d. %s (%s)\n", i+1, t.URITemplate, t.Name)
+		if t.Description != "" {
+			fmt.Printf("   Description: %s\n", t.Description)
+		}
+	}
+	return nil
+}
+
+// runRead handles "read <uri>". Text contents are printed through the
+// output filters; binary ones are summarized.
+func runRead(ctx context.Context, client *mcpclient.Client, parts []string) error {
+	if len(parts) != 2 {
+		return fmt.Errorf("usage: read <uri> (type 'resources' for the server's resources)")
+	}
+	res, err := client.Session().ReadResource(ctx, &mcp.ReadResourceParams{URI: parts[1]})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n=== Reading %s ===\n", parts[1])
+	if outputFormat != outputText {
+		out, err := renderJSON(res)
+		if err != nil {
+			return err
+		}
+		printResult(out)
+		return nil
+	}
+	var b strings.Builder
+	for _, c := range res.Contents {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		if c.Text != "" || len(c.Blob) == 0 {
+			b.WriteString(c.Text)
+		} else {
+			fmt.Fprintf(&b, "[%s %s, %d bytes]", c.URI, c.MIMEType, len(c.Blob))
+		}
+	}
+	printResult(b.String())
+	return nil
+}
+
+// listPrompts prints the server's prompts with their arguments.
+func listPrompts(ctx context.Context, client *mcpclient.Client) error {
+	fmt.Println("\n=== Prompts ===")
+	prompts, err := cache.Prompts(ctx, client)
+	if err != nil {
+		return err
+	}
+	if len(prompts) == 0 {
+		fmt.Println("No prompts available")
+	}
+	for i, p := range prompts {
+		fmt.Printf("%d. %s\n", i+1, p.Name)
+		if p.Description != "" {
+			fmt.Printf("   Description: %s\n", p.Description)
+		}
+		for _, arg := range p.Arguments {
+			required := ""
+			if arg.Required {
+				required = ", required"
+			}
+			fmt.Printf("   - %s (string%s) %s\n", arg.Name, required, arg.Description)
+		}
+	}
+	return nil
+}
+
+// runPrompt handles "prompt <name> [args]". Arguments are a JSON object
+// or name=value pairs; without any, the prompt's arguments are asked for
+// one by one.
+func runPrompt(ctx context.Context, client *mcpclient.Client, parts []string) error {
+	if len(parts) < 2 {
+		return fmt.Errorf("usage: prompt <name> [json | name=value ...] (e.g. prompt current_time timezone=Europe/Kyiv)")
+	}
+	name := parts[1]
+	prompt, err := findPrompt(ctx, client, name)
+	if err != nil {
+		return fmt.Errorf("%v (type 'prompts' for the server's prompts)", err)
+	}
+
+	var args map[string]string
+	switch {
+	case len(parts) == 2 && len(prompt.Arguments) > 0:
+		if args, err = promptPromptArgs(prompt); err != nil {
+			return err
+		}
+	case len(parts) > 2 && strings.HasPrefix(parts[2], "{"):
+		if err := json.Unmarshal([]byte(strings.Join(parts[2:], " ")), &args); err != nil {
+			return fmt.Errorf("invalid arguments: %v (prompt arguments are strings)", err)
+		}
+	default:
+		args = make(map[string]string)
+		for _, pair := range parts[2:] {
+			k, v, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("invalid argument %q: want name=value", pair)
+			}
+			args[k] = v
+		}
+	}
+
+	res, err := client.Session().GetPrompt(ctx, &mcp.GetPromptParams{Name: name, Arguments: args})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n=== Prompt %s ===\n", name)
+	if outputFormat != outputText {
+		out, err := renderJSON(res)
+		if err != nil {
+			return err
+		}
+		printResult(out)
+		return nil
+	}
+	if res.Description != "" {
+		fmt.Println(res.Description)
+	}
+	var b strings.Builder
+	for i, m := range res.Messages {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "[%s] %s", m.Role, mcpclient.ContentText(m.Content))
+	}
+	printResult(b.String())
+	return nil
+}
+
+// findPrompt looks up a prompt by name in the cached prompts/list result.
+func findPrompt(ctx context.Context, client *mcpclient.Client, name string) (*mcp.Prompt, error) {
+	prompts, err := cache.Prompts(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range prompts {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("prompt %q not found on server", name)
+}
+
+// promptPromptArgs asks for each argument of prompt, required ones first,
+// with the same form as tool arguments.
+func promptPromptArgs(prompt *mcp.Prompt) (map[string]string, error) {
+	schema := &jsonschema.Schema{Type: "object", Properties: make(map[string]*jsonschema.Schema)}
+	for _, arg := range prompt.Arguments {
+		schema.Properties[arg.Name] = &jsonschema.Schema{Type: "string", Description: arg.Description}
+		if arg.Required {
+			schema.Required = append(schema.Required, arg.Name)
+		}
+	}
+	fmt.Printf("Enter arguments for prompt %s (optional fields may be skipped)\n", prompt.Name)
+	values, err := promptObject(schema, "  ")
+	if err != nil {
+		return nil, err
+	}
+	args := make(map[string]string, len(values))
+	for k, v := range values {
+		args[k] = fmt.Sprint(v)
+	}
+	return args, nil
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"mcp-demo-server/pkg/mcpclient"
+)
+
+// stdin is shared by the REPL loop and the argument form so that both
+// consume the same buffered input stream.
+var stdin = bufio.NewScanner(os.Stdin)
+
+// findTool looks up a tool by name in the cached tools/list result.
+func findTool(ctx context.Context, client *mcpclient.Client, name string) (*mcp.Tool, error) {
+	tools, err := cache.Tools(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	for _, tool := range tools {
+		if tool.Name == name {
+			return tool, nil
+		}
+	}
+	return nil, fmt.Errorf("tool %q not found on server", name)
+}
+
+// toolSchema converts the wire form of a tool's input schema (a map on the
+// client side) into a typed schema.
+func toolSchema(tool *mcp.Tool) (*jsonschema.Schema, error) {
+	raw, err := json.Marshal(tool.InputSchema)
+	if err != nil {
+		return nil, err
+	}
+	var schema jsonschema.Schema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, fmt.Errorf("invalid input schema for %s: %w", tool.Name, err)
+	}
+	return &schema, nil
+}
+
+// schemaType returns the primary JSON type of a schema, ignoring "null"
+// in type unions (the SDK infers ["null","object"] for maps and slices).
+func schemaType(s *jsonschema.Schema) string {
+	if s.Type != "" {
+		return s.Type
+	}
+	for _, t := range s.Types {
+		if t != "null" {
+			return t
+		}
+	}
+	return "string"
+}
+
+// promptToolArgs fetches the input schema of the named tool and asks the
+// user for each property in turn, validating values against the schema
+// before they are sent. Required properties are prompted first, and the
+// properties of nested objects are prompted one by one.
+func promptToolArgs(ctx context.Context, client *mcpclient.Client, name string) (map[string]any, error) {
+	tool, err := findTool(ctx, client, name)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := toolSchema(tool)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Enter arguments for %s (empty line keeps the default, optional fields may be skipped)\n", name)
+	args, err := promptObject(schema, "  ")
+	if err != nil {
+		return nil, err
+	}
+	// The fields were checked one at a time; this catches what only the
+	// whole object can break, such as minProperties or dependencies.
+	if resolved, err := schema.Resolve(nil); err == nil {
+		if err := resolved.Validate(args); err != nil {
+			return nil, fmt.Errorf("arguments do not match the schema of %s: %v", name, err)
+		}
+	}
+	return args, nil
+}
+
+// promptObject prompts for the properties of an object schema, indenting
+// each prompt by indent.
+func promptObject(schema *jsonschema.Schema, indent string) (map[string]any, error) {
+	required := make(map[string]bool)
+	for _, r := range schema.Required {
+		required[r] = true
+	}
+	names := make([]string, 0, len(schema.Properties))
+	for prop := range schema.Properties {
+		names = append(names, prop)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if required[names[i]] != required[names[j]] {
+			return required[names[i]]
+		}
+		return names[i] < names[j]
+	})
+
+	args := make(map[string]any)
+	for _, prop := range names {
+		value, set, err := promptField(prop, schema.Properties[prop], required[prop], indent)
+		if err != nil {
+			return nil, err
+		}
+		if set {
+			args[prop] = value
+		}
+	}
+	return args, nil
+}
+
+// promptField reads a single property value, re-prompting until the input
+// is valid. It reports set=false for a skipped optional field. An object
+// with known properties and no default is prompted for field by field;
+// skipping all the fields of an optional one leaves it out.
+func promptField(name string, prop *jsonschema.Schema, required bool, indent string) (value any, set bool, err error) {
+	typ := schemaType(prop)
+	if typ == "array" && prop.Items != nil {
+		typ = "array of " + schemaType(prop.Items)
+	}
+	label := fmt.Sprintf("%s%s (%s", indent, name, typ)
+	if required {
+		label += ", required"
+	}
+	label += ")"
+	if len(prop.Default) > 0 {
+		label += fmt.Sprintf(" [default %s]", prop.Default)
+	}
+	if len(prop.Enum) > 0 {
+		label += fmt.Sprintf(" one of %v", prop.Enum)
+	}
+	if prop.Description != "" {
+		fmt.Printf("%s# %s\n", indent, prop.Description)
+	}
+	if typ == "object" && len(prop.Properties) > 0 && len(prop.Default) == 0 {
+		fmt.Printf("%s:\n", label)
+		obj, err := promptObject(prop, indent+"  ")
+		if err != nil {
+			return nil, false, err
+		}
+		return obj, required || len(obj) > 0, nil
+	}
+
+	for {
+		fmt.Printf("%s: ", label)
+		if !stdin.Scan() {
+			return nil, false, fmt.Errorf("input closed")
+		}
+		input := strings.TrimSpace(stdin.Text())
+
+		if input == "" {
+			if len(prop.Default) > 0 {
+				var def any
+				if err := json.Unmarshal(prop.Default, &def); err == nil {
+					return def, true, nil
+				}
+			}
+			if !required {
+				return nil, false, nil
+			}
+			fmt.Printf("%svalue is required\n", indent)
+			continue
+		}
+
+		v, err := parseField(input, schemaType(prop), prop)
+		if err != nil {
+			fmt.Printf("%sinvalid value: %v\n", indent, err)
+			continue
+		}
+		return v, true, nil
+	}
+}
+
+// parseField converts raw input to the schema's type and checks the
+// constraints the SDK-generated schemas commonly carry. Arrays of scalars
+// may also be given as comma-separated values.
+func parseField(input, typ string, prop *jsonschema.Schema) (any, error) {
+	var v any
+	switch typ {
+	case "integer":
+		n, err := strconv.ParseInt(input, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected an integer")
+		}
+		if err := checkRange(float64(n), prop); err != nil {
+			return nil, err
+		}
+		v = n
+	case "number":
+		f, err := strconv.ParseFloat(input, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected a number")
+		}
+		if err := checkRange(f, prop); err != nil {
+			return nil, err
+		}
+		v = f
+	case "boolean":
+		b, err := strconv.ParseBool(input)
+		if err != nil {
+			return nil, fmt.Errorf("expected true or false")
+		}
+		v = b
+	case "array":
+		if items := prop.Items; items != nil && !strings.HasPrefix(input, "[") {
+			itemType := schemaType(items)
+			if itemType == "object" || itemType == "array" {
+				return nil, fmt.Errorf("expected a JSON array")
+			}
+			var list []any
+			for i, item := range strings.Split(input, ",") {
+				iv, err := parseField(strings.TrimSpace(item), itemType, items)
+				if err != nil {
+					return nil, fmt.Errorf("item %d: %v", i+1, err)
+				}
+				list = append(list, iv)
+			}
+			v = list
+			break
+		}
+		fallthrough
+	case "object":
+		if err := json.Unmarshal([]byte(input), &v); err != nil {
+			return nil, fmt.Errorf("expected JSON %s: %v", typ, err)
+		}
+		if _, ok := v.(map[string]any); typ == "object" && !ok {
+			return nil, fmt.Errorf("expected a JSON object")
+		}
+		if _, ok := v.([]any); typ == "array" && !ok {
+			return nil, fmt.Errorf("expected a JSON array")
+		}
+	default:
+		if prop.MinLength != nil && len(input) < *prop.MinLength {
+			return nil, fmt.Errorf("must be at least %d characters", *prop.MinLength)
+		}
+		if prop.MaxLength != nil && len(input) > *prop.MaxLength {
+			return nil, fmt.Errorf("must be at most %d characters", *prop.MaxLength)
+		}
+		if prop.Pattern != "" {
+			if re, err := regexp.Compile(prop.Pattern); err == nil && !re.MatchString(input) {
+				return nil, fmt.Errorf("must match %s", prop.Pattern)
+			}
+		}
+		v = input
+	}
+
+	if len(prop.Enum) > 0 {
+		for _, e := range prop.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				return v, nil
+			}
+		}
+		return nil, fmt.Errorf("must be one of %v", prop.Enum)
+	}
+	return v, nil
+}
+
+func checkRange(n float64, prop *jsonschema.Schema) error {
+	if prop.Minimum != nil && n < *prop.Minimum {
+		return fmt.Errorf("must be >= %v", *prop.Minimum)
+	}
+	if prop.Maximum != nil && n > *prop.Maximum {
+		return fmt.Errorf("must be <= %v", *prop.Maximum)
+	}
+	return nil
+}
+
+// runToolForm prompts for a tool's arguments and calls it.
+func runToolForm(ctx context.Context, client *mcpclient.Client, name string) error {
+	args, err := promptToolArgs(ctx, client, name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n=== Calling %s ===\n", name)
+	result, err := callTool(ctx, client, name, args)
+	if err != nil {
+		return err
+	}
+
+	printResult(result)
+	return nil
+}
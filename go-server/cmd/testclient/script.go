@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"mcp-demo-server/pkg/mcpclient"
+)
+
+// scriptRun tracks a -script run: the outcome of the last command, which
+// expect lines check, and the tally for the summary.
+type scriptRun struct {
+	// lastErr is the error of the command at lastLine.
+	lastErr  error
+	lastLine string
+	// unchecked is set while lastErr has not been looked at by an
+	// "expect error"; the next command fails the script instead.
+	unchecked bool
+
+	commands, passed, failed int
+}
+
+// runScript runs the commands of a script file against one session and
+// returns false when a command or expectation failed. Lines are REPL
+// commands, run as typed; blank lines and lines starting with # are
+// skipped. The script may also use:
+//
+//	expect contains <text>           the last result contains text
+//	expect regex <regexp>            the last result matches regexp
+//	expect json <path> [op value]    the value at path is not null, or
+//	                                 compares with ==, !=, contains or ~
+//	expect error [text]              the last command failed
+//	capture <name> [json path]       set $name to the last result
+//
+// A failed expectation is reported and the script goes on; a command that
+// fails without an "expect error" after it stops the script.
+func runScript(config Config, path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Invalid -script: %v", err)
+	}
+	defer f.Close()
+
+	fmt.Printf("Connecting to %s...\n", config.target())
+	client, err := connectToServer(context.Background(), config)
+	if err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	run := &scriptRun{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if run.unchecked && !strings.HasPrefix(line, "expect ") {
+			break
+		}
+		if line == "quit" || line == "exit" || line == "q" {
+			break
+		}
+		fmt.Printf("\n[%s:%d] %s\n", path, n, line)
+		run.step(client, config, fmt.Sprintf("%s:%d", path, n), line)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Reading %s: %v", path, err)
+	}
+	if run.unchecked {
+		fmt.Printf("FAIL %s: stopped after an error no \"expect error\" checked: %v\n", run.lastLine, run.lastErr)
+		run.failed++
+	}
+
+	fmt.Printf("\n=== Script %s: %d commands, %d expectations passed, %d failed ===\n", path, run.commands, run.passed, run.failed)
+	return run.failed == 0
+}
+
+// step runs the script line at pos.
+func (r *scriptRun) step(client *mcpclient.Client, config Config, pos, line string) {
+	parts := strings.Fields(line)
+	if parts[0] == "expect" {
+		if err := r.expect(line); err != nil {
+			fmt.Printf("FAIL %s: %v\n", pos, err)
+			r.failed++
+		} else {
+			fmt.Printf("ok   %s\n", pos)
+			r.passed++
+		}
+		return
+	}
+
+	r.commands++
+	r.lastLine = pos
+	if parts[0] == "capture" {
+		r.lastErr = capture(parts)
+	} else {
+		lastResult = ""
+		ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+		r.lastErr = handleCommand(ctx, client, line)
+		cancel()
+	}
+	r.unchecked = r.lastErr != nil
+	if r.lastErr != nil {
+		fmt.Printf("Error: %v\n", r.lastErr)
+	}
+}
+
+// expect checks an expect line against the last command's outcome.
+func (r *scriptRun) expect(line string) error {
+	line, err := expandVars(line)
+	if err != nil {
+		return err
+	}
+	rest := strings.TrimSpace(strings.TrimPrefix(line, "expect"))
+	kind, arg, _ := strings.Cut(rest, " ")
+	arg = strings.TrimSpace(arg)
+
+	if kind == "error" {
+		r.unchecked = false
+		if r.lastErr == nil {
+			return fmt.Errorf("the command succeeded")
+		}
+		if !strings.Contains(r.lastErr.Error(), arg) {
+			return fmt.Errorf("error %q does not contain %q", r.lastErr, arg)
+		}
+		return nil
+	}
+	if r.lastErr != nil {
+		return fmt.Errorf("the command failed: %v", r.lastErr)
+	}
+
+	switch kind {
+	case "contains":
+		if !strings.Contains(lastResult, arg) {
+			return fmt.Errorf("result does not contain %q", arg)
+		}
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(lastResult) {
+			return fmt.Errorf("result does not match %s", arg)
+		}
+	case "json":
+		return expectJSON(arg)
+	default:
+		return fmt.Errorf("unknown expectation %q (want contains, regex, json or error)", kind)
+	}
+	return nil
+}
+
+// expectJSON checks "<path> [op value]" against the last result. value
+// is read as JSON when it parses, else as a string.
+func expectJSON(arg string) error {
+	path, cond, _ := strings.Cut(arg, " ")
+	op, want, _ := strings.Cut(strings.TrimSpace(cond), " ")
+	want = strings.TrimSpace(want)
+
+	got, err := resultValue(path)
+	if err != nil {
+		return err
+	}
+	gotText, _ := got.(string)
+	if gotText == "" && got != nil {
+		data, _ := json.Marshal(got)
+		gotText = string(data)
+	}
+
+	switch op {
+	case "":
+		if got == nil {
+			return fmt.Errorf("%s is null or missing", path)
+		}
+	case "==", "!=":
+		var wantValue any = want
+		if json.Unmarshal([]byte(want), &wantValue) != nil {
+			wantValue = want
+		}
+		if reflect.DeepEqual(got, wantValue) != (op == "==") {
+			return fmt.Errorf("%s is %s", path, gotText)
+		}
+	case "contains":
+		if !strings.Contains(gotText, want) {
+			return fmt.Errorf("%s is %s", path, gotText)
+		}
+	case "~":
+		re, err := regexp.Compile(want)
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(gotText) {
+			return fmt.Errorf("%s is %s", path, gotText)
+		}
+	default:
+		return fmt.Errorf("unknown operator %q (want ==, !=, contains or ~)", op)
+	}
+	return nil
+}
+
+// resultValue evaluates a json path against the last result. A path with
+// [] yields the array of the values it reaches.
+func resultValue(path string) (any, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeOutput(lastResult)
+	if err != nil {
+		return nil, err
+	}
+	values, err := evalJSONPath(doc, steps)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range steps {
+		if step.each {
+			return values, nil
+		}
+	}
+	return values[0], nil
+}
+
+// capture handles "capture <name> [json path]", setting a variable to the
+// last result or the value at path in it.
+func capture(parts []string) error {
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("usage: capture <name> [json path]")
+	}
+	value := lastResult
+	if len(parts) == 3 {
+		v, err := resultValue(parts[2])
+		if err != nil {
+			return err
+		}
+		if s, ok := v.(string); ok {
+			value = s
+		} else {
+			data, _ := json.Marshal(v)
+			value = string(data)
+		}
+	}
+	return handleSet([]string{"set", parts[1], value})
+}
@@ -0,0 +1,14 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import "errors"
+
+// isTerminal reports false: the REPL reads plain lines on this platform.
+func isTerminal(fd int) bool { return false }
+
+func termWidth(fd int) int { return 0 }
+
+func makeRaw(fd int) (restore func(), err error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRecordedBody caps the response body kept per exchange; long event
+// streams are cut there.
+const maxRecordedBody = 64 << 10
+
+// sessionIDHeader is the Streamable HTTP session header.
+const sessionIDHeader = "Mcp-Session-Id"
+
+// redactedHeaders carry credentials. -record keeps their names but not
+// their values, and the exports read the values from the environment.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// exchange is one HTTP request of a recorded session and its response,
+// a line of the -record file.
+type exchange struct {
+	Seq             int         `json:"seq"`
+	Time            time.Time   `json:"time"`
+	Method          string      `json:"method"`
+	URL             string      `json:"url"`
+	RequestHeaders  http.Header `json:"request_headers,omitempty"`
+	RequestBody     string      `json:"request_body,omitempty"`
+	Status          string      `json:"status,omitempty"`
+	ResponseHeaders http.Header `json:"response_headers,omitempty"`
+	ResponseBody    string      `json:"response_body,omitempty"`
+	Truncated       bool        `json:"truncated,omitempty"`
+	Error           string      `json:"error,omitempty"`
+}
+
+// transcript is the -record file. Exchanges are written when their
+// response body is closed, so the file is ordered by completion; seq
+// gives the order in which requests were sent.
+type transcript struct {
+	mu  sync.Mutex
+	f   *os.File
+	seq int
+}
+
+// recording is non-nil when -record is set.
+var recording *transcript
+
+func openTranscript(path string) (*transcript, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &transcript{f: f}, nil
+}
+
+func (t *transcript) nextSeq() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seq++
+	return t.seq
+}
+
+func (t *transcript) write(ex *exchange) {
+	data, err := json.Marshal(ex)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := t.f.Write(append(data, '\n')); err != nil {
+		log.Printf("Recording: %v", err)
+	}
+}
+
+// recorded returns c with its requests recorded, if -record is set.
+func recorded(c *http.Client) *http.Client {
+	if recording == nil {
+		return c
+	}
+	next := c.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &http.Client{Transport: &recordingTransport{t: recording, next: next}}
+}
+
+type recordingTransport struct {
+	t    *transcript
+	next http.RoundTripper
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ex := &exchange{
+		Seq:            rt.t.nextSeq(),
+		Time:           time.Now().UTC(),
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		RequestHeaders: redactHeaders(req.Header),
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		ex.RequestBody = string(data)
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(data))
+	}
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil {
+		ex.Error = err.Error()
+		rt.t.write(ex)
+		return nil, err
+	}
+	ex.Status = resp.Status
+	ex.ResponseHeaders = redactHeaders(resp.Header)
+	if resp.Body == http.NoBody {
+		// Nobody may ever close it: the SDK drops the answer to DELETE.
+		rt.t.write(ex)
+		return resp, nil
+	}
+	resp.Body = &recordedBody{ReadCloser: resp.Body, t: rt.t, ex: ex}
+	return resp, nil
+}
+
+func redactHeaders(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range redactedHeaders {
+		if _, ok := out[name]; ok {
+			out[name] = []string{"REDACTED"}
+		}
+	}
+	return out
+}
+
+// recordedBody keeps the start of a response body and writes the
+// exchange once the body is read to the end or closed.
+type recordedBody struct {
+	io.ReadCloser
+	t    *transcript
+	ex   *exchange
+	buf  bytes.Buffer
+	once sync.Once
+}
+
+func (b *recordedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := maxRecordedBody - b.buf.Len(); n > room {
+		b.buf.Write(p[:room])
+		b.ex.Truncated = true
+	} else {
+		b.buf.Write(p[:n])
+	}
+	if err != nil {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *recordedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *recordedBody) finish() {
+	b.once.Do(func() {
+		b.ex.ResponseBody = b.buf.String()
+		b.t.write(b.ex)
+	})
+}
+
+// loadTranscript reads a -record file in the order the requests were
+// sent.
+func loadTranscript(path string) ([]*exchange, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []*exchange
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64<<10), 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var ex exchange
+		if err := json.Unmarshal(scanner.Bytes(), &ex); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		out = append(out, &ex)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(out, func(a, b *exchange) int { return a.Seq - b.Seq })
+	return out, nil
+}
+
+// rpcLabel names the JSON-RPC message in a request body: its method, or
+// "response" for a reply to a server request.
+func rpcLabel(body string) string {
+	var msg struct {
+		Method string          `json:"method"`
+		ID     json.RawMessage `json:"id"`
+	}
+	switch {
+	case body == "":
+		return ""
+	case json.Unmarshal([]byte(body), &msg) != nil:
+		return "batch or non-JSON body"
+	case msg.Method != "":
+		return msg.Method
+	case msg.ID != nil:
+		return "response"
+	}
+	return ""
+}
+
+// exchangeLabel is what an export calls a request.
+func exchangeLabel(ex *exchange) string {
+	if ex.Method == http.MethodDelete {
+		return "end of session"
+	}
+	return rpcLabel(ex.RequestBody)
+}
+
+// isEventStream reports whether ex opened the standalone SSE stream,
+// which stays open for the whole session and is left out of exports.
+func isEventStream(ex *exchange) bool {
+	return ex.Method == http.MethodGet && strings.Contains(ex.RequestHeaders.Get("Accept"), "text/event-stream")
+}
+
+// transcriptExport turns a transcript into a script or request file. The
+// origin of the first request becomes a variable, the session ID is taken
+// from each initialize response, and redacted headers come from the
+// environment.
+type transcriptExport struct {
+	exchanges []*exchange
+	origin    string
+	out       strings.Builder
+}
+
+func newTranscriptExport(exchanges []*exchange) (*transcriptExport, error) {
+	if len(exchanges) == 0 {
+		return nil, fmt.Errorf("the transcript is empty")
+	}
+	u, err := url.Parse(exchanges[0].URL)
+	if err != nil {
+		return nil, err
+	}
+	return &transcriptExport{exchanges: exchanges, origin: u.Scheme + "://" + u.Host}, nil
+}
+
+// header returns a request header's templated value, or "" to leave it
+// out: the transport sets Content-Length and the like itself.
+func (e *transcriptExport) header(name, value, sessionID string) string {
+	switch {
+	case name == sessionIDHeader:
+		return sessionID
+	case slices.Contains(redactedHeaders, name):
+		return e.envHeader(name)
+	case name == "Content-Length" || name == "Accept-Encoding":
+		return ""
+	}
+	return value
+}
+
+// envHeader is the variable a redacted header's value comes from.
+func (e *transcriptExport) envHeader(name string) string {
+	return "MCP_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// path returns u relative to the origin, or "" when u is elsewhere.
+func (e *transcriptExport) path(u string) string {
+	if rest, ok := strings.CutPrefix(u, e.origin); ok && (rest == "" || strings.HasPrefix(rest, "/") || strings.HasPrefix(rest, "?")) {
+		return rest
+	}
+	return ""
+}
+
+// recordedLine opens both exports.
+func (e *transcriptExport) recordedLine() string {
+	first := e.exchanges[0]
+	return fmt.Sprintf("MCP session recorded by mcp-test-client %s at %s from %s.", version, first.Time.Format(time.RFC3339), e.origin)
+}
+
+// usedEnvHeaders lists the redacted headers the transcript sends.
+func (e *transcriptExport) usedEnvHeaders() []string {
+	var names []string
+	for _, ex := range e.exchanges {
+		for _, name := range redactedHeaders {
+			if _, ok := ex.RequestHeaders[name]; ok && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// curl writes a POSIX shell script of curl commands.
+func (e *transcriptExport) curl() string {
+	w := &e.out
+	fmt.Fprintf(w, "#!/bin/sh\n# %s\n", e.recordedLine())
+	w.WriteString("# Replays its requests with curl. Set MCP_ORIGIN to target another server;\n")
+	w.WriteString("# the session ID comes from each initialize response.\n")
+	w.WriteString("set -e\n")
+	fmt.Fprintf(w, "MCP_ORIGIN=\"${MCP_ORIGIN:-%s}\"\n", e.origin)
+	for _, name := range e.usedEnvHeaders() {
+		v := e.envHeader(name)
+		fmt.Fprintf(w, ": \"${%s:?set %s to the %s header value}\"\n", v, v, name)
+	}
+	w.WriteString("HEADERS=$(mktemp)\ntrap 'rm -f \"$HEADERS\"' EXIT\n")
+	for i, ex := range e.exchanges {
+		label := exchangeLabel(ex)
+		fmt.Fprintf(w, "\n# %d. %s %s", i+1, ex.Method, orDash(label))
+		if isEventStream(ex) {
+			w.WriteString(" (event stream, left out)\n")
+			continue
+		}
+		fmt.Fprintf(w, " (recorded: %s)\n", recordedOutcome(ex))
+		target := shellQuote(ex.URL)
+		if p := e.path(ex.URL); p != "" || ex.URL == e.origin {
+			target = "\"$MCP_ORIGIN\"" + shellQuote(p)
+		}
+		initialize := label == "initialize"
+		w.WriteString("curl -sS")
+		if initialize {
+			w.WriteString(" -D \"$HEADERS\"")
+		}
+		fmt.Fprintf(w, " -X %s %s", ex.Method, target)
+		for _, name := range sortedKeys(ex.RequestHeaders) {
+			for _, value := range ex.RequestHeaders[name] {
+				switch v := e.header(name, value, "$MCP_SESSION_ID"); {
+				case v == "":
+				case name == sessionIDHeader:
+					fmt.Fprintf(w, " \\\n  -H \"%s: %s\"", name, v)
+				case slices.Contains(redactedHeaders, name):
+					fmt.Fprintf(w, " \\\n  -H \"%s: $%s\"", name, v)
+				default:
+					fmt.Fprintf(w, " \\\n  -H %s", shellQuote(name+": "+v))
+				}
+			}
+		}
+		if ex.RequestBody != "" {
+			fmt.Fprintf(w, " \\\n  --data-raw %s", shellQuote(ex.RequestBody))
+		}
+		w.WriteString("\n")
+		if initialize {
+			w.WriteString("MCP_SESSION_ID=$(awk 'tolower($1) == \"mcp-session-id:\" { print $2 }' \"$HEADERS\" | tr -d '\\r')\n")
+		}
+		w.WriteString("echo\n")
+	}
+	return w.String()
+}
+
+// http writes a request file for the REST Client extension of VS Code,
+// whose named requests let later ones use the session ID of a response.
+func (e *transcriptExport) http() string {
+	w := &e.out
+	fmt.Fprintf(w, "# %s\n", e.recordedLine())
+	w.WriteString("# For the REST Client extension of VS Code: send the requests in order.\n")
+	w.WriteString("# Change @origin to target another server; the session ID comes from each\n")
+	w.WriteString("# initialize response, and credentials from the environment.\n")
+	fmt.Fprintf(w, "@origin = %s\n", e.origin)
+	sessionID := ""
+	inits := 0
+	for i, ex := range e.exchanges {
+		label := exchangeLabel(ex)
+		fmt.Fprintf(w, "\n### %d. %s %s", i+1, ex.Method, orDash(label))
+		if isEventStream(ex) {
+			w.WriteString(" (event stream, left out)\n")
+			continue
+		}
+		fmt.Fprintf(w, " (recorded: %s)\n", recordedOutcome(ex))
+		name := ""
+		if label == "initialize" {
+			inits++
+			name = fmt.Sprintf("initialize%d", inits)
+			fmt.Fprintf(w, "# @name %s\n", name)
+		}
+		target := ex.URL
+		if p := e.path(ex.URL); p != "" || ex.URL == e.origin {
+			target = "{{origin}}" + p
+		}
+		fmt.Fprintf(w, "%s %s\n", ex.Method, target)
+		for _, hname := range sortedKeys(ex.RequestHeaders) {
+			for _, value := range ex.RequestHeaders[hname] {
+				switch v := e.header(hname, value, sessionID); {
+				case v == "":
+				case slices.Contains(redactedHeaders, hname):
+					fmt.Fprintf(w, "%s: {{$processEnv %s}}\n", hname, v)
+				default:
+					fmt.Fprintf(w, "%s: %s\n", hname, v)
+				}
+			}
+		}
+		if ex.RequestBody != "" {
+			fmt.Fprintf(w, "\n%s\n", ex.RequestBody)
+		}
+		if name != "" {
+			sessionID = "{{" + name + ".response.headers." + sessionIDHeader + "}}"
+		}
+	}
+	return w.String()
+}
+
+func recordedOutcome(ex *exchange) string {
+	if ex.Error != "" {
+		return "error: " + ex.Error
+	}
+	return ex.Status
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// runExportTranscript implements `testclient export-transcript`: it
+// converts a -record file to curl commands or a .http file.
+func runExportTranscript(args []string) {
+	fs := flag.NewFlagSet("export-transcript", flag.ExitOnError)
+	format := fs.String("format", "curl", "Output format: curl (a shell script) or http (a .http file for VS Code's REST Client)")
+	output := fs.String("o", "", "Write to this file (default: standard output)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: testclient export-transcript [-format curl|http] [-o file] transcript.jsonl")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	exchanges, err := loadTranscript(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("Reading transcript: %v", err)
+	}
+	export, err := newTranscriptExport(exchanges)
+	if err != nil {
+		log.Fatalf("Export failed: %v", err)
+	}
+	var text string
+	switch *format {
+	case "curl":
+		text = export.curl()
+	case "http":
+		text = export.http()
+	default:
+		log.Fatalf("Invalid -format %q (want curl or http)", *format)
+	}
+	if *output == "" {
+		fmt.Print(text)
+		return
+	}
+	mode := os.FileMode(0o644)
+	if *format == "curl" {
+		mode = 0o755
+	}
+	if err := os.WriteFile(*output, []byte(text), mode); err != nil {
+		log.Fatalf("Export failed: %v", err)
+	}
+	fmt.Printf("Wrote %d requests to %s\n", len(exchanges), *output)
+}
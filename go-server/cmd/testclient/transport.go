@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Values of -transport.
+const (
+	transportAuto       = "auto"
+	transportStreamable = "streamable"
+	transportSSE        = "sse"
+)
+
+// probeRequest is the initialize request auto-detection sends: a
+// Streamable HTTP endpoint accepts it, while an SSE server's GET endpoint
+// does not take POSTs at all.
+const probeRequest = `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"mcp-test-client-probe","version":"` + version + `"}}}`
+
+// detected remembers what -transport auto found, so that reconnects do
+// not probe again.
+var detected struct {
+	sync.Mutex
+	kind string
+}
+
+// newTransport returns the transport connectToServer should use, or nil
+// for mcpclient's default, which picks Streamable HTTP or WebSocket from
+// the URL scheme.
+func newTransport(ctx context.Context, config Config, httpClient *http.Client) (mcp.Transport, error) {
+	if config.Command != "" {
+		args := strings.Fields(config.Command)
+		cmd := exec.Command(args[0], args[1:]...)
+		// The server's log goes to our stderr, where a failed start shows.
+		cmd.Stderr = os.Stderr
+		return &mcp.CommandTransport{Command: cmd}, nil
+	}
+	if u, err := url.Parse(config.ServerURL); err == nil && (u.Scheme == "ws" || u.Scheme == "wss") {
+		if config.Transport == transportSSE {
+			return nil, fmt.Errorf("-transport sse needs an http:// or https:// -url")
+		}
+		return nil, nil
+	}
+
+	kind := config.Transport
+	if kind == transportAuto {
+		var err error
+		if kind, err = detectTransport(ctx, httpClient, config.ServerURL); err != nil {
+			return nil, err
+		}
+	}
+	if kind == transportSSE {
+		return &mcp.SSEClientTransport{Endpoint: config.ServerURL, HTTPClient: httpClient}, nil
+	}
+	return nil, nil
+}
+
+// detectTransport probes endpoint, once per run, the way the MCP spec
+// suggests for backwards compatibility: POST an initialize request, and
+// fall back to an SSE GET when that is refused with 400, 404 or 405. The
+// session a Streamable HTTP server opens for the probe is deleted again.
+func detectTransport(ctx context.Context, httpClient *http.Client, endpoint string) (string, error) {
+	detected.Lock()
+	defer detected.Unlock()
+	if detected.kind != "" {
+		return detected.kind, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(probeRequest))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("probing transport: %w", err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusMethodNotAllowed:
+		if !servesSSE(ctx, httpClient, endpoint) {
+			return "", fmt.Errorf("probing transport: %s answered POST with %s and GET without an event stream; is -url the MCP endpoint?", endpoint, resp.Status)
+		}
+		detected.kind = transportSSE
+	case http.StatusUnauthorized, http.StatusForbidden:
+		challenge := ""
+		if c := resp.Header.Get("WWW-Authenticate"); c != "" {
+			challenge = " (" + c + ")"
+		}
+		return "", fmt.Errorf("probing transport: %s answered %s%s; pass credentials with -bearer-token, -basic-auth or -header", endpoint, resp.Status, challenge)
+	default:
+		// Anything else is left for the Streamable HTTP connection to
+		// report.
+		if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+			deleteSession(ctx, httpClient, endpoint, id)
+		}
+		detected.kind = transportStreamable
+	}
+	fmt.Fprintf(statusOut, "Detected %s transport at %s\n", detected.kind, endpoint)
+	return detected.kind, nil
+}
+
+// servesSSE reports whether a GET of endpoint opens an event stream.
+func servesSSE(ctx context.Context, httpClient *http.Client, endpoint string) bool {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false
+	}
+	// The stream stays open; cancelling ctx ends it once the headers are
+	// read.
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK && strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+}
+
+// deleteSession ends the session the probe opened; failure only leaves it
+// to the server's idle timeout.
+func deleteSession(ctx context.Context, httpClient *http.Client, endpoint, id string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, endpoint, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Mcp-Session-Id", id)
+	if resp, err := httpClient.Do(req); err == nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"mcp-demo-server/pkg/mcpclient"
+)
+
+// vars holds REPL variables defined with `set`, referenced as $name or
+// ${name} in commands and argument templates.
+var vars = map[string]string{}
+
+// expandVars substitutes $name and ${name} references. "$$" yields a
+// literal dollar sign; an undefined variable is an error rather than an
+// empty string, so typos don't silently produce wrong URLs.
+func expandVars(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		if s[i+1] == '$' {
+			b.WriteByte('$')
+			i++
+			continue
+		}
+
+		var name string
+		if s[i+1] == '{' {
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${ in %q", s)
+			}
+			name = s[i+2 : i+2+end]
+			i += 2 + end
+		} else {
+			j := i + 1
+			for j < len(s) && isVarChar(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				b.WriteByte('$')
+				continue
+			}
+			name = s[i+1 : j]
+			i = j - 1
+		}
+
+		value, ok := vars[name]
+		if !ok {
+			return "", fmt.Errorf("undefined variable $%s (use 'set %s <value>')", name, name)
+		}
+		b.WriteString(value)
+	}
+	return b.String(), nil
+}
+
+func isVarChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// handleSet implements `set [name [value...]]`.
+func handleSet(parts []string) error {
+	switch len(parts) {
+	case 1:
+		if len(vars) == 0 {
+			fmt.Println("No variables set")
+			return nil
+		}
+		names := make([]string, 0, len(vars))
+		for name := range vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%s = %s\n", name, vars[name])
+		}
+		return nil
+	case 2:
+		return fmt.Errorf("usage: set <name> <value>")
+	}
+	name := parts[1]
+	for i := 0; i < len(name); i++ {
+		if !isVarChar(name[i]) {
+			return fmt.Errorf("invalid variable name %q", name)
+		}
+	}
+	vars[name] = strings.Join(parts[2:], " ")
+	return nil
+}
+
+// argTemplate is the file format read by the `template` command.
+type argTemplate struct {
+	Tool      string         `json:"tool"`
+	Arguments map[string]any `json:"arguments"`
+}
+
+// loadTemplate reads an argument template and expands variables in every
+// string value.
+func loadTemplate(path string) (*argTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var t argTemplate
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if t.Tool == "" {
+		return nil, fmt.Errorf("%s: missing \"tool\"", path)
+	}
+	expanded, err := expandValue(t.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	t.Arguments, _ = expanded.(map[string]any)
+	return &t, nil
+}
+
+func expandValue(v any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		return expandVars(v)
+	case map[string]any:
+		for k, item := range v {
+			x, err := expandValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = x
+		}
+		return v, nil
+	case []any:
+		for i, item := range v {
+			x, err := expandValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = x
+		}
+		return v, nil
+	}
+	return v, nil
+}
+
+// runTemplate calls the tool named in a template file.
+func runTemplate(ctx context.Context, client *mcpclient.Client, path string) error {
+	t, err := loadTemplate(path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n=== Calling %s ===\n", t.Tool)
+	result, err := callTool(ctx, client, t.Tool, t.Arguments)
+	if err != nil {
+		return err
+	}
+
+	printResult(result)
+	return nil
+}
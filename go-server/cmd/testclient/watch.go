@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"mcp-demo-server/pkg/mcpclient"
+)
+
+// watchLevel is the log level the server is asked for while watching.
+const watchLevel = "debug"
+
+// watching is the state of the event viewer that the watch command turns
+// on: while on, every server notification is printed as it arrives.
+var watching struct {
+	sync.Mutex
+	on   bool
+	uris []string // subscribed resources
+	// logLevel is -log-level, which unwatch sets back.
+	logLevel string
+}
+
+func watchOn() bool {
+	watching.Lock()
+	defer watching.Unlock()
+	return watching.on
+}
+
+// printEvent prints a notification with the time it arrived.
+func printEvent(kind, format string, args ...any) {
+	fmt.Printf("\n[%s] %-9s %s\n", time.Now().Format("15:04:05.000"), kind, fmt.Sprintf(format, args...))
+}
+
+// eventHandlers adds the event viewer to handlers. Log messages are
+// still printed without it when -log-level is set.
+func eventHandlers(handlers mcpclient.Handlers, logLevel string) mcpclient.Handlers {
+	watching.Lock()
+	watching.logLevel = logLevel
+	watching.Unlock()
+	handlers.Log = func(client *mcpclient.Client, params *mcp.LoggingMessageParams) {
+		switch {
+		case watchOn():
+			data, _ := json.Marshal(params.Data)
+			if fields, ok := params.Data.(map[string]any); ok {
+				data, _ = json.MarshalIndent(fields, "          ", "  ")
+			}
+			printEvent("log", "%s %s %s", params.Level, params.Logger, data)
+		case logLevel != "":
+			printLogMessage(client, params)
+		}
+	}
+	handlers.Progress = func(_ *mcpclient.Client, params *mcp.ProgressNotificationParams) {
+		if !watchOn() {
+			return
+		}
+		progress := fmt.Sprintf("%g", params.Progress)
+		if params.Total > 0 {
+			progress += fmt.Sprintf("/%g (%.0f%%)", params.Total, 100*params.Progress/params.Total)
+		}
+		if params.Message != "" {
+			progress += " " + params.Message
+		}
+		printEvent("progress", "%v %s", params.ProgressToken, progress)
+	}
+	handlers.ResourceUpdated = func(_ *mcpclient.Client, params *mcp.ResourceUpdatedNotificationParams) {
+		if watchOn() {
+			printEvent("updated", "%s", params.URI)
+		}
+	}
+	return handlers
+}
+
+// runWatch handles "watch [uri ...]": it turns the event viewer on, asks
+// the server for log messages down to debug and subscribes to the given
+// resources. Events print between commands, so a call can be watched as
+// it runs.
+func runWatch(ctx context.Context, client *mcpclient.Client, parts []string) error {
+	if err := client.Session().SetLoggingLevel(ctx, &mcp.SetLoggingLevelParams{Level: watchLevel}); err != nil {
+		return fmt.Errorf("logging/setLevel: %w", err)
+	}
+	for _, uri := range parts[1:] {
+		if err := client.Session().Subscribe(ctx, &mcp.SubscribeParams{URI: uri}); err != nil {
+			return fmt.Errorf("resources/subscribe %s: %w", uri, err)
+		}
+		watching.Lock()
+		if !slices.Contains(watching.uris, uri) {
+			watching.uris = append(watching.uris, uri)
+		}
+		watching.Unlock()
+	}
+
+	watching.Lock()
+	watching.on = true
+	uris := strings.Join(watching.uris, ", ")
+	watching.Unlock()
+	fmt.Println("Watching log messages, progress, list changes and resource updates; 'unwatch' stops.")
+	if uris != "" {
+		fmt.Printf("Subscribed to %s\n", uris)
+	}
+	return nil
+}
+
+// runUnwatch handles "unwatch": it turns the event viewer off and
+// unsubscribes from its resources. The server's log level is set back to
+// -log-level, if given; otherwise its messages are just no longer shown.
+func runUnwatch(ctx context.Context, client *mcpclient.Client) error {
+	watching.Lock()
+	uris, logLevel := watching.uris, watching.logLevel
+	watching.on, watching.uris = false, nil
+	watching.Unlock()
+
+	for _, uri := range uris {
+		if err := client.Session().Unsubscribe(ctx, &mcp.UnsubscribeParams{URI: uri}); err != nil {
+			return fmt.Errorf("resources/unsubscribe %s: %w", uri, err)
+		}
+	}
+	if logLevel != "" {
+		if err := client.Session().SetLoggingLevel(ctx, &mcp.SetLoggingLevelParams{Level: mcp.LoggingLevel(logLevel)}); err != nil {
+			return fmt.Errorf("logging/setLevel: %w", err)
+		}
+	}
+	fmt.Println("Stopped watching.")
+	return nil
+}
+
+// rewatch restores the event viewer on a new session after a reconnect.
+func rewatch(ctx context.Context, client *mcpclient.Client) error {
+	watching.Lock()
+	on, uris := watching.on, slices.Clone(watching.uris)
+	watching.Unlock()
+	if !on {
+		return nil
+	}
+	return runWatch(ctx, client, append([]string{"watch"}, uris...))
+}
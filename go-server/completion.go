@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Completions (completion/complete) ---------- */
+
+const (
+	// maxCompletions is the most values the protocol allows per answer;
+	// the rest are reported through total and hasMore.
+	maxCompletions = 100
+	// maxRecentURLs is how many URLs are remembered per session.
+	maxRecentURLs = 20
+	// maxURLSessions bounds the sessions URLs are remembered for.
+	maxURLSessions = 1024
+)
+
+// exampleURLs are suggested for url arguments before the session has
+// fetched anything.
+var exampleURLs = []string{
+	"https://example.com/",
+	"https://httpbin.org/get",
+	"https://httpbin.org/json",
+	"https://api.github.com/zen",
+	"https://ifconfig.co/json",
+}
+
+var httpMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// addCompletionPrompts registers prompts whose arguments mirror those of
+// the timeserver and fetch tools, so that clients can try completions on
+// them. Completion applies to prompt and resource template arguments
+// only; tool arguments have no completion in the protocol.
+func addCompletionPrompts(server *mcp.Server) {
+	server.AddPrompt(&mcp.Prompt{
+		Name:        "current_time",
+		Title:       "Current time",
+		Description: "Ask for the current time in a timezone; timezone and format support completion",
+		Arguments: []*mcp.PromptArgument{
+			{Name: "timezone", Description: "IANA timezone, e.g. Europe/Kyiv", Required: true},
+			{Name: "format", Description: "A timeserver format preset such as rfc1123 or kitchen"},
+		},
+	}, func(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		tz, format := req.Params.Arguments["timezone"], req.Params.Arguments["format"]
+		if tz == "" {
+			return nil, fmt.Errorf("timezone is required")
+		}
+		if err := validateTimezone(tz); err != nil {
+			return nil, err
+		}
+		text := fmt.Sprintf("What time is it in %s? Use the timeserver tool with timezone %q", tz, tz)
+		if format != "" {
+			text += fmt.Sprintf(" and format %q", format)
+		}
+		return completionPromptResult("Current time in "+tz, text+"."), nil
+	})
+
+	server.AddPrompt(&mcp.Prompt{
+		Name:        "fetch_url",
+		Title:       "Fetch a URL",
+		Description: "Ask to fetch and summarize a URL; url suggests the session's recent URLs, method the HTTP methods",
+		Arguments: []*mcp.PromptArgument{
+			{Name: "url", Description: "HTTP or HTTPS URL", Required: true},
+			{Name: "method", Description: "HTTP method (default GET)"},
+		},
+	}, func(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		target, method := req.Params.Arguments["url"], strings.ToUpper(req.Params.Arguments["method"])
+		if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("url must be an absolute http or https URL, got %q", target)
+		}
+		if method == "" {
+			method = "GET"
+		}
+		text := fmt.Sprintf("Use the fetch tool to send a %s request to %s and summarize the response.", method, target)
+		return completionPromptResult("Fetch "+target, text), nil
+	})
+}
+
+func completionPromptResult(description, text string) *mcp.GetPromptResult {
+	return &mcp.GetPromptResult{
+		Description: description,
+		Messages:    []*mcp.PromptMessage{{Role: "user", Content: &mcp.TextContent{Text: text}}},
+	}
+}
+
+// complete answers completion/complete. Unknown references and arguments
+// get no suggestions rather than an error, as the protocol recommends.
+func complete(ctx context.Context, req *mcp.CompleteRequest) (*mcp.CompleteResult, error) {
+	ref, arg := req.Params.Ref, req.Params.Argument
+	var resolved map[string]string
+	if req.Params.Context != nil {
+		resolved = req.Params.Context.Arguments
+	}
+	var values []string
+	switch {
+	case ref.Type == "ref/prompt" && ref.Name == "current_time" && arg.Name == "timezone":
+		values = completeTimezone(req.Session, arg.Value)
+	case ref.Type == "ref/prompt" && ref.Name == "current_time" && arg.Name == "format":
+		values = matchPrefix(sortedKeys(timeFormats), arg.Value)
+	case ref.Type == "ref/prompt" && ref.Name == "fetch_url" && arg.Name == "url":
+		values = completeURL(req.Session, arg.Value)
+	case ref.Type == "ref/prompt" && ref.Name == "fetch_url" && arg.Name == "method":
+		values = matchPrefix(httpMethods, strings.ToUpper(arg.Value))
+	case ref.Type == "ref/resource" && ref.URI == artifactURITemplate:
+		values = artifacts.complete(arg.Name, arg.Value, resolved["kind"])
+	case ref.Type == "ref/resource" && ref.URI == sandboxURITemplate && arg.Name == "path":
+		values = completeSandboxPath(ctx, req.Session, arg.Value)
+	}
+
+	res := &mcp.CompleteResult{Completion: mcp.CompletionResultDetails{Values: []string{}}}
+	if values != nil {
+		res.Completion.Values = values
+	}
+	if len(values) > maxCompletions {
+		res.Completion.Values = values[:maxCompletions]
+		res.Completion.Total = len(values)
+		res.Completion.HasMore = true
+	}
+	return res, nil
+}
+
+// matchPrefix returns the values that start with prefix, ignoring case.
+func matchPrefix(values []string, prefix string) []string {
+	prefix = strings.ToLower(prefix)
+	var out []string
+	for _, v := range values {
+		if strings.HasPrefix(strings.ToLower(v), prefix) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// completeTimezone suggests the session's default timezone first, then
+// names starting with value, then names containing it, so that "kyiv"
+// and "new york" find Europe/Kyiv and America/New_York.
+func completeTimezone(session *mcp.ServerSession, value string) []string {
+	names, err := timezoneNames()
+	if err != nil {
+		return nil
+	}
+	query := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(value), " ", "_"))
+	var first, prefixed, contained []string
+	if tz := defaults.lookup(session).Timezone; tz != "" && strings.Contains(strings.ToLower(tz), query) {
+		first = append(first, tz)
+	}
+	for _, name := range names {
+		lower := strings.ToLower(name)
+		switch {
+		case slices.Contains(first, name):
+		case strings.HasPrefix(lower, query):
+			prefixed = append(prefixed, name)
+		case strings.Contains(lower, query):
+			contained = append(contained, name)
+		}
+	}
+	return slices.Concat(first, prefixed, contained)
+}
+
+// completeURL suggests the URLs the session has passed to tools, most
+// recent first, then example URLs: the allowed hosts in -public-demo
+// mode.
+func completeURL(session *mcp.ServerSession, value string) []string {
+	var candidates []string
+	if session != nil {
+		candidates = recentURLs.list(session.ID())
+	}
+	if publicDemo != nil {
+		for _, host := range publicDemo.Hosts {
+			candidates = append(candidates, "https://"+host+"/")
+		}
+	} else {
+		candidates = append(candidates, exampleURLs...)
+	}
+	var out []string
+	for _, c := range matchPrefix(candidates, value) {
+		if !slices.Contains(out, c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// complete suggests artifact kinds, or the IDs of the artifacts of kind.
+func (s *artifactStore) complete(arg, value, kind string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for i := len(s.order) - 1; i >= 0; i-- {
+		k, id, _ := strings.Cut(strings.TrimPrefix(s.order[i], "artifact://"), "/")
+		switch {
+		case arg == "kind" && !slices.Contains(out, k):
+			out = append(out, k)
+		case arg == "id" && (kind == "" || kind == k):
+			out = append(out, id)
+		}
+	}
+	return matchPrefix(out, value)
+}
+
+// completeSandboxPath suggests the entries of the directory value names,
+// directories with a trailing slash, limited to the client's roots.
+func completeSandboxPath(ctx context.Context, session *mcp.ServerSession, value string) []string {
+	if fsSandbox == nil {
+		return nil
+	}
+	dir, base := path.Split(value)
+	if slices.Contains(strings.Split(dir, "/"), "..") {
+		return nil
+	}
+	abs, err := fsSandbox.resolve(dir)
+	if err != nil {
+		return nil
+	}
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), base) {
+			continue
+		}
+		if fsSandbox.checkRoots(ctx, session, filepath.Join(abs, e.Name())) != nil {
+			continue
+		}
+		name := dir + e.Name()
+		if e.IsDir() {
+			name += "/"
+		}
+		out = append(out, name)
+	}
+	return out
+}
+
+// urlHistory remembers, per session, the URLs passed to tools in their
+// url argument, for completion.
+type urlHistory struct {
+	mu       sync.Mutex
+	sessions map[string][]string // most recent first
+}
+
+var recentURLs = &urlHistory{sessions: make(map[string][]string)}
+
+func (h *urlHistory) add(sessionID, u string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	urls, ok := h.sessions[sessionID]
+	if !ok && len(h.sessions) >= maxURLSessions {
+		// Sessions end without telling us; start over rather than grow.
+		clear(h.sessions)
+	}
+	urls = slices.DeleteFunc(urls, func(v string) bool { return v == u })
+	urls = append([]string{u}, urls...)
+	if len(urls) > maxRecentURLs {
+		urls = urls[:maxRecentURLs]
+	}
+	h.sessions[sessionID] = urls
+}
+
+func (h *urlHistory) list(sessionID string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return slices.Clone(h.sessions[sessionID])
+}
+
+// retain drops the history of sessions not in live, returning how many.
+func (h *urlHistory) retain(live map[string]bool) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for id := range h.sessions {
+		if !live[id] {
+			delete(h.sessions, id)
+			n++
+		}
+	}
+	return n
+}
+
+// urlHistoryToolMiddleware records the url argument of successful calls.
+func urlHistoryToolMiddleware(tool *mcp.Tool, next ToolFunc) ToolFunc {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, any, error) {
+		res, out, err := next(ctx, req)
+		if err != nil || res == nil || res.IsError || req.Session == nil {
+			return res, out, err
+		}
+		var args struct {
+			URL string `json:"url"`
+		}
+		if json.Unmarshal(req.Params.Arguments, &args) == nil && args.URL != "" {
+			recentURLs.add(req.Session.ID(), args.URL)
+		}
+		return res, out, err
+	}
+}
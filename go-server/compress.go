@@ -0,0 +1,182 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+/* ---------- Response compression ---------- */
+
+// Content-Encodings -compression may list.
+const (
+	encodingZstd = "zstd"
+	encodingGzip = "gzip"
+)
+
+// compression compresses HTTP responses, including the SSE streams of
+// /mcp, with the first of Encodings the client accepts. Every flush of
+// the handler flushes the compressor too, so each SSE event still reaches
+// the client as soon as it is written.
+type compression struct {
+	Encodings []string // in order of preference
+}
+
+// parseCompression validates -compression.
+func parseCompression(s string) (*compression, error) {
+	c := &compression{}
+	for _, e := range strings.Split(s, ",") {
+		switch e = strings.ToLower(strings.TrimSpace(e)); e {
+		case "":
+		case encodingZstd, encodingGzip:
+			c.Encodings = append(c.Encodings, e)
+		default:
+			return nil, fmt.Errorf("unknown encoding %q (want zstd or gzip)", e)
+		}
+	}
+	if len(c.Encodings) == 0 {
+		return nil, nil
+	}
+	return c, nil
+}
+
+// negotiate picks the encoding for an Accept-Encoding header, or "".
+func (c *compression) negotiate(accept string) string {
+	q := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = f
+			}
+		}
+		if name != "" {
+			q[name] = weight
+		}
+	}
+	for _, e := range c.Encodings {
+		w, ok := q[e]
+		if !ok {
+			w, ok = q["*"]
+		}
+		if ok && w > 0 {
+			return e
+		}
+	}
+	return ""
+}
+
+// wrap compresses next's responses. WebSocket upgrades and HEAD requests
+// pass through untouched.
+func (c *compression) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		enc := c.negotiate(r.Header.Get("Accept-Encoding"))
+		if enc == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: enc}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// Encoders are reused: a zstd encoder in particular is costly to set up.
+var (
+	gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+	zstdWriters = sync.Pool{New: func() any {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(1<<20))
+		return w
+	}}
+)
+
+// compressEncoder is what gzip.Writer and zstd.Encoder have in common.
+type compressEncoder interface {
+	io.Writer
+	Flush() error
+	Close() error
+}
+
+// compressWriter compresses the body once the status and headers show the
+// response can be: not already encoded, and with a body.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	enc         compressEncoder
+	wroteHeader bool
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	h := cw.Header()
+	if code >= http.StatusOK && code != http.StatusNoContent && code != http.StatusNotModified && h.Get("Content-Encoding") == "" {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		switch cw.encoding {
+		case encodingZstd:
+			z := zstdWriters.Get().(*zstd.Encoder)
+			z.Reset(cw.ResponseWriter)
+			cw.enc = z
+		case encodingGzip:
+			g := gzipWriters.Get().(*gzip.Writer)
+			g.Reset(cw.ResponseWriter)
+			cw.enc = g
+		}
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.enc == nil {
+		return cw.ResponseWriter.Write(b)
+	}
+	return cw.enc.Write(b)
+}
+
+// Flush implements http.Flusher: what has been compressed so far is sent
+// at once, as SSE needs.
+func (cw *compressWriter) Flush() {
+	if cw.enc != nil {
+		cw.enc.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close ends the compressed stream and returns the encoder to its pool.
+func (cw *compressWriter) close() {
+	if cw.enc == nil {
+		return
+	}
+	cw.enc.Close()
+	switch enc := cw.enc.(type) {
+	case *zstd.Encoder:
+		enc.Reset(nil)
+		zstdWriters.Put(enc)
+	case *gzip.Writer:
+		enc.Reset(io.Discard)
+		gzipWriters.Put(enc)
+	}
+	cw.enc = nil
+}
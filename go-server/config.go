@@ -0,0 +1,559 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+/* ---------- Config file ---------- */
+
+// serverConfig is the JSON file given with -config. Command-line flags
+// override the settings it holds. Everything in it can be reloaded while
+// the server runs (see reload.go).
+//
+// The file is read strictly: unknown fields are errors, reported with
+// their line and column like every other problem. It may hold // and
+// /* */ comments, as the starter written by "config init" does, and
+// ${VAR} references to the environment (see configenv.go).
+type serverConfig struct {
+	// Schema lets editors find the schema; the server ignores it.
+	Schema string `json:"$schema,omitempty" jsonschema:"URL or path of this file's JSON Schema (see -print-config-schema), for editors; ignored by the server"`
+	configSettings
+	// Profiles override the settings above for one deployment, chosen
+	// with -profile.
+	Profiles map[string]configSettings `json:"profiles,omitempty" jsonschema:"Named overrides of the settings above (e.g. dev, staging, prod), selected with -profile or MCP_PROFILE"`
+}
+
+// configSettings are the settings of the file's top level and of each
+// profile.
+type configSettings struct {
+	// LogLevel is debug, info or warn.
+	LogLevel   string           `json:"log_level,omitempty" jsonschema:"Server log level: debug (adds a line per tool call), info (adds every HTTP request) or warn"`
+	Fetch      fetchConfig      `json:"fetch,omitempty" jsonschema:"Limits of the fetch tool; ignored in -public-demo mode"`
+	PublicDemo publicDemoTuning `json:"public_demo,omitempty" jsonschema:"Settings of -public-demo mode that may change at runtime"`
+	Tools      toolsConfig      `json:"tools,omitempty" jsonschema:"Which tools are registered"`
+	// SLOs maps tool names, or "*" for the tools not listed, to their
+	// objectives.
+	SLOs map[string]sloConfig `json:"slos,omitempty" jsonschema:"Service level objectives by tool name; * applies to tools not listed"`
+}
+
+// fetchConfig tunes the fetch tool. Ignored in -public-demo mode, which
+// has fixed limits.
+type fetchConfig struct {
+	// MaxBytes is the largest max_bytes a call may ask for.
+	MaxBytes int `json:"max_bytes,omitempty" jsonschema:"Largest max_bytes a fetch call may ask for"`
+	// DefaultBytes is the max_bytes of calls that give none.
+	DefaultBytes   int      `json:"default_bytes,omitempty" jsonschema:"Response bytes returned when a fetch call gives no max_bytes (capped at max_bytes)"`
+	AllowedHeaders []string `json:"allowed_headers,omitempty" jsonschema:"Request headers fetch callers may set"`
+	// MaxBinaryBytes is the largest binary body returned as a content
+	// block.
+	MaxBinaryBytes int `json:"max_binary_bytes,omitempty" jsonschema:"Largest binary body (image, audio, PDF...) a fetch call returns as a content block"`
+}
+
+// publicDemoTuning holds the -public-demo settings that may change at
+// runtime.
+type publicDemoTuning struct {
+	RatePerMinute float64 `json:"rate_per_minute,omitempty" jsonschema:"Requests per minute per client address"`
+}
+
+// toolsConfig selects which tools are registered.
+type toolsConfig struct {
+	// Enable, when non-empty, registers only these tools.
+	Enable []string `json:"enable,omitempty" jsonschema:"When not empty, register only these tools"`
+	// Disable leaves these tools out.
+	Disable []string `json:"disable,omitempty" jsonschema:"Tools not to register"`
+}
+
+// sloConfig sets a tool's service level objectives. Either target may be
+// left out.
+type sloConfig struct {
+	// SuccessRate is the share of calls that must succeed.
+	SuccessRate float64 `json:"success_rate,omitempty" jsonschema:"Share of calls that must succeed, e.g. 0.99; calls the client cancels are not counted"`
+	// LatencyMs and LatencyTarget: LatencyTarget of the calls must finish
+	// within LatencyMs.
+	LatencyMs     int     `json:"latency_ms,omitempty" jsonschema:"Latency objective: calls must finish within this many milliseconds"`
+	LatencyTarget float64 `json:"latency_target,omitempty" jsonschema:"Share of calls that must finish within latency_ms (default 0.99)"`
+	// MinCalls is how many calls a window needs before it can alert.
+	MinCalls int `json:"min_calls,omitempty" jsonschema:"Calls needed in the long window of an alert before it fires (default 10)"`
+}
+
+// configError locates a problem in the config file.
+type configError struct {
+	Line, Column int
+	Msg          string
+}
+
+func (e *configError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// loadConfig reads a config file.
+func loadConfig(path string) (*serverConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(data)
+}
+
+// parseConfig decodes and validates a config file. Errors are
+// *configError where the problem has a position.
+func parseConfig(data []byte) (*serverConfig, error) {
+	data, err := expandEnv(stripComments(data))
+	if err != nil {
+		return nil, err
+	}
+	keys, err := checkConfigKeys(data)
+	if err != nil {
+		return nil, locateConfigError(data, keys, err)
+	}
+	var cfg serverConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, locateConfigError(data, keys, err)
+	}
+	if path, err := cfg.validate(); err != nil {
+		line, col := position(data, keys[path])
+		return nil, &configError{Line: line, Column: col, Msg: err.Error()}
+	}
+	return &cfg, nil
+}
+
+// validate checks the values the file sets, returning the dotted path of
+// the first bad one. Unset values are left to the flags and defaults.
+func (c *serverConfig) validate() (string, error) {
+	if path, err := c.configSettings.validate(""); err != nil {
+		return path, err
+	}
+	for _, name := range sortedKeys(c.Profiles) {
+		settings := c.Profiles[name]
+		if path, err := settings.validate("profiles." + name + "."); err != nil {
+			return path, err
+		}
+	}
+	return "", nil
+}
+
+// validate checks settings found under prefix.
+func (c *configSettings) validate(prefix string) (string, error) {
+	if _, ok := logLevels[c.LogLevel]; c.LogLevel != "" && !ok {
+		return prefix + "log_level", fmt.Errorf("%slog_level %q: want debug, info or warn", prefix, c.LogLevel)
+	}
+	if c.Fetch.MaxBytes != 0 && (c.Fetch.MaxBytes < minCapBytes || c.Fetch.MaxBytes > fetchBytesLimit) {
+		return prefix + "fetch.max_bytes", fmt.Errorf("%sfetch.max_bytes must be between %d and %d", prefix, minCapBytes, fetchBytesLimit)
+	}
+	if c.Fetch.DefaultBytes != 0 && (c.Fetch.DefaultBytes < minCapBytes || c.Fetch.DefaultBytes > fetchBytesLimit) {
+		return prefix + "fetch.default_bytes", fmt.Errorf("%sfetch.default_bytes must be between %d and %d", prefix, minCapBytes, fetchBytesLimit)
+	}
+	if c.Fetch.MaxBinaryBytes != 0 && (c.Fetch.MaxBinaryBytes < minCapBytes || c.Fetch.MaxBinaryBytes > fetchBytesLimit) {
+		return prefix + "fetch.max_binary_bytes", fmt.Errorf("%sfetch.max_binary_bytes must be between %d and %d", prefix, minCapBytes, fetchBytesLimit)
+	}
+	if c.PublicDemo.RatePerMinute < 0 {
+		return prefix + "public_demo.rate_per_minute", fmt.Errorf("%spublic_demo.rate_per_minute must be positive", prefix)
+	}
+	for _, tool := range sortedKeys(c.SLOs) {
+		if path, err := c.SLOs[tool].validate(prefix + "slos." + tool + "."); err != nil {
+			return path, err
+		}
+	}
+	return "", nil
+}
+
+// validate checks the objectives of one tool, found under prefix.
+func (c sloConfig) validate(prefix string) (string, error) {
+	if c.SuccessRate == 0 && c.LatencyMs == 0 {
+		return strings.TrimSuffix(prefix, "."), fmt.Errorf("%s: set success_rate or latency_ms", strings.TrimSuffix(prefix, "."))
+	}
+	if c.SuccessRate != 0 && (c.SuccessRate <= 0 || c.SuccessRate >= 1) {
+		return prefix + "success_rate", fmt.Errorf("%ssuccess_rate must be between 0 and 1, e.g. 0.99", prefix)
+	}
+	if c.LatencyMs < 0 {
+		return prefix + "latency_ms", fmt.Errorf("%slatency_ms must be positive", prefix)
+	}
+	if c.LatencyTarget != 0 && (c.LatencyTarget <= 0 || c.LatencyTarget >= 1) {
+		return prefix + "latency_target", fmt.Errorf("%slatency_target must be between 0 and 1, e.g. 0.99", prefix)
+	}
+	if c.LatencyTarget != 0 && c.LatencyMs == 0 {
+		return prefix + "latency_target", fmt.Errorf("%slatency_target needs latency_ms", prefix)
+	}
+	if c.MinCalls < 0 {
+		return prefix + "min_calls", fmt.Errorf("%smin_calls must be positive", prefix)
+	}
+	return "", nil
+}
+
+// checkConfigKeys walks the JSON in data and rejects object keys that the
+// matching serverConfig struct does not have. It returns the offset of
+// every key by dotted path, for error positions.
+func checkConfigKeys(data []byte) (map[string]int64, error) {
+	keys := make(map[string]int64)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := walkConfig(data, dec, reflect.TypeFor[serverConfig](), "", keys); err != nil {
+		if errors.Is(err, io.EOF) {
+			return keys, &configError{Line: 1, Column: 1, Msg: "empty config file; want a JSON object"}
+		}
+		return keys, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		line, col := position(data, skipSpace(data, dec.InputOffset()))
+		return keys, &configError{Line: line, Column: col, Msg: "unexpected data after the config object"}
+	}
+	return keys, nil
+}
+
+// walkConfig reads one value, checking object keys against t (nil when
+// the value's type is unknown). The keys of maps are not checked.
+func walkConfig(data []byte, dec *json.Decoder, t reflect.Type, path string, keys map[string]int64) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		// Scalar types are checked when the file is decoded.
+		return nil
+	}
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch delim {
+	case '[':
+		var elem reflect.Type
+		if t != nil && t.Kind() == reflect.Slice {
+			elem = t.Elem()
+		}
+		for dec.More() {
+			if err := walkConfig(data, dec, elem, path, keys); err != nil {
+				return err
+			}
+		}
+	case '{':
+		for dec.More() {
+			off := skipSpace(data, dec.InputOffset())
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key := tok.(string)
+			p := key
+			if path != "" {
+				p = path + "." + key
+			}
+			keys[p] = off
+			var ft reflect.Type
+			if t != nil && t.Kind() == reflect.Map {
+				ft = t.Elem()
+			}
+			if t != nil && t.Kind() == reflect.Struct {
+				f, ok := jsonField(t, key)
+				if !ok {
+					line, col := position(data, off)
+					return &configError{Line: line, Column: col, Msg: unknownFieldMessage(t, p, key)}
+				}
+				ft = f.Type
+			}
+			if err := walkConfig(data, dec, ft, p, keys); err != nil {
+				return err
+			}
+		}
+	}
+	// The closing delimiter.
+	_, err = dec.Token()
+	return err
+}
+
+// jsonField finds the field of struct t encoded under name.
+// Fields of embedded structs count as t's own, as in encoding/json.
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for _, f := range reflect.VisibleFields(t) {
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.Anonymous && tag == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// unknownFieldMessage names the unknown field and suggests a field that
+// differs only in case, "_" or "-", or lists the valid ones.
+func unknownFieldMessage(t reflect.Type, path, key string) string {
+	normalize := func(s string) string {
+		return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(s))
+	}
+	var names []string
+	for _, f := range reflect.VisibleFields(t) {
+		if f.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if normalize(name) == normalize(key) {
+			return fmt.Sprintf("unknown field %q (did you mean %q?)", path, name)
+		}
+		names = append(names, name)
+	}
+	return fmt.Sprintf("unknown field %q (want one of %s)", path, strings.Join(names, ", "))
+}
+
+// locateConfigError turns decoding errors into *configError. Type errors
+// point at their key when it is known.
+func locateConfigError(data []byte, keys map[string]int64, err error) error {
+	var cfgErr *configError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &cfgErr):
+		return cfgErr
+	case errors.As(err, &syntaxErr):
+		line, col := position(data, syntaxErr.Offset)
+		return &configError{Line: line, Column: col, Msg: syntaxErr.Error()}
+	case errors.As(err, &typeErr):
+		off, ok := keys[typeErr.Field]
+		if !ok {
+			off = typeErr.Offset
+		}
+		field := typeErr.Field
+		if field == "" {
+			field, off = "config", skipSpace(data, 0)
+		}
+		line, col := position(data, off)
+		return &configError{Line: line, Column: col, Msg: fmt.Sprintf("%s: got a JSON %s, want %s", field, typeErr.Value, jsonTypeName(typeErr.Type))}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		line, col := position(data, int64(len(data)))
+		return &configError{Line: line, Column: col, Msg: "unexpected end of file"}
+	}
+	return err
+}
+
+// jsonTypeName describes a Go type as the JSON it decodes from.
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Slice:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	}
+	return t.String()
+}
+
+// position converts a byte offset in data to a 1-based line and column.
+func position(data []byte, offset int64) (line, col int) {
+	before := data[:min(max(offset, 0), int64(len(data)))]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = len(before) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// skipSpace returns the offset of the first byte at or after off that is
+// not whitespace or a separator, i.e. where the next token starts.
+func skipSpace(data []byte, off int64) int64 {
+	for off < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[off]) >= 0 {
+		off++
+	}
+	return off
+}
+
+// stripComments blanks out // and /* */ comments outside strings. Line
+// breaks are kept, so positions in errors still match the file.
+func stripComments(data []byte) []byte {
+	out := bytes.Clone(data)
+	inString := false
+	for i := 0; i < len(out); i++ {
+		switch c := out[i]; {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			if end < 0 {
+				// Left in place, so that decoding reports it.
+				return out
+			}
+			for stop := i + 2 + end + 2; i < stop; i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			i--
+		}
+	}
+	return out
+}
+
+// configSchema returns the JSON Schema of the config file, for
+// -print-config-schema.
+func configSchema() *jsonschema.Schema {
+	s := outputSchema[serverConfig]()
+	s.Schema = "https://json-schema.org/draft/2020-12/schema"
+	s.Title = "mcp-server-demo-go config file"
+	tuneSettingsSchema(s)
+	tuneSettingsSchema(s.Properties["profiles"].AdditionalProperties)
+	return s
+}
+
+// tuneSettingsSchema adds the constraints that struct tags cannot express
+// to the schema of a configSettings.
+func tuneSettingsSchema(s *jsonschema.Schema) {
+	s.Properties["log_level"].Enum = []any{logDebug, logInfo, logWarn}
+	for _, name := range []string{"max_bytes", "default_bytes", "max_binary_bytes"} {
+		prop := s.Properties["fetch"].Properties[name]
+		prop.Minimum = jsonschema.Ptr(float64(minCapBytes))
+		prop.Maximum = jsonschema.Ptr(float64(fetchBytesLimit))
+	}
+	s.Properties["public_demo"].Properties["rate_per_minute"].ExclusiveMinimum = jsonschema.Ptr(0.0)
+	slo := s.Properties["slos"].AdditionalProperties
+	for _, name := range []string{"success_rate", "latency_target"} {
+		slo.Properties[name].ExclusiveMinimum = jsonschema.Ptr(0.0)
+		slo.Properties[name].ExclusiveMaximum = jsonschema.Ptr(1.0)
+	}
+	slo.Properties["latency_ms"].Minimum = jsonschema.Ptr(1.0)
+	slo.Properties["min_calls"].Minimum = jsonschema.Ptr(1.0)
+	slo.AnyOf = []*jsonschema.Schema{{Required: []string{"success_rate"}}, {Required: []string{"latency_ms"}}}
+}
+
+// starterConfig is written by "config init". It sets every option to its
+// default, so it behaves like running without -config.
+func starterConfig() string {
+	headers, _ := json.Marshal(strings.Split(defaultFetchAllowedHeaders, ","))
+	return fmt.Sprintf(`// Config file for mcp-server-demo-go, read with -config. Flags given on
+// the command line override these settings. The server re-reads the file
+// on SIGHUP and, every -config-watch, when it changes.
+// The environment variables MCP_LOG_LEVEL, MCP_FETCH_MAX_BYTES,
+// MCP_FETCH_DEFAULT_BYTES, MCP_FETCH_MAX_BINARY_BYTES,
+// MCP_FETCH_ALLOWED_HEADERS, MCP_PUBLIC_DEMO_RATE, MCP_ENABLE_TOOLS and
+// MCP_DISABLE_TOOLS override the file and its profile.
+// The JSON Schema of this file is printed by -print-config-schema.
+{
+  // Server log level: debug (adds a line per tool call), info (adds
+  // every HTTP request) or warn.
+  "log_level": %q,
+
+  // The fetch tool. Ignored in -public-demo mode, which has fixed limits.
+  "fetch": {
+    // Largest max_bytes a call may ask for (%d to %d).
+    "max_bytes": %d,
+    // Bytes returned to calls that give no max_bytes.
+    "default_bytes": %d,
+    // Largest binary body (image, audio, PDF...) returned as a content
+    // block rather than text.
+    "max_binary_bytes": %d,
+    // Request headers callers may set.
+    "allowed_headers": %s
+  },
+
+  "public_demo": {
+    // Requests per minute per client address in -public-demo mode.
+    "rate_per_minute": 30
+  },
+
+  // Which tools are registered. A non-empty "enable" registers only the
+  // listed tools; "disable" leaves the listed ones out. Naming a tool
+  // that the server does not offer stops it with the list of tools.
+  "tools": {
+    "enable": [],
+    "disable": []
+  },
+
+  // Service level objectives by tool name; "*" applies to the tools not
+  // listed. A tool may have a success rate target, a latency target or
+  // both. When a tool spends its error budget too fast (burn rate), the
+  // server logs it, raises an slo_burn event (sent to -alert-webhook) and
+  // reports it on /metrics. For example:
+  //   "fetch": {"success_rate": 0.99, "latency_ms": 2000, "latency_target": 0.95},
+  //   "*": {"success_rate": 0.999}
+  "slos": {},
+
+  // Profiles override the settings above for one deployment; choose one
+  // with -profile or MCP_PROFILE. Values anywhere in the file may use
+  // ${VAR} or ${VAR:-default} from the environment; inside a string the
+  // value is inserted as text, outside one as JSON.
+  "profiles": {
+    "dev": {"log_level": "debug"},
+    "staging": {"fetch": {"max_bytes": ${STAGING_FETCH_MAX_BYTES:-32768}}},
+    "prod": {"log_level": "warn"}
+  }
+}
+`, logInfo, minCapBytes, fetchBytesLimit, maxCapBytes, defaultMaxBytes, defaultMaxBinaryBytes, headers)
+}
+
+// runConfigCommand implements the "config" subcommand:
+//
+//	config init [-force] [path]
+//
+// writes the starter config to path (default server.json, "-" for
+// standard output). It returns the exit code.
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "init" {
+		fmt.Fprintln(os.Stderr, "usage: config init [-force] [path]")
+		return 2
+	}
+	fs := flag.NewFlagSet("config init", flag.ContinueOnError)
+	force := fs.Bool("force", false, "Overwrite an existing file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: config init [-force] [path]\n\nWrites a commented starter config file (default server.json, - for standard output).")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	path := "server.json"
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		path = fs.Arg(0)
+	default:
+		fs.Usage()
+		return 2
+	}
+
+	content := starterConfig()
+	if path == "-" {
+		fmt.Print(content)
+		return 0
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if *force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		fmt.Fprintf(os.Stderr, "%s already exists; use -force to overwrite it\n", path)
+		return 1
+	}
+	if err == nil {
+		_, err = f.WriteString(content)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config init: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Wrote %s; start the server with -config %s\n", path, path)
+	return 0
+}
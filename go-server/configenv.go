@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/* ---------- Config layers: environment and profiles ---------- */
+
+// Settings are layered, each layer overriding the ones before it:
+//
+//	defaults < config file < its -profile < MCP_* variables < flags
+//
+// The file may also pull single values from the environment with ${VAR}.
+// Every other flag can be set with its MCP_* variable too, see
+// setFlagsFromEnv.
+
+// configEnvVars are the environment variables of the env layer, each
+// named after the flag it stands in for. Empty variables are ignored.
+var configEnvVars = []struct {
+	name string
+	set  func(s *configSettings, v string) error
+}{
+	{"MCP_LOG_LEVEL", func(s *configSettings, v string) error { s.LogLevel = v; return nil }},
+	{"MCP_FETCH_MAX_BYTES", func(s *configSettings, v string) (err error) {
+		if s.Fetch.MaxBytes, err = strconv.Atoi(v); err != nil {
+			return errNotANumber
+		}
+		return nil
+	}},
+	{"MCP_FETCH_DEFAULT_BYTES", func(s *configSettings, v string) (err error) {
+		if s.Fetch.DefaultBytes, err = strconv.Atoi(v); err != nil {
+			return errNotANumber
+		}
+		return nil
+	}},
+	{"MCP_FETCH_MAX_BINARY_BYTES", func(s *configSettings, v string) (err error) {
+		if s.Fetch.MaxBinaryBytes, err = strconv.Atoi(v); err != nil {
+			return errNotANumber
+		}
+		return nil
+	}},
+	{"MCP_FETCH_ALLOWED_HEADERS", func(s *configSettings, v string) error {
+		s.Fetch.AllowedHeaders = strings.Split(v, ",")
+		return nil
+	}},
+	{"MCP_PUBLIC_DEMO_RATE", func(s *configSettings, v string) (err error) {
+		if s.PublicDemo.RatePerMinute, err = strconv.ParseFloat(v, 64); err != nil {
+			return errNotANumber
+		}
+		return nil
+	}},
+	{"MCP_ENABLE_TOOLS", func(s *configSettings, v string) error { s.Tools.Enable = strings.Split(v, ","); return nil }},
+	{"MCP_DISABLE_TOOLS", func(s *configSettings, v string) error { s.Tools.Disable = strings.Split(v, ","); return nil }},
+}
+
+var errNotANumber = errors.New("not a number")
+
+// profileEnvVar selects the profile when -profile is not given.
+const profileEnvVar = "MCP_PROFILE"
+
+// flagEnvName is the variable that stands in for a flag: -fetch-deny-private
+// is MCP_FETCH_DENY_PRIVATE.
+func flagEnvName(flag string) string {
+	return "MCP_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// flagsWithoutEnv are actions rather than settings.
+var flagsWithoutEnv = map[string]bool{"print-config-schema": true, "install-service": true, "uninstall-service": true}
+
+// setFlagsFromEnv sets each flag of fs from its MCP_* variable, if that is
+// not empty, before the command line is parsed over it; a flag set this
+// way counts as given. The flags the config file also covers keep to
+// their place in the layers: their variables are read by settingsFromEnv,
+// above the file, on every load. Each flag's usage names its variable.
+func setFlagsFromEnv(fs *flag.FlagSet) error {
+	layered := map[string]bool{profileEnvVar: true}
+	for _, v := range configEnvVars {
+		layered[v.name] = true
+	}
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if flagsWithoutEnv[f.Name] {
+			return
+		}
+		name := flagEnvName(f.Name)
+		f.Usage += " [$" + name + "]"
+		value := os.Getenv(name)
+		if err != nil || layered[name] || value == "" {
+			return
+		}
+		if serr := fs.Set(f.Name, value); serr != nil {
+			err = fmt.Errorf("%s=%q: %w", name, value, serr)
+		}
+	})
+	return err
+}
+
+// settingsFromEnv returns the settings the MCP_* variables set.
+func settingsFromEnv() (configSettings, error) {
+	var s configSettings
+	for _, v := range configEnvVars {
+		if value := os.Getenv(v.name); value != "" {
+			if err := v.set(&s, value); err != nil {
+				return s, fmt.Errorf("%s=%q: %w", v.name, value, err)
+			}
+		}
+	}
+	return s, nil
+}
+
+// selectProfile layers the named profile over the file's top-level
+// settings.
+func (c *serverConfig) selectProfile(name string) error {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		if len(c.Profiles) == 0 {
+			return fmt.Errorf("profile %q: the config file has no profiles", name)
+		}
+		return fmt.Errorf("profile %q not found (have %s)", name, strings.Join(sortedKeys(c.Profiles), ", "))
+	}
+	overlaySettings(&c.configSettings, &profile)
+	return nil
+}
+
+// overlaySettings copies the settings src sets over dst: non-zero
+// values, and lists that are present even when empty, so that a layer
+// can clear a list with [].
+func overlaySettings(dst, src *configSettings) {
+	overlay(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem())
+}
+
+func overlay(dst, src reflect.Value) {
+	for i := range src.NumField() {
+		s, d := src.Field(i), dst.Field(i)
+		switch {
+		case s.Kind() == reflect.Struct:
+			overlay(d, s)
+		case s.Kind() == reflect.Slice:
+			if !s.IsNil() {
+				d.Set(s)
+			}
+		case !s.IsZero():
+			d.Set(s)
+		}
+	}
+}
+
+// expandEnv replaces ${VAR} and ${VAR:-default} with values from the
+// environment; $$ stands for a literal $. Inside a JSON string the value
+// is escaped, so any text is safe; outside one it is inserted as is, so
+// that numbers and whole values can come from the environment. A
+// variable that is not set and has no default is an error.
+func expandEnv(data []byte) ([]byte, error) {
+	if !bytes.Contains(data, []byte("$")) {
+		return data, nil
+	}
+	var out bytes.Buffer
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '$' && i+1 < len(data) && data[i+1] == '$':
+			out.WriteByte('$')
+			i++
+			continue
+		case c == '$' && i+1 < len(data) && data[i+1] == '{':
+			end := bytes.IndexByte(data[i+2:], '}')
+			if end < 0 {
+				line, col := position(data, int64(i))
+				return nil, &configError{Line: line, Column: col, Msg: "unterminated ${ reference"}
+			}
+			ref := string(data[i+2 : i+2+end])
+			name, def, hasDef := strings.Cut(ref, ":-")
+			value, ok := os.LookupEnv(name)
+			if !ok || (value == "" && hasDef) {
+				if !hasDef {
+					line, col := position(data, int64(i))
+					return nil, &configError{Line: line, Column: col, Msg: fmt.Sprintf("environment variable %s is not set (use ${%s:-default} for a default)", name, name)}
+				}
+				value = def
+			}
+			if inString {
+				quoted, _ := json.Marshal(value)
+				value = string(quoted[1 : len(quoted)-1])
+			}
+			out.WriteString(value)
+			i += 2 + end
+			continue
+		case inString && c == '\\' && i+1 < len(data):
+			out.WriteByte(c)
+			i++
+			c = data[i]
+		case c == '"':
+			inString = !inString
+		}
+		out.WriteByte(c)
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* ---------- CORS ---------- */
+
+// corsDefaultHeaders are the request headers a browser MCP client needs;
+// -cors-headers adds to them.
+var corsDefaultHeaders = []string{"Accept", "Authorization", "Content-Type", "Last-Event-ID", "Mcp-Protocol-Version", sessionIDHeader}
+
+// corsExposedHeaders are the response headers browser scripts may read.
+var corsExposedHeaders = []string{sessionIDHeader, "Mcp-Protocol-Version", "Retry-After", "WWW-Authenticate"}
+
+// corsPolicy lets browser-based MCP clients on other origins call the MCP
+// endpoint. Origins are exact ("https://app.example.com"), a wildcard
+// subdomain ("https://*.example.com") or "*" for any origin.
+type corsPolicy struct {
+	Origins     []corsOrigin
+	Headers     []string
+	Credentials bool
+	MaxAge      time.Duration
+	anyOrigin   bool
+}
+
+// corsOrigin is one -cors-origins entry.
+type corsOrigin struct {
+	Scheme, Host, Port string
+	// Subdomains matches the subdomains of Host rather than Host itself.
+	Subdomains bool
+}
+
+// newCORSPolicy validates the -cors-* flags.
+func newCORSPolicy(origins, headers string, credentials bool, maxAge time.Duration) (*corsPolicy, error) {
+	p := &corsPolicy{Headers: corsDefaultHeaders, Credentials: credentials, MaxAge: maxAge}
+	for _, o := range strings.Split(origins, ",") {
+		o = strings.TrimSuffix(strings.TrimSpace(o), "/")
+		switch o {
+		case "":
+			continue
+		case "*":
+			if credentials {
+				return nil, fmt.Errorf("-cors-credentials cannot be used with the * origin; list the origins")
+			}
+			p.anyOrigin = true
+			continue
+		}
+		origin, ok := parseOrigin(strings.Replace(o, "://*.", "://", 1))
+		if !ok {
+			return nil, fmt.Errorf("origin %q: want scheme://host[:port], scheme://*.domain or *", o)
+		}
+		origin.Subdomains = strings.Contains(o, "://*.")
+		p.Origins = append(p.Origins, origin)
+	}
+	if len(p.Origins) == 0 && !p.anyOrigin {
+		return nil, fmt.Errorf("no origins given")
+	}
+	for _, h := range strings.Split(headers, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			p.Headers = append(p.Headers, http.CanonicalHeaderKey(h))
+		}
+	}
+	return p, nil
+}
+
+// parseOrigin splits an origin such as https://app.example.com:8443.
+func parseOrigin(s string) (corsOrigin, bool) {
+	u, err := url.Parse(strings.ToLower(s))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+		return corsOrigin{}, false
+	}
+	return corsOrigin{Scheme: u.Scheme, Host: u.Hostname(), Port: u.Port()}, true
+}
+
+// allowed reports whether a request's Origin header matches the policy.
+func (p *corsPolicy) allowed(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	o, ok := parseOrigin(origin)
+	if !ok {
+		return false
+	}
+	for _, a := range p.Origins {
+		if a.Scheme != o.Scheme || a.Port != o.Port {
+			continue
+		}
+		if a.Subdomains && strings.HasSuffix(o.Host, "."+a.Host) || !a.Subdomains && o.Host == a.Host {
+			return true
+		}
+	}
+	return false
+}
+
+// wrap adds the CORS headers to next's responses and answers preflight
+// requests itself. Requests from origins the policy does not allow are
+// served without CORS headers, so the browser keeps the response from
+// the calling page; their preflights get 403.
+func (p *corsPolicy) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !p.allowed(origin) {
+			if preflight {
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if p.anyOrigin {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if p.Credentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			h.Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		h.Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		h.Set("Access-Control-Allow-Headers", strings.Join(p.Headers, ", "))
+		if p.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
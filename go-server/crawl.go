@@ -0,0 +1,597 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+/* ---------- Tool: crawl_preview ---------- */
+
+const (
+	// defaultCrawlPages and maxCrawlPages bound the max_pages argument.
+	defaultCrawlPages = 10
+	maxCrawlPages     = 50
+	// defaultCrawlDelay is the pause between requests; delay_ms may not
+	// go below minCrawlDelay, and robots.txt Crawl-delay is honored up to
+	// maxCrawlDelay.
+	defaultCrawlDelay = time.Second
+	minCrawlDelay     = 250 * time.Millisecond
+	maxCrawlDelay     = 10 * time.Second
+	// maxCrawlPageBytes caps how much of each page is read; the title and
+	// description are near the top.
+	maxCrawlPageBytes = 512 << 10
+	// maxChildSitemaps caps the sitemaps read from a sitemap index.
+	maxChildSitemaps = 3
+	// crawlAgent is the robots.txt user-agent token the crawler obeys
+	// besides "*".
+	crawlAgent = "mcp-server-demo-go"
+)
+
+type CrawlArgs struct {
+	URL      string `json:"url" jsonschema:"Sitemap (XML urlset or sitemap index) or seed page URL; only pages on the same origin are visited"`
+	MaxPages int    `json:"max_pages,omitempty" jsonschema:"Maximum pages to visit (default 10, max 50; the per-call request budget also applies)"`
+	DelayMs  int    `json:"delay_ms,omitempty" jsonschema:"Pause between requests in milliseconds (default 1000, min 250); a larger robots.txt Crawl-delay wins"`
+}
+
+// CrawlPage is what the crawl found on one page. The tool result holds
+// the summary fields; the artifact holds all of them.
+type CrawlPage struct {
+	URL         string `json:"url"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Error       string `json:"error,omitempty"`
+
+	FinalURL    string `json:"final_url,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Canonical   string `json:"canonical,omitempty"`
+	Links       int    `json:"links,omitempty"`
+	ElapsedMs   int64  `json:"elapsed_ms"`
+}
+
+// CrawlPageSummary is one page in the crawl_preview result.
+type CrawlPageSummary struct {
+	URL         string `json:"url"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// CrawlResult is the structured output of the crawl_preview tool.
+type CrawlResult struct {
+	Seed          string             `json:"seed"`
+	Source        string             `json:"source" jsonschema:"sitemap when the URL was a sitemap, links when pages were found by following links"`
+	Pages         []CrawlPageSummary `json:"pages"`
+	Pending       int                `json:"pending" jsonschema:"Same-origin URLs found but not visited"`
+	RobotsSkipped int                `json:"robots_skipped" jsonschema:"URLs not visited because robots.txt disallows them"`
+	DelayMs       int64              `json:"delay_ms" jsonschema:"Pause used between requests"`
+	Stopped       string             `json:"stopped,omitempty" jsonschema:"Why the crawl ended early, e.g. the request budget ran out"`
+	Artifact      string             `json:"artifact,omitempty" jsonschema:"URI of the resource holding the full results (final URLs, content types, canonical URLs, link counts, timings)"`
+}
+
+// crawlReport is the JSON stored as the crawl's artifact.
+type crawlReport struct {
+	Seed          string      `json:"seed"`
+	Source        string      `json:"source"`
+	Started       time.Time   `json:"started"`
+	DelayMs       int64       `json:"delay_ms"`
+	RobotsSkipped []string    `json:"robots_skipped,omitempty"`
+	Pending       []string    `json:"pending,omitempty"`
+	Stopped       string      `json:"stopped,omitempty"`
+	Pages         []CrawlPage `json:"pages"`
+}
+
+// crawler holds the state of one crawl_preview call.
+type crawler struct {
+	client *http.Client
+	origin *url.URL
+	robots robotsRules
+	delay  time.Duration
+	last   time.Time // when the previous request was sent
+}
+
+func CrawlPreviewTool(ctx context.Context, req *mcp.CallToolRequest, in CrawlArgs) (*mcp.CallToolResult, any, error) {
+	if !strings.HasPrefix(in.URL, "http://") && !strings.HasPrefix(in.URL, "https://") {
+		return errorResult("URL must start with http:// or https://"), nil, nil
+	}
+	seed, err := url.Parse(in.URL)
+	if err != nil {
+		return errorResult("Invalid URL: " + err.Error()), nil, nil
+	}
+	if err := egress.Check(ctx, seed); err != nil {
+		return errorResult("URL not allowed: " + err.Error()), nil, nil
+	}
+	maxPages := in.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultCrawlPages
+	}
+	maxPages = min(maxPages, maxCrawlPages)
+	delay := defaultCrawlDelay
+	if in.DelayMs > 0 {
+		delay = max(time.Duration(in.DelayMs)*time.Millisecond, minCrawlDelay)
+	}
+
+	// Progress counts pages here, not the bytes of each response.
+	progress := progressFrom(ctx)
+	ctx = withoutTransferProgress(ctx)
+
+	var redirects []string
+	c := &crawler{
+		client: redirectingClient(true, defaultMaxRedirects, &redirects),
+		origin: &url.URL{Scheme: seed.Scheme, Host: seed.Host},
+	}
+	report := crawlReport{Seed: in.URL, Source: "links", Started: time.Now().UTC()}
+
+	stop := func(err error) bool {
+		switch {
+		case errors.Is(err, errBudgetExceeded):
+			report.Stopped = err.Error()
+		case ctx.Err() != nil:
+			report.Stopped = "cancelled: " + ctx.Err().Error()
+		default:
+			return false
+		}
+		return true
+	}
+
+	c.robots, err = c.fetchRobots(ctx)
+	if stop(err) {
+		return errorResult("robots.txt: " + report.Stopped), nil, nil
+	}
+	c.delay = delay
+	if c.robots.delay > delay {
+		c.delay = min(c.robots.delay, maxCrawlDelay)
+	}
+	report.DelayMs = c.delay.Milliseconds()
+
+	// The seed is either a sitemap, whose URLs make up the queue, or the
+	// first page of a crawl that follows links.
+	if !c.robots.allowed(seed) {
+		return errorResult("robots.txt disallows " + in.URL), nil, nil
+	}
+	var queue []string
+	seen := map[string]bool{}
+	first, body, err := c.visit(ctx, seed.String())
+	if stop(err) {
+		return errorResult("seed: " + report.Stopped), nil, nil
+	}
+	if locs, index, ok := parseSitemap(body); ok {
+		report.Source = "sitemap"
+		queue = c.sitemapURLs(ctx, locs, index, maxPages)
+	} else {
+		seen[seed.String()] = true
+		queue = c.links(first, body)
+		first.Links = len(queue)
+		report.Pages = append(report.Pages, first)
+		progress.notify(1, float64(maxPages), "crawled "+first.URL)
+	}
+
+	for len(queue) > 0 && len(report.Pages) < maxPages && report.Stopped == "" {
+		next := queue[0]
+		queue = queue[1:]
+		if seen[next] {
+			continue
+		}
+		seen[next] = true
+		u, err := url.Parse(next)
+		if err != nil || !c.sameOrigin(u) {
+			continue
+		}
+		if !c.robots.allowed(u) {
+			report.RobotsSkipped = append(report.RobotsSkipped, next)
+			continue
+		}
+		page, body, err := c.visit(ctx, next)
+		if stop(err) {
+			break
+		}
+		if report.Source == "links" {
+			links := c.links(page, body)
+			page.Links = len(links)
+			queue = append(queue, links...)
+		}
+		report.Pages = append(report.Pages, page)
+		total := maxPages
+		if report.Source == "sitemap" {
+			total = min(maxPages, len(report.Pages)+len(queue))
+		}
+		progress.notify(float64(len(report.Pages)), float64(total), fmt.Sprintf("crawled %d/%d: %s", len(report.Pages), total, next))
+	}
+	for _, u := range queue {
+		if !seen[u] {
+			seen[u] = true
+			report.Pending = append(report.Pending, u)
+		}
+	}
+	if report.Pages == nil {
+		report.Pages = []CrawlPage{}
+	}
+
+	out := CrawlResult{
+		Seed:          in.URL,
+		Source:        report.Source,
+		Pages:         make([]CrawlPageSummary, 0, len(report.Pages)),
+		Pending:       len(report.Pending),
+		RobotsSkipped: len(report.RobotsSkipped),
+		DelayMs:       report.DelayMs,
+		Stopped:       report.Stopped,
+	}
+	for _, p := range report.Pages {
+		out.Pages = append(out.Pages, CrawlPageSummary{URL: p.URL, StatusCode: p.StatusCode, Title: p.Title, Description: p.Description, Error: p.Error})
+	}
+	artifactNote := ""
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err == nil {
+		out.Artifact, err = artifacts.add("crawl", "Crawl of "+in.URL, "application/json", data)
+	}
+	if err != nil {
+		artifactNote = "\n(full results not stored: " + err.Error() + ")"
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: formatCrawlResult(out) + artifactNote}},
+	}, out, nil
+}
+
+// wait keeps the politeness delay between two requests.
+func (c *crawler) wait(ctx context.Context) error {
+	if c.last.IsZero() {
+		c.last = time.Now()
+		return nil
+	}
+	t := time.NewTimer(time.Until(c.last.Add(c.delay)))
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+	}
+	c.last = time.Now()
+	return nil
+}
+
+// get requests rawURL after the politeness delay and reads up to limit
+// bytes of the decoded body.
+func (c *crawler) get(ctx context.Context, rawURL, accept string, limit int64) (*http.Response, []byte, error) {
+	if err := c.wait(ctx); err != nil {
+		return nil, nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	httpReq.Header.Set("User-Agent", fetchUserAgent)
+	httpReq.Header.Set("Accept-Encoding", fetchAcceptEncoding)
+	httpReq.Header.Set("Accept", accept)
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	decoded, err := decodeBody(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return resp, nil, err
+	}
+	defer decoded.Close()
+	body, err := io.ReadAll(io.LimitReader(decoded, limit))
+	if err != nil {
+		return resp, nil, err
+	}
+	body, _, err = toUTF8(body, resp.Header.Get("Content-Type"))
+	return resp, body, err
+}
+
+// visit fetches one page and extracts its metadata. Only budget and
+// cancellation errors are returned; other failures are recorded on the
+// page.
+func (c *crawler) visit(ctx context.Context, rawURL string) (CrawlPage, []byte, error) {
+	page := CrawlPage{URL: rawURL}
+	start := time.Now()
+	resp, body, err := c.get(ctx, rawURL, "text/html, application/xhtml+xml, application/xml;q=0.9, */*;q=0.5", maxCrawlPageBytes)
+	page.ElapsedMs = time.Since(start).Milliseconds()
+	if resp != nil {
+		page.StatusCode = resp.StatusCode
+		page.FinalURL = resp.Request.URL.String()
+		page.ContentType = resp.Header.Get("Content-Type")
+	}
+	if err != nil {
+		if errors.Is(err, errBudgetExceeded) || ctx.Err() != nil {
+			return page, nil, err
+		}
+		page.Error = err.Error()
+		return page, nil, nil
+	}
+	if isHTML(page.ContentType) {
+		if doc, err := html.Parse(bytes.NewReader(body)); err == nil {
+			page.Title, page.Description, page.Canonical = pageMetadata(doc)
+		}
+	}
+	return page, body, nil
+}
+
+// pageMetadata returns the title, meta description and canonical link of
+// an HTML document.
+func pageMetadata(doc *html.Node) (title, description, canonical string) {
+	var ogDescription string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Title:
+				if title == "" && n.FirstChild != nil {
+					title = strings.TrimSpace(spaceRun.ReplaceAllString(n.FirstChild.Data, " "))
+				}
+			case atom.Meta:
+				switch {
+				case strings.EqualFold(attr(n, "name"), "description"):
+					description = attr(n, "content")
+				case attr(n, "property") == "og:description":
+					ogDescription = attr(n, "content")
+				}
+			case atom.Link:
+				if strings.EqualFold(attr(n, "rel"), "canonical") {
+					canonical = attr(n, "href")
+				}
+			case atom.Body:
+				return
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	if description == "" {
+		description = ogDescription
+	}
+	return title, strings.TrimSpace(spaceRun.ReplaceAllString(description, " ")), canonical
+}
+
+// links returns the same-origin links of an HTML page, without fragments.
+// Pages that redirected to another origin are not followed further.
+func (c *crawler) links(page CrawlPage, body []byte) []string {
+	base, err := url.Parse(page.FinalURL)
+	if err != nil || !c.sameOrigin(base) || !isHTML(page.ContentType) {
+		return nil
+	}
+	var out []string
+	for _, link := range pageLinks(base, body, true) {
+		if u, err := url.Parse(link); err == nil && c.sameOrigin(u) {
+			out = append(out, link)
+		}
+	}
+	return out
+}
+
+func (c *crawler) sameOrigin(u *url.URL) bool {
+	return u.Scheme == c.origin.Scheme && strings.EqualFold(u.Host, c.origin.Host)
+}
+
+// sitemapURLs returns the page URLs of a sitemap. For a sitemap index,
+// the first maxChildSitemaps same-origin sitemaps are read until there are
+// enough URLs.
+func (c *crawler) sitemapURLs(ctx context.Context, locs []string, index bool, want int) []string {
+	if !index {
+		return locs
+	}
+	var urls []string
+	read := 0
+	for _, loc := range locs {
+		if read == maxChildSitemaps || len(urls) >= want {
+			break
+		}
+		u, err := url.Parse(loc)
+		if err != nil || !c.sameOrigin(u) {
+			continue
+		}
+		read++
+		_, body, err := c.get(ctx, loc, "application/xml, text/xml", maxExtractInputBytes)
+		if err != nil {
+			continue
+		}
+		if child, isIndex, ok := parseSitemap(body); ok && !isIndex {
+			urls = append(urls, child...)
+		}
+	}
+	return urls
+}
+
+// parseSitemap reads the <loc> entries of a sitemap urlset, or of a
+// sitemap index when index is true. ok is false for anything else.
+func parseSitemap(body []byte) (locs []string, index, ok bool) {
+	var doc struct {
+		XMLName  xml.Name
+		URLs     []string `xml:"url>loc"`
+		Sitemaps []string `xml:"sitemap>loc"`
+	}
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	// Charset conversion already happened; accept the declared encoding.
+	dec.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+	if err := dec.Decode(&doc); err != nil {
+		return nil, false, false
+	}
+	trim := func(in []string) []string {
+		out := make([]string, 0, len(in))
+		for _, s := range in {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	switch doc.XMLName.Local {
+	case "urlset":
+		return trim(doc.URLs), false, true
+	case "sitemapindex":
+		return trim(doc.Sitemaps), true, true
+	}
+	return nil, false, false
+}
+
+// robotsRules are the robots.txt rules that apply to the crawler.
+type robotsRules struct {
+	allow, disallow []*regexp.Regexp
+	delay           time.Duration
+	// lengths of the patterns, for longest-match precedence
+	allowLen, disallowLen []int
+}
+
+// fetchRobots reads the origin's robots.txt. A missing or unreadable file
+// allows everything; only budget and cancellation errors are returned.
+func (c *crawler) fetchRobots(ctx context.Context) (robotsRules, error) {
+	resp, body, err := c.get(ctx, c.origin.String()+"/robots.txt", "text/plain", 512<<10)
+	if err != nil {
+		if errors.Is(err, errBudgetExceeded) || ctx.Err() != nil {
+			return robotsRules{}, err
+		}
+		return robotsRules{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return robotsRules{}, nil
+	}
+	return parseRobots(string(body), crawlAgent), nil
+}
+
+// parseRobots picks the group for agent, or else the "*" group, and
+// compiles its Allow and Disallow rules, with * and $ wildcards.
+func parseRobots(body, agent string) robotsRules {
+	type group struct {
+		agents []string
+		lines  [][2]string
+	}
+	var groups []*group
+	var cur *group
+	for _, line := range strings.Split(body, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		if key == "user-agent" {
+			if cur == nil || len(cur.lines) > 0 {
+				cur = &group{}
+				groups = append(groups, cur)
+			}
+			cur.agents = append(cur.agents, strings.ToLower(value))
+			continue
+		}
+		if cur != nil {
+			cur.lines = append(cur.lines, [2]string{key, value})
+		}
+	}
+	var chosen *group
+	for _, g := range groups {
+		for _, a := range g.agents {
+			if a == agent {
+				chosen = g
+			} else if a == "*" && chosen == nil {
+				chosen = g
+			}
+		}
+	}
+	var rules robotsRules
+	if chosen == nil {
+		return rules
+	}
+	for _, l := range chosen.lines {
+		switch l[0] {
+		case "allow", "disallow":
+			if l[1] == "" {
+				continue
+			}
+			pattern := regexp.QuoteMeta(l[1])
+			pattern = strings.ReplaceAll(pattern, `\*`, ".*")
+			if strings.HasSuffix(pattern, `\$`) {
+				pattern = strings.TrimSuffix(pattern, `\$`) + "$"
+			}
+			re, err := regexp.Compile("^" + pattern)
+			if err != nil {
+				continue
+			}
+			if l[0] == "allow" {
+				rules.allow, rules.allowLen = append(rules.allow, re), append(rules.allowLen, len(l[1]))
+			} else {
+				rules.disallow, rules.disallowLen = append(rules.disallow, re), append(rules.disallowLen, len(l[1]))
+			}
+		case "crawl-delay":
+			var secs float64
+			if _, err := fmt.Sscanf(l[1], "%g", &secs); err == nil && secs > 0 {
+				rules.delay = time.Duration(secs * float64(time.Second))
+			}
+		}
+	}
+	return rules
+}
+
+// allowed applies the longest matching rule; Allow wins a tie.
+func (r robotsRules) allowed(u *url.URL) bool {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	longest := func(res []*regexp.Regexp, lens []int) int {
+		best := -1
+		for i, re := range res {
+			if lens[i] > best && re.MatchString(path) {
+				best = lens[i]
+			}
+		}
+		return best
+	}
+	return longest(r.allow, r.allowLen) >= longest(r.disallow, r.disallowLen)
+}
+
+// formatCrawlResult renders one line per page plus a summary.
+func formatCrawlResult(out CrawlResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Crawl of %s (%s): %d pages, %dms between requests\n", out.Seed, out.Source, len(out.Pages), out.DelayMs)
+	for i, p := range out.Pages {
+		switch {
+		case p.Error != "":
+			fmt.Fprintf(&b, "\n[%d] %s\n    error: %s", i+1, p.URL, p.Error)
+		default:
+			fmt.Fprintf(&b, "\n[%d] %d %s", i+1, p.StatusCode, p.URL)
+			if p.Title != "" {
+				fmt.Fprintf(&b, "\n    %s", p.Title)
+			}
+			if p.Description != "" {
+				fmt.Fprintf(&b, "\n    %s", p.Description)
+			}
+		}
+	}
+	b.WriteString("\n")
+	if out.Pending > 0 {
+		fmt.Fprintf(&b, "\nNot visited: %d more same-origin URLs", out.Pending)
+	}
+	if out.RobotsSkipped > 0 {
+		fmt.Fprintf(&b, "\nSkipped by robots.txt: %d", out.RobotsSkipped)
+	}
+	if out.Stopped != "" {
+		fmt.Fprintf(&b, "\nStopped early: %s", out.Stopped)
+	}
+	if out.Artifact != "" {
+		fmt.Fprintf(&b, "\nFull results: %s", out.Artifact)
+	}
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Tool call deadlines ---------- */
+
+// callDeadlines is configured from -call-timeout and -call-max-timeout.
+// A zero Default leaves calls without a deadline unless the client asks
+// for one; a zero Max accepts any client timeout.
+var callDeadlines = struct {
+	Default time.Duration
+	Max     time.Duration
+}{Default: 5 * time.Minute, Max: 10 * time.Minute}
+
+// deadlineError is the structured form of DEADLINE_EXCEEDED, sent in the
+// result's _meta under errorMetaKey.
+type deadlineError struct {
+	Code      string  `json:"code"`
+	TimeoutMs int64   `json:"timeout_ms"`
+	ElapsedMs float64 `json:"elapsed_ms"`
+	// Source is "client" when the timeout came from the request's _meta,
+	// "server" when it is -call-timeout. Capped is set when
+	// -call-max-timeout shortened the client's timeout.
+	Source string `json:"source"`
+	Capped bool   `json:"capped,omitempty"`
+}
+
+// deadlineExceededCount counts calls that ran out of time, per tool.
+var deadlineExceededCount = struct {
+	sync.Mutex
+	byTool map[string]int
+}{byTool: make(map[string]int)}
+
+// requestedTimeout reads the client's timeout hint. It accepts a number
+// or a numeric string; anything else, or a value <= 0, is ignored with an
+// invalid_meta warning.
+func requestedTimeout(ctx context.Context, req *mcp.CallToolRequest) time.Duration {
+	ms, ok, present := metaNumber(requestMeta(req), timeoutMetaKey)
+	if !present {
+		return 0
+	}
+	if !ok || ms <= 0 {
+		addWarning(ctx, "invalid_meta", fmt.Sprintf("_meta[%q] is not a positive number of milliseconds; ignored", timeoutMetaKey))
+		return 0
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// callTimeout picks the deadline for a call: the client's timeout capped
+// at Max, else Default.
+func callTimeout(ctx context.Context, req *mcp.CallToolRequest) (timeout time.Duration, source string, capped bool) {
+	timeout = requestedTimeout(ctx, req)
+	if timeout == 0 {
+		return callDeadlines.Default, "server", false
+	}
+	if max := callDeadlines.Max; max > 0 && timeout > max {
+		addWarning(ctx, "timeout_capped", fmt.Sprintf("the requested timeout of %s was shortened to the server's maximum of %s", timeout, max))
+		return max, "client", true
+	}
+	return timeout, "client", false
+}
+
+// deadlineToolMiddleware runs each call under its deadline. The handler's
+// context expires at the deadline, which stops its outbound requests and
+// commands; a handler that does not return by then is abandoned and the
+// client gets DEADLINE_EXCEEDED at once rather than waiting on it.
+func deadlineToolMiddleware(tool *mcp.Tool, next ToolFunc) ToolFunc {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, any, error) {
+		timeout, source, capped := callTimeout(ctx, req)
+		if timeout <= 0 {
+			return next(ctx, req)
+		}
+		start := time.Now()
+		callCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		type outcome struct {
+			res *mcp.CallToolResult
+			out any
+			err error
+		}
+		done := make(chan outcome, 1)
+		go func() {
+			res, out, err := next(callCtx, req)
+			done <- outcome{res, out, err}
+		}()
+
+		select {
+		case o := <-done:
+			// A handler that gave up because of the deadline is reported
+			// like one that was abandoned; anything else stands.
+			failed := o.err != nil || (o.res != nil && o.res.IsError)
+			if !failed || !deadlineHit(ctx, callCtx) {
+				return o.res, o.out, o.err
+			}
+		case <-callCtx.Done():
+			if !deadlineHit(ctx, callCtx) {
+				// The client cancelled: the handler stops on its own.
+				o := <-done
+				return o.res, o.out, o.err
+			}
+		}
+
+		elapsed := time.Since(start)
+		deadlineExceededCount.Lock()
+		deadlineExceededCount.byTool[tool.Name]++
+		deadlineExceededCount.Unlock()
+		origin := source + " timeout"
+		if capped {
+			origin = "client timeout capped by the server"
+		}
+		res := errorResult(fmt.Sprintf("DEADLINE_EXCEEDED: %s did not finish within %s (%s; elapsed %s)",
+			tool.Name, timeout, origin, elapsed.Round(time.Millisecond)))
+		setMeta(res, errorMetaKey, deadlineError{
+			Code:      "DEADLINE_EXCEEDED",
+			TimeoutMs: timeout.Milliseconds(),
+			ElapsedMs: roundMs(elapsed),
+			Source:    source,
+			Capped:    capped,
+		})
+		return res, nil, nil
+	}
+}
+
+// deadlineHit reports whether callCtx ended at its deadline rather than
+// with its parent ctx.
+func deadlineHit(ctx, callCtx context.Context) bool {
+	return errors.Is(callCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+}
+
+func collectDeadlineMetrics(w *metricsWriter) {
+	deadlineExceededCount.Lock()
+	defer deadlineExceededCount.Unlock()
+	w.family("mcp_tool_deadline_exceeded_total", "counter", "Tool calls that ran past their deadline, per tool")
+	for _, name := range sortedKeys(deadlineExceededCount.byTool) {
+		w.sample("mcp_tool_deadline_exceeded_total", float64(deadlineExceededCount.byTool[name]), "tool", name)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Debug endpoints ---------- */
+
+// maxCallHistory is how many recent tool calls /debug/calls keeps.
+const maxCallHistory = 200
+
+// serverStarted is when the process started, for uptime.
+var serverStarted = time.Now()
+
+// callRecord is one finished tool call. Arguments are never kept, as in
+// the tool call log.
+type callRecord struct {
+	Tool       string    `json:"tool"`
+	Session    string    `json:"session,omitempty"`
+	Started    time.Time `json:"started"`
+	DurationMs float64   `json:"duration_ms"`
+	// Outcome is ok, error (an error result), failed or cancelled.
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+// callHistory is a ring of the latest calls.
+type callHistory struct {
+	mu      sync.Mutex
+	records []callRecord
+	next    int
+}
+
+var recentCalls = &callHistory{}
+
+func (h *callHistory) add(r callRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.records) < maxCallHistory {
+		h.records = append(h.records, r)
+		return
+	}
+	h.records[h.next] = r
+	h.next = (h.next + 1) % maxCallHistory
+}
+
+// list returns the calls, most recent first.
+func (h *callHistory) list() []callRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]callRecord, 0, len(h.records))
+	for i := range h.records {
+		out = append(out, h.records[(h.next+len(h.records)-1-i)%len(h.records)])
+	}
+	return out
+}
+
+// historyToolMiddleware records every call in recentCalls.
+func historyToolMiddleware(tool *mcp.Tool, next ToolFunc) ToolFunc {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, any, error) {
+		start := time.Now()
+		res, out, err := next(ctx, req)
+		r := callRecord{Tool: tool.Name, Started: start, DurationMs: roundMs(time.Since(start)), Outcome: callOutcome(ctx, res, err)}
+		if req.Session != nil {
+			r.Session = req.Session.ID()
+		}
+		switch r.Outcome {
+		case "failed":
+			r.Error = err.Error()
+		case "error":
+			r.Error = string(truncateUTF8([]byte(resultText(res)), 200))
+		}
+		recentCalls.add(r)
+		availability.recordCall(r.Outcome)
+		return res, out, err
+	}
+}
+
+// registerDebug adds the /debug/ endpoints to mux, each behind the admin
+// token:
+//
+//	/debug/            index of the endpoints below
+//	/debug/sessions    the session table (as /admin/sessions)
+//	/debug/tools       offered tools, whether registered, and call counters
+//	/debug/calls       recent calls and per-tool latency percentiles
+//	/debug/runtime     uptime, goroutines, memory and GC statistics
+//	/debug/pprof/      the net/http/pprof profiles
+func registerDebug(mux *http.ServeMux, token string) {
+	mux.HandleFunc("GET /debug/{$}", requireAdmin(token, debugIndexHandler))
+	mux.HandleFunc("GET /debug/sessions", requireAdmin(token, sessionsHandler))
+	mux.HandleFunc("GET /debug/tools", requireAdmin(token, debugToolsHandler))
+	mux.HandleFunc("GET /debug/calls", requireAdmin(token, debugCallsHandler))
+	mux.HandleFunc("GET /debug/runtime", requireAdmin(token, debugRuntimeHandler))
+	mux.HandleFunc("/debug/pprof/", requireAdmin(token, pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", requireAdmin(token, pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", requireAdmin(token, pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", requireAdmin(token, pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", requireAdmin(token, pprof.Trace))
+}
+
+func debugIndexHandler(w http.ResponseWriter, r *http.Request) {
+	writeGatewayJSON(w, http.StatusOK, map[string]string{
+		"/debug/sessions": "open MCP sessions",
+		"/debug/tools":    "offered tools, whether registered, and call counters",
+		"/debug/calls":    "the latest tool calls and per-tool latency percentiles",
+		"/debug/runtime":  "uptime, goroutines, memory and GC statistics",
+		"/debug/pprof/":   "CPU, heap, goroutine and other profiles (go tool pprof)",
+	})
+}
+
+// debugTool is a /debug/tools entry.
+type debugTool struct {
+	Name          string  `json:"name"`
+	Registered    bool    `json:"registered"`
+	Calls         int     `json:"calls"`
+	Errors        int     `json:"errors"`
+	Cancelled     int     `json:"cancelled"`
+	MeanLatencyMs float64 `json:"mean_latency_ms,omitempty"`
+}
+
+func debugToolsHandler(w http.ResponseWriter, r *http.Request) {
+	toolSet.mu.Lock()
+	tools := make([]debugTool, len(toolSet.offered))
+	for i, name := range toolSet.offered {
+		tools[i] = debugTool{Name: name, Registered: toolSet.registered[name]}
+	}
+	toolSet.mu.Unlock()
+
+	toolCallStats.mu.Lock()
+	for i := range tools {
+		if c := toolCallStats.tools[tools[i].Name]; c != nil {
+			tools[i].Calls, tools[i].Errors, tools[i].Cancelled = c.calls, c.errors, c.cancelled
+			tools[i].MeanLatencyMs = roundMs(c.duration / time.Duration(c.calls))
+		}
+	}
+	toolCallStats.mu.Unlock()
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	writeGatewayJSON(w, http.StatusOK, map[string]any{"count": len(tools), "tools": tools})
+}
+
+// callLatency summarizes one tool's calls in the history.
+type callLatency struct {
+	Calls int     `json:"calls"`
+	P50   float64 `json:"p50_ms"`
+	P95   float64 `json:"p95_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+func debugCallsHandler(w http.ResponseWriter, r *http.Request) {
+	calls := recentCalls.list()
+	durations := map[string][]float64{}
+	for _, c := range calls {
+		durations[c.Tool] = append(durations[c.Tool], c.DurationMs)
+	}
+	byTool := map[string]callLatency{}
+	for tool, ds := range durations {
+		sort.Float64s(ds)
+		byTool[tool] = callLatency{Calls: len(ds), P50: percentile(ds, 50), P95: percentile(ds, 95), Max: ds[len(ds)-1]}
+	}
+	writeGatewayJSON(w, http.StatusOK, map[string]any{"count": len(calls), "by_tool": byTool, "calls": calls})
+}
+
+func debugRuntimeHandler(w http.ResponseWriter, r *http.Request) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	writeGatewayJSON(w, http.StatusOK, map[string]any{
+		"version":        version,
+		"go_version":     runtime.Version(),
+		"started":        serverStarted,
+		"uptime_seconds": time.Since(serverStarted).Seconds(),
+		"goroutines":     runtime.NumGoroutine(),
+		"gomaxprocs":     runtime.GOMAXPROCS(0),
+		"num_cpu":        runtime.NumCPU(),
+		"memory": map[string]any{
+			"heap_alloc_bytes":  m.HeapAlloc,
+			"heap_inuse_bytes":  m.HeapInuse,
+			"heap_objects":      m.HeapObjects,
+			"sys_bytes":         m.Sys,
+			"total_alloc_bytes": m.TotalAlloc,
+			"stack_inuse_bytes": m.StackInuse,
+			"num_gc":            m.NumGC,
+			"gc_pause_total_ms": roundMs(time.Duration(m.PauseTotalNs)),
+			"gc_cpu_fraction":   m.GCCPUFraction,
+			"next_gc_bytes":     m.NextGC,
+			"last_gc":           time.Unix(0, int64(m.LastGC)),
+		},
+	})
+}
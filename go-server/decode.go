@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/net/html/charset"
+)
+
+/* ---------- Response decoding (fetch) ---------- */
+
+// fetchAcceptEncoding is sent on every fetch. Setting it explicitly turns
+// off the transport's transparent gzip handling, so decodeBody handles all
+// encodings uniformly.
+const fetchAcceptEncoding = "gzip, deflate, br"
+
+// decodeBody wraps body with decompressors for the Content-Encoding
+// header, applied in reverse order of the listed codings. An empty body
+// (HEAD, 204, 304) is returned as-is whatever the header says.
+func decodeBody(body io.Reader, contentEncoding string) (io.ReadCloser, error) {
+	br := bufio.NewReader(body)
+	if _, err := br.Peek(1); err == io.EOF {
+		return io.NopCloser(br), nil
+	}
+
+	codings := strings.Split(contentEncoding, ",")
+	var r io.ReadCloser = io.NopCloser(br)
+	for i := len(codings) - 1; i >= 0; i-- {
+		var err error
+		switch strings.ToLower(strings.TrimSpace(codings[i])) {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(r)
+		case "deflate":
+			r, err = newDeflateReader(r)
+		case "br":
+			r = io.NopCloser(brotli.NewReader(r))
+		default:
+			return nil, fmt.Errorf("unsupported Content-Encoding %q", codings[i])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", codings[i], err)
+		}
+	}
+	return r, nil
+}
+
+// newDeflateReader accepts both zlib-wrapped deflate (what the spec
+// requires) and raw deflate (what some servers actually send).
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(2)
+	if len(head) == 2 && head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// isTextual reports whether a media type carries text that should be
+// converted to UTF-8.
+func isTextual(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/json", mediaType == "application/xml",
+		mediaType == "application/xhtml+xml", mediaType == "application/javascript",
+		strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	return false
+}
+
+// toUTF8 converts a textual body to UTF-8 using the charset from the
+// Content-Type header, a byte order mark or an HTML <meta> tag. It returns
+// the detected charset name.
+func toUTF8(body []byte, contentType string) ([]byte, string, error) {
+	if !isTextual(contentType) {
+		return body, "", nil
+	}
+	enc, name, _ := charset.DetermineEncoding(body, contentType)
+	if name == "utf-8" {
+		return body, name, nil
+	}
+	decoded, err := io.ReadAll(enc.NewDecoder().Reader(bytes.NewReader(body)))
+	if err != nil {
+		return nil, name, fmt.Errorf("converting %s to UTF-8: %w", name, err)
+	}
+	return decoded, name, nil
+}
+
+// truncateUTF8 cuts b to at most n bytes without splitting a multi-byte
+// character.
+func truncateUTF8(b []byte, n int) []byte {
+	if len(b) <= n {
+		return b
+	}
+	b = b[:n]
+	for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+		if r, size := utf8.DecodeLastRune(b); r != utf8.RuneError || size != 1 {
+			break
+		}
+		b = b[:len(b)-1]
+	}
+	return b
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"golang.org/x/text/language"
+)
+
+/* ---------- Tool: set_defaults ---------- */
+
+// defaultsCapability is the experimental client capability through which
+// defaults can be supplied at initialize time, e.g.
+//
+//	"capabilities": {"experimental": {"defaults": {"timezone": "Europe/Kyiv", "locale": "uk-UA"}}}
+const defaultsCapability = "defaults"
+
+// sessionDefaults are values tools fall back to when the corresponding
+// per-call argument is omitted: timeserver and time_convert use Timezone,
+// fetch sends Locale as Accept-Language.
+type sessionDefaults struct {
+	Timezone string `json:"timezone,omitempty"`
+	Locale   string `json:"locale,omitempty"`
+}
+
+// defaultsStore holds the defaults set with set_defaults, per session.
+type defaultsStore struct {
+	mu       sync.Mutex
+	sessions map[string]sessionDefaults
+}
+
+var defaults = &defaultsStore{sessions: make(map[string]sessionDefaults)}
+
+// lookup returns the defaults for the calling session: values from
+// set_defaults take precedence over those declared at initialize time.
+func (s *defaultsStore) lookup(session *mcp.ServerSession) sessionDefaults {
+	if session == nil {
+		return sessionDefaults{}
+	}
+	d := initDefaults(session)
+	s.mu.Lock()
+	set, ok := s.sessions[session.ID()]
+	s.mu.Unlock()
+	if ok {
+		if set.Timezone != "" {
+			d.Timezone = set.Timezone
+		}
+		if set.Locale != "" {
+			d.Locale = set.Locale
+		}
+	}
+	return d
+}
+
+func (s *defaultsStore) set(sessionID string, d sessionDefaults) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sessionID] = d
+}
+
+func (s *defaultsStore) clear(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionID)
+}
+
+// initDefaults reads the defaults capability from the client's initialize
+// request. Invalid values are ignored rather than failing every call.
+func initDefaults(session *mcp.ServerSession) sessionDefaults {
+	params := session.InitializeParams()
+	if params == nil || params.Capabilities == nil {
+		return sessionDefaults{}
+	}
+	raw, ok := params.Capabilities.Experimental[defaultsCapability].(map[string]any)
+	if !ok {
+		return sessionDefaults{}
+	}
+	var d sessionDefaults
+	if tz, ok := raw["timezone"].(string); ok && validateTimezone(tz) == nil {
+		d.Timezone = tz
+	}
+	if loc, ok := raw["locale"].(string); ok {
+		if tag, err := language.Parse(loc); err == nil {
+			d.Locale = tag.String()
+		}
+	}
+	return d
+}
+
+// sessionLocation returns the timezone given per call, else the session
+// default, else fallback.
+func sessionLocation(req *mcp.CallToolRequest, tz string, fallback *time.Location) (*time.Location, error) {
+	if tz == "" {
+		tz = defaults.lookup(req.Session).Timezone
+	}
+	if tz == "" {
+		return fallback, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %v", tz, err)
+	}
+	return loc, nil
+}
+
+func validateTimezone(tz string) error {
+	_, err := time.LoadLocation(tz)
+	return err
+}
+
+type SetDefaultsArgs struct {
+	Timezone string `json:"timezone,omitempty" jsonschema:"Default IANA timezone for time tools, e.g. Europe/Kyiv"`
+	Locale   string `json:"locale,omitempty" jsonschema:"Default BCP 47 locale, e.g. uk-UA; sent as Accept-Language by fetch"`
+	Clear    bool   `json:"clear,omitempty" jsonschema:"Drop defaults set with this tool (initialize-time defaults remain)"`
+}
+
+// SetDefaultsResult is the structured output of the set_defaults tool.
+type SetDefaultsResult struct {
+	Timezone string `json:"timezone,omitempty" jsonschema:"Effective default timezone"`
+	Locale   string `json:"locale,omitempty" jsonschema:"Effective default locale"`
+}
+
+func SetDefaultsTool(ctx context.Context, req *mcp.CallToolRequest, in SetDefaultsArgs) (*mcp.CallToolResult, any, error) {
+	id := req.Session.ID()
+	if in.Clear {
+		defaults.clear(id)
+	}
+
+	if in.Timezone != "" || in.Locale != "" {
+		d := defaults.lookup(req.Session)
+		if in.Timezone != "" {
+			if err := validateTimezone(in.Timezone); err != nil {
+				return errorResult(fmt.Sprintf("invalid timezone %q: %v", in.Timezone, err)), nil, nil
+			}
+			d.Timezone = in.Timezone
+		}
+		if in.Locale != "" {
+			tag, err := language.Parse(in.Locale)
+			if err != nil {
+				return errorResult(fmt.Sprintf("invalid locale %q: %v", in.Locale, err)), nil, nil
+			}
+			d.Locale = tag.String()
+		}
+		defaults.set(id, d)
+	}
+
+	d := defaults.lookup(req.Session)
+	out := SetDefaultsResult(d)
+	var lines []string
+	for _, kv := range [][2]string{{"timezone", d.Timezone}, {"locale", d.Locale}} {
+		if kv[1] == "" {
+			kv[1] = "(unset)"
+		}
+		lines = append(lines, kv[0]+"="+kv[1])
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: strings.Join(lines, "\n")}},
+	}, out, nil
+}
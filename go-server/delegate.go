@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"mcp-demo-server/pkg/mcpclient"
+)
+
+/* ---------- Tool: delegate ---------- */
+
+const (
+	// samplingTarget asks the calling client's own LLM via sampling.
+	samplingTarget = "sampling"
+	// defaultDelegateTool is the tool called on upstream MCP agents whose
+	// URL has no #tool fragment; another instance of this server answers it.
+	defaultDelegateTool = "delegate"
+	// maxDelegateResponseBytes caps the response collected from an agent.
+	maxDelegateResponseBytes = 65536
+	// delegateTimeout bounds a whole delegation.
+	delegateTimeout = 2 * time.Minute
+	// defaultDelegateMaxTokens is the sampling budget when max_tokens is unset.
+	defaultDelegateMaxTokens = 1024
+)
+
+// delegateAgent is an agent endpoint configured with -delegate-agents.
+type delegateAgent struct {
+	Name string
+	// Kind is "http" for plain agent endpoints and "mcp" for MCP servers.
+	Kind string
+	URL  string
+	// Tool is the upstream tool called for "mcp" agents.
+	Tool string
+}
+
+// delegateAgents is keyed by agent name. Set from -delegate-agents.
+var delegateAgents = map[string]*delegateAgent{}
+
+// parseDelegateAgents parses a comma-separated list of name=URL entries.
+// URLs starting with mcp+http:// or mcp+https:// name an MCP server whose
+// tool (the URL fragment, default "delegate") receives the prompt; other
+// http(s) URLs receive a JSON POST.
+func parseDelegateAgents(list string) (map[string]*delegateAgent, error) {
+	agents := make(map[string]*delegateAgent)
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, rawURL, ok := strings.Cut(entry, "=")
+		if !ok || name == "" || name == samplingTarget {
+			return nil, fmt.Errorf("invalid agent %q (want name=URL; %q is reserved)", entry, samplingTarget)
+		}
+		agent := &delegateAgent{Name: name, Kind: "http", URL: rawURL}
+		if rest, ok := strings.CutPrefix(rawURL, "mcp+"); ok {
+			agent.Kind = "mcp"
+			agent.URL, agent.Tool, _ = strings.Cut(rest, "#")
+			if agent.Tool == "" {
+				agent.Tool = defaultDelegateTool
+			}
+		}
+		if !strings.HasPrefix(agent.URL, "http://") && !strings.HasPrefix(agent.URL, "https://") {
+			return nil, fmt.Errorf("agent %s: URL must be http(s) or mcp+http(s)", name)
+		}
+		agents[name] = agent
+	}
+	return agents, nil
+}
+
+type DelegateArgs struct {
+	Prompt    string `json:"prompt" jsonschema:"Task or question for the other agent"`
+	Context   string `json:"context,omitempty" jsonschema:"Background material passed along with the prompt"`
+	Target    string `json:"target,omitempty" jsonschema:"'sampling' (default) to ask the calling client's model, or the name of a configured agent"`
+	MaxTokens int    `json:"max_tokens,omitempty" jsonschema:"Token budget for sampling (default 1024)"`
+}
+
+// DelegateResult is the structured output of the delegate tool.
+type DelegateResult struct {
+	Target    string `json:"target"`
+	Kind      string `json:"kind" jsonschema:"sampling, http or mcp"`
+	Model     string `json:"model,omitempty" jsonschema:"Model reported by the sampling client"`
+	Response  string `json:"response"`
+	Chunks    int    `json:"chunks" jsonschema:"Number of streamed chunks received (1 for non-streaming targets)"`
+	Truncated bool   `json:"truncated"`
+	ElapsedMs int64  `json:"elapsed_ms"`
+}
+
+// delegateStream forwards response chunks to the caller as progress
+// notifications when the call carried a progress token, and collects
+// them up to maxDelegateResponseBytes.
+type delegateStream struct {
+	ctx     context.Context
+	buf     strings.Builder
+	chunks  int
+	trimmed bool
+}
+
+func (s *delegateStream) add(chunk string) {
+	s.chunks++
+	if room := maxDelegateResponseBytes - s.buf.Len(); len(chunk) > room {
+		chunk = string(truncateUTF8([]byte(chunk), max(room, 0)))
+		s.trimmed = true
+	}
+	s.buf.WriteString(chunk)
+	progressFrom(s.ctx).notify(float64(s.chunks), 0, chunk)
+}
+
+func DelegateTool(ctx context.Context, req *mcp.CallToolRequest, in DelegateArgs) (*mcp.CallToolResult, any, error) {
+	if strings.TrimSpace(in.Prompt) == "" {
+		return errorResult("prompt is required"), nil, nil
+	}
+	target := in.Target
+	if target == "" {
+		target = samplingTarget
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, delegateTimeout)
+	defer cancel()
+
+	out := DelegateResult{Target: target}
+	stream := &delegateStream{ctx: ctx}
+	// Chunks are the progress here; byte counts from the transport would
+	// run ahead of them.
+	ctx = withoutTransferProgress(ctx)
+	start := time.Now()
+	var err error
+	if target == samplingTarget {
+		out.Kind = samplingTarget
+		out.Model, err = delegateSampling(ctx, req, in, stream)
+	} else if agent, ok := delegateAgents[target]; ok {
+		out.Kind = agent.Kind
+		if agent.Kind == "mcp" {
+			err = delegateMCP(ctx, agent, in, stream)
+		} else {
+			err = delegateHTTP(ctx, agent, in, stream)
+		}
+	} else {
+		names := []string{samplingTarget}
+		for name := range delegateAgents {
+			names = append(names, name)
+		}
+		sort.Strings(names[1:])
+		return errorResult(fmt.Sprintf("unknown target %q (available: %s)", target, strings.Join(names, ", "))), nil, nil
+	}
+	if err != nil {
+		return errorResult(fmt.Sprintf("Delegation to %s failed: %v", target, err)), nil, nil
+	}
+
+	out.Response = stream.buf.String()
+	out.Chunks = stream.chunks
+	out.Truncated = stream.trimmed
+	out.ElapsedMs = time.Since(start).Milliseconds()
+
+	text := out.Response
+	if out.Truncated {
+		text += fmt.Sprintf("\n\n[truncated at %d bytes]", maxDelegateResponseBytes)
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: text}},
+	}, out, nil
+}
+
+// delegatePrompt joins the context and prompt into a single message.
+func delegatePrompt(in DelegateArgs) string {
+	if in.Context == "" {
+		return in.Prompt
+	}
+	return "Context:\n" + in.Context + "\n\nTask:\n" + in.Prompt
+}
+
+// checkSampling reports an error unless the calling client declared the
+// sampling capability.
+func checkSampling(req *mcp.CallToolRequest) error {
+	if req.Session == nil {
+		return fmt.Errorf("no client session")
+	}
+	if params := req.Session.InitializeParams(); params == nil || params.Capabilities == nil || params.Capabilities.Sampling == nil {
+		return fmt.Errorf("the calling client does not support sampling")
+	}
+	return nil
+}
+
+// delegateSampling asks the calling client to run the prompt through its
+// own model, returning the model name.
+func delegateSampling(ctx context.Context, req *mcp.CallToolRequest, in DelegateArgs, stream *delegateStream) (string, error) {
+	if err := checkSampling(req); err != nil {
+		return "", err
+	}
+	maxTokens := in.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultDelegateMaxTokens
+	}
+	res, err := req.Session.CreateMessage(ctx, &mcp.CreateMessageParams{
+		MaxTokens: int64(maxTokens),
+		Messages: []*mcp.SamplingMessage{{
+			Role:    "user",
+			Content: &mcp.TextContent{Text: delegatePrompt(in)},
+		}},
+	})
+	if err != nil {
+		return "", err
+	}
+	if text, ok := res.Content.(*mcp.TextContent); ok {
+		stream.add(text.Text)
+	} else {
+		stream.add(fmt.Sprintf("[non-text %T response]", res.Content))
+	}
+	return res.Model, nil
+}
+
+// delegateHTTP POSTs {"prompt","context"} to an agent endpoint. Responses
+// are streamed line by line; for text/event-stream each data: line is a
+// chunk.
+func delegateHTTP(ctx context.Context, agent *delegateAgent, in DelegateArgs, stream *delegateStream) error {
+	body, err := json.Marshal(map[string]string{"prompt": in.Prompt, "context": in.Context})
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, agent.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream, application/json, text/plain")
+	httpReq.Header.Set("User-Agent", fetchUserAgent)
+
+	// The shared client's overall timeout would cut long streams short;
+	// the delegation context bounds the call instead.
+	client := *httpClient
+	client.Timeout = 0
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("agent returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	sse := strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxDelegateResponseBytes)
+	for scanner.Scan() && !stream.trimmed {
+		line := scanner.Text()
+		if sse {
+			data, ok := strings.CutPrefix(line, "data:")
+			if !ok {
+				continue
+			}
+			data = strings.TrimPrefix(data, " ")
+			if data == "[DONE]" {
+				break
+			}
+			stream.add(data)
+			continue
+		}
+		if stream.chunks > 0 {
+			line = "\n" + line
+		}
+		stream.add(line)
+	}
+	return scanner.Err()
+}
+
+// delegateMCP calls a tool on another MCP server with the same arguments
+// (minus target), so delegate calls can be chained across servers.
+func delegateMCP(ctx context.Context, agent *delegateAgent, in DelegateArgs, stream *delegateStream) error {
+	// Share the outbound transport (and its circuit breakers), without
+	// the overall timeout that would cut the session short.
+	httpc := *httpClient
+	httpc.Timeout = 0
+	client, err := mcpclient.Connect(ctx, mcpclient.Options{
+		Endpoint:   agent.URL,
+		HTTPClient: &httpc,
+		Name:       "mcp-server-demo-go",
+		Version:    version,
+	})
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	args := map[string]any{"prompt": in.Prompt}
+	if in.Context != "" {
+		args["context"] = in.Context
+	}
+	if in.MaxTokens > 0 {
+		args["max_tokens"] = in.MaxTokens
+	}
+	result, err := client.CallTool(ctx, agent.Tool, args)
+	if err != nil {
+		return err
+	}
+	stream.add(mcpclient.Text(result))
+	return nil
+}
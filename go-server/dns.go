@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+/* ---------- Outbound DNS cache ---------- */
+
+const (
+	// defaultDNSTTL applies when no record TTL was seen, e.g. for names
+	// answered from /etc/hosts or over TCP.
+	defaultDNSTTL = time.Minute
+	minDNSTTL     = time.Second
+	maxDNSTTL     = time.Hour
+	// defaultDNSNegativeTTL is the default value of -dns-negative-ttl.
+	defaultDNSNegativeTTL = 30 * time.Second
+)
+
+// dnsCache resolves hostnames for every outbound request. Answers are
+// cached for their record TTL, NXDOMAIN answers for NegativeTTL, and
+// pinned hosts never reach DNS. The egress check and the dialer both go
+// through the cache, so a connection always goes to an address that was
+// checked: a name cannot rebind to a private address between the two.
+type dnsCache struct {
+	Pins        map[string][]netip.Addr
+	NegativeTTL time.Duration
+	// Family restricts or orders the addresses dialed (-ip-family) and
+	// Delay staggers parallel attempts (-happy-eyeballs-delay).
+	Family string
+	Delay  time.Duration
+
+	resolver *net.Resolver
+	dialer   *net.Dialer
+
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+	hits    int
+	misses  int
+}
+
+type dnsEntry struct {
+	addrs   []netip.Addr
+	err     error
+	expires time.Time
+}
+
+// dnsResolver is the process-wide cache, configured from flags in main.
+var dnsResolver = newDNSCache()
+
+func newDNSCache() *dnsCache {
+	c := &dnsCache{
+		Pins:        map[string][]netip.Addr{},
+		NegativeTTL: defaultDNSNegativeTTL,
+		Family:      ipFamilyAuto,
+		Delay:       defaultHappyEyeballsDelay,
+		dialer:      &net.Dialer{Timeout: defaultFetchTimeout, KeepAlive: 30 * time.Second},
+		entries:     make(map[string]*dnsEntry),
+	}
+	c.resolver = &net.Resolver{PreferGo: true, Dial: c.dialDNS}
+	return c
+}
+
+// parseDNSPins parses "host=ip" entries separated by commas; repeating a
+// host adds addresses.
+func parseDNSPins(list string) (map[string][]netip.Addr, error) {
+	pins := make(map[string][]netip.Addr)
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, addr, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q: want host=ip", entry)
+		}
+		ip, err := netip.ParseAddr(strings.TrimSpace(addr))
+		if err != nil {
+			return nil, fmt.Errorf("%q: %v", entry, err)
+		}
+		host = normalizeHost(host)
+		pins[host] = append(pins[host], ip)
+	}
+	return pins, nil
+}
+
+func normalizeHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+}
+
+// lookup returns the addresses of host: an IP literal as is, a pinned
+// host's pins, or the cached or freshly resolved answer.
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]netip.Addr, error) {
+	host = normalizeHost(host)
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{ip}, nil
+	}
+	if pinned, ok := c.Pins[host]; ok {
+		return pinned, nil
+	}
+
+	c.mu.Lock()
+	if e, ok := c.entries[host]; ok && time.Now().Before(e.expires) {
+		c.hits++
+		c.mu.Unlock()
+		return e.addrs, e.err
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	rec := &ttlRecorder{}
+	addrs, err := c.resolver.LookupNetIP(context.WithValue(ctx, ttlRecorderKey{}, rec), "ip", host)
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		ttl := defaultDNSTTL
+		if seen, ok := rec.min(); ok {
+			ttl = min(max(seen, minDNSTTL), maxDNSTTL)
+		}
+		for i, a := range addrs {
+			addrs[i] = a.Unmap()
+		}
+		c.store(host, &dnsEntry{addrs: addrs, expires: time.Now().Add(ttl)})
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound && c.NegativeTTL > 0:
+		c.store(host, &dnsEntry{err: err, expires: time.Now().Add(c.NegativeTTL)})
+	}
+	return addrs, err
+}
+
+func (c *dnsCache) store(host string, e *dnsEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Drop expired entries now and then so the map stays bounded by the
+	// set of recently used hosts.
+	if len(c.entries) >= 1024 {
+		c.removeExpired(time.Now())
+	}
+	c.entries[host] = e
+}
+
+// expire drops the entries expired by now, returning how many.
+func (c *dnsCache) expire(now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.removeExpired(now)
+}
+
+func (c *dnsCache) removeExpired(now time.Time) int {
+	n := 0
+	for h, old := range c.entries {
+		if now.After(old.expires) {
+			delete(c.entries, h)
+			n++
+		}
+	}
+	return n
+}
+
+// dialContext is the outbound transport's dialer: it connects to the
+// cached addresses of the host rather than resolving it again, enforces
+// the egress policy on those addresses and races them by family.
+func (c *dnsCache) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := c.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	// The proxy was chosen by the operator and is often on a private
+	// network; the policy applies to the hosts behind it.
+	if egress.DenyPrivate && !fetchProxy.isProxyAddr(address) {
+		for _, ip := range addrs {
+			if !isPublicAddr(ip) {
+				return nil, fmt.Errorf("host %s resolves to non-public address %s", host, ip)
+			}
+		}
+	}
+	ordered := orderAddrs(addrs, c.Family)
+	if len(ordered) == 0 {
+		if len(addrs) > 0 {
+			return nil, fmt.Errorf("host %s has no %s address (-ip-family=%s)", host, c.Family, c.Family)
+		}
+		return nil, fmt.Errorf("no addresses for %s", host)
+	}
+	return c.dialHappyEyeballs(ctx, network, port, ordered)
+}
+
+// dialDNS connects the Go resolver to a nameserver. UDP answers are
+// inspected on the way in to learn their TTLs.
+func (c *dnsCache) dialDNS(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := c.dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	rec, ok := ctx.Value(ttlRecorderKey{}).(*ttlRecorder)
+	udp, isUDP := conn.(*net.UDPConn)
+	if !ok || !isUDP {
+		return conn, nil
+	}
+	return &ttlConn{UDPConn: udp, rec: rec}, nil
+}
+
+type ttlRecorderKey struct{}
+
+// ttlRecorder collects the smallest answer TTL seen during one lookup,
+// which may query A and AAAA records concurrently.
+type ttlRecorder struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	seen bool
+}
+
+func (r *ttlRecorder) observe(ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.seen || ttl < r.ttl {
+		r.ttl, r.seen = ttl, true
+	}
+}
+
+func (r *ttlRecorder) min() (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ttl, r.seen
+}
+
+// ttlConn is a UDP nameserver connection; each Read is one DNS message.
+// It embeds *net.UDPConn so the resolver still sees a net.PacketConn and
+// speaks the datagram protocol to it.
+type ttlConn struct {
+	*net.UDPConn
+	rec *ttlRecorder
+}
+
+func (c *ttlConn) Read(b []byte) (int, error) {
+	n, err := c.UDPConn.Read(b)
+	if n > 0 {
+		var p dnsmessage.Parser
+		if _, perr := p.Start(b[:n]); perr == nil && p.SkipAllQuestions() == nil {
+			for {
+				h, herr := p.AnswerHeader()
+				if herr != nil {
+					break
+				}
+				c.rec.observe(time.Duration(h.TTL) * time.Second)
+				if p.SkipAnswer() != nil {
+					break
+				}
+			}
+		}
+	}
+	return n, err
+}
+
+// collectMetrics exports cache counters for /metrics.
+func (c *dnsCache) collectMetrics(w *metricsWriter) {
+	c.mu.Lock()
+	hits, misses, entries := c.hits, c.misses, len(c.entries)
+	c.mu.Unlock()
+	w.family("mcp_dns_cache_hits_total", "counter", "Outbound DNS lookups answered from the cache")
+	w.sample("mcp_dns_cache_hits_total", float64(hits))
+	w.family("mcp_dns_cache_misses_total", "counter", "Outbound DNS lookups sent to the resolver")
+	w.sample("mcp_dns_cache_misses_total", float64(misses))
+	w.family("mcp_dns_cache_entries", "gauge", "Cached DNS answers, including negative ones")
+	w.sample("mcp_dns_cache_entries", float64(entries))
+}
+
+// newOutboundTransport is the base transport of httpClient: the default
+// transport, dialing through dnsResolver. A browser build keeps the
+// default dialer, since any other would stop requests from going through
+// the Fetch API. Requests go through fetchProxy, if any, and responses
+// whose headers exceed maxHeaderBytes are refused.
+func newOutboundTransport(maxHeaderBytes int64) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxResponseHeaderBytes = maxHeaderBytes
+	if !browserBuild {
+		t.DialContext = dnsResolver.dialContext
+		t.Proxy = nil
+		if fetchProxy != nil {
+			t.Proxy = fetchProxy.proxyFunc
+		}
+	}
+	return t
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Tool: download ---------- */
+
+const (
+	// defaultDownloadMaxBytes, defaultDownloadQuota and
+	// defaultDownloadTimeout are the defaults of -download-max-size,
+	// -download-quota and -download-timeout.
+	defaultDownloadMaxBytes = 100 << 20
+	defaultDownloadQuota    = 1 << 30
+	defaultDownloadTimeout  = 5 * time.Minute
+)
+
+// downloadSettings are set from flags in main.
+var downloadSettings = struct {
+	// MaxBytes is the largest file a call may download.
+	MaxBytes int64
+	// Timeout replaces the fetch timeout for downloads.
+	Timeout time.Duration
+}{MaxBytes: defaultDownloadMaxBytes, Timeout: defaultDownloadTimeout}
+
+type DownloadArgs struct {
+	URL     string            `json:"url" jsonschema:"URL to download with GET (must be http or https)"`
+	Headers map[string]string `json:"headers,omitempty" jsonschema:"Request headers to send (only server-allowlisted names are accepted)"`
+	// Redirect handling; nil means follow.
+	FollowRedirects *bool `json:"follow_redirects,omitempty" jsonschema:"Follow HTTP redirects (default true)"`
+	// MaxBytes can only lower the server's -download-max-size.
+	MaxBytes int64 `json:"max_bytes,omitempty" jsonschema:"Fail when the file is larger than this (default and max: the server's download limit, 100 MiB unless configured otherwise)"`
+}
+
+// DownloadResult is the structured output of the download tool.
+type DownloadResult struct {
+	URL         string `json:"url"`
+	FinalURL    string `json:"final_url" jsonschema:"URL of the response after following redirects"`
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type"`
+	Bytes       int64  `json:"bytes" jsonschema:"Size of the downloaded file, after decompression"`
+	SHA256      string `json:"sha256" jsonschema:"SHA-256 of the file, hex encoded"`
+	ElapsedMs   int64  `json:"elapsed_ms" jsonschema:"Time from sending the request to storing the file, in milliseconds"`
+	Resource    string `json:"resource" jsonschema:"URI of the resource holding the file; read it with resources/read"`
+}
+
+// DownloadTool streams a URL to a temporary file and stores it as an
+// artifact, so a client can work with files far larger than a fetch
+// result. The result links to the resource instead of carrying the bytes.
+func DownloadTool(ctx context.Context, req *mcp.CallToolRequest, in DownloadArgs) (*mcp.CallToolResult, any, error) {
+	if in.URL == "" {
+		return errorResult("URL is required"), nil, nil
+	}
+	if !strings.HasPrefix(in.URL, "http://") && !strings.HasPrefix(in.URL, "https://") {
+		return errorResult("URL must start with http:// or https://"), nil, nil
+	}
+	target, err := url.Parse(in.URL)
+	if err != nil {
+		return errorResult("Invalid URL: " + err.Error()), nil, nil
+	}
+	if err := egress.Check(ctx, target); err != nil {
+		return errorResult("URL not allowed: " + err.Error()), nil, nil
+	}
+	if rejected := fetchSettings.Load().disallowedHeaders(in.Headers); len(rejected) > 0 {
+		notePolicyBlocked(ctx)
+		return errorResult("headers not allowed: " + strings.Join(rejected, ", ")), nil, nil
+	}
+	if in.MaxBytes < 0 {
+		return errorResult("max_bytes must be positive"), nil, nil
+	}
+	limit := downloadSettings.MaxBytes
+	if in.MaxBytes > 0 {
+		limit = min(in.MaxBytes, limit)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, in.URL, nil)
+	if err != nil {
+		return errorResult("Invalid URL: " + err.Error()), nil, nil
+	}
+	httpReq.Header.Set("User-Agent", fetchUserAgent)
+	httpReq.Header.Set("Accept-Encoding", fetchAcceptEncoding)
+	for name, value := range in.Headers {
+		httpReq.Header.Set(name, value)
+	}
+
+	var redirects []string
+	client := redirectingClient(in.FollowRedirects == nil || *in.FollowRedirects, defaultMaxRedirects, &redirects)
+	client.Timeout = downloadSettings.Timeout
+
+	start := time.Now()
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return errorResult("Download error: " + err.Error()), nil, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errorResult(fmt.Sprintf("Download error: %s returned %s", resp.Request.URL, resp.Status)), nil, nil
+	}
+	if resp.ContentLength > limit && resp.Header.Get("Content-Encoding") == "" {
+		return errorResult(fmt.Sprintf("Download error: file is %s, over the limit of %s", formatBytes(resp.ContentLength), formatBytes(limit))), nil, nil
+	}
+
+	decoded, err := decodeBody(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return errorResult("Decode error: " + err.Error()), nil, nil
+	}
+	defer decoded.Close()
+	file, size, sum, err := saveDownload(decoded, limit)
+	if err != nil {
+		return errorResult("Download error: " + err.Error()), nil, nil
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mimeType := "application/octet-stream"
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		mimeType = mediaType
+	}
+	name := path.Base(resp.Request.URL.Path)
+	if name == "/" || name == "." {
+		name = resp.Request.URL.Host
+	}
+	uri, err := artifacts.addFile("download", name+" from "+resp.Request.URL.Host, mimeType, file, size)
+	if err != nil {
+		return errorResult("Download error: file not stored: " + err.Error()), nil, nil
+	}
+
+	out := DownloadResult{
+		URL:         in.URL,
+		FinalURL:    resp.Request.URL.String(),
+		StatusCode:  resp.StatusCode,
+		ContentType: contentType,
+		Bytes:       size,
+		SHA256:      sum,
+		ElapsedMs:   time.Since(start).Milliseconds(),
+		Resource:    uri,
+	}
+	redirectNote := ""
+	if len(redirects) > 0 {
+		redirectNote = fmt.Sprintf("\nFinal URL: %s (after %d redirects)", out.FinalURL, len(redirects))
+	}
+	text := fmt.Sprintf("Downloaded %s%s\nContent-Type: %s\nBytes: %d (%s)\nSHA-256: %s\nElapsed: %dms\nResource: %s",
+		out.URL, redirectNote, out.ContentType, out.Bytes, formatBytes(out.Bytes), out.SHA256, out.ElapsedMs, out.Resource)
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: text},
+			&mcp.ResourceLink{URI: uri, Name: name, Title: name, MIMEType: mimeType, Size: &size,
+				Description: "Downloaded from " + out.FinalURL},
+		},
+	}, out, nil
+}
+
+// saveDownload copies body to a new temporary file, failing once it
+// passes limit bytes. It returns the file's path, size and SHA-256.
+func saveDownload(body io.Reader, limit int64) (string, int64, string, error) {
+	f, err := os.CreateTemp("", "mcp-download-*")
+	if err != nil {
+		return "", 0, "", err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), io.LimitReader(body, limit+1))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > limit {
+		err = errors.New("file is over the limit of " + formatBytes(limit))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", 0, "", err
+	}
+	return f.Name(), n, hex.EncodeToString(h.Sum(nil)), nil
+}
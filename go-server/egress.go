@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+/* ---------- Outbound (SSRF) policy ---------- */
+
+// egressPolicy decides which URLs outbound network tools may contact. It
+// is applied to the initial URL and again to every redirect hop.
+type egressPolicy struct {
+	// DenyPrivate rejects hosts that resolve to loopback, private,
+	// link-local or otherwise non-public addresses.
+	DenyPrivate bool
+	// AllowHosts, when non-empty, restricts requests to these hosts and
+	// their subdomains.
+	AllowHosts []string
+}
+
+// egress is the process-wide policy, configured from flags in main.
+var egress = &egressPolicy{}
+
+// Check validates a URL against the policy, noting the outcome for the
+// anomaly detector.
+func (p *egressPolicy) Check(ctx context.Context, u *url.URL) error {
+	if err := p.check(ctx, u); err != nil {
+		notePolicyBlocked(ctx)
+		return err
+	}
+	noteEgressHost(ctx, u.Hostname())
+	return nil
+}
+
+func (p *egressPolicy) check(ctx context.Context, u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("URL scheme %q not allowed (must be http or https)", u.Scheme)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("URL has no host")
+	}
+	if len(p.AllowHosts) > 0 && !p.hostAllowed(host) {
+		return fmt.Errorf("host %s is not on the allowlist", host)
+	}
+	if !p.DenyPrivate {
+		return nil
+	}
+
+	// The dialer uses the same cached answer, so the checked addresses are
+	// the ones connected to.
+	addrs, err := dnsResolver.lookup(ctx, host)
+	if err != nil {
+		// Behind a proxy, the local resolver often knows only internal
+		// names; the proxy resolves the others.
+		if fetchProxy.proxied(u) {
+			return nil
+		}
+		return fmt.Errorf("resolving %s: %w", host, err)
+	}
+	for _, ip := range addrs {
+		if !isPublicAddr(ip) {
+			return fmt.Errorf("host %s resolves to non-public address %s", host, ip)
+		}
+	}
+	return nil
+}
+
+// hostAllowed reports whether host equals an AllowHosts entry or is a
+// subdomain of one.
+func (p *egressPolicy) hostAllowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, allowed := range p.AllowHosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPublicAddr reports whether ip is a globally routable unicast address.
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() &&
+		!ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsUnspecified()
+}
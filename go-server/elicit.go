@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Tool: elicit ---------- */
+
+const (
+	elicitProfile = "profile"
+	elicitConfirm = "confirm"
+	elicitAPIKey  = "api_key"
+	// elicitNone is the action reported when nothing had to be asked.
+	elicitNone = "none"
+)
+
+// elicitRoles are the choices offered for the profile's role field.
+var elicitRoles = []any{"developer", "operator", "tester"}
+
+type ElicitArgs struct {
+	Scenario string `json:"scenario,omitempty" jsonschema:"profile (default): ask only for the profile fields not given as arguments; confirm: ask the user to confirm an action; api_key: ask for a secret"`
+	Name     string `json:"name,omitempty" jsonschema:"Profile name; asked for when empty"`
+	Email    string `json:"email,omitempty" jsonschema:"Profile email address; asked for when empty"`
+	Age      int    `json:"age,omitempty" jsonschema:"Profile age; asked for when zero"`
+	Role     string `json:"role,omitempty" jsonschema:"Profile role (developer, operator or tester); asked for when empty"`
+	Action   string `json:"action,omitempty" jsonschema:"What the confirm scenario asks about (default: delete the demo records)"`
+}
+
+// ElicitResult is the structured output of the elicit tool.
+type ElicitResult struct {
+	Scenario string         `json:"scenario"`
+	Action   string         `json:"action" jsonschema:"The user's answer: accept, decline or cancel; none when nothing had to be asked"`
+	Asked    []string       `json:"asked" jsonschema:"Fields requested from the user"`
+	Values   map[string]any `json:"values" jsonschema:"Arguments merged with the accepted answers; secrets are masked"`
+	Outcome  string         `json:"outcome"`
+}
+
+// ElicitTool demonstrates elicitation/create: it asks the user, through
+// the calling client, for input the call did not carry and reports what
+// came back. The server never returns a secret it was given, only a
+// masked form and a fingerprint.
+func ElicitTool(ctx context.Context, req *mcp.CallToolRequest, in ElicitArgs) (*mcp.CallToolResult, any, error) {
+	scenario := strings.ToLower(strings.TrimSpace(in.Scenario))
+	if scenario == "" {
+		scenario = elicitProfile
+	}
+	var (
+		message string
+		schema  *jsonschema.Schema
+		values  = map[string]any{}
+	)
+	switch scenario {
+	case elicitProfile:
+		message, schema = profileElicitation(in, values)
+	case elicitConfirm:
+		action := in.Action
+		if action == "" {
+			action = "delete the demo records"
+		}
+		values["action"] = action
+		message = fmt.Sprintf("Do you want to %s? Nothing is actually changed; this is a demo.", action)
+		schema = &jsonschema.Schema{
+			Type: "object",
+			Properties: map[string]*jsonschema.Schema{
+				"confirm": {Type: "boolean", Title: "Confirm", Description: "Check to go ahead", Default: []byte("false")},
+			},
+			Required: []string{"confirm"},
+		}
+	case elicitAPIKey:
+		message = "Enter an API key for the demo service. The server keeps only a fingerprint and never echoes the key."
+		schema = &jsonschema.Schema{
+			Type: "object",
+			Properties: map[string]*jsonschema.Schema{
+				"api_key": {Type: "string", Title: "API key", MinLength: jsonschema.Ptr(8)},
+			},
+			Required: []string{"api_key"},
+		}
+	default:
+		return errorResult(fmt.Sprintf("unknown scenario %q (want profile, confirm or api_key)", in.Scenario)), nil, nil
+	}
+
+	out := ElicitResult{Scenario: scenario, Action: elicitNone, Asked: []string{}, Values: values}
+	if len(schema.Properties) == 0 {
+		out.Outcome = "All profile fields were given; nothing to ask"
+		return elicitResult(out), out, nil
+	}
+	out.Asked = sortedKeys(schema.Properties)
+	if err := checkElicitation(req); err != nil {
+		return errorResult(fmt.Sprintf("%v; missing: %s", err, strings.Join(out.Asked, ", "))), nil, nil
+	}
+
+	res, err := req.Session.Elicit(ctx, &mcp.ElicitParams{Message: message, RequestedSchema: schema})
+	if err != nil {
+		return errorResult("Elicitation failed: " + err.Error()), nil, nil
+	}
+	out.Action = res.Action
+	if res.Action != "accept" {
+		out.Outcome = fmt.Sprintf("The user chose to %s; nothing was done", res.Action)
+		return elicitResult(out), out, nil
+	}
+	for _, name := range out.Asked {
+		v, ok := res.Content[name]
+		if !ok {
+			continue
+		}
+		if name == "age" {
+			// JSON numbers arrive as float64.
+			if f, ok := v.(float64); ok {
+				v = int(f)
+			}
+		}
+		values[name] = v
+	}
+
+	switch scenario {
+	case elicitConfirm:
+		if confirmed, _ := values["confirm"].(bool); confirmed {
+			out.Outcome = fmt.Sprintf("Confirmed: would %s now", values["action"])
+		} else {
+			out.Outcome = "Accepted without confirming; nothing was done"
+		}
+	case elicitAPIKey:
+		key, _ := values["api_key"].(string)
+		values["api_key"] = maskSecret(key)
+		sum := sha256.Sum256([]byte(key))
+		values["fingerprint"] = "sha256:" + hex.EncodeToString(sum[:6])
+		out.Outcome = fmt.Sprintf("Received a %d-character key", len(key))
+	default:
+		out.Outcome = "Profile complete"
+		if missing := missingProfileFields(values); len(missing) > 0 {
+			out.Outcome = "Profile still missing: " + strings.Join(missing, ", ")
+		}
+	}
+	return elicitResult(out), out, nil
+}
+
+// profileElicitation copies the profile fields given as arguments into
+// values and returns a form for the rest.
+func profileElicitation(in ElicitArgs, values map[string]any) (string, *jsonschema.Schema) {
+	props := map[string]*jsonschema.Schema{}
+	if in.Name != "" {
+		values["name"] = in.Name
+	} else {
+		props["name"] = &jsonschema.Schema{Type: "string", Title: "Name", MinLength: jsonschema.Ptr(1)}
+	}
+	if in.Email != "" {
+		values["email"] = in.Email
+	} else {
+		props["email"] = &jsonschema.Schema{Type: "string", Title: "Email", Format: "email"}
+	}
+	if in.Age > 0 {
+		values["age"] = in.Age
+	} else {
+		props["age"] = &jsonschema.Schema{Type: "integer", Title: "Age", Minimum: jsonschema.Ptr(1.0), Maximum: jsonschema.Ptr(150.0)}
+	}
+	if in.Role != "" {
+		values["role"] = in.Role
+	} else {
+		props["role"] = &jsonschema.Schema{Type: "string", Title: "Role", Enum: elicitRoles}
+	}
+	schema := &jsonschema.Schema{Type: "object", Properties: props, Required: sortedKeys(props)}
+	return "Please complete your profile: " + strings.Join(schema.Required, ", "), schema
+}
+
+func missingProfileFields(values map[string]any) []string {
+	var missing []string
+	for _, name := range []string{"name", "email", "age", "role"} {
+		if _, ok := values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// checkElicitation reports an error unless the calling client declared
+// the elicitation capability.
+func checkElicitation(req *mcp.CallToolRequest) error {
+	if req.Session == nil {
+		return fmt.Errorf("no client session")
+	}
+	if params := req.Session.InitializeParams(); params == nil || params.Capabilities == nil || params.Capabilities.Elicitation == nil {
+		return fmt.Errorf("the calling client does not support elicitation")
+	}
+	return nil
+}
+
+// maskSecret keeps the last four characters of longer secrets.
+func maskSecret(s string) string {
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+	return strings.Repeat("*", len(s)-4) + s[len(s)-4:]
+}
+
+func elicitResult(out ElicitResult) *mcp.CallToolResult {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Scenario: %s\nAction: %s\n%s", out.Scenario, out.Action, out.Outcome)
+	for _, k := range sortedKeys(out.Values) {
+		fmt.Fprintf(&b, "\n  %s: %v", k, out.Values[k])
+	}
+	return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: b.String()}}}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"mcp-demo-server/pkg/payloadcrypt"
+)
+
+/* ---------- Payload encryption ---------- */
+
+// encryptionKey is non-nil when -encrypt-payloads is set. Sessions then
+// agree on a key in initialize, and the params and results of the
+// requests in payloadcrypt.Methods travel encrypted, so that a proxy
+// terminating TLS in front of the server only sees envelopes.
+var encryptionKey *ecdh.PrivateKey
+
+// maxEncryptedSessions bounds the session keys kept.
+const maxEncryptedSessions = 1024
+
+// loadEncryptionKey reads a PKCS#8 PEM X25519 private key, as written by
+// `openssl genpkey -algorithm x25519`. An empty path generates a key
+// that lasts for this process only.
+func loadEncryptionKey(path string) (*ecdh.PrivateKey, error) {
+	if path == "" {
+		return ecdh.X25519().GenerateKey(rand.Reader)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	priv, ok := key.(*ecdh.PrivateKey)
+	if !ok || priv.Curve() != ecdh.X25519() {
+		return nil, fmt.Errorf("want an X25519 key, got %T", key)
+	}
+	return priv, nil
+}
+
+func encryptionPublicKey() payloadcrypt.PublicKey {
+	return payloadcrypt.Describe(encryptionKey.PublicKey())
+}
+
+// encryptedSessions holds the key each session agreed on.
+type encryptedSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*sessionKey
+}
+
+type sessionKey struct {
+	session  *payloadcrypt.Session
+	lastUsed time.Time
+}
+
+var sessionKeys = &encryptedSessions{sessions: make(map[string]*sessionKey)}
+
+func (s *encryptedSessions) get(id string) *payloadcrypt.Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.sessions[id]
+	if !ok {
+		return nil
+	}
+	k.lastUsed = time.Now()
+	return k.session
+}
+
+func (s *encryptedSessions) set(id string, session *payloadcrypt.Session) {
+	live := liveSessions.ids()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[id]; !ok && len(s.sessions) >= maxEncryptedSessions {
+		// Sessions end without telling us: drop the keys of those that
+		// have, and if all are open, the key unused the longest. That
+		// session has to reconnect.
+		if s.retainLocked(live) == 0 {
+			oldest := ""
+			for sid, k := range s.sessions {
+				if oldest == "" || k.lastUsed.Before(s.sessions[oldest].lastUsed) {
+					oldest = sid
+				}
+			}
+			delete(s.sessions, oldest)
+		}
+	}
+	s.sessions[id] = &sessionKey{session: session, lastUsed: time.Now()}
+}
+
+// retain drops the keys of sessions not in live, returning how many.
+func (s *encryptedSessions) retain(live map[string]bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.retainLocked(live)
+}
+
+func (s *encryptedSessions) retainLocked(live map[string]bool) int {
+	n := 0
+	for id := range s.sessions {
+		if !live[id] {
+			delete(s.sessions, id)
+			n++
+		}
+	}
+	return n
+}
+
+// encryptionRequired reports whether a session must encrypt. Sessions
+// that reach the server over the network must; the stdio client and
+// in-process sessions (REST gateway, browser), which no proxy sits in
+// front of, may.
+func encryptionRequired(ss *mcp.ServerSession) bool {
+	return ss.ID() != "" && ss.ID() != stdioSessionID
+}
+
+// clientEncryptionKey returns the public key a client offered in
+// initialize, or nil.
+func clientEncryptionKey(params *mcp.InitializeParams) (*ecdh.PublicKey, error) {
+	if params == nil || params.Capabilities == nil {
+		return nil, nil
+	}
+	raw, ok := params.Capabilities.Experimental[payloadcrypt.CapabilityKey]
+	if !ok {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var doc payloadcrypt.PublicKey
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc.Decode()
+}
+
+// initializeEncryption agrees on the session key and confirms it to the
+// client with the server's public key.
+func initializeEncryption(ctx context.Context, next mcp.MethodHandler, method string, req *mcp.ServerRequest[*mcp.InitializeParams]) (mcp.Result, error) {
+	ss := req.Session
+	clientKey, err := clientEncryptionKey(req.Params)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", payloadcrypt.CapabilityKey, err)
+	}
+	if clientKey == nil || ss.ID() == "" {
+		if encryptionRequired(ss) {
+			log.Printf("[ENCRYPT] Refused session %s: client did not offer payload encryption", ss.ID())
+			return nil, fmt.Errorf("this server requires payload encryption (experimental capability %s, key at %s)", payloadcrypt.CapabilityKey, payloadcrypt.WellKnownPath)
+		}
+		return next(ctx, method, req)
+	}
+	session, err := payloadcrypt.NewSession(encryptionKey, clientKey, clientKey, encryptionKey.PublicKey())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", payloadcrypt.CapabilityKey, err)
+	}
+	result, err := next(ctx, method, req)
+	if err != nil {
+		return result, err
+	}
+	if res, ok := result.(*mcp.InitializeResult); ok {
+		if res.Capabilities == nil {
+			res.Capabilities = &mcp.ServerCapabilities{}
+		}
+		if res.Capabilities.Experimental == nil {
+			res.Capabilities.Experimental = make(map[string]any)
+		}
+		res.Capabilities.Experimental[payloadcrypt.CapabilityKey] = encryptionPublicKey()
+		sessionKeys.set(ss.ID(), session)
+		if logEnabled(logDebug) {
+			log.Printf("[ENCRYPT] Session %s: client key %s", ss.ID(), payloadcrypt.KeyID(clientKey))
+		}
+	}
+	return result, nil
+}
+
+// encryptionMiddleware decrypts requests on their way in and encrypts
+// results on their way out. It must be the outermost receiving
+// middleware, so that every other middleware sees plaintext and signing
+// covers the plaintext result.
+func encryptionMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		ss, ok := req.GetSession().(*mcp.ServerSession)
+		if !ok {
+			return next(ctx, method, req)
+		}
+		if init, ok := req.(*mcp.ServerRequest[*mcp.InitializeParams]); ok {
+			return initializeEncryption(ctx, next, method, init)
+		}
+		if !payloadcrypt.Methods[method] {
+			return next(ctx, method, req)
+		}
+		session := sessionKeys.get(ss.ID())
+		if session == nil {
+			if encryptionRequired(ss) {
+				return nil, fmt.Errorf("%s: session has no encryption key; reconnect", method)
+			}
+			return next(ctx, method, req)
+		}
+		seq, err := session.OpenParams(method, req.GetParams())
+		if err != nil {
+			log.Printf("[ENCRYPT] Session %s: %s: %v", ss.ID(), method, err)
+			return nil, fmt.Errorf("%s: %w", method, err)
+		}
+		result, err := next(ctx, method, req)
+		if err != nil {
+			return result, err
+		}
+		if err := session.SealResult(method, seq, result); err != nil {
+			return nil, fmt.Errorf("encrypting result: %w", err)
+		}
+		return result, nil
+	}
+}
+
+// encryptionKeyHandler serves the public key at payloadcrypt.WellKnownPath.
+func encryptionKeyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(encryptionPublicKey())
+}
+
+// logEncryption prints the key clients should be configured with.
+func logEncryption(ephemeral bool) {
+	key := encryptionPublicKey()
+	log.Printf("Payload encryption: X25519 key_id=%s public_key=%s", key.KeyID, key.Key)
+	if ephemeral {
+		log.Printf("Payload encryption: using a key generated for this run; pass -encrypt-key to keep it across restarts")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/* ---------- Internal event bus and webhook alerts ---------- */
+
+// maxPendingAlerts is how many events the webhook sink queues before it
+// drops new ones.
+const maxPendingAlerts = 100
+
+// serverEvent is something operators may want to hear about, such as an
+// anomaly in tool usage.
+type serverEvent struct {
+	Time     time.Time      `json:"time"`
+	Kind     string         `json:"kind"`
+	Severity string         `json:"severity"` // info, warning or critical
+	Message  string         `json:"message"`
+	Fields   map[string]any `json:"fields,omitempty"`
+}
+
+// eventBus delivers every published event to all subscribers, in order.
+// Subscribers run on the publisher's goroutine and must not block.
+type eventBus struct {
+	mu   sync.Mutex
+	subs []func(serverEvent)
+}
+
+var events = &eventBus{}
+
+func (b *eventBus) subscribe(fn func(serverEvent)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, fn)
+}
+
+func (b *eventBus) publish(e serverEvent) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	b.mu.Lock()
+	subs := b.subs
+	b.mu.Unlock()
+	for _, fn := range subs {
+		fn(e)
+	}
+}
+
+// webhookSink posts events as JSON to -alert-webhook. The body carries a
+// "text" line, so a Slack incoming webhook URL works as is, and the event
+// itself under "event" for other receivers.
+type webhookSink struct {
+	url     string
+	client  *http.Client
+	queue   chan serverEvent
+	mu      sync.Mutex
+	sent    int
+	failed  int
+	dropped int
+}
+
+// newWebhookSink starts the goroutine that delivers queued events.
+// Delivery does not go through the egress policy: the operator chose the
+// URL.
+func newWebhookSink(url string) *webhookSink {
+	s := &webhookSink{url: url, client: &http.Client{Timeout: 10 * time.Second}, queue: make(chan serverEvent, maxPendingAlerts)}
+	go func() {
+		for e := range s.queue {
+			err := s.post(e)
+			s.mu.Lock()
+			if err != nil {
+				s.failed++
+			} else {
+				s.sent++
+			}
+			s.mu.Unlock()
+			if err != nil {
+				log.Printf("[ALERTS] Webhook delivery of %s failed: %v", e.Kind, err)
+			}
+		}
+	}()
+	return s
+}
+
+// enqueue is the bus subscriber; it drops the event when the queue is
+// full rather than hold up the tool call that raised it.
+func (s *webhookSink) enqueue(e serverEvent) {
+	select {
+	case s.queue <- e:
+	default:
+		s.mu.Lock()
+		s.dropped++
+		s.mu.Unlock()
+	}
+}
+
+func (s *webhookSink) post(e serverEvent) error {
+	body, err := json.Marshal(map[string]any{
+		"text":  fmt.Sprintf("[mcp-demo %s] %s", e.Severity, e.Message),
+		"event": e,
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *webhookSink) collectMetrics(w *metricsWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.family("mcp_alert_webhook_total", "counter", "Events posted to -alert-webhook, by result")
+	w.sample("mcp_alert_webhook_total", float64(s.sent), "result", "sent")
+	w.sample("mcp_alert_webhook_total", float64(s.failed), "result", "failed")
+	w.sample("mcp_alert_webhook_total", float64(s.dropped), "result", "dropped")
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Tool: exec ---------- */
+
+const (
+	// defaultExecAllow is the default value of -exec-allow.
+	defaultExecAllow = "date,uname,uptime,hostname,whoami,id,df,echo"
+	// execPath is the only PATH commands are looked up in and run with.
+	execPath = "/usr/local/bin:/usr/bin:/bin"
+	// defaultExecTimeout and maxExecTimeout bound timeout_seconds.
+	defaultExecTimeout = 10 * time.Second
+	maxExecTimeout     = 60 * time.Second
+	// maxExecOutputBytes caps each of stdout and stderr.
+	maxExecOutputBytes = 65536
+	// maxExecArgs and maxExecArgBytes bound the argument list.
+	maxExecArgs     = 32
+	maxExecArgBytes = 1024
+)
+
+// execCommands maps allowlisted command names to resolved executable
+// paths. Set from -exec-allow when -enable-exec is given.
+var execCommands map[string]string
+
+// parseExecAllow resolves a comma-separated command allowlist against
+// execPath. Entries may be bare names or name=/absolute/path.
+func parseExecAllow(list string) (map[string]string, error) {
+	commands := make(map[string]string)
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, path, explicit := strings.Cut(entry, "=")
+		if !explicit {
+			var err error
+			if path, err = lookExecPath(name); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		} else if !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("%s: path %q must be absolute", name, path)
+		}
+		commands[name] = path
+	}
+	if len(commands) == 0 {
+		return nil, errors.New("allowlist is empty")
+	}
+	return commands, nil
+}
+
+// lookExecPath finds name in execPath rather than the server's own PATH.
+func lookExecPath(name string) (string, error) {
+	if strings.ContainsRune(name, '/') {
+		return "", errors.New("command names must not contain '/'; use name=/path")
+	}
+	for _, dir := range strings.Split(execPath, ":") {
+		path := dir + "/" + name
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() && info.Mode()&0o111 != 0 {
+			return path, nil
+		}
+	}
+	return "", exec.ErrNotFound
+}
+
+// execEnv is the complete environment of spawned commands; nothing from
+// the server's environment (tokens, credentials) is inherited.
+func execEnv(dir string) []string {
+	return []string{"PATH=" + execPath, "LANG=C.UTF-8", "HOME=" + dir}
+}
+
+// execDir is the working directory for commands: the filesystem sandbox
+// root when one is configured, otherwise the temp directory.
+func execDir() string {
+	if fsSandbox != nil {
+		return fsSandbox.Root
+	}
+	return os.TempDir()
+}
+
+// limitedBuffer keeps the first max bytes written and discards the rest,
+// so a chatty command cannot exhaust memory.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		b.buf.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+type ExecArgs struct {
+	Command        string   `json:"command" jsonschema:"Name of an allowlisted command"`
+	Args           []string `json:"args,omitempty" jsonschema:"Arguments passed directly to the command (no shell expansion)"`
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty" jsonschema:"Kill the command after this many seconds (default 10, max 60)"`
+}
+
+// ExecResult is the structured output of the exec tool.
+type ExecResult struct {
+	Command         string   `json:"command"`
+	Args            []string `json:"args"`
+	ExitCode        int      `json:"exit_code" jsonschema:"Process exit code, or -1 if it was killed"`
+	TimedOut        bool     `json:"timed_out"`
+	ElapsedMs       int64    `json:"elapsed_ms"`
+	Stdout          string   `json:"stdout"`
+	Stderr          string   `json:"stderr"`
+	StdoutTruncated bool     `json:"stdout_truncated"`
+	StderrTruncated bool     `json:"stderr_truncated"`
+}
+
+// validateExecArgs rejects oversized or control-character arguments.
+// Commands are never run through a shell, so metacharacters are inert.
+func validateExecArgs(args []string) error {
+	if len(args) > maxExecArgs {
+		return fmt.Errorf("too many arguments (%d, max %d)", len(args), maxExecArgs)
+	}
+	for i, arg := range args {
+		if len(arg) > maxExecArgBytes {
+			return fmt.Errorf("argument %d too long (%d bytes, max %d)", i+1, len(arg), maxExecArgBytes)
+		}
+		for _, r := range arg {
+			if r < 0x20 || r == 0x7f {
+				return fmt.Errorf("argument %d contains a control character", i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// execArgPolicies check the arguments of each command by its executable
+// name, so an alias such as lst=/bin/ls is checked as ls. They return the
+// arguments to run with. Commands without a policy are checked by
+// validateExecArgs alone: an operator who allowlists one vouches for all
+// of its arguments.
+var execArgPolicies = map[string]func(args []string) ([]string, error){
+	"date":     dateArgs,
+	"uname":    flagArgs(nil),
+	"uptime":   flagArgs(nil),
+	"whoami":   flagArgs(nil),
+	"hostname": flagArgs([]string{"-s", "--short", "-f", "--fqdn", "--long", "-d", "--domain", "-i", "--ip-address", "-I", "--all-ip-addresses", "-A", "--all-fqdns", "-a", "--alias"}),
+	"id":       idArgs,
+	"echo":     func(args []string) ([]string, error) { return args, nil },
+	// df takes paths too; so do the file readers, which are left out of
+	// the default allowlist but confined to -fs-root when added.
+	"df":   sandboxPathArgs(false),
+	"ls":   sandboxPathArgs(true),
+	"cat":  sandboxPathArgs(true),
+	"wc":   sandboxPathArgs(true),
+	"head": sandboxPathArgs(true),
+	"tail": sandboxPathArgs(true),
+	"stat": sandboxPathArgs(true),
+	"file": sandboxPathArgs(true),
+}
+
+// checkExecArgs applies the policy of the command at path to args.
+func checkExecArgs(path string, args []string) ([]string, error) {
+	if err := validateExecArgs(args); err != nil {
+		return nil, err
+	}
+	policy, ok := execArgPolicies[filepath.Base(path)]
+	if !ok {
+		return args, nil
+	}
+	return policy(args)
+}
+
+// flagArgs allows options only, and of those only allowed unless it is
+// nil. Operands are refused: hostname would set the host name with one.
+func flagArgs(allowed []string) func([]string) ([]string, error) {
+	return func(args []string) ([]string, error) {
+		for _, arg := range args {
+			if !strings.HasPrefix(arg, "-") {
+				return nil, fmt.Errorf("argument %q: only options are allowed", arg)
+			}
+			if allowed != nil && !slices.Contains(allowed, arg) {
+				return nil, fmt.Errorf("option %q is not allowed (allowed: %s)", arg, strings.Join(allowed, " "))
+			}
+		}
+		return args, nil
+	}
+}
+
+// dateArgs allows a +FORMAT and the output options. -f and -r would read
+// a file (and print its lines in errors); -s would set the clock.
+func dateArgs(args []string) ([]string, error) {
+	for i, arg := range args {
+		switch {
+		case i > 0 && (args[i-1] == "-d" || args[i-1] == "--date"): // the date string
+		case strings.HasPrefix(arg, "+"),
+			arg == "-u", arg == "--utc", arg == "--universal", arg == "-R", arg == "--rfc-email",
+			arg == "-I", strings.HasPrefix(arg, "--iso-8601"), strings.HasPrefix(arg, "--rfc-3339="),
+			strings.HasPrefix(arg, "-d"), strings.HasPrefix(arg, "--date="):
+		default:
+			return nil, fmt.Errorf("argument %q is not allowed (use +FORMAT, -u, -R, -I, --rfc-3339=, or -d/--date=)", arg)
+		}
+	}
+	return args, nil
+}
+
+// idArgs allows options and user names.
+func idArgs(args []string) ([]string, error) {
+	for _, arg := range args {
+		if strings.ContainsRune(arg, '/') {
+			return nil, fmt.Errorf("argument %q: want an option or a user name", arg)
+		}
+	}
+	return args, nil
+}
+
+// sandboxPathArgs allows options and paths inside -fs-root. Each operand
+// is resolved like the filesystem tools' paths, symlinks included, and
+// passed relative to the root, which is the working directory, so output
+// does not show the host location. Options that name a file of their own
+// are refused. A reader (needsRoot) is refused outright without -fs-root;
+// df then takes options only.
+func sandboxPathArgs(needsRoot bool) func([]string) ([]string, error) {
+	return func(args []string) ([]string, error) {
+		if fsSandbox == nil && needsRoot {
+			return nil, errors.New("this command reads files and needs -fs-root, to which its paths are confined")
+		}
+		out := make([]string, 0, len(args))
+		options := true
+		for _, arg := range args {
+			if options && arg == "--" {
+				options = false
+				out = append(out, arg)
+				continue
+			}
+			if options && strings.HasPrefix(arg, "-") && arg != "-" {
+				if strings.HasPrefix(arg, "--files0-from") || strings.HasPrefix(arg, "--files-from") || strings.HasPrefix(arg, "--magic-file") {
+					return nil, fmt.Errorf("option %q is not allowed", arg)
+				}
+				out = append(out, arg)
+				continue
+			}
+			if fsSandbox == nil {
+				return nil, fmt.Errorf("argument %q: paths need -fs-root", arg)
+			}
+			full, err := fsSandbox.resolve(arg)
+			if err != nil {
+				return nil, err
+			}
+			rel := fsSandbox.rel(full)
+			if strings.HasPrefix(rel, "-") {
+				rel = "./" + rel
+			}
+			out = append(out, rel)
+		}
+		return out, nil
+	}
+}
+
+func ExecTool(ctx context.Context, req *mcp.CallToolRequest, in ExecArgs) (*mcp.CallToolResult, any, error) {
+	path, ok := execCommands[in.Command]
+	if !ok {
+		allowed := make([]string, 0, len(execCommands))
+		for name := range execCommands {
+			allowed = append(allowed, name)
+		}
+		sort.Strings(allowed)
+		notePolicyBlocked(ctx)
+		return errorResult(fmt.Sprintf("command %q is not allowed (allowed: %s)", in.Command, strings.Join(allowed, ", "))), nil, nil
+	}
+	args, err := checkExecArgs(path, in.Args)
+	if err != nil {
+		notePolicyBlocked(ctx)
+		return errorResult(fmt.Sprintf("%s: %v", in.Command, err)), nil, nil
+	}
+	timeout := defaultExecTimeout
+	if in.TimeoutSeconds > 0 {
+		timeout = min(time.Duration(in.TimeoutSeconds)*time.Second, maxExecTimeout)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dir := execDir()
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Dir = dir
+	cmd.Env = execEnv(dir)
+	stdout := &limitedBuffer{max: maxExecOutputBytes}
+	stderr := &limitedBuffer{max: maxExecOutputBytes}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err = cmd.Run()
+	elapsed := time.Since(start)
+
+	out := ExecResult{
+		Command:         in.Command,
+		Args:            append([]string{}, in.Args...),
+		ExitCode:        cmd.ProcessState.ExitCode(),
+		TimedOut:        errors.Is(ctx.Err(), context.DeadlineExceeded),
+		ElapsedMs:       elapsed.Milliseconds(),
+		Stdout:          stdout.buf.String(),
+		Stderr:          stderr.buf.String(),
+		StdoutTruncated: stdout.truncated,
+		StderrTruncated: stderr.truncated,
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) && !out.TimedOut {
+		return errorResult("Exec error: " + err.Error()), nil, nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "$ %s %s\n", in.Command, strings.Join(in.Args, " "))
+	fmt.Fprintf(&b, "Exit code: %d", out.ExitCode)
+	if out.TimedOut {
+		fmt.Fprintf(&b, " (killed after %s)", timeout)
+	}
+	fmt.Fprintf(&b, "\nElapsed: %dms\n", out.ElapsedMs)
+	if out.Stdout != "" {
+		fmt.Fprintf(&b, "\n--- stdout ---\n%s", out.Stdout)
+		if out.StdoutTruncated {
+			fmt.Fprintf(&b, "\n[stdout truncated at %d bytes]", maxExecOutputBytes)
+		}
+	}
+	if out.Stderr != "" {
+		fmt.Fprintf(&b, "\n--- stderr ---\n%s", out.Stderr)
+		if out.StderrTruncated {
+			fmt.Fprintf(&b, "\n[stderr truncated at %d bytes]", maxExecOutputBytes)
+		}
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: strings.TrimRight(b.String(), "\n")}},
+	}, out, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCheckExecArgs(t *testing.T) {
+	root, outside := testSandbox(t)
+	testTree(t, root, outside)
+
+	tests := []struct {
+		cmd  string
+		args []string
+		want []string // nil for an error
+	}{
+		{"date", []string{"+%Y-%m-%d"}, []string{"+%Y-%m-%d"}},
+		{"date", []string{"-u", "-I"}, []string{"-u", "-I"}},
+		{"date", []string{"-d", "tomorrow"}, []string{"-d", "tomorrow"}},
+		{"date", []string{"-f", "/etc/passwd"}, nil},
+		{"date", []string{"-r", "data/a.txt"}, nil},
+		{"date", []string{"-s", "2020-01-01"}, nil},
+		{"uname", []string{"-a"}, []string{"-a"}},
+		{"uname", []string{"x"}, nil},
+		{"hostname", []string{"-f"}, []string{"-f"}},
+		{"hostname", []string{"newname"}, nil},
+		{"hostname", []string{"-F", "/etc/hostname"}, nil},
+		{"id", []string{"-u", "root"}, []string{"-u", "root"}},
+		{"id", []string{"/etc/passwd"}, nil},
+		{"echo", []string{"a", "-n", "../x"}, []string{"a", "-n", "../x"}},
+		// Paths are confined to the root and passed relative to it.
+		{"cat", []string{"data/a.txt"}, []string{"data/a.txt"}},
+		{"cat", []string{"/etc/passwd"}, []string{"etc/passwd"}},
+		{"cat", []string{"../../etc/passwd"}, []string{"etc/passwd"}},
+		{"cat", []string{"in/a.txt"}, []string{"data/a.txt"}},
+		{"cat", []string{"out/secret"}, nil},
+		{"cat", []string{"dangle"}, nil},
+		{"ls", []string{"-la"}, []string{"-la"}},
+		{"ls", []string{"-la", "data/up"}, nil},
+		{"ls", []string{"--", "-x"}, []string{"--", "./-x"}},
+		{"wc", []string{"--files0-from=/etc/passwd"}, nil},
+		{"tail", []string{"--files-from", "list"}, nil},
+		{"file", []string{"--magic-file", "/etc/magic", "data/a.txt"}, nil},
+		{"df", []string{"-h", "/"}, []string{"-h", "."}},
+		// An alias is checked as the command it runs.
+		{"/usr/bin/cat", []string{"/etc/shadow"}, []string{"etc/shadow"}},
+		{"/usr/bin/cat", []string{"out"}, nil},
+		// Any command: control characters and too many arguments.
+		{"echo", []string{"a\nb"}, nil},
+		{"echo", make([]string, maxExecArgs+1), nil},
+		// Commands without a policy are left to the operator.
+		{"/opt/tool", []string{"--anything", "/etc"}, []string{"--anything", "/etc"}},
+	}
+	for _, tt := range tests {
+		got, err := checkExecArgs(tt.cmd, tt.args)
+		name := tt.cmd + " " + strings.Join(tt.args, " ")
+		switch {
+		case tt.want == nil && err == nil:
+			t.Errorf("%s: allowed as %q", name, got)
+		case tt.want != nil && err != nil:
+			t.Errorf("%s: %v", name, err)
+		case tt.want != nil && !slices.Equal(got, tt.want):
+			t.Errorf("%s: got %q, want %q", name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckExecArgsWithoutRoot(t *testing.T) {
+	saved := fsSandbox
+	fsSandbox = nil
+	t.Cleanup(func() { fsSandbox = saved })
+
+	tests := []struct {
+		cmd  string
+		args []string
+		ok   bool
+	}{
+		{"df", []string{"-h"}, true},
+		{"df", []string{"/"}, false},
+		{"cat", []string{"-n"}, false},
+		{"cat", nil, false},
+		{"date", nil, true},
+	}
+	for _, tt := range tests {
+		_, err := checkExecArgs(tt.cmd, tt.args)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s %q without -fs-root: err = %v, want ok = %v", tt.cmd, tt.args, err, tt.ok)
+		}
+	}
+}
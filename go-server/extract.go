@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+/* ---------- HTML extraction (fetch extract option) ---------- */
+
+const (
+	extractRaw      = "raw"
+	extractText     = "text"
+	extractMarkdown = "markdown"
+
+	// maxExtractInputBytes caps how much HTML is read before extraction;
+	// max_bytes is applied to the extracted output.
+	maxExtractInputBytes = 2 << 20
+)
+
+// skippedElements are dropped together with their content: scripts and
+// styles are never content, the rest is typical page boilerplate.
+var skippedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Iframe:   true,
+	atom.Head:     true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Button:   true,
+}
+
+// blockElements start on a new line in both text and markdown output.
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true,
+	atom.Main: true, atom.Ul: true, atom.Ol: true, atom.Li: true,
+	atom.Table: true, atom.Tr: true, atom.Blockquote: true, atom.Pre: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Figure: true, atom.Hr: true,
+}
+
+var (
+	spaceRun   = regexp.MustCompile(`[ \t\r\n\f]+`)
+	blankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// isHTML reports whether a Content-Type header denotes an HTML document.
+func isHTML(contentType string) bool {
+	ct := strings.ToLower(contentType)
+	return strings.HasPrefix(ct, "text/html") || strings.HasPrefix(ct, "application/xhtml+xml")
+}
+
+// validExtractMode normalizes the extract argument; empty means raw.
+func validExtractMode(mode string) (string, error) {
+	switch m := strings.ToLower(strings.TrimSpace(mode)); m {
+	case "", extractRaw:
+		return extractRaw, nil
+	case extractText, extractMarkdown:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid extract mode %q (want raw, text or markdown)", mode)
+	}
+}
+
+// extractHTML converts an HTML document to plain text or markdown.
+func extractHTML(r io.Reader, mode string) (string, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", err
+	}
+	c := &htmlConverter{markdown: mode == extractMarkdown}
+	c.walk(doc)
+	out := blankLines.ReplaceAllString(c.b.String(), "\n\n")
+	return strings.TrimSpace(out) + "\n", nil
+}
+
+type htmlConverter struct {
+	b        strings.Builder
+	markdown bool
+	inPre    bool
+	listType []atom.Atom // stack of enclosing ul/ol
+	olIndex  []int
+}
+
+// newline ends the current line unless the output already does.
+func (c *htmlConverter) newline(n int) {
+	s := c.b.String()
+	trailing := len(s) - len(strings.TrimRight(s, "\n"))
+	if len(s) == 0 {
+		return
+	}
+	for ; trailing < n; trailing++ {
+		c.b.WriteByte('\n')
+	}
+}
+
+func (c *htmlConverter) text(s string) {
+	if c.inPre {
+		c.b.WriteString(s)
+		return
+	}
+	s = spaceRun.ReplaceAllString(s, " ")
+	if s == " " || s == "" {
+		if out := c.b.String(); out != "" && !strings.HasSuffix(out, " ") && !strings.HasSuffix(out, "\n") {
+			c.b.WriteByte(' ')
+		}
+		return
+	}
+	if out := c.b.String(); strings.HasSuffix(out, "\n") || out == "" {
+		s = strings.TrimLeft(s, " ")
+	}
+	c.b.WriteString(s)
+}
+
+func (c *htmlConverter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		c.text(n.Data)
+		return
+	case html.ElementNode:
+		if skippedElements[n.DataAtom] {
+			return
+		}
+	case html.DocumentNode:
+	default:
+		return
+	}
+
+	block := blockElements[n.DataAtom]
+	if block {
+		c.newline(1)
+	}
+	suffix := c.open(n)
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.walk(child)
+	}
+	c.b.WriteString(suffix)
+	c.close(n)
+	if block {
+		c.newline(1)
+	}
+}
+
+// open writes the markup that precedes an element's content and returns
+// the markup that follows it.
+func (c *htmlConverter) open(n *html.Node) string {
+	switch n.DataAtom {
+	case atom.Br:
+		c.b.WriteByte('\n')
+	case atom.Pre:
+		c.inPre = true
+		if c.markdown {
+			c.b.WriteString("```\n")
+			return "\n```"
+		}
+	case atom.Ul, atom.Ol:
+		c.listType = append(c.listType, n.DataAtom)
+		c.olIndex = append(c.olIndex, 0)
+	case atom.Li:
+		depth := len(c.listType)
+		if depth > 0 {
+			c.b.WriteString(strings.Repeat("  ", depth-1))
+		}
+		if depth > 0 && c.listType[depth-1] == atom.Ol {
+			c.olIndex[depth-1]++
+			fmt.Fprintf(&c.b, "%d. ", c.olIndex[depth-1])
+		} else {
+			c.b.WriteString("- ")
+		}
+	}
+	if !c.markdown {
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		c.newline(2)
+		level := int(n.Data[1] - '0')
+		c.b.WriteString(strings.Repeat("#", level) + " ")
+	case atom.P:
+		c.newline(2)
+	case atom.Blockquote:
+		c.b.WriteString("> ")
+	case atom.Hr:
+		c.b.WriteString("---")
+	case atom.Strong, atom.B:
+		c.b.WriteString("**")
+		return "**"
+	case atom.Em, atom.I:
+		c.b.WriteString("_")
+		return "_"
+	case atom.Code:
+		if !c.inPre {
+			c.b.WriteString("`")
+			return "`"
+		}
+	case atom.A:
+		if href := attr(n, "href"); href != "" && !strings.HasPrefix(href, "javascript:") {
+			c.b.WriteString("[")
+			return "](" + href + ")"
+		}
+	case atom.Img:
+		if src := attr(n, "src"); src != "" {
+			fmt.Fprintf(&c.b, "![%s](%s)", attr(n, "alt"), src)
+		}
+	}
+	return ""
+}
+
+func (c *htmlConverter) close(n *html.Node) {
+	switch n.DataAtom {
+	case atom.Pre:
+		c.inPre = false
+	case atom.Ul, atom.Ol:
+		c.listType = c.listType[:len(c.listType)-1]
+		c.olIndex = c.olIndex[:len(c.olIndex)-1]
+	case atom.Td, atom.Th:
+		c.b.WriteString(" | ")
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Tool: fetch ---------- */
+
+const (
+	fetchUserAgent = "mcp-server-demo-go/1.0 (+https://example.local)"
+	// maxFetchBodyBytes caps the request body a caller may send.
+	maxFetchBodyBytes = 65536
+	// defaultMaxRedirects and maxRedirectsCap bound the max_redirects argument.
+	defaultMaxRedirects = 10
+	maxRedirectsCap     = 20
+	// defaultFetchAllowedHeaders is the default value of -fetch-allowed-headers.
+	defaultFetchAllowedHeaders = "Accept,Accept-Language,Authorization,Cache-Control,Content-Type,If-Match,If-Modified-Since,If-None-Match,User-Agent"
+	// defaultFetchMaxHeaderBytes is the default value of
+	// -fetch-max-header-bytes, net/http's own limit.
+	defaultFetchMaxHeaderBytes = 1 << 20
+)
+
+// fetchMethods lists the HTTP methods the fetch tool accepts.
+var fetchMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// fetchLimits are the fetch settings a config reload may change.
+type fetchLimits struct {
+	// MaxBytes is the upper bound for max_bytes; -public-demo lowers it.
+	MaxBytes int
+	// DefaultBytes applies to calls that give no max_bytes.
+	DefaultBytes int
+	// MaxBinaryBytes bounds binary bodies, which are returned whole or
+	// not at all.
+	MaxBinaryBytes int
+	// AllowedHeaders holds the canonical names of request headers callers
+	// may set through the headers argument (-fetch-allowed-headers).
+	AllowedHeaders map[string]bool
+}
+
+// fetchSettings is read by every fetch call and replaced as a whole.
+var fetchSettings atomic.Pointer[fetchLimits]
+
+func init() {
+	fetchSettings.Store(&fetchLimits{MaxBytes: maxCapBytes, DefaultBytes: defaultMaxBytes, MaxBinaryBytes: defaultMaxBinaryBytes, AllowedHeaders: parseHeaderList(defaultFetchAllowedHeaders)})
+}
+
+// parseHeaderList turns a comma-separated list of header names into a set
+// of canonical header names.
+func parseHeaderList(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			set[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+	return set
+}
+
+// disallowedHeaders returns the names in headers that callers may not set,
+// sorted.
+func (l *fetchLimits) disallowedHeaders(headers map[string]string) []string {
+	var rejected []string
+	for name := range headers {
+		if !l.AllowedHeaders[http.CanonicalHeaderKey(name)] {
+			rejected = append(rejected, name)
+		}
+	}
+	sort.Strings(rejected)
+	return rejected
+}
+
+type FetchArgs struct {
+	// URL to fetch
+	URL string `json:"url" jsonschema:"URL to fetch (must be http or https)"`
+	// Max bytes of the response body to return; the server sets the
+	// default and the cap.
+	MaxBytes int `json:"max_bytes,omitempty" jsonschema:"Limit response body bytes (min 256; the server's default is 4096 and its cap 65536 unless configured otherwise)"`
+	// Timeout of this request; the server's -fetch-timeout caps it.
+	TimeoutMs int `json:"timeout_ms,omitempty" jsonschema:"Give up on the request after this many milliseconds, body included (default and max: the server's fetch timeout, 10000 unless configured otherwise)"`
+	// HTTP method, defaults to GET.
+	Method string `json:"method,omitempty" jsonschema:"HTTP method: GET (default), HEAD, POST, PUT, PATCH, DELETE or OPTIONS"`
+	// Extra request headers; names must be on the server's allowlist.
+	Headers map[string]string `json:"headers,omitempty" jsonschema:"Request headers to send (only server-allowlisted names are accepted)"`
+	// Request body sent as-is.
+	Body string `json:"body,omitempty" jsonschema:"Request body (max 65536 bytes), typically used with POST, PUT or PATCH"`
+	// Redirect handling; nil means follow.
+	FollowRedirects *bool `json:"follow_redirects,omitempty" jsonschema:"Follow HTTP redirects (default true); when false the 3xx response itself is returned"`
+	MaxRedirects    int   `json:"max_redirects,omitempty" jsonschema:"Maximum redirect hops to follow (default 10, max 20)"`
+	// How to post-process HTML responses before max_bytes is applied.
+	Extract string `json:"extract,omitempty" jsonschema:"HTML handling: raw (default), text or markdown; text and markdown strip scripts, styles and page boilerplate"`
+	// Skip the content-type adapters.
+	Raw bool `json:"raw,omitempty" jsonschema:"Return the body as received, without content-type adapters (JSON pretty-printing, XML to JSON, CSV preview, image thumbnails, and HTML extraction when extract asks for it)"`
+	// Return binary bodies as text, as fetch did before content blocks.
+	ForceText bool `json:"force_text,omitempty" jsonschema:"Return a binary body (image, audio, PDF, archive...) as text cut at max_bytes instead of as a content block"`
+	// Retries of idempotent requests; nil means the server's default.
+	Retries        *int `json:"retries,omitempty" jsonschema:"Times to send a GET, HEAD, OPTIONS, PUT or DELETE request again after a dropped connection or a 408, 429, 500, 502, 503 or 504 response (default: the server's, 2 unless configured otherwise; max 5)"`
+	RetryBackoffMs int  `json:"retry_backoff_ms,omitempty" jsonschema:"Wait before the first retry in milliseconds, doubled for each further one, with jitter (default: the server's, 250 unless configured otherwise); a Retry-After header takes precedence"`
+}
+
+// redirectingClient returns a copy of httpClient with the given redirect
+// policy. Each hop is re-checked against the egress policy, so a public
+// URL cannot redirect the server into a private network; followed URLs
+// are appended to chain.
+func redirectingClient(follow bool, maxRedirects int, chain *[]string) *http.Client {
+	client := *httpClient
+	client.CheckRedirect = func(r *http.Request, via []*http.Request) error {
+		if !follow {
+			return http.ErrUseLastResponse
+		}
+		if len(via) > maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		if err := egress.Check(r.Context(), r.URL); err != nil {
+			return fmt.Errorf("redirect to %s not allowed: %w", r.URL, err)
+		}
+		*chain = append(*chain, r.URL.String())
+		return nil
+	}
+	return &client
+}
+
+// FetchResult is the structured output of the fetch tool.
+type FetchResult struct {
+	URL         string            `json:"url"`
+	FinalURL    string            `json:"final_url" jsonschema:"URL of the response after following redirects"`
+	Redirects   []string          `json:"redirects,omitempty" jsonschema:"Redirect chain: every URL redirected to, in order"`
+	Method      string            `json:"method"`
+	StatusCode  int               `json:"status_code"`
+	Status      string            `json:"status"`
+	Headers     map[string]string `json:"headers"`
+	ContentType string            `json:"content_type"`
+	Encoding    string            `json:"content_encoding,omitempty" jsonschema:"Content-Encoding the body was decompressed from"`
+	Charset     string            `json:"charset,omitempty" jsonschema:"Character set the body was converted from to UTF-8"`
+	ElapsedMs   int64             `json:"elapsed_ms" jsonschema:"Time from sending the request to reading the body, in milliseconds"`
+	RemoteAddr  string            `json:"remote_addr,omitempty" jsonschema:"Address the response came from, as ip:port"`
+	IPFamily    string            `json:"ip_family,omitempty" jsonschema:"Address family of remote_addr: ipv4 or ipv6"`
+	Bytes       int               `json:"bytes" jsonschema:"Number of body bytes returned"`
+	Truncated   bool              `json:"truncated" jsonschema:"True when the body was cut at max_bytes"`
+	Extract     string            `json:"extract" jsonschema:"Extraction applied to the body: raw, text or markdown"`
+	Adapter     string            `json:"adapter,omitempty" jsonschema:"Content-type adapter applied to the body: json, html, xml, csv or image"`
+	Binary      bool              `json:"binary,omitempty" jsonschema:"True when the body is binary: it is returned as an image, audio or embedded resource content block, not in body"`
+	Attempts    int               `json:"attempts" jsonschema:"Requests sent: 1 plus the retries"`
+	Body        string            `json:"body"`
+}
+
+// flattenHeaders joins multi-valued headers with ", " so that they fit a
+// string-to-string map.
+func flattenHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		out[name] = strings.Join(values, ", ")
+	}
+	return out
+}
+
+func FetchTool(ctx context.Context, req *mcp.CallToolRequest, in FetchArgs) (*mcp.CallToolResult, any, error) {
+	// Validate URL
+	if in.URL == "" {
+		return errorResult("URL is required"), nil, nil
+	}
+
+	// Validate URL scheme and destination
+	if !strings.HasPrefix(in.URL, "http://") && !strings.HasPrefix(in.URL, "https://") {
+		return errorResult("URL must start with http:// or https://"), nil, nil
+	}
+	target, err := url.Parse(in.URL)
+	if err != nil {
+		return errorResult("Invalid URL: " + err.Error()), nil, nil
+	}
+	if err := egress.Check(ctx, target); err != nil {
+		return errorResult("URL not allowed: " + err.Error()), nil, nil
+	}
+
+	method := strings.ToUpper(strings.TrimSpace(in.Method))
+	if method == "" {
+		method = http.MethodGet
+	}
+	if !fetchMethods[method] {
+		return errorResult(fmt.Sprintf("unsupported method %q", in.Method)), nil, nil
+	}
+
+	if len(in.Body) > maxFetchBodyBytes {
+		return errorResult(fmt.Sprintf("request body exceeds %d bytes", maxFetchBodyBytes)), nil, nil
+	}
+
+	limits := fetchSettings.Load()
+	if rejected := limits.disallowedHeaders(in.Headers); len(rejected) > 0 {
+		notePolicyBlocked(ctx)
+		return errorResult("headers not allowed: " + strings.Join(rejected, ", ")), nil, nil
+	}
+
+	extract, err := validExtractMode(in.Extract)
+	if err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+
+	maxBytes := in.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = limits.DefaultBytes
+	}
+	maxBytes = clamp(maxBytes, minCapBytes, limits.MaxBytes)
+
+	// timeout_ms can only shorten the client's timeout.
+	if in.TimeoutMs < 0 {
+		return errorResult("timeout_ms must be positive"), nil, nil
+	}
+	reqCtx := ctx
+	var timeout time.Duration
+	if in.TimeoutMs > 0 {
+		timeout = time.Duration(min(int64(in.TimeoutMs), httpClient.Timeout.Milliseconds())) * time.Millisecond
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	// timeoutNote explains an error caused by timeout_ms running out
+	// rather than by the call ending; it is empty otherwise.
+	timeoutNote := func() string {
+		if timeout == 0 || reqCtx.Err() == nil || ctx.Err() != nil {
+			return ""
+		}
+		if int64(in.TimeoutMs) > timeout.Milliseconds() {
+			return fmt.Sprintf("%dms (timeout_ms capped at the server's fetch timeout)", timeout.Milliseconds())
+		}
+		return fmt.Sprintf("%dms (timeout_ms)", timeout.Milliseconds())
+	}
+
+	var body io.Reader
+	if in.Body != "" {
+		body = strings.NewReader(in.Body)
+	}
+	httpReq, err := http.NewRequestWithContext(reqCtx, method, in.URL, body)
+	if err != nil {
+		return errorResult("Invalid URL: " + err.Error()), nil, nil
+	}
+	httpReq.Header.Set("User-Agent", fetchUserAgent)
+	httpReq.Header.Set("Accept-Encoding", fetchAcceptEncoding)
+	for name, value := range in.Headers {
+		httpReq.Header.Set(name, value)
+	}
+	if locale := defaults.lookup(req.Session).Locale; locale != "" && httpReq.Header.Get("Accept-Language") == "" {
+		httpReq.Header.Set("Accept-Language", locale)
+	}
+
+	follow := in.FollowRedirects == nil || *in.FollowRedirects
+	maxRedirects := in.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = defaultMaxRedirects
+	}
+	maxRedirects = min(maxRedirects, maxRedirectsCap)
+
+	retries, backoff := fetchRetrySettings.Retries, fetchRetrySettings.Backoff
+	if in.Retries != nil {
+		retries = min(max(*in.Retries, 0), maxFetchRetries)
+	}
+	if in.RetryBackoffMs < 0 {
+		return errorResult("retry_backoff_ms must be positive"), nil, nil
+	}
+	if in.RetryBackoffMs > 0 {
+		backoff = time.Duration(in.RetryBackoffMs) * time.Millisecond
+	}
+
+	var redirects []string
+	client := redirectingClient(follow, maxRedirects, &redirects)
+
+	// The last connection used is the one the final response came from.
+	var remote netip.AddrPort
+	httpReq = httpReq.WithContext(httptrace.WithClientTrace(reqCtx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if ap, err := netip.ParseAddrPort(info.Conn.RemoteAddr().String()); err == nil {
+				remote = ap
+			}
+		},
+	}))
+
+	start := time.Now()
+	resp, attempts, err := doWithRetries(client, httpReq, retries, backoff, func() { redirects = nil })
+	if err != nil {
+		attemptsNote := ""
+		if attempts > 1 {
+			attemptsNote = fmt.Sprintf(" (after %d attempts)", attempts)
+		}
+		if note := timeoutNote(); note != "" {
+			return errorResult("Fetch error: no response within " + note + attemptsNote), nil, nil
+		}
+		return errorResult("Fetch error: " + err.Error() + attemptsNote), nil, nil
+	}
+	defer resp.Body.Close()
+
+	// Read one byte past the cap so truncation is detected even when the
+	// server does not send Content-Length. The cap applies to the
+	// decompressed, UTF-8 converted body; a body that is going through a
+	// content-type adapter is read with a larger budget and the cap then
+	// applies to the adapter's output.
+	contentType := resp.Header.Get("Content-Type")
+	adapter, mediaType := adapterFor(contentType)
+	// HTML is only converted when extract asks for text or markdown.
+	if in.Raw || (adapter != nil && adapter.Name == "html" && extract == extractRaw) {
+		adapter = nil
+	}
+	readLimit := int64(maxBytes) + 1
+	if adapter != nil {
+		readLimit = max(maxExtractInputBytes, readLimit)
+	}
+	// A body not declared as text may be binary, which is returned whole
+	// or not at all.
+	mayBeBinary := !in.ForceText && !isTextual(contentType)
+	if mayBeBinary {
+		readLimit = max(int64(limits.MaxBinaryBytes)+1, readLimit)
+	}
+	decoded, err := decodeBody(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return errorResult("Decode error: " + err.Error()), nil, nil
+	}
+	defer decoded.Close()
+	respBody, err := io.ReadAll(io.LimitReader(decoded, readLimit))
+	if err != nil {
+		if note := timeoutNote(); note != "" {
+			return errorResult("Read error: body not received within " + note), nil, nil
+		}
+		return errorResult("Read error: " + err.Error()), nil, nil
+	}
+	respBody, bodyCharset, err := toUTF8(respBody, contentType)
+	if err != nil {
+		return errorResult("Decode error: " + err.Error()), nil, nil
+	}
+	var adapterName, adapterNote string
+	var image *mcp.ImageContent
+	switch {
+	case adapter != nil && adapter.Name != "html" && int64(len(respBody)) == readLimit:
+		// A cut-off document would not parse; show it as received. HTML
+		// parsing copes with a truncated page.
+		adapterNote = fmt.Sprintf("\n(%s adapter skipped: body over %d bytes)", adapter.Name, readLimit)
+	case adapter != nil:
+		res, err := adapter.Adapt(respBody, mediaType, extract)
+		if err != nil {
+			adapterNote = fmt.Sprintf("\n(%s adapter failed: %v; showing the body as received)", adapter.Name, err)
+			break
+		}
+		adapterName, image = adapter.Name, res.Image
+		respBody = []byte(res.Text)
+	}
+	if adapterName != "html" {
+		extract = extractRaw
+	}
+	var binary mcp.Content
+	binaryType := ""
+	if mayBeBinary && adapterName == "" {
+		binaryType = binaryMediaType(mediaType, respBody)
+	}
+	truncated := len(respBody) > maxBytes
+	switch {
+	case binaryType != "":
+		truncated = len(respBody) > limits.MaxBinaryBytes
+		if !truncated {
+			binary = binaryContent(resp.Request.URL.String(), binaryType, respBody)
+		}
+	case truncated && bodyCharset != "":
+		respBody = truncateUTF8(respBody, maxBytes)
+	case truncated:
+		respBody = respBody[:maxBytes]
+	}
+
+	out := FetchResult{
+		URL:         in.URL,
+		FinalURL:    resp.Request.URL.String(),
+		Redirects:   redirects,
+		Method:      method,
+		StatusCode:  resp.StatusCode,
+		Status:      resp.Status,
+		Headers:     flattenHeaders(resp.Header),
+		ContentType: contentType,
+		Encoding:    resp.Header.Get("Content-Encoding"),
+		Charset:     bodyCharset,
+		ElapsedMs:   time.Since(start).Milliseconds(),
+		Bytes:       len(respBody),
+		Truncated:   truncated,
+		Extract:     extract,
+		Adapter:     adapterName,
+		Binary:      binaryType != "",
+		Attempts:    attempts,
+		Body:        string(respBody),
+	}
+	if out.Binary {
+		out.Body = ""
+		if truncated {
+			out.Bytes = 0
+		}
+	}
+
+	if remote.IsValid() {
+		out.RemoteAddr = netip.AddrPortFrom(remote.Addr().Unmap(), remote.Port()).String()
+		out.IPFamily = addrFamily(remote.Addr())
+	}
+
+	truncatedNote := ""
+	if truncated {
+		truncatedNote = " (truncated)"
+	}
+
+	redirectNote := ""
+	if len(redirects) > 0 {
+		redirectNote = fmt.Sprintf("\nFinal URL: %s (after %d redirects)", out.FinalURL, len(redirects))
+	}
+
+	attemptsNote := ""
+	if attempts > 1 {
+		attemptsNote = fmt.Sprintf(" (%d attempts)", attempts)
+	}
+
+	remoteNote := ""
+	if out.RemoteAddr != "" {
+		remoteNote = fmt.Sprintf("\nRemote: %s (%s)", out.RemoteAddr, out.IPFamily)
+	}
+
+	if adapterName != "" {
+		adapterNote = fmt.Sprintf("\nAdapter: %s (pass raw=true for the body as received)", adapterName)
+	}
+
+	shown := out.Body
+	switch {
+	case binary != nil:
+		shown = fmt.Sprintf("(binary %s body attached as a content block; pass force_text=true for text)", binaryType)
+	case out.Binary:
+		truncatedNote = ""
+		shown = fmt.Sprintf("(binary %s body not returned: over the server's limit of %d bytes for binary content; pass force_text=true for the first max_bytes as text)",
+			binaryType, limits.MaxBinaryBytes)
+	}
+
+	result := fmt.Sprintf("URL: %s%s\nMethod: %s\nStatus: %s\nContent-Type: %s%s\nElapsed: %dms%s\nBytes: %d%s%s\n\n%s",
+		out.URL, redirectNote, out.Method, out.Status, out.ContentType, remoteNote, out.ElapsedMs, attemptsNote, out.Bytes, truncatedNote, adapterNote, shown)
+
+	content := []mcp.Content{&mcp.TextContent{Text: result}}
+	if image != nil {
+		content = append(content, image)
+	}
+	if binary != nil {
+		content = append(content, binary)
+	}
+	return &mcp.CallToolResult{Content: content}, out, nil
+}
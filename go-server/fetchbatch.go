@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Tool: fetch_batch ---------- */
+
+const (
+	// maxFetchBatchURLs bounds the urls argument.
+	maxFetchBatchURLs = 20
+	// defaultFetchBatchWorkers and maxFetchBatchWorkers bound the
+	// concurrency argument.
+	defaultFetchBatchWorkers = 4
+	maxFetchBatchWorkers     = 8
+)
+
+type FetchBatchArgs struct {
+	URLs        []string `json:"urls" jsonschema:"URLs to fetch with GET (http or https, at most 20)"`
+	Concurrency int      `json:"concurrency,omitempty" jsonschema:"How many URLs are fetched at once (default 4, max 8)"`
+	// The options below apply to every URL, as in fetch.
+	MaxBytes        int               `json:"max_bytes,omitempty" jsonschema:"Limit each response body, as in fetch"`
+	Headers         map[string]string `json:"headers,omitempty" jsonschema:"Request headers sent with every request (only server-allowlisted names are accepted)"`
+	FollowRedirects *bool             `json:"follow_redirects,omitempty" jsonschema:"Follow HTTP redirects (default true)"`
+	Extract         string            `json:"extract,omitempty" jsonschema:"HTML handling: raw (default), text or markdown"`
+	Raw             bool              `json:"raw,omitempty" jsonschema:"Return bodies as received, without content-type adapters"`
+	TimeoutMs       int               `json:"timeout_ms,omitempty" jsonschema:"Give up on each request after this many milliseconds, as in fetch"`
+	Retries         *int              `json:"retries,omitempty" jsonschema:"Times each request is sent again after a transient failure, as in fetch"`
+}
+
+// FetchBatchItem is the outcome of one URL, in the order given.
+type FetchBatchItem struct {
+	URL    string       `json:"url"`
+	OK     bool         `json:"ok" jsonschema:"True when a response was received, whatever its status code"`
+	Error  string       `json:"error,omitempty"`
+	Result *FetchResult `json:"result,omitempty" jsonschema:"The fetch result, when ok"`
+}
+
+// FetchBatchResult is the structured output of the fetch_batch tool.
+type FetchBatchResult struct {
+	Requested int              `json:"requested"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	ElapsedMs int64            `json:"elapsed_ms" jsonschema:"Time for the whole batch, in milliseconds"`
+	Results   []FetchBatchItem `json:"results" jsonschema:"One entry per URL, in the order given"`
+}
+
+// FetchBatchTool fetches several URLs at once through FetchTool, so each
+// URL gets the same checks, limits and adapters as a fetch call. Image
+// thumbnails are left out; fetch the image alone to get one.
+func FetchBatchTool(ctx context.Context, req *mcp.CallToolRequest, in FetchBatchArgs) (*mcp.CallToolResult, any, error) {
+	if len(in.URLs) == 0 {
+		return errorResult("urls is required"), nil, nil
+	}
+	if len(in.URLs) > maxFetchBatchURLs {
+		return errorResult(fmt.Sprintf("too many urls: %d (max %d)", len(in.URLs), maxFetchBatchURLs)), nil, nil
+	}
+	workers := in.Concurrency
+	if workers <= 0 {
+		workers = defaultFetchBatchWorkers
+	}
+	workers = min(workers, maxFetchBatchWorkers, len(in.URLs))
+
+	// Progress counts URLs here, not the bytes of each response.
+	progress := progressFrom(ctx)
+	ctx = withoutTransferProgress(ctx)
+
+	start := time.Now()
+	items := make([]FetchBatchItem, len(in.URLs))
+	texts := make([]string, len(in.URLs))
+	var mu sync.Mutex
+	done := 0
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				items[i], texts[i] = fetchBatchOne(ctx, req, in, in.URLs[i])
+				mu.Lock()
+				done++
+				progress.notify(float64(done), float64(len(in.URLs)), fmt.Sprintf("fetched %d/%d: %s", done, len(in.URLs), in.URLs[i]))
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range in.URLs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	out := FetchBatchResult{Requested: len(in.URLs), Results: items, ElapsedMs: time.Since(start).Milliseconds()}
+	for _, item := range items {
+		if item.OK {
+			out.Succeeded++
+		} else {
+			out.Failed++
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Fetched %d URLs: %d ok, %d failed, in %dms", out.Requested, out.Succeeded, out.Failed, out.ElapsedMs)
+	for i, text := range texts {
+		fmt.Fprintf(&b, "\n\n=== [%d/%d] %s ===\n%s", i+1, len(texts), in.URLs[i], text)
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: b.String()}},
+	}, out, nil
+}
+
+// fetchBatchOne fetches one URL of a batch, returning its item and the
+// text fetch would have shown.
+func fetchBatchOne(ctx context.Context, req *mcp.CallToolRequest, in FetchBatchArgs, url string) (FetchBatchItem, string) {
+	item := FetchBatchItem{URL: url}
+	res, structured, err := FetchTool(ctx, req, FetchArgs{
+		URL:             url,
+		MaxBytes:        in.MaxBytes,
+		Headers:         in.Headers,
+		FollowRedirects: in.FollowRedirects,
+		Extract:         in.Extract,
+		Raw:             in.Raw,
+		TimeoutMs:       in.TimeoutMs,
+		Retries:         in.Retries,
+	})
+	text := ""
+	if res != nil && len(res.Content) > 0 {
+		if tc, ok := res.Content[0].(*mcp.TextContent); ok {
+			text = tc.Text
+		}
+	}
+	switch {
+	case err != nil:
+		item.Error = err.Error()
+		return item, "Error: " + item.Error
+	case res.IsError:
+		item.Error = text
+		return item, "Error: " + text
+	}
+	result := structured.(FetchResult)
+	item.OK, item.Result = true, &result
+	return item, text
+}
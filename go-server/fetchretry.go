@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+/* ---------- Fetch retries ---------- */
+
+const (
+	// defaultFetchRetries, defaultFetchRetryBackoff and
+	// defaultFetchRetryMaxWait are the defaults of -fetch-retries,
+	// -fetch-retry-backoff and -fetch-retry-max-wait.
+	defaultFetchRetries      = 2
+	defaultFetchRetryBackoff = 250 * time.Millisecond
+	defaultFetchRetryMaxWait = 10 * time.Second
+	// maxFetchRetries bounds -fetch-retries and the retries argument.
+	maxFetchRetries = 5
+	// retryDrainBytes is how much of a response that is retried is read
+	// before closing it, so that its connection can be reused.
+	retryDrainBytes = 4 << 10
+)
+
+// fetchRetrySettings are set from flags in main.
+var fetchRetrySettings = struct {
+	// Retries is how many times a call that gives no retries argument
+	// tries again.
+	Retries int
+	// Backoff is the wait before the first retry; it doubles for each
+	// further one.
+	Backoff time.Duration
+	// MaxWait caps a single wait, Retry-After included: a server asking
+	// for longer gets its response returned instead.
+	MaxWait time.Duration
+}{Retries: defaultFetchRetries, Backoff: defaultFetchRetryBackoff, MaxWait: defaultFetchRetryMaxWait}
+
+// retryStatuses are the response codes another attempt may get past.
+var retryStatuses = map[int]bool{
+	http.StatusRequestTimeout:      true,
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// idempotentMethods are the methods that may be sent more than once.
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// fetchRetryCount counts retries by reason: a status code or
+// "connection".
+var fetchRetryCount = struct {
+	sync.Mutex
+	byReason map[string]int
+}{byReason: make(map[string]int)}
+
+// retryableError reports whether err is a dropped connection, which a
+// new attempt may get past. Refused connections and timeouts are not
+// retried: the host is down or already took its time.
+func retryableError(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// doWithRetries sends req with client and, for idempotent methods, sends
+// it again up to retries times after a dropped connection or a status in
+// retryStatuses. Waits start at backoff and double, with jitter; a
+// Retry-After header replaces the wait. Retrying stops early when a wait
+// would pass MaxWait or the request's deadline, and the last response or
+// error is returned. reset is called before each retry.
+func doWithRetries(client *http.Client, req *http.Request, retries int, backoff time.Duration, reset func()) (resp *http.Response, attempts int, err error) {
+	ctx := req.Context()
+	for attempts = 1; ; attempts++ {
+		r := req
+		if attempts > 1 {
+			r = req.Clone(ctx)
+			if req.GetBody != nil {
+				if r.Body, err = req.GetBody(); err != nil {
+					return nil, attempts - 1, err
+				}
+			}
+			reset()
+		}
+		resp, err = client.Do(r)
+		if attempts > retries || !idempotentMethods[req.Method] {
+			return resp, attempts, err
+		}
+		var reason string
+		switch {
+		case err != nil && retryableError(err):
+			reason = "connection"
+		case err == nil && retryStatuses[resp.StatusCode]:
+			reason = strconv.Itoa(resp.StatusCode)
+		default:
+			return resp, attempts, err
+		}
+		wait, ok := retryWait(attempts, backoff, resp)
+		if !ok || !waitFits(ctx, wait) {
+			return resp, attempts, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, retryDrainBytes))
+			resp.Body.Close()
+		}
+		fetchRetryCount.Lock()
+		fetchRetryCount.byReason[reason]++
+		fetchRetryCount.Unlock()
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, attempts, ctx.Err()
+		}
+	}
+}
+
+// retryWait is the wait before retry n (1-based): the response's
+// Retry-After if it has one, else backoff doubled n-1 times, of which the
+// second half is random. ok is false when the wait would pass MaxWait.
+func retryWait(n int, backoff time.Duration, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return d, d <= fetchRetrySettings.MaxWait
+		}
+	}
+	d := min(backoff<<(n-1), fetchRetrySettings.MaxWait)
+	if d > 1 {
+		d = d/2 + rand.N(d/2)
+	}
+	return d, true
+}
+
+// parseRetryAfter reads a Retry-After value: delay seconds or an HTTP
+// date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(max(secs, 0)) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	return max(t.Sub(now), 0), true
+}
+
+// waitFits reports whether ctx leaves room for a wait of d and a new
+// attempt.
+func waitFits(ctx context.Context, d time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > d
+}
+
+func collectFetchRetryMetrics(w *metricsWriter) {
+	fetchRetryCount.Lock()
+	defer fetchRetryCount.Unlock()
+	w.family("mcp_fetch_retries_total", "counter", "Fetch requests sent again, by reason: the status code retried or connection")
+	for _, reason := range sortedKeys(fetchRetryCount.byReason) {
+		w.sample("mcp_fetch_retries_total", float64(fetchRetryCount.byReason[reason]), "reason", reason)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Resources: sandbox files and subscriptions ---------- */
+
+const (
+	// sandboxURIPrefix and sandboxURITemplate name files under -fs-root
+	// by their path relative to it, e.g. sandbox:///notes/todo.txt.
+	sandboxURIPrefix   = "sandbox:///"
+	sandboxURITemplate = sandboxURIPrefix + "{+path}"
+	// maxSubscriptions caps the files watched for subscribers.
+	maxSubscriptions = 256
+	// updateDebounce merges the bursts of events one save produces into
+	// one notification.
+	updateDebounce = 100 * time.Millisecond
+)
+
+// addSandboxResources exposes the files under the sandbox as resources
+// that clients can read and subscribe to.
+func addSandboxResources(server *mcp.Server) {
+	fileWatches.mu.Lock()
+	fileWatches.server = server
+	fileWatches.mu.Unlock()
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		URITemplate: sandboxURITemplate,
+		Name:        "sandbox-file",
+		Title:       "Sandbox file",
+		Description: "A file under the server's sandbox directory, by its path relative to it; subscribe to be notified when it changes",
+	}, readSandboxFile)
+}
+
+// sandboxPath resolves a sandbox:/// URI to a path inside the sandbox
+// that the session's roots allow.
+func sandboxPath(ctx context.Context, session *mcp.ServerSession, uri string) (string, error) {
+	rel, ok := strings.CutPrefix(uri, sandboxURIPrefix)
+	if !ok || fsSandbox == nil {
+		return "", mcp.ResourceNotFoundError(uri)
+	}
+	rel, err := url.PathUnescape(rel)
+	if err != nil {
+		return "", fmt.Errorf("invalid resource URI %q: %v", uri, err)
+	}
+	path, err := fsSandbox.resolve(rel)
+	if err != nil {
+		return "", err
+	}
+	if err := fsSandbox.checkRoots(ctx, session, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func readSandboxFile(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	uri := req.Params.URI
+	path, err := sandboxPath(ctx, req.Session, uri)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open: %s", fsSandbox.describe(err))
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat: %s", fsSandbox.describe(err))
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", fsSandbox.rel(path))
+	}
+	if info.Size() > maxFSReadBytes {
+		return nil, fmt.Errorf("%s is too large to read as a resource (%d bytes, max %d); use read_file with offset", fsSandbox.rel(path), info.Size(), maxFSReadBytes)
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxFSReadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("read: %s", fsSandbox.describe(err))
+	}
+
+	mimeType := mime.TypeByExtension(filepath.Ext(path))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	contents := &mcp.ResourceContents{URI: uri, MIMEType: mimeType}
+	if utf8.Valid(data) {
+		contents.Text = string(data)
+	} else {
+		contents.Blob = data
+	}
+	return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{contents}}, nil
+}
+
+// fileWatchSet watches the files that sessions subscribed to and sends
+// notifications/resources/updated when they change. Directories are
+// watched rather than files, so that a file replaced by an editor's
+// rename, or created after the subscription, is still seen.
+type fileWatchSet struct {
+	mu      sync.Mutex
+	server  *mcp.Server
+	watcher *fsnotify.Watcher
+	uris    map[string]*fileWatch // by resource URI
+	dirs    map[string]int        // watched directory -> files watched in it
+	pending map[string]*time.Timer
+}
+
+// fileWatch is one subscribed file.
+type fileWatch struct {
+	path     string
+	sessions map[string]bool
+}
+
+var fileWatches = &fileWatchSet{
+	uris:    make(map[string]*fileWatch),
+	dirs:    make(map[string]int),
+	pending: make(map[string]*time.Timer),
+}
+
+// subscribeSandboxFile handles resources/subscribe. The file may not
+// exist yet, but its directory must.
+func subscribeSandboxFile(ctx context.Context, req *mcp.SubscribeRequest) error {
+	uri := req.Params.URI
+	path, err := sandboxPath(ctx, req.Session, uri)
+	if err != nil {
+		return err
+	}
+	return fileWatches.add(uri, path, req.Session.ID())
+}
+
+// unsubscribeSandboxFile handles resources/unsubscribe. The SDK forgets
+// the subscriptions of sessions that end without unsubscribing; the
+// janitor removes their watches.
+func unsubscribeSandboxFile(ctx context.Context, req *mcp.UnsubscribeRequest) error {
+	fileWatches.remove(req.Params.URI, req.Session.ID())
+	return nil
+}
+
+func (s *fileWatchSet) add(uri, path, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w := s.uris[uri]; w != nil {
+		w.sessions[sessionID] = true
+		return nil
+	}
+	if len(s.uris) >= maxSubscriptions {
+		return fmt.Errorf("too many subscribed files (max %d)", maxSubscriptions)
+	}
+	if s.watcher == nil {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("file watcher: %v", err)
+		}
+		s.watcher = watcher
+		go s.run(watcher)
+	}
+	dir := filepath.Dir(path)
+	if s.dirs[dir] == 0 {
+		if err := s.watcher.Add(dir); err != nil {
+			return fmt.Errorf("watch %s: %v", fsSandbox.rel(dir), err)
+		}
+	}
+	s.dirs[dir]++
+	s.uris[uri] = &fileWatch{path: path, sessions: map[string]bool{sessionID: true}}
+	return nil
+}
+
+func (s *fileWatchSet) remove(uri, sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeLocked(uri, sessionID)
+}
+
+// retain drops the subscriptions of sessions not in live, returning how
+// many.
+func (s *fileWatchSet) retain(live map[string]bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for uri, w := range s.uris {
+		for id := range w.sessions {
+			if !live[id] {
+				s.removeLocked(uri, id)
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func (s *fileWatchSet) removeLocked(uri, sessionID string) {
+	w := s.uris[uri]
+	if w == nil {
+		return
+	}
+	delete(w.sessions, sessionID)
+	if len(w.sessions) > 0 {
+		return
+	}
+	delete(s.uris, uri)
+	dir := filepath.Dir(w.path)
+	if s.dirs[dir]--; s.dirs[dir] <= 0 {
+		delete(s.dirs, dir)
+		s.watcher.Remove(dir)
+	}
+}
+
+// run turns file events into notifications until the watcher is closed.
+func (s *fileWatchSet) run(watcher *fsnotify.Watcher) {
+	for {
+		select {
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if ev.Has(fsnotify.Chmod) && !ev.Has(fsnotify.Write) {
+				continue
+			}
+			s.changed(ev.Name)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("File watcher: %v", err)
+		}
+	}
+}
+
+// changed schedules a notification for every URI backed by path.
+func (s *fileWatchSet) changed(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for uri, w := range s.uris {
+		if w.path != path {
+			continue
+		}
+		if t := s.pending[uri]; t != nil {
+			t.Reset(updateDebounce)
+			continue
+		}
+		s.pending[uri] = time.AfterFunc(updateDebounce, func() {
+			s.mu.Lock()
+			delete(s.pending, uri)
+			server := s.server
+			s.mu.Unlock()
+			server.ResourceUpdated(context.Background(), &mcp.ResourceUpdatedNotificationParams{URI: uri})
+		})
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Tools: read_file, write_file, list_dir ---------- */
+
+const (
+	// defaultFSReadBytes and maxFSReadBytes bound read_file's max_bytes.
+	defaultFSReadBytes = 65536
+	maxFSReadBytes     = 1 << 20
+	// maxFSWriteBytes caps the content write_file accepts in one call.
+	maxFSWriteBytes = 1 << 20
+	// maxDirEntries caps the number of entries list_dir returns.
+	maxDirEntries = 1000
+)
+
+// sandbox confines the filesystem tools to one directory tree. Paths from
+// callers are always interpreted relative to Root; ".." cannot climb
+// above it and symlinks that resolve outside it are rejected. Within it,
+// each session is further limited to its client's roots (see roots.go).
+type sandbox struct {
+	Root     string
+	ReadOnly bool
+}
+
+// fsSandbox is configured from -fs-root; nil disables the filesystem tools.
+var fsSandbox *sandbox
+
+// newSandbox validates root and returns a sandbox for it.
+func newSandbox(root string, readOnly bool) (*sandbox, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	abs, err = filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	return &sandbox{Root: abs, ReadOnly: readOnly}, nil
+}
+
+// resolve maps a caller-supplied path to an absolute path inside the
+// sandbox. The deepest existing ancestor is resolved through symlinks and
+// must still lie within Root, which also covers files not yet created. A
+// symlink whose target does not exist is rejected: creating the path
+// would follow it to wherever it points.
+func (s *sandbox) resolve(p string) (string, error) {
+	if strings.ContainsRune(p, 0) {
+		return "", errors.New("path contains a NUL byte")
+	}
+	full := filepath.Join(s.Root, filepath.Clean("/"+filepath.ToSlash(p)))
+
+	existing, rest := full, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if !s.contains(resolved) {
+				return "", fmt.Errorf("path %q escapes the sandbox root", p)
+			}
+			return filepath.Join(resolved, rest), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		if _, lerr := os.Lstat(existing); lerr == nil {
+			return "", fmt.Errorf("path %q goes through a symlink whose target does not exist", p)
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+}
+
+func (s *sandbox) contains(p string) bool {
+	return pathWithin(s.Root, p)
+}
+
+// describe formats err for callers with sandbox paths made relative, so
+// the host location of the root is not disclosed.
+func (s *sandbox) describe(err error) string {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return fmt.Sprintf("%s %s: %v", pathErr.Op, s.rel(pathErr.Path), pathErr.Err)
+	}
+	return err.Error()
+}
+
+// rel returns p relative to Root with forward slashes, for display.
+func (s *sandbox) rel(p string) string {
+	r, err := filepath.Rel(s.Root, p)
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(r)
+}
+
+type ReadFileArgs struct {
+	Path     string `json:"path" jsonschema:"File path relative to the sandbox root"`
+	Offset   int64  `json:"offset,omitempty" jsonschema:"Byte offset to start reading from (default 0)"`
+	MaxBytes int    `json:"max_bytes,omitempty" jsonschema:"Maximum bytes to return (default 65536, max 1048576)"`
+}
+
+// ReadFileResult is the structured output of the read_file tool.
+type ReadFileResult struct {
+	Path      string `json:"path"`
+	Size      int64  `json:"size" jsonschema:"Total file size in bytes"`
+	Offset    int64  `json:"offset"`
+	Bytes     int    `json:"bytes" jsonschema:"Number of bytes returned"`
+	Truncated bool   `json:"truncated" jsonschema:"True when the file continues past the returned bytes"`
+	Encoding  string `json:"encoding" jsonschema:"utf-8, or base64 for binary content"`
+	Content   string `json:"content"`
+}
+
+func ReadFileTool(ctx context.Context, req *mcp.CallToolRequest, in ReadFileArgs) (*mcp.CallToolResult, any, error) {
+	if in.Path == "" {
+		return errorResult("path is required"), nil, nil
+	}
+	if in.Offset < 0 {
+		return errorResult("offset must not be negative"), nil, nil
+	}
+	maxBytes := in.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultFSReadBytes
+	}
+	maxBytes = min(maxBytes, maxFSReadBytes)
+
+	path, err := fsSandbox.resolve(in.Path)
+	if err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+	if err := fsSandbox.checkRoots(ctx, req.Session, path); err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return errorResult("Open error: " + fsSandbox.describe(err)), nil, nil
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return errorResult("Stat error: " + fsSandbox.describe(err)), nil, nil
+	}
+	if !info.Mode().IsRegular() {
+		return errorResult(fmt.Sprintf("%s is not a regular file", in.Path)), nil, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(io.NewSectionReader(f, in.Offset, info.Size()), int64(maxBytes)))
+	if err != nil {
+		return errorResult("Read error: " + fsSandbox.describe(err)), nil, nil
+	}
+
+	out := ReadFileResult{
+		Path:      fsSandbox.rel(path),
+		Size:      info.Size(),
+		Offset:    in.Offset,
+		Bytes:     len(data),
+		Truncated: in.Offset+int64(len(data)) < info.Size(),
+		Encoding:  "utf-8",
+		Content:   string(data),
+	}
+	text := out.Content
+	if !utf8.Valid(data) {
+		out.Encoding = "base64"
+		out.Content = base64.StdEncoding.EncodeToString(data)
+		text = fmt.Sprintf("[binary content, %d bytes, base64]\n%s", len(data), out.Content)
+	}
+	if out.Truncated {
+		text += fmt.Sprintf("\n\n[truncated: showing %d of %d bytes from offset %d]", out.Bytes, out.Size, out.Offset)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: text}},
+	}, out, nil
+}
+
+type WriteFileArgs struct {
+	Path       string `json:"path" jsonschema:"File path relative to the sandbox root"`
+	Content    string `json:"content" jsonschema:"Text to write (max 1048576 bytes)"`
+	Append     bool   `json:"append,omitempty" jsonschema:"Append to the file instead of replacing it"`
+	CreateDirs bool   `json:"create_dirs,omitempty" jsonschema:"Create missing parent directories"`
+}
+
+// WriteFileResult is the structured output of the write_file tool.
+type WriteFileResult struct {
+	Path         string `json:"path"`
+	BytesWritten int    `json:"bytes_written"`
+	Size         int64  `json:"size" jsonschema:"File size after the write"`
+}
+
+func WriteFileTool(ctx context.Context, req *mcp.CallToolRequest, in WriteFileArgs) (*mcp.CallToolResult, any, error) {
+	if fsSandbox.ReadOnly {
+		return errorResult("filesystem is read-only"), nil, nil
+	}
+	if in.Path == "" {
+		return errorResult("path is required"), nil, nil
+	}
+	if len(in.Content) > maxFSWriteBytes {
+		return errorResult(fmt.Sprintf("content too large (%d bytes, max %d)", len(in.Content), maxFSWriteBytes)), nil, nil
+	}
+
+	path, err := fsSandbox.resolve(in.Path)
+	if err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+	if err := fsSandbox.checkRoots(ctx, req.Session, path); err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+	if path == fsSandbox.Root {
+		return errorResult("cannot write to the sandbox root"), nil, nil
+	}
+	// Snapshots and restores wait for writes in progress.
+	fsWrites.RLock()
+	defer fsWrites.RUnlock()
+	fsChanged.Store(true)
+	if in.CreateDirs {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return errorResult("Mkdir error: " + fsSandbox.describe(err)), nil, nil
+		}
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if in.Append {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return errorResult("Open error: " + fsSandbox.describe(err)), nil, nil
+	}
+	n, err := f.WriteString(in.Content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errorResult("Write error: " + fsSandbox.describe(err)), nil, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return errorResult("Stat error: " + fsSandbox.describe(err)), nil, nil
+	}
+
+	out := WriteFileResult{Path: fsSandbox.rel(path), BytesWritten: n, Size: info.Size()}
+	text := fmt.Sprintf("Wrote %d bytes to %s (size now %d bytes)", out.BytesWritten, out.Path, out.Size)
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: text}},
+	}, out, nil
+}
+
+type ListDirArgs struct {
+	Path string `json:"path,omitempty" jsonschema:"Directory path relative to the sandbox root (default: the root)"`
+}
+
+// DirEntry describes one entry returned by list_dir.
+type DirEntry struct {
+	Name    string    `json:"name"`
+	Type    string    `json:"type" jsonschema:"file, dir, symlink or other"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// ListDirResult is the structured output of the list_dir tool.
+type ListDirResult struct {
+	Path      string     `json:"path"`
+	Entries   []DirEntry `json:"entries"`
+	Truncated bool       `json:"truncated" jsonschema:"True when the directory has more than 1000 entries"`
+}
+
+func ListDirTool(ctx context.Context, req *mcp.CallToolRequest, in ListDirArgs) (*mcp.CallToolResult, any, error) {
+	path, err := fsSandbox.resolve(in.Path)
+	if err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+	if err := fsSandbox.checkRoots(ctx, req.Session, path); err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return errorResult("ReadDir error: " + fsSandbox.describe(err)), nil, nil
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	out := ListDirResult{Path: fsSandbox.rel(path), Entries: []DirEntry{}}
+	if len(entries) > maxDirEntries {
+		entries = entries[:maxDirEntries]
+		out.Truncated = true
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Directory: %s\n", out.Path)
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		entry := DirEntry{Name: e.Name(), Type: "other", Size: info.Size(), ModTime: info.ModTime().UTC()}
+		switch {
+		case e.Type()&fs.ModeSymlink != 0:
+			entry.Type = "symlink"
+		case e.IsDir():
+			entry.Type = "dir"
+			entry.Size = 0
+		case e.Type().IsRegular():
+			entry.Type = "file"
+		}
+		out.Entries = append(out.Entries, entry)
+
+		name := entry.Name
+		if entry.Type == "dir" {
+			name += "/"
+		}
+		fmt.Fprintf(&b, "%-8s %10d  %s\n", entry.Type, entry.Size, name)
+	}
+	if out.Truncated {
+		fmt.Fprintf(&b, "[truncated at %d entries]\n", maxDirEntries)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: strings.TrimRight(b.String(), "\n")}},
+	}, out, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// testSandbox points fsSandbox at a fresh directory for the test and
+// returns it with a directory outside it.
+func testSandbox(t *testing.T) (root, outside string) {
+	t.Helper()
+	sb, err := newSandbox(t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside, err = filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, savedRoots := fsSandbox, respectClientRoots
+	fsSandbox, respectClientRoots = sb, false
+	t.Cleanup(func() { fsSandbox, respectClientRoots = saved, savedRoots })
+	return sb.Root, outside
+}
+
+// testTree fills root with data/a.txt and symlinks to inside and
+// outside it, dangling ones included.
+func testTree(t *testing.T, root, outside string) {
+	t.Helper()
+	if err := os.Mkdir(filepath.Join(root, "data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "data", "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for name, target := range map[string]string{
+		"in":          "data",
+		"in-abs":      filepath.Join(root, "data"),
+		"out":         outside,
+		"out-rel":     filepath.Join("..", filepath.Base(outside)),
+		"dangle":      filepath.Join(outside, "missing"),
+		"dangle-in":   "missing",
+		"data/parent": "..",
+		"data/up":     "../..",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skipf("cannot create symlinks: %v", err)
+		}
+	}
+}
+
+func TestSandboxResolve(t *testing.T) {
+	root, outside := testSandbox(t)
+	// out-rel only leaves the root when both are in the same directory.
+	outside = filepath.Join(filepath.Dir(root), "outside")
+	if err := os.Mkdir(outside, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	testTree(t, root, outside)
+
+	tests := []struct {
+		path string
+		want string // relative to root; "" for an error
+	}{
+		{"data/a.txt", "data/a.txt"},
+		{"", "."},
+		{".", "."},
+		{"new/file.txt", "new/file.txt"},
+		// ".." and absolute paths stay under the root.
+		{"..", "."},
+		{"../../etc/passwd", "etc/passwd"},
+		{"data/../../x", "x"},
+		{"/etc/passwd", "etc/passwd"},
+		{"/data/a.txt", "data/a.txt"},
+		// Symlinks resolving inside the root are followed.
+		{"in/a.txt", "data/a.txt"},
+		{"in-abs/a.txt", "data/a.txt"},
+		{"in/new.txt", "data/new.txt"},
+		{"data/parent/data/a.txt", "data/a.txt"},
+		// Symlinks leaving it are not, whether or not the rest exists.
+		{"out", ""},
+		{"out/file", ""},
+		{"out/new/file", ""},
+		{"out-rel", ""},
+		{"data/up", ""},
+		{"data/up/x", ""},
+		// Nor are dangling ones, which creating the path would follow.
+		{"dangle", ""},
+		{"dangle/x", ""},
+		{"dangle-in", ""},
+		{"a\x00b", ""},
+	}
+	for _, tt := range tests {
+		got, err := fsSandbox.resolve(tt.path)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("resolve(%q) = %s, want an error", tt.path, got)
+		case tt.want != "" && err != nil:
+			t.Errorf("resolve(%q): %v", tt.path, err)
+		case tt.want != "" && got != filepath.Join(root, tt.want):
+			t.Errorf("resolve(%q) = %s, want %s", tt.path, got, filepath.Join(root, tt.want))
+		}
+	}
+}
+
+func TestWriteFileDanglingSymlink(t *testing.T) {
+	root, outside := testSandbox(t)
+	target := filepath.Join(outside, "pwned")
+	if err := os.Symlink(target, filepath.Join(root, "link")); err != nil {
+		t.Skipf("cannot create symlinks: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "missing"), filepath.Join(root, "dir", "sub")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, in := range []WriteFileArgs{
+		{Path: "link", Content: "x"},
+		{Path: "link", Content: "x", Append: true},
+		{Path: "dir/sub/file", Content: "x", CreateDirs: true},
+	} {
+		res, _, err := WriteFileTool(context.Background(), &mcp.CallToolRequest{}, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.IsError {
+			t.Errorf("write_file %+v succeeded", in)
+		}
+	}
+	for _, p := range []string{target, filepath.Join(outside, "missing")} {
+		if _, err := os.Lstat(p); !os.IsNotExist(err) {
+			t.Errorf("%s was created outside the sandbox", p)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- REST gateway ---------- */
+
+const (
+	// maxGatewayBodyBytes caps the JSON arguments accepted by the gateway.
+	maxGatewayBodyBytes = 1 << 20
+	// gatewayIdleTimeout closes a caller's session after this long without
+	// a request, and maxGatewaySessions bounds the callers kept, closing
+	// the least recently used.
+	gatewayIdleTimeout = 10 * time.Minute
+	maxGatewaySessions = 256
+)
+
+// restGateway exposes the server's tools as plain HTTP endpoints for
+// clients that don't speak MCP:
+//
+//	GET  /api/tools         list tools with their input and output schemas
+//	POST /api/tools/{name}  call a tool; the body is the JSON arguments
+//
+// Calls go through in-memory MCP sessions with the same server, so
+// argument validation and any server middleware apply exactly as they
+// do for MCP clients. Each caller gets its own session, so set_defaults,
+// learned preferences, roots and workshop progress are not shared: a
+// caller with an Authorization header keeps one session for it, and
+// other requests get a session of their own. The session's requests carry
+// the caller's Authorization header, as Streamable HTTP requests do, for
+// the features that pick a tenant by bearer token.
+type restGateway struct {
+	server *mcp.Server
+	cors   *corsPolicy
+
+	mu       sync.Mutex
+	sessions map[string]*gatewaySession // by Authorization header
+}
+
+type gatewaySession struct {
+	session  *mcp.ClientSession
+	lastUsed time.Time
+	calls    int // in flight; the session is not closed while in use
+}
+
+// newRESTGateway serves server's tools; cors, if set, is the -cors-origins
+// policy of /mcp.
+func newRESTGateway(server *mcp.Server, cors *corsPolicy) *restGateway {
+	g := &restGateway{server: server, cors: cors, sessions: make(map[string]*gatewaySession)}
+	go func() {
+		for range time.Tick(time.Minute) {
+			g.closeIdle(time.Now().Add(-gatewayIdleTimeout))
+		}
+	}()
+	return g
+}
+
+// connect opens a session for the caller of r.
+func (g *restGateway) connect(r *http.Request) (*mcp.ClientSession, error) {
+	header := make(http.Header)
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		header.Set("Authorization", auth)
+	}
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	ss, err := g.server.Connect(context.Background(), &gatewayTransport{Transport: serverTransport, id: "gateway-" + randomHex(16), header: header}, nil)
+	if err != nil {
+		return nil, err
+	}
+	liveSessions.open(ss, "gateway", r)
+	client := mcp.NewClient(&mcp.Implementation{Name: "rest-gateway", Version: version}, nil)
+	session, err := client.Connect(context.Background(), clientTransport, nil)
+	if err != nil {
+		ss.Close()
+		return nil, err
+	}
+	return session, nil
+}
+
+// session returns the session for the caller of r and a function to call
+// when the request is done with it.
+func (g *restGateway) session(r *http.Request) (*mcp.ClientSession, func(), error) {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		session, err := g.connect(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return session, func() { session.Close() }, nil
+	}
+
+	g.mu.Lock()
+	s := g.sessions[auth]
+	if s == nil {
+		g.mu.Unlock()
+		session, err := g.connect(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		g.mu.Lock()
+		if s = g.sessions[auth]; s == nil {
+			s = &gatewaySession{session: session}
+			g.sessions[auth] = s
+			g.evictLocked()
+			go g.forgetClosed(auth, s)
+		} else {
+			// Another request of the caller got there first.
+			defer session.Close()
+		}
+	}
+	s.calls++
+	s.lastUsed = time.Now()
+	g.mu.Unlock()
+	return s.session, func() {
+		g.mu.Lock()
+		s.calls--
+		s.lastUsed = time.Now()
+		g.mu.Unlock()
+	}, nil
+}
+
+// evictLocked closes the least recently used idle sessions while there
+// are more than maxGatewaySessions.
+func (g *restGateway) evictLocked() {
+	for len(g.sessions) > maxGatewaySessions {
+		oldest := ""
+		for auth, s := range g.sessions {
+			if s.calls == 0 && (oldest == "" || s.lastUsed.Before(g.sessions[oldest].lastUsed)) {
+				oldest = auth
+			}
+		}
+		if oldest == "" {
+			return
+		}
+		g.sessions[oldest].session.Close()
+		delete(g.sessions, oldest)
+	}
+}
+
+// forgetClosed drops the caller's session once it ends, for instance
+// when an admin closes it, so that the next request opens a new one.
+func (g *restGateway) forgetClosed(auth string, s *gatewaySession) {
+	s.session.Wait()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.sessions[auth] == s {
+		delete(g.sessions, auth)
+	}
+}
+
+// closeIdle closes the sessions last used before cutoff.
+func (g *restGateway) closeIdle(cutoff time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for auth, s := range g.sessions {
+		if s.calls == 0 && s.lastUsed.Before(cutoff) {
+			s.session.Close()
+			delete(g.sessions, auth)
+		}
+	}
+}
+
+// gatewayTransport is the server side of a gateway session. It names the
+// session, which the in-memory transport leaves unnamed, so that
+// per-session state is kept apart, and gives each request the caller's
+// header the way the Streamable HTTP transport does.
+type gatewayTransport struct {
+	mcp.Transport
+	id     string
+	header http.Header
+}
+
+func (t *gatewayTransport) Connect(ctx context.Context) (mcp.Connection, error) {
+	conn, err := t.Transport.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &gatewayConn{Connection: conn, id: t.id, header: t.header}, nil
+}
+
+type gatewayConn struct {
+	mcp.Connection
+	id     string
+	header http.Header
+}
+
+func (c *gatewayConn) SessionID() string { return c.id }
+
+func (c *gatewayConn) Read(ctx context.Context) (jsonrpc.Message, error) {
+	msg, err := c.Connection.Read(ctx)
+	if req, ok := msg.(*jsonrpc.Request); ok {
+		req.Extra = &mcp.RequestExtra{Header: c.header}
+	}
+	return msg, err
+}
+
+// register adds the gateway routes to mux, behind the CORS policy of /mcp
+// when there is one.
+func (g *restGateway) register(mux *http.ServeMux) {
+	wrap := func(h http.HandlerFunc) http.Handler {
+		if g.cors == nil {
+			return h
+		}
+		return g.cors.wrap(h)
+	}
+	mux.Handle("GET /api/tools", wrap(g.listTools))
+	mux.Handle("POST /api/tools/{name}", wrap(g.callTool))
+}
+
+// gatewayTool is the listing entry for one tool.
+type gatewayTool struct {
+	Name         string `json:"name"`
+	Description  string `json:"description,omitempty"`
+	InputSchema  any    `json:"input_schema"`
+	OutputSchema any    `json:"output_schema,omitempty"`
+}
+
+// gatewayResult is the response body of a tool call.
+type gatewayResult struct {
+	IsError           bool   `json:"is_error"`
+	Text              string `json:"text"`
+	StructuredContent any    `json:"structured_content,omitempty"`
+}
+
+func gatewayTools(ctx context.Context, session *mcp.ClientSession) (map[string]*mcp.Tool, []gatewayTool, error) {
+	byName := make(map[string]*mcp.Tool)
+	var list []gatewayTool
+	for tool, err := range session.Tools(ctx, nil) {
+		if err != nil {
+			return nil, nil, err
+		}
+		byName[tool.Name] = tool
+		list = append(list, gatewayTool{
+			Name:         tool.Name,
+			Description:  tool.Description,
+			InputSchema:  tool.InputSchema,
+			OutputSchema: tool.OutputSchema,
+		})
+	}
+	return byName, list, nil
+}
+
+func (g *restGateway) listTools(w http.ResponseWriter, r *http.Request) {
+	session, done, err := g.session(r)
+	if err != nil {
+		writeGatewayError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	defer done()
+	_, list, err := gatewayTools(r.Context(), session)
+	if err != nil {
+		writeGatewayError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeGatewayJSON(w, http.StatusOK, map[string]any{"tools": list})
+}
+
+func (g *restGateway) callTool(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	session, done, err := g.session(r)
+	if err != nil {
+		writeGatewayError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	defer done()
+	byName, _, err := gatewayTools(r.Context(), session)
+	if err != nil {
+		writeGatewayError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if byName[name] == nil {
+		writeGatewayError(w, http.StatusNotFound, fmt.Sprintf("unknown tool %q", name))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxGatewayBodyBytes))
+	if err != nil {
+		writeGatewayError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	args := map[string]any{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &args); err != nil {
+			writeGatewayError(w, http.StatusBadRequest, "arguments must be a JSON object: "+err.Error())
+			return
+		}
+	}
+
+	// The request ID and trace context are per request, so they travel in
+	// _meta rather than with the session's header.
+	params := &mcp.CallToolParams{Name: name, Arguments: args}
+	meta := mcp.Meta{}
+	for key, header := range map[string]string{requestIDMetaKey: "X-Request-Id", traceparentMetaKey: "Traceparent", tracestateMetaKey: "Tracestate"} {
+		if v := strings.TrimSpace(r.Header.Get(header)); v != "" {
+			meta[key] = v
+		}
+	}
+	if len(meta) > 0 {
+		params.Meta = meta
+	}
+	result, err := session.CallTool(r.Context(), params)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, mcp.ErrConnectionClosed) || r.Context().Err() != nil {
+			status = http.StatusServiceUnavailable
+		}
+		writeGatewayError(w, status, err.Error())
+		return
+	}
+
+	out := gatewayResult{IsError: result.IsError, StructuredContent: result.StructuredContent}
+	for _, c := range result.Content {
+		if text, ok := c.(*mcp.TextContent); ok {
+			out.Text += text.Text
+		}
+	}
+	status := http.StatusOK
+	if result.IsError {
+		status = http.StatusUnprocessableEntity
+	}
+	writeGatewayJSON(w, status, out)
+}
+
+func writeGatewayJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[GATEWAY] encode error: %v", err)
+	}
+}
+
+func writeGatewayError(w http.ResponseWriter, status int, msg string) {
+	writeGatewayJSON(w, status, map[string]string{"error": msg})
+}
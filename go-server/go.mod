@@ -4,9 +4,24 @@ go 1.23.0
 
 toolchain go1.24.4
 
-require github.com/modelcontextprotocol/go-sdk v1.1.0
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/andybalholm/cascadia v1.3.3
+	github.com/antchfx/htmlquery v1.3.5
+	github.com/antchfx/xmlquery v1.5.0
+	github.com/antchfx/xpath v1.3.5
+	github.com/coder/websocket v1.8.14
+	github.com/fsnotify/fsnotify v1.10.1
+	github.com/google/jsonschema-go v0.3.0
+	github.com/klauspost/compress v1.18.0
+	github.com/modelcontextprotocol/go-sdk v1.1.0
+	golang.org/x/net v0.42.0
+	golang.org/x/sys v0.34.0
+	golang.org/x/text v0.27.0
+)
 
 require (
-	github.com/google/jsonschema-go v0.3.0 // indirect
+	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
+	golang.org/x/oauth2 v0.30.0 // indirect
 )
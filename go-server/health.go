@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* ---------- Liveness and readiness ---------- */
+
+// Readiness checks (-ready-checks).
+const (
+	readyCheckNetwork = "network" // -ready-check-url answers, so fetch can work
+	readyCheckDisk    = "disk"    // -fs-root and -snapshot-dir are usable
+	readyCheckConfig  = "config"  // -config still loads and validates
+)
+
+// readiness runs the /readyz checks. /livez only says the process is up
+// and serving; /readyz says whether it can do its work, so an orchestrator
+// restarts a stuck process but only stops routing to one whose
+// dependencies are failing.
+type readiness struct {
+	Checks  []string
+	URL     string // checked with HEAD; empty skips the network check
+	Timeout time.Duration
+
+	configPath string
+	flags      *configFlags
+}
+
+var ready = &readiness{Checks: []string{readyCheckNetwork, readyCheckDisk, readyCheckConfig}, Timeout: 2 * time.Second}
+
+// parseReadyChecks validates -ready-checks.
+func parseReadyChecks(s string) ([]string, error) {
+	var checks []string
+	for _, c := range strings.Split(s, ",") {
+		switch c = strings.TrimSpace(c); c {
+		case "":
+		case readyCheckNetwork, readyCheckDisk, readyCheckConfig:
+			checks = append(checks, c)
+		default:
+			return nil, fmt.Errorf("unknown check %q (want network, disk or config)", c)
+		}
+	}
+	return checks, nil
+}
+
+// checkResult is one check in the /readyz answer.
+type checkResult struct {
+	Status     string  `json:"status"` // ok, failed or skipped
+	DurationMs float64 `json:"duration_ms"`
+	Detail     string  `json:"detail,omitempty"`
+}
+
+type readyReport struct {
+	Status string                 `json:"status"` // ready or not_ready
+	Checks map[string]checkResult `json:"checks"`
+}
+
+// run runs the checks at once, each within Timeout.
+func (rd *readiness) run(ctx context.Context) readyReport {
+	ctx, cancel := context.WithTimeout(ctx, rd.Timeout)
+	defer cancel()
+	rep := readyReport{Status: "ready", Checks: make(map[string]checkResult)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range rd.Checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			res := rd.check(ctx, name)
+			res.DurationMs = roundMs(time.Since(start))
+			mu.Lock()
+			defer mu.Unlock()
+			rep.Checks[name] = res
+			if res.Status == "failed" {
+				rep.Status = "not_ready"
+			}
+		}()
+	}
+	wg.Wait()
+	return rep
+}
+
+func (rd *readiness) check(ctx context.Context, name string) checkResult {
+	var detail string
+	var err error
+	switch name {
+	case readyCheckNetwork:
+		if rd.URL == "" {
+			return checkResult{Status: "skipped", Detail: "no -ready-check-url"}
+		}
+		detail, err = checkNetwork(ctx, rd.URL)
+	case readyCheckDisk:
+		if fsSandbox == nil && snapshots == nil {
+			return checkResult{Status: "skipped", Detail: "no -fs-root"}
+		}
+		detail, err = checkDisk()
+	case readyCheckConfig:
+		if rd.configPath == "" {
+			return checkResult{Status: "skipped", Detail: "no -config"}
+		}
+		if _, err = loadSettings(rd.configPath, rd.flags); err == nil {
+			detail = rd.configPath
+		}
+	}
+	if err != nil {
+		return checkResult{Status: "failed", Detail: err.Error()}
+	}
+	return checkResult{Status: "ok", Detail: detail}
+}
+
+// checkNetwork sends HEAD to url through the client fetch uses. Any HTTP
+// answer counts: the check is about reaching the network, not about the
+// target's health.
+func checkNetwork(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return fmt.Sprintf("%s: %s", url, resp.Status), nil
+}
+
+// checkDisk makes sure the sandbox root, and the snapshot directory if
+// any, are directories the server can write to (or read, for a read-only
+// sandbox).
+func checkDisk() (string, error) {
+	var dirs []string
+	if fsSandbox != nil {
+		if err := checkDir(fsSandbox.Root, !fsSandbox.ReadOnly); err != nil {
+			return "", err
+		}
+		dirs = append(dirs, fsSandbox.Root)
+	}
+	if snapshots != nil {
+		if err := checkDir(snapshots.Dir, true); err != nil {
+			return "", err
+		}
+		dirs = append(dirs, snapshots.Dir)
+	}
+	return strings.Join(dirs, ", "), nil
+}
+
+func checkDir(dir string, writable bool) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if !writable {
+		return nil
+	}
+	f, err := os.CreateTemp(dir, ".readyz-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// livezHandler serves GET /livez: the process is up and serving HTTP.
+func livezHandler(w http.ResponseWriter, r *http.Request) {
+	writeGatewayJSON(w, http.StatusOK, map[string]any{
+		"status":         "ok",
+		"version":        version,
+		"uptime_seconds": int(time.Since(serverStarted).Seconds()),
+	})
+}
+
+// readyzHandler serves GET /readyz: 200 when every check passes or is
+// skipped, 503 otherwise, with each check's result.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	rep := ready.run(r.Context())
+	status := http.StatusOK
+	if rep.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	writeGatewayJSON(w, status, rep)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/* ---------- Outbound request hedging ---------- */
+
+const (
+	// hedgeSamples is how many recent response times are kept per host,
+	// and hedgeMinSamples how many a host needs before it is hedged.
+	hedgeSamples    = 100
+	hedgeMinSamples = 20
+	// maxHedgeHosts caps the hosts tracked; when it fills up it starts
+	// over, which at worst stops hedging some hosts for a while.
+	maxHedgeHosts = 1000
+)
+
+// hedgingTransport sends a second attempt of an idempotent request when
+// the first has had no response headers after the host's Percentile time
+// to headers (at least MinDelay), takes whichever answers first and
+// cancels the other. A slow connection or overloaded backend then costs
+// one extra request rather than the whole wait. Hosts are hedged once
+// hedgeMinSamples requests have been timed.
+type hedgingTransport struct {
+	Percentile float64
+	MinDelay   time.Duration
+	next       http.RoundTripper
+
+	mu    sync.Mutex
+	hosts map[string]*hedgeTimes
+
+	eligible, hedged, hedgeWins, primaryWins atomic.Int64
+}
+
+// hedgeTimes is a ring of recent times to response headers, in seconds.
+type hedgeTimes struct {
+	samples []float64
+	next    int
+}
+
+// hedger is non-nil when -fetch-hedge is set.
+var hedger *hedgingTransport
+
+func newHedgingTransport(percentile float64, minDelay time.Duration, next http.RoundTripper) *hedgingTransport {
+	return &hedgingTransport{Percentile: percentile, MinDelay: minDelay, next: next, hosts: make(map[string]*hedgeTimes)}
+}
+
+type noHedgeKey struct{}
+
+// withoutHedging returns a context whose requests are never hedged, for
+// tools that time or count individual requests.
+func withoutHedging(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noHedgeKey{}, true)
+}
+
+// hedgeable reports whether req may be sent twice: a GET or HEAD without
+// a body whose context allows it.
+func hedgeable(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		return false
+	}
+	no, _ := req.Context().Value(noHedgeKey{}).(bool)
+	return !no
+}
+
+// delay returns how long to wait before hedging a request to host, and
+// false when the host has too few samples.
+func (t *hedgingTransport) delay(host string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.hosts[host]
+	if h == nil || len(h.samples) < hedgeMinSamples {
+		return 0, false
+	}
+	sorted := slices.Clone(h.samples)
+	slices.Sort(sorted)
+	d := time.Duration(percentile(sorted, t.Percentile) * float64(time.Second))
+	return max(d, t.MinDelay), true
+}
+
+// observe records a time to response headers for host.
+func (t *hedgingTransport) observe(host string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.hosts[host]
+	if h == nil {
+		if len(t.hosts) >= maxHedgeHosts {
+			t.hosts = make(map[string]*hedgeTimes)
+		}
+		h = &hedgeTimes{}
+		t.hosts[host] = h
+	}
+	if len(h.samples) < hedgeSamples {
+		h.samples = append(h.samples, d.Seconds())
+	} else {
+		h.samples[h.next] = d.Seconds()
+	}
+	h.next = (h.next + 1) % hedgeSamples
+}
+
+// hedgeAttempt is the outcome of one attempt.
+type hedgeAttempt struct {
+	resp   *http.Response
+	err    error
+	hedge  bool
+	cancel context.CancelFunc
+}
+
+func (t *hedgingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !hedgeable(req) {
+		return t.next.RoundTrip(req)
+	}
+	host := strings.ToLower(req.URL.Hostname())
+	t.eligible.Add(1)
+	delay, ok := t.delay(host)
+	if !ok {
+		start := time.Now()
+		resp, err := t.next.RoundTrip(req)
+		if err == nil {
+			t.observe(host, time.Since(start))
+		}
+		return resp, err
+	}
+
+	results := make(chan hedgeAttempt, 2)
+	send := func(hedge bool) {
+		ctx := req.Context()
+		if hedge {
+			ctx = untraced{ctx}
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		r := req.Clone(ctx)
+		start := time.Now()
+		go func() {
+			resp, err := t.next.RoundTrip(r)
+			if err == nil {
+				t.observe(host, time.Since(start))
+			}
+			results <- hedgeAttempt{resp: resp, err: err, hedge: hedge, cancel: cancel}
+		}()
+	}
+	send(false)
+	pending, hedged := 1, false
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			t.hedged.Add(1)
+			send(true)
+			pending, hedged = pending+1, true
+		case a := <-results:
+			pending--
+			if a.err != nil {
+				a.cancel()
+				if pending > 0 {
+					// The other attempt may still succeed.
+					continue
+				}
+				// A failure before the hedge was due is returned as
+				// is: a second attempt would most likely fail too.
+				return nil, a.err
+			}
+			switch {
+			case a.hedge:
+				t.hedgeWins.Add(1)
+			case hedged:
+				t.primaryWins.Add(1)
+			}
+			// The loser is cancelled; a response it still delivers is
+			// discarded.
+			if pending > 0 {
+				go func() {
+					late := <-results
+					late.cancel()
+					if late.err == nil {
+						late.resp.Body.Close()
+					}
+				}()
+			}
+			a.resp.Body = &cancelOnClose{ReadCloser: a.resp.Body, cancel: a.cancel}
+			return a.resp, nil
+		}
+	}
+}
+
+// untraced hides the caller's httptrace hooks from a hedge attempt: they
+// are not safe to run for two attempts at once. What callers trace, such
+// as fetch's remote address, then comes from the first attempt.
+type untraced struct{ context.Context }
+
+func (c untraced) Value(key any) any {
+	v := c.Context.Value(key)
+	if _, ok := v.(*httptrace.ClientTrace); ok {
+		return nil
+	}
+	return v
+}
+
+// cancelOnClose ends an attempt's context once its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func (t *hedgingTransport) collectMetrics(w *metricsWriter) {
+	w.family("mcp_fetch_hedge_eligible_total", "counter", "Outbound GET and HEAD requests that could be hedged")
+	w.sample("mcp_fetch_hedge_eligible_total", float64(t.eligible.Load()))
+	w.family("mcp_fetch_hedged_total", "counter", "Outbound requests for which a second attempt was sent")
+	w.sample("mcp_fetch_hedged_total", float64(t.hedged.Load()))
+	w.family("mcp_fetch_hedge_wins_total", "counter", "Hedged requests by the attempt that answered first")
+	w.sample("mcp_fetch_hedge_wins_total", float64(t.primaryWins.Load()), "attempt", "primary")
+	w.sample("mcp_fetch_hedge_wins_total", float64(t.hedgeWins.Load()), "attempt", "hedge")
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w.family("mcp_fetch_hedge_delay_seconds", "gauge", "Current hedging delay per host: the percentile of its recent times to response headers")
+	for _, host := range sortedKeys(t.hosts) {
+		h := t.hosts[host]
+		if len(h.samples) < hedgeMinSamples {
+			continue
+		}
+		sorted := slices.Clone(h.samples)
+		slices.Sort(sorted)
+		d := max(percentile(sorted, t.Percentile), t.MinDelay.Seconds())
+		w.sample("mcp_fetch_hedge_delay_seconds", d, "host", host)
+	}
+}
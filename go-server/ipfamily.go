@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+)
+
+/* ---------- Outbound address family ---------- */
+
+// IP family modes for outbound connections (-ip-family).
+const (
+	ipFamilyAuto       = "auto"
+	ipFamilyPreferIPv4 = "prefer-ipv4"
+	ipFamilyPreferIPv6 = "prefer-ipv6"
+	ipFamilyIPv4       = "ipv4"
+	ipFamilyIPv6       = "ipv6"
+)
+
+// defaultHappyEyeballsDelay is how long a connection attempt gets before
+// the next address is tried in parallel (RFC 8305 recommends 250ms).
+const defaultHappyEyeballsDelay = 250 * time.Millisecond
+
+func parseIPFamily(s string) (string, error) {
+	switch s {
+	case ipFamilyAuto, ipFamilyPreferIPv4, ipFamilyPreferIPv6, ipFamilyIPv4, ipFamilyIPv6:
+		return s, nil
+	}
+	return "", fmt.Errorf("%q: want auto, prefer-ipv4, prefer-ipv6, ipv4 or ipv6", s)
+}
+
+// addrFamily names the family of ip as reported in tool results.
+func addrFamily(ip netip.Addr) string {
+	if ip.Unmap().Is4() {
+		return ipFamilyIPv4
+	}
+	return ipFamilyIPv6
+}
+
+// orderAddrs returns the addresses to try, in order. ipv4 and ipv6 keep
+// only that family. The other modes interleave the families, starting
+// with the preferred one (IPv6 for auto, as in RFC 8305), so that a
+// broken family costs one attempt delay rather than a timeout per
+// address.
+func orderAddrs(addrs []netip.Addr, family string) []netip.Addr {
+	var v4, v6 []netip.Addr
+	for _, ip := range addrs {
+		if ip.Is4() {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	switch family {
+	case ipFamilyIPv4:
+		return v4
+	case ipFamilyIPv6:
+		return v6
+	}
+	first, second := v6, v4
+	if family == ipFamilyPreferIPv4 {
+		first, second = v4, v6
+	}
+	out := make([]netip.Addr, 0, len(addrs))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			out = append(out, first[i])
+		}
+		if i < len(second) {
+			out = append(out, second[i])
+		}
+	}
+	return out
+}
+
+// dialHappyEyeballs connects to the first of addrs that answers. Each
+// attempt gets Delay before the next one starts alongside it, and a
+// failed attempt starts the next one at once. With a zero delay the
+// addresses are tried one after another.
+func (c *dnsCache) dialHappyEyeballs(ctx context.Context, network, port string, addrs []netip.Addr) (net.Conn, error) {
+	if c.Delay <= 0 || len(addrs) == 1 {
+		var lastErr error
+		for _, ip := range addrs {
+			conn, err := c.dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type attempt struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan attempt, len(addrs))
+	dial := func(ip netip.Addr) {
+		conn, err := c.dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		results <- attempt{conn, err}
+	}
+	// discard closes the connections of attempts still in flight.
+	discard := func(n int) {
+		for ; n > 0; n-- {
+			if late := <-results; late.conn != nil {
+				late.conn.Close()
+			}
+		}
+	}
+
+	next, pending := 0, 0
+	var lastErr error
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			if next < len(addrs) {
+				go dial(addrs[next])
+				next++
+				pending++
+				timer.Reset(c.Delay)
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				go discard(pending)
+				return r.conn, nil
+			}
+			lastErr = r.err
+			if next < len(addrs) {
+				go dial(addrs[next])
+				next++
+				pending++
+				timer.Reset(c.Delay)
+			} else if pending == 0 {
+				return nil, lastErr
+			}
+		case <-ctx.Done():
+			go discard(pending)
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/* ---------- Janitor ---------- */
+
+// janitor expires state that would otherwise stay until a restart or
+// until its store fills up:
+//
+//   - artifacts older than ArtifactTTL
+//   - expired DNS and ASN cache entries
+//   - per-session state (cached roots, URL history for completions, file
+//     subscriptions, workshop progress) of sessions that have ended
+//   - rotated audit log files older than AuditRetention
+//
+// It runs every Interval and on POST /admin/gc. A zero TTL or retention
+// keeps that kind of state.
+type janitorState struct {
+	Interval       time.Duration
+	ArtifactTTL    time.Duration
+	AuditRetention time.Duration
+
+	run sync.Mutex // one sweep at a time
+
+	mu      sync.Mutex
+	runs    int
+	lastRun time.Time
+	items   map[string]int   // reclaimed by kind
+	bytes   map[string]int64 // reclaimed by kind, where sizes are known
+}
+
+var janitor = &janitorState{
+	Interval:       5 * time.Minute,
+	ArtifactTTL:    24 * time.Hour,
+	AuditRetention: 30 * 24 * time.Hour,
+	items:          make(map[string]int),
+	bytes:          make(map[string]int64),
+}
+
+// janitorKinds are the kinds of state a sweep reclaims.
+var janitorKinds = []string{"artifacts", "asn_cache", "audit_files", "dns_cache", "session_state"}
+
+// gcReport is what one sweep reclaimed.
+type gcReport struct {
+	Started    time.Time        `json:"started"`
+	DurationMs float64          `json:"duration_ms"`
+	Items      map[string]int   `json:"items"`
+	Bytes      map[string]int64 `json:"bytes"`
+}
+
+// start sweeps every Interval in the background.
+func (j *janitorState) start() {
+	if j.Interval <= 0 {
+		return
+	}
+	go func() {
+		for range time.Tick(j.Interval) {
+			if r := j.sweep(); logEnabled(logDebug) {
+				log.Printf("[JANITOR] Reclaimed %v (%v bytes) in %.1f ms", r.Items, r.Bytes, r.DurationMs)
+			}
+		}
+	}()
+}
+
+// sweep reclaims what has expired now.
+func (j *janitorState) sweep() gcReport {
+	j.run.Lock()
+	defer j.run.Unlock()
+	now := time.Now()
+	r := gcReport{Started: now.UTC(), Items: make(map[string]int), Bytes: make(map[string]int64)}
+	for _, kind := range janitorKinds {
+		r.Items[kind] = 0
+	}
+
+	if j.ArtifactTTL > 0 {
+		n, size := artifacts.expire(now.Add(-j.ArtifactTTL))
+		r.Items["artifacts"], r.Bytes["artifacts"] = n, int64(size)
+	}
+	r.Items["dns_cache"] = dnsResolver.expire(now)
+	r.Items["asn_cache"] = expireASNCache(now)
+
+	live := liveSessions.ids()
+	r.Items["session_state"] = clientRoots.retain(live) + recentURLs.retain(live) + prefs.retain(live) + sessionKeys.retain(live) + fileWatches.retain(live) + workshop.retain(live)
+
+	if audit != nil && j.AuditRetention > 0 {
+		r.Items["audit_files"], r.Bytes["audit_files"] = audit.prune(now.Add(-j.AuditRetention))
+	}
+	r.DurationMs = roundMs(time.Since(now))
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.runs++
+	j.lastRun = now
+	for kind, n := range r.Items {
+		j.items[kind] += n
+	}
+	for kind, n := range r.Bytes {
+		j.bytes[kind] += n
+	}
+	return r
+}
+
+// gcHandler serves POST /admin/gc: sweep now and report what was
+// reclaimed.
+func gcHandler(w http.ResponseWriter, r *http.Request) {
+	writeGatewayJSON(w, http.StatusOK, janitor.sweep())
+}
+
+func (j *janitorState) collectMetrics(w *metricsWriter) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	w.family("mcp_janitor_runs_total", "counter", "Janitor sweeps, scheduled and from /admin/gc")
+	w.sample("mcp_janitor_runs_total", float64(j.runs))
+	if !j.lastRun.IsZero() {
+		w.family("mcp_janitor_last_run_timestamp_seconds", "gauge", "When the janitor last swept")
+		w.sample("mcp_janitor_last_run_timestamp_seconds", float64(j.lastRun.Unix()))
+	}
+	w.family("mcp_janitor_reclaimed_total", "counter", "Expired items removed by the janitor, by kind")
+	for _, kind := range janitorKinds {
+		w.sample("mcp_janitor_reclaimed_total", float64(j.items[kind]), "kind", kind)
+	}
+	w.family("mcp_janitor_reclaimed_bytes_total", "counter", "Bytes freed by the janitor, by kind")
+	for _, kind := range []string{"artifacts", "audit_files"} {
+		w.sample("mcp_janitor_reclaimed_bytes_total", float64(j.bytes[kind]), "kind", kind)
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Tool: latency_probe ---------- */
+
+const (
+	// defaultProbeCount and maxProbeCount bound the count argument.
+	defaultProbeCount = 5
+	maxProbeCount     = 20
+	// defaultProbeInterval and maxProbeInterval bound interval_ms.
+	defaultProbeInterval = 100 * time.Millisecond
+	maxProbeInterval     = 5 * time.Second
+)
+
+type LatencyProbeArgs struct {
+	URL        string `json:"url" jsonschema:"URL to probe (must be http or https); redirects are not followed"`
+	Count      int    `json:"count,omitempty" jsonschema:"Number of requests (default 5, max 20; the per-call request budget also applies)"`
+	Method     string `json:"method,omitempty" jsonschema:"GET (default) or HEAD"`
+	KeepAlive  bool   `json:"keep_alive,omitempty" jsonschema:"Reuse the connection between requests; by default every request opens a new one so connect and TLS times are measured each time"`
+	IntervalMs int    `json:"interval_ms,omitempty" jsonschema:"Pause between requests in milliseconds (default 100, max 5000)"`
+}
+
+// LatencySample is the timing of one request. Phases that did not happen,
+// such as TLS for http URLs or connect on a reused connection, are zero.
+type LatencySample struct {
+	Seq        int     `json:"seq"`
+	StatusCode int     `json:"status_code,omitempty"`
+	Reused     bool    `json:"reused,omitempty" jsonschema:"The request went over an existing connection"`
+	DNSMs      float64 `json:"dns_ms" jsonschema:"Name lookup; zero when the server's DNS cache answered"`
+	ConnectMs  float64 `json:"connect_ms" jsonschema:"TCP connect, including trying further addresses"`
+	TLSMs      float64 `json:"tls_ms"`
+	TTFBMs     float64 `json:"ttfb_ms" jsonschema:"From the start of the request to the first response byte"`
+	TotalMs    float64 `json:"total_ms" jsonschema:"From the start of the request to the end of the body"`
+	Bytes      int64   `json:"bytes"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// LatencyStats summarizes one phase over the successful samples, in
+// milliseconds.
+type LatencyStats struct {
+	Samples int     `json:"samples"`
+	Min     float64 `json:"min"`
+	Mean    float64 `json:"mean"`
+	P50     float64 `json:"p50"`
+	P90     float64 `json:"p90"`
+	P95     float64 `json:"p95"`
+	P99     float64 `json:"p99"`
+	Max     float64 `json:"max"`
+}
+
+// LatencyProbeResult is the structured output of the latency_probe tool.
+type LatencyProbeResult struct {
+	URL         string                   `json:"url"`
+	Method      string                   `json:"method"`
+	KeepAlive   bool                     `json:"keep_alive"`
+	RemoteAddr  string                   `json:"remote_addr,omitempty"`
+	Requested   int                      `json:"requested"`
+	Succeeded   int                      `json:"succeeded"`
+	Failed      int                      `json:"failed"`
+	StatusCodes map[string]int           `json:"status_codes,omitempty"`
+	Stats       map[string]*LatencyStats `json:"stats" jsonschema:"Per phase (dns, connect, tls, ttfb, total); dns, connect and tls only count samples where the phase happened"`
+	Samples     []LatencySample          `json:"samples"`
+	Stopped     string                   `json:"stopped,omitempty" jsonschema:"Why the probe ended early, e.g. the request budget ran out"`
+}
+
+// latencyPhases is the order phases are reported in.
+var latencyPhases = []string{"dns", "connect", "tls", "ttfb", "total"}
+
+// LatencyProbeTool sends count requests to a URL one after another and
+// reports where the time went, as measured by httptrace.
+func LatencyProbeTool(ctx context.Context, req *mcp.CallToolRequest, in LatencyProbeArgs) (*mcp.CallToolResult, any, error) {
+	if in.URL == "" {
+		return errorResult("URL is required"), nil, nil
+	}
+	target, err := url.Parse(in.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		return errorResult("URL must be http or https"), nil, nil
+	}
+	if err := egress.Check(ctx, target); err != nil {
+		return errorResult("URL not allowed: " + err.Error()), nil, nil
+	}
+	method := strings.ToUpper(in.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	if method != http.MethodGet && method != http.MethodHead {
+		return errorResult("method must be GET or HEAD"), nil, nil
+	}
+	count := in.Count
+	if count <= 0 {
+		count = defaultProbeCount
+	}
+	count = min(count, maxProbeCount)
+	interval := defaultProbeInterval
+	if in.IntervalMs > 0 {
+		interval = min(time.Duration(in.IntervalMs)*time.Millisecond, maxProbeInterval)
+	}
+
+	// Progress counts requests here, not the bytes of each response.
+	progress := progressFrom(ctx)
+	ctx = withoutTransferProgress(ctx)
+
+	client := *httpClient
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	out := LatencyProbeResult{
+		URL:         in.URL,
+		Method:      method,
+		KeepAlive:   in.KeepAlive,
+		Requested:   count,
+		StatusCodes: map[string]int{},
+		Samples:     []LatencySample{},
+	}
+	for i := 1; i <= count; i++ {
+		if i > 1 {
+			t := time.NewTimer(interval)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+			case <-t.C:
+			}
+		}
+		if ctx.Err() != nil {
+			out.Stopped = "cancelled: " + ctx.Err().Error()
+			break
+		}
+		sample, remote, err := probeLatency(ctx, &client, method, in.URL, in.KeepAlive)
+		if errors.Is(err, errBudgetExceeded) {
+			out.Stopped = err.Error()
+			break
+		}
+		sample.Seq = i
+		if err != nil {
+			if ctx.Err() != nil {
+				out.Stopped = "cancelled: " + ctx.Err().Error()
+				break
+			}
+			sample.Error = err.Error()
+			out.Failed++
+		} else {
+			out.Succeeded++
+			out.StatusCodes[fmt.Sprint(sample.StatusCode)]++
+			if remote != "" {
+				out.RemoteAddr = remote
+			}
+		}
+		out.Samples = append(out.Samples, sample)
+		progress.notify(float64(i), float64(count), fmt.Sprintf("request %d/%d: %s", i, count, sampleOutcome(sample)))
+	}
+	out.Stats = latencyStats(out.Samples)
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: formatLatencyProbe(out)}},
+	}, out, nil
+}
+
+// probeLatency sends one request and times its phases. Unless keepAlive is
+// set the connection is closed afterwards, so the next request dials
+// again. The body is read (up to maxExtractInputBytes) to time the whole
+// transfer.
+func probeLatency(ctx context.Context, client *http.Client, method, rawURL string, keepAlive bool) (LatencySample, string, error) {
+	var (
+		mu                        sync.Mutex
+		sample                    LatencySample
+		remote                    string
+		dnsStart, dnsDone         time.Time
+		connectStart, connectDone time.Time
+		tlsStart, tlsDone         time.Time
+		firstByte                 time.Time
+		inLookup                  bool
+	)
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			dnsStart, inLookup = time.Now(), true
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			dnsDone, inLookup = time.Now(), false
+		},
+		// Connects during the lookup are the resolver's own, to the
+		// nameserver. Parallel attempts to several addresses count from
+		// the first start to the first success.
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			defer mu.Unlock()
+			if !inLookup && connectStart.IsZero() {
+				connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if !inLookup && err == nil && connectDone.IsZero() {
+				connectDone = time.Now()
+			}
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			tlsDone = time.Now()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			sample.Reused = info.Reused
+			remote = info.Conn.RemoteAddr().String()
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			firstByte = time.Now()
+		},
+	}
+
+	// A hedged request would mix the phases of two attempts.
+	httpReq, err := http.NewRequestWithContext(httptrace.WithClientTrace(withoutHedging(ctx), trace), method, rawURL, nil)
+	if err != nil {
+		return sample, "", err
+	}
+	httpReq.Header.Set("User-Agent", fetchUserAgent)
+	httpReq.Close = !keepAlive
+
+	start := time.Now()
+	resp, err := client.Do(httpReq)
+	if err == nil {
+		sample.StatusCode = resp.StatusCode
+		sample.Bytes, err = io.Copy(io.Discard, io.LimitReader(resp.Body, maxExtractInputBytes))
+		resp.Body.Close()
+	}
+	end := time.Now()
+
+	mu.Lock()
+	defer mu.Unlock()
+	span := func(from, to time.Time) float64 {
+		if from.IsZero() || to.IsZero() {
+			return 0
+		}
+		return roundMs(to.Sub(from))
+	}
+	sample.DNSMs = span(dnsStart, dnsDone)
+	sample.ConnectMs = span(connectStart, connectDone)
+	sample.TLSMs = span(tlsStart, tlsDone)
+	sample.TTFBMs = span(start, firstByte)
+	sample.TotalMs = roundMs(end.Sub(start))
+	return sample, remote, err
+}
+
+// roundMs converts d to milliseconds with microsecond precision.
+func roundMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// latencyStats summarizes each phase over the successful samples. The
+// dns, connect and tls phases only include samples where they happened;
+// a phase that never happened is left out.
+func latencyStats(samples []LatencySample) map[string]*LatencyStats {
+	values := map[string][]float64{}
+	for _, s := range samples {
+		if s.Error != "" {
+			continue
+		}
+		for phase, v := range map[string]float64{"dns": s.DNSMs, "connect": s.ConnectMs, "tls": s.TLSMs} {
+			if v > 0 {
+				values[phase] = append(values[phase], v)
+			}
+		}
+		values["ttfb"] = append(values["ttfb"], s.TTFBMs)
+		values["total"] = append(values["total"], s.TotalMs)
+	}
+	stats := map[string]*LatencyStats{}
+	for phase, vs := range values {
+		sort.Float64s(vs)
+		sum := 0.0
+		for _, v := range vs {
+			sum += v
+		}
+		stats[phase] = &LatencyStats{
+			Samples: len(vs),
+			Min:     vs[0],
+			Mean:    math.Round(sum/float64(len(vs))*1000) / 1000,
+			P50:     percentile(vs, 50),
+			P90:     percentile(vs, 90),
+			P95:     percentile(vs, 95),
+			P99:     percentile(vs, 99),
+			Max:     vs[len(vs)-1],
+		}
+	}
+	return stats
+}
+
+// percentile returns the nearest-rank percentile p of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+func sampleOutcome(s LatencySample) string {
+	if s.Error != "" {
+		return "error: " + s.Error
+	}
+	return fmt.Sprintf("%d in %.1fms", s.StatusCode, s.TotalMs)
+}
+
+// formatLatencyProbe renders the summary table followed by one line per
+// request.
+func formatLatencyProbe(out LatencyProbeResult) string {
+	var b strings.Builder
+	conns := "new connection per request"
+	if out.KeepAlive {
+		conns = "keep-alive"
+	}
+	fmt.Fprintf(&b, "%s %s (%s): %d of %d requests succeeded", out.Method, out.URL, conns, out.Succeeded, out.Requested)
+	if out.RemoteAddr != "" {
+		fmt.Fprintf(&b, ", remote %s", out.RemoteAddr)
+	}
+	if len(out.StatusCodes) > 0 {
+		var codes []string
+		for _, code := range sortedKeys(out.StatusCodes) {
+			codes = append(codes, fmt.Sprintf("%s x%d", code, out.StatusCodes[code]))
+		}
+		fmt.Fprintf(&b, "\nStatus: %s", strings.Join(codes, ", "))
+	}
+	if len(out.Stats) > 0 {
+		fmt.Fprintf(&b, "\n%-8s %8s %8s %8s %8s %8s %8s", "ms", "min", "mean", "p50", "p90", "p99", "max")
+		for _, phase := range latencyPhases {
+			if s := out.Stats[phase]; s != nil {
+				fmt.Fprintf(&b, "\n%-8s %8.1f %8.1f %8.1f %8.1f %8.1f %8.1f", phase, s.Min, s.Mean, s.P50, s.P90, s.P99, s.Max)
+			}
+		}
+	}
+	for _, s := range out.Samples {
+		if s.Error != "" {
+			fmt.Fprintf(&b, "\n#%d error: %s", s.Seq, s.Error)
+			continue
+		}
+		fmt.Fprintf(&b, "\n#%d %d dns=%.1f connect=%.1f tls=%.1f ttfb=%.1f total=%.1f bytes=%d",
+			s.Seq, s.StatusCode, s.DNSMs, s.ConnectMs, s.TLSMs, s.TTFBMs, s.TotalMs, s.Bytes)
+		if s.Reused {
+			b.WriteString(" (reused)")
+		}
+	}
+	if out.Stopped != "" {
+		fmt.Fprintf(&b, "\nStopped early: %s", out.Stopped)
+	}
+	return b.String()
+}
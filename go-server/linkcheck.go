@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+/* ---------- Tool: check_links ---------- */
+
+const (
+	// defaultLinkChecks and maxLinkChecks bound the max_links argument.
+	defaultLinkChecks = 50
+	maxLinkChecks     = 200
+	// linkCheckWorkers is how many links are checked at once.
+	linkCheckWorkers = 4
+	// linkCheckHostDelay spaces out requests to the same host.
+	linkCheckHostDelay = 250 * time.Millisecond
+)
+
+// linkAttrs maps elements that refer to other URLs to the attribute
+// holding the URL.
+var linkAttrs = map[atom.Atom]string{
+	atom.A:      "href",
+	atom.Area:   "href",
+	atom.Link:   "href",
+	atom.Img:    "src",
+	atom.Script: "src",
+	atom.Iframe: "src",
+	atom.Source: "src",
+}
+
+// pageLinks returns the distinct http(s) links of an HTML document in
+// document order, resolved against base and without fragments. With
+// anchorsOnly, only <a> and <area> links are returned.
+func pageLinks(base *url.URL, body []byte, anchorsOnly bool) []string {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var out []string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if n.DataAtom == atom.Base && attr(n, "href") != "" {
+				if u, err := base.Parse(attr(n, "href")); err == nil {
+					base = u
+				}
+			}
+			key, ok := linkAttrs[n.DataAtom]
+			if ok && (!anchorsOnly || n.DataAtom == atom.A || n.DataAtom == atom.Area) {
+				if href := strings.TrimSpace(attr(n, key)); href != "" {
+					if u, err := base.Parse(href); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+						u.Fragment = ""
+						if s := u.String(); !seen[s] {
+							seen[s] = true
+							out = append(out, s)
+						}
+					}
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	return out
+}
+
+type CheckLinksArgs struct {
+	URL        string   `json:"url,omitempty" jsonschema:"Page whose links are checked: a, area, link, img, script, iframe and source elements (use either url or links)"`
+	Links      []string `json:"links,omitempty" jsonschema:"URLs to check instead of extracting them from a page"`
+	SameOrigin bool     `json:"same_origin,omitempty" jsonschema:"Only check links on the page's own origin"`
+	MaxLinks   int      `json:"max_links,omitempty" jsonschema:"Maximum links to check (default 50, max 200; the per-call request budget also applies)"`
+}
+
+// LinkCheck is the outcome of checking one link.
+type LinkCheck struct {
+	URL        string `json:"url"`
+	Broken     bool   `json:"broken" jsonschema:"True for 4xx and 5xx status codes and failed requests"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Method     string `json:"method,omitempty" jsonschema:"HEAD, or GET when the server rejected HEAD"`
+	FinalURL   string `json:"final_url,omitempty" jsonschema:"Where the link redirects to, when it does"`
+	Redirects  int    `json:"redirects,omitempty"`
+	LatencyMs  int64  `json:"latency_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// SkippedLink is a link that was not checked.
+type SkippedLink struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// CheckLinksResult is the structured output of the check_links tool.
+type CheckLinksResult struct {
+	Source     string        `json:"source" jsonschema:"The page URL, or links when the links were given"`
+	Found      int           `json:"found" jsonschema:"Distinct links found or given"`
+	Checked    int           `json:"checked"`
+	Broken     int           `json:"broken"`
+	Redirected int           `json:"redirected"`
+	Results    []LinkCheck   `json:"results" jsonschema:"Broken links first, then redirected ones, then the rest"`
+	Skipped    []SkippedLink `json:"skipped,omitempty" jsonschema:"Links not checked: over max_links, refused by the outbound policy or left when the request budget ran out"`
+}
+
+func CheckLinksTool(ctx context.Context, req *mcp.CallToolRequest, in CheckLinksArgs) (*mcp.CallToolResult, any, error) {
+	if (in.URL != "") == (len(in.Links) > 0) {
+		return errorResult("provide exactly one of url or links"), nil, nil
+	}
+	maxLinks := in.MaxLinks
+	if maxLinks <= 0 {
+		maxLinks = defaultLinkChecks
+	}
+	maxLinks = min(maxLinks, maxLinkChecks)
+
+	// Progress counts links here, not the bytes of each response.
+	progress := progressFrom(ctx)
+	ctx = withoutTransferProgress(ctx)
+
+	out := CheckLinksResult{Source: "links", Results: []LinkCheck{}}
+	var links []string
+	if in.URL != "" {
+		out.Source = in.URL
+		data, contentType, err := fetchQueryDocument(ctx, in.URL)
+		if err != nil {
+			return errorResult(err.Error()), nil, nil
+		}
+		if contentType != "" && !isHTML(contentType) {
+			return errorResult(fmt.Sprintf("%s is %s, not an HTML page", in.URL, contentType)), nil, nil
+		}
+		base, _ := url.Parse(in.URL)
+		for _, link := range pageLinks(base, data, false) {
+			if u, _ := url.Parse(link); !in.SameOrigin || (u.Scheme == base.Scheme && strings.EqualFold(u.Host, base.Host)) {
+				links = append(links, link)
+			}
+		}
+	} else {
+		seen := map[string]bool{}
+		for _, link := range in.Links {
+			if link = strings.TrimSpace(link); link != "" && !seen[link] {
+				seen[link] = true
+				links = append(links, link)
+			}
+		}
+	}
+	out.Found = len(links)
+
+	// Links the policy refuses are reported, not checked.
+	var targets []string
+	for _, link := range links {
+		u, err := url.Parse(link)
+		switch {
+		case err != nil:
+			out.Skipped = append(out.Skipped, SkippedLink{URL: link, Reason: "invalid URL: " + err.Error()})
+		case u.Scheme != "http" && u.Scheme != "https":
+			out.Skipped = append(out.Skipped, SkippedLink{URL: link, Reason: "not an http(s) URL"})
+		case len(targets) == maxLinks:
+			out.Skipped = append(out.Skipped, SkippedLink{URL: link, Reason: fmt.Sprintf("over max_links (%d)", maxLinks)})
+		default:
+			if err := egress.Check(ctx, u); err != nil {
+				out.Skipped = append(out.Skipped, SkippedLink{URL: link, Reason: "not allowed: " + err.Error()})
+				continue
+			}
+			targets = append(targets, link)
+		}
+	}
+
+	results := make([]*LinkCheck, len(targets))
+	gate := &hostGate{delay: linkCheckHostDelay, next: make(map[string]time.Time)}
+	var mu sync.Mutex
+	var stopped error
+	done := 0
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(linkCheckWorkers, len(targets)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				mu.Lock()
+				halt := stopped
+				mu.Unlock()
+				if halt != nil {
+					continue
+				}
+				check, err := checkLink(ctx, gate, targets[i])
+				mu.Lock()
+				if err != nil {
+					if stopped == nil {
+						stopped = err
+					}
+				} else {
+					results[i] = check
+					done++
+					progress.notify(float64(done), float64(len(targets)), fmt.Sprintf("checked %d/%d: %s", done, len(targets), targets[i]))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	reason := "request budget exhausted"
+	if !errors.Is(stopped, errBudgetExceeded) {
+		reason = "cancelled"
+	}
+	for i, check := range results {
+		if check == nil {
+			out.Skipped = append(out.Skipped, SkippedLink{URL: targets[i], Reason: reason})
+			continue
+		}
+		out.Results = append(out.Results, *check)
+		out.Checked++
+		switch {
+		case check.Broken:
+			out.Broken++
+		case check.FinalURL != "":
+			out.Redirected++
+		}
+	}
+	rank := func(c LinkCheck) int {
+		switch {
+		case c.Broken:
+			return 0
+		case c.FinalURL != "":
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(out.Results, func(i, j int) bool { return rank(out.Results[i]) < rank(out.Results[j]) })
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: formatLinkChecks(out)}},
+	}, out, nil
+}
+
+// checkLink probes one link after the per-host delay. Only budget and
+// cancellation errors are returned; other failures mark the link broken.
+func checkLink(ctx context.Context, gate *hostGate, link string) (*LinkCheck, error) {
+	u, _ := url.Parse(link)
+	if err := gate.wait(ctx, u.Host); err != nil {
+		return nil, err
+	}
+	check := &LinkCheck{URL: link}
+	start := time.Now()
+	probe, err := probeURL(ctx, link)
+	check.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		if errors.Is(err, errBudgetExceeded) || ctx.Err() != nil {
+			return nil, err
+		}
+		check.Broken, check.Error = true, err.Error()
+		return check, nil
+	}
+	check.LatencyMs = probe.latency.Milliseconds()
+	check.StatusCode, check.Method = probe.resp.StatusCode, probe.method
+	check.Broken = probe.resp.StatusCode >= 400
+	if len(probe.redirects) > 0 {
+		check.FinalURL, check.Redirects = probe.resp.Request.URL.String(), len(probe.redirects)
+	}
+	return check, nil
+}
+
+// hostGate spaces out requests to the same host by delay.
+type hostGate struct {
+	mu    sync.Mutex
+	delay time.Duration
+	next  map[string]time.Time
+}
+
+// wait blocks until a request to host may be sent.
+func (g *hostGate) wait(ctx context.Context, host string) error {
+	g.mu.Lock()
+	at := time.Now()
+	if next := g.next[host]; next.After(at) {
+		at = next
+	}
+	g.next[host] = at.Add(g.delay)
+	g.mu.Unlock()
+
+	t := time.NewTimer(time.Until(at))
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// formatLinkChecks lists broken and redirected links; working links are
+// only counted.
+func formatLinkChecks(out CheckLinksResult) string {
+	var b strings.Builder
+	source := "Links from " + out.Source
+	if out.Source == "links" {
+		source = "Links given"
+	}
+	fmt.Fprintf(&b, "%s: %d found, %d checked, %d broken, %d redirected",
+		source, out.Found, out.Checked, out.Broken, out.Redirected)
+	for _, c := range out.Results {
+		switch {
+		case c.Broken && c.Error != "":
+			fmt.Fprintf(&b, "\nBROKEN %s: %s", c.URL, c.Error)
+		case c.Broken:
+			fmt.Fprintf(&b, "\nBROKEN %d %s", c.StatusCode, c.URL)
+			if c.FinalURL != "" {
+				fmt.Fprintf(&b, " (redirected to %s)", c.FinalURL)
+			}
+		case c.FinalURL != "":
+			fmt.Fprintf(&b, "\nREDIRECT %d %s -> %s", c.StatusCode, c.URL, c.FinalURL)
+		}
+	}
+	if len(out.Skipped) > 0 {
+		fmt.Fprintf(&b, "\nSkipped %d:", len(out.Skipped))
+		for _, s := range out.Skipped {
+			fmt.Fprintf(&b, "\n  %s (%s)", s.URL, s.Reason)
+		}
+	}
+	return b.String()
+}
@@ -2,35 +2,79 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/google/jsonschema-go/jsonschema"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"mcp-demo-server/pkg/payloadcrypt"
+	"mcp-demo-server/pkg/signature"
+	"mcp-demo-server/pkg/wstransport"
 )
 
 const (
-	version         = "v1.1.0"
+	version = "v1.1.0"
+	// defaultMaxBytes and maxCapBytes are the defaults of
+	// -fetch-default-bytes and -fetch-max-bytes.
 	defaultMaxBytes = 4096
 	maxCapBytes     = 65536
 	minCapBytes     = 256
+	// defaultMaxBinaryBytes is the default of -fetch-max-binary-bytes.
+	defaultMaxBinaryBytes = 1 << 20
+	// fetchBytesLimit bounds -fetch-max-bytes: fetched bodies are held in
+	// memory.
+	fetchBytesLimit = 16 << 20
+	// defaultFetchTimeout is the default of -fetch-timeout and
+	// -fetch-connect-timeout.
+	defaultFetchTimeout = 10 * time.Second
 )
 
-// responseWriter wraps http.ResponseWriter to capture the status code
+// responseWriter wraps http.ResponseWriter to capture the status code,
+// the body size and when the headers were sent, for the access log
 // It also implements http.Flusher to support SSE streaming
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	bytes      int64
+	headerAt   time.Time
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
+	if rw.headerAt.IsZero() {
+		rw.statusCode = code
+		rw.headerAt = time.Now()
+	}
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if rw.headerAt.IsZero() {
+		rw.headerAt = time.Now()
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // Flush implements http.Flusher for SSE support
 func (rw *responseWriter) Flush() {
 	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
@@ -39,7 +83,7 @@ func (rw *responseWriter) Flush() {
 }
 
 var httpClient = &http.Client{
-	Timeout: 10 * time.Second,
+	Timeout: defaultFetchTimeout,
 }
 
 func clamp(n, lo, hi int) int {
@@ -55,161 +99,746 @@ func clamp(n, lo, hi int) int {
 	return n
 }
 
+// errorResult builds an IsError tool result carrying a single text message.
+func errorResult(msg string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		IsError: true,
+		Content: []mcp.Content{&mcp.TextContent{Text: msg}},
+	}
+}
+
+// outputSchema infers the output schema for a tool whose handler returns
+// its structured result as `any`, so that error results can omit
+// structured content entirely.
+func outputSchema[T any]() *jsonschema.Schema {
+	schema, err := jsonschema.For[T](nil)
+	if err != nil {
+		panic(fmt.Sprintf("output schema for %T: %v", *new(T), err))
+	}
+	return schema
+}
+
 /* ---------- Tool: echotest ---------- */
 
+const maxEchoRepeat = 100
+
+// echoMaxDelay caps delay_ms; -public-demo lowers it.
+var echoMaxDelay = 30 * time.Second
+
 type EchoArgs struct {
 	// Message to echo back
 	Message string `json:"message" jsonschema:"Message to echo back"`
+	// Transformations, applied in this order: uppercase, reverse, repeat.
+	Uppercase bool `json:"uppercase,omitempty" jsonschema:"Upper-case the message"`
+	Reverse   bool `json:"reverse,omitempty" jsonschema:"Reverse the message (by character)"`
+	Repeat    int  `json:"repeat,omitempty" jsonschema:"Repeat the message this many times, one per line (default 1, max 100)"`
+	// Artificial latency for testing client timeouts and cancellation.
+	DelayMs int `json:"delay_ms,omitempty" jsonschema:"Wait this long before replying (max 30000); progress is reported every second when the request has a progress token"`
 }
 
 func EchotestTool(ctx context.Context, req *mcp.CallToolRequest, in EchoArgs) (*mcp.CallToolResult, any, error) {
+	if in.Repeat < 0 || in.Repeat > maxEchoRepeat {
+		return errorResult(fmt.Sprintf("repeat must be between 1 and %d (0 uses the default)", maxEchoRepeat)), nil, nil
+	}
+	delay := time.Duration(in.DelayMs) * time.Millisecond
+	if delay < 0 || delay > echoMaxDelay {
+		return errorResult(fmt.Sprintf("delay_ms must be between 0 and %d", echoMaxDelay.Milliseconds())), nil, nil
+	}
+	if err := echoDelay(ctx, delay); err != nil {
+		return errorResult("echo cancelled: " + err.Error()), nil, nil
+	}
+
+	msg := in.Message
+	if in.Uppercase {
+		msg = strings.ToUpper(msg)
+	}
+	if in.Reverse {
+		runes := []rune(msg)
+		slices.Reverse(runes)
+		msg = string(runes)
+	}
+	if in.Repeat > 1 {
+		msg = strings.TrimSuffix(strings.Repeat(msg+"\n", in.Repeat), "\n")
+	}
+
 	return &mcp.CallToolResult{
-		Content: []mcp.Content{&mcp.TextContent{Text: in.Message}},
+		Content: []mcp.Content{&mcp.TextContent{Text: msg}},
 	}, nil, nil
 }
 
+// echoDelay sleeps for d, sending a progress notification each second
+// when the caller asked for progress, and stops early on cancellation.
+func echoDelay(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	done := time.NewTimer(d)
+	defer done.Stop()
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	start := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-done.C:
+			return nil
+		case <-tick.C:
+			progressFrom(ctx).notify(float64(time.Since(start).Milliseconds()), float64(d.Milliseconds()), "waiting")
+		}
+	}
+}
+
 /* ---------- Tool: timeserver ---------- */
 
+// timeFormats are the named presets accepted by timeserver's format
+// argument; anything else is used as a Go layout string.
+var timeFormats = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"iso8601":     time.RFC3339,
+	"rfc1123":     time.RFC1123,
+	"rfc1123z":    time.RFC1123Z,
+	"rfc822":      time.RFC822,
+	"rfc822z":     time.RFC822Z,
+	"rfc850":      time.RFC850,
+	"ansic":       time.ANSIC,
+	"unixdate":    time.UnixDate,
+	"kitchen":     time.Kitchen,
+	"stamp":       time.Stamp,
+	"datetime":    time.DateTime,
+	"date":        time.DateOnly,
+	"time":        time.TimeOnly,
+}
+
 type TimeArgs struct {
-	// IANA timezone, e.g. "Europe/Kyiv". Empty -> system local tz.
-	Timezone string `json:"timezone,omitempty" jsonschema:"IANA timezone, e.g. Europe/Kyiv"`
+	// IANA timezone, e.g. "Europe/Kyiv". Empty -> session default, then
+	// system local tz.
+	Timezone string `json:"timezone,omitempty" jsonschema:"IANA timezone, e.g. Europe/Kyiv (default: the session default from set_defaults, else server local time)"`
+	// Named preset or Go layout string, e.g. "kitchen" or "02 Jan 2006 15:04".
+	Format string `json:"format,omitempty" jsonschema:"Extra formatted field: a preset (rfc3339, rfc1123, rfc822, kitchen, datetime, date, time, unixdate, ...) or a Go layout string such as '02 Jan 2006 15:04'"`
+}
+
+// TimeResult is the structured output of the timeserver tool.
+type TimeResult struct {
+	Timezone     string `json:"timezone"`
+	ISO8601Local string `json:"iso8601_local"`
+	ISO8601UTC   string `json:"iso8601_utc"`
+	Unix         int64  `json:"unix"`
+	UnixMs       int64  `json:"unix_ms"`
+	UTCOffset    string `json:"utc_offset" jsonschema:"Offset from UTC as +HH:MM"`
+	Abbrev       string `json:"abbrev" jsonschema:"Zone abbreviation, e.g. EEST"`
+	Weekday      string `json:"weekday"`
+	IsDST        bool   `json:"is_dst"`
+	Formatted    string `json:"formatted,omitempty" jsonschema:"The time rendered with the format argument"`
+}
+
+// timeLayout resolves a format argument to a Go layout string.
+func timeLayout(format string) (string, error) {
+	if layout, ok := timeFormats[strings.ToLower(format)]; ok {
+		return layout, nil
+	}
+	// A layout without any reference-time element formats to itself.
+	if time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC).Format(format) == format {
+		return "", fmt.Errorf("format %q is neither a preset nor a Go layout (use elements of Mon Jan 2 15:04:05 MST 2006)", format)
+	}
+	return format, nil
 }
 
 func TimeServerTool(ctx context.Context, req *mcp.CallToolRequest, in TimeArgs) (*mcp.CallToolResult, any, error) {
-	loc := time.Local
-	var err error
-	if in.Timezone != "" {
-		loc, err = time.LoadLocation(in.Timezone)
-		if err != nil {
-			return &mcp.CallToolResult{
-				IsError: true,
-				Content: []mcp.Content{
-					&mcp.TextContent{Text: fmt.Sprintf("invalid timezone %q: %v", in.Timezone, err)},
-				},
-			}, nil, nil
-		}
+	loc, err := sessionLocation(req, in.Timezone, time.Local)
+	var layout string
+	if err == nil && in.Format != "" {
+		layout, err = timeLayout(in.Format)
+	}
+	if err != nil {
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: err.Error()},
+			},
+		}, nil, nil
 	}
 
-	nowLocal := time.Now().In(loc)
-	nowUTC := time.Now().UTC()
+	now := time.Now()
+	nowLocal := now.In(loc)
+	nowUTC := now.UTC()
+	abbrev, offset := nowLocal.Zone()
+
+	result := TimeResult{
+		Timezone:     loc.String(),
+		ISO8601Local: nowLocal.Format(time.RFC3339Nano),
+		ISO8601UTC:   nowUTC.Format(time.RFC3339Nano),
+		Unix:         nowLocal.Unix(),
+		UnixMs:       nowLocal.UnixMilli(),
+		UTCOffset:    formatOffset(offset),
+		Abbrev:       abbrev,
+		Weekday:      nowLocal.Weekday().String(),
+		IsDST:        nowLocal.IsDST(),
+	}
 
 	out := fmt.Sprintf(
 		"now_local=%s (tz=%s)\nnow_utc=%s\nunix=%d",
-		nowLocal.Format(time.RFC3339Nano),
-		loc.String(),
-		nowUTC.Format(time.RFC3339Nano),
-		nowLocal.Unix(),
+		result.ISO8601Local,
+		result.Timezone,
+		result.ISO8601UTC,
+		result.Unix,
 	)
+	if layout != "" {
+		result.Formatted = nowLocal.Format(layout)
+		out += "\nformatted=" + result.Formatted
+	}
 
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{&mcp.TextContent{Text: out}},
-	}, nil, nil
+	}, result, nil
 }
 
-/* ---------- Tool: fetch ---------- */
-
-type FetchArgs struct {
-	// URL to fetch
-	URL string `json:"url" jsonschema:"URL to fetch (must be http or https)"`
-	// Max bytes of the response body to return (defaults to 4096, [256..65536]).
-	MaxBytes int `json:"max_bytes,omitempty" jsonschema:"Limit response body bytes (default 4096, min 256, max 65536)"`
-}
+/* ---------- main ---------- */
 
-func FetchTool(ctx context.Context, req *mcp.CallToolRequest, in FetchArgs) (*mcp.CallToolResult, any, error) {
-	// Validate URL
-	if in.URL == "" {
-		return &mcp.CallToolResult{
-			IsError: true,
-			Content: []mcp.Content{&mcp.TextContent{Text: "URL is required"}},
-		}, nil, nil
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		os.Exit(runReportCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		os.Exit(runRestoreCommand(os.Args[2:]))
 	}
 
-	// Validate URL scheme
-	if len(in.URL) < 7 || (in.URL[:7] != "http://" && in.URL[:8] != "https://") {
-		return &mcp.CallToolResult{
-			IsError: true,
-			Content: []mcp.Content{&mcp.TextContent{Text: "URL must start with http:// or https://"}},
-		}, nil, nil
+	// Command-line flags
+	mode := flag.String("mode", defaultMode, "Transport mode: stdio, http, both as stdio,http, or browser (postMessage, in a js/wasm build)")
+	port := flag.String("port", "8080", "HTTP port for network mode")
+	host := flag.String("host", "0.0.0.0", "Host address to bind to")
+	fetchHeaders := flag.String("fetch-allowed-headers", defaultFetchAllowedHeaders, "Comma-separated request headers the fetch tool may set")
+	fetchMaxBytes := flag.Int("fetch-max-bytes", maxCapBytes, fmt.Sprintf("Largest max_bytes a fetch call may ask for (%d to %d)", minCapBytes, fetchBytesLimit))
+	fetchDefaultBytes := flag.Int("fetch-default-bytes", defaultMaxBytes, "Response bytes fetch returns when a call gives no max_bytes (capped at -fetch-max-bytes)")
+	fetchMaxBinaryBytes := flag.Int("fetch-max-binary-bytes", defaultMaxBinaryBytes, fmt.Sprintf("Largest binary body (image, audio, PDF...) fetch returns as a content block (%d to %d)", minCapBytes, fetchBytesLimit))
+	fetchTimeout := flag.Duration("fetch-timeout", defaultFetchTimeout, "Time an outbound HTTP request may take, body included; also the longest timeout_ms a fetch call may ask for")
+	fetchConnectTimeout := flag.Duration("fetch-connect-timeout", defaultFetchTimeout, "Time an outbound connection may take to establish")
+	fetchMaxHeaderBytes := flag.Int64("fetch-max-header-bytes", defaultFetchMaxHeaderBytes, "Largest response header block outbound HTTP requests accept, in bytes")
+	fetchMaxConnsPerHost := flag.Int("fetch-max-conns-per-host", defaultFetchMaxConnsPerHost, "Outbound connections one host may have open, idle or in use; further requests wait (0: no limit)")
+	fetchMaxIdlePerHost := flag.Int("fetch-max-idle-per-host", defaultFetchMaxIdlePerHost, "Idle outbound connections kept per host for reuse")
+	fetchIdleTimeout := flag.Duration("fetch-idle-timeout", defaultFetchIdleTimeout, "Time an idle outbound connection is kept for reuse")
+	fetchHTTP2 := flag.Bool("fetch-http2", true, "Let outbound TLS connections negotiate HTTP/2")
+	downloadMaxSize := flag.Int("download-max-size", defaultDownloadMaxBytes>>20, "Largest file the download tool stores, in MiB")
+	downloadQuota := flag.Int("download-quota", defaultDownloadQuota>>20, "Disk space the download tool's files may use together, in MiB; the oldest are deleted first")
+	fetchRetries := flag.Int("fetch-retries", defaultFetchRetries, "Times fetch sends an idempotent request again after a dropped connection or a 408, 429, 500, 502, 503 or 504 response, unless the call says otherwise (max 5)")
+	fetchRetryBackoff := flag.Duration("fetch-retry-backoff", defaultFetchRetryBackoff, "Wait before fetch's first retry, doubled for each further one, with jitter")
+	fetchRetryMaxWait := flag.Duration("fetch-retry-max-wait", defaultFetchRetryMaxWait, "Longest wait before a fetch retry; a Retry-After asking for more ends the retries")
+	downloadTimeout := flag.Duration("download-timeout", defaultDownloadTimeout, "Time a download may take, body included")
+	fetchHedge := flag.Bool("fetch-hedge", false, "Hedge outbound GET and HEAD requests: when a host is slower than usual to respond, send a second attempt and use whichever answers first")
+	fetchHedgePercentile := flag.Float64("fetch-hedge-percentile", 95, "With -fetch-hedge, send the second attempt after this percentile of the host's recent times to response headers")
+	fetchHedgeMinDelay := flag.Duration("fetch-hedge-min-delay", 50*time.Millisecond, "With -fetch-hedge, never send the second attempt sooner than this")
+	denyPrivate := flag.Bool("fetch-deny-private", false, "Reject outbound requests (including redirect hops) to loopback, private and link-local addresses")
+	fsRoot := flag.String("fs-root", "", "Directory exposed to the read_file, write_file and list_dir tools (disabled when empty)")
+	fsClientRoots := flag.Bool("fs-client-roots", true, "Limit the filesystem tools to the directories where -fs-root overlaps the roots the client lists")
+	fsReadOnly := flag.Bool("fs-read-only", false, "Expose only read_file and list_dir under -fs-root")
+	enableExec := flag.Bool("enable-exec", false, "Expose the exec tool, which runs commands from -exec-allow")
+	enableNetDiag := flag.Bool("enable-net-diag", false, "Expose the traceroute and path_mtu tools, which send ICMP or UDP probe packets")
+	asnProvider := flag.String("asn-provider", asnProviderRIPEstat, "Source of the asn_lookup tool's routing data: ripestat (RIPEstat API) or cymru (Team Cymru whois)")
+	asnCacheTTL := flag.Duration("asn-cache-ttl", defaultASNCacheTTL, "How long asn_lookup remembers an answer (0 disables the cache)")
+	execAllow := flag.String("exec-allow", defaultExecAllow, "Comma-separated commands the exec tool may run (name or name=/absolute/path)")
+	agents := flag.String("delegate-agents", "", "Comma-separated name=URL agents for the delegate tool (http(s):// endpoints or mcp+http(s)://host/mcp#tool)")
+	restGatewayFlag := flag.Bool("rest-gateway", false, "In http mode, also expose tools as REST endpoints under /api/tools")
+	publicDemoFlag := flag.Bool("public-demo", false, "Run as a public playground: only echotest, timeserver and a restricted fetch, per-client rate limits and anonymized logs")
+	publicDemoHosts := flag.String("public-demo-hosts", defaultPublicDemoHosts, "Comma-separated hosts fetch may contact in -public-demo mode (subdomains included)")
+	publicDemoRate := flag.Float64("public-demo-rate", 30, "Requests per minute per client address in -public-demo mode")
+	signResponses := flag.Bool("sign-responses", false, "Sign every tool result with Ed25519 (signature in _meta, public key at "+signature.WellKnownPath+")")
+	signKey := flag.String("sign-key", "", "PEM PKCS#8 Ed25519 private key for -sign-responses (default: a key generated at startup)")
+	encryptPayloads := flag.Bool("encrypt-payloads", false, "Require network clients to encrypt requests and results end to end (X25519 + AES-GCM, public key at "+payloadcrypt.WellKnownPath+")")
+	encryptKey := flag.String("encrypt-key", "", "PEM PKCS#8 X25519 private key for -encrypt-payloads (default: a key generated at startup)")
+	breakerFailures := flag.Int("breaker-failures", 5, "Consecutive failures (errors or 5xx) that open an outbound host's circuit; 0 disables circuit breakers")
+	breakerCooldown := flag.Duration("breaker-cooldown", 30*time.Second, "How long an open circuit rejects requests before a probe is let through")
+	fetchProxyFlag := flag.String("fetch-proxy", "", "Proxy for outbound requests: http://, https:// or socks5://, with user:password@ for an authenticated proxy, or direct to ignore the environment (default: HTTP_PROXY/HTTPS_PROXY; NO_PROXY applies either way)")
+	dnsPins := flag.String("dns-pins", "", "Comma-separated host=ip entries that outbound requests use instead of DNS (repeat a host for several addresses)")
+	dnsNegativeTTL := flag.Duration("dns-negative-ttl", defaultDNSNegativeTTL, "How long outbound DNS lookups remember that a name does not exist (0 disables)")
+	ipFamily := flag.String("ip-family", ipFamilyAuto, "Address family for outbound connections: auto (dual-stack, IPv6 first), prefer-ipv4, prefer-ipv6, ipv4 or ipv6")
+	happyEyeballsDelay := flag.Duration("happy-eyeballs-delay", defaultHappyEyeballsDelay, "How long an outbound connection attempt runs before the next address is tried in parallel (0 tries addresses one at a time)")
+	callMaxRequests := flag.Int("call-max-requests", callBudgetLimits.Requests, "Upstream requests one tool call may make, including redirects and retries (0: unlimited)")
+	callMaxBytes := flag.Int64("call-max-bytes", callBudgetLimits.Bytes, "Upstream response bytes one tool call may read (0: unlimited)")
+	callMaxTime := flag.Duration("call-max-time", callBudgetLimits.Time, "Total upstream time one tool call may use (0: unlimited)")
+	callTimeoutFlag := flag.Duration("call-timeout", callDeadlines.Default, "Deadline of a tool call whose client sets none in _meta[\""+timeoutMetaKey+"\"] (0: none)")
+	callMaxTimeout := flag.Duration("call-max-timeout", callDeadlines.Max, "Longest deadline a client may ask for in _meta[\""+timeoutMetaKey+"\"] (0: no cap)")
+	maxConcurrentCalls := flag.Int("max-concurrent-calls", 0, "Tool calls run at once; more wait in a queue by priority class (0: unlimited, no queue)")
+	maxQueuedCalls := flag.Int("max-queued-calls", 100, "Tool calls that may wait for a slot before calls are shed, lowest priority first")
+	priorityConfigPath := flag.String("priority-config", "", "JSON file mapping bearer tokens to priority classes (interactive, normal, background)")
+	sessionIdleTimeout := flag.Duration("session-idle-timeout", sessionLimits.IdleTimeout, "In http mode, close sessions that send no request for this long (0: never)")
+	maxSessions := flag.Int("max-sessions", 0, "In http mode, refuse new sessions with 503 while this many are open (0: unlimited)")
+	adminToken := flag.String("admin-token", "", "In http mode, serve the admin and debug endpoints (/admin/sessions, /admin/breakers, /debug/) to requests with this bearer token")
+	auditFlag := flag.Bool("audit", false, "Record every tool call, with redacted arguments, in the -audit-log file")
+	auditLogPath := flag.String("audit-log", "audit.jsonl", "Audit log file, one JSON line per tool call; giving it also turns on -audit")
+	auditMaxSize := flag.Int("audit-max-size", 100, "Rotate the audit log when it reaches this many MiB (0: never)")
+	auditMaxFiles := flag.Int("audit-max-files", 5, "Rotated audit log files to keep, as <audit-log>.1 (newest) and up")
+	auditRedactFields := flag.String("audit-redact-fields", defaultAuditRedactFields, "Comma-separated names; audited arguments, nested fields and URL query parameters whose name contains one are masked")
+	anomalyFlag := flag.Bool("anomaly-detection", false, "Raise events for unusual tool usage: bursts of new hosts, repeated policy-blocked calls, outsized results")
+	alertWebhook := flag.String("alert-webhook", "", "POST events such as anomalies as JSON to this URL (a Slack incoming webhook works)")
+	corsOrigins := flag.String("cors-origins", "", "In http mode, comma-separated origins browser clients may call /mcp from: https://app.example.com, https://*.example.com or * (default: none)")
+	corsHeaders := flag.String("cors-headers", "", "Comma-separated request headers to allow cross-origin besides the MCP ones")
+	corsCredentials := flag.Bool("cors-credentials", false, "Let cross-origin requests carry cookies and HTTP authentication (not with -cors-origins=*)")
+	corsMaxAge := flag.Duration("cors-max-age", 10*time.Minute, "How long browsers may cache a CORS preflight answer")
+	compressionFlag := flag.String("compression", "", "In http mode, compress responses, including SSE streams, with the first of these encodings the client accepts: zstd, gzip (default: none)")
+	webSocketFlag := flag.Bool("websocket", false, "In http mode, also serve MCP over WebSocket at /ws, for gateways that do not pass Server-Sent Events through")
+	webSocketPing := flag.Duration("websocket-ping-interval", 30*time.Second, "Ping WebSocket clients this often and drop those whose pong does not arrive in time (0: never)")
+	webSocketMaxMessage := flag.Int64("websocket-max-message", wstransport.DefaultMaxMessageSize, "Largest WebSocket message accepted from a client, in bytes; larger ones close the connection")
+	janitorInterval := flag.Duration("janitor-interval", janitor.Interval, "How often to expire old artifacts, cache entries, state of ended sessions and audit files (0: only on POST /admin/gc)")
+	artifactTTL := flag.Duration("artifact-ttl", janitor.ArtifactTTL, "Expire artifacts older than this (0: keep until evicted)")
+	auditRetention := flag.Duration("audit-retention", janitor.AuditRetention, "Delete rotated audit log files older than this (0: keep -audit-max-files)")
+	accessLogFormat := flag.String("access-log-format", "", "In http mode, log each request once answered as common, combined or json, instead of the [REQUEST]/[RESPONSE] lines")
+	accessLogPath := flag.String("access-log", "", "File for -access-log-format lines (default: standard error)")
+	snapshotDir := flag.String("snapshot-dir", "", "Keep snapshots of -fs-root in this directory: at startup, every -snapshot-interval when changed, and on POST /admin/snapshots")
+	snapshotInterval := flag.Duration("snapshot-interval", time.Hour, "How often to snapshot -fs-root if write_file changed it (0: at startup and on request only)")
+	snapshotKeep := flag.Int("snapshot-keep", 24, "Snapshots to keep; older ones are deleted (0: keep all)")
+	readyChecks := flag.String("ready-checks", strings.Join(ready.Checks, ","), "Comma-separated /readyz checks: network (-ready-check-url), disk (-fs-root, -snapshot-dir), config (-config)")
+	readyCheckURL := flag.String("ready-check-url", "", "URL /readyz sends HEAD to through the fetch client, to check outbound access (default: skip the network check)")
+	readyTimeout := flag.Duration("ready-timeout", ready.Timeout, "Time /readyz gives its checks")
+	metricsFlag := flag.Bool("metrics", false, "In http mode, serve Prometheus metrics at /metrics")
+	logToolCallsFlag := flag.Bool("log-tool-calls", false, "Log each tool call's name, outcome and duration (never its arguments)")
+	logLevel := flag.String("log-level", logInfo, "Logging: debug (adds tool calls), info (adds every HTTP request) or warn")
+	redactionConfigPath := flag.String("redaction-config", "", "JSON file of output redaction profiles and the bearer tokens of tenants they apply to")
+	configPath := flag.String("config", "", "JSON config file; flags override its settings. Reloaded on SIGHUP and when the file changes")
+	printConfigSchema := flag.Bool("print-config-schema", false, "Print the JSON Schema of the -config file and exit")
+	profile := flag.String("profile", "", "Profile of the -config file to apply over its top-level settings, e.g. dev or prod")
+	configWatch := flag.Duration("config-watch", 5*time.Second, "How often to check -config for changes (0: reload on SIGHUP only)")
+	enableTools := flag.String("enable-tools", "", "Comma-separated tools to register, leaving out all others (default: all available)")
+	disableTools := flag.String("disable-tools", "", "Comma-separated tools not to register")
+	installServiceFlag := flag.Bool("install-service", false, "Install the server, with the other arguments given, as a Windows service that starts automatically, and exit")
+	uninstallServiceFlag := flag.Bool("uninstall-service", false, "Stop and remove the Windows service, and exit")
+	serviceName := flag.String("service-name", defaultServiceName, "Name of the Windows service, and of the default state and log directories")
+	stateDir := flag.String("state-dir", "", "Directory that a relative -snapshot-dir is in (default: the platform's state directory when run as a service, else the current directory)")
+	logDir := flag.String("log-dir", "", "Directory that relative -audit-log and -access-log files are in (default: the platform's log directory when run as a service, else the current directory)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "On SIGTERM, interrupt or a service stop, how long open requests and streams may take before they are cut")
+	workshopFlag := flag.Bool("workshop", false, "Add guided workshop prompts and a progress resource; without -fs-root, seeds a temporary directory with exercise files")
+	// Flags > MCP_* variables > defaults, so that a container image can be
+	// configured from its environment.
+	if err := setFlagsFromEnv(flag.CommandLine); err != nil {
+		log.Fatalf("Invalid environment: %v", err)
 	}
+	flag.Parse()
 
-	maxBytes := clamp(in.MaxBytes, minCapBytes, maxCapBytes)
+	if *printConfigSchema {
+		data, err := json.MarshalIndent(configSchema(), "", "  ")
+		if err != nil {
+			log.Fatalf("Config schema: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, in.URL, nil)
+	if *installServiceFlag || *uninstallServiceFlag {
+		if *installServiceFlag {
+			if err := installService(*serviceName, serviceArgs(os.Args[1:])); err != nil {
+				log.Fatalf("Installing service %s: %v", *serviceName, err)
+			}
+			log.Printf("Installed service %s", *serviceName)
+		} else {
+			if err := uninstallService(*serviceName); err != nil {
+				log.Fatalf("Removing service %s: %v", *serviceName, err)
+			}
+			log.Printf("Removed service %s", *serviceName)
+		}
+		return
+	}
+	if runningAsService() {
+		if *stateDir == "" {
+			*stateDir = defaultStateDir(*serviceName)
+		}
+		if *logDir == "" {
+			*logDir = defaultLogDir(*serviceName)
+		}
+	}
+	if *stateDir != "" {
+		path, err := inDir(*stateDir, uptimeFileName)
+		if err == nil {
+			err = availability.open(path)
+		}
+		if err != nil {
+			log.Printf("Uptime: %v; restarts are not counted", err)
+		}
+		availability.start()
+	}
+	// A Windows service has no console to log to.
+	if isWindowsService() {
+		path, err := inDir(*logDir, "server.log")
+		if err != nil {
+			log.Fatalf("Invalid -log-dir: %v", err)
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+		if err != nil {
+			log.Fatalf("Server log: %v", err)
+		}
+		log.SetOutput(f)
+	}
+	// Stop gracefully on SIGTERM, an interrupt or a service stop request; a
+	// second signal stops at once.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	service, err := startService(*serviceName, stop)
 	if err != nil {
-		return &mcp.CallToolResult{
-			IsError: true,
-			Content: []mcp.Content{&mcp.TextContent{Text: "Invalid URL: " + err.Error()}},
-		}, nil, nil
+		log.Fatalf("Service: %v", err)
 	}
-	httpReq.Header.Set("User-Agent", "mcp-server-demo-go/1.0 (+https://example.local)")
 
-	resp, err := httpClient.Do(httpReq)
-	if err != nil {
-		return &mcp.CallToolResult{
-			IsError: true,
-			Content: []mcp.Content{&mcp.TextContent{Text: "Fetch error: " + err.Error()}},
-		}, nil, nil
+	if *publicDemoFlag {
+		// Everything that can touch the host or other services stays off.
+		if *fsRoot != "" || *enableExec || *enableNetDiag || *agents != "" || *restGatewayFlag {
+			log.Printf("Public demo mode: ignoring -fs-root, -enable-exec, -enable-net-diag, -delegate-agents and -rest-gateway")
+		}
+		*fsRoot, *enableExec, *enableNetDiag, *agents, *restGatewayFlag = "", false, false, "", false
+		if *publicDemoRate <= 0 {
+			log.Fatalf("Invalid -public-demo-rate: must be positive")
+		}
 	}
-	defer resp.Body.Close()
 
-	limited := io.LimitReader(resp.Body, int64(maxBytes))
-	body, err := io.ReadAll(limited)
+	var workshopDir string
+	if *workshopFlag && *fsRoot == "" && !*publicDemoFlag {
+		var err error
+		if workshopDir, err = seedWorkshopDir(); err != nil {
+			log.Fatalf("Workshop: %v", err)
+		}
+		*fsRoot = workshopDir
+	}
+
+	cfgFlags := &configFlags{
+		given:          make(map[string]bool),
+		Profile:        *profile,
+		LogLevel:       *logLevel,
+		FetchHeaders:   *fetchHeaders,
+		FetchMaxBytes:  *fetchMaxBytes,
+		FetchDefault:   *fetchDefaultBytes,
+		FetchMaxBinary: *fetchMaxBinaryBytes,
+		PublicDemoRate: *publicDemoRate,
+		EnableTools:    *enableTools,
+		DisableTools:   *disableTools,
+	}
+	flag.Visit(func(f *flag.Flag) { cfgFlags.given[f.Name] = true })
+	modes, err := parseModes(*mode)
 	if err != nil {
-		return &mcp.CallToolResult{
-			IsError: true,
-			Content: []mcp.Content{&mcp.TextContent{Text: "Read error: " + err.Error()}},
-		}, nil, nil
+		log.Fatalf("Invalid -mode: %v", err)
+	}
+	if err := checkBrowserFlags(*mode, cfgFlags.given); err != nil {
+		log.Fatal(err)
+	}
+	cfg, cfgErr := loadSettings(*configPath, cfgFlags)
+	if cfgErr != nil {
+		log.Fatalf("Invalid configuration: %v", cfgErr)
 	}
+	toolSet.set(cfg.Tools)
 
-	truncatedNote := ""
-	if resp.ContentLength > 0 && resp.ContentLength > int64(maxBytes) {
-		truncatedNote = " (truncated)"
+	readyCheckList, readyErr := parseReadyChecks(*readyChecks)
+	if readyErr != nil {
+		log.Fatalf("Invalid -ready-checks: %v", readyErr)
 	}
+	if u, err := url.Parse(*readyCheckURL); *readyCheckURL != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		log.Fatalf("Invalid -ready-check-url: want an http or https URL")
+	}
+	ready.Checks, ready.URL, ready.Timeout = readyCheckList, *readyCheckURL, *readyTimeout
+	ready.configPath, ready.flags = *configPath, cfgFlags
 
-	result := fmt.Sprintf("URL: %s\nStatus: %s\nBytes: %d%s\n\n%s",
-		in.URL, resp.Status, len(body), truncatedNote, string(body))
+	egress.DenyPrivate = *denyPrivate
+	if *publicDemoFlag {
+		applyPublicDemo(&publicDemoConfig{Hosts: parseHostList(*publicDemoHosts), RatePerMinute: cfg.PublicDemo.RatePerMinute})
+	}
+	applySettings(cfg)
+	if *fsRoot != "" {
+		var err error
+		if fsSandbox, err = newSandbox(*fsRoot, *fsReadOnly); err != nil {
+			log.Fatalf("Invalid -fs-root: %v", err)
+		}
+	}
+	if *snapshotDir != "" {
+		if fsSandbox == nil || *publicDemoFlag {
+			log.Fatalf("-snapshot-dir needs -fs-root (or -workshop), outside -public-demo mode")
+		}
+		dir, err := inDir(*stateDir, *snapshotDir)
+		if err != nil {
+			log.Fatalf("Invalid -state-dir: %v", err)
+		}
+		if snapshots, err = newSnapshotStore(dir, *snapshotKeep); err != nil {
+			log.Fatalf("Invalid -snapshot-dir: %v", err)
+		}
+		if dir, err := filepath.EvalSymlinks(snapshots.Dir); err == nil && pathWithin(fsSandbox.Root, dir) {
+			log.Fatalf("Invalid -snapshot-dir: it must not be inside -fs-root")
+		}
+		info, err := snapshots.take(fsSandbox.Root, "startup")
+		if err != nil {
+			log.Fatalf("Snapshot of -fs-root: %v", err)
+		}
+		log.Printf("Snapshots: %s in %s, keeping %d", info.ID, snapshots.Dir, *snapshotKeep)
+		if *snapshotInterval > 0 {
+			go snapshots.run(fsSandbox.Root, *snapshotInterval)
+		}
+	}
+	if *agents != "" {
+		var err error
+		if delegateAgents, err = parseDelegateAgents(*agents); err != nil {
+			log.Fatalf("Invalid -delegate-agents: %v", err)
+		}
+	}
+	respectClientRoots = *fsClientRoots
+	netDiagEnabled = *enableNetDiag
+	if provider, err := parseASNProvider(*asnProvider); err == nil {
+		asnSettings.Provider, asnSettings.CacheTTL = provider, *asnCacheTTL
+	} else {
+		log.Fatalf("Invalid -asn-provider: %v", err)
+	}
+	if *enableExec {
+		var err error
+		if execCommands, err = parseExecAllow(*execAllow); err != nil {
+			log.Fatalf("Invalid -exec-allow: %v", err)
+		}
+	}
+	if *dnsPins != "" {
+		var err error
+		if dnsResolver.Pins, err = parseDNSPins(*dnsPins); err != nil {
+			log.Fatalf("Invalid -dns-pins: %v", err)
+		}
+	}
+	dnsResolver.NegativeTTL = *dnsNegativeTTL
+	if *ipFamily != ipFamilyAuto {
+		var err error
+		if dnsResolver.Family, err = parseIPFamily(*ipFamily); err != nil {
+			log.Fatalf("Invalid -ip-family: %v", err)
+		}
+	}
+	dnsResolver.Delay = *happyEyeballsDelay
+	registerMetrics(dnsResolver.collectMetrics)
+	if fetchProxy, err = parseFetchProxy(*fetchProxyFlag); err != nil {
+		log.Fatalf("Invalid -fetch-proxy: %v", err)
+	}
+	if *fetchTimeout <= 0 || *fetchConnectTimeout <= 0 {
+		log.Fatalf("Invalid -fetch-timeout or -fetch-connect-timeout: must be positive")
+	}
+	if *fetchMaxHeaderBytes <= 0 {
+		log.Fatalf("Invalid -fetch-max-header-bytes: must be positive")
+	}
+	if *downloadMaxSize <= 0 || *downloadQuota < *downloadMaxSize || *downloadTimeout <= 0 {
+		log.Fatalf("Invalid -download-max-size, -download-quota or -download-timeout: must be positive, with the quota at least the max size")
+	}
+	if *fetchRetries < 0 || *fetchRetries > maxFetchRetries || *fetchRetryBackoff <= 0 || *fetchRetryMaxWait <= 0 {
+		log.Fatalf("Invalid -fetch-retries, -fetch-retry-backoff or -fetch-retry-max-wait: want 0 to %d retries and positive waits", maxFetchRetries)
+	}
+	fetchRetrySettings.Retries, fetchRetrySettings.Backoff, fetchRetrySettings.MaxWait = *fetchRetries, *fetchRetryBackoff, *fetchRetryMaxWait
+	registerMetrics(collectFetchRetryMetrics)
+	downloadSettings.MaxBytes, downloadSettings.Timeout = int64(*downloadMaxSize)<<20, *downloadTimeout
+	artifacts.FileQuota = int64(*downloadQuota) << 20
+	httpClient.Timeout = *fetchTimeout
+	dnsResolver.dialer.Timeout = *fetchConnectTimeout
+	if *fetchMaxConnsPerHost < 0 || *fetchMaxIdlePerHost < 0 || *fetchIdleTimeout <= 0 {
+		log.Fatalf("Invalid -fetch-max-conns-per-host, -fetch-max-idle-per-host or -fetch-idle-timeout: want counts not below 0 and a positive timeout")
+	}
+	base := newOutboundTransport(*fetchMaxHeaderBytes)
+	var transport http.RoundTripper = base
+	if !browserBuild {
+		outboundPool = newConnPool(poolSettings{MaxConnsPerHost: *fetchMaxConnsPerHost, MaxIdlePerHost: *fetchMaxIdlePerHost, IdleTimeout: *fetchIdleTimeout, HTTP2: *fetchHTTP2})
+		outboundPool.configure(base)
+		transport = &poolTransport{pool: outboundPool, next: base}
+		registerMetrics(outboundPool.collectMetrics)
+	}
+	if *breakerFailures > 0 {
+		breakers = newBreakerSet(*breakerFailures, *breakerCooldown)
+		transport = &breakerTransport{set: breakers, next: transport}
+		registerMetrics(breakers.collectMetrics)
+	}
+	if *fetchHedge {
+		if *fetchHedgePercentile <= 0 || *fetchHedgePercentile > 100 || *fetchHedgeMinDelay < 0 {
+			log.Fatalf("Invalid -fetch-hedge-percentile or -fetch-hedge-min-delay: want a percentile in (0, 100] and a delay not below 0")
+		}
+		// Outside the breakers, so each attempt counts for the host.
+		hedger = newHedgingTransport(*fetchHedgePercentile, *fetchHedgeMinDelay, transport)
+		transport = hedger
+		registerMetrics(hedger.collectMetrics)
+		log.Printf("Hedging: outbound GET and HEAD requests after the host's p%g time to headers (at least %s)", *fetchHedgePercentile, *fetchHedgeMinDelay)
+	}
+	callBudgetLimits = callLimits{Requests: *callMaxRequests, Bytes: *callMaxBytes, Time: *callMaxTime}
+	httpClient.Transport = &clientLogTransport{next: &progressTransport{next: &budgetTransport{next: transport}}}
+	registerMetrics(collectBudgetMetrics)
+	callDeadlines.Default, callDeadlines.Max = *callTimeoutFlag, *callMaxTimeout
+	registerMetrics(collectDeadlineMetrics)
+	if *maxConcurrentCalls > 0 {
+		scheduler = newCallScheduler(*maxConcurrentCalls, *maxQueuedCalls)
+		registerMetrics(scheduler.collectMetrics)
+	}
+	if *priorityConfigPath != "" {
+		var err error
+		if priorities, err = loadPriorityConfig(*priorityConfigPath); err != nil {
+			log.Fatalf("Invalid -priority-config: %v", err)
+		}
+	}
+	if *redactionConfigPath != "" {
+		var err error
+		if redaction, err = loadRedactionConfig(*redactionConfigPath); err != nil {
+			log.Fatalf("Invalid -redaction-config: %v", err)
+		}
+	}
+	if *signResponses {
+		var err error
+		if signingKey, err = loadSigningKey(*signKey); err != nil {
+			log.Fatalf("Invalid -sign-key: %v", err)
+		}
+	}
+	if *encryptPayloads {
+		if *restGatewayFlag {
+			// The gateway would serve the tools in plaintext next to /mcp.
+			log.Fatalf("-rest-gateway cannot be combined with -encrypt-payloads")
+		}
+		var err error
+		if encryptionKey, err = loadEncryptionKey(*encryptKey); err != nil {
+			log.Fatalf("Invalid -encrypt-key: %v", err)
+		}
+	}
+	if *auditFlag || (cfgFlags.given["audit-log"] && !cfgFlags.given["audit"]) {
+		if *auditMaxSize < 0 || *auditMaxFiles < 0 {
+			log.Fatalf("-audit-max-size and -audit-max-files must not be negative")
+		}
+		path, err := inDir(*logDir, *auditLogPath)
+		if err != nil {
+			log.Fatalf("Invalid -log-dir: %v", err)
+		}
+		if audit, err = openAuditLog(path); err != nil {
+			log.Fatalf("Invalid -audit-log: %v", err)
+		}
+		audit.MaxBytes, audit.MaxFiles = int64(*auditMaxSize)<<20, *auditMaxFiles
+		audit.RedactFields = nil
+		for _, f := range strings.Split(*auditRedactFields, ",") {
+			if f = strings.ToLower(strings.TrimSpace(f)); f != "" {
+				audit.RedactFields = append(audit.RedactFields, f)
+			}
+		}
+	}
+	if *anomalyFlag {
+		anomalies = newAnomalyDetector()
+		events.subscribe(logAnomaly)
+		registerMetrics(anomalies.collectMetrics)
+	}
+	if *alertWebhook != "" {
+		if u, err := url.Parse(*alertWebhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			log.Fatalf("Invalid -alert-webhook: must be an http or https URL")
+		}
+		sink := newWebhookSink(*alertWebhook)
+		events.subscribe(sink.enqueue)
+		registerMetrics(sink.collectMetrics)
+	}
 
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{&mcp.TextContent{Text: result}},
-	}, nil, nil
-}
+	events.subscribe(logSLO)
+	registerMetrics(slos.collectMetrics)
+	go slos.run(sloEvalInterval)
 
-/* ---------- main ---------- */
+	janitor.Interval, janitor.ArtifactTTL, janitor.AuditRetention = *janitorInterval, *artifactTTL, *auditRetention
+	registerMetrics(janitor.collectMetrics)
+	janitor.start()
 
-func main() {
-	// Command-line flags
-	mode := flag.String("mode", "stdio", "Transport mode: stdio or http")
-	port := flag.String("port", "8080", "HTTP port for network mode")
-	host := flag.String("host", "0.0.0.0", "Host address to bind to")
-	flag.Parse()
+	// Tool middleware must be in place before the tools are registered.
+	useToolMiddleware(metricsToolMiddleware, sloToolMiddleware, historyToolMiddleware, budgetToolMiddleware, metaToolMiddleware, auditToolMiddleware, anomalyToolMiddleware, progressToolMiddleware, clientLogToolMiddleware, urlHistoryToolMiddleware, deadlineToolMiddleware, priorityToolMiddleware)
+	registerMetrics(toolCallStats.collectMetrics)
+	logToolCalls = *logToolCallsFlag
+	useToolMiddleware(logToolMiddleware)
+	// Innermost, so that metrics and logging see a panic as an error result.
+	useToolMiddleware(recoverToolMiddleware)
 
+	serverOpts := &mcp.ServerOptions{
+		RootsListChangedHandler: rootsListChanged,
+		CompletionHandler:       complete,
+	}
+	if fsSandbox != nil && publicDemo == nil {
+		serverOpts.SubscribeHandler = subscribeSandboxFile
+		serverOpts.UnsubscribeHandler = unsubscribeSandboxFile
+	}
 	server := mcp.NewServer(&mcp.Implementation{
 		Name:    "mcp-server-demo-go",
 		Version: version,
-	}, nil)
+	}, serverOpts)
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "echotest",
 		Description: "Echo back the provided message",
 	}, EchotestTool)
 
-	mcp.AddTool(server, &mcp.Tool{
-		Name:        "timeserver",
-		Description: "Return current time; optional IANA tz via timezone arg",
+	addTool(server, &mcp.Tool{
+		Name:         "timeserver",
+		Description:  "Return current time; optional IANA tz via timezone arg and a format preset or Go layout via format arg",
+		OutputSchema: outputSchema[TimeResult](),
 	}, TimeServerTool)
 
-	mcp.AddTool(server, &mcp.Tool{
-		Name:        "fetch",
-		Description: "Fetch content from a URL (HTTP/HTTPS). Optional max_bytes to limit response size",
+	addTool(server, &mcp.Tool{
+		Name:         "fetch",
+		Description:  "Fetch content from a URL (HTTP/HTTPS). Optional method, headers and body for REST calls, and max_bytes to limit response size",
+		OutputSchema: outputSchema[FetchResult](),
 	}, FetchTool)
 
-	var err error
-	ctx := context.Background()
+	addCompletionPrompts(server)
+
+	if publicDemo != nil {
+		addPublicDemoBanner(server)
+	} else {
+		addExtraTools(server)
+		addArtifacts(server)
+		addUptimeResource(server)
+		if fsSandbox != nil {
+			addSandboxResources(server)
+		}
+		if audit != nil {
+			addUsageReport(server)
+		}
+	}
+
+	if err := toolSet.check(); err != nil {
+		log.Fatalf("Invalid tool selection: %v", err)
+	}
+
+	if *workshopFlag {
+		addWorkshop(server)
+	}
+	server.AddReceivingMiddleware(sessionMiddleware, prefsMiddleware, capabilitiesMiddleware)
+	if redaction != nil {
+		server.AddReceivingMiddleware(redactionMiddleware)
+	}
+	// Last, so that signing wraps every other middleware.
+	if signingKey != nil {
+		addResponseSigning(server)
+	}
+	// Encryption wraps even signing: signatures cover the plaintext.
+	if encryptionKey != nil {
+		server.AddReceivingMiddleware(encryptionMiddleware)
+	}
+
+	logTools()
+	if *configPath != "" {
+		reloader := &configReloader{path: *configPath, flags: cfgFlags, server: server}
+		go reloader.watch(*configWatch)
+		if *configWatch > 0 {
+			log.Printf("Config: %s (reloaded on SIGHUP or when the file changes)", *configPath)
+		} else {
+			log.Printf("Config: %s (reloaded on SIGHUP)", *configPath)
+		}
+	}
+	if publicDemo != nil {
+		logPublicDemo()
+	}
+	if *workshopFlag {
+		logWorkshop(workshopDir)
+	}
+	if redaction != nil {
+		logRedaction()
+	}
+	if signingKey != nil {
+		logSigning(*signKey == "")
+	}
+	if encryptionKey != nil {
+		logEncryption(*encryptKey == "")
+	}
+	if fetchProxy != nil {
+		log.Printf("Fetch proxy: %s", fetchProxy.describe())
+	}
 
-	if *mode == "http" {
+	log.Printf("mcp-server-demo-go %s starting...", version)
+	var transports []serverTransport
+	if modes[modeHTTP] {
 		addr := fmt.Sprintf("%s:%s", *host, *port)
 
-		log.Printf("mcp-server-demo-go %s starting...", version)
 		log.Printf("Transport: Streamable HTTP (MCP spec 2025-03-26)")
 
 		// Create Streamable HTTP handler for MCP over HTTP
@@ -219,25 +848,65 @@ func main() {
 		}, &mcp.StreamableHTTPOptions{
 			Stateless:      false, // Stateful sessions with session ID management
 			JSONResponse:   false, // Use SSE streaming for responses
-			SessionTimeout: 30 * time.Minute, // Session timeout for idle connections
+			SessionTimeout: *sessionIdleTimeout, // Session timeout for idle connections
 		})
+		sessionLimits.IdleTimeout, sessionLimits.Max = *sessionIdleTimeout, *maxSessions
 
 		// Create a mux to handle both MCP and health check endpoints
 		mux := http.NewServeMux()
 
+		// Public demo mode rate-limits every client address
+		var handler http.Handler = mux
+		if publicDemo != nil {
+			handler = demoLimiter.middleware(mux)
+		}
+
+		// Optional response compression, negotiated per request
+		compress, err := parseCompression(*compressionFlag)
+		if err != nil {
+			log.Fatalf("Invalid -compression: %v", err)
+		}
+		if compress != nil {
+			handler = compress.wrap(handler)
+			log.Printf("Compression: %s", strings.Join(compress.Encodings, ", "))
+		}
+
+		if *accessLogFormat != "" {
+			path, err := inDir(*logDir, *accessLogPath)
+			if err != nil {
+				log.Fatalf("Invalid -log-dir: %v", err)
+			}
+			if accessLogger, err = newAccessLog(*accessLogFormat, path); err != nil {
+				log.Fatalf("Invalid -access-log-format or -access-log: %v", err)
+			}
+		}
+
 		// Logging middleware to trace ALL incoming requests
 		loggingMux := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			log.Printf("[REQUEST] Method=%s Path=%s RemoteAddr=%s UserAgent=%s",
-				r.Method, r.URL.Path, r.RemoteAddr, r.Header.Get("User-Agent"))
-			log.Printf("[HEADERS] %v", r.Header)
+			start := time.Now()
+			logRequests := accessLogger == nil && logEnabled(logInfo)
+			if logRequests && publicDemo != nil {
+				// Anonymized: truncated client address, no user agent or headers
+				log.Printf("[REQUEST] Method=%s Path=%s Client=%s", r.Method, r.URL.Path, anonymizeAddr(r.RemoteAddr))
+			} else if logRequests {
+				log.Printf("[REQUEST] Method=%s Path=%s RemoteAddr=%s UserAgent=%s",
+					r.Method, r.URL.Path, r.RemoteAddr, r.Header.Get("User-Agent"))
+				log.Printf("[HEADERS] %v", r.Header)
+			}
 
 			// Create a response writer wrapper to capture status code
 			wrappedWriter := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 
 			// Serve the request
-			mux.ServeHTTP(wrappedWriter, r)
+			handler.ServeHTTP(wrappedWriter, r)
 
-			log.Printf("[RESPONSE] Path=%s Status=%d", r.URL.Path, wrappedWriter.statusCode)
+			if accessLogger != nil {
+				accessLogger.record(r, wrappedWriter, start)
+			}
+			availability.recordHTTP(r.URL.Path, wrappedWriter.statusCode)
+			if logRequests {
+				log.Printf("[RESPONSE] Path=%s Status=%d", r.URL.Path, wrappedWriter.statusCode)
+			}
 		})
 
 		// Health check endpoint
@@ -254,8 +923,69 @@ func main() {
 			fmt.Fprintf(w, `{"status":"ok","service":"mcp-server-demo-go","version":"%s"}`, version)
 		})
 
+		// Liveness (the process serves HTTP) and readiness (its checks pass)
+		mux.HandleFunc("GET /livez", livezHandler)
+		mux.HandleFunc("GET /readyz", readyzHandler)
+
 		// MCP Streamable HTTP handler on /mcp path (new standard endpoint)
-		mux.Handle("/mcp", mcpHandler)
+		var mcpEndpoint http.Handler = limitSessions(mcpHandler)
+		var cors *corsPolicy
+		if *corsOrigins != "" {
+			var err error
+			if cors, err = newCORSPolicy(*corsOrigins, *corsHeaders, *corsCredentials, *corsMaxAge); err != nil {
+				log.Fatalf("Invalid -cors-origins: %v", err)
+			}
+			mcpEndpoint = cors.wrap(mcpEndpoint)
+			log.Printf("CORS: /mcp accepts browser requests from %s", *corsOrigins)
+		}
+		mux.Handle("/mcp", mcpEndpoint)
+
+		// MCP over WebSocket, one session per connection
+		var wsEndpoint *webSocketEndpoint
+		if *webSocketFlag {
+			if *webSocketMaxMessage <= 0 {
+				log.Fatalf("Invalid -websocket-max-message: must be positive")
+			}
+			wsEndpoint = newWebSocketEndpoint(server, cors, *webSocketPing, *webSocketMaxMessage)
+			mux.Handle("GET /ws", wsEndpoint)
+			log.Printf("WebSocket: /ws (ping every %s, messages up to %d bytes)", *webSocketPing, *webSocketMaxMessage)
+		}
+
+		if signingKey != nil {
+			mux.HandleFunc(signature.WellKnownPath, signingKeyHandler)
+		}
+		if encryptionKey != nil {
+			mux.HandleFunc(payloadcrypt.WellKnownPath, encryptionKeyHandler)
+		}
+
+		if *metricsFlag {
+			registerMetrics(collectSessionMetrics)
+			mux.HandleFunc("/metrics", metricsHandler)
+		}
+
+		if *adminToken != "" {
+			mux.HandleFunc("GET /admin/sessions", requireAdmin(*adminToken, sessionsHandler))
+			mux.HandleFunc("DELETE /admin/sessions/{id}", requireAdmin(*adminToken, sessionsHandler))
+			registerDebug(mux, *adminToken)
+			mux.HandleFunc("POST /admin/gc", requireAdmin(*adminToken, gcHandler))
+			if snapshots != nil {
+				mux.HandleFunc("GET /admin/snapshots", requireAdmin(*adminToken, snapshotsHandler))
+				mux.HandleFunc("POST /admin/snapshots", requireAdmin(*adminToken, snapshotsHandler))
+				mux.HandleFunc("POST /admin/snapshots/{id}/restore", requireAdmin(*adminToken, snapshotsHandler))
+			}
+			if audit != nil {
+				mux.HandleFunc("GET /admin/report", requireAdmin(*adminToken, reportHandler))
+			}
+			if breakers != nil {
+				mux.HandleFunc("GET /admin/breakers", requireAdmin(*adminToken, breakersHandler))
+			}
+			log.Printf("Admin endpoints: /admin/sessions, /admin/gc, /admin/breakers and /debug/ (bearer token required)")
+		}
+
+		if *restGatewayFlag {
+			newRESTGateway(server, cors).register(mux)
+			log.Printf("REST gateway: GET /api/tools, POST /api/tools/{name}")
+		}
 
 		// Catch-all handler for unmatched routes (will show 404s)
 		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -273,15 +1003,223 @@ func main() {
 
 		log.Printf("Server listening on %s", addr)
 		log.Printf("MCP endpoint: http://%s/mcp", addr)
-		log.Printf("Health check endpoints: /health and /healthz")
-		err = httpServer.ListenAndServe()
-	} else {
-		log.Printf("mcp-server-demo-go %s starting...", version)
+		log.Printf("Health check endpoints: /health, /healthz, /livez and /readyz")
+		ln, lerr := net.Listen("tcp", addr)
+		if lerr != nil {
+			log.Fatal(lerr)
+		}
+		shutdown := make(chan struct{})
+		go func() {
+			defer close(shutdown)
+			<-ctx.Done()
+			log.Printf("Shutting down; waiting up to %s for open requests", *shutdownTimeout)
+			service.Stopping()
+			sctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+			defer cancel()
+			if wsEndpoint != nil {
+				wsEndpoint.shutdown(sctx)
+			}
+			if httpServer.Shutdown(sctx) != nil {
+				httpServer.Close()
+			}
+		}()
+		transports = append(transports, serverTransport{name: modeHTTP, status: addr, serve: func() error {
+			err := httpServer.Serve(ln)
+			if errors.Is(err, http.ErrServerClosed) {
+				<-shutdown
+				err = nil
+			}
+			return err
+		}})
+	}
+	if modes[modeBrowser] {
+		log.Printf("Transport: browser (postMessage)")
+		transports = append(transports, serverTransport{name: modeBrowser, status: "postMessage", serve: func() error {
+			return server.Run(ctx, newBrowserTransport())
+		}})
+	}
+	if modes[modeStdio] {
 		log.Printf("Transport: stdio")
-		err = server.Run(ctx, &mcp.StdioTransport{})
+		transports = append(transports, serverTransport{name: modeStdio, status: "stdio", serve: func() error {
+			return server.Run(ctx, &stdioTransport{})
+		}})
 	}
 
+	var serving []string
+	for _, t := range transports {
+		serving = append(serving, t.status)
+	}
+	service.Ready("serving on " + strings.Join(serving, " and "))
+	err = runTransports(ctx, transports)
+	availability.stop()
+	artifacts.close()
+	service.Stopped(err)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+// addExtraTools registers the tools beyond echotest, timeserver and
+// fetch. Optional ones depend on their flags; none are exposed in
+// -public-demo mode.
+func addExtraTools(server *mcp.Server) {
+	addTool(server, &mcp.Tool{
+		Name:         "fetch_batch",
+		Description:  "Fetch up to 20 URLs concurrently with GET, each like fetch, and return per-URL results in order; one URL failing does not fail the batch",
+		OutputSchema: outputSchema[FetchBatchResult](),
+	}, FetchBatchTool)
+
+	if !browserBuild {
+		addTool(server, &mcp.Tool{
+			Name:         "download",
+			Description:  "Download a URL with GET to a temporary file on the server (up to 100 MiB unless configured otherwise) and return a link to a resource holding it, instead of the bytes; for files too large for fetch",
+			OutputSchema: outputSchema[DownloadResult](),
+		}, DownloadTool)
+	}
+
+	addTool(server, &mcp.Tool{
+		Name:         "url_status",
+		Description:  "Check a URL with HEAD (falling back to GET without reading the body); returns status, content type, content length and latency",
+		OutputSchema: outputSchema[URLStatusResult](),
+	}, URLStatusTool)
+
+	addTool(server, &mcp.Tool{
+		Name:         "random",
+		Description:  "Generate UUIDs (v4/v7), random integers in a range, random bytes (hex/base64) or URL-safe tokens; optional seed for reproducible output",
+		OutputSchema: outputSchema[RandomResult](),
+	}, RandomTool)
+
+	addTool(server, &mcp.Tool{
+		Name:         "transform",
+		Description:  "Hash (md5, sha1, sha256, sha512) or encode/decode (base64, hex, URL) an input string or the body of a URL",
+		OutputSchema: outputSchema[TransformResult](),
+	}, TransformTool)
+
+	addTool(server, &mcp.Tool{
+		Name:         "time_convert",
+		Description:  "Convert a timestamp between IANA timezones, add or subtract durations, compute the difference to another timestamp and list upcoming DST transitions",
+		OutputSchema: outputSchema[TimeConvertResult](),
+	}, TimeConvertTool)
+
+	addTool(server, &mcp.Tool{
+		Name:         "list_timezones",
+		Description:  "Search IANA timezone names (e.g. 'Kyiv', 'new york', 'America/') to find valid values for the timezone arguments of timeserver, time_convert and set_defaults",
+		OutputSchema: outputSchema[ListTimezonesResult](),
+	}, ListTimezonesTool)
+
+	addTool(server, &mcp.Tool{
+		Name:         "set_defaults",
+		Description:  "Set this session's default timezone and locale, used by timeserver, time_convert and fetch when the argument is omitted; call with no arguments to show the current defaults",
+		OutputSchema: outputSchema[SetDefaultsResult](),
+	}, SetDefaultsTool)
+
+	addTool(server, &mcp.Tool{
+		Name:         "prefs",
+		Description:  "Show or reset the argument values this session's calls taught the server: the last timezone and max_bytes per tool, and the base URL that url arguments starting with / resolve against",
+		OutputSchema: outputSchema[PrefsResult](),
+	}, PrefsTool)
+
+	addTool(server, &mcp.Tool{
+		Name:         "xpath",
+		Description:  "Query an XML or HTML document, given inline or fetched from a URL, with an XPath expression or CSS selector; returns the matched nodes' text and attributes instead of the whole page",
+		OutputSchema: outputSchema[XPathResult](),
+	}, XPathTool)
+
+	addTool(server, &mcp.Tool{
+		Name:         "check_links",
+		Description:  "Check the links of a page (or a given list) with HEAD requests, a few at a time and spaced out per host, and report broken links with status codes and where redirected links end up",
+		OutputSchema: outputSchema[CheckLinksResult](),
+	}, CheckLinksTool)
+
+	addTool(server, &mcp.Tool{
+		Name:         "crawl_preview",
+		Description:  "Visit up to max_pages same-origin pages from a sitemap or seed URL, obeying robots.txt and a delay between requests, and list each page's status, title and meta description; full results are stored as an artifact resource",
+		OutputSchema: outputSchema[CrawlResult](),
+	}, CrawlPreviewTool)
+
+	addTool(server, &mcp.Tool{
+		Name:         "latency_probe",
+		Description:  "Send a number of timed requests to a URL and report DNS, connect, TLS, time-to-first-byte and total times per request, with min, mean and p50/p90/p95/p99 percentiles for each phase",
+		OutputSchema: outputSchema[LatencyProbeResult](),
+	}, LatencyProbeTool)
+
+	addTool(server, &mcp.Tool{
+		Name:         "list_roots",
+		Description:  "List the roots the client shares with the server and, with a sandbox, how each relates to it and which sandbox directories the filesystem tools may use in this session",
+		OutputSchema: outputSchema[ListRootsResult](),
+	}, ListRootsTool)
+
+	if fsSandbox != nil {
+		addTool(server, &mcp.Tool{
+			Name:         "read_file",
+			Description:  "Read a file under the server's sandbox directory; optional offset and max_bytes for large files",
+			OutputSchema: outputSchema[ReadFileResult](),
+		}, ReadFileTool)
+
+		addTool(server, &mcp.Tool{
+			Name:         "list_dir",
+			Description:  "List the entries of a directory under the server's sandbox directory",
+			OutputSchema: outputSchema[ListDirResult](),
+		}, ListDirTool)
+
+		if !fsSandbox.ReadOnly {
+			addTool(server, &mcp.Tool{
+				Name:         "write_file",
+				Description:  "Write or append text to a file under the server's sandbox directory",
+				OutputSchema: outputSchema[WriteFileResult](),
+			}, WriteFileTool)
+		}
+	}
+
+	addTool(server, &mcp.Tool{
+		Name:         "status_report",
+		Description:  "Summarize the server's health: uptime, restarts, today's tool call and HTTP success rates, readiness checks, open sessions and open circuit breakers, with a healthy verdict and the problems found",
+		OutputSchema: outputSchema[StatusReportResult](),
+	}, StatusReportTool)
+
+	addTool(server, &mcp.Tool{
+		Name:         "delegate",
+		Description:  "Hand a prompt plus context to another agent: the calling client's model via sampling, or a configured HTTP or MCP agent; streamed chunks arrive as progress notifications",
+		OutputSchema: outputSchema[DelegateResult](),
+	}, DelegateTool)
+
+	addTool(server, &mcp.Tool{
+		Name:         "summarize_url",
+		Description:  "Fetch a page and ask the calling client's model to summarize it via sampling (sampling/createMessage); the client must support sampling",
+		OutputSchema: outputSchema[SummarizeURLResult](),
+	}, SummarizeURLTool)
+
+	addTool(server, &mcp.Tool{
+		Name:         "elicit",
+		Description:  "Demonstrate elicitation: ask the user, through the client, for the profile fields not passed as arguments, for a confirmation or for an API key, and report the answer; the client must support elicitation",
+		OutputSchema: outputSchema[ElicitResult](),
+	}, ElicitTool)
+
+	addTool(server, &mcp.Tool{
+		Name:         "asn_lookup",
+		Description:  "Map an IP address or prefix to its BGP prefix and origin ASes (number, holder name, country), optionally listing the prefixes each AS announces, from RIPEstat or Team Cymru; answers are cached",
+		OutputSchema: outputSchema[ASNLookupResult](),
+	}, ASNLookupTool)
+
+	if netDiagEnabled {
+		addTool(server, &mcp.Tool{
+			Name:         "traceroute",
+			Description:  "Trace the route to a host with ICMP echo probes, or unprivileged UDP probes when the server may not open raw sockets; returns each hop's address, round-trip times and lost probes",
+			OutputSchema: outputSchema[TracerouteResult](),
+		}, TracerouteTool)
+
+		addTool(server, &mcp.Tool{
+			Name:         "path_mtu",
+			Description:  "Find the largest packet that reaches a host without fragmentation, following the MTUs routers report and bisecting when probes go unanswered",
+			OutputSchema: outputSchema[PathMTUResult](),
+		}, PathMTUTool)
+	}
+
+	if execCommands != nil {
+		addTool(server, &mcp.Tool{
+			Name:         "exec",
+			Description:  "Run an allowlisted command without a shell; returns exit code and (truncated) stdout and stderr",
+			OutputSchema: outputSchema[ExecResult](),
+		}, ExecTool)
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- _meta ---------- */
+
+// Keys of the _meta entries the server reads from tool call requests and
+// writes to their results. The server's own keys are prefixed with
+// mcp-demo/; trace context uses the W3C Trace Context names.
+const (
+	// requestIDMetaKey names a call in the log and audit log. A client
+	// may pick the ID (or send an X-Request-Id header); otherwise the
+	// server makes one up. The result carries it either way.
+	requestIDMetaKey = "mcp-demo/request_id"
+	// traceparentMetaKey and tracestateMetaKey carry W3C trace context,
+	// from _meta or the HTTP headers of the same names. The result's
+	// traceparent names the server's span of the call.
+	traceparentMetaKey = "traceparent"
+	tracestateMetaKey  = "tracestate"
+	// timeoutMetaKey is the request entry in which a client asks for a
+	// deadline, in milliseconds.
+	timeoutMetaKey = "mcp-demo/timeout_ms"
+	// priorityMetaKey is the request entry naming a call's class.
+	priorityMetaKey = "mcp-demo/priority"
+	// errorMetaKey is the result entry describing a structured error such
+	// as DEADLINE_EXCEEDED.
+	errorMetaKey = "mcp-demo/error"
+	// costMetaKey is the result entry with the call's callCost.
+	costMetaKey = "mcp-demo/cost"
+	// cacheMetaKey is the result entry telling whether a tool with a cache
+	// answered from it: "hit" or "miss".
+	cacheMetaKey = "mcp-demo/cache"
+	// warningsMetaKey is the result entry listing metaWarnings.
+	warningsMetaKey = "mcp-demo/warnings"
+	// deprecatedMetaKey, in a tool's own _meta, marks the tool deprecated;
+	// its value says what to use instead. Every call then gets a
+	// "deprecated" warning.
+	deprecatedMetaKey = "mcp-demo/deprecated"
+)
+
+// maxRequestIDLen bounds a client's request ID; a longer one is replaced.
+const maxRequestIDLen = 128
+
+// callCost is what a call took: its time and the upstream requests and
+// response bytes it used from its budget.
+type callCost struct {
+	DurationMs       float64 `json:"duration_ms"`
+	UpstreamRequests int     `json:"upstream_requests"`
+	UpstreamBytes    int64   `json:"upstream_bytes"`
+}
+
+// metaWarning is something the client should know about a call that
+// otherwise went through, such as a timeout the server shortened.
+type metaWarning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// traceContext is a parsed W3C traceparent, plus tracestate.
+type traceContext struct {
+	TraceID, ParentID, Flags string
+	State                    string
+}
+
+// callMeta collects the _meta of one call while it runs. Middlewares and
+// tools add to it through the helpers below, which do nothing outside a
+// call; metaToolMiddleware writes it into the result.
+type callMeta struct {
+	RequestID string
+	// Trace is the client's trace context, nil when it sent none, and
+	// SpanID the server's span within it.
+	Trace  *traceContext
+	SpanID string
+
+	mu       sync.Mutex
+	cache    string
+	warnings []metaWarning
+	budget   *callBudget
+}
+
+type callMetaKey struct{}
+
+func callMetaFrom(ctx context.Context) *callMeta {
+	m, _ := ctx.Value(callMetaKey{}).(*callMeta)
+	return m
+}
+
+// callRequestID returns the request ID of the call ctx belongs to, or "".
+func callRequestID(ctx context.Context) string {
+	if m := callMetaFrom(ctx); m != nil {
+		return m.RequestID
+	}
+	return ""
+}
+
+// setCacheStatus records whether the call was answered from a cache.
+func setCacheStatus(ctx context.Context, status string) {
+	if m := callMetaFrom(ctx); m != nil {
+		m.mu.Lock()
+		m.cache = status
+		m.mu.Unlock()
+	}
+}
+
+// addWarning adds a warning to the call's result.
+func addWarning(ctx context.Context, code, message string) {
+	if m := callMetaFrom(ctx); m != nil {
+		m.mu.Lock()
+		m.warnings = append(m.warnings, metaWarning{Code: code, Message: message})
+		m.mu.Unlock()
+	}
+}
+
+// requestMeta returns the _meta of a call request, which may be nil.
+func requestMeta(req *mcp.CallToolRequest) mcp.Meta {
+	if req == nil || req.Params == nil {
+		return nil
+	}
+	return req.Params.Meta
+}
+
+// metaString returns the string entry key of m, trimmed.
+func metaString(m mcp.Meta, key string) (string, bool) {
+	s, ok := m[key].(string)
+	return strings.TrimSpace(s), ok
+}
+
+// metaNumber returns the entry key of m as a number; clients may send
+// either a JSON number or a numeric string. present reports whether the
+// entry is there at all, so that a malformed one can be told apart.
+func metaNumber(m mcp.Meta, key string) (v float64, ok, present bool) {
+	switch x := m[key].(type) {
+	case nil:
+		return 0, false, false
+	case float64:
+		return x, true, true
+	case string:
+		v, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return v, err == nil, true
+	}
+	return 0, false, true
+}
+
+// setMeta sets key in the _meta of res, keeping its other entries.
+func setMeta(res *mcp.CallToolResult, key string, v any) {
+	if res.Meta == nil {
+		res.Meta = mcp.Meta{}
+	}
+	res.Meta[key] = v
+}
+
+// addMeta sets key in the _meta of res unless it is already set, so that
+// what a tool wrote itself wins.
+func addMeta(res *mcp.CallToolResult, key string, v any) {
+	if _, ok := res.Meta[key]; !ok {
+		setMeta(res, key, v)
+	}
+}
+
+// metaToolMiddleware starts each call's callMeta and, once the call is
+// done, writes its request ID, trace context, cost, cache status and
+// warnings into the result's _meta, unless the session turned that off.
+// It sits inside budgetToolMiddleware so that the cost can be read from
+// the call's budget.
+func metaToolMiddleware(tool *mcp.Tool, next ToolFunc) ToolFunc {
+	deprecation, _ := metaString(tool.Meta, deprecatedMetaKey)
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, any, error) {
+		start := time.Now()
+		m := newCallMeta(req)
+		m.budget, _ = ctx.Value(callBudgetKey{}).(*callBudget)
+		ctx = context.WithValue(ctx, callMetaKey{}, m)
+		if deprecation != "" {
+			addWarning(ctx, "deprecated", tool.Name+" is deprecated: "+deprecation)
+		}
+		res, out, err := next(ctx, req)
+		if res == nil || !featureEnabled(req.Session, featureResultMeta) {
+			return res, out, err
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		addMeta(res, requestIDMetaKey, m.RequestID)
+		if m.Trace != nil {
+			addMeta(res, traceparentMetaKey, "00-"+m.Trace.TraceID+"-"+m.SpanID+"-"+m.Trace.Flags)
+			if m.Trace.State != "" {
+				addMeta(res, tracestateMetaKey, m.Trace.State)
+			}
+		}
+		cost := callCost{DurationMs: roundMs(time.Since(start))}
+		if m.budget != nil {
+			cost.UpstreamRequests, cost.UpstreamBytes = m.budget.usage()
+		}
+		addMeta(res, costMetaKey, cost)
+		if m.cache != "" {
+			addMeta(res, cacheMetaKey, m.cache)
+		}
+		if len(m.warnings) > 0 {
+			addMeta(res, warningsMetaKey, slices.Clone(m.warnings))
+		}
+		return res, out, err
+	}
+}
+
+// newCallMeta reads the request ID and trace context of a call from its
+// _meta, falling back to the HTTP headers of the request that carried it.
+func newCallMeta(req *mcp.CallToolRequest) *callMeta {
+	meta := requestMeta(req)
+	var header http.Header
+	if extra := req.GetExtra(); extra != nil {
+		header = extra.Header
+	}
+	m := &callMeta{}
+	id, ok := metaString(meta, requestIDMetaKey)
+	if !ok {
+		id = strings.TrimSpace(header.Get("X-Request-Id"))
+	}
+	if id == "" || len(id) > maxRequestIDLen {
+		id = randomHex(8)
+	}
+	m.RequestID = id
+
+	parent, ok := metaString(meta, traceparentMetaKey)
+	state, _ := metaString(meta, tracestateMetaKey)
+	if !ok {
+		parent, state = header.Get("Traceparent"), header.Get("Tracestate")
+	}
+	if tc, ok := parseTraceparent(parent); ok {
+		tc.State = strings.TrimSpace(state)
+		m.Trace, m.SpanID = &tc, randomHex(8)
+	}
+	return m
+}
+
+// parseTraceparent parses a W3C traceparent header value. Versions after
+// 00 may append fields, which are ignored.
+func parseTraceparent(s string) (traceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || (parts[0] == "00" && len(parts) != 4) || parts[0] == "ff" {
+		return traceContext{}, false
+	}
+	for i, n := range []int{2, 32, 16, 2} {
+		if len(parts[i]) != n || !isLowerHex(parts[i]) {
+			return traceContext{}, false
+		}
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return traceContext{}, false
+	}
+	return traceContext{TraceID: parts[1], ParentID: parts[2], Flags: parts[3]}, true
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// randomHex returns n random bytes, hex encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
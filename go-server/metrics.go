@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/* ---------- Metrics ---------- */
+
+// metricsCollector writes the current value of one or more metric
+// families. Collectors read live state when /metrics is scraped instead
+// of keeping their own copies.
+type metricsCollector func(w *metricsWriter)
+
+var (
+	metricsMu         sync.Mutex
+	metricsCollectors []metricsCollector
+)
+
+// registerMetrics adds a collector to the /metrics endpoint.
+func registerMetrics(c metricsCollector) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	metricsCollectors = append(metricsCollectors, c)
+}
+
+// metricsWriter renders the Prometheus text exposition format.
+type metricsWriter struct {
+	w io.Writer
+}
+
+// family writes the HELP and TYPE lines of a metric family.
+func (m *metricsWriter) family(name, kind, help string) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes one value; labels alternate names and values.
+func (m *metricsWriter) sample(name string, value float64, labels ...string) {
+	if len(labels) == 0 {
+		fmt.Fprintf(m.w, "%s %g\n", name, value)
+		return
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+	fmt.Fprintf(m.w, "%s{%s} %g\n", name, strings.Join(pairs, ","), value)
+}
+
+// metricsHandler serves every registered collector.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metricsMu.Lock()
+	collectors := append([]metricsCollector(nil), metricsCollectors...)
+	metricsMu.Unlock()
+	mw := &metricsWriter{w: w}
+	for _, c := range collectors {
+		c(mw)
+	}
+}
+
+// sortedKeys returns the keys of m in order, for stable metric output.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
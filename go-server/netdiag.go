@@ -0,0 +1,449 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"golang.org/x/net/ipv4"
+)
+
+/* ---------- Tools: traceroute, path_mtu ---------- */
+
+const (
+	// defaultTraceHops and maxTraceHops bound the max_hops argument.
+	defaultTraceHops = 30
+	maxTraceHops     = 64
+	// defaultTraceProbes and maxTraceProbes bound probes per hop.
+	defaultTraceProbes = 3
+	maxTraceProbes     = 5
+	// traceSilentHops ends a trace after this many hops in a row that
+	// answered no probe.
+	traceSilentHops = 5
+	// traceProbeSize is the IP packet size of traceroute probes.
+	traceProbeSize = 60
+	// tracePort is the destination port of UDP probes: traceroute(8)'s
+	// first port, which is normally closed.
+	tracePort = 33434
+	// defaultNetProbeTimeout and maxNetProbeTimeout bound timeout_ms.
+	defaultNetProbeTimeout = time.Second
+	maxNetProbeTimeout     = 5 * time.Second
+	// defaultMaxMTU and maxMTUCap bound the max_mtu argument; minMTU is
+	// the smallest MTU IPv4 allows.
+	defaultMaxMTU = 1500
+	maxMTUCap     = 9000
+	minMTU        = 68
+	// maxMTUProbes bounds the probes of one path_mtu call.
+	maxMTUProbes = 16
+)
+
+// netDiagEnabled registers traceroute and path_mtu. Set from
+// -enable-net-diag.
+var netDiagEnabled bool
+
+// Probe protocols.
+const (
+	probeAuto = "auto"
+	probeICMP = "icmp"
+	probeUDP  = "udp"
+)
+
+// Probe outcomes.
+const (
+	outcomeTimeExceeded = "time_exceeded"
+	outcomeReached      = "reached"
+	outcomeUnreachable  = "unreachable"
+	outcomeFragNeeded   = "frag_needed"
+	outcomeTooBig       = "too_big_local"
+	outcomeTimeout      = "timeout"
+)
+
+// probeResult is what came back for one probe packet.
+type probeResult struct {
+	outcome string
+	from    netip.Addr // zero for timeouts and local errors
+	detail  string     // e.g. "time exceeded", "port unreachable"
+	mtu     int        // next-hop MTU for frag_needed and too_big_local
+	rtt     time.Duration
+}
+
+// netProber sends probe packets to one destination and waits for the
+// answer. ttl limits the hops a packet may take, size is the IP packet
+// size and df sets Don't Fragment.
+type netProber interface {
+	probe(ctx context.Context, ttl, size int, df bool) (probeResult, error)
+	Close() error
+}
+
+// openProber opens a prober for protocol. auto prefers ICMP echo, which
+// needs a raw socket (root or CAP_NET_RAW), and falls back to UDP probes
+// that read ICMP errors from the socket's error queue without privileges.
+// fallback says why ICMP was not used.
+func openProber(protocol string, dst netip.Addr, timeout time.Duration) (p netProber, used, fallback string, err error) {
+	switch protocol {
+	case probeICMP, probeAuto:
+		p, err = newICMPProber(dst, timeout)
+		if err == nil {
+			return p, probeICMP, "", nil
+		}
+		if protocol == probeICMP || !errors.Is(err, os.ErrPermission) {
+			if errors.Is(err, os.ErrPermission) {
+				err = fmt.Errorf("%w (raw ICMP sockets need root or CAP_NET_RAW; use protocol udp)", err)
+			}
+			return nil, "", "", err
+		}
+		fallback = "no permission for raw ICMP sockets, which need root or CAP_NET_RAW; used unprivileged UDP probes"
+		fallthrough
+	case probeUDP:
+		p, err = newUDPProber(dst, tracePort, timeout)
+		if err != nil {
+			return nil, "", "", err
+		}
+		return p, probeUDP, fallback, nil
+	}
+	return nil, "", "", fmt.Errorf("unknown protocol %q (want auto, icmp or udp)", protocol)
+}
+
+// icmpErrorResult classifies an ICMP error about one of our probes.
+func icmpErrorResult(from netip.Addr, typ ipv4.ICMPType, code byte, mtu int) probeResult {
+	r := probeResult{from: from, outcome: outcomeUnreachable}
+	switch {
+	case typ == ipv4.ICMPTypeTimeExceeded:
+		r.outcome, r.detail = outcomeTimeExceeded, "time exceeded"
+	case typ != ipv4.ICMPTypeDestinationUnreachable:
+		r.detail = fmt.Sprintf("ICMP type %d code %d", typ, code)
+	case code == 3:
+		// UDP probes reach the destination when its closed port answers.
+		r.outcome, r.detail = outcomeReached, "port unreachable"
+	case code == 4:
+		r.outcome, r.detail, r.mtu = outcomeFragNeeded, "fragmentation needed", mtu
+	case code == 0:
+		r.detail = "network unreachable"
+	case code == 1:
+		r.detail = "host unreachable"
+	case code == 2:
+		r.detail = "protocol unreachable"
+	case code == 9, code == 10, code == 13:
+		r.detail = "administratively prohibited"
+	default:
+		r.detail = fmt.Sprintf("unreachable (code %d)", code)
+	}
+	return r
+}
+
+// resolveProbeTarget applies the egress policy to host and returns its
+// first IPv4 address; probes are IPv4 only.
+func resolveProbeTarget(ctx context.Context, host string) (netip.Addr, error) {
+	host = strings.TrimSpace(host)
+	if host == "" {
+		return netip.Addr{}, errors.New("host is required")
+	}
+	if err := egress.Check(ctx, &url.URL{Scheme: "http", Host: host}); err != nil {
+		return netip.Addr{}, fmt.Errorf("host not allowed: %v", err)
+	}
+	addrs, err := dnsResolver.lookup(ctx, host)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	for _, a := range addrs {
+		if a = a.Unmap(); a.Is4() {
+			return a, nil
+		}
+	}
+	return netip.Addr{}, fmt.Errorf("%s has no IPv4 address (only IPv4 is supported)", host)
+}
+
+// probeTimeout converts timeout_ms, applying the default and cap.
+func probeTimeout(ms int) time.Duration {
+	if ms <= 0 {
+		return defaultNetProbeTimeout
+	}
+	return min(time.Duration(ms)*time.Millisecond, maxNetProbeTimeout)
+}
+
+type TracerouteArgs struct {
+	Host      string `json:"host" jsonschema:"Hostname or IPv4 address to trace"`
+	Protocol  string `json:"protocol,omitempty" jsonschema:"auto (default): ICMP echo when the server may open raw sockets, else UDP; icmp; or udp (unprivileged, Linux)"`
+	MaxHops   int    `json:"max_hops,omitempty" jsonschema:"Highest TTL to try (default 30, max 64)"`
+	Probes    int    `json:"probes,omitempty" jsonschema:"Probes per hop (default 3, max 5)"`
+	TimeoutMs int    `json:"timeout_ms,omitempty" jsonschema:"How long to wait for each probe's answer in milliseconds (default 1000, max 5000)"`
+}
+
+// TraceHop is one TTL of a trace.
+type TraceHop struct {
+	TTL       int       `json:"ttl"`
+	Addresses []string  `json:"addresses" jsonschema:"Routers or the destination that answered, in order; empty when no probe was answered"`
+	RTTsMs    []float64 `json:"rtts_ms" jsonschema:"Round-trip times of the answered probes"`
+	Lost      int       `json:"lost" jsonschema:"Probes without an answer"`
+	Response  string    `json:"response,omitempty" jsonschema:"Last answer: time exceeded, echo reply, port unreachable, host unreachable, ..."`
+}
+
+// TracerouteResult is the structured output of the traceroute tool.
+type TracerouteResult struct {
+	Host     string     `json:"host"`
+	Address  string     `json:"address"`
+	Protocol string     `json:"protocol" jsonschema:"icmp or udp"`
+	Fallback string     `json:"fallback,omitempty" jsonschema:"Why UDP was used when protocol was auto"`
+	Reached  bool       `json:"reached"`
+	Hops     []TraceHop `json:"hops"`
+	Stopped  string     `json:"stopped,omitempty" jsonschema:"Why the trace ended before reaching the host or max_hops"`
+}
+
+// TracerouteTool sends probes with increasing TTLs and lists the routers
+// that report the packets expiring, up to the destination.
+func TracerouteTool(ctx context.Context, req *mcp.CallToolRequest, in TracerouteArgs) (*mcp.CallToolResult, any, error) {
+	dst, err := resolveProbeTarget(ctx, in.Host)
+	if err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+	maxHops := in.MaxHops
+	if maxHops <= 0 {
+		maxHops = defaultTraceHops
+	}
+	maxHops = min(maxHops, maxTraceHops)
+	probes := in.Probes
+	if probes <= 0 {
+		probes = defaultTraceProbes
+	}
+	probes = min(probes, maxTraceProbes)
+	protocol := strings.ToLower(in.Protocol)
+	if protocol == "" {
+		protocol = probeAuto
+	}
+
+	p, used, fallback, err := openProber(protocol, dst, probeTimeout(in.TimeoutMs))
+	if err != nil {
+		return errorResult("Cannot probe: " + err.Error()), nil, nil
+	}
+	defer p.Close()
+
+	progress := progressFrom(ctx)
+	out := TracerouteResult{Host: in.Host, Address: dst.String(), Protocol: used, Fallback: fallback, Hops: []TraceHop{}}
+	silent := 0
+	for ttl := 1; ttl <= maxHops && !out.Reached && out.Stopped == ""; ttl++ {
+		hop := TraceHop{TTL: ttl, Addresses: []string{}, RTTsMs: []float64{}}
+		for range probes {
+			r, err := p.probe(ctx, ttl, traceProbeSize, false)
+			if err != nil {
+				if ctx.Err() != nil {
+					out.Stopped = "cancelled: " + ctx.Err().Error()
+					break
+				}
+				return errorResult(fmt.Sprintf("Probe failed at hop %d: %v", ttl, err)), nil, nil
+			}
+			if r.outcome == outcomeTimeout {
+				hop.Lost++
+				continue
+			}
+			if addr := r.from.String(); !slices.Contains(hop.Addresses, addr) {
+				hop.Addresses = append(hop.Addresses, addr)
+			}
+			hop.RTTsMs = append(hop.RTTsMs, roundMs(r.rtt))
+			hop.Response = r.detail
+			switch r.outcome {
+			case outcomeReached:
+				out.Reached = true
+			case outcomeUnreachable:
+				out.Stopped = fmt.Sprintf("%s reported %s", r.from, r.detail)
+			}
+		}
+		if hop.Lost == probes {
+			silent++
+		} else {
+			silent = 0
+		}
+		if len(hop.RTTsMs) > 0 || hop.Lost > 0 {
+			out.Hops = append(out.Hops, hop)
+		}
+		progress.notify(float64(ttl), float64(maxHops), "hop "+formatHop(hop))
+		if silent == traceSilentHops && out.Stopped == "" {
+			out.Stopped = fmt.Sprintf("no answers for %d hops in a row", traceSilentHops)
+		}
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: formatTraceroute(out, maxHops)}},
+	}, out, nil
+}
+
+// formatHop renders a hop like traceroute(8): addresses, then round-trip
+// times with a * per lost probe.
+func formatHop(h TraceHop) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%2d  ", h.TTL)
+	if len(h.Addresses) > 0 {
+		b.WriteString(strings.Join(h.Addresses, ", ") + "  ")
+	}
+	for _, rtt := range h.RTTsMs {
+		fmt.Fprintf(&b, "%.3f ms  ", rtt)
+	}
+	b.WriteString(strings.Repeat("*  ", h.Lost))
+	return strings.TrimRight(b.String(), " ")
+}
+
+func formatTraceroute(out TracerouteResult, maxHops int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "traceroute to %s (%s), %d hops max, %s probes", out.Host, out.Address, maxHops, out.Protocol)
+	if out.Fallback != "" {
+		fmt.Fprintf(&b, "\n(%s)", out.Fallback)
+	}
+	for _, h := range out.Hops {
+		b.WriteString("\n" + formatHop(h))
+		if h.Response != "" && h.Response != "time exceeded" {
+			fmt.Fprintf(&b, "  [%s]", h.Response)
+		}
+	}
+	if out.Reached {
+		b.WriteString("\nReached the destination")
+	}
+	if out.Stopped != "" {
+		fmt.Fprintf(&b, "\nStopped: %s", out.Stopped)
+	}
+	return b.String()
+}
+
+type PathMTUArgs struct {
+	Host      string `json:"host" jsonschema:"Hostname or IPv4 address to measure the path to"`
+	Protocol  string `json:"protocol,omitempty" jsonschema:"auto (default), icmp or udp; see traceroute"`
+	MaxMTU    int    `json:"max_mtu,omitempty" jsonschema:"Largest packet size to try in bytes (default 1500, max 9000)"`
+	TimeoutMs int    `json:"timeout_ms,omitempty" jsonschema:"How long to wait for each probe's answer in milliseconds (default 1000, max 5000)"`
+}
+
+// MTUProbe is one packet size tried.
+type MTUProbe struct {
+	Size    int     `json:"size"`
+	Outcome string  `json:"outcome" jsonschema:"reached, frag_needed (a router reported a smaller MTU), too_big_local (over the local route MTU), timeout or unreachable"`
+	From    string  `json:"from,omitempty"`
+	MTU     int     `json:"mtu,omitempty" jsonschema:"MTU reported by the router or the local route"`
+	RTTMs   float64 `json:"rtt_ms,omitempty"`
+}
+
+// PathMTUResult is the structured output of the path_mtu tool.
+type PathMTUResult struct {
+	Host     string     `json:"host"`
+	Address  string     `json:"address"`
+	Protocol string     `json:"protocol"`
+	Fallback string     `json:"fallback,omitempty"`
+	MTU      int        `json:"mtu" jsonschema:"Largest packet that reached the host unfragmented; 0 when no probe got through"`
+	Probes   []MTUProbe `json:"probes"`
+	Note     string     `json:"note,omitempty"`
+}
+
+// PathMTUTool finds the largest packet that reaches a host with Don't
+// Fragment set. It starts at max_mtu, follows the MTUs routers and the
+// local route report, and bisects when probes go unanswered, as happens
+// behind routers that drop packets without reporting.
+func PathMTUTool(ctx context.Context, req *mcp.CallToolRequest, in PathMTUArgs) (*mcp.CallToolResult, any, error) {
+	dst, err := resolveProbeTarget(ctx, in.Host)
+	if err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+	hi := in.MaxMTU
+	if hi <= 0 {
+		hi = defaultMaxMTU
+	}
+	hi = max(min(hi, maxMTUCap), minMTU)
+	protocol := strings.ToLower(in.Protocol)
+	if protocol == "" {
+		protocol = probeAuto
+	}
+
+	p, used, fallback, err := openProber(protocol, dst, probeTimeout(in.TimeoutMs))
+	if err != nil {
+		return errorResult("Cannot probe: " + err.Error()), nil, nil
+	}
+	defer p.Close()
+
+	progress := progressFrom(ctx)
+	out := PathMTUResult{Host: in.Host, Address: dst.String(), Protocol: used, Fallback: fallback, Probes: []MTUProbe{}}
+	// lo is the largest size known to get through, hi the largest still
+	// possible.
+	lo, size := 0, hi
+	for len(out.Probes) < maxMTUProbes && lo < hi {
+		r, err := p.probe(ctx, 64, size, true)
+		if err != nil {
+			if ctx.Err() != nil {
+				out.Note = "cancelled: " + ctx.Err().Error()
+				break
+			}
+			return errorResult(fmt.Sprintf("Probe of %d bytes failed: %v", size, err)), nil, nil
+		}
+		probe := MTUProbe{Size: size, Outcome: r.outcome, MTU: r.mtu}
+		if r.from.IsValid() {
+			probe.From = r.from.String()
+			probe.RTTMs = roundMs(r.rtt)
+		}
+		out.Probes = append(out.Probes, probe)
+		progress.notify(float64(len(out.Probes)), 0, fmt.Sprintf("%d bytes: %s", size, r.outcome))
+
+		switch r.outcome {
+		case outcomeReached:
+			lo = size
+		case outcomeFragNeeded, outcomeTooBig:
+			// A reported MTU that does not shrink the packet is bogus.
+			if r.mtu >= minMTU && r.mtu < size {
+				hi = r.mtu
+			} else {
+				hi = size - 1
+			}
+		case outcomeTimeout:
+			hi = size - 1
+		default:
+			out.Note = fmt.Sprintf("%s reported %s", r.from, r.detail)
+			hi = lo
+		}
+		if hi < minMTU {
+			break
+		}
+		if r.outcome == outcomeReached || r.outcome == outcomeTimeout {
+			size = max((lo+hi+1)/2, minMTU)
+		} else {
+			size = hi
+		}
+	}
+	out.MTU = lo
+	switch {
+	case out.Note != "":
+	case lo == 0:
+		out.Note = "no probe got through; the host may not answer " + used + " probes"
+	case lo < hi:
+		out.Note = fmt.Sprintf("probe limit reached; the path MTU is between %d and %d", lo, hi)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: formatPathMTU(out)}},
+	}, out, nil
+}
+
+func formatPathMTU(out PathMTUResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Path MTU to %s (%s) using %s probes: ", out.Host, out.Address, out.Protocol)
+	if out.MTU > 0 {
+		fmt.Fprintf(&b, "%d bytes", out.MTU)
+	} else {
+		b.WriteString("unknown")
+	}
+	if out.Fallback != "" {
+		fmt.Fprintf(&b, "\n(%s)", out.Fallback)
+	}
+	for _, pr := range out.Probes {
+		fmt.Fprintf(&b, "\n  %5d bytes: %s", pr.Size, pr.Outcome)
+		if pr.From != "" {
+			fmt.Fprintf(&b, " from %s", pr.From)
+		}
+		if pr.MTU > 0 {
+			fmt.Fprintf(&b, " (mtu %d)", pr.MTU)
+		}
+	}
+	if out.Note != "" {
+		fmt.Fprintf(&b, "\nNote: %s", out.Note)
+	}
+	return b.String()
+}
@@ -0,0 +1,298 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/rand/v2"
+	"net/netip"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ipv4HeaderLen = 20
+	icmpHeaderLen = 8
+	udpHeaderLen  = 8
+	// pollSlice is how often a probe waiting for its answer checks for
+	// cancellation.
+	pollSlice = 100 * time.Millisecond
+)
+
+// icmpProber sends ICMP echo requests from a raw socket. The socket sees
+// every ICMP message the host receives; answers are matched by the echo
+// ID and sequence number, which errors from routers quote.
+type icmpProber struct {
+	fd      int
+	dst     netip.Addr
+	id, seq uint16
+	timeout time.Duration
+}
+
+func newICMPProber(dst netip.Addr, timeout time.Duration) (netProber, error) {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_RAW|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, unix.IPPROTO_ICMP)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	return &icmpProber{fd: fd, dst: dst, id: uint16(rand.Uint32()), timeout: timeout}, nil
+}
+
+func (p *icmpProber) Close() error { return unix.Close(p.fd) }
+
+func (p *icmpProber) probe(ctx context.Context, ttl, size int, df bool) (probeResult, error) {
+	p.seq++
+	if err := setProbeOptions(p.fd, ttl, df); err != nil {
+		return probeResult{}, err
+	}
+	msg, err := (&icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: int(p.id), Seq: int(p.seq), Data: make([]byte, max(size-ipv4HeaderLen-icmpHeaderLen, 0))},
+	}).Marshal(nil)
+	if err != nil {
+		return probeResult{}, err
+	}
+	start := time.Now()
+	if err := unix.Sendto(p.fd, msg, 0, sockaddr4(p.dst, 0)); err != nil {
+		if errors.Is(err, unix.EMSGSIZE) {
+			return probeResult{outcome: outcomeTooBig, detail: "over the local route MTU", mtu: routeMTU(p.dst)}, nil
+		}
+		return probeResult{}, os.NewSyscallError("sendto", err)
+	}
+	buf := make([]byte, 1024)
+	deadline := start.Add(p.timeout)
+	for {
+		events, err := waitFD(ctx, p.fd, unix.POLLIN, deadline)
+		if err != nil || events == 0 {
+			return probeResult{outcome: outcomeTimeout}, err
+		}
+		n, _, err := unix.Recvfrom(p.fd, buf, 0)
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return probeResult{}, os.NewSyscallError("recvfrom", err)
+		}
+		if r, ok := p.match(buf[:n]); ok {
+			r.rtt = time.Since(start)
+			return r, nil
+		}
+	}
+}
+
+// match reports whether pkt, an IPv4 packet holding an ICMP message, is
+// the answer to the current probe.
+func (p *icmpProber) match(pkt []byte) (probeResult, bool) {
+	if len(pkt) < ipv4HeaderLen {
+		return probeResult{}, false
+	}
+	ihl := int(pkt[0]&0x0f) * 4
+	if len(pkt) < ihl+icmpHeaderLen {
+		return probeResult{}, false
+	}
+	from := netip.AddrFrom4([4]byte(pkt[12:16]))
+	msg := pkt[ihl:]
+	typ, code := ipv4.ICMPType(msg[0]), msg[1]
+	if typ == ipv4.ICMPTypeEchoReply {
+		ok := from == p.dst && binary.BigEndian.Uint16(msg[4:]) == p.id && binary.BigEndian.Uint16(msg[6:]) == p.seq
+		return probeResult{outcome: outcomeReached, from: from, detail: "echo reply"}, ok
+	}
+	if typ != ipv4.ICMPTypeTimeExceeded && typ != ipv4.ICMPTypeDestinationUnreachable {
+		return probeResult{}, false
+	}
+	// Errors quote the probe's IP header and its first 8 bytes, which
+	// hold the echo ID and sequence number.
+	inner := msg[icmpHeaderLen:]
+	if len(inner) < ipv4HeaderLen {
+		return probeResult{}, false
+	}
+	innerIHL := int(inner[0]&0x0f) * 4
+	if len(inner) < innerIHL+icmpHeaderLen || netip.AddrFrom4([4]byte(inner[16:20])) != p.dst {
+		return probeResult{}, false
+	}
+	echo := inner[innerIHL:]
+	if ipv4.ICMPType(echo[0]) != ipv4.ICMPTypeEcho || binary.BigEndian.Uint16(echo[4:]) != p.id || binary.BigEndian.Uint16(echo[6:]) != p.seq {
+		return probeResult{}, false
+	}
+	// Fragmentation needed carries the next-hop MTU in bytes 6-7.
+	return icmpErrorResult(from, typ, code, int(binary.BigEndian.Uint16(msg[6:]))), true
+}
+
+// udpProber sends UDP datagrams to a closed port from an ordinary socket,
+// which needs no privileges. With IP_RECVERR the kernel queues the ICMP
+// errors the probes cause on the socket, together with the address of the
+// router that sent them and the probe's payload, which carries a sequence
+// number. The destination answers with port unreachable.
+type udpProber struct {
+	fd      int
+	dst     netip.Addr
+	seq     uint32
+	timeout time.Duration
+}
+
+func newUDPProber(dst netip.Addr, port int, timeout time.Duration) (netProber, error) {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	if err := unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_RECVERR, 1); err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("setsockopt IP_RECVERR", err)
+	}
+	if err := unix.Connect(fd, sockaddr4(dst, port)); err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("connect", err)
+	}
+	return &udpProber{fd: fd, dst: dst, timeout: timeout}, nil
+}
+
+func (p *udpProber) Close() error { return unix.Close(p.fd) }
+
+func (p *udpProber) probe(ctx context.Context, ttl, size int, df bool) (probeResult, error) {
+	p.seq++
+	buf, oob := make([]byte, 1024), make([]byte, 512)
+	// Late answers to earlier probes would otherwise fail the send.
+	for {
+		if _, _, _, _, err := unix.Recvmsg(p.fd, buf, oob, unix.MSG_ERRQUEUE); err != nil {
+			break
+		}
+	}
+	unix.GetsockoptInt(p.fd, unix.SOL_SOCKET, unix.SO_ERROR)
+
+	if err := setProbeOptions(p.fd, ttl, df); err != nil {
+		return probeResult{}, err
+	}
+	payload := make([]byte, max(size-ipv4HeaderLen-udpHeaderLen, 4))
+	binary.BigEndian.PutUint32(payload, p.seq)
+	start := time.Now()
+	if _, err := unix.Write(p.fd, payload); err != nil {
+		if errors.Is(err, unix.EMSGSIZE) {
+			return probeResult{outcome: outcomeTooBig, detail: "over the local route MTU", mtu: routeMTU(p.dst)}, nil
+		}
+		return probeResult{}, os.NewSyscallError("write", err)
+	}
+	deadline := start.Add(p.timeout)
+	for {
+		events, err := waitFD(ctx, p.fd, unix.POLLIN, deadline)
+		if err != nil || events == 0 {
+			return probeResult{outcome: outcomeTimeout}, err
+		}
+		if events&unix.POLLERR != 0 {
+			if r, ok := p.readError(buf, oob); ok {
+				r.rtt = time.Since(start)
+				return r, nil
+			}
+			continue
+		}
+		// A service on the port answered.
+		if _, err := unix.Read(p.fd, buf); err == nil {
+			return probeResult{outcome: outcomeReached, from: p.dst, detail: "udp reply", rtt: time.Since(start)}, nil
+		}
+	}
+}
+
+// readError takes one error off the socket's error queue and reports
+// whether it answers the current probe.
+func (p *udpProber) readError(buf, oob []byte) (probeResult, bool) {
+	n, oobn, _, _, err := unix.Recvmsg(p.fd, buf, oob, unix.MSG_ERRQUEUE)
+	if err != nil {
+		// Only the pending socket error was left; clear it.
+		unix.GetsockoptInt(p.fd, unix.SOL_SOCKET, unix.SO_ERROR)
+		return probeResult{}, false
+	}
+	if n < 4 || binary.BigEndian.Uint32(buf) != p.seq {
+		return probeResult{}, false
+	}
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return probeResult{}, false
+	}
+	for _, m := range msgs {
+		// struct sock_extended_err, followed by the offender's sockaddr_in.
+		d := m.Data
+		if m.Header.Level != unix.SOL_IP || m.Header.Type != unix.IP_RECVERR || len(d) < 16 {
+			continue
+		}
+		errno, origin, typ, code := binary.NativeEndian.Uint32(d[0:]), d[4], d[5], d[6]
+		info := int(binary.NativeEndian.Uint32(d[8:]))
+		switch origin {
+		case unix.SO_EE_ORIGIN_ICMP:
+			var from netip.Addr
+			if len(d) >= 24 {
+				from = netip.AddrFrom4([4]byte(d[20:24]))
+			}
+			return icmpErrorResult(from, ipv4.ICMPType(typ), code, info), true
+		case unix.SO_EE_ORIGIN_LOCAL:
+			if unix.Errno(errno) == unix.EMSGSIZE {
+				return probeResult{outcome: outcomeTooBig, detail: "over the local route MTU", mtu: info}, true
+			}
+		}
+	}
+	return probeResult{}, false
+}
+
+// setProbeOptions sets the TTL and Don't Fragment for the next packet.
+// With DF the kernel also refuses packets over the MTU it knows for the
+// route, including MTUs learned from earlier fragmentation-needed errors.
+func setProbeOptions(fd, ttl int, df bool) error {
+	if err := unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_TTL, ttl); err != nil {
+		return os.NewSyscallError("setsockopt IP_TTL", err)
+	}
+	pmtu := unix.IP_PMTUDISC_DONT
+	if df {
+		pmtu = unix.IP_PMTUDISC_DO
+	}
+	if err := unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, pmtu); err != nil {
+		return os.NewSyscallError("setsockopt IP_MTU_DISCOVER", err)
+	}
+	return nil
+}
+
+// routeMTU returns the MTU the kernel knows for the route to dst, or 0.
+func routeMTU(dst netip.Addr) int {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return 0
+	}
+	defer unix.Close(fd)
+	if err := unix.Connect(fd, sockaddr4(dst, tracePort)); err != nil {
+		return 0
+	}
+	mtu, err := unix.GetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_MTU)
+	if err != nil {
+		return 0
+	}
+	return mtu
+}
+
+func sockaddr4(addr netip.Addr, port int) *unix.SockaddrInet4 {
+	return &unix.SockaddrInet4{Port: port, Addr: addr.As4()}
+}
+
+// waitFD polls fd until one of events (or an error) is pending, the
+// deadline passes or ctx is done. It returns the pending events, 0 on
+// timeout.
+func waitFD(ctx context.Context, fd int, events int16, deadline time.Time) (int16, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		left := time.Until(deadline)
+		if left <= 0 {
+			return 0, nil
+		}
+		fds := []unix.PollFd{{Fd: int32(fd), Events: events}}
+		n, err := unix.Poll(fds, int(min(left, pollSlice).Milliseconds())+1)
+		if err != nil && !errors.Is(err, unix.EINTR) {
+			return 0, os.NewSyscallError("poll", err)
+		}
+		if n > 0 {
+			return fds[0].Revents, nil
+		}
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net/netip"
+	"time"
+)
+
+// errNetDiagUnsupported is returned on platforms without the Linux socket
+// options the probes rely on (IP_RECVERR, IP_MTU_DISCOVER).
+var errNetDiagUnsupported = errors.New("traceroute and path_mtu are only supported on Linux")
+
+func newICMPProber(netip.Addr, time.Duration) (netProber, error) {
+	return nil, errNetDiagUnsupported
+}
+
+func newUDPProber(netip.Addr, int, time.Duration) (netProber, error) {
+	return nil, errNetDiagUnsupported
+}
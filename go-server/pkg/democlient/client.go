@@ -0,0 +1,27 @@
+// Package democlient is a typed client for the demo server's tools. The
+// methods and argument/result structs in client_gen.go are generated from
+// the server's tool registry; run `go generate ./pkg/democlient` after
+// adding or changing a tool.
+package democlient
+
+//go:generate go run ../../cmd/genclient -server "go run ../.. -fs-root . -enable-exec -enable-net-diag" -package democlient -out client_gen.go
+
+import (
+	"context"
+
+	"mcp-demo-server/pkg/mcpclient"
+)
+
+// Client wraps an mcpclient.Client with one method per server tool.
+type Client struct {
+	*mcpclient.Client
+}
+
+// Connect connects to the demo server; see mcpclient.Connect.
+func Connect(ctx context.Context, opts mcpclient.Options) (*Client, error) {
+	c, err := mcpclient.Connect(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Client: c}, nil
+}
@@ -0,0 +1,1095 @@
+// Code generated by genclient; DO NOT EDIT.
+
+package democlient
+
+import (
+	"context"
+
+	"mcp-demo-server/pkg/mcpclient"
+)
+
+// AsnLookupArgs holds the arguments of the asn_lookup tool.
+type AsnLookupArgs struct {
+	// Also list the prefixes each origin AS announces (ripestat only)
+	ListPrefixes *bool `json:"list_prefixes,omitempty"`
+	// Prefixes listed per AS with list_prefixes (default 50, max 500)
+	MaxPrefixes int `json:"max_prefixes,omitempty"`
+	// ripestat or cymru (default: the server's -asn-provider)
+	Provider string `json:"provider,omitempty"`
+	// IP address or prefix (e.g. 193.0.6.139 or 193.0.0.0/21)
+	Resource string `json:"resource"`
+}
+
+// AsnLookupResultOrigin is a nested object in a tool schema.
+type AsnLookupResultOrigin struct {
+	// Allocation date (cymru)
+	Allocated string `json:"allocated,omitempty"`
+	Asn       int    `json:"asn"`
+	Country   string `json:"country,omitempty"`
+	// AS holder name
+	Name string `json:"name,omitempty"`
+	// Prefixes the AS announces, with list_prefixes
+	Prefixes []string `json:"prefixes,omitempty"`
+	// Number of prefixes the AS announces, when listed
+	PrefixesTotal int `json:"prefixes_total,omitempty"`
+	// Regional internet registry (cymru)
+	Registry string `json:"registry,omitempty"`
+}
+
+// AsnLookupResult is the structured result of the asn_lookup tool.
+type AsnLookupResult struct {
+	// Served from the server's cache
+	Cached bool `json:"cached"`
+	// When the provider was asked (RFC 3339)
+	FetchedAt string `json:"fetched_at"`
+	Note      string `json:"note,omitempty"`
+	// Origin ASes; more than one means the prefix is announced from several ASes (MOAS)
+	Origins []AsnLookupResultOrigin `json:"origins"`
+	// BGP prefix announcing the resource; empty when it is not announced
+	Prefix   string `json:"prefix,omitempty"`
+	Provider string `json:"provider"`
+	Resource string `json:"resource"`
+}
+
+// CheckLinksArgs holds the arguments of the check_links tool.
+type CheckLinksArgs struct {
+	// URLs to check instead of extracting them from a page
+	Links []string `json:"links,omitempty"`
+	// Maximum links to check (default 50, max 200; the per-call request budget also applies)
+	MaxLinks int `json:"max_links,omitempty"`
+	// Only check links on the page's own origin
+	SameOrigin *bool `json:"same_origin,omitempty"`
+	// Page whose links are checked: a, area, link, img, script, iframe and source elements (use either url or links)
+	URL string `json:"url,omitempty"`
+}
+
+// CheckLinksResultResult is a nested object in a tool schema.
+type CheckLinksResultResult struct {
+	// True for 4xx and 5xx status codes and failed requests
+	Broken bool   `json:"broken"`
+	Error  string `json:"error,omitempty"`
+	// Where the link redirects to, when it does
+	FinalURL  string `json:"final_url,omitempty"`
+	LatencyMs int    `json:"latency_ms"`
+	// HEAD, or GET when the server rejected HEAD
+	Method     string `json:"method,omitempty"`
+	Redirects  int    `json:"redirects,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	URL        string `json:"url"`
+}
+
+// CheckLinksResultSkippedItem is a nested object in a tool schema.
+type CheckLinksResultSkippedItem struct {
+	Reason string `json:"reason"`
+	URL    string `json:"url"`
+}
+
+// CheckLinksResult is the structured result of the check_links tool.
+type CheckLinksResult struct {
+	Broken  int `json:"broken"`
+	Checked int `json:"checked"`
+	// Distinct links found or given
+	Found      int `json:"found"`
+	Redirected int `json:"redirected"`
+	// Broken links first, then redirected ones, then the rest
+	Results []CheckLinksResultResult `json:"results"`
+	// Links not checked: over max_links, refused by the outbound policy or left when the request budget ran out
+	Skipped []CheckLinksResultSkippedItem `json:"skipped,omitempty"`
+	// The page URL, or links when the links were given
+	Source string `json:"source"`
+}
+
+// CrawlPreviewArgs holds the arguments of the crawl_preview tool.
+type CrawlPreviewArgs struct {
+	// Pause between requests in milliseconds (default 1000, min 250); a larger robots.txt Crawl-delay wins
+	DelayMs int `json:"delay_ms,omitempty"`
+	// Maximum pages to visit (default 10, max 50; the per-call request budget also applies)
+	MaxPages int `json:"max_pages,omitempty"`
+	// Sitemap (XML urlset or sitemap index) or seed page URL; only pages on the same origin are visited
+	URL string `json:"url"`
+}
+
+// CrawlPreviewResultPage is a nested object in a tool schema.
+type CrawlPreviewResultPage struct {
+	Description string `json:"description,omitempty"`
+	Error       string `json:"error,omitempty"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	Title       string `json:"title,omitempty"`
+	URL         string `json:"url"`
+}
+
+// CrawlPreviewResult is the structured result of the crawl_preview tool.
+type CrawlPreviewResult struct {
+	// URI of the resource holding the full results (final URLs, content types, canonical URLs, link counts, timings)
+	Artifact string `json:"artifact,omitempty"`
+	// Pause used between requests
+	DelayMs int                      `json:"delay_ms"`
+	Pages   []CrawlPreviewResultPage `json:"pages"`
+	// Same-origin URLs found but not visited
+	Pending int `json:"pending"`
+	// URLs not visited because robots.txt disallows them
+	RobotsSkipped int    `json:"robots_skipped"`
+	Seed          string `json:"seed"`
+	// sitemap when the URL was a sitemap, links when pages were found by following links
+	Source string `json:"source"`
+	// Why the crawl ended early, e.g. the request budget ran out
+	Stopped string `json:"stopped,omitempty"`
+}
+
+// DelegateArgs holds the arguments of the delegate tool.
+type DelegateArgs struct {
+	// Background material passed along with the prompt
+	Context string `json:"context,omitempty"`
+	// Token budget for sampling (default 1024)
+	MaxTokens int `json:"max_tokens,omitempty"`
+	// Task or question for the other agent
+	Prompt string `json:"prompt"`
+	// 'sampling' (default) to ask the calling client's model, or the name of a configured agent
+	Target string `json:"target,omitempty"`
+}
+
+// DelegateResult is the structured result of the delegate tool.
+type DelegateResult struct {
+	// Number of streamed chunks received (1 for non-streaming targets)
+	Chunks    int `json:"chunks"`
+	ElapsedMs int `json:"elapsed_ms"`
+	// sampling, http or mcp
+	Kind string `json:"kind"`
+	// Model reported by the sampling client
+	Model     string `json:"model,omitempty"`
+	Response  string `json:"response"`
+	Target    string `json:"target"`
+	Truncated bool   `json:"truncated"`
+}
+
+// DownloadArgs holds the arguments of the download tool.
+type DownloadArgs struct {
+	// Follow HTTP redirects (default true)
+	FollowRedirects *bool `json:"follow_redirects,omitempty"`
+	// Request headers to send (only server-allowlisted names are accepted)
+	Headers map[string]string `json:"headers,omitempty"`
+	// Fail when the file is larger than this (default and max: the server's download limit, 100 MiB unless configured otherwise)
+	MaxBytes int `json:"max_bytes,omitempty"`
+	// URL to download with GET (must be http or https)
+	URL string `json:"url"`
+}
+
+// DownloadResult is the structured result of the download tool.
+type DownloadResult struct {
+	// Size of the downloaded file, after decompression
+	Bytes       int    `json:"bytes"`
+	ContentType string `json:"content_type"`
+	// Time from sending the request to storing the file, in milliseconds
+	ElapsedMs int `json:"elapsed_ms"`
+	// URL of the response after following redirects
+	FinalURL string `json:"final_url"`
+	// URI of the resource holding the file; read it with resources/read
+	Resource string `json:"resource"`
+	// SHA-256 of the file, hex encoded
+	Sha256     string `json:"sha256"`
+	StatusCode int    `json:"status_code"`
+	URL        string `json:"url"`
+}
+
+// EchotestArgs holds the arguments of the echotest tool.
+type EchotestArgs struct {
+	// Wait this long before replying (max 30000); progress is reported every second when the request has a progress token
+	DelayMs int `json:"delay_ms,omitempty"`
+	// Message to echo back
+	Message string `json:"message"`
+	// Repeat the message this many times, one per line (default 1, max 100)
+	Repeat int `json:"repeat,omitempty"`
+	// Reverse the message (by character)
+	Reverse *bool `json:"reverse,omitempty"`
+	// Upper-case the message
+	Uppercase *bool `json:"uppercase,omitempty"`
+}
+
+// ElicitArgs holds the arguments of the elicit tool.
+type ElicitArgs struct {
+	// What the confirm scenario asks about (default: delete the demo records)
+	Action string `json:"action,omitempty"`
+	// Profile age; asked for when zero
+	Age int `json:"age,omitempty"`
+	// Profile email address; asked for when empty
+	Email string `json:"email,omitempty"`
+	// Profile name; asked for when empty
+	Name string `json:"name,omitempty"`
+	// Profile role (developer, operator or tester); asked for when empty
+	Role string `json:"role,omitempty"`
+	// profile (default): ask only for the profile fields not given as arguments; confirm: ask the user to confirm an action; api_key: ask for a secret
+	Scenario string `json:"scenario,omitempty"`
+}
+
+// ElicitResult is the structured result of the elicit tool.
+type ElicitResult struct {
+	// The user's answer: accept, decline or cancel; none when nothing had to be asked
+	Action string `json:"action"`
+	// Fields requested from the user
+	Asked    []string `json:"asked"`
+	Outcome  string   `json:"outcome"`
+	Scenario string   `json:"scenario"`
+	// Arguments merged with the accepted answers; secrets are masked
+	Values map[string]any `json:"values"`
+}
+
+// ExecArgs holds the arguments of the exec tool.
+type ExecArgs struct {
+	// Arguments passed directly to the command (no shell expansion)
+	Args []string `json:"args,omitempty"`
+	// Name of an allowlisted command
+	Command string `json:"command"`
+	// Kill the command after this many seconds (default 10, max 60)
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
+
+// ExecResult is the structured result of the exec tool.
+type ExecResult struct {
+	Args      []string `json:"args"`
+	Command   string   `json:"command"`
+	ElapsedMs int      `json:"elapsed_ms"`
+	// Process exit code, or -1 if it was killed
+	ExitCode        int    `json:"exit_code"`
+	Stderr          string `json:"stderr"`
+	StderrTruncated bool   `json:"stderr_truncated"`
+	Stdout          string `json:"stdout"`
+	StdoutTruncated bool   `json:"stdout_truncated"`
+	TimedOut        bool   `json:"timed_out"`
+}
+
+// FetchArgs holds the arguments of the fetch tool.
+type FetchArgs struct {
+	// Request body (max 65536 bytes), typically used with POST, PUT or PATCH
+	Body string `json:"body,omitempty"`
+	// HTML handling: raw (default), text or markdown; text and markdown strip scripts, styles and page boilerplate
+	Extract string `json:"extract,omitempty"`
+	// Follow HTTP redirects (default true); when false the 3xx response itself is returned
+	FollowRedirects *bool `json:"follow_redirects,omitempty"`
+	// Return a binary body (image, audio, PDF, archive...) as text cut at max_bytes instead of as a content block
+	ForceText *bool `json:"force_text,omitempty"`
+	// Request headers to send (only server-allowlisted names are accepted)
+	Headers map[string]string `json:"headers,omitempty"`
+	// Limit response body bytes (min 256; the server's default is 4096 and its cap 65536 unless configured otherwise)
+	MaxBytes int `json:"max_bytes,omitempty"`
+	// Maximum redirect hops to follow (default 10, max 20)
+	MaxRedirects int `json:"max_redirects,omitempty"`
+	// HTTP method: GET (default), HEAD, POST, PUT, PATCH, DELETE or OPTIONS
+	Method string `json:"method,omitempty"`
+	// Return the body as received, without content-type adapters (JSON pretty-printing, XML to JSON, CSV preview, image thumbnails, and HTML extraction when extract asks for it)
+	Raw *bool `json:"raw,omitempty"`
+	// Times to send a GET, HEAD, OPTIONS, PUT or DELETE request again after a dropped connection or a 408, 429, 500, 502, 503 or 504 response (default: the server's, 2 unless configured otherwise; max 5)
+	Retries *int `json:"retries,omitempty"`
+	// Wait before the first retry in milliseconds, doubled for each further one, with jitter (default: the server's, 250 unless configured otherwise); a Retry-After header takes precedence
+	RetryBackoffMs int `json:"retry_backoff_ms,omitempty"`
+	// Give up on the request after this many milliseconds, body included (default and max: the server's fetch timeout, 10000 unless configured otherwise)
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+	// URL to fetch (must be http or https)
+	URL string `json:"url"`
+}
+
+// FetchResult is the structured result of the fetch tool.
+type FetchResult struct {
+	// Content-type adapter applied to the body: json, html, xml, csv or image
+	Adapter string `json:"adapter,omitempty"`
+	// Requests sent: 1 plus the retries
+	Attempts int `json:"attempts"`
+	// True when the body is binary: it is returned as an image, audio or embedded resource content block, not in body
+	Binary *bool  `json:"binary,omitempty"`
+	Body   string `json:"body"`
+	// Number of body bytes returned
+	Bytes int `json:"bytes"`
+	// Character set the body was converted from to UTF-8
+	Charset string `json:"charset,omitempty"`
+	// Content-Encoding the body was decompressed from
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	ContentType     string `json:"content_type"`
+	// Time from sending the request to reading the body, in milliseconds
+	ElapsedMs int `json:"elapsed_ms"`
+	// Extraction applied to the body: raw, text or markdown
+	Extract string `json:"extract"`
+	// URL of the response after following redirects
+	FinalURL string            `json:"final_url"`
+	Headers  map[string]string `json:"headers"`
+	// Address family of remote_addr: ipv4 or ipv6
+	IPFamily string `json:"ip_family,omitempty"`
+	Method   string `json:"method"`
+	// Redirect chain: every URL redirected to, in order
+	Redirects []string `json:"redirects,omitempty"`
+	// Address the response came from, as ip:port
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	Status     string `json:"status"`
+	StatusCode int    `json:"status_code"`
+	// True when the body was cut at max_bytes
+	Truncated bool   `json:"truncated"`
+	URL       string `json:"url"`
+}
+
+// FetchBatchArgs holds the arguments of the fetch_batch tool.
+type FetchBatchArgs struct {
+	// How many URLs are fetched at once (default 4, max 8)
+	Concurrency int `json:"concurrency,omitempty"`
+	// HTML handling: raw (default), text or markdown
+	Extract string `json:"extract,omitempty"`
+	// Follow HTTP redirects (default true)
+	FollowRedirects *bool `json:"follow_redirects,omitempty"`
+	// Request headers sent with every request (only server-allowlisted names are accepted)
+	Headers map[string]string `json:"headers,omitempty"`
+	// Limit each response body, as in fetch
+	MaxBytes int `json:"max_bytes,omitempty"`
+	// Return bodies as received, without content-type adapters
+	Raw *bool `json:"raw,omitempty"`
+	// Times each request is sent again after a transient failure, as in fetch
+	Retries *int `json:"retries,omitempty"`
+	// Give up on each request after this many milliseconds, as in fetch
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+	// URLs to fetch with GET (http or https, at most 20)
+	Urls []string `json:"urls"`
+}
+
+// FetchBatchResultResultResult is a nested object in a tool schema.
+type FetchBatchResultResultResult struct {
+	// Content-type adapter applied to the body: json, html, xml, csv or image
+	Adapter string `json:"adapter,omitempty"`
+	// Requests sent: 1 plus the retries
+	Attempts int `json:"attempts"`
+	// True when the body is binary: it is returned as an image, audio or embedded resource content block, not in body
+	Binary *bool  `json:"binary,omitempty"`
+	Body   string `json:"body"`
+	// Number of body bytes returned
+	Bytes int `json:"bytes"`
+	// Character set the body was converted from to UTF-8
+	Charset string `json:"charset,omitempty"`
+	// Content-Encoding the body was decompressed from
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	ContentType     string `json:"content_type"`
+	// Time from sending the request to reading the body, in milliseconds
+	ElapsedMs int `json:"elapsed_ms"`
+	// Extraction applied to the body: raw, text or markdown
+	Extract string `json:"extract"`
+	// URL of the response after following redirects
+	FinalURL string            `json:"final_url"`
+	Headers  map[string]string `json:"headers"`
+	// Address family of remote_addr: ipv4 or ipv6
+	IPFamily string `json:"ip_family,omitempty"`
+	Method   string `json:"method"`
+	// Redirect chain: every URL redirected to, in order
+	Redirects []string `json:"redirects,omitempty"`
+	// Address the response came from, as ip:port
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	Status     string `json:"status"`
+	StatusCode int    `json:"status_code"`
+	// True when the body was cut at max_bytes
+	Truncated bool   `json:"truncated"`
+	URL       string `json:"url"`
+}
+
+// FetchBatchResultResult is a nested object in a tool schema.
+type FetchBatchResultResult struct {
+	Error string `json:"error,omitempty"`
+	// True when a response was received, whatever its status code
+	OK bool `json:"ok"`
+	// The fetch result, when ok
+	Result *FetchBatchResultResultResult `json:"result,omitempty"`
+	URL    string                        `json:"url"`
+}
+
+// FetchBatchResult is the structured result of the fetch_batch tool.
+type FetchBatchResult struct {
+	// Time for the whole batch, in milliseconds
+	ElapsedMs int `json:"elapsed_ms"`
+	Failed    int `json:"failed"`
+	Requested int `json:"requested"`
+	// One entry per URL, in the order given
+	Results   []FetchBatchResultResult `json:"results"`
+	Succeeded int                      `json:"succeeded"`
+}
+
+// LatencyProbeArgs holds the arguments of the latency_probe tool.
+type LatencyProbeArgs struct {
+	// Number of requests (default 5, max 20; the per-call request budget also applies)
+	Count int `json:"count,omitempty"`
+	// Pause between requests in milliseconds (default 100, max 5000)
+	IntervalMs int `json:"interval_ms,omitempty"`
+	// Reuse the connection between requests; by default every request opens a new one so connect and TLS times are measured each time
+	KeepAlive *bool `json:"keep_alive,omitempty"`
+	// GET (default) or HEAD
+	Method string `json:"method,omitempty"`
+	// URL to probe (must be http or https); redirects are not followed
+	URL string `json:"url"`
+}
+
+// LatencyProbeResultSample is a nested object in a tool schema.
+type LatencyProbeResultSample struct {
+	Bytes int `json:"bytes"`
+	// TCP connect, including trying further addresses
+	ConnectMs float64 `json:"connect_ms"`
+	// Name lookup; zero when the server's DNS cache answered
+	DnsMs float64 `json:"dns_ms"`
+	Error string  `json:"error,omitempty"`
+	// The request went over an existing connection
+	Reused     *bool   `json:"reused,omitempty"`
+	Seq        int     `json:"seq"`
+	StatusCode int     `json:"status_code,omitempty"`
+	TlsMs      float64 `json:"tls_ms"`
+	// From the start of the request to the end of the body
+	TotalMs float64 `json:"total_ms"`
+	// From the start of the request to the first response byte
+	TtfbMs float64 `json:"ttfb_ms"`
+}
+
+// LatencyProbeResult is the structured result of the latency_probe tool.
+type LatencyProbeResult struct {
+	Failed     int                        `json:"failed"`
+	KeepAlive  bool                       `json:"keep_alive"`
+	Method     string                     `json:"method"`
+	RemoteAddr string                     `json:"remote_addr,omitempty"`
+	Requested  int                        `json:"requested"`
+	Samples    []LatencyProbeResultSample `json:"samples"`
+	// Per phase (dns, connect, tls, ttfb, total); dns, connect and tls only count samples where the phase happened
+	Stats       map[string]any `json:"stats"`
+	StatusCodes map[string]int `json:"status_codes,omitempty"`
+	// Why the probe ended early, e.g. the request budget ran out
+	Stopped   string `json:"stopped,omitempty"`
+	Succeeded int    `json:"succeeded"`
+	URL       string `json:"url"`
+}
+
+// ListDirArgs holds the arguments of the list_dir tool.
+type ListDirArgs struct {
+	// Directory path relative to the sandbox root (default: the root)
+	Path string `json:"path,omitempty"`
+}
+
+// ListDirResultEntry is a nested object in a tool schema.
+type ListDirResultEntry struct {
+	ModTime string `json:"mod_time"`
+	Name    string `json:"name"`
+	Size    int    `json:"size"`
+	// file, dir, symlink or other
+	Type string `json:"type"`
+}
+
+// ListDirResult is the structured result of the list_dir tool.
+type ListDirResult struct {
+	Entries []ListDirResultEntry `json:"entries"`
+	Path    string               `json:"path"`
+	// True when the directory has more than 1000 entries
+	Truncated bool `json:"truncated"`
+}
+
+// ListRootsArgs holds the arguments of the list_roots tool.
+type ListRootsArgs struct {
+}
+
+// ListRootsResultRoot is a nested object in a tool schema.
+type ListRootsResultRoot struct {
+	// Why the root cannot be used
+	Error string `json:"error,omitempty"`
+	Name  string `json:"name,omitempty"`
+	// Local directory for file:// roots
+	Path string `json:"path,omitempty"`
+	// How the root relates to the sandbox: inside, contains or outside; empty without a sandbox
+	Sandbox string `json:"sandbox,omitempty"`
+	URI     string `json:"uri"`
+}
+
+// ListRootsResult is the structured result of the list_roots tool.
+type ListRootsResult struct {
+	Note  string                `json:"note,omitempty"`
+	Roots []ListRootsResultRoot `json:"roots"`
+	// Directories, relative to the sandbox root, the filesystem tools may use in this session
+	Scope []string `json:"scope,omitempty"`
+	// False when the client does not provide roots
+	Supported bool `json:"supported"`
+}
+
+// ListTimezonesArgs holds the arguments of the list_timezones tool.
+type ListTimezonesArgs struct {
+	// Maximum zones to return (default 50, max 600)
+	Limit int `json:"limit,omitempty"`
+	// Case-insensitive text to look for, e.g. Kyiv, new york or America/ (default: all)
+	Query string `json:"query,omitempty"`
+}
+
+// ListTimezonesResultZone is a nested object in a tool schema.
+type ListTimezonesResultZone struct {
+	// Current zone abbreviation
+	Abbrev string `json:"abbrev"`
+	Name   string `json:"name"`
+	// Current offset from UTC as +HH:MM
+	UTCOffset string `json:"utc_offset"`
+}
+
+// ListTimezonesResult is the structured result of the list_timezones tool.
+type ListTimezonesResult struct {
+	Query string `json:"query,omitempty"`
+	// Number of matching zones, before limit
+	Total     int                       `json:"total"`
+	Truncated bool                      `json:"truncated"`
+	Zones     []ListTimezonesResultZone `json:"zones"`
+}
+
+// PathMTUArgs holds the arguments of the path_mtu tool.
+type PathMTUArgs struct {
+	// Hostname or IPv4 address to measure the path to
+	Host string `json:"host"`
+	// Largest packet size to try in bytes (default 1500, max 9000)
+	MaxMTU int `json:"max_mtu,omitempty"`
+	// auto (default), icmp or udp; see traceroute
+	Protocol string `json:"protocol,omitempty"`
+	// How long to wait for each probe's answer in milliseconds (default 1000, max 5000)
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+}
+
+// PathMTUResultProbe is a nested object in a tool schema.
+type PathMTUResultProbe struct {
+	From string `json:"from,omitempty"`
+	// MTU reported by the router or the local route
+	MTU int `json:"mtu,omitempty"`
+	// reached, frag_needed (a router reported a smaller MTU), too_big_local (over the local route MTU), timeout or unreachable
+	Outcome string  `json:"outcome"`
+	RttMs   float64 `json:"rtt_ms,omitempty"`
+	Size    int     `json:"size"`
+}
+
+// PathMTUResult is the structured result of the path_mtu tool.
+type PathMTUResult struct {
+	Address  string `json:"address"`
+	Fallback string `json:"fallback,omitempty"`
+	Host     string `json:"host"`
+	// Largest packet that reached the host unfragmented; 0 when no probe got through
+	MTU      int                  `json:"mtu"`
+	Note     string               `json:"note,omitempty"`
+	Probes   []PathMTUResultProbe `json:"probes"`
+	Protocol string               `json:"protocol"`
+}
+
+// PrefsArgs holds the arguments of the prefs tool.
+type PrefsArgs struct {
+	// Keys to reset, e.g. fetch.max_bytes or base_url
+	Keys []string `json:"keys,omitempty"`
+	// Forget learned preferences: the given keys, or all of them
+	Reset *bool `json:"reset,omitempty"`
+}
+
+// PrefsResultPref is a nested object in a tool schema.
+type PrefsResultPref struct {
+	// tool.argument, or base_url
+	Key       string `json:"key"`
+	LearnedAt string `json:"learned_at"`
+	Value     any    `json:"value"`
+}
+
+// PrefsResult is the structured result of the prefs tool.
+type PrefsResult struct {
+	Prefs []PrefsResultPref `json:"prefs"`
+	// Keys that were forgotten
+	Reset []string `json:"reset,omitempty"`
+}
+
+// RandomArgs holds the arguments of the random tool.
+type RandomArgs struct {
+	// Number of values to generate (default 1, max 100)
+	Count int `json:"count,omitempty"`
+	// Encoding for bytes: hex (default) or base64; tokens are always URL-safe base64
+	Encoding string `json:"encoding,omitempty"`
+	// uuid4 (default), uuid7, int, bytes or token
+	Kind string `json:"kind,omitempty"`
+	// Number of random bytes for bytes (default 16) and token (default 32), max 1024
+	Length int `json:"length,omitempty"`
+	// Upper bound for int, inclusive (default 100)
+	Max int `json:"max,omitempty"`
+	// Lower bound for int, inclusive (default 0)
+	Min int `json:"min,omitempty"`
+	// Deterministic seed for reproducible output; omit for cryptographically secure randomness
+	Seed *int `json:"seed,omitempty"`
+}
+
+// RandomResult is the structured result of the random tool.
+type RandomResult struct {
+	Kind string `json:"kind"`
+	// True when output came from the deterministic seed and must not be used for secrets
+	Seeded bool     `json:"seeded"`
+	Values []string `json:"values"`
+}
+
+// ReadFileArgs holds the arguments of the read_file tool.
+type ReadFileArgs struct {
+	// Maximum bytes to return (default 65536, max 1048576)
+	MaxBytes int `json:"max_bytes,omitempty"`
+	// Byte offset to start reading from (default 0)
+	Offset int `json:"offset,omitempty"`
+	// File path relative to the sandbox root
+	Path string `json:"path"`
+}
+
+// ReadFileResult is the structured result of the read_file tool.
+type ReadFileResult struct {
+	// Number of bytes returned
+	Bytes   int    `json:"bytes"`
+	Content string `json:"content"`
+	// utf-8, or base64 for binary content
+	Encoding string `json:"encoding"`
+	Offset   int    `json:"offset"`
+	Path     string `json:"path"`
+	// Total file size in bytes
+	Size int `json:"size"`
+	// True when the file continues past the returned bytes
+	Truncated bool `json:"truncated"`
+}
+
+// SetDefaultsArgs holds the arguments of the set_defaults tool.
+type SetDefaultsArgs struct {
+	// Drop defaults set with this tool (initialize-time defaults remain)
+	Clear *bool `json:"clear,omitempty"`
+	// Default BCP 47 locale, e.g. uk-UA; sent as Accept-Language by fetch
+	Locale string `json:"locale,omitempty"`
+	// Default IANA timezone for time tools, e.g. Europe/Kyiv
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// SetDefaultsResult is the structured result of the set_defaults tool.
+type SetDefaultsResult struct {
+	// Effective default locale
+	Locale string `json:"locale,omitempty"`
+	// Effective default timezone
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// StatusReportArgs holds the arguments of the status_report tool.
+type StatusReportArgs struct {
+}
+
+// StatusReportResultToday is a nested object in a tool schema.
+type StatusReportResultToday struct {
+	Date    string `json:"date"`
+	HTTP5xx int    `json:"http_5xx"`
+	// HTTP requests other than health checks
+	HTTPRequests    int      `json:"http_requests"`
+	HTTPSuccessRate *float64 `json:"http_success_rate,omitempty"`
+	ToolCalls       int      `json:"tool_calls"`
+	// Calls that returned an error result, such as an unreachable URL
+	ToolErrors int `json:"tool_errors"`
+	// Calls that failed with a protocol error or panic
+	ToolFailed int `json:"tool_failed"`
+	// Share of calls that did not fail; error results count as served
+	ToolSuccessRate *float64 `json:"tool_success_rate,omitempty"`
+}
+
+// StatusReportResult is the structured result of the status_report tool.
+type StatusReportResult struct {
+	Healthy bool `json:"healthy"`
+	// Outbound hosts whose circuit breaker is open
+	OpenCircuits []string `json:"open_circuits,omitempty"`
+	Problems     []string `json:"problems,omitempty"`
+	// The /readyz checks
+	Readiness map[string]any `json:"readiness"`
+	Restarts  int            `json:"restarts"`
+	// Open MCP sessions
+	Sessions      int                      `json:"sessions"`
+	Summary       string                   `json:"summary"`
+	Today         *StatusReportResultToday `json:"today,omitempty"`
+	UncleanStops  int                      `json:"unclean_stops"`
+	Uptime        string                   `json:"uptime"`
+	UptimeSeconds float64                  `json:"uptime_seconds"`
+}
+
+// SummarizeURLArgs holds the arguments of the summarize_url tool.
+type SummarizeURLArgs struct {
+	// What the summary should concentrate on, e.g. 'pricing' or 'breaking changes'
+	Focus string `json:"focus,omitempty"`
+	// Token budget for the summary (default 512)
+	MaxTokens int `json:"max_tokens,omitempty"`
+	// Page to summarize (must be http or https)
+	URL string `json:"url"`
+}
+
+// SummarizeURLResult is the structured result of the summarize_url tool.
+type SummarizeURLResult struct {
+	ContentType string `json:"content_type"`
+	ElapsedMs   int    `json:"elapsed_ms"`
+	// Bytes of page text sent to the model
+	InputBytes int `json:"input_bytes"`
+	// The page text was cut to fit the model request
+	InputTruncated bool `json:"input_truncated"`
+	// Model reported by the sampling client
+	Model      string `json:"model,omitempty"`
+	StopReason string `json:"stop_reason,omitempty"`
+	Summary    string `json:"summary"`
+	URL        string `json:"url"`
+}
+
+// TimeConvertArgs holds the arguments of the time_convert tool.
+type TimeConvertArgs struct {
+	// Duration to add, e.g. '90m', '1d2h', '-2w', '1mo' (y, mo, w, d, h, m, s, ms); days and larger follow the calendar
+	Add string `json:"add,omitempty"`
+	// Second timestamp; the result includes the difference from the converted time to it
+	DiffTo string `json:"diff_to,omitempty"`
+	// List this many upcoming DST/offset transitions in the target zone (max 10)
+	DstTransitions int `json:"dst_transitions,omitempty"`
+	// IANA timezone for timestamps without an offset (default: the session default from set_defaults, else UTC)
+	From string `json:"from,omitempty"`
+	// Timestamp: RFC 3339, 'YYYY-MM-DD[ HH:MM[:SS]]', Unix seconds, or 'now' (default)
+	Time string `json:"time,omitempty"`
+	// IANA timezone to convert to (default: same as from)
+	To string `json:"to,omitempty"`
+}
+
+// TimeConvertResultDifference is a nested object in a tool schema.
+type TimeConvertResultDifference struct {
+	// The same difference as days, hours, minutes and seconds
+	Human string `json:"human"`
+	// diff_to minus the result time, in seconds
+	Seconds int `json:"seconds"`
+}
+
+// TimeConvertResultDstTransition is a nested object in a tool schema.
+type TimeConvertResultDstTransition struct {
+	// Instant of the change, RFC 3339 in UTC
+	At         string `json:"at"`
+	FromAbbrev string `json:"from_abbrev"`
+	FromOffset string `json:"from_offset"`
+	ToAbbrev   string `json:"to_abbrev"`
+	ToOffset   string `json:"to_offset"`
+}
+
+// TimeConvertResult is the structured result of the time_convert tool.
+type TimeConvertResult struct {
+	// Zone abbreviation at the result time, e.g. CEST
+	Abbrev         string                           `json:"abbrev"`
+	Added          string                           `json:"added,omitempty"`
+	Difference     *TimeConvertResultDifference     `json:"difference,omitempty"`
+	DstTransitions []TimeConvertResultDstTransition `json:"dst_transitions,omitempty"`
+	Input          string                           `json:"input"`
+	IsDst          bool                             `json:"is_dst"`
+	// Converted (and shifted) time in the to zone, RFC 3339
+	Result string `json:"result"`
+	// Parsed input time in the from zone, RFC 3339
+	Source    string `json:"source"`
+	Timezone  string `json:"timezone"`
+	Unix      int    `json:"unix"`
+	UTCOffset string `json:"utc_offset"`
+	Weekday   string `json:"weekday"`
+}
+
+// TimeserverArgs holds the arguments of the timeserver tool.
+type TimeserverArgs struct {
+	// Extra formatted field: a preset (rfc3339, rfc1123, rfc822, kitchen, datetime, date, time, unixdate, ...) or a Go layout string such as '02 Jan 2006 15:04'
+	Format string `json:"format,omitempty"`
+	// IANA timezone, e.g. Europe/Kyiv (default: the session default from set_defaults, else server local time)
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// TimeserverResult is the structured result of the timeserver tool.
+type TimeserverResult struct {
+	// Zone abbreviation, e.g. EEST
+	Abbrev string `json:"abbrev"`
+	// The time rendered with the format argument
+	Formatted    string `json:"formatted,omitempty"`
+	IsDst        bool   `json:"is_dst"`
+	Iso8601Local string `json:"iso8601_local"`
+	Iso8601UTC   string `json:"iso8601_utc"`
+	Timezone     string `json:"timezone"`
+	Unix         int    `json:"unix"`
+	UnixMs       int    `json:"unix_ms"`
+	// Offset from UTC as +HH:MM
+	UTCOffset string `json:"utc_offset"`
+	Weekday   string `json:"weekday"`
+}
+
+// TracerouteArgs holds the arguments of the traceroute tool.
+type TracerouteArgs struct {
+	// Hostname or IPv4 address to trace
+	Host string `json:"host"`
+	// Highest TTL to try (default 30, max 64)
+	MaxHops int `json:"max_hops,omitempty"`
+	// Probes per hop (default 3, max 5)
+	Probes int `json:"probes,omitempty"`
+	// auto (default): ICMP echo when the server may open raw sockets, else UDP; icmp; or udp (unprivileged, Linux)
+	Protocol string `json:"protocol,omitempty"`
+	// How long to wait for each probe's answer in milliseconds (default 1000, max 5000)
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+}
+
+// TracerouteResultHop is a nested object in a tool schema.
+type TracerouteResultHop struct {
+	// Routers or the destination that answered, in order; empty when no probe was answered
+	Addresses []string `json:"addresses"`
+	// Probes without an answer
+	Lost int `json:"lost"`
+	// Last answer: time exceeded, echo reply, port unreachable, host unreachable, ...
+	Response string `json:"response,omitempty"`
+	// Round-trip times of the answered probes
+	RttsMs []float64 `json:"rtts_ms"`
+	Ttl    int       `json:"ttl"`
+}
+
+// TracerouteResult is the structured result of the traceroute tool.
+type TracerouteResult struct {
+	Address string `json:"address"`
+	// Why UDP was used when protocol was auto
+	Fallback string                `json:"fallback,omitempty"`
+	Hops     []TracerouteResultHop `json:"hops"`
+	Host     string                `json:"host"`
+	// icmp or udp
+	Protocol string `json:"protocol"`
+	Reached  bool   `json:"reached"`
+	// Why the trace ended before reaching the host or max_hops
+	Stopped string `json:"stopped,omitempty"`
+}
+
+// TransformArgs holds the arguments of the transform tool.
+type TransformArgs struct {
+	// Input text (use either input or url)
+	Input string `json:"input,omitempty"`
+	// md5, sha1, sha256, sha512, base64_encode, base64_decode, hex_encode, hex_decode, url_encode or url_decode
+	Operation string `json:"operation"`
+	// Fetch the input from this http(s) URL instead (max 1 MiB)
+	URL string `json:"url,omitempty"`
+}
+
+// TransformResult is the structured result of the transform tool.
+type TransformResult struct {
+	// utf-8, or hex when a decode produced binary data
+	Encoding   string `json:"encoding"`
+	InputBytes int    `json:"input_bytes"`
+	Operation  string `json:"operation"`
+	Output     string `json:"output"`
+	// input, or the URL the input was fetched from
+	Source string `json:"source"`
+}
+
+// URLStatusArgs holds the arguments of the url_status tool.
+type URLStatusArgs struct {
+	// URL to check (must be http or https)
+	URL string `json:"url"`
+}
+
+// URLStatusResult is the structured result of the url_status tool.
+type URLStatusResult struct {
+	// Declared Content-Length, or -1 when unknown
+	ContentLength int    `json:"content_length"`
+	ContentType   string `json:"content_type"`
+	FinalURL      string `json:"final_url"`
+	// Time until response headers were received, in milliseconds
+	LatencyMs int `json:"latency_ms"`
+	// HEAD, or GET when the server rejected HEAD
+	Method string `json:"method"`
+	// True for 2xx and 3xx status codes
+	OK         bool   `json:"ok"`
+	Status     string `json:"status"`
+	StatusCode int    `json:"status_code"`
+	URL        string `json:"url"`
+}
+
+// WriteFileArgs holds the arguments of the write_file tool.
+type WriteFileArgs struct {
+	// Append to the file instead of replacing it
+	Append *bool `json:"append,omitempty"`
+	// Text to write (max 1048576 bytes)
+	Content string `json:"content"`
+	// Create missing parent directories
+	CreateDirs *bool `json:"create_dirs,omitempty"`
+	// File path relative to the sandbox root
+	Path string `json:"path"`
+}
+
+// WriteFileResult is the structured result of the write_file tool.
+type WriteFileResult struct {
+	BytesWritten int    `json:"bytes_written"`
+	Path         string `json:"path"`
+	// File size after the write
+	Size int `json:"size"`
+}
+
+// XpathArgs holds the arguments of the xpath tool.
+type XpathArgs struct {
+	// Attribute names to return for matched elements (default: all)
+	Attributes []string `json:"attributes,omitempty"`
+	// XML or HTML document to query (use either content or url)
+	Content string `json:"content,omitempty"`
+	// XPath 1.0 expression, e.g. //a/@href or count(//item); use either expression or selector
+	Expression string `json:"expression,omitempty"`
+	// How to parse the document: html, xml or auto (default; from the Content-Type, or xml when the content starts with an XML declaration)
+	Format string `json:"format,omitempty"`
+	// Maximum matches to return (default 20, max 200)
+	Limit int `json:"limit,omitempty"`
+	// Also return the outer markup of matched elements
+	Markup *bool `json:"markup,omitempty"`
+	// CSS selector, e.g. article h2 > a (HTML only)
+	Selector string `json:"selector,omitempty"`
+	// Fetch the document from this http(s) URL instead (max 2 MiB)
+	URL string `json:"url,omitempty"`
+}
+
+// XpathResultMatche is a nested object in a tool schema.
+type XpathResultMatche struct {
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Markup     string            `json:"markup,omitempty"`
+	// Element name, @attribute for attribute nodes, #text or #comment
+	Name string `json:"name"`
+	// Text content with whitespace collapsed, or the attribute value
+	Text string `json:"text"`
+	// True when text or markup was cut
+	Truncated *bool `json:"truncated,omitempty"`
+}
+
+// XpathResult is the structured result of the xpath tool.
+type XpathResult struct {
+	// Number of matched nodes, before limit
+	Count int `json:"count"`
+	// html or xml
+	Format string `json:"format"`
+	// xpath or css
+	Kind    string              `json:"kind"`
+	Matches []XpathResultMatche `json:"matches"`
+	Query   string              `json:"query"`
+	// content, or the URL the document was fetched from
+	Source string `json:"source"`
+	// Result of an XPath expression that does not select nodes, e.g. count() or string()
+	Value any `json:"value,omitempty"`
+}
+
+// AsnLookup calls the asn_lookup tool: Map an IP address or prefix to its BGP prefix and origin ASes (number, holder name, country), optionally listing the prefixes each AS announces, from RIPEstat or Team Cymru; answers are cached
+func (c *Client) AsnLookup(ctx context.Context, args AsnLookupArgs) (AsnLookupResult, error) {
+	return mcpclient.CallToolTyped[AsnLookupResult](ctx, c.Client, "asn_lookup", args)
+}
+
+// CheckLinks calls the check_links tool: Check the links of a page (or a given list) with HEAD requests, a few at a time and spaced out per host, and report broken links with status codes and where redirected links end up
+func (c *Client) CheckLinks(ctx context.Context, args CheckLinksArgs) (CheckLinksResult, error) {
+	return mcpclient.CallToolTyped[CheckLinksResult](ctx, c.Client, "check_links", args)
+}
+
+// CrawlPreview calls the crawl_preview tool: Visit up to max_pages same-origin pages from a sitemap or seed URL, obeying robots.txt and a delay between requests, and list each page's status, title and meta description; full results are stored as an artifact resource
+func (c *Client) CrawlPreview(ctx context.Context, args CrawlPreviewArgs) (CrawlPreviewResult, error) {
+	return mcpclient.CallToolTyped[CrawlPreviewResult](ctx, c.Client, "crawl_preview", args)
+}
+
+// Delegate calls the delegate tool: Hand a prompt plus context to another agent: the calling client's model via sampling, or a configured HTTP or MCP agent; streamed chunks arrive as progress notifications
+func (c *Client) Delegate(ctx context.Context, args DelegateArgs) (DelegateResult, error) {
+	return mcpclient.CallToolTyped[DelegateResult](ctx, c.Client, "delegate", args)
+}
+
+// Download calls the download tool: Download a URL with GET to a temporary file on the server (up to 100 MiB unless configured otherwise) and return a link to a resource holding it, instead of the bytes; for files too large for fetch
+func (c *Client) Download(ctx context.Context, args DownloadArgs) (DownloadResult, error) {
+	return mcpclient.CallToolTyped[DownloadResult](ctx, c.Client, "download", args)
+}
+
+// Echotest calls the echotest tool: Echo back the provided message
+func (c *Client) Echotest(ctx context.Context, args EchotestArgs) (string, error) {
+	result, err := c.CallTool(ctx, "echotest", args)
+	if err != nil {
+		return "", err
+	}
+	return mcpclient.Text(result), nil
+}
+
+// Elicit calls the elicit tool: Demonstrate elicitation: ask the user, through the client, for the profile fields not passed as arguments, for a confirmation or for an API key, and report the answer; the client must support elicitation
+func (c *Client) Elicit(ctx context.Context, args ElicitArgs) (ElicitResult, error) {
+	return mcpclient.CallToolTyped[ElicitResult](ctx, c.Client, "elicit", args)
+}
+
+// Exec calls the exec tool: Run an allowlisted command without a shell; returns exit code and (truncated) stdout and stderr
+func (c *Client) Exec(ctx context.Context, args ExecArgs) (ExecResult, error) {
+	return mcpclient.CallToolTyped[ExecResult](ctx, c.Client, "exec", args)
+}
+
+// Fetch calls the fetch tool: Fetch content from a URL (HTTP/HTTPS). Optional method, headers and body for REST calls, and max_bytes to limit response size
+func (c *Client) Fetch(ctx context.Context, args FetchArgs) (FetchResult, error) {
+	return mcpclient.CallToolTyped[FetchResult](ctx, c.Client, "fetch", args)
+}
+
+// FetchBatch calls the fetch_batch tool: Fetch up to 20 URLs concurrently with GET, each like fetch, and return per-URL results in order; one URL failing does not fail the batch
+func (c *Client) FetchBatch(ctx context.Context, args FetchBatchArgs) (FetchBatchResult, error) {
+	return mcpclient.CallToolTyped[FetchBatchResult](ctx, c.Client, "fetch_batch", args)
+}
+
+// LatencyProbe calls the latency_probe tool: Send a number of timed requests to a URL and report DNS, connect, TLS, time-to-first-byte and total times per request, with min, mean and p50/p90/p95/p99 percentiles for each phase
+func (c *Client) LatencyProbe(ctx context.Context, args LatencyProbeArgs) (LatencyProbeResult, error) {
+	return mcpclient.CallToolTyped[LatencyProbeResult](ctx, c.Client, "latency_probe", args)
+}
+
+// ListDir calls the list_dir tool: List the entries of a directory under the server's sandbox directory
+func (c *Client) ListDir(ctx context.Context, args ListDirArgs) (ListDirResult, error) {
+	return mcpclient.CallToolTyped[ListDirResult](ctx, c.Client, "list_dir", args)
+}
+
+// ListRoots calls the list_roots tool: List the roots the client shares with the server and, with a sandbox, how each relates to it and which sandbox directories the filesystem tools may use in this session
+func (c *Client) ListRoots(ctx context.Context, args ListRootsArgs) (ListRootsResult, error) {
+	return mcpclient.CallToolTyped[ListRootsResult](ctx, c.Client, "list_roots", args)
+}
+
+// ListTimezones calls the list_timezones tool: Search IANA timezone names (e.g. 'Kyiv', 'new york', 'America/') to find valid values for the timezone arguments of timeserver, time_convert and set_defaults
+func (c *Client) ListTimezones(ctx context.Context, args ListTimezonesArgs) (ListTimezonesResult, error) {
+	return mcpclient.CallToolTyped[ListTimezonesResult](ctx, c.Client, "list_timezones", args)
+}
+
+// PathMTU calls the path_mtu tool: Find the largest packet that reaches a host without fragmentation, following the MTUs routers report and bisecting when probes go unanswered
+func (c *Client) PathMTU(ctx context.Context, args PathMTUArgs) (PathMTUResult, error) {
+	return mcpclient.CallToolTyped[PathMTUResult](ctx, c.Client, "path_mtu", args)
+}
+
+// Prefs calls the prefs tool: Show or reset the argument values this session's calls taught the server: the last timezone and max_bytes per tool, and the base URL that url arguments starting with / resolve against
+func (c *Client) Prefs(ctx context.Context, args PrefsArgs) (PrefsResult, error) {
+	return mcpclient.CallToolTyped[PrefsResult](ctx, c.Client, "prefs", args)
+}
+
+// Random calls the random tool: Generate UUIDs (v4/v7), random integers in a range, random bytes (hex/base64) or URL-safe tokens; optional seed for reproducible output
+func (c *Client) Random(ctx context.Context, args RandomArgs) (RandomResult, error) {
+	return mcpclient.CallToolTyped[RandomResult](ctx, c.Client, "random", args)
+}
+
+// ReadFile calls the read_file tool: Read a file under the server's sandbox directory; optional offset and max_bytes for large files
+func (c *Client) ReadFile(ctx context.Context, args ReadFileArgs) (ReadFileResult, error) {
+	return mcpclient.CallToolTyped[ReadFileResult](ctx, c.Client, "read_file", args)
+}
+
+// SetDefaults calls the set_defaults tool: Set this session's default timezone and locale, used by timeserver, time_convert and fetch when the argument is omitted; call with no arguments to show the current defaults
+func (c *Client) SetDefaults(ctx context.Context, args SetDefaultsArgs) (SetDefaultsResult, error) {
+	return mcpclient.CallToolTyped[SetDefaultsResult](ctx, c.Client, "set_defaults", args)
+}
+
+// StatusReport calls the status_report tool: Summarize the server's health: uptime, restarts, today's tool call and HTTP success rates, readiness checks, open sessions and open circuit breakers, with a healthy verdict and the problems found
+func (c *Client) StatusReport(ctx context.Context, args StatusReportArgs) (StatusReportResult, error) {
+	return mcpclient.CallToolTyped[StatusReportResult](ctx, c.Client, "status_report", args)
+}
+
+// SummarizeURL calls the summarize_url tool: Fetch a page and ask the calling client's model to summarize it via sampling (sampling/createMessage); the client must support sampling
+func (c *Client) SummarizeURL(ctx context.Context, args SummarizeURLArgs) (SummarizeURLResult, error) {
+	return mcpclient.CallToolTyped[SummarizeURLResult](ctx, c.Client, "summarize_url", args)
+}
+
+// TimeConvert calls the time_convert tool: Convert a timestamp between IANA timezones, add or subtract durations, compute the difference to another timestamp and list upcoming DST transitions
+func (c *Client) TimeConvert(ctx context.Context, args TimeConvertArgs) (TimeConvertResult, error) {
+	return mcpclient.CallToolTyped[TimeConvertResult](ctx, c.Client, "time_convert", args)
+}
+
+// Timeserver calls the timeserver tool: Return current time; optional IANA tz via timezone arg and a format preset or Go layout via format arg
+func (c *Client) Timeserver(ctx context.Context, args TimeserverArgs) (TimeserverResult, error) {
+	return mcpclient.CallToolTyped[TimeserverResult](ctx, c.Client, "timeserver", args)
+}
+
+// Traceroute calls the traceroute tool: Trace the route to a host with ICMP echo probes, or unprivileged UDP probes when the server may not open raw sockets; returns each hop's address, round-trip times and lost probes
+func (c *Client) Traceroute(ctx context.Context, args TracerouteArgs) (TracerouteResult, error) {
+	return mcpclient.CallToolTyped[TracerouteResult](ctx, c.Client, "traceroute", args)
+}
+
+// Transform calls the transform tool: Hash (md5, sha1, sha256, sha512) or encode/decode (base64, hex, URL) an input string or the body of a URL
+func (c *Client) Transform(ctx context.Context, args TransformArgs) (TransformResult, error) {
+	return mcpclient.CallToolTyped[TransformResult](ctx, c.Client, "transform", args)
+}
+
+// URLStatus calls the url_status tool: Check a URL with HEAD (falling back to GET without reading the body); returns status, content type, content length and latency
+func (c *Client) URLStatus(ctx context.Context, args URLStatusArgs) (URLStatusResult, error) {
+	return mcpclient.CallToolTyped[URLStatusResult](ctx, c.Client, "url_status", args)
+}
+
+// WriteFile calls the write_file tool: Write or append text to a file under the server's sandbox directory
+func (c *Client) WriteFile(ctx context.Context, args WriteFileArgs) (WriteFileResult, error) {
+	return mcpclient.CallToolTyped[WriteFileResult](ctx, c.Client, "write_file", args)
+}
+
+// Xpath calls the xpath tool: Query an XML or HTML document, given inline or fetched from a URL, with an XPath expression or CSS selector; returns the matched nodes' text and attributes instead of the whole page
+func (c *Client) Xpath(ctx context.Context, args XpathArgs) (XpathResult, error) {
+	return mcpclient.CallToolTyped[XpathResult](ctx, c.Client, "xpath", args)
+}
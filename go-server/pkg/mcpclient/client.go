@@ -0,0 +1,285 @@
+// Package mcpclient is a small convenience layer over the MCP Go SDK
+// client: connecting over Streamable HTTP, WebSocket or any SDK
+// transport, calling tools with optional retries and typed results,
+// listing everything across pages, and routing server notifications to
+// callbacks.
+//
+// It was extracted from cmd/testclient so that other Go programs can talk
+// to MCP servers the same way.
+package mcpclient
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"iter"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"mcp-demo-server/pkg/payloadcrypt"
+	"mcp-demo-server/pkg/signature"
+	"mcp-demo-server/pkg/wstransport"
+)
+
+// TimeoutMetaKey is the request _meta entry through which CallTool passes
+// the time left before ctx's deadline, in milliseconds, so that the server
+// can stop the call then instead of working on after the client gave up.
+const TimeoutMetaKey = "mcp-demo/timeout_ms"
+
+// ListKind names a server listing that can change at runtime.
+type ListKind string
+
+const (
+	Tools     ListKind = "tools"
+	Resources ListKind = "resources"
+	Prompts   ListKind = "prompts"
+)
+
+// Handlers receive server notifications. Nil handlers are ignored.
+// Handlers run on the session's read loop: they must not block on
+// requests to the same server, so start a goroutine for that. When
+// Progress is set, CallTool asks for progress notifications, with a token
+// of the tool name and a sequence number.
+type Handlers struct {
+	ListChanged     func(c *Client, kind ListKind)
+	Log             func(c *Client, params *mcp.LoggingMessageParams)
+	Progress        func(c *Client, params *mcp.ProgressNotificationParams)
+	ResourceUpdated func(c *Client, params *mcp.ResourceUpdatedNotificationParams)
+}
+
+// Retry configures CallTool retries after transport failures. Tool errors
+// (IsError results) and protocol errors are never retried.
+type Retry struct {
+	// Attempts is the total number of tries; values below 2 disable retry.
+	Attempts int
+	// Backoff is the delay before the first retry, doubling on each one.
+	Backoff time.Duration
+}
+
+// Options configure Connect.
+type Options struct {
+	// Endpoint is the Streamable HTTP URL, e.g. http://localhost:8080/mcp,
+	// or a WebSocket one, e.g. ws://localhost:8080/ws. It is ignored when
+	// Transport is set.
+	Endpoint string
+	// HTTPClient is used for the Streamable HTTP transport and the
+	// WebSocket handshake; nil means http.DefaultClient.
+	HTTPClient *http.Client
+	// Transport overrides the default Streamable HTTP transport.
+	Transport mcp.Transport
+	// MaxRetries is the transport's reconnect budget (see
+	// mcp.StreamableClientTransport); zero uses the SDK default.
+	MaxRetries int
+
+	// Name and Version identify the client to the server.
+	Name    string
+	Version string
+
+	// KeepAlive, if non-zero, makes the SDK ping the server at this
+	// interval and close the session when a ping fails.
+	KeepAlive time.Duration
+	Retry     Retry
+	Handlers  Handlers
+
+	// VerifyKey, if set, makes CallTool reject results whose _meta
+	// signature (see package signature) is missing or does not verify.
+	VerifyKey ed25519.PublicKey
+	// EncryptionKey, if set, encrypts the session end to end with a server
+	// holding the matching private key (see package payloadcrypt), and
+	// fails Connect when the server does not confirm it.
+	EncryptionKey *ecdh.PublicKey
+
+	// ClientOptions is passed to mcp.NewClient after the handler fields
+	// above have been filled in; use it for sampling or elicitation.
+	ClientOptions *mcp.ClientOptions
+}
+
+// Client is a connected MCP session.
+type Client struct {
+	session *mcp.ClientSession
+	opts    Options
+	calls   atomic.Int64 // numbers progress tokens
+}
+
+// Connect creates an MCP client and performs the initialize handshake.
+func Connect(ctx context.Context, opts Options) (*Client, error) {
+	c := &Client{opts: opts}
+
+	var clientOpts mcp.ClientOptions
+	if opts.ClientOptions != nil {
+		clientOpts = *opts.ClientOptions
+	}
+	clientOpts.KeepAlive = opts.KeepAlive
+	h := opts.Handlers
+	if h.ListChanged != nil {
+		clientOpts.ToolListChangedHandler = func(context.Context, *mcp.ToolListChangedRequest) { h.ListChanged(c, Tools) }
+		clientOpts.ResourceListChangedHandler = func(context.Context, *mcp.ResourceListChangedRequest) { h.ListChanged(c, Resources) }
+		clientOpts.PromptListChangedHandler = func(context.Context, *mcp.PromptListChangedRequest) { h.ListChanged(c, Prompts) }
+	}
+	if h.Log != nil {
+		clientOpts.LoggingMessageHandler = func(_ context.Context, req *mcp.LoggingMessageRequest) { h.Log(c, req.Params) }
+	}
+	if h.Progress != nil {
+		clientOpts.ProgressNotificationHandler = func(_ context.Context, req *mcp.ProgressNotificationClientRequest) { h.Progress(c, req.Params) }
+	}
+	if h.ResourceUpdated != nil {
+		clientOpts.ResourceUpdatedHandler = func(_ context.Context, req *mcp.ResourceUpdatedNotificationRequest) { h.ResourceUpdated(c, req.Params) }
+	}
+
+	name, ver := opts.Name, opts.Version
+	if name == "" {
+		name = "mcpclient"
+	}
+	if ver == "" {
+		ver = "v0.0.0"
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: name, Version: ver}, &clientOpts)
+	if opts.EncryptionKey != nil {
+		mw, err := payloadcrypt.ClientMiddleware(opts.EncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("payload encryption: %w", err)
+		}
+		client.AddSendingMiddleware(mw)
+	}
+
+	transport := opts.Transport
+	if transport == nil {
+		if opts.Endpoint == "" {
+			return nil, errors.New("mcpclient: Endpoint or Transport is required")
+		}
+		if u, err := url.Parse(opts.Endpoint); err == nil && (u.Scheme == "ws" || u.Scheme == "wss") {
+			transport = &wstransport.ClientTransport{URL: opts.Endpoint, HTTPClient: opts.HTTPClient}
+		} else {
+			transport = &mcp.StreamableClientTransport{
+				Endpoint:   opts.Endpoint,
+				HTTPClient: opts.HTTPClient,
+				MaxRetries: opts.MaxRetries,
+			}
+		}
+	}
+
+	session, err := client.Connect(ctx, transport, nil)
+	if err != nil {
+		return nil, fmt.Errorf("connection failed: %w", err)
+	}
+	c.session = session
+	return c, nil
+}
+
+// Session exposes the underlying SDK session for anything this package
+// does not wrap.
+func (c *Client) Session() *mcp.ClientSession { return c.session }
+
+// Close ends the session.
+func (c *Client) Close() error { return c.session.Close() }
+
+// Ping checks that the server is responsive.
+func (c *Client) Ping(ctx context.Context) error { return c.session.Ping(ctx, nil) }
+
+// ToolError is returned by CallTool when the tool reported IsError.
+type ToolError struct {
+	Tool   string
+	Result *mcp.CallToolResult
+}
+
+func (e *ToolError) Error() string {
+	if msg := Text(e.Result); msg != "" {
+		return fmt.Sprintf("tool %s returned error: %s", e.Tool, msg)
+	}
+	return fmt.Sprintf("tool %s returned error", e.Tool)
+}
+
+// CallTool calls a tool, retrying transport failures per Options.Retry.
+// A result with IsError set is returned together with a *ToolError. The
+// deadline of ctx, if any, is sent along (see TimeoutMetaKey).
+func (c *Client) CallTool(ctx context.Context, name string, args any) (*mcp.CallToolResult, error) {
+	attempts := max(c.opts.Retry.Attempts, 1)
+	backoff := c.opts.Retry.Backoff
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var result *mcp.CallToolResult
+		params := &mcp.CallToolParams{Name: name, Arguments: args}
+		if deadline, ok := ctx.Deadline(); ok {
+			params.Meta = mcp.Meta{TimeoutMetaKey: max(time.Until(deadline).Milliseconds(), 1)}
+		}
+		if c.opts.Handlers.Progress != nil {
+			if params.Meta == nil {
+				// SetProgressToken drops the token into a map of its own
+				// when there is none.
+				params.Meta = mcp.Meta{}
+			}
+			params.SetProgressToken(fmt.Sprintf("%s-%d", name, c.calls.Add(1)))
+		}
+		result, err = c.session.CallTool(ctx, params)
+		if err == nil {
+			if c.opts.VerifyKey != nil {
+				if err := signature.Verify(c.opts.VerifyKey, result); err != nil {
+					return nil, fmt.Errorf("signature verification failed: %w", err)
+				}
+			}
+			if result.IsError {
+				return result, &ToolError{Tool: name, Result: result}
+			}
+			return result, nil
+		}
+		if attempt >= attempts || !isTransient(err) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return nil, fmt.Errorf("tool call failed: %w", err)
+}
+
+// isTransient reports whether err looks like a network or connection
+// failure rather than an error returned by the server.
+func isTransient(err error) bool {
+	var netErr net.Error
+	var urlErr *url.Error
+	return errors.Is(err, mcp.ErrConnectionClosed) || errors.As(err, &netErr) || errors.As(err, &urlErr)
+}
+
+// ListAll drains a paginated SDK iterator such as Session().Tools(ctx, nil).
+func ListAll[T any](seq iter.Seq2[*T, error]) ([]*T, error) {
+	items := []*T{}
+	for item, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// ListTools returns every tool, following pagination cursors.
+func (c *Client) ListTools(ctx context.Context) ([]*mcp.Tool, error) {
+	return ListAll(c.session.Tools(ctx, nil))
+}
+
+// ListResources returns every resource, following pagination cursors.
+func (c *Client) ListResources(ctx context.Context) ([]*mcp.Resource, error) {
+	return ListAll(c.session.Resources(ctx, nil))
+}
+
+// ListPrompts returns every prompt, following pagination cursors.
+func (c *Client) ListPrompts(ctx context.Context) ([]*mcp.Prompt, error) {
+	return ListAll(c.session.Prompts(ctx, nil))
+}
+
+// ListResourceTemplates returns every resource template, following
+// pagination cursors.
+func (c *Client) ListResourceTemplates(ctx context.Context) ([]*mcp.ResourceTemplate, error) {
+	return ListAll(c.session.ResourceTemplates(ctx, nil))
+}
@@ -0,0 +1,70 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Text renders the content of a tool result as plain text. Text blocks
+// are concatenated; other content types are summarized in brackets.
+func Text(result *mcp.CallToolResult) string {
+	if result == nil {
+		return ""
+	}
+	return ContentText(result.Content...)
+}
+
+// ContentText renders content blocks, such as those of a tool result or a
+// prompt message, the way Text does.
+func ContentText(contents ...mcp.Content) string {
+	var b strings.Builder
+	for _, content := range contents {
+		switch c := content.(type) {
+		case *mcp.TextContent:
+			b.WriteString(c.Text)
+		case *mcp.ImageContent:
+			fmt.Fprintf(&b, "[image %s, %d bytes]", c.MIMEType, len(c.Data))
+		case *mcp.AudioContent:
+			fmt.Fprintf(&b, "[audio %s, %d bytes]", c.MIMEType, len(c.Data))
+		case *mcp.ResourceLink:
+			fmt.Fprintf(&b, "[resource link %s]", c.URI)
+		case *mcp.EmbeddedResource:
+			if c.Resource != nil {
+				if c.Resource.Text != "" {
+					b.WriteString(c.Resource.Text)
+				} else {
+					fmt.Fprintf(&b, "[resource %s, %d bytes]", c.Resource.URI, len(c.Resource.Blob))
+				}
+			}
+		}
+	}
+	return b.String()
+}
+
+// CallToolTyped calls a tool and decodes its structured content into T.
+// Servers that only return text are supported when the text is JSON.
+func CallToolTyped[T any](ctx context.Context, c *Client, name string, args any) (T, error) {
+	var out T
+	result, err := c.CallTool(ctx, name, args)
+	if err != nil {
+		return out, err
+	}
+
+	var data []byte
+	if result.StructuredContent != nil {
+		data, err = json.Marshal(result.StructuredContent)
+		if err != nil {
+			return out, err
+		}
+	} else {
+		data = []byte(Text(result))
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return out, fmt.Errorf("decoding %s result: %w", name, err)
+	}
+	return out, nil
+}
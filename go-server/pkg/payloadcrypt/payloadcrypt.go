@@ -0,0 +1,462 @@
+// Package payloadcrypt encrypts MCP payloads end to end, for deployments
+// where TLS ends at a proxy that should not see tool arguments and
+// results.
+//
+// The server has a static X25519 key that clients know in advance. In
+// initialize, the client sends a fresh X25519 public key in the
+// experimental capability CapabilityKey, and both sides derive an
+// AES-256-GCM key from the shared secret with HKDF-SHA256. From then on,
+// the params of the requests in Methods, and their results, travel as
+// one AES-GCM envelope under MetaKey in _meta; everything else in them is
+// left empty. Method names, IDs, errors, pings and notifications stay in
+// the clear.
+//
+// Each request envelope carries a sequence number, bound into its
+// additional data, that the receiver accepts only once; the result
+// envelope is bound to the number of its request. A proxy can therefore
+// neither replay a request nor answer it with another request's result.
+package payloadcrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// Algorithm names the key agreement, key derivation and cipher.
+	Algorithm = "X25519-HKDF-SHA256-AES256GCM"
+	// CapabilityKey is the experimental capability in which the client
+	// sends its public key and the server confirms with its own.
+	CapabilityKey = "mcp-demo/encryption"
+	// MetaKey is the _meta entry holding an Envelope.
+	MetaKey = "mcp-demo/encrypted"
+	// WellKnownPath is where HTTP servers publish their PublicKey.
+	WellKnownPath = "/.well-known/mcp-encryption-key"
+
+	hkdfInfo = "mcp-demo payload encryption v1"
+
+	// replayWindow is how far behind the highest sequence number seen a
+	// request may arrive, for requests overtaking each other in flight.
+	replayWindow = 64
+)
+
+// Methods are the requests whose params and results are encrypted.
+var Methods = map[string]bool{
+	"tools/call":               true,
+	"tools/list":               true,
+	"resources/read":           true,
+	"resources/list":           true,
+	"resources/templates/list": true,
+	"prompts/get":              true,
+	"prompts/list":             true,
+	"completion/complete":      true,
+}
+
+// ErrNotEncrypted is returned by OpenParams and OpenResult for a payload
+// without an Envelope.
+var ErrNotEncrypted = errors.New("payload is not encrypted")
+
+// PublicKey is the document published at WellKnownPath, and the value of
+// CapabilityKey in initialize.
+type PublicKey struct {
+	Alg   string `json:"alg"`
+	KeyID string `json:"key_id,omitempty"`
+	Key   string `json:"public_key" jsonschema:"Base64 raw 32-byte X25519 public key"`
+}
+
+// KeyID derives a short identifier from a public key: the first 8 bytes
+// of its SHA-256, hex encoded.
+func KeyID(pub *ecdh.PublicKey) string {
+	sum := sha256.Sum256(pub.Bytes())
+	return hex.EncodeToString(sum[:8])
+}
+
+// Describe returns the PublicKey document for pub.
+func Describe(pub *ecdh.PublicKey) PublicKey {
+	return PublicKey{Alg: Algorithm, KeyID: KeyID(pub), Key: base64.StdEncoding.EncodeToString(pub.Bytes())}
+}
+
+// Decode parses a PublicKey document, checking its algorithm and key ID.
+func (k PublicKey) Decode() (*ecdh.PublicKey, error) {
+	if k.Alg != Algorithm {
+		return nil, fmt.Errorf("unsupported encryption algorithm %q", k.Alg)
+	}
+	pub, err := ParsePublicKey(k.Key)
+	if err != nil {
+		return nil, err
+	}
+	if k.KeyID != "" && k.KeyID != KeyID(pub) {
+		return nil, fmt.Errorf("key_id %s does not match the key", k.KeyID)
+	}
+	return pub, nil
+}
+
+// ParsePublicKey decodes a base64 raw X25519 public key.
+func ParsePublicKey(s string) (*ecdh.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("malformed X25519 public key")
+	}
+	pub, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, errors.New("malformed X25519 public key")
+	}
+	return pub, nil
+}
+
+// Fetch downloads the PublicKey published next to an MCP endpoint, at
+// WellKnownPath on the same origin. Only a key obtained some other way
+// protects against the proxy the encryption is meant for, which could
+// answer with its own.
+func Fetch(ctx context.Context, client *http.Client, endpoint string) (*ecdh.PublicKey, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path, u.RawQuery, u.Fragment = WellKnownPath, "", ""
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	var doc PublicKey
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("GET %s: %w", u, err)
+	}
+	return doc.Decode()
+}
+
+// Envelope is the value stored under MetaKey.
+type Envelope struct {
+	Seq        uint64 `json:"seq,omitempty"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// Session holds the key of one MCP session, the sequence numbers its
+// client has used and those its server has accepted.
+type Session struct {
+	aead cipher.AEAD
+
+	mu      sync.Mutex
+	sent    uint64 // last sequence number sealed
+	highest uint64 // highest sequence number opened
+	seen    uint64 // bit i: highest-i has been opened
+}
+
+// NewSession derives the session key from priv and the peer's public key.
+// clientPub and serverPub are the two public keys, which both sides bind
+// into the key.
+func NewSession(priv *ecdh.PrivateKey, peer *ecdh.PublicKey, clientPub, serverPub *ecdh.PublicKey) (*Session, error) {
+	secret, err := priv.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+	// HKDF-SHA256 (RFC 5869) for a single 32-byte block.
+	extract := hmac.New(sha256.New, append(clientPub.Bytes(), serverPub.Bytes()...))
+	extract.Write(secret)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write([]byte(hkdfInfo))
+	expand.Write([]byte{1})
+	block, err := aes.NewCipher(expand.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{aead: aead}, nil
+}
+
+// The additional data binds each envelope to its method, direction and
+// request sequence number, so that a proxy cannot pass one payload off as
+// another.
+func requestAD(method string, seq uint64) []byte {
+	return []byte("request " + method + " " + strconv.FormatUint(seq, 10))
+}
+
+func resultAD(method string, seq uint64) []byte {
+	return []byte("result " + method + " " + strconv.FormatUint(seq, 10))
+}
+
+// payload is what Params and Result have in common.
+type payload interface {
+	GetMeta() map[string]any
+	SetMeta(map[string]any)
+}
+
+func (s *Session) seal(seq uint64, ad []byte, v payload) error {
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	env := Envelope{
+		Seq:        seq,
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(s.aead.Seal(nil, nonce, plain, ad)),
+	}
+	reflect.ValueOf(v).Elem().SetZero()
+	v.SetMeta(map[string]any{MetaKey: env})
+	return nil
+}
+
+// envelope returns the Envelope in v.
+func envelope(v payload) (Envelope, error) {
+	var env Envelope
+	if v == nil || reflect.ValueOf(v).IsNil() {
+		return env, ErrNotEncrypted
+	}
+	raw, ok := v.GetMeta()[MetaKey]
+	if !ok {
+		return env, ErrNotEncrypted
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return env, err
+	}
+	if err := json.Unmarshal(data, &env); err != nil {
+		return env, fmt.Errorf("malformed envelope: %w", err)
+	}
+	return env, nil
+}
+
+// decrypt returns the plaintext of env.
+func (s *Session) decrypt(env Envelope, ad []byte) ([]byte, error) {
+	nonce, err1 := base64.StdEncoding.DecodeString(env.Nonce)
+	ciphertext, err2 := base64.StdEncoding.DecodeString(env.Ciphertext)
+	if err1 != nil || err2 != nil || len(nonce) != s.aead.NonceSize() {
+		return nil, errors.New("malformed envelope")
+	}
+	plain, err := s.aead.Open(nil, nonce, ciphertext, ad)
+	if err != nil {
+		return nil, errors.New("envelope does not decrypt with the session key")
+	}
+	return plain, nil
+}
+
+// restore replaces v with plain.
+func restore(plain []byte, v payload) error {
+	reflect.ValueOf(v).Elem().SetZero()
+	return json.Unmarshal(plain, v)
+}
+
+// accept records seq as opened, failing if it was before or is too old
+// to tell.
+func (s *Session) accept(seq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case seq > s.highest:
+		if shift := seq - s.highest; shift < replayWindow {
+			s.seen = s.seen<<shift | 1
+		} else {
+			s.seen = 1
+		}
+		s.highest = seq
+	case s.highest-seq >= replayWindow:
+		return fmt.Errorf("sequence number %d is too old", seq)
+	case s.seen&(1<<(s.highest-seq)) != 0:
+		return fmt.Errorf("sequence number %d was already used", seq)
+	default:
+		s.seen |= 1 << (s.highest - seq)
+	}
+	return nil
+}
+
+// SealParams replaces params with an envelope of their encryption under
+// the session's next sequence number, which it returns for OpenResult.
+func (s *Session) SealParams(method string, params mcp.Params) (uint64, error) {
+	s.mu.Lock()
+	s.sent++
+	seq := s.sent
+	s.mu.Unlock()
+	return seq, s.seal(seq, requestAD(method, seq), params)
+}
+
+// OpenParams restores params from their envelope and returns its sequence
+// number, for SealResult. It fails for a number already opened.
+func (s *Session) OpenParams(method string, params mcp.Params) (uint64, error) {
+	env, err := envelope(params)
+	if err != nil {
+		return 0, err
+	}
+	if env.Seq == 0 {
+		return 0, errors.New("envelope has no sequence number")
+	}
+	plain, err := s.decrypt(env, requestAD(method, env.Seq))
+	if err != nil {
+		return 0, err
+	}
+	if err := s.accept(env.Seq); err != nil {
+		return 0, err
+	}
+	return env.Seq, restore(plain, params)
+}
+
+// SealResult replaces result with an envelope of its encryption, bound to
+// the sequence number of its request.
+func (s *Session) SealResult(method string, seq uint64, result mcp.Result) error {
+	return s.seal(0, resultAD(method, seq), result)
+}
+
+// OpenResult restores result from its envelope, which must be bound to
+// seq, the sequence number its request was sealed with.
+func (s *Session) OpenResult(method string, seq uint64, result mcp.Result) error {
+	env, err := envelope(result)
+	if err != nil {
+		return err
+	}
+	plain, err := s.decrypt(env, resultAD(method, seq))
+	if err != nil {
+		return err
+	}
+	return restore(plain, result)
+}
+
+// ClientMiddleware is sending middleware for an mcp.Client that encrypts
+// the session with a server whose public key is serverKey. It adds the
+// client's key to initialize and fails it unless the server confirms
+// with serverKey.
+func ClientMiddleware(serverKey *ecdh.PublicKey) (mcp.Middleware, error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	session, err := NewSession(priv, serverKey, priv.PublicKey(), serverKey)
+	if err != nil {
+		return nil, err
+	}
+	return func(next mcp.MethodHandler) mcp.MethodHandler {
+		return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+			switch {
+			case method == "initialize":
+				params, ok := req.GetParams().(*mcp.InitializeParams)
+				if !ok {
+					return next(ctx, method, req)
+				}
+				if params.Capabilities == nil {
+					params.Capabilities = &mcp.ClientCapabilities{}
+				}
+				if params.Capabilities.Experimental == nil {
+					params.Capabilities.Experimental = make(map[string]any)
+				}
+				params.Capabilities.Experimental[CapabilityKey] = Describe(priv.PublicKey())
+				result, err := next(ctx, method, req)
+				if err != nil {
+					return result, err
+				}
+				if err := confirmServerKey(result, serverKey); err != nil {
+					return nil, err
+				}
+				return result, nil
+			case Methods[method]:
+				params := req.GetParams()
+				if params == nil || reflect.ValueOf(params).IsNil() {
+					// Listings may be sent without params; they get an
+					// envelope all the same, so the server can require one.
+					r, ok := req.(*mcp.ClientRequest[mcp.Params])
+					if params = emptyParams(method); !ok || params == nil {
+						return nil, fmt.Errorf("%s: no params to encrypt", method)
+					}
+					r.Params = params
+				}
+				seq, err := session.SealParams(method, params)
+				if err != nil {
+					return nil, fmt.Errorf("encrypting %s: %w", method, err)
+				}
+				result, err := next(ctx, method, req)
+				if err != nil {
+					return result, err
+				}
+				if err := session.OpenResult(method, seq, result); err != nil {
+					return nil, fmt.Errorf("decrypting %s: %w", method, err)
+				}
+				return result, nil
+			}
+			return next(ctx, method, req)
+		}
+	}, nil
+}
+
+// emptyParams returns the params of a listing sent without any.
+func emptyParams(method string) mcp.Params {
+	switch method {
+	case "tools/list":
+		return &mcp.ListToolsParams{}
+	case "resources/list":
+		return &mcp.ListResourcesParams{}
+	case "resources/templates/list":
+		return &mcp.ListResourceTemplatesParams{}
+	case "prompts/list":
+		return &mcp.ListPromptsParams{}
+	}
+	return nil
+}
+
+// confirmServerKey checks that the server agreed to encrypt with the key
+// the client expects.
+func confirmServerKey(result mcp.Result, want *ecdh.PublicKey) error {
+	res, ok := result.(*mcp.InitializeResult)
+	if !ok || res.Capabilities == nil {
+		return errors.New("server does not support payload encryption")
+	}
+	raw, ok := res.Capabilities.Experimental[CapabilityKey]
+	if !ok {
+		return errors.New("server does not support payload encryption")
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	var doc PublicKey
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("server encryption key: %w", err)
+	}
+	got, err := doc.Decode()
+	if err != nil {
+		return fmt.Errorf("server encryption key: %w", err)
+	}
+	if !got.Equal(want) {
+		return fmt.Errorf("server encrypts with key %s, expected %s", KeyID(got), KeyID(want))
+	}
+	return nil
+}
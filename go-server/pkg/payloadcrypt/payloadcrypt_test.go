@@ -0,0 +1,205 @@
+package payloadcrypt
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// pair returns the client's and the server's Session for one key exchange.
+func pair(t *testing.T) (client, server *Session) {
+	t.Helper()
+	clientPriv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverPriv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientPub, serverPub := clientPriv.PublicKey(), serverPriv.PublicKey()
+	client, err = NewSession(clientPriv, serverPub, clientPub, serverPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err = NewSession(serverPriv, clientPub, clientPub, serverPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+func callParams() *mcp.CallToolParams {
+	return &mcp.CallToolParams{Name: "echo", Arguments: map[string]any{"text": "hello"}}
+}
+
+func TestRoundTrip(t *testing.T) {
+	client, server := pair(t)
+	params := callParams()
+	seq, err := client.SealParams("tools/call", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Name != "" || params.Arguments != nil {
+		t.Fatalf("sealed params still carry plaintext: %+v", params)
+	}
+	got, err := server.OpenParams("tools/call", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != seq {
+		t.Errorf("OpenParams returned sequence number %d, want %d", got, seq)
+	}
+	if params.Name != "echo" || params.Arguments.(map[string]any)["text"] != "hello" {
+		t.Errorf("opened params = %+v", params)
+	}
+
+	result := &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "hello"}}}
+	if err := server.SealResult("tools/call", got, result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Content) != 0 {
+		t.Fatalf("sealed result still carries plaintext: %+v", result)
+	}
+	if err := client.OpenResult("tools/call", seq, result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Content) != 1 || result.Content[0].(*mcp.TextContent).Text != "hello" {
+		t.Errorf("opened result = %+v", result)
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	client, _ := pair(t)
+	_, other := pair(t)
+	params := callParams()
+	if _, err := client.SealParams("tools/call", params); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.OpenParams("tools/call", params); err == nil {
+		t.Error("params opened with another session's key")
+	}
+}
+
+func TestModifiedCiphertext(t *testing.T) {
+	client, server := pair(t)
+	params := callParams()
+	if _, err := client.SealParams("tools/call", params); err != nil {
+		t.Fatal(err)
+	}
+	env := params.Meta[MetaKey].(Envelope)
+	ciphertext, _ := base64.StdEncoding.DecodeString(env.Ciphertext)
+	ciphertext[0] ^= 1
+	env.Ciphertext = base64.StdEncoding.EncodeToString(ciphertext)
+	params.Meta[MetaKey] = env
+	if _, err := server.OpenParams("tools/call", params); err == nil {
+		t.Error("modified ciphertext opened")
+	}
+}
+
+func TestADMismatch(t *testing.T) {
+	client, server := pair(t)
+
+	// A request envelope passed off as a result, and the reverse.
+	params := callParams()
+	seq, err := client.SealParams("tools/call", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &mcp.CallToolResult{}
+	result.SetMeta(params.GetMeta())
+	if err := client.OpenResult("tools/call", seq, result); err == nil {
+		t.Error("request envelope opened as a result")
+	}
+	if _, err := server.OpenParams("tools/call", params); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.SealResult("tools/call", seq, result); err != nil {
+		t.Fatal(err)
+	}
+	params = &mcp.CallToolParams{}
+	params.SetMeta(result.GetMeta())
+	if _, err := server.OpenParams("tools/call", params); err == nil {
+		t.Error("result envelope opened as a request")
+	}
+
+	// Another method.
+	params = callParams()
+	if _, err := client.SealParams("tools/call", params); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.OpenParams("prompts/get", params); err == nil {
+		t.Error("tools/call envelope opened as prompts/get")
+	}
+
+	// A result bound to another request.
+	result = &mcp.CallToolResult{}
+	if err := server.SealResult("tools/call", seq, result); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.OpenResult("tools/call", seq+1, result); err == nil {
+		t.Error("result opened for another request")
+	}
+
+	// A sequence number rewritten in transit.
+	params = callParams()
+	if _, err := client.SealParams("tools/call", params); err != nil {
+		t.Fatal(err)
+	}
+	env := params.Meta[MetaKey].(Envelope)
+	env.Seq += 100
+	params.Meta[MetaKey] = env
+	if _, err := server.OpenParams("tools/call", params); err == nil {
+		t.Error("envelope opened with a rewritten sequence number")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	client, server := pair(t)
+	var sealed []map[string]any
+	for range 3 {
+		params := callParams()
+		if _, err := client.SealParams("tools/call", params); err != nil {
+			t.Fatal(err)
+		}
+		sealed = append(sealed, params.GetMeta())
+	}
+	open := func(meta map[string]any) error {
+		params := &mcp.CallToolParams{}
+		params.SetMeta(meta)
+		_, err := server.OpenParams("tools/call", params)
+		return err
+	}
+	// Out of order is fine, once each.
+	for _, i := range []int{1, 0, 2} {
+		if err := open(sealed[i]); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	for i, meta := range sealed {
+		if err := open(meta); err == nil {
+			t.Errorf("request %d opened twice", i)
+		}
+	}
+
+	// Beyond the window, a request can no longer be told from a replay.
+	late := callParams()
+	if _, err := client.SealParams("tools/call", late); err != nil {
+		t.Fatal(err)
+	}
+	for range replayWindow {
+		params := callParams()
+		if _, err := client.SealParams("tools/call", params); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := server.OpenParams("tools/call", params); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := server.OpenParams("tools/call", late); err == nil {
+		t.Error("request older than the replay window opened")
+	}
+}
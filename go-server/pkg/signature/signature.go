@@ -0,0 +1,214 @@
+// Package signature signs and verifies MCP tool results with Ed25519.
+//
+// The server signs the canonical JSON of a result's content,
+// structuredContent and isError fields and the signing time, and stores
+// the signature under MetaKey in the result's _meta. Canonical JSON has object keys sorted
+// and no insignificant whitespace, so it can be recomputed from the
+// decoded result on the client side.
+package signature
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// MetaKey is the _meta entry holding the Signature.
+	MetaKey = "mcp-demo/signature"
+	// Algorithm is the only supported signature algorithm.
+	Algorithm = "Ed25519"
+	// WellKnownPath is where HTTP servers publish their PublicKey.
+	WellKnownPath = "/.well-known/mcp-signing-key"
+	// ResourceURI is the MCP resource holding the PublicKey.
+	ResourceURI = "signing://public-key"
+)
+
+// ErrUnsigned is returned by Verify for results without a signature.
+var ErrUnsigned = errors.New("result is not signed")
+
+// MaxAge is how far from the verifier's clock a signature's time may be,
+// so that an old result cannot be passed off as a new one. 0 accepts any
+// time.
+var MaxAge = 5 * time.Minute
+
+// Signature is the value stored under MetaKey.
+type Signature struct {
+	Alg      string `json:"alg"`
+	KeyID    string `json:"key_id"`
+	SignedAt int64  `json:"signed_at" jsonschema:"Unix time of signing, covered by the signature"`
+	Value    string `json:"sig" jsonschema:"Base64 signature over the canonical result"`
+}
+
+// PublicKey is the document published at WellKnownPath and ResourceURI.
+type PublicKey struct {
+	Alg   string `json:"alg"`
+	KeyID string `json:"key_id"`
+	Key   string `json:"public_key" jsonschema:"Base64 raw 32-byte Ed25519 public key"`
+}
+
+// KeyID derives a short identifier from a public key: the first 8 bytes
+// of its SHA-256, hex encoded.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Describe returns the PublicKey document for pub.
+func Describe(pub ed25519.PublicKey) PublicKey {
+	return PublicKey{Alg: Algorithm, KeyID: KeyID(pub), Key: base64.StdEncoding.EncodeToString(pub)}
+}
+
+// Decode parses a PublicKey document, checking its algorithm and key ID.
+func (k PublicKey) Decode() (ed25519.PublicKey, error) {
+	if k.Alg != Algorithm {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", k.Alg)
+	}
+	raw, err := base64.StdEncoding.DecodeString(k.Key)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, errors.New("malformed Ed25519 public key")
+	}
+	pub := ed25519.PublicKey(raw)
+	if k.KeyID != "" && k.KeyID != KeyID(pub) {
+		return nil, fmt.Errorf("key_id %s does not match the key", k.KeyID)
+	}
+	return pub, nil
+}
+
+// Canonical returns the bytes that are signed for result at signedAt
+// (Unix time).
+func Canonical(result *mcp.CallToolResult, signedAt int64) ([]byte, error) {
+	data, err := json.Marshal(struct {
+		Content           []mcp.Content `json:"content"`
+		StructuredContent any           `json:"structuredContent,omitempty"`
+		IsError           bool          `json:"isError,omitempty"`
+		SignedAt          int64         `json:"signed_at"`
+	}{result.Content, result.StructuredContent, result.IsError, signedAt})
+	if err != nil {
+		return nil, err
+	}
+	// Round-trip through generic values so struct field order (server
+	// side) and map order (client side) both become sorted keys.
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// Sign adds a signature to result's _meta.
+func Sign(priv ed25519.PrivateKey, result *mcp.CallToolResult) error {
+	return signAt(priv, result, time.Now())
+}
+
+func signAt(priv ed25519.PrivateKey, result *mcp.CallToolResult, now time.Time) error {
+	data, err := Canonical(result, now.Unix())
+	if err != nil {
+		return err
+	}
+	pub := priv.Public().(ed25519.PublicKey)
+	if result.Meta == nil {
+		result.Meta = mcp.Meta{}
+	}
+	result.Meta[MetaKey] = Signature{
+		Alg:      Algorithm,
+		KeyID:    KeyID(pub),
+		SignedAt: now.Unix(),
+		Value:    base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data)),
+	}
+	return nil
+}
+
+// Verify checks the signature in result's _meta against pub, and that it
+// was made within MaxAge of now.
+func Verify(pub ed25519.PublicKey, result *mcp.CallToolResult) error {
+	raw, ok := result.Meta[MetaKey]
+	if !ok {
+		return ErrUnsigned
+	}
+	var sig Signature
+	if s, ok := raw.(Signature); ok {
+		sig = s
+	} else {
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &sig); err != nil {
+			return fmt.Errorf("malformed signature: %w", err)
+		}
+	}
+	if sig.Alg != Algorithm {
+		return fmt.Errorf("unsupported signing algorithm %q", sig.Alg)
+	}
+	if want := KeyID(pub); sig.KeyID != want {
+		return fmt.Errorf("signed by key %s, expected %s", sig.KeyID, want)
+	}
+	value, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil {
+		return fmt.Errorf("malformed signature: %w", err)
+	}
+	data, err := Canonical(result, sig.SignedAt)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, data, value) {
+		return errors.New("signature does not match the result")
+	}
+	age := time.Since(time.Unix(sig.SignedAt, 0))
+	if MaxAge > 0 && (age > MaxAge || age < -MaxAge) {
+		return fmt.Errorf("signed at %s, more than %s from now", time.Unix(sig.SignedAt, 0).UTC().Format(time.RFC3339), MaxAge)
+	}
+	return nil
+}
+
+// Fetch downloads the PublicKey published next to an MCP endpoint, at
+// WellKnownPath on the same origin.
+func Fetch(ctx context.Context, client *http.Client, endpoint string) (ed25519.PublicKey, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path, u.RawQuery, u.Fragment = WellKnownPath, "", ""
+	// The key of a WebSocket endpoint is served over plain HTTP(S).
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	var doc PublicKey
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("GET %s: %w", u, err)
+	}
+	return doc.Decode()
+}
@@ -0,0 +1,133 @@
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func newKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, priv
+}
+
+func newResult() *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content:           []mcp.Content{&mcp.TextContent{Text: "12:00 UTC"}},
+		StructuredContent: map[string]any{"time": "12:00", "zone": "UTC"},
+	}
+}
+
+// transmit sends result through JSON, as a client receives it.
+func transmit(t *testing.T, result *mcp.CallToolResult) *mcp.CallToolResult {
+	t.Helper()
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got mcp.CallToolResult
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	return &got
+}
+
+func TestValidSignature(t *testing.T) {
+	pub, priv := newKey(t)
+	result := newResult()
+	if err := Sign(priv, result); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(pub, result); err != nil {
+		t.Errorf("Verify before transmission: %v", err)
+	}
+	if err := Verify(pub, transmit(t, result)); err != nil {
+		t.Errorf("Verify after transmission: %v", err)
+	}
+}
+
+func TestModifiedBody(t *testing.T) {
+	pub, priv := newKey(t)
+	result := newResult()
+	if err := Sign(priv, result); err != nil {
+		t.Fatal(err)
+	}
+	got := transmit(t, result)
+	got.Content[0].(*mcp.TextContent).Text = "13:00 UTC"
+	if err := Verify(pub, got); err == nil {
+		t.Error("modified content verified")
+	}
+
+	got = transmit(t, result)
+	got.StructuredContent.(map[string]any)["zone"] = "CET"
+	if err := Verify(pub, got); err == nil {
+		t.Error("modified structured content verified")
+	}
+
+	got = transmit(t, result)
+	got.IsError = true
+	if err := Verify(pub, got); err == nil {
+		t.Error("modified isError verified")
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	_, priv := newKey(t)
+	other, _ := newKey(t)
+	result := newResult()
+	if err := Sign(priv, result); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(other, transmit(t, result)); err == nil {
+		t.Error("signature verified with another key")
+	}
+
+	// A key_id rewritten to match does not help.
+	got := transmit(t, result)
+	sig := got.Meta[MetaKey].(map[string]any)
+	sig["key_id"] = KeyID(other)
+	if err := Verify(other, got); err == nil {
+		t.Error("signature verified with another key and a rewritten key_id")
+	}
+}
+
+func TestStaleTimestamp(t *testing.T) {
+	pub, priv := newKey(t)
+	for _, offset := range []time.Duration{-time.Hour, time.Hour} {
+		result := newResult()
+		if err := signAt(priv, result, time.Now().Add(offset)); err != nil {
+			t.Fatal(err)
+		}
+		if err := Verify(pub, transmit(t, result)); err == nil {
+			t.Errorf("signature made %s from now verified", offset)
+		}
+	}
+
+	// Moving signed_at to now breaks the signature instead.
+	result := newResult()
+	if err := signAt(priv, result, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	got := transmit(t, result)
+	got.Meta[MetaKey].(map[string]any)["signed_at"] = time.Now().Unix()
+	if err := Verify(pub, got); err == nil {
+		t.Error("signature verified with a rewritten signed_at")
+	}
+
+	// Within MaxAge is fine.
+	result = newResult()
+	if err := signAt(priv, result, time.Now().Add(-MaxAge/2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(pub, transmit(t, result)); err != nil {
+		t.Errorf("signature made %s ago: %v", MaxAge/2, err)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !js
+
+package wstransport
+
+import "github.com/coder/websocket"
+
+func (t *ClientTransport) dialOptions() *websocket.DialOptions {
+	return &websocket.DialOptions{
+		HTTPClient:   t.HTTPClient,
+		HTTPHeader:   t.Header,
+		Subprotocols: []string{Subprotocol},
+	}
+}
@@ -0,0 +1,11 @@
+//go:build js
+
+package wstransport
+
+import "github.com/coder/websocket"
+
+// dialOptions leaves out the HTTP client and headers: the browser's
+// WebSocket API sends its own.
+func (t *ClientTransport) dialOptions() *websocket.DialOptions {
+	return &websocket.DialOptions{Subprotocols: []string{Subprotocol}}
+}
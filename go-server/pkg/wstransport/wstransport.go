@@ -0,0 +1,155 @@
+// Package wstransport carries MCP over WebSocket, for networks whose
+// proxies and gateways buffer or cut Server-Sent Events. Each JSON-RPC
+// message is one text message. Either side may ping the other at an
+// interval, which keeps idle connections open through proxies and notices
+// a peer that went away without closing.
+//
+// A server accepts the connection with github.com/coder/websocket and
+// hands NewConn's result to mcp.Server.Connect; clients connect with
+// ClientTransport.
+package wstransport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// Subprotocol is the WebSocket subprotocol both sides offer.
+	Subprotocol = "mcp"
+	// SessionIDHeader carries the session ID in the handshake response,
+	// as it does on Streamable HTTP.
+	SessionIDHeader = "Mcp-Session-Id"
+	// DefaultMaxMessageSize is the largest message read when no limit is
+	// given.
+	DefaultMaxMessageSize = 4 << 20
+)
+
+// Conn is an MCP connection over one WebSocket.
+type Conn struct {
+	ws        *websocket.Conn
+	sessionID string
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewConn wraps ws, which should already have its read limit set. A
+// positive pingInterval pings the peer at that interval and drops the
+// connection when the pong does not arrive within it.
+func NewConn(ws *websocket.Conn, sessionID string, pingInterval time.Duration) *Conn {
+	c := &Conn{ws: ws, sessionID: sessionID, done: make(chan struct{})}
+	if pingInterval > 0 {
+		go c.keepAlive(pingInterval)
+	}
+	return c
+}
+
+// keepAlive pings the peer. The pong is read by the session's Read loop.
+func (c *Conn) keepAlive(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-t.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		err := c.ws.Ping(ctx)
+		cancel()
+		if err != nil {
+			c.ws.CloseNow()
+			return
+		}
+	}
+}
+
+// Read returns the next message. A normal close by the peer is io.EOF.
+func (c *Conn) Read(ctx context.Context) (jsonrpc.Message, error) {
+	_, data, err := c.ws.Read(ctx)
+	if err != nil {
+		switch websocket.CloseStatus(err) {
+		case websocket.StatusNormalClosure, websocket.StatusGoingAway:
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	return jsonrpc.DecodeMessage(data)
+}
+
+// Write sends msg as one text message.
+func (c *Conn) Write(ctx context.Context, msg jsonrpc.Message) error {
+	data, err := jsonrpc.EncodeMessage(msg)
+	if err != nil {
+		return err
+	}
+	return c.ws.Write(ctx, websocket.MessageText, data)
+}
+
+// Close closes the WebSocket with a normal closure.
+func (c *Conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		// The peer or the keepalive may have closed it already.
+		if err = c.ws.Close(websocket.StatusNormalClosure, ""); errors.Is(err, net.ErrClosed) {
+			err = nil
+		}
+	})
+	return err
+}
+
+// SessionID is the ID the server gave the session.
+func (c *Conn) SessionID() string { return c.sessionID }
+
+// Transport returns a transport that connects to c, for
+// mcp.Server.Connect.
+func (c *Conn) Transport() mcp.Transport { return connTransport{c} }
+
+type connTransport struct{ c *Conn }
+
+func (t connTransport) Connect(context.Context) (mcp.Connection, error) { return t.c, nil }
+
+// ClientTransport connects to an MCP server's WebSocket endpoint, such as
+// ws://localhost:8080/ws.
+type ClientTransport struct {
+	URL string
+	// HTTPClient is used for the handshake; nil means http.DefaultClient.
+	// A browser (js/wasm) makes the handshake itself and ignores it.
+	HTTPClient *http.Client
+	// Header is sent with the handshake, e.g. for authorization; not in a
+	// browser.
+	Header http.Header
+	// MaxMessageSize limits the messages read; zero means
+	// DefaultMaxMessageSize.
+	MaxMessageSize int64
+	// PingInterval, if positive, pings the server at this interval.
+	PingInterval time.Duration
+}
+
+// Connect implements mcp.Transport.
+func (t *ClientTransport) Connect(ctx context.Context) (mcp.Connection, error) {
+	ws, resp, err := websocket.Dial(ctx, t.URL, t.dialOptions())
+	if err != nil {
+		return nil, err
+	}
+	limit := t.MaxMessageSize
+	if limit <= 0 {
+		limit = DefaultMaxMessageSize
+	}
+	ws.SetReadLimit(limit)
+	var id string
+	if resp != nil {
+		id = resp.Header.Get(SessionIDHeader)
+	}
+	return NewConn(ws, id, t.PingInterval), nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/* ---------- Outbound connection pool ---------- */
+
+const (
+	// defaultFetchMaxConnsPerHost, defaultFetchMaxIdlePerHost and
+	// defaultFetchIdleTimeout are the defaults of
+	// -fetch-max-conns-per-host, -fetch-max-idle-per-host and
+	// -fetch-idle-timeout.
+	defaultFetchMaxConnsPerHost = 16
+	defaultFetchMaxIdlePerHost  = 4
+	defaultFetchIdleTimeout     = 90 * time.Second
+)
+
+// poolSettings shape the connections of the outbound transport.
+type poolSettings struct {
+	// MaxConnsPerHost bounds the connections to one host, idle or in use;
+	// requests beyond it wait for one to be free. 0: no limit.
+	MaxConnsPerHost int
+	// MaxIdlePerHost is how many idle connections a host keeps for reuse,
+	// for at most IdleTimeout each.
+	MaxIdlePerHost int
+	IdleTimeout    time.Duration
+	// HTTP2 lets TLS connections negotiate HTTP/2.
+	HTTP2 bool
+}
+
+// connPool counts the connections the outbound transport opens and how
+// requests get one. Go's transport keeps its pool to itself, so
+// connections are counted as they are dialed and closed.
+type connPool struct {
+	settings poolSettings
+
+	mu   sync.Mutex
+	open map[string]int // by dialed address
+
+	opened, reused, fresh atomic.Int64
+	acquireNanos          atomic.Int64
+}
+
+// outboundPool is set in main, except in a browser build, whose requests
+// go through the browser's own connections.
+var outboundPool *connPool
+
+func newConnPool(settings poolSettings) *connPool {
+	return &connPool{settings: settings, open: make(map[string]int)}
+}
+
+// configure applies the pool settings to t and counts the connections it
+// dials.
+func (p *connPool) configure(t *http.Transport) {
+	t.MaxConnsPerHost = p.settings.MaxConnsPerHost
+	t.MaxIdleConnsPerHost = p.settings.MaxIdlePerHost
+	t.IdleConnTimeout = p.settings.IdleTimeout
+	t.ForceAttemptHTTP2 = p.settings.HTTP2
+	if !p.settings.HTTP2 {
+		// A non-nil empty map is what turns HTTP/2 off.
+		t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+	dial := t.DialContext
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		p.opened.Add(1)
+		p.mu.Lock()
+		p.open[addr]++
+		p.mu.Unlock()
+		return &pooledConn{Conn: conn, pool: p, addr: addr}, nil
+	}
+}
+
+// pooledConn takes itself off the open count when closed.
+type pooledConn struct {
+	net.Conn
+	pool *connPool
+	addr string
+	once sync.Once
+}
+
+func (c *pooledConn) Close() error {
+	c.once.Do(func() {
+		p := c.pool
+		p.mu.Lock()
+		if p.open[c.addr]--; p.open[c.addr] <= 0 {
+			delete(p.open, c.addr)
+		}
+		p.mu.Unlock()
+	})
+	return c.Conn.Close()
+}
+
+// poolTransport records, for each request, whether it reused a pooled
+// connection and how long getting one took. It must sit directly on the
+// outbound transport: hedging hides outer traces from its second attempt.
+type poolTransport struct {
+	pool *connPool
+	next http.RoundTripper
+}
+
+func (t *poolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var start time.Time
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) { start = time.Now() },
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				t.pool.reused.Add(1)
+			} else {
+				t.pool.fresh.Add(1)
+			}
+			if !start.IsZero() {
+				t.pool.acquireNanos.Add(int64(time.Since(start)))
+			}
+		},
+	}
+	return t.next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+func (p *connPool) collectMetrics(w *metricsWriter) {
+	p.mu.Lock()
+	w.family("mcp_fetch_conns_open", "gauge", "Open outbound connections, idle or in use, per dialed address")
+	for _, addr := range sortedKeys(p.open) {
+		w.sample("mcp_fetch_conns_open", float64(p.open[addr]), "addr", addr)
+	}
+	p.mu.Unlock()
+	w.family("mcp_fetch_conns_opened_total", "counter", "Outbound connections dialed")
+	w.sample("mcp_fetch_conns_opened_total", float64(p.opened.Load()))
+	w.family("mcp_fetch_conn_requests_total", "counter", "Outbound requests by the connection they got: reused from the pool or new")
+	w.sample("mcp_fetch_conn_requests_total", float64(p.reused.Load()), "conn", "reused")
+	w.sample("mcp_fetch_conn_requests_total", float64(p.fresh.Load()), "conn", "new")
+	w.family("mcp_fetch_conn_acquire_seconds_total", "counter", "Time outbound requests spent getting a connection: dialing, or waiting for one under -fetch-max-conns-per-host")
+	w.sample("mcp_fetch_conn_acquire_seconds_total", time.Duration(p.acquireNanos.Load()).Seconds())
+}
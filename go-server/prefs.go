@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Learned argument defaults ---------- */
+
+// learnedArgs are the arguments whose last value is remembered per session
+// and tool, and passed again when a later call to that tool leaves them
+// out. Values are only learned from calls that succeed, so they are valid
+// for the tool.
+var learnedArgs = []string{"timezone", "max_bytes"}
+
+const (
+	// prefBaseURL is the origin of the last absolute http(s) url argument,
+	// against which url arguments starting with / are resolved.
+	prefBaseURL = "base_url"
+	// maxPrefSessions bounds the sessions preferences are kept for.
+	maxPrefSessions = 1024
+)
+
+// learnedPref is one remembered value, keyed by tool.argument or base_url.
+type learnedPref struct {
+	Value   json.RawMessage
+	Learned time.Time
+}
+
+// prefsStore holds the learned preferences of each session. Unlike
+// set_defaults, nothing has to be set: the server picks the values up
+// from the calls the client makes.
+type prefsStore struct {
+	mu       sync.Mutex
+	sessions map[string]map[string]learnedPref
+}
+
+var prefs = &prefsStore{sessions: make(map[string]map[string]learnedPref)}
+
+func (s *prefsStore) get(sessionID string) map[string]learnedPref {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]learnedPref, len(s.sessions[sessionID]))
+	for k, v := range s.sessions[sessionID] {
+		out[k] = v
+	}
+	return out
+}
+
+func (s *prefsStore) learn(sessionID string, learned map[string]json.RawMessage) {
+	if len(learned) == 0 {
+		return
+	}
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.sessions[sessionID]
+	if !ok {
+		if len(s.sessions) >= maxPrefSessions {
+			// Sessions end without telling us; start over rather than grow.
+			clear(s.sessions)
+		}
+		p = make(map[string]learnedPref)
+		s.sessions[sessionID] = p
+	}
+	for k, v := range learned {
+		p[k] = learnedPref{Value: v, Learned: now}
+	}
+}
+
+// reset forgets the given keys of a session, or all of them when keys is
+// empty, returning those it forgot.
+func (s *prefsStore) reset(sessionID string, keys []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.sessions[sessionID]
+	var removed []string
+	for k := range p {
+		if len(keys) == 0 || slices.Contains(keys, k) {
+			delete(p, k)
+			removed = append(removed, k)
+		}
+	}
+	if len(p) == 0 {
+		delete(s.sessions, sessionID)
+	}
+	slices.Sort(removed)
+	return removed
+}
+
+// retain drops the preferences of sessions not in live, returning how
+// many.
+func (s *prefsStore) retain(live map[string]bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id := range s.sessions {
+		if !live[id] {
+			delete(s.sessions, id)
+			n++
+		}
+	}
+	return n
+}
+
+// apply fills the arguments of a call to tool that the client left out
+// from the session's preferences, and resolves a url starting with / on
+// the base URL. It returns the keys it used.
+func (s *prefsStore) apply(session *mcp.ServerSession, tool string, args map[string]json.RawMessage) []string {
+	p := s.get(session.ID())
+	var used []string
+	for _, name := range learnedArgs {
+		key := tool + "." + name
+		v, ok := p[key]
+		if _, given := args[name]; given || !ok {
+			continue
+		}
+		// A timezone set with set_defaults is chosen on purpose and wins
+		// over a learned one.
+		if name == "timezone" && defaults.lookup(session).Timezone != "" {
+			continue
+		}
+		args[name] = v.Value
+		used = append(used, key)
+	}
+	var ref string
+	if base, ok := p[prefBaseURL]; ok && json.Unmarshal(args["url"], &ref) == nil && strings.HasPrefix(ref, "/") {
+		var b string
+		json.Unmarshal(base.Value, &b)
+		bu, err1 := url.Parse(b)
+		ru, err2 := url.Parse(ref)
+		if err1 == nil && err2 == nil {
+			args["url"], _ = json.Marshal(bu.ResolveReference(ru).String())
+			used = append(used, prefBaseURL)
+		}
+	}
+	return used
+}
+
+// learnable picks from the arguments a client gave the values worth
+// remembering.
+func learnable(tool string, args map[string]json.RawMessage) map[string]json.RawMessage {
+	out := make(map[string]json.RawMessage)
+	for _, name := range learnedArgs {
+		if v, ok := args[name]; ok && string(v) != "null" {
+			out[tool+"."+name] = v
+		}
+	}
+	var raw string
+	if json.Unmarshal(args["url"], &raw) == nil {
+		if u, err := url.Parse(raw); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+			out[prefBaseURL], _ = json.Marshal(u.Scheme + "://" + u.Host)
+		}
+	}
+	return out
+}
+
+// prefsMiddleware applies the session's preferences to tools/call before
+// the SDK decodes the arguments, and learns from the arguments of calls
+// that succeed.
+func prefsMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		call, ok := req.(*mcp.CallToolRequest)
+		// A session without an ID cannot be told apart from other such
+		// sessions, which would share its preferences.
+		if method != "tools/call" || !ok || call.Session == nil || call.Session.ID() == "" || call.Params == nil {
+			return next(ctx, method, req)
+		}
+		var args map[string]json.RawMessage
+		if len(call.Params.Arguments) > 0 && json.Unmarshal(call.Params.Arguments, &args) != nil {
+			return next(ctx, method, req)
+		}
+		if args == nil {
+			args = make(map[string]json.RawMessage)
+		}
+		given := learnable(call.Params.Name, args)
+		if used := prefs.apply(call.Session, call.Params.Name, args); len(used) > 0 {
+			raw, err := json.Marshal(args)
+			if err != nil {
+				return nil, err
+			}
+			call.Params.Arguments = raw
+			if logEnabled(logDebug) {
+				log.Printf("[PREFS] %s: applied %s", call.Params.Name, strings.Join(used, ", "))
+			}
+		}
+		result, err := next(ctx, method, req)
+		if res, ok := result.(*mcp.CallToolResult); err == nil && ok && !res.IsError {
+			prefs.learn(call.Session.ID(), given)
+		}
+		return result, err
+	}
+}
+
+type PrefsArgs struct {
+	Reset bool     `json:"reset,omitempty" jsonschema:"Forget learned preferences: the given keys, or all of them"`
+	Keys  []string `json:"keys,omitempty" jsonschema:"Keys to reset, e.g. fetch.max_bytes or base_url"`
+}
+
+// PrefEntry is one learned preference.
+type PrefEntry struct {
+	Key       string    `json:"key" jsonschema:"tool.argument, or base_url"`
+	Value     any       `json:"value"`
+	LearnedAt time.Time `json:"learned_at"`
+}
+
+// PrefsResult is the structured output of the prefs tool.
+type PrefsResult struct {
+	Prefs []PrefEntry `json:"prefs"`
+	Reset []string    `json:"reset,omitempty" jsonschema:"Keys that were forgotten"`
+}
+
+func PrefsTool(ctx context.Context, req *mcp.CallToolRequest, in PrefsArgs) (*mcp.CallToolResult, any, error) {
+	if len(in.Keys) > 0 && !in.Reset {
+		return errorResult("keys needs reset: true"), nil, nil
+	}
+	id := req.Session.ID()
+	out := PrefsResult{Prefs: []PrefEntry{}}
+	if id == "" {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{&mcp.TextContent{Text: "No learned preferences: they are kept per session, and this session has no ID"}},
+		}, out, nil
+	}
+	if in.Reset {
+		out.Reset = prefs.reset(id, in.Keys)
+	}
+	p := prefs.get(id)
+	var lines []string
+	for _, k := range sortedKeys(p) {
+		var v any
+		json.Unmarshal(p[k].Value, &v)
+		out.Prefs = append(out.Prefs, PrefEntry{Key: k, Value: v, LearnedAt: p[k].Learned.UTC()})
+		lines = append(lines, fmt.Sprintf("%s=%s", k, p[k].Value))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "No learned preferences")
+	}
+	if len(out.Reset) > 0 {
+		lines = append(lines, "Reset: "+strings.Join(out.Reset, ", "))
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: strings.Join(lines, "\n")}},
+	}, out, nil
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Priority classes and the call scheduler ---------- */
+
+// priorityClass orders tool calls when they have to wait for a slot.
+type priorityClass int
+
+const (
+	priorityBackground priorityClass = iota
+	priorityNormal
+	priorityInteractive
+	numPriorityClasses
+)
+
+var priorityNames = [numPriorityClasses]string{"background", "normal", "interactive"}
+
+func (c priorityClass) String() string { return priorityNames[c] }
+
+func parsePriority(s string) (priorityClass, error) {
+	for c, name := range priorityNames {
+		if strings.EqualFold(s, name) {
+			return priorityClass(c), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown priority %q (want interactive, normal or background)", s)
+}
+
+// priorityConfig is the JSON file given with -priority-config. Callers
+// whose bearer token belongs to a tenant get the tenant's priority,
+// others Default. _meta can lower a call's class but not raise it.
+type priorityConfig struct {
+	Default string           `json:"default"`
+	Tenants []priorityTenant `json:"tenants"`
+
+	defaultClass priorityClass
+}
+
+type priorityTenant struct {
+	Name     string `json:"name"`
+	Token    string `json:"token"`
+	Priority string `json:"priority"`
+
+	class priorityClass
+}
+
+// priorities is non-nil when -priority-config is set. Without it, _meta
+// may pick any class and calls default to normal.
+var priorities *priorityConfig
+
+func loadPriorityConfig(path string) (*priorityConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg priorityConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	cfg.defaultClass = priorityNormal
+	if cfg.Default != "" {
+		if cfg.defaultClass, err = parsePriority(cfg.Default); err != nil {
+			return nil, fmt.Errorf("default: %v", err)
+		}
+	}
+	for i, t := range cfg.Tenants {
+		if t.Token == "" {
+			return nil, fmt.Errorf("tenant %q: token is required", t.Name)
+		}
+		if cfg.Tenants[i].class, err = parsePriority(t.Priority); err != nil {
+			return nil, fmt.Errorf("tenant %q: %v", t.Name, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// classFor returns the highest class the caller may use: that of the
+// tenant whose token the request carries, or the default.
+func (c *priorityConfig) classFor(header http.Header) priorityClass {
+	if c == nil {
+		return priorityInteractive
+	}
+	if token, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer "); ok {
+		for _, t := range c.Tenants {
+			if subtle.ConstantTimeCompare([]byte(t.Token), []byte(strings.TrimSpace(token))) == 1 {
+				return t.class
+			}
+		}
+	}
+	return c.defaultClass
+}
+
+// callPriority picks a call's class from its _meta, limited to what the
+// caller may use. Without a valid _meta entry, a caller of a configured
+// tenant (or the default) gets its class and others get normal. Sessions
+// that turned the priority feature off always get that class. A class
+// that is invalid or above the caller's is reported in a warning.
+func callPriority(ctx context.Context, req *mcp.CallToolRequest) priorityClass {
+	var header http.Header
+	if extra := req.GetExtra(); extra != nil {
+		header = extra.Header
+	}
+	limit := priorities.classFor(header)
+	class := min(priorityNormal, limit)
+	if priorities != nil {
+		class = limit
+	}
+	if !featureEnabled(req.Session, featurePriority) {
+		return class
+	}
+	if name, ok := metaString(requestMeta(req), priorityMetaKey); ok {
+		requested, err := parsePriority(name)
+		switch {
+		case err != nil:
+			addWarning(ctx, "invalid_meta", fmt.Sprintf("_meta[%q]: %v; ignored", priorityMetaKey, err))
+		case requested > limit:
+			addWarning(ctx, "priority_lowered", fmt.Sprintf("priority %s is not allowed for this caller; the call ran as %s", requested, limit))
+			class = limit
+		default:
+			class = requested
+		}
+	}
+	return class
+}
+
+// callScheduler admits at most limit tool calls at a time. Waiting calls
+// are started highest class first, in arrival order within a class. When
+// the queue is full, a new call displaces the most recent waiter of a
+// lower class, so background calls are shed first; if there is none, the
+// new call is shed itself.
+type callScheduler struct {
+	limit, maxQueued int
+
+	mu      sync.Mutex
+	running [numPriorityClasses]int
+	queues  [numPriorityClasses][]*callWaiter
+	stats   [numPriorityClasses]schedulerCounters
+}
+
+type callWaiter struct {
+	class priorityClass
+	ready chan error // receives nil when started, errCallShed when displaced
+}
+
+type schedulerCounters struct {
+	admitted, shed int
+	wait           time.Duration
+}
+
+var errCallShed = errors.New("shed")
+
+// scheduler is non-nil when -max-concurrent-calls is set.
+var scheduler *callScheduler
+
+func newCallScheduler(limit, maxQueued int) *callScheduler {
+	return &callScheduler{limit: limit, maxQueued: maxQueued}
+}
+
+func (s *callScheduler) runningLocked() int {
+	n := 0
+	for _, r := range s.running {
+		n += r
+	}
+	return n
+}
+
+func (s *callScheduler) queuedLocked() int {
+	n := 0
+	for _, q := range s.queues {
+		n += len(q)
+	}
+	return n
+}
+
+// acquire waits for a slot. It fails with errCallShed when the call is
+// shed, or with ctx's error.
+func (s *callScheduler) acquire(ctx context.Context, class priorityClass) error {
+	start := time.Now()
+	s.mu.Lock()
+	if s.runningLocked() < s.limit && s.queuedLocked() == 0 {
+		s.running[class]++
+		s.stats[class].admitted++
+		s.mu.Unlock()
+		return nil
+	}
+	if s.queuedLocked() >= s.maxQueued && !s.displaceLocked(class) {
+		s.stats[class].shed++
+		s.mu.Unlock()
+		return errCallShed
+	}
+	w := &callWaiter{class: class, ready: make(chan error, 1)}
+	s.queues[class] = append(s.queues[class], w)
+	s.mu.Unlock()
+
+	select {
+	case err := <-w.ready:
+		s.recordWait(class, start)
+		return err
+	case <-ctx.Done():
+		s.mu.Lock()
+		q := s.queues[class]
+		for i, other := range q {
+			if other == w {
+				s.queues[class] = append(q[:i], q[i+1:]...)
+				s.mu.Unlock()
+				s.recordWait(class, start)
+				return ctx.Err()
+			}
+		}
+		s.mu.Unlock()
+		// Started or shed just as ctx ended.
+		if err := <-w.ready; err == nil {
+			s.release(class)
+		}
+		s.recordWait(class, start)
+		return ctx.Err()
+	}
+}
+
+// displaceLocked sheds the newest waiter of the lowest class below class,
+// reporting whether there was one.
+func (s *callScheduler) displaceLocked(class priorityClass) bool {
+	for c := priorityBackground; c < class; c++ {
+		if q := s.queues[c]; len(q) > 0 {
+			w := q[len(q)-1]
+			s.queues[c] = q[:len(q)-1]
+			s.stats[c].shed++
+			w.ready <- errCallShed
+			return true
+		}
+	}
+	return false
+}
+
+// release frees a slot and starts the next waiter, if any.
+func (s *callScheduler) release(class priorityClass) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running[class]--
+	for c := numPriorityClasses - 1; c >= priorityBackground; c-- {
+		if q := s.queues[c]; len(q) > 0 && s.runningLocked() < s.limit {
+			w := q[0]
+			s.queues[c] = q[1:]
+			s.running[c]++
+			s.stats[c].admitted++
+			w.ready <- nil
+			return
+		}
+	}
+}
+
+func (s *callScheduler) recordWait(class priorityClass, start time.Time) {
+	s.mu.Lock()
+	s.stats[class].wait += time.Since(start)
+	s.mu.Unlock()
+}
+
+// priorityToolMiddleware runs each call once the scheduler admits it.
+// Shed calls get an OVERLOADED error result, with details in _meta.
+func priorityToolMiddleware(tool *mcp.Tool, next ToolFunc) ToolFunc {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, any, error) {
+		if scheduler == nil {
+			return next(ctx, req)
+		}
+		class := callPriority(ctx, req)
+		if err := scheduler.acquire(ctx, class); err != nil {
+			if !errors.Is(err, errCallShed) {
+				return nil, nil, err
+			}
+			res := errorResult(fmt.Sprintf("OVERLOADED: the server is busy and shed this %s-priority %s call; retry later", class, tool.Name))
+			setMeta(res, errorMetaKey, map[string]any{"code": "OVERLOADED", "priority": class.String()})
+			return res, nil, nil
+		}
+		defer scheduler.release(class)
+		return next(ctx, req)
+	}
+}
+
+func (s *callScheduler) collectMetrics(w *metricsWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.family("mcp_call_queue_length", "gauge", "Tool calls waiting for a slot, per priority class")
+	for c := range numPriorityClasses {
+		w.sample("mcp_call_queue_length", float64(len(s.queues[c])), "priority", c.String())
+	}
+	w.family("mcp_calls_running", "gauge", "Tool calls holding a slot, per priority class")
+	for c := range numPriorityClasses {
+		w.sample("mcp_calls_running", float64(s.running[c]), "priority", c.String())
+	}
+	w.family("mcp_calls_admitted_total", "counter", "Tool calls started by the scheduler, per priority class")
+	for c := range numPriorityClasses {
+		w.sample("mcp_calls_admitted_total", float64(s.stats[c].admitted), "priority", c.String())
+	}
+	w.family("mcp_calls_shed_total", "counter", "Tool calls shed because the queue was full, per priority class")
+	for c := range numPriorityClasses {
+		w.sample("mcp_calls_shed_total", float64(s.stats[c].shed), "priority", c.String())
+	}
+	w.family("mcp_call_queue_wait_seconds_total", "counter", "Time tool calls spent queued, per priority class")
+	for c := range numPriorityClasses {
+		w.sample("mcp_call_queue_wait_seconds_total", s.stats[c].wait.Seconds(), "priority", c.String())
+	}
+}
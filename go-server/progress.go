@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Progress notifications ---------- */
+
+// progressInterval spaces out the notifications of frequent updates such
+// as bytes downloaded.
+const progressInterval = 250 * time.Millisecond
+
+// progressReporter sends notifications/progress for one tool call that
+// carried a progress token. A nil reporter drops everything, so callers
+// need not check whether the client asked for progress.
+type progressReporter struct {
+	ctx     context.Context
+	session *mcp.ServerSession
+	token   any
+
+	mu       sync.Mutex
+	progress float64 // last value sent; each notification must increase it
+	sent     time.Time
+}
+
+type progressKey struct{}
+
+// progressToolMiddleware gives calls with a progress token a reporter,
+// which handlers and the outbound transport find with progressFrom.
+func progressToolMiddleware(tool *mcp.Tool, next ToolFunc) ToolFunc {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, any, error) {
+		if token := req.Params.GetProgressToken(); token != nil && req.Session != nil {
+			ctx = context.WithValue(ctx, progressKey{}, &progressReporter{ctx: ctx, session: req.Session, token: token})
+		}
+		return next(ctx, req)
+	}
+}
+
+// progressFrom returns the reporter of the tool call ctx belongs to, or
+// nil.
+func progressFrom(ctx context.Context) *progressReporter {
+	p, _ := ctx.Value(progressKey{}).(*progressReporter)
+	return p
+}
+
+// withoutTransferProgress returns a context whose requests do not report
+// bytes read, for tools that report progress in other units.
+func withoutTransferProgress(ctx context.Context) context.Context {
+	return context.WithValue(ctx, progressKey{}, (*progressReporter)(nil))
+}
+
+// notify sends a notification now. total is 0 when unknown. A progress
+// value not above the last one sent is dropped.
+func (p *progressReporter) notify(progress, total float64, message string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.send(progress, total, message)
+}
+
+// report is notify for frequent updates: it sends at most one
+// notification per progressInterval, and always the one reaching total.
+func (p *progressReporter) report(progress, total float64, message string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Since(p.sent) < progressInterval && (total == 0 || progress < total) {
+		return
+	}
+	p.send(progress, total, message)
+}
+
+func (p *progressReporter) send(progress, total float64, message string) {
+	if progress <= p.progress {
+		return
+	}
+	p.progress, p.sent = progress, time.Now()
+	p.session.NotifyProgress(p.ctx, &mcp.ProgressNotificationParams{
+		ProgressToken: p.token,
+		Message:       message,
+		Progress:      progress,
+		Total:         total,
+	})
+}
+
+// current returns the last progress value sent.
+func (p *progressReporter) current() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.progress
+}
+
+// progressTransport reports the response bytes read during tool calls that
+// asked for progress. Progress counts bytes across all of a call's
+// requests, so it keeps increasing over redirects and fallbacks; total is
+// set while the current response has a Content-Length.
+type progressTransport struct {
+	next http.RoundTripper
+}
+
+func (t *progressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	p := progressFrom(req.Context())
+	if err != nil || p == nil {
+		return resp, err
+	}
+	resp.Body = &progressBody{ReadCloser: resp.Body, reporter: p, host: req.URL.Host, base: p.current(), size: resp.ContentLength}
+	return resp, nil
+}
+
+// progressBody reports the bytes read from one response.
+type progressBody struct {
+	io.ReadCloser
+	reporter *progressReporter
+	host     string
+	base     float64
+	size     int64 // -1 when unknown
+	read     int64
+}
+
+func (r *progressBody) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.read += int64(n)
+		progress, total := r.base+float64(r.read), 0.0
+		msg := fmt.Sprintf("downloaded %d bytes from %s", r.read, r.host)
+		if r.size > 0 {
+			total = r.base + float64(r.size)
+			msg = fmt.Sprintf("downloaded %d of %d bytes (%d%%) from %s", r.read, r.size, r.read*100/r.size, r.host)
+		}
+		r.reporter.report(progress, total, msg)
+	}
+	return n, err
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+/* ---------- Outbound proxy ---------- */
+
+// fetchProxyDirect is the -fetch-proxy value that ignores the proxy
+// environment variables.
+const fetchProxyDirect = "direct"
+
+// outboundProxy routes outbound requests through an HTTP(S) or SOCKS5
+// proxy: the one given with -fetch-proxy, or else HTTP_PROXY and
+// HTTPS_PROXY. NO_PROXY applies either way. Credentials go in the URL's
+// userinfo and are sent as Proxy-Authorization (or SOCKS5
+// username/password).
+type outboundProxy struct {
+	config *httpproxy.Config
+	proxy  func(*url.URL) (*url.URL, error)
+	// addrs are the host:port of the proxies, which the dialer may reach
+	// even when -fetch-deny-private would refuse their address.
+	addrs map[string]bool
+}
+
+// fetchProxy is the process-wide proxy, configured from flags in main;
+// nil means direct connections.
+var fetchProxy *outboundProxy
+
+// parseFetchProxy validates -fetch-proxy, reading the environment when it
+// is empty. It returns nil when there is no proxy to use.
+func parseFetchProxy(s string) (*outboundProxy, error) {
+	var config *httpproxy.Config
+	switch s {
+	case fetchProxyDirect:
+		return nil, nil
+	case "":
+		config = httpproxy.FromEnvironment()
+	default:
+		config = &httpproxy.Config{HTTPProxy: s, HTTPSProxy: s, NoProxy: httpproxy.FromEnvironment().NoProxy}
+	}
+	p := &outboundProxy{config: config, addrs: make(map[string]bool)}
+	for _, raw := range []string{config.HTTPProxy, config.HTTPSProxy} {
+		if raw == "" {
+			continue
+		}
+		u, err := parseProxyURL(raw)
+		if err != nil {
+			return nil, err
+		}
+		p.addrs[proxyAddr(u)] = true
+	}
+	if len(p.addrs) == 0 {
+		return nil, nil
+	}
+	p.proxy = config.ProxyFunc()
+	return p, nil
+}
+
+// parseProxyURL checks a proxy URL the way the transport will use it: a
+// bare host:port means http://host:port.
+func parseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || !strings.Contains(raw, "://") {
+		if u, err = url.Parse("http://" + raw); err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %q", redactProxy(raw))
+		}
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("proxy scheme %q not supported (want http, https or socks5)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", redactProxy(raw))
+	}
+	return u, nil
+}
+
+// proxyAddr is the address the transport dials for a proxy.
+func proxyAddr(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return net.JoinHostPort(u.Hostname(), port)
+	}
+	port := "80"
+	switch u.Scheme {
+	case "https":
+		port = "443"
+	case "socks5", "socks5h":
+		port = "1080"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// redactProxy hides the password of a proxy URL for logs and errors.
+func redactProxy(raw string) string {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	if u, err := url.Parse(raw); err == nil {
+		return u.Redacted()
+	}
+	if i := strings.LastIndex(raw, "@"); i >= 0 {
+		return "xxxxx" + raw[i:]
+	}
+	return raw
+}
+
+// proxyFunc is the transport's Proxy.
+func (p *outboundProxy) proxyFunc(r *http.Request) (*url.URL, error) {
+	return p.proxy(r.URL)
+}
+
+// proxied reports whether requests to u go through the proxy.
+func (p *outboundProxy) proxied(u *url.URL) bool {
+	if p == nil {
+		return false
+	}
+	proxy, err := p.proxy(u)
+	return err == nil && proxy != nil
+}
+
+// isProxyAddr reports whether address is one of the proxies.
+func (p *outboundProxy) isProxyAddr(address string) bool {
+	return p != nil && p.addrs[address]
+}
+
+// describe summarizes the proxy for the startup log.
+func (p *outboundProxy) describe() string {
+	var parts []string
+	if p.config.HTTPProxy == p.config.HTTPSProxy {
+		parts = append(parts, redactProxy(p.config.HTTPProxy))
+	} else {
+		if p.config.HTTPProxy != "" {
+			parts = append(parts, "http via "+redactProxy(p.config.HTTPProxy))
+		}
+		if p.config.HTTPSProxy != "" {
+			parts = append(parts, "https via "+redactProxy(p.config.HTTPSProxy))
+		}
+	}
+	if p.config.NoProxy != "" {
+		parts = append(parts, "except NO_PROXY="+p.config.NoProxy)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Public demo mode ---------- */
+
+const (
+	// defaultPublicDemoHosts is the default value of -public-demo-hosts.
+	defaultPublicDemoHosts = "example.com,example.org,httpbin.org,ifconfig.co,api.github.com"
+	// publicDemoFetchMaxBytes caps fetch responses in public demo mode.
+	publicDemoFetchMaxBytes = 8192
+	// publicDemoEchoMaxDelay caps echotest's delay_ms in public demo mode.
+	publicDemoEchoMaxDelay = 5 * time.Second
+	// publicDemoBurst is the number of requests a client may send at once
+	// before the per-minute rate applies.
+	publicDemoBurst = 10
+	// publicDemoBannerURI is the informational resource shown to clients.
+	publicDemoBannerURI = "demo://banner"
+)
+
+// publicDemoConfig holds the -public-demo settings.
+type publicDemoConfig struct {
+	Hosts         []string
+	RatePerMinute float64
+}
+
+// publicDemo is non-nil when the server runs with -public-demo.
+var publicDemo *publicDemoConfig
+
+// demoLimiter rate-limits HTTP clients in -public-demo mode.
+var demoLimiter *rateLimiter
+
+// applyPublicDemo locks the server down for anonymous internet use: fetch
+// is limited to GET/HEAD on allowlisted public hosts with no custom
+// headers and a small size cap. Callers must also skip registering any
+// tool other than echotest, timeserver and fetch.
+func applyPublicDemo(cfg *publicDemoConfig) {
+	egress.DenyPrivate = true
+	egress.AllowHosts = cfg.Hosts
+	fetchMethods = map[string]bool{http.MethodGet: true, http.MethodHead: true}
+	fetchSettings.Store(&fetchLimits{MaxBytes: publicDemoFetchMaxBytes, DefaultBytes: defaultMaxBytes, MaxBinaryBytes: publicDemoFetchMaxBytes, AllowedHeaders: map[string]bool{}})
+	echoMaxDelay = publicDemoEchoMaxDelay
+	demoLimiter = newRateLimiter(cfg.RatePerMinute, publicDemoBurst)
+	publicDemo = cfg
+}
+
+// parseHostList lower-cases and trims a comma-separated host list.
+func parseHostList(list string) []string {
+	var hosts []string
+	for _, h := range strings.Split(list, ",") {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h != "" {
+			hosts = append(hosts, strings.TrimSuffix(h, "."))
+		}
+	}
+	return hosts
+}
+
+// addPublicDemoBanner registers the demo://banner resource describing
+// the playground and its limits.
+func addPublicDemoBanner(server *mcp.Server) {
+	server.AddResource(&mcp.Resource{
+		URI:         publicDemoBannerURI,
+		Name:        "banner",
+		Title:       "About this MCP playground",
+		Description: "What this public demo server offers and how it is limited",
+		MIMEType:    "text/markdown",
+	}, func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		return &mcp.ReadResourceResult{
+			Contents: []*mcp.ResourceContents{{
+				URI:      publicDemoBannerURI,
+				MIMEType: "text/markdown",
+				Text:     publicDemoBanner(),
+			}},
+		}, nil
+	})
+}
+
+func publicDemoBanner() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# mcp-server-demo-go %s: public playground\n\n", version)
+	b.WriteString("This is a live MCP server you can connect any MCP client to. It is a demo: do not send secrets.\n\n")
+	b.WriteString("## Tools\n\n")
+	fmt.Fprintf(&b, "- `echotest`: echoes a message back, optionally transformed or delayed (up to %s)\n", publicDemoEchoMaxDelay)
+	b.WriteString("- `timeserver`: current time in any IANA timezone\n")
+	fmt.Fprintf(&b, "- `fetch`: GET or HEAD, up to %d bytes, only these hosts (and their subdomains): %s\n\n",
+		publicDemoFetchMaxBytes, strings.Join(publicDemo.Hosts, ", "))
+	b.WriteString("## Limits\n\n")
+	fmt.Fprintf(&b, "- %g requests per minute per client address (bursts of %d), then HTTP 429\n", demoLimiter.perMinute(), publicDemoBurst)
+	b.WriteString("- Idle sessions expire after 30 minutes\n")
+	b.WriteString("- Request logs keep only truncated client addresses and no headers\n")
+	return b.String()
+}
+
+// rateLimiter is a per-client token bucket: each client address gets
+// burst tokens, refilled at rate per second.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(perMinute float64, burst int) *rateLimiter {
+	l := &rateLimiter{rate: perMinute / 60, burst: float64(burst), buckets: make(map[string]*bucket)}
+	go l.sweep()
+	return l
+}
+
+// setRate changes the refill rate; existing buckets keep their tokens.
+func (l *rateLimiter) setRate(perMinute float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = perMinute / 60
+}
+
+func (l *rateLimiter) perMinute() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate * 60
+}
+
+// allow takes a token for key, returning how long to wait when none is
+// left.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// sweep drops buckets that have refilled completely, bounding memory to
+// the set of recently active clients.
+func (l *rateLimiter) sweep() {
+	for range time.Tick(time.Minute) {
+		l.mu.Lock()
+		full := time.Duration(l.burst / l.rate * float64(time.Second))
+		for key, b := range l.buckets {
+			if time.Since(b.last) > full {
+				delete(l.buckets, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+// middleware rejects requests over the limit with 429 Too Many Requests.
+// Health checks are exempt so probes never fail because of load.
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health", "/healthz", "/livez", "/readyz":
+			next.ServeHTTP(w, r)
+			return
+		}
+		ok, wait := l.allow(clientHost(r.RemoteAddr))
+		if !ok {
+			w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "rate limit exceeded; see the demo://banner resource for limits", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func clientHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// anonymizeAddr truncates a client address for logging: IPv4 to /24 and
+// IPv6 to /48, dropping the port.
+func anonymizeAddr(remoteAddr string) string {
+	ip, err := netip.ParseAddr(clientHost(remoteAddr))
+	if err != nil {
+		return "unknown"
+	}
+	bits := 24
+	if !ip.Unmap().Is4() {
+		bits = 48
+	}
+	prefix, err := ip.Unmap().Prefix(bits)
+	if err != nil {
+		return "unknown"
+	}
+	return prefix.String()
+}
+
+// logPublicDemo prints the startup summary of the demo restrictions.
+func logPublicDemo() {
+	log.Printf("Public demo mode: tools echotest, timeserver, fetch (GET/HEAD, %d bytes, hosts %s)",
+		publicDemoFetchMaxBytes, strings.Join(publicDemo.Hosts, ","))
+	log.Printf("Public demo mode: %g requests/minute per client (burst %d), anonymized logs", demoLimiter.perMinute(), publicDemoBurst)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Tool: random ---------- */
+
+const (
+	maxRandomCount = 100
+	// defaultRandomBytes and maxRandomBytes bound length for bytes and tokens.
+	defaultRandomBytes = 16
+	defaultTokenBytes  = 32
+	maxRandomBytes     = 1024
+	defaultRandomMax   = 100
+)
+
+// seededEpoch is the UUIDv7 timestamp base used when a seed is given, so
+// seeded output does not depend on the clock.
+var seededEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type RandomArgs struct {
+	Kind     string `json:"kind,omitempty" jsonschema:"uuid4 (default), uuid7, int, bytes or token"`
+	Count    int    `json:"count,omitempty" jsonschema:"Number of values to generate (default 1, max 100)"`
+	Min      int64  `json:"min,omitempty" jsonschema:"Lower bound for int, inclusive (default 0)"`
+	Max      int64  `json:"max,omitempty" jsonschema:"Upper bound for int, inclusive (default 100)"`
+	Length   int    `json:"length,omitempty" jsonschema:"Number of random bytes for bytes (default 16) and token (default 32), max 1024"`
+	Encoding string `json:"encoding,omitempty" jsonschema:"Encoding for bytes: hex (default) or base64; tokens are always URL-safe base64"`
+	Seed     *int64 `json:"seed,omitempty" jsonschema:"Deterministic seed for reproducible output; omit for cryptographically secure randomness"`
+}
+
+// RandomResult is the structured output of the random tool.
+type RandomResult struct {
+	Kind   string   `json:"kind"`
+	Values []string `json:"values"`
+	Seeded bool     `json:"seeded" jsonschema:"True when output came from the deterministic seed and must not be used for secrets"`
+}
+
+// randomSource draws either from crypto/rand or from a seeded ChaCha8
+// stream; both are exposed as an io.Reader.
+func randomSource(seed *int64) io.Reader {
+	if seed == nil {
+		return crand.Reader
+	}
+	var key [32]byte
+	binary.LittleEndian.PutUint64(key[:], uint64(*seed))
+	return rand.NewChaCha8(key)
+}
+
+// randomInt returns a uniform value in [lo, hi], rejecting draws from
+// the incomplete top range to avoid modulo bias.
+func randomInt(r io.Reader, lo, hi int64) (int64, error) {
+	span := uint64(hi-lo) + 1 // wraps to 0 for the full int64 range
+	var buf [8]byte
+	for {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return 0, err
+		}
+		v := binary.LittleEndian.Uint64(buf[:])
+		if span == 0 {
+			return int64(v), nil
+		}
+		if limit := -span % span; v >= limit {
+			return lo + int64(v%span), nil
+		}
+	}
+}
+
+// newUUID builds an RFC 9562 UUID of version 4 or 7 from r. For version 7
+// the first 48 bits are the Unix time in milliseconds.
+func newUUID(r io.Reader, version byte, now time.Time) (string, error) {
+	var u [16]byte
+	if _, err := io.ReadFull(r, u[:]); err != nil {
+		return "", err
+	}
+	if version == 7 {
+		ms := uint64(now.UnixMilli())
+		for i := 0; i < 6; i++ {
+			u[i] = byte(ms >> (40 - 8*i))
+		}
+	}
+	u[6] = u[6]&0x0f | version<<4
+	u[8] = u[8]&0x3f | 0x80
+	h := hex.EncodeToString(u[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+}
+
+func RandomTool(ctx context.Context, req *mcp.CallToolRequest, in RandomArgs) (*mcp.CallToolResult, any, error) {
+	kind := strings.ToLower(in.Kind)
+	if kind == "" {
+		kind = "uuid4"
+	}
+	count := in.Count
+	if count <= 0 {
+		count = 1
+	}
+	if count > maxRandomCount {
+		return errorResult(fmt.Sprintf("count must be at most %d", maxRandomCount)), nil, nil
+	}
+	if in.Length < 0 || in.Length > maxRandomBytes {
+		return errorResult(fmt.Sprintf("length must be between 1 and %d (0 uses the default)", maxRandomBytes)), nil, nil
+	}
+
+	r := randomSource(in.Seed)
+	out := RandomResult{Kind: kind, Seeded: in.Seed != nil, Values: make([]string, 0, count)}
+
+	var gen func(i int) (string, error)
+	switch kind {
+	case "uuid4":
+		gen = func(int) (string, error) { return newUUID(r, 4, time.Time{}) }
+	case "uuid7":
+		gen = func(i int) (string, error) {
+			now := time.Now()
+			if in.Seed != nil {
+				now = seededEpoch.Add(time.Duration(i) * time.Millisecond)
+			}
+			return newUUID(r, 7, now)
+		}
+	case "int":
+		lo, hi := in.Min, in.Max
+		if hi == 0 && lo == 0 {
+			hi = defaultRandomMax
+		}
+		if lo > hi {
+			return errorResult(fmt.Sprintf("min (%d) must not exceed max (%d)", lo, hi)), nil, nil
+		}
+		gen = func(int) (string, error) {
+			n, err := randomInt(r, lo, hi)
+			return fmt.Sprint(n), err
+		}
+	case "bytes", "token":
+		length := in.Length
+		if length == 0 {
+			length = defaultRandomBytes
+			if kind == "token" {
+				length = defaultTokenBytes
+			}
+		}
+		encode := hex.EncodeToString
+		switch {
+		case kind == "token":
+			encode = base64.RawURLEncoding.EncodeToString
+		case in.Encoding == "base64":
+			encode = base64.StdEncoding.EncodeToString
+		case in.Encoding != "" && in.Encoding != "hex":
+			return errorResult(fmt.Sprintf("unknown encoding %q (want hex or base64)", in.Encoding)), nil, nil
+		}
+		gen = func(int) (string, error) {
+			b := make([]byte, length)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return "", err
+			}
+			return encode(b), nil
+		}
+	default:
+		return errorResult(fmt.Sprintf("unknown kind %q (want uuid4, uuid7, int, bytes or token)", in.Kind)), nil, nil
+	}
+
+	for i := 0; i < count; i++ {
+		v, err := gen(i)
+		if err != nil {
+			return errorResult("Random source error: " + err.Error()), nil, nil
+		}
+		out.Values = append(out.Values, v)
+	}
+
+	text := strings.Join(out.Values, "\n")
+	if out.Seeded {
+		text += fmt.Sprintf("\n\n[seed %d: deterministic output, not for secrets]", *in.Seed)
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: text}},
+	}, out, nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Redaction profiles ---------- */
+
+// redactionCategories are the built-in kinds of data a profile can mask,
+// applied in this order so that e.g. the host part of an email address is
+// replaced together with the rest of it.
+var redactionCategories = []struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// valid, if set, confirms a regexp candidate before it is replaced.
+	valid func(string) bool
+}{
+	{"email", regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@(?:[a-z0-9-]+\.)+[a-z]{2,63}\b`), nil},
+	{"ipv6", regexp.MustCompile(`(?i)[0-9a-f]*:[0-9a-f:]*:[0-9a-f.:]*`), func(s string) bool {
+		addr, err := netip.ParseAddr(s)
+		return err == nil && addr.Is6()
+	}},
+	{"ipv4", regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), func(s string) bool {
+		addr, err := netip.ParseAddr(s)
+		return err == nil && addr.Is4()
+	}},
+	{"hostname", regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}\b`), nil},
+}
+
+// redactionConfig is the JSON file given with -redaction-config.
+type redactionConfig struct {
+	// Default names the profile for callers without a known token.
+	Default  string                      `json:"default"`
+	Profiles map[string]redactionProfile `json:"profiles"`
+	Tenants  []redactionTenant           `json:"tenants"`
+}
+
+// redactionProfile lists what to mask. An empty profile returns results
+// unchanged.
+type redactionProfile struct {
+	// Mask holds built-in categories: email, ipv4, ipv6, ip (both) and
+	// hostname.
+	Mask []string `json:"mask"`
+	// Patterns are extra regular expressions replaced with [redacted].
+	Patterns []string `json:"patterns"`
+
+	categories map[string]bool
+	patterns   []*regexp.Regexp
+}
+
+// redactionTenant maps a bearer token to a profile.
+type redactionTenant struct {
+	Name    string `json:"name"`
+	Token   string `json:"token"`
+	Profile string `json:"profile"`
+}
+
+// redaction is non-nil when -redaction-config is set.
+var redaction *redactionConfig
+
+// loadRedactionConfig reads and validates a profile file.
+func loadRedactionConfig(path string) (*redactionConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg redactionConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	for name, p := range cfg.Profiles {
+		p.categories = make(map[string]bool)
+		for _, m := range p.Mask {
+			switch m = strings.ToLower(m); m {
+			case "ip":
+				p.categories["ipv4"], p.categories["ipv6"] = true, true
+			case "email", "ipv4", "ipv6", "hostname":
+				p.categories[m] = true
+			default:
+				return nil, fmt.Errorf("profile %q: unknown mask %q (want email, ip, ipv4, ipv6 or hostname)", name, m)
+			}
+		}
+		for _, pat := range p.Patterns {
+			re, err := regexp.Compile(pat)
+			if err != nil {
+				return nil, fmt.Errorf("profile %q: %v", name, err)
+			}
+			p.patterns = append(p.patterns, re)
+		}
+		cfg.Profiles[name] = p
+	}
+	if _, ok := cfg.Profiles[cfg.Default]; !ok {
+		return nil, fmt.Errorf("default profile %q is not defined", cfg.Default)
+	}
+	for _, t := range cfg.Tenants {
+		if t.Token == "" {
+			return nil, fmt.Errorf("tenant %q: token is required", t.Name)
+		}
+		if _, ok := cfg.Profiles[t.Profile]; !ok {
+			return nil, fmt.Errorf("tenant %q: profile %q is not defined", t.Name, t.Profile)
+		}
+	}
+	return &cfg, nil
+}
+
+// profileFor picks the profile of the tenant whose token the request
+// carries in its Authorization header, or the default profile.
+func (c *redactionConfig) profileFor(header http.Header) redactionProfile {
+	token, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer ")
+	if ok {
+		for _, t := range c.Tenants {
+			if subtle.ConstantTimeCompare([]byte(t.Token), []byte(strings.TrimSpace(token))) == 1 {
+				return c.Profiles[t.Profile]
+			}
+		}
+	}
+	return c.Profiles[c.Default]
+}
+
+func (p redactionProfile) empty() bool {
+	return len(p.categories) == 0 && len(p.patterns) == 0
+}
+
+// redactString masks every configured category and pattern in s.
+func (p redactionProfile) redactString(s string) string {
+	for _, c := range redactionCategories {
+		if !p.categories[c.Name] {
+			continue
+		}
+		s = c.Pattern.ReplaceAllStringFunc(s, func(m string) string {
+			if c.valid != nil && !c.valid(m) {
+				return m
+			}
+			return "[" + c.Name + "]"
+		})
+	}
+	for _, re := range p.patterns {
+		s = re.ReplaceAllString(s, "[redacted]")
+	}
+	return s
+}
+
+// redactValue rewrites every string (including object keys, which may be
+// header names or hosts) inside a decoded JSON value.
+func (p redactionProfile) redactValue(v any) any {
+	switch v := v.(type) {
+	case string:
+		return p.redactString(v)
+	case []any:
+		for i := range v {
+			v[i] = p.redactValue(v[i])
+		}
+		return v
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, val := range v {
+			out[p.redactString(k)] = p.redactValue(val)
+		}
+		return out
+	}
+	return v
+}
+
+// redact applies the profile to a tool result's text and structured
+// content in place.
+func (p redactionProfile) redact(res *mcp.CallToolResult) error {
+	for _, c := range res.Content {
+		if t, ok := c.(*mcp.TextContent); ok {
+			t.Text = p.redactString(t.Text)
+		}
+	}
+	if res.StructuredContent == nil {
+		return nil
+	}
+	data, err := json.Marshal(res.StructuredContent)
+	if err != nil {
+		return err
+	}
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	res.StructuredContent = p.redactValue(v)
+	return nil
+}
+
+// redactionMiddleware masks tool results according to the caller's
+// profile. It must sit inside the signing middleware so that signatures
+// cover what the caller actually receives.
+func redactionMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		result, err := next(ctx, method, req)
+		if method != "tools/call" || err != nil {
+			return result, err
+		}
+		res, ok := result.(*mcp.CallToolResult)
+		if !ok {
+			return result, err
+		}
+		var header http.Header
+		if extra := req.GetExtra(); extra != nil {
+			header = extra.Header
+		}
+		if profile := redaction.profileFor(header); !profile.empty() {
+			if err := profile.redact(res); err != nil {
+				return nil, fmt.Errorf("redacting result: %w", err)
+			}
+		}
+		return result, err
+	}
+}
+
+// logRedaction prints the configured profiles and tenants, never tokens.
+func logRedaction() {
+	var names []string
+	for name := range redaction.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	log.Printf("Redaction: profiles %s (default %s), %d tenant(s)", strings.Join(names, ","), redaction.Default, len(redaction.Tenants))
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Config reload ---------- */
+
+// Log levels (-log-level, "log_level" in the config file). info logs
+// every HTTP request; debug adds a line per tool call; warn only keeps
+// startup messages and problems.
+const (
+	logDebug = "debug"
+	logInfo  = "info"
+	logWarn  = "warn"
+)
+
+var logLevels = map[string]int{logDebug: 0, logInfo: 1, logWarn: 2}
+
+var currentLogLevel atomic.Value // string
+
+func init() { currentLogLevel.Store(logInfo) }
+
+// logEnabled reports whether messages at level are logged.
+func logEnabled(level string) bool {
+	return logLevels[level] >= logLevels[currentLogLevel.Load().(string)]
+}
+
+// configFlags are the command-line values that take precedence over the
+// config file, on startup and on every reload.
+type configFlags struct {
+	// given holds the names of the flags set on the command line.
+	given map[string]bool
+
+	Profile        string
+	LogLevel       string
+	FetchHeaders   string
+	FetchMaxBytes  int
+	FetchDefault   int
+	FetchMaxBinary int
+	PublicDemoRate float64
+	EnableTools    string
+	DisableTools   string
+}
+
+// resolve layers the flags over cfg, fills in defaults and validates
+// the result.
+func (f *configFlags) resolve(cfg serverConfig) (*serverConfig, error) {
+	if f.given["log-level"] || cfg.LogLevel == "" {
+		cfg.LogLevel = f.LogLevel
+	}
+	if f.given["fetch-allowed-headers"] || cfg.Fetch.AllowedHeaders == nil {
+		cfg.Fetch.AllowedHeaders = strings.Split(f.FetchHeaders, ",")
+	}
+	if f.given["fetch-max-bytes"] || cfg.Fetch.MaxBytes == 0 {
+		cfg.Fetch.MaxBytes = f.FetchMaxBytes
+	}
+	if f.given["fetch-default-bytes"] || cfg.Fetch.DefaultBytes == 0 {
+		cfg.Fetch.DefaultBytes = f.FetchDefault
+	}
+	if f.given["fetch-max-binary-bytes"] || cfg.Fetch.MaxBinaryBytes == 0 {
+		cfg.Fetch.MaxBinaryBytes = f.FetchMaxBinary
+	}
+	if f.given["public-demo-rate"] || cfg.PublicDemo.RatePerMinute == 0 {
+		cfg.PublicDemo.RatePerMinute = f.PublicDemoRate
+	}
+	if f.given["enable-tools"] {
+		cfg.Tools.Enable = strings.Split(f.EnableTools, ",")
+	}
+	if f.given["disable-tools"] {
+		cfg.Tools.Disable = strings.Split(f.DisableTools, ",")
+	}
+
+	if _, ok := logLevels[cfg.LogLevel]; !ok {
+		return nil, fmt.Errorf("log level %q: want debug, info or warn", cfg.LogLevel)
+	}
+	if cfg.Fetch.MaxBytes < minCapBytes || cfg.Fetch.MaxBytes > fetchBytesLimit {
+		return nil, fmt.Errorf("fetch.max_bytes must be between %d and %d", minCapBytes, fetchBytesLimit)
+	}
+	if cfg.Fetch.DefaultBytes < minCapBytes || cfg.Fetch.DefaultBytes > fetchBytesLimit {
+		return nil, fmt.Errorf("fetch.default_bytes must be between %d and %d", minCapBytes, fetchBytesLimit)
+	}
+	if cfg.Fetch.MaxBinaryBytes < minCapBytes || cfg.Fetch.MaxBinaryBytes > fetchBytesLimit {
+		return nil, fmt.Errorf("fetch.max_binary_bytes must be between %d and %d", minCapBytes, fetchBytesLimit)
+	}
+	if cfg.PublicDemo.RatePerMinute <= 0 {
+		return nil, fmt.Errorf("public demo rate must be positive")
+	}
+	return &cfg, nil
+}
+
+// loadSettings reads the config file at path, if any, selects its
+// profile and layers the environment and the flags over it.
+func loadSettings(path string, flags *configFlags) (*serverConfig, error) {
+	var cfg serverConfig
+	if path != "" {
+		loaded, err := loadConfig(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		cfg = *loaded
+	}
+	profile := flags.Profile
+	if !flags.given["profile"] {
+		profile = os.Getenv(profileEnvVar)
+	}
+	if profile != "" {
+		if path == "" {
+			return nil, fmt.Errorf("profile %q selected without -config", profile)
+		}
+		if err := cfg.selectProfile(profile); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	env, err := settingsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	overlaySettings(&cfg.configSettings, &env)
+	return flags.resolve(cfg)
+}
+
+// applySettings puts resolved settings other than the tool selection into
+// effect. -public-demo keeps its fixed fetch limits.
+func applySettings(cfg *serverConfig) {
+	currentLogLevel.Store(cfg.LogLevel)
+	if publicDemo == nil {
+		fetchSettings.Store(&fetchLimits{
+			MaxBytes:       cfg.Fetch.MaxBytes,
+			DefaultBytes:   cfg.Fetch.DefaultBytes,
+			MaxBinaryBytes: cfg.Fetch.MaxBinaryBytes,
+			AllowedHeaders: parseHeaderList(strings.Join(cfg.Fetch.AllowedHeaders, ",")),
+		})
+	} else {
+		demoLimiter.setRate(cfg.PublicDemo.RatePerMinute)
+	}
+	slos.configure(cfg.SLOs)
+}
+
+// configReloader re-reads the config file on SIGHUP and when the file
+// changes. Sessions are untouched: settings are swapped in place, and
+// tools are added or removed on the running server, which notifies
+// clients with notifications/tools/list_changed.
+type configReloader struct {
+	path   string
+	flags  *configFlags
+	server *mcp.Server
+
+	mu sync.Mutex
+}
+
+func (r *configReloader) reload(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cfg, err := loadSettings(r.path, r.flags)
+	if err != nil {
+		log.Printf("Config reload (%s) failed, keeping current settings: %v", reason, err)
+		return
+	}
+	added, removed, err := toolSet.apply(r.server, cfg.Tools)
+	if err != nil {
+		log.Printf("Config reload (%s) failed, keeping current settings: %v", reason, err)
+		return
+	}
+	applySettings(cfg)
+	log.Printf("Config reloaded (%s): log level %s, fetch max_bytes %d%s",
+		reason, cfg.LogLevel, fetchSettings.Load().MaxBytes, toolChanges(added, removed))
+}
+
+func toolChanges(added, removed []string) string {
+	var b strings.Builder
+	if len(added) > 0 {
+		fmt.Fprintf(&b, ", tools added %s", strings.Join(added, ","))
+	}
+	if len(removed) > 0 {
+		fmt.Fprintf(&b, ", tools removed %s", strings.Join(removed, ","))
+	}
+	return b.String()
+}
+
+// watch reloads on SIGHUP and, with a positive interval, whenever the
+// file's modification time or size changes.
+func (r *configReloader) watch(interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	notifyReload(hup)
+	var tick <-chan time.Time
+	if interval > 0 {
+		tick = time.Tick(interval)
+	}
+	last := fileStamp(r.path)
+	for {
+		select {
+		case <-hup:
+			last = fileStamp(r.path)
+			r.reload("SIGHUP")
+		case <-tick:
+			if stamp := fileStamp(r.path); stamp != last && stamp != "" {
+				last = stamp
+				r.reload("file changed")
+			}
+		}
+	}
+}
+
+// fileStamp identifies a version of a file, or is empty if it cannot be
+// read (e.g. in the middle of an editor's rename).
+func fileStamp(path string) string {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", fi.ModTime().UnixNano(), fi.Size())
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Usage report ---------- */
+
+const (
+	usageReportURI = "report://usage"
+	// defaultReportSince is the period a report covers by default.
+	defaultReportSince = 7 * 24 * time.Hour
+	defaultReportTop   = 10
+)
+
+// usageReport aggregates the audit log over a period.
+type usageReport struct {
+	From         time.Time    `json:"from"`
+	To           time.Time    `json:"to"`
+	Calls        int          `json:"calls"`
+	Errors       int          `json:"errors"`
+	FetchedBytes int64        `json:"fetched_bytes"`
+	Tools        []toolUsage  `json:"tools"`
+	Days         []dayUsage   `json:"days"`
+	TopClients   []usageCount `json:"top_clients"`
+	// Skipped counts audit log lines that could not be parsed.
+	Skipped int `json:"skipped_lines,omitempty"`
+}
+
+// usageCount is the usage of one tool, client or tool on one day.
+type usageCount struct {
+	Name         string  `json:"name"`
+	Calls        int     `json:"calls"`
+	Errors       int     `json:"errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	FetchedBytes int64   `json:"fetched_bytes"`
+}
+
+type toolUsage struct {
+	usageCount
+	MeanDurationMs float64 `json:"mean_duration_ms"`
+	totalMs        float64
+}
+
+// dayUsage holds the calls of one UTC day, per tool.
+type dayUsage struct {
+	Day   string       `json:"day"`
+	Tools []usageCount `json:"tools"`
+}
+
+func (u *usageCount) add(r auditRecord) {
+	u.Calls++
+	if r.Outcome == "error" || r.Outcome == "failed" {
+		u.Errors++
+	}
+	u.ErrorRate = float64(u.Errors) / float64(u.Calls)
+	u.FetchedBytes += r.FetchedBytes
+}
+
+// readAuditRecords reads the records at or after since from an audit log
+// and its rotated files. Lines that do not parse are counted, not fatal,
+// so that a line cut off by a crash does not hide the rest.
+func readAuditRecords(path string, since time.Time) (records []auditRecord, skipped int, err error) {
+	for _, p := range auditFiles(path) {
+		n, err := readAuditFile(p, since, &records)
+		skipped += n
+		if err != nil {
+			return nil, skipped, err
+		}
+	}
+	return records, skipped, nil
+}
+
+func readAuditFile(path string, since time.Time, records *[]auditRecord) (skipped int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var r auditRecord
+		if json.Unmarshal(line, &r) != nil || r.Tool == "" {
+			skipped++
+			continue
+		}
+		if !r.Time.Before(since) {
+			*records = append(*records, r)
+		}
+	}
+	return skipped, sc.Err()
+}
+
+// buildUsageReport aggregates records into a report listing the top
+// clients by calls.
+func buildUsageReport(records []auditRecord, from, to time.Time, top int) *usageReport {
+	rep := &usageReport{From: from.UTC(), To: to.UTC(), Tools: []toolUsage{}, Days: []dayUsage{}, TopClients: []usageCount{}}
+	tools := map[string]*toolUsage{}
+	days := map[string]map[string]*usageCount{}
+	clients := map[string]*usageCount{}
+	for _, r := range records {
+		rep.Calls++
+		if r.Outcome == "error" || r.Outcome == "failed" {
+			rep.Errors++
+		}
+		rep.FetchedBytes += r.FetchedBytes
+
+		t := tools[r.Tool]
+		if t == nil {
+			t = &toolUsage{usageCount: usageCount{Name: r.Tool}}
+			tools[r.Tool] = t
+		}
+		t.add(r)
+		t.totalMs += r.DurationMs
+		t.MeanDurationMs = roundMs(time.Duration(t.totalMs / float64(t.Calls) * float64(time.Millisecond)))
+
+		day := r.Time.UTC().Format(time.DateOnly)
+		if days[day] == nil {
+			days[day] = map[string]*usageCount{}
+		}
+		d := days[day][r.Tool]
+		if d == nil {
+			d = &usageCount{Name: r.Tool}
+			days[day][r.Tool] = d
+		}
+		d.add(r)
+
+		client := r.Client
+		if client == "" {
+			client = r.Remote
+		}
+		if client == "" {
+			client = "unknown"
+		}
+		c := clients[client]
+		if c == nil {
+			c = &usageCount{Name: client}
+			clients[client] = c
+		}
+		c.add(r)
+	}
+
+	for _, name := range sortedKeys(tools) {
+		rep.Tools = append(rep.Tools, *tools[name])
+	}
+	for _, day := range sortedKeys(days) {
+		du := dayUsage{Day: day}
+		for _, name := range sortedKeys(days[day]) {
+			du.Tools = append(du.Tools, *days[day][name])
+		}
+		rep.Days = append(rep.Days, du)
+	}
+	for _, c := range clients {
+		rep.TopClients = append(rep.TopClients, *c)
+	}
+	sort.Slice(rep.TopClients, func(i, j int) bool {
+		a, b := rep.TopClients[i], rep.TopClients[j]
+		if a.Calls != b.Calls {
+			return a.Calls > b.Calls
+		}
+		return a.Name < b.Name
+	})
+	if len(rep.TopClients) > top {
+		rep.TopClients = rep.TopClients[:top]
+	}
+	return rep
+}
+
+// usageReportFromLog reads the audit log and builds the report of the
+// period since the given duration before now.
+func usageReportFromLog(path string, since time.Duration, top int) (*usageReport, error) {
+	to := time.Now()
+	from := to.Add(-since)
+	records, skipped, err := readAuditRecords(path, from)
+	if err != nil {
+		return nil, err
+	}
+	rep := buildUsageReport(records, from, to, top)
+	rep.Skipped = skipped
+	return rep, nil
+}
+
+// writeCSV writes one row per day and tool.
+func (rep *usageReport) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"day", "tool", "calls", "errors", "error_rate", "fetched_bytes"})
+	for _, d := range rep.Days {
+		for _, t := range d.Tools {
+			cw.Write([]string{d.Day, t.Name, strconv.Itoa(t.Calls), strconv.Itoa(t.Errors),
+				strconv.FormatFloat(t.ErrorRate, 'f', 4, 64), strconv.FormatInt(t.FetchedBytes, 10)})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// markdown renders the report as a summary with tables.
+func (rep *usageReport) markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Usage report\n\n%s to %s (UTC)\n\n", rep.From.Format("2006-01-02 15:04"), rep.To.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "- Calls: %d\n- Errors: %d (%s)\n- Fetched: %s\n", rep.Calls, rep.Errors, percent(rep.Errors, rep.Calls), formatBytes(rep.FetchedBytes))
+	if rep.Skipped > 0 {
+		fmt.Fprintf(&b, "- Unreadable audit log lines: %d\n", rep.Skipped)
+	}
+	if rep.Calls == 0 {
+		b.WriteString("\nNo tool calls in this period.\n")
+		return b.String()
+	}
+
+	b.WriteString("\n## Tools\n\n| Tool | Calls | Errors | Error rate | Mean duration | Fetched |\n|---|---:|---:|---:|---:|---:|\n")
+	for _, t := range rep.Tools {
+		fmt.Fprintf(&b, "| %s | %d | %d | %s | %.2f ms | %s |\n", t.Name, t.Calls, t.Errors, percent(t.Errors, t.Calls), t.MeanDurationMs, formatBytes(t.FetchedBytes))
+	}
+	b.WriteString("\n## Calls per day\n\n| Day | Tool | Calls | Errors |\n|---|---|---:|---:|\n")
+	for _, d := range rep.Days {
+		for _, t := range d.Tools {
+			fmt.Fprintf(&b, "| %s | %s | %d | %d |\n", d.Day, t.Name, t.Calls, t.Errors)
+		}
+	}
+	b.WriteString("\n## Top clients\n\n| Client | Calls | Error rate | Fetched |\n|---|---:|---:|---:|\n")
+	for _, c := range rep.TopClients {
+		fmt.Fprintf(&b, "| %s | %d | %s | %s |\n", strings.ReplaceAll(c.Name, "|", `\|`), c.Calls, percent(c.Errors, c.Calls), formatBytes(c.FetchedBytes))
+	}
+	return b.String()
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "0%"
+	}
+	return strconv.FormatFloat(100*float64(n)/float64(total), 'f', 1, 64) + "%"
+}
+
+// formatBytes renders n with a binary unit, e.g. 1.5 MiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// writeUsageReport renders rep in format: json, csv or markdown.
+func writeUsageReport(w io.Writer, rep *usageReport, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	case "csv":
+		return rep.writeCSV(w)
+	case "markdown", "md":
+		_, err := io.WriteString(w, rep.markdown())
+		return err
+	}
+	return fmt.Errorf("unknown format %q (want json, csv or markdown)", format)
+}
+
+// parseSince accepts a Go duration or a number of days such as 30d.
+func parseSince(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid period %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid period %q (e.g. 24h or 7d)", s)
+	}
+	return d, nil
+}
+
+// runReportCommand implements "report [-since 7d] [-format json] [-top 10]
+// <audit-log>".
+func runReportCommand(args []string) int {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	since := fs.String("since", "7d", "Period to cover, ending now: a duration such as 24h or a number of days such as 30d")
+	format := fs.String("format", "markdown", "Output format: json, csv (calls per day and tool) or markdown")
+	top := fs.Int("top", defaultReportTop, "Number of top clients to list")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: report [-since 7d] [-format json|csv|markdown] [-top 10] <audit-log>\n\nAggregates an -audit-log file and its rotated files into a usage report\non standard output.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	period, err := parseSince(*since)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "report: %v\n", err)
+		return 2
+	}
+	rep, err := usageReportFromLog(fs.Arg(0), period, *top)
+	if err == nil {
+		err = writeUsageReport(os.Stdout, rep, *format)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "report: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// reportHandler serves GET /admin/report?since=7d&format=json&top=10.
+func reportHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	period, top := defaultReportSince, defaultReportTop
+	var err error
+	if s := q.Get("since"); s != "" {
+		if period, err = parseSince(s); err != nil {
+			writeGatewayError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if s := q.Get("top"); s != "" {
+		if top, err = strconv.Atoi(s); err != nil || top <= 0 {
+			writeGatewayError(w, http.StatusBadRequest, "top must be a positive number")
+			return
+		}
+	}
+	format := q.Get("format")
+	contentTypes := map[string]string{"": "application/json", "json": "application/json", "csv": "text/csv; charset=utf-8", "markdown": "text/markdown; charset=utf-8", "md": "text/markdown; charset=utf-8"}
+	contentType, ok := contentTypes[format]
+	if !ok {
+		writeGatewayError(w, http.StatusBadRequest, "format must be json, csv or markdown")
+		return
+	}
+	if format == "" {
+		format = "json"
+	}
+	rep, err := usageReportFromLog(audit.path, period, top)
+	if err != nil {
+		writeGatewayError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	writeUsageReport(w, rep, format)
+}
+
+// addUsageReport exposes the last week's report as a Markdown resource.
+func addUsageReport(server *mcp.Server) {
+	server.AddResource(&mcp.Resource{
+		URI:         usageReportURI,
+		Name:        "usage-report",
+		Title:       "Usage report",
+		Description: "Tool calls per tool and day, error rates, bytes fetched and top clients over the last 7 days, from the audit log",
+		MIMEType:    "text/markdown",
+	}, func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		rep, err := usageReportFromLog(audit.path, defaultReportSince, defaultReportTop)
+		if err != nil {
+			return nil, errors.New("cannot read the audit log")
+		}
+		return &mcp.ReadResourceResult{
+			Contents: []*mcp.ResourceContents{{URI: usageReportURI, MIMEType: "text/markdown", Text: rep.markdown()}},
+		}, nil
+	})
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Client roots and tool: list_roots ---------- */
+
+// rootsTimeout bounds one roots/list request to the client.
+const rootsTimeout = 10 * time.Second
+
+// respectClientRoots is set from -fs-client-roots. When true, the
+// filesystem tools only use the directories where the sandbox overlaps
+// the roots the client lists. A client that lists no roots, or cannot
+// list them, leaves the whole sandbox available.
+var respectClientRoots = true
+
+// rootsEntry is a session's answer to roots/list.
+type rootsEntry struct {
+	roots     []*mcp.Root
+	supported bool
+}
+
+// rootsStore caches each session's roots until the client reports that
+// they changed.
+type rootsStore struct {
+	mu       sync.Mutex
+	sessions map[string]rootsEntry
+}
+
+var clientRoots = &rootsStore{sessions: make(map[string]rootsEntry)}
+
+// list returns the session's roots, asking the client when they are not
+// cached or refresh is set. An error other than the call being cancelled
+// or timing out means the client does not provide roots.
+func (s *rootsStore) list(ctx context.Context, session *mcp.ServerSession, refresh bool) (rootsEntry, error) {
+	if session == nil {
+		return rootsEntry{}, nil
+	}
+	s.mu.Lock()
+	entry, ok := s.sessions[session.ID()]
+	s.mu.Unlock()
+	if ok && !refresh {
+		return entry, nil
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, rootsTimeout)
+	defer cancel()
+	res, err := session.ListRoots(listCtx, nil)
+	switch {
+	case ctx.Err() != nil:
+		return rootsEntry{}, ctx.Err()
+	case errors.Is(err, context.DeadlineExceeded):
+		return rootsEntry{}, errors.New("client did not answer roots/list in time")
+	case err != nil:
+		entry = rootsEntry{}
+	default:
+		entry = rootsEntry{roots: res.Roots, supported: true}
+	}
+	s.mu.Lock()
+	s.sessions[session.ID()] = entry
+	s.mu.Unlock()
+	return entry, nil
+}
+
+func (s *rootsStore) forget(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionID)
+}
+
+// retain drops the cached roots of sessions not in live, returning how
+// many.
+func (s *rootsStore) retain(live map[string]bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id := range s.sessions {
+		if !live[id] {
+			delete(s.sessions, id)
+			n++
+		}
+	}
+	return n
+}
+
+// rootsListChanged handles notifications/roots/list_changed: the next
+// filesystem call asks the client for its roots again.
+func rootsListChanged(ctx context.Context, req *mcp.RootsListChangedRequest) {
+	clientRoots.forget(req.Session.ID())
+	if logEnabled(logDebug) {
+		log.Printf("[ROOTS] session %s changed its roots", req.Session.ID())
+	}
+}
+
+// rootPath returns the local directory a file:// root names, with
+// symlinks resolved when it exists.
+func rootPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported root URI scheme %q", u.Scheme)
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return "", fmt.Errorf("root on another host %q", u.Host)
+	}
+	p := filepath.Clean(filepath.FromSlash(u.Path))
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		p = resolved
+	}
+	return p, nil
+}
+
+// pathWithin reports whether p is dir or lies below it.
+func pathWithin(dir, p string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// overlap returns the part of the sandbox a root covers: the root itself
+// when it lies inside the sandbox, the sandbox root when the root
+// contains it, and "" otherwise.
+func (s *sandbox) overlap(root string) string {
+	switch {
+	case s.contains(root):
+		return root
+	case pathWithin(root, s.Root):
+		return s.Root
+	}
+	return ""
+}
+
+// scope returns the directories of the sandbox the calling session may
+// use; limited is false when the whole sandbox is available.
+func (s *sandbox) scope(ctx context.Context, session *mcp.ServerSession) (dirs []string, limited bool, err error) {
+	if !respectClientRoots {
+		return nil, false, nil
+	}
+	entry, err := clientRoots.list(ctx, session, false)
+	if err != nil || len(entry.roots) == 0 {
+		return nil, false, err
+	}
+	for _, r := range entry.roots {
+		p, err := rootPath(r.URI)
+		if err != nil {
+			continue
+		}
+		if dir := s.overlap(p); dir != "" && !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, true, nil
+}
+
+// checkRoots rejects a resolved sandbox path that lies outside the
+// client's roots.
+func (s *sandbox) checkRoots(ctx context.Context, session *mcp.ServerSession, path string) error {
+	dirs, limited, err := s.scope(ctx, session)
+	if err != nil {
+		return fmt.Errorf("cannot list the client's roots: %v", err)
+	}
+	if !limited {
+		return nil
+	}
+	if len(dirs) == 0 {
+		return errors.New("none of the client's roots overlap the sandbox")
+	}
+	for _, d := range dirs {
+		if pathWithin(d, path) {
+			return nil
+		}
+	}
+	rel := make([]string, len(dirs))
+	for i, d := range dirs {
+		rel[i] = s.rel(d)
+	}
+	return fmt.Errorf("path %q is outside the client's roots (allowed: %s)", s.rel(path), strings.Join(rel, ", "))
+}
+
+type ListRootsArgs struct{}
+
+// ClientRoot is one root the client listed.
+type ClientRoot struct {
+	URI     string `json:"uri"`
+	Name    string `json:"name,omitempty"`
+	Path    string `json:"path,omitempty" jsonschema:"Local directory for file:// roots"`
+	Sandbox string `json:"sandbox,omitempty" jsonschema:"How the root relates to the sandbox: inside, contains or outside; empty without a sandbox"`
+	Error   string `json:"error,omitempty" jsonschema:"Why the root cannot be used"`
+}
+
+// ListRootsResult is the structured output of the list_roots tool.
+type ListRootsResult struct {
+	Supported bool         `json:"supported" jsonschema:"False when the client does not provide roots"`
+	Roots     []ClientRoot `json:"roots"`
+	Scope     []string     `json:"scope,omitempty" jsonschema:"Directories, relative to the sandbox root, the filesystem tools may use in this session"`
+	Note      string       `json:"note,omitempty"`
+}
+
+func ListRootsTool(ctx context.Context, req *mcp.CallToolRequest, in ListRootsArgs) (*mcp.CallToolResult, any, error) {
+	entry, err := clientRoots.list(ctx, req.Session, true)
+	if err != nil {
+		return errorResult("Roots error: " + err.Error()), nil, nil
+	}
+
+	out := ListRootsResult{Supported: entry.supported, Roots: []ClientRoot{}}
+	for _, r := range entry.roots {
+		root := ClientRoot{URI: r.URI, Name: r.Name}
+		p, err := rootPath(r.URI)
+		if err != nil {
+			root.Error = err.Error()
+			out.Roots = append(out.Roots, root)
+			continue
+		}
+		root.Path = p
+		if fsSandbox != nil {
+			switch {
+			case fsSandbox.contains(p):
+				root.Sandbox = "inside"
+			case pathWithin(p, fsSandbox.Root):
+				root.Sandbox = "contains"
+			default:
+				root.Sandbox = "outside"
+			}
+		}
+		out.Roots = append(out.Roots, root)
+	}
+
+	switch {
+	case fsSandbox == nil:
+		out.Note = "the server has no sandbox (-fs-root), so roots do not affect any tool"
+	case !respectClientRoots:
+		out.Note = "the server ignores client roots (-fs-client-roots=false); the filesystem tools use the whole sandbox"
+		out.Scope = []string{"."}
+	case len(out.Roots) == 0:
+		out.Note = "no roots listed; the filesystem tools use the whole sandbox"
+		out.Scope = []string{"."}
+	default:
+		dirs, _, _ := fsSandbox.scope(ctx, req.Session)
+		for _, d := range dirs {
+			out.Scope = append(out.Scope, fsSandbox.rel(d))
+		}
+		if len(dirs) == 0 {
+			out.Note = "none of the roots overlap the sandbox, so the filesystem tools refuse every path"
+		}
+	}
+
+	var b strings.Builder
+	if !out.Supported {
+		b.WriteString("The client does not provide roots.")
+	} else {
+		fmt.Fprintf(&b, "%d root(s):", len(out.Roots))
+	}
+	for _, r := range out.Roots {
+		fmt.Fprintf(&b, "\n  %s", r.URI)
+		if r.Name != "" {
+			fmt.Fprintf(&b, " (%s)", r.Name)
+		}
+		switch {
+		case r.Error != "":
+			fmt.Fprintf(&b, ": %s", r.Error)
+		case r.Sandbox != "":
+			fmt.Fprintf(&b, ": %s the sandbox", r.Sandbox)
+		}
+	}
+	if len(out.Scope) > 0 {
+		fmt.Fprintf(&b, "\nFilesystem scope: %s", strings.Join(out.Scope, ", "))
+	}
+	if out.Note != "" {
+		fmt.Fprintf(&b, "\nNote: %s", out.Note)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: b.String()}},
+	}, out, nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* ---------- Running as a service ---------- */
+
+// defaultServiceName is the -service-name of the Windows service, and the
+// name of the state and log directories.
+const defaultServiceName = "mcp-demo-server"
+
+// serviceNotifier tells the service manager that started the server how
+// it is doing: systemd through sd_notify, or the Windows service control
+// manager.
+type serviceNotifier interface {
+	// Ready is called once the server accepts requests.
+	Ready(status string)
+	// Stopping is called when shutdown begins.
+	Stopping()
+	// Stopped is called last, with the error the server stopped on.
+	Stopped(err error)
+}
+
+// startService returns the notifier of the service manager that started
+// the process, or a no-op one when there is none. Under Windows, cancel
+// is called when the service is asked to stop.
+func startService(name string, cancel context.CancelFunc) (serviceNotifier, error) {
+	if s, err := startWindowsService(name, cancel); s != nil || err != nil {
+		return s, err
+	}
+	if socket := os.Getenv("NOTIFY_SOCKET"); socket != "" {
+		return newSDNotifier(socket), nil
+	}
+	return noService{}, nil
+}
+
+type noService struct{}
+
+func (noService) Ready(string)  {}
+func (noService) Stopping()     {}
+func (noService) Stopped(error) {}
+
+// runningAsService reports whether a service manager started the process:
+// a Windows service, or a systemd unit (which sets INVOCATION_ID).
+func runningAsService() bool {
+	return isWindowsService() || os.Getenv("INVOCATION_ID") != "" || os.Getenv("NOTIFY_SOCKET") != ""
+}
+
+// sdNotifier implements the sd_notify protocol of a Type=notify systemd
+// unit: READY=1 once serving, STOPPING=1 on shutdown and, when the unit
+// sets WatchdogSec=, WATCHDOG=1 at half that interval.
+type sdNotifier struct {
+	addr *net.UnixAddr
+}
+
+func newSDNotifier(socket string) *sdNotifier {
+	// An @ stands for the abstract namespace.
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	return &sdNotifier{addr: &net.UnixAddr{Name: socket, Net: "unixgram"}}
+}
+
+func (n *sdNotifier) notify(state string) {
+	conn, err := net.DialUnix("unixgram", nil, n.addr)
+	if err != nil {
+		log.Printf("sd_notify: %v", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		log.Printf("sd_notify: %v", err)
+	}
+}
+
+func (n *sdNotifier) Ready(status string) {
+	n.notify("READY=1\nSTATUS=" + status)
+	if interval := sdWatchdogInterval(); interval > 0 {
+		go func() {
+			for range time.Tick(interval / 2) {
+				n.notify("WATCHDOG=1")
+			}
+		}()
+		log.Printf("systemd: ready, watchdog every %s", interval/2)
+	} else {
+		log.Printf("systemd: ready")
+	}
+}
+
+func (n *sdNotifier) Stopping() { n.notify("STOPPING=1") }
+
+func (n *sdNotifier) Stopped(err error) {
+	if err != nil {
+		n.notify("STATUS=" + err.Error())
+	}
+}
+
+// sdWatchdogInterval is the unit's WatchdogSec=, or 0 when the watchdog
+// is off or meant for another process.
+func sdWatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// defaultStateDir is where a service keeps its state, such as snapshots:
+// systemd's StateDirectory=, %ProgramData%\<name> on Windows,
+// ~/Library/Application Support/<name> on macOS, and /var/lib/<name> for
+// root or $XDG_STATE_HOME/<name> for other users elsewhere.
+func defaultStateDir(name string) string {
+	if dir := os.Getenv("STATE_DIRECTORY"); dir != "" {
+		first, _, _ := strings.Cut(dir, ":")
+		return first
+	}
+	home, _ := os.UserHomeDir()
+	switch runtime.GOOS {
+	case "windows":
+		return filepath.Join(os.Getenv("ProgramData"), name)
+	case "darwin":
+		return filepath.Join(home, "Library", "Application Support", name)
+	}
+	if os.Geteuid() == 0 {
+		return filepath.Join("/var/lib", name)
+	}
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, name)
+	}
+	return filepath.Join(home, ".local", "state", name)
+}
+
+// defaultLogDir is where a service writes its audit and access logs:
+// systemd's LogsDirectory=, <state dir>\logs on Windows, ~/Library/Logs/
+// <name> on macOS, and /var/log/<name> for root or <state dir>/logs for
+// other users elsewhere.
+func defaultLogDir(name string) string {
+	if dir := os.Getenv("LOGS_DIRECTORY"); dir != "" {
+		first, _, _ := strings.Cut(dir, ":")
+		return first
+	}
+	switch runtime.GOOS {
+	case "windows":
+		return filepath.Join(defaultStateDir(name), "logs")
+	case "darwin":
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, "Library", "Logs", name)
+	}
+	if os.Geteuid() == 0 {
+		return filepath.Join("/var/log", name)
+	}
+	return filepath.Join(defaultStateDir(name), "logs")
+}
+
+// inDir makes a relative path relative to dir, creating dir if needed; an
+// empty path or dir leaves path as it is.
+func inDir(dir, path string) (string, error) {
+	if dir == "" || path == "" || filepath.IsAbs(path) {
+		return path, nil
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", fmt.Errorf("creating %s: %w", dir, err)
+	}
+	return filepath.Join(dir, path), nil
+}
+
+// serviceArgs are the arguments to install a service with: the command
+// line without the flags that install or remove it.
+func serviceArgs(args []string) []string {
+	var out []string
+	for _, a := range args {
+		name, _, _ := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		if strings.HasPrefix(a, "-") && (name == "install-service" || name == "uninstall-service") {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// errServiceUnsupported is returned by -install-service and
+// -uninstall-service outside Windows, where the service manager's own
+// unit files do that job.
+var errServiceUnsupported = errors.New("-install-service and -uninstall-service are only for Windows; on Linux, install systemd/mcp-demo-server.service")
+
+func isWindowsService() bool { return false }
+
+func startWindowsService(string, context.CancelFunc) (serviceNotifier, error) {
+	return nil, nil
+}
+
+func installService(string, []string) error { return errServiceUnsupported }
+
+func uninstallService(string) error { return errServiceUnsupported }
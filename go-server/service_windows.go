@@ -0,0 +1,137 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// windowsService runs the server under the Windows service control
+// manager: it reports Running once the server is ready and turns Stop and
+// Shutdown requests into a graceful shutdown.
+type windowsService struct {
+	cancel  context.CancelFunc
+	ready   chan struct{}
+	stopped chan uint32   // the exit code, once the server has stopped
+	exited  chan struct{} // closed when svc.Run returns
+}
+
+func isWindowsService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+func startWindowsService(name string, cancel context.CancelFunc) (serviceNotifier, error) {
+	if !isWindowsService() {
+		return nil, nil
+	}
+	s := &windowsService{cancel: cancel, ready: make(chan struct{}, 1), stopped: make(chan uint32, 1), exited: make(chan struct{})}
+	go func() {
+		defer close(s.exited)
+		if err := svc.Run(name, s); err != nil {
+			log.Printf("Windows service: %v", err)
+			cancel()
+		}
+	}()
+	return s, nil
+}
+
+func (s *windowsService) Execute(_ []string, r <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	for {
+		select {
+		case <-s.ready:
+			status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				status <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				s.cancel()
+			}
+		case code := <-s.stopped:
+			return code != 0, code
+		}
+	}
+}
+
+func (s *windowsService) Ready(string) {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (s *windowsService) Stopping() {}
+
+// Stopped waits for the service control manager to see the service stop.
+func (s *windowsService) Stopped(err error) {
+	var code uint32
+	if err != nil {
+		code = 1
+	}
+	s.stopped <- code
+	<-s.exited
+}
+
+// installService registers the executable as an automatically started
+// service run with args, restarted if it fails.
+func installService(name string, args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.Abs(exe); err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists", name)
+	}
+	s, err := m.CreateService(name, exe, mgr.Config{
+		DisplayName: "MCP demo server (" + name + ")",
+		Description: "MCP demo server " + version,
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	// Restart after 5s, 30s, then every minute; failures are forgotten
+	// after a day without one.
+	return s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: time.Minute},
+	}, uint32((24 * time.Hour).Seconds()))
+}
+
+// uninstallService stops the service if it runs and removes it.
+func uninstallService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s: %w", name, err)
+	}
+	defer s.Close()
+	s.Control(svc.Stop)
+	return s.Delete()
+}
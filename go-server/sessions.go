@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Session table and admin endpoint ---------- */
+
+// sessionIDHeader carries the Streamable HTTP session ID.
+const sessionIDHeader = "Mcp-Session-Id"
+
+// sessionTable tracks the live MCP sessions for the admin endpoint and
+// the -max-sessions limit. Sessions are added on their first request and
+// removed when they close: by the client, after -session-idle-timeout or
+// from the admin endpoint.
+type sessionTable struct {
+	mu       sync.Mutex
+	sessions map[string]*sessionEntry
+	rejected int
+}
+
+// sessionEntry describes one session. Remote address and user agent come
+// from the session's latest HTTP request.
+type sessionEntry struct {
+	ID            string    `json:"id"`
+	Transport     string    `json:"transport"`
+	ClientName    string    `json:"client_name,omitempty"`
+	ClientVersion string    `json:"client_version,omitempty"`
+	Protocol      string    `json:"protocol_version,omitempty"`
+	RemoteAddr    string    `json:"remote_addr,omitempty"`
+	UserAgent     string    `json:"user_agent,omitempty"`
+	Started       time.Time `json:"started"`
+	LastActivity  time.Time `json:"last_activity"`
+	LastMethod    string    `json:"last_method,omitempty"`
+	Requests      int       `json:"requests"`
+	ToolCalls     int       `json:"tool_calls"`
+	Features      []string  `json:"features,omitempty"` // experimental features negotiated
+
+	session *mcp.ServerSession
+}
+
+var liveSessions = &sessionTable{sessions: make(map[string]*sessionEntry)}
+
+// sessionLimits are configured from -session-idle-timeout and
+// -max-sessions; zero disables each.
+var sessionLimits = struct {
+	IdleTimeout time.Duration
+	Max         int
+}{IdleTimeout: 30 * time.Minute}
+
+// sessionMiddleware records every request a session sends.
+func sessionMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		if ss, ok := req.GetSession().(*mcp.ServerSession); ok {
+			liveSessions.touch(ss, method)
+		}
+		return next(ctx, method, req)
+	}
+}
+
+func (t *sessionTable) touch(ss *mcp.ServerSession, method string) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e := t.entryLocked(ss, now)
+	if e.ClientName == "" {
+		if params := ss.InitializeParams(); params != nil {
+			if params.ClientInfo != nil {
+				e.ClientName, e.ClientVersion = params.ClientInfo.Name, params.ClientInfo.Version
+			}
+			e.Protocol = params.ProtocolVersion
+			e.Features = sessionFeatures(ss)
+		}
+	}
+	e.LastActivity = now
+	e.LastMethod = method
+	e.Requests++
+	if method == "tools/call" {
+		e.ToolCalls++
+	}
+}
+
+// entryLocked returns the entry of ss, adding it if needed.
+func (t *sessionTable) entryLocked(ss *mcp.ServerSession, now time.Time) *sessionEntry {
+	if e := t.sessions[ss.ID()]; e != nil {
+		return e
+	}
+	e := &sessionEntry{ID: ss.ID(), Transport: "in-memory", Started: now, LastActivity: now, session: ss}
+	if ss.ID() == stdioSessionID {
+		e.Transport = "stdio"
+	}
+	t.sessions[ss.ID()] = e
+	go func() {
+		ss.Wait()
+		t.remove(ss.ID())
+	}()
+	return e
+}
+
+// open adds a session before its first request, for transports that
+// connect first, such as WebSocket.
+func (t *sessionTable) open(ss *mcp.ServerSession, transport string, r *http.Request) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e := t.entryLocked(ss, time.Now())
+	e.Transport = transport
+	e.RemoteAddr, e.UserAgent = r.RemoteAddr, r.Header.Get("User-Agent")
+	if publicDemo != nil {
+		e.RemoteAddr, e.UserAgent = anonymizeAddr(r.RemoteAddr), ""
+	}
+}
+
+// noteHTTP records the address and user agent of a session's request.
+func (t *sessionTable) noteHTTP(id string, r *http.Request) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e := t.sessions[id]; e != nil {
+		e.Transport = "http"
+		e.RemoteAddr, e.UserAgent = r.RemoteAddr, r.Header.Get("User-Agent")
+		if publicDemo != nil {
+			e.RemoteAddr, e.UserAgent = anonymizeAddr(r.RemoteAddr), ""
+		}
+	}
+}
+
+// remote returns the address of a session's latest HTTP request.
+func (t *sessionTable) remote(id string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e := t.sessions[id]; e != nil {
+		return e.RemoteAddr
+	}
+	return ""
+}
+
+func (t *sessionTable) remove(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.sessions, id)
+}
+
+// ids returns the IDs of the open sessions.
+func (t *sessionTable) ids() map[string]bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ids := make(map[string]bool, len(t.sessions))
+	for id := range t.sessions {
+		ids[id] = true
+	}
+	return ids
+}
+
+// countHTTP returns the number of sessions over Streamable HTTP or
+// WebSocket, leaving out in-memory ones and the REST gateway's.
+func (t *sessionTable) countHTTP() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for _, e := range t.sessions {
+		if e.Transport == "http" || e.Transport == "websocket" {
+			n++
+		}
+	}
+	return n
+}
+
+// list returns the sessions, most recently active first.
+func (t *sessionTable) list() []sessionEntry {
+	t.mu.Lock()
+	out := make([]sessionEntry, 0, len(t.sessions))
+	for _, e := range t.sessions {
+		out = append(out, *e)
+	}
+	t.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].LastActivity.After(out[j].LastActivity) })
+	return out
+}
+
+// close ends a session, reporting whether it was found.
+func (t *sessionTable) close(id string) bool {
+	t.mu.Lock()
+	e := t.sessions[id]
+	t.mu.Unlock()
+	if e == nil {
+		return false
+	}
+	e.session.Close()
+	return true
+}
+
+// refuseSession answers 503 and reports true while -max-sessions
+// sessions are open.
+func refuseSession(w http.ResponseWriter, r *http.Request) bool {
+	if sessionLimits.Max <= 0 || liveSessions.countHTTP() < sessionLimits.Max {
+		return false
+	}
+	liveSessions.mu.Lock()
+	liveSessions.rejected++
+	liveSessions.mu.Unlock()
+	client := r.RemoteAddr
+	if publicDemo != nil {
+		client = anonymizeAddr(client)
+	}
+	log.Printf("[SESSIONS] Refused a new session from %s: %d sessions open (max %d)", client, liveSessions.countHTTP(), sessionLimits.Max)
+	w.Header().Set("Retry-After", "30")
+	http.Error(w, "too many sessions; retry later", http.StatusServiceUnavailable)
+	return true
+}
+
+// limitSessions refuses new sessions beyond -max-sessions with 503 and
+// notes each session's HTTP details.
+func limitSessions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(sessionIDHeader)
+		if id == "" && r.Method == http.MethodPost && refuseSession(w, r) {
+			return
+		}
+		if id != "" {
+			liveSessions.noteHTTP(id, r)
+		}
+		next.ServeHTTP(w, r)
+		if id == "" {
+			// The SDK assigned the new session's ID in the response.
+			if id = w.Header().Get(sessionIDHeader); id != "" {
+				liveSessions.noteHTTP(id, r)
+			}
+		}
+	})
+}
+
+// sessionView is an admin listing entry with derived idle times.
+type sessionView struct {
+	sessionEntry
+	AgeSeconds  float64 `json:"age_seconds"`
+	IdleSeconds float64 `json:"idle_seconds"`
+	// ExpiresInSeconds is the time left before -session-idle-timeout
+	// closes an HTTP session; omitted when there is no timeout.
+	ExpiresInSeconds *float64 `json:"expires_in_seconds,omitempty"`
+}
+
+// sessionsHandler serves the session table:
+//
+//	GET    /admin/sessions       list sessions, most recently active first
+//	DELETE /admin/sessions/{id}  close a session
+func sessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if id := r.PathValue("id"); id != "" {
+		if !liveSessions.close(id) {
+			writeGatewayError(w, http.StatusNotFound, "session not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	now := time.Now()
+	list := liveSessions.list()
+	views := make([]sessionView, len(list))
+	for i, e := range list {
+		idle := now.Sub(e.LastActivity)
+		views[i] = sessionView{sessionEntry: e, AgeSeconds: now.Sub(e.Started).Seconds(), IdleSeconds: idle.Seconds()}
+		if sessionLimits.IdleTimeout > 0 && e.Transport == "http" {
+			left := max(sessionLimits.IdleTimeout-idle, 0).Seconds()
+			views[i].ExpiresInSeconds = &left
+		}
+	}
+	writeGatewayJSON(w, http.StatusOK, map[string]any{
+		"count":                len(views),
+		"sessions":             views,
+		"idle_timeout_seconds": sessionLimits.IdleTimeout.Seconds(),
+		"max_sessions":         sessionLimits.Max,
+	})
+}
+
+// requireAdmin serves h only to requests bearing the admin token.
+func requireAdmin(token string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeGatewayError(w, http.StatusUnauthorized, "admin token required")
+			return
+		}
+		h(w, r)
+	}
+}
+
+func collectSessionMetrics(w *metricsWriter) {
+	liveSessions.mu.Lock()
+	defer liveSessions.mu.Unlock()
+	w.family("mcp_sessions_active", "gauge", "Open MCP sessions")
+	w.sample("mcp_sessions_active", float64(len(liveSessions.sessions)))
+	w.family("mcp_sessions_rejected_total", "counter", "New sessions refused by -max-sessions")
+	w.sample("mcp_sessions_rejected_total", float64(liveSessions.rejected))
+}
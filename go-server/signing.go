@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"mcp-demo-server/pkg/signature"
+)
+
+/* ---------- Response signing ---------- */
+
+// signingKey is non-nil when -sign-responses is set; every tools/call
+// result then carries an Ed25519 signature in _meta.
+var signingKey ed25519.PrivateKey
+
+// loadSigningKey reads a PKCS#8 PEM Ed25519 private key, as written by
+// `openssl genpkey -algorithm ed25519`. An empty path generates a key
+// that lasts for this process only.
+func loadSigningKey(path string) (ed25519.PrivateKey, error) {
+	if path == "" {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("want an Ed25519 key, got %T", key)
+	}
+	return priv, nil
+}
+
+func signingPublicKey() signature.PublicKey {
+	return signature.Describe(signingKey.Public().(ed25519.PublicKey))
+}
+
+// signingMiddleware signs tool results on their way out, for sessions
+// that did not turn signatures off. It must be the outermost receiving
+// middleware so that nothing changes a result after it is signed.
+func signingMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		result, err := next(ctx, method, req)
+		if method != "tools/call" || err != nil {
+			return result, err
+		}
+		if ss, ok := req.GetSession().(*mcp.ServerSession); ok && !featureEnabled(ss, featureSignatures) {
+			return result, err
+		}
+		if res, ok := result.(*mcp.CallToolResult); ok {
+			if err := signature.Sign(signingKey, res); err != nil {
+				return nil, fmt.Errorf("signing result: %w", err)
+			}
+		}
+		return result, err
+	}
+}
+
+// addResponseSigning installs the signing middleware and the public key
+// resource.
+func addResponseSigning(server *mcp.Server) {
+	server.AddReceivingMiddleware(signingMiddleware)
+
+	server.AddResource(&mcp.Resource{
+		URI:         signature.ResourceURI,
+		Name:        "signing-key",
+		Title:       "Response signing key",
+		Description: "Ed25519 public key that verifies the signature in each tool result's _meta",
+		MIMEType:    "application/json",
+	}, func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		data, err := json.MarshalIndent(signingPublicKey(), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return &mcp.ReadResourceResult{
+			Contents: []*mcp.ResourceContents{{URI: signature.ResourceURI, MIMEType: "application/json", Text: string(data)}},
+		}, nil
+	})
+}
+
+// signingKeyHandler serves the public key at signature.WellKnownPath.
+func signingKeyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(signingPublicKey())
+}
+
+// logSigning prints the key clients should expect.
+func logSigning(ephemeral bool) {
+	key := signingPublicKey()
+	log.Printf("Response signing: Ed25519 key_id=%s public_key=%s", key.KeyID, key.Key)
+	if ephemeral {
+		log.Printf("Response signing: using a key generated for this run; pass -sign-key to keep it across restarts")
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- SLO burn-rate alerts ---------- */
+
+const (
+	// sloHistory is how many one-minute buckets a tool keeps: the longest
+	// alert window.
+	sloHistory = 6 * 60
+	// defaultSLOMinCalls and defaultLatencyTarget apply when the config
+	// leaves min_calls and latency_target out.
+	defaultSLOMinCalls   = 10
+	defaultLatencyTarget = 0.99
+	// sloEvalInterval is how often alerts are evaluated.
+	sloEvalInterval = 30 * time.Second
+)
+
+// burnAlert fires when the burn rate is above Rate over both windows: the
+// long one shows the budget is really being spent, the short one that it
+// still is, so the alert stops soon after the problem does. Burn rate is
+// the share of bad calls divided by the share the objective allows; at 1
+// the error budget lasts exactly the objective's period. These are the
+// multiwindow alerts of the Google SRE workbook for a 30-day objective:
+// 14.4 spends 2% of the budget in an hour, 6 spends 5% in six hours.
+type burnAlert struct {
+	Severity    string
+	Long, Short time.Duration
+	Rate        float64
+}
+
+var burnAlerts = []burnAlert{
+	{Severity: "critical", Long: time.Hour, Short: 5 * time.Minute, Rate: 14.4},
+	{Severity: "warning", Long: 6 * time.Hour, Short: 30 * time.Minute, Rate: 6},
+}
+
+// burnWindows are the windows reported in mcp_slo_burn_rate.
+var burnWindows = []time.Duration{5 * time.Minute, 30 * time.Minute, time.Hour, 6 * time.Hour}
+
+// sloTracker counts the calls of tools that have objectives, by minute,
+// and raises "slo_burn" events when an alert starts or stops firing.
+type sloTracker struct {
+	config atomic.Pointer[map[string]sloConfig]
+
+	mu     sync.Mutex
+	tools  map[string]*sloSeries
+	firing map[sloAlertKey]bool
+	fired  map[string]int // by severity
+}
+
+// sloSeries is a ring of one-minute buckets.
+type sloSeries struct {
+	buckets [sloHistory]sloBucket
+}
+
+// sloAlertKey identifies an alert of one objective of a tool.
+type sloAlertKey struct {
+	Tool, Objective, Severity string
+}
+
+type sloBucket struct {
+	minute       int64 // Unix minute the counts belong to
+	calls        int
+	failed, slow int
+}
+
+var slos = &sloTracker{
+	tools:  make(map[string]*sloSeries),
+	firing: make(map[sloAlertKey]bool),
+	fired:  make(map[string]int),
+}
+
+// configure swaps in the objectives from the config file. Tools that lost
+// theirs keep their counts until the next evaluation drops them.
+func (t *sloTracker) configure(objectives map[string]sloConfig) {
+	t.config.Store(&objectives)
+}
+
+// objective returns the objectives of tool, if any.
+func (t *sloTracker) objective(tool string) (sloConfig, bool) {
+	p := t.config.Load()
+	if p == nil {
+		return sloConfig{}, false
+	}
+	if c, ok := (*p)[tool]; ok {
+		return c, true
+	}
+	c, ok := (*p)["*"]
+	return c, ok
+}
+
+// sloToolMiddleware counts the calls of tools that have objectives.
+// Calls the client cancelled say nothing about the tool and are left out.
+func sloToolMiddleware(tool *mcp.Tool, next ToolFunc) ToolFunc {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, any, error) {
+		start := time.Now()
+		res, out, err := next(ctx, req)
+		if c, ok := slos.objective(tool.Name); ok && !cancelled(ctx) {
+			elapsed := time.Since(start)
+			slow := c.LatencyMs > 0 && elapsed > time.Duration(c.LatencyMs)*time.Millisecond
+			slos.record(time.Now(), tool.Name, err != nil || (res != nil && res.IsError), slow)
+		}
+		return res, out, err
+	}
+}
+
+func (t *sloTracker) record(now time.Time, tool string, failed, slow bool) {
+	minute := now.Unix() / 60
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.tools[tool]
+	if s == nil {
+		s = &sloSeries{}
+		t.tools[tool] = s
+	}
+	b := &s.buckets[minute%sloHistory]
+	if b.minute != minute {
+		*b = sloBucket{minute: minute}
+	}
+	b.calls++
+	if failed {
+		b.failed++
+	}
+	if slow {
+		b.slow++
+	}
+}
+
+// window sums the buckets of the last d, the current minute included.
+func (s *sloSeries) window(now time.Time, d time.Duration) (calls, failed, slow int) {
+	minute := now.Unix() / 60
+	oldest := minute - int64(d/time.Minute) + 1
+	for i := range s.buckets {
+		if b := &s.buckets[i]; b.minute >= oldest && b.minute <= minute {
+			calls += b.calls
+			failed += b.failed
+			slow += b.slow
+		}
+	}
+	return calls, failed, slow
+}
+
+// sloObjective is one target of a tool: "success" or "latency".
+type sloObjective struct {
+	Name   string
+	Target float64
+}
+
+func (c sloConfig) objectives() []sloObjective {
+	var list []sloObjective
+	if c.SuccessRate > 0 {
+		list = append(list, sloObjective{"success", c.SuccessRate})
+	}
+	if c.LatencyMs > 0 {
+		target := c.LatencyTarget
+		if target == 0 {
+			target = defaultLatencyTarget
+		}
+		list = append(list, sloObjective{"latency", target})
+	}
+	return list
+}
+
+func (c sloConfig) minCalls() int {
+	if c.MinCalls > 0 {
+		return c.MinCalls
+	}
+	return defaultSLOMinCalls
+}
+
+// burnRate is the burn rate of objective o over the last d, and the number
+// of calls it is based on.
+func (s *sloSeries) burnRate(now time.Time, d time.Duration, o sloObjective) (float64, int) {
+	calls, failed, slow := s.window(now, d)
+	if calls == 0 {
+		return 0, 0
+	}
+	bad := failed
+	if o.Name == "latency" {
+		bad = slow
+	}
+	return float64(bad) / float64(calls) / (1 - o.Target), calls
+}
+
+// run evaluates the alerts every interval, for the life of the process.
+func (t *sloTracker) run(interval time.Duration) {
+	for range time.Tick(interval) {
+		for _, e := range t.evaluate(time.Now()) {
+			events.publish(e)
+		}
+	}
+}
+
+// evaluate updates which alerts are firing and returns an event for each
+// that started or stopped.
+func (t *sloTracker) evaluate(now time.Time) []serverEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var found []serverEvent
+	firing := make(map[sloAlertKey]bool)
+	for _, tool := range sortedKeys(t.tools) {
+		s := t.tools[tool]
+		c, ok := t.objective(tool)
+		if !ok {
+			delete(t.tools, tool)
+			continue
+		}
+		for _, o := range c.objectives() {
+			for _, a := range burnAlerts {
+				long, calls := s.burnRate(now, a.Long, o)
+				short, _ := s.burnRate(now, a.Short, o)
+				if calls < c.minCalls() || long < a.Rate || short < a.Rate {
+					continue
+				}
+				key := sloAlertKey{tool, o.Name, a.Severity}
+				firing[key] = true
+				if t.firing[key] {
+					continue
+				}
+				t.fired[a.Severity]++
+				found = append(found, serverEvent{Kind: "slo_burn", Severity: a.Severity,
+					Message: fmt.Sprintf("%s is spending its %s error budget %.1fx too fast over %s (%.1fx over %s; target %s)",
+						tool, o.Name, long, formatWindow(a.Long), short, formatWindow(a.Short), formatTarget(o.Target)),
+					Fields: map[string]any{"tool": tool, "objective": o.Name, "target": o.Target, "state": "firing",
+						"burn_rate_long": long, "burn_rate_short": short, "window_long": formatWindow(a.Long), "window_short": formatWindow(a.Short),
+						"threshold": a.Rate, "calls": calls}})
+			}
+		}
+	}
+	for _, key := range t.firingKeys() {
+		if firing[key] {
+			continue
+		}
+		found = append(found, serverEvent{Kind: "slo_burn", Severity: "info",
+			Message: fmt.Sprintf("%s %s error budget burn is back below the %s alert threshold", key.Tool, key.Objective, key.Severity),
+			Fields:  map[string]any{"tool": key.Tool, "objective": key.Objective, "alert": key.Severity, "state": "resolved"}})
+	}
+	t.firing = firing
+	return found
+}
+
+// firingKeys returns the alerts firing, sorted.
+func (t *sloTracker) firingKeys() []sloAlertKey {
+	keys := make([]sloAlertKey, 0, len(t.firing))
+	for k := range t.firing {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.Tool != b.Tool {
+			return a.Tool < b.Tool
+		}
+		if a.Objective != b.Objective {
+			return a.Objective < b.Objective
+		}
+		return a.Severity < b.Severity
+	})
+	return keys
+}
+
+func formatWindow(d time.Duration) string {
+	if d >= time.Hour {
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	}
+	return fmt.Sprintf("%dm", int(d/time.Minute))
+}
+
+func formatTarget(target float64) string {
+	return fmt.Sprintf("%g%%", target*100)
+}
+
+func logSLO(e serverEvent) {
+	if e.Kind == "slo_burn" {
+		log.Printf("[SLO] %s", e.Message)
+	}
+}
+
+func (t *sloTracker) collectMetrics(w *metricsWriter) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	type row struct {
+		tool string
+		c    sloConfig
+	}
+	var rows []row
+	for _, tool := range sortedKeys(t.tools) {
+		if c, ok := t.objective(tool); ok {
+			rows = append(rows, row{tool, c})
+		}
+	}
+	w.family("mcp_slo_target", "gauge", "Target share of good calls, by tool and objective")
+	for _, r := range rows {
+		for _, o := range r.c.objectives() {
+			w.sample("mcp_slo_target", o.Target, "tool", r.tool, "objective", o.Name)
+		}
+	}
+	w.family("mcp_slo_burn_rate", "gauge", "Rate at which the error budget is spent over the window; 1 spends it exactly over the objective's period")
+	for _, r := range rows {
+		for _, o := range r.c.objectives() {
+			for _, d := range burnWindows {
+				rate, _ := t.tools[r.tool].burnRate(now, d, o)
+				w.sample("mcp_slo_burn_rate", rate, "tool", r.tool, "objective", o.Name, "window", formatWindow(d))
+			}
+		}
+	}
+	w.family("mcp_slo_alerts_firing", "gauge", "Burn-rate alerts firing, by tool, objective and severity")
+	for _, key := range t.firingKeys() {
+		w.sample("mcp_slo_alerts_firing", 1, "tool", key.Tool, "objective", key.Objective, "severity", key.Severity)
+	}
+	w.family("mcp_slo_alerts_total", "counter", "Burn-rate alerts that started firing, by severity")
+	for _, a := range burnAlerts {
+		w.sample("mcp_slo_alerts_total", float64(t.fired[a.Severity]), "severity", a.Severity)
+	}
+}
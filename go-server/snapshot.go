@@ -0,0 +1,463 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/* ---------- Sandbox snapshots ---------- */
+
+const (
+	snapshotSuffix     = ".tar.gz"
+	snapshotTimeLayout = "20060102T150405.000Z"
+)
+
+// fsWrites is held for reading by write_file and for writing while a
+// snapshot is taken or restored, so a snapshot is a point in time.
+var fsWrites sync.RWMutex
+
+// fsChanged is set by write_file and cleared by each snapshot.
+var fsChanged atomic.Bool
+
+// snapshotStore keeps gzipped tar archives of the -fs-root sandbox in Dir,
+// named <time>-<reason>.tar.gz, and removes all but the newest Keep.
+// Regular files, directories and symlinks are archived; other special
+// files are not.
+type snapshotStore struct {
+	Dir  string
+	Keep int
+
+	mu sync.Mutex // one snapshot or restore at a time
+}
+
+// snapshots is non-nil when -snapshot-dir is set.
+var snapshots *snapshotStore
+
+// snapshotInfo describes one archive.
+type snapshotInfo struct {
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason"` // startup, scheduled, manual or pre-restore
+	Bytes  int64     `json:"bytes"`
+}
+
+func newSnapshotStore(dir string, keep int) (*snapshotStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshotStore{Dir: abs, Keep: keep}, nil
+}
+
+// take archives root and prunes old snapshots.
+func (s *snapshotStore) take(root, reason string) (snapshotInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, err := s.takeLocked(root, reason)
+	if err == nil {
+		s.prune()
+	}
+	return info, err
+}
+
+func (s *snapshotStore) takeLocked(root, reason string) (snapshotInfo, error) {
+	now := time.Now().UTC()
+	info := snapshotInfo{ID: now.Format(snapshotTimeLayout) + "-" + reason, Time: now, Reason: reason}
+	tmp, err := os.CreateTemp(s.Dir, ".snapshot-*")
+	if err != nil {
+		return info, err
+	}
+	defer os.Remove(tmp.Name())
+
+	fsWrites.Lock()
+	err = writeSnapshot(tmp, root)
+	if err == nil {
+		fsChanged.Store(false)
+	}
+	fsWrites.Unlock()
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return info, err
+	}
+	if err := os.Rename(tmp.Name(), s.path(info.ID)); err != nil {
+		return info, err
+	}
+	if st, err := os.Stat(s.path(info.ID)); err == nil {
+		info.Bytes = st.Size()
+	}
+	return info, nil
+}
+
+func (s *snapshotStore) path(id string) string {
+	return filepath.Join(s.Dir, id+snapshotSuffix)
+}
+
+// writeSnapshot writes root's files, directories and symlinks as a
+// gzipped tar, leaving out what an interrupted restore left behind.
+// Symlinks are kept only if they resolve within root; absolute ones are
+// stored relative to the link.
+func writeSnapshot(w io.Writer, root string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return err
+		}
+		if d.IsDir() && filepath.Dir(path) == root && strings.HasPrefix(d.Name(), ".restore-") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && !d.Type().IsRegular() && d.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		link := ""
+		if d.Type()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+			resolved, ok := linkWithin(root, path)
+			if !ok {
+				log.Printf("[SNAPSHOTS] Leaving out symlink %s: its target is missing or outside the sandbox", filepath.ToSlash(rel))
+				return nil
+			}
+			if filepath.IsAbs(link) {
+				link, _ = filepath.Rel(filepath.Dir(path), resolved)
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		hdr.Uname, hdr.Gname = "", ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.CopyN(tw, f, hdr.Size)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// list returns the snapshots, newest first.
+func (s *snapshotStore) list() ([]snapshotInfo, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	out := []snapshotInfo{}
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), snapshotSuffix)
+		if !ok || !e.Type().IsRegular() {
+			continue
+		}
+		stamp, reason, _ := strings.Cut(id, "-")
+		t, err := time.Parse(snapshotTimeLayout, stamp)
+		if err != nil {
+			continue
+		}
+		info := snapshotInfo{ID: id, Time: t, Reason: reason}
+		if st, err := e.Info(); err == nil {
+			info.Bytes = st.Size()
+		}
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	return out, nil
+}
+
+// find resolves an ID, or "latest", to a snapshot.
+func (s *snapshotStore) find(id string) (snapshotInfo, error) {
+	list, err := s.list()
+	if err != nil {
+		return snapshotInfo{}, err
+	}
+	for _, info := range list {
+		if info.ID == id || id == "latest" {
+			return info, nil
+		}
+	}
+	return snapshotInfo{}, fmt.Errorf("no snapshot %q", id)
+}
+
+// prune removes all but the newest Keep snapshots.
+func (s *snapshotStore) prune() {
+	list, err := s.list()
+	if err != nil || s.Keep <= 0 || len(list) <= s.Keep {
+		return
+	}
+	for _, info := range list[s.Keep:] {
+		if err := os.Remove(s.path(info.ID)); err != nil {
+			log.Printf("[SNAPSHOTS] Cannot remove %s: %v", info.ID, err)
+		}
+	}
+}
+
+// restore replaces root's contents with a snapshot's, after taking a
+// pre-restore snapshot so the restore itself can be undone. The snapshot
+// is extracted next to the current files first; they are only replaced
+// once it has been read completely.
+func (s *snapshotStore) restore(root, id string) (restored, saved snapshotInfo, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if restored, err = s.find(id); err != nil {
+		return restored, saved, err
+	}
+	if saved, err = s.takeLocked(root, "pre-restore"); err != nil {
+		return restored, saved, fmt.Errorf("pre-restore snapshot: %w", err)
+	}
+
+	f, err := os.Open(s.path(restored.ID))
+	if err != nil {
+		return restored, saved, err
+	}
+	defer f.Close()
+	fsWrites.Lock()
+	defer fsWrites.Unlock()
+	staging, err := os.MkdirTemp(root, ".restore-")
+	if err != nil {
+		return restored, saved, err
+	}
+	defer os.RemoveAll(staging)
+	if err := extractSnapshot(f, staging); err != nil {
+		return restored, saved, fmt.Errorf("reading %s: %w", restored.ID, err)
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return restored, saved, err
+	}
+	for _, e := range entries {
+		if p := filepath.Join(root, e.Name()); p != staging {
+			if err := os.RemoveAll(p); err != nil {
+				return restored, saved, err
+			}
+		}
+	}
+	entries, err = os.ReadDir(staging)
+	if err != nil {
+		return restored, saved, err
+	}
+	for _, e := range entries {
+		if err := os.Rename(filepath.Join(staging, e.Name()), filepath.Join(root, e.Name())); err != nil {
+			return restored, saved, err
+		}
+	}
+	fsChanged.Store(true)
+	return restored, saved, nil
+}
+
+// extractSnapshot unpacks a snapshot into dir, refusing entries that
+// would land outside it. Symlinks are created last, so no file is written
+// through one, and each must resolve within dir.
+func extractSnapshot(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	links := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return extractLinks(links, dir)
+		}
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(hdr.Name, "/")
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("entry %q is outside the sandbox", hdr.Name)
+		}
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+			os.Chtimes(path, hdr.ModTime, hdr.ModTime)
+		case tar.TypeSymlink:
+			if filepath.IsAbs(hdr.Linkname) {
+				return fmt.Errorf("symlink %q has an absolute target", hdr.Name)
+			}
+			links[path] = hdr.Linkname
+		}
+	}
+}
+
+// extractLinks creates the symlinks of a snapshot in dir. Their parent
+// directories are all made first, so that no link is created through
+// another; then every link must resolve within dir.
+func extractLinks(links map[string]string, dir string) error {
+	for path := range links {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+	}
+	for path, target := range links {
+		if err := os.Symlink(target, path); err != nil {
+			return err
+		}
+	}
+	for path := range links {
+		if _, ok := linkWithin(dir, path); !ok {
+			rel, _ := filepath.Rel(dir, path)
+			return fmt.Errorf("symlink %q points outside the sandbox or to nothing", filepath.ToSlash(rel))
+		}
+	}
+	return nil
+}
+
+// linkWithin resolves the symlink at path and reports whether its target
+// exists within root.
+func linkWithin(root, path string) (string, bool) {
+	resolved, err := filepath.EvalSymlinks(path)
+	return resolved, err == nil && pathWithin(root, resolved)
+}
+
+// run takes a scheduled snapshot every interval when write_file has
+// changed the sandbox since the last one.
+func (s *snapshotStore) run(root string, interval time.Duration) {
+	for range time.Tick(interval) {
+		if !fsChanged.Load() {
+			continue
+		}
+		if _, err := s.take(root, "scheduled"); err != nil {
+			log.Printf("[SNAPSHOTS] Scheduled snapshot failed: %v", err)
+		}
+	}
+}
+
+// snapshotsHandler serves the snapshots of the running server:
+//
+//	GET  /admin/snapshots               list snapshots, newest first
+//	POST /admin/snapshots               take one now
+//	POST /admin/snapshots/{id}/restore  roll the sandbox back to one ("latest" works)
+func snapshotsHandler(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.PathValue("id") != "":
+		restored, saved, err := snapshots.restore(fsSandbox.Root, r.PathValue("id"))
+		if err != nil {
+			writeGatewayError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		log.Printf("[SNAPSHOTS] Restored %s; the previous state is %s", restored.ID, saved.ID)
+		writeGatewayJSON(w, http.StatusOK, map[string]any{"restored": restored, "pre_restore": saved})
+	case r.Method == http.MethodPost:
+		info, err := snapshots.take(fsSandbox.Root, "manual")
+		if err != nil {
+			writeGatewayError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeGatewayJSON(w, http.StatusCreated, info)
+	default:
+		list, err := snapshots.list()
+		if err != nil {
+			writeGatewayError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeGatewayJSON(w, http.StatusOK, map[string]any{"count": len(list), "snapshots": list})
+	}
+}
+
+// runRestoreCommand implements "restore -snapshot-dir dir -fs-root dir
+// [id|latest]", which lists the snapshots when no ID is given. Run it
+// while the server is stopped; a running server restores through POST
+// /admin/snapshots/{id}/restore.
+func runRestoreCommand(args []string) int {
+	fset := flag.NewFlagSet("restore", flag.ContinueOnError)
+	dir := fset.String("snapshot-dir", "", "Directory of the snapshots (the server's -snapshot-dir)")
+	root := fset.String("fs-root", "", "Sandbox directory to roll back (the server's -fs-root)")
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), "usage: restore -snapshot-dir dir [-fs-root dir id|latest]\n\nLists the snapshots, or replaces the contents of -fs-root with a snapshot's\nafter saving the current contents as a pre-restore snapshot.")
+		fset.PrintDefaults()
+	}
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+	if *dir == "" || fset.NArg() > 1 || (fset.NArg() == 1 && *root == "") {
+		fset.Usage()
+		return 2
+	}
+	store := &snapshotStore{Dir: *dir}
+	if fset.NArg() == 0 {
+		list, err := store.list()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "restore: %v\n", err)
+			return 1
+		}
+		for _, info := range list {
+			fmt.Printf("%-40s %s  %s\n", info.ID, info.Time.Local().Format(time.DateTime), formatBytes(info.Bytes))
+		}
+		return 0
+	}
+	sb, err := newSandbox(*root, false)
+	if err == nil {
+		var restored, saved snapshotInfo
+		restored, saved, err = store.restore(sb.Root, fset.Arg(0))
+		if err == nil {
+			fmt.Printf("Restored %s into %s; the previous contents are snapshot %s\n", restored.ID, sb.Root, saved.ID)
+			return 0
+		}
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		err = fmt.Errorf("%v (check -snapshot-dir and -fs-root)", err)
+	}
+	fmt.Fprintf(os.Stderr, "restore: %v\n", err)
+	return 1
+}
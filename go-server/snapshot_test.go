@@ -0,0 +1,117 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testArchive builds a snapshot archive from entries: a name ending in
+// "/" is a directory, a target starting with "->" a symlink, anything
+// else a file with that content.
+func testArchive(t *testing.T, entries [][2]string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e[0], Mode: 0o644}
+		switch {
+		case e[0][len(e[0])-1] == '/':
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0o755
+		case len(e[1]) > 2 && e[1][:2] == "->":
+			hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, e[1][2:]
+		default:
+			hdr.Typeflag, hdr.Size = tar.TypeReg, int64(len(e[1]))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			tw.Write([]byte(e[1]))
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return &buf
+}
+
+func TestExtractSnapshotSymlinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries [][2]string
+		ok      bool
+	}{
+		{"link inside", [][2]string{{"data/", ""}, {"data/a.txt", "a"}, {"docs/a", "->../data/a.txt"}}, true},
+		{"link to a directory", [][2]string{{"data/", ""}, {"current", "->data"}}, true},
+		{"absolute link", [][2]string{{"link", "->/etc/passwd"}}, false},
+		{"link up and out", [][2]string{{"link", "->../outside"}}, false},
+		{"link out through another link", [][2]string{{"y", "->."}, {"z", "->y/../outside"}}, false},
+		{"dangling link", [][2]string{{"link", "->missing"}}, false},
+		{"link under a link", [][2]string{{"d", "->."}, {"d/z", "->../x"}}, false},
+		{"entry outside", [][2]string{{"../x", "x"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := t.TempDir()
+			dir := filepath.Join(parent, "staging")
+			if err := os.Mkdir(dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			err := extractSnapshot(testArchive(t, tt.entries), dir)
+			if tt.ok && err != nil {
+				t.Errorf("extract: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("extract succeeded")
+			}
+			if entries, _ := os.ReadDir(parent); len(entries) != 1 {
+				t.Errorf("extract created %d entries next to the staging directory", len(entries)-1)
+			}
+		})
+	}
+}
+
+func TestWriteSnapshotSymlinks(t *testing.T) {
+	root, outside := testSandbox(t)
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for name, target := range map[string]string{
+		"relative": "a.txt",
+		"absolute": filepath.Join(root, "a.txt"),
+		"outside":  outside,
+		"dangling": "missing",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skipf("cannot create symlinks: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeSnapshot(&buf, root); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		if hdr.Typeflag == tar.TypeSymlink {
+			links[hdr.Name] = hdr.Linkname
+		}
+	}
+	want := map[string]string{"relative": "a.txt", "absolute": "a.txt"}
+	if len(links) != len(want) || links["relative"] != want["relative"] || links["absolute"] != want["absolute"] {
+		t.Errorf("snapshot symlinks = %v, want %v", links, want)
+	}
+}
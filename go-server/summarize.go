@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Tool: summarize_url ---------- */
+
+const (
+	// maxSummaryInputBytes caps the page text sent to the client's model.
+	maxSummaryInputBytes = 32 << 10
+	// defaultSummaryMaxTokens is the sampling budget when max_tokens is unset.
+	defaultSummaryMaxTokens = 512
+	// summarizeTimeout bounds the fetch and the sampling request together;
+	// the client may wait for a person to approve the request.
+	summarizeTimeout = 2 * time.Minute
+)
+
+const summarizeSystemPrompt = "You summarize web pages for a user who has not read them. " +
+	"Use only the page content given; say so when it is empty or not what was expected. " +
+	"Answer in plain prose or short bullet points, without preamble."
+
+type SummarizeURLArgs struct {
+	URL       string `json:"url" jsonschema:"Page to summarize (must be http or https)"`
+	Focus     string `json:"focus,omitempty" jsonschema:"What the summary should concentrate on, e.g. 'pricing' or 'breaking changes'"`
+	MaxTokens int    `json:"max_tokens,omitempty" jsonschema:"Token budget for the summary (default 512)"`
+}
+
+// SummarizeURLResult is the structured output of the summarize_url tool.
+type SummarizeURLResult struct {
+	URL            string `json:"url"`
+	ContentType    string `json:"content_type"`
+	InputBytes     int    `json:"input_bytes" jsonschema:"Bytes of page text sent to the model"`
+	InputTruncated bool   `json:"input_truncated" jsonschema:"The page text was cut to fit the model request"`
+	Model          string `json:"model,omitempty" jsonschema:"Model reported by the sampling client"`
+	StopReason     string `json:"stop_reason,omitempty"`
+	Summary        string `json:"summary"`
+	ElapsedMs      int64  `json:"elapsed_ms"`
+}
+
+// SummarizeURLTool fetches a page, converts it to text the way fetch does
+// and asks the calling client's model for a summary via sampling: the
+// server does the fetching, the client's LLM does the reading.
+func SummarizeURLTool(ctx context.Context, req *mcp.CallToolRequest, in SummarizeURLArgs) (*mcp.CallToolResult, any, error) {
+	if in.URL == "" {
+		return errorResult("URL is required"), nil, nil
+	}
+	// Fail before fetching when the answer would be thrown away.
+	if err := checkSampling(req); err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+	maxTokens := in.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultSummaryMaxTokens
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, summarizeTimeout)
+	defer cancel()
+	// Progress is reported per step: fetched, then summarized.
+	progress := progressFrom(ctx)
+	ctx = withoutTransferProgress(ctx)
+	start := time.Now()
+
+	data, contentType, err := fetchQueryDocument(ctx, in.URL)
+	if err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+	text, err := pageText(data, contentType)
+	if err != nil {
+		return errorResult(fmt.Sprintf("%s: %v", in.URL, err)), nil, nil
+	}
+	out := SummarizeURLResult{URL: in.URL, ContentType: contentType}
+	if len(text) > maxSummaryInputBytes {
+		text = string(truncateUTF8([]byte(text), maxSummaryInputBytes))
+		out.InputTruncated = true
+	}
+	out.InputBytes = len(text)
+	progress.notify(1, 2, fmt.Sprintf("fetched %s (%d bytes of text); asking the client's model for a summary", in.URL, out.InputBytes))
+
+	res, err := req.Session.CreateMessage(ctx, &mcp.CreateMessageParams{
+		MaxTokens:    int64(maxTokens),
+		SystemPrompt: summarizeSystemPrompt,
+		Messages: []*mcp.SamplingMessage{{
+			Role:    "user",
+			Content: &mcp.TextContent{Text: summarizePrompt(in, text, out.InputTruncated)},
+		}},
+		ModelPreferences: &mcp.ModelPreferences{SpeedPriority: 0.7, CostPriority: 0.5, IntelligencePriority: 0.3},
+		IncludeContext:   "none",
+	})
+	if err != nil {
+		return errorResult("Sampling failed: " + err.Error()), nil, nil
+	}
+	summary, ok := res.Content.(*mcp.TextContent)
+	if !ok {
+		return errorResult(fmt.Sprintf("Sampling returned non-text %T content", res.Content)), nil, nil
+	}
+	out.Model, out.StopReason = res.Model, res.StopReason
+	out.Summary = strings.TrimSpace(summary.Text)
+	out.ElapsedMs = time.Since(start).Milliseconds()
+	progress.notify(2, 2, "summarized by "+out.Model)
+
+	header := fmt.Sprintf("Summary of %s", out.URL)
+	if out.Model != "" {
+		header += " (by " + out.Model + ")"
+	}
+	if out.InputTruncated {
+		header += fmt.Sprintf(", from the first %d bytes of the page", out.InputBytes)
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: header + ":\n\n" + out.Summary}},
+	}, out, nil
+}
+
+// pageText turns a fetched body into text for the model: HTML as
+// markdown, JSON, XML and CSV through their fetch adapters and other
+// text types as they are.
+func pageText(data []byte, contentType string) (string, error) {
+	adapter, mediaType := adapterFor(contentType)
+	switch {
+	case adapter != nil && adapter.Name != "image":
+		res, err := adapter.Adapt(data, mediaType, extractMarkdown)
+		if err != nil {
+			return "", fmt.Errorf("%s adapter failed: %v", adapter.Name, err)
+		}
+		return res.Text, nil
+	case mediaType == "" || strings.HasPrefix(mediaType, "text/"):
+		return string(data), nil
+	}
+	return "", fmt.Errorf("cannot summarize %s content", mediaType)
+}
+
+// summarizePrompt is the user message of the sampling request.
+func summarizePrompt(in SummarizeURLArgs, text string, truncated bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Summarize the page at %s.", in.URL)
+	if focus := strings.TrimSpace(in.Focus); focus != "" {
+		fmt.Fprintf(&b, " Concentrate on: %s.", focus)
+	}
+	if truncated {
+		b.WriteString(" The page was cut off; only its beginning is included.")
+	}
+	b.WriteString("\n\n<page>\n")
+	b.WriteString(text)
+	b.WriteString("\n</page>")
+	return b.String()
+}
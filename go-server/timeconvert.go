@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Tool: time_convert ---------- */
+
+const (
+	// maxDSTTransitions bounds dst_transitions.
+	maxDSTTransitions = 10
+	// dstSearchYears is how far ahead transitions are searched for.
+	dstSearchYears = 5
+)
+
+// timeLayouts are tried in order for timestamps without a zone offset;
+// RFC 3339 with an offset is handled first.
+var timeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// durationPart matches one signed component of a calendar duration such
+// as "-1d", "2w" or "1mo".
+var durationPart = regexp.MustCompile(`([+-]?)(\d+)(y|mo|w|d|h|m|s|ms)`)
+
+type TimeConvertArgs struct {
+	Time           string `json:"time,omitempty" jsonschema:"Timestamp: RFC 3339, 'YYYY-MM-DD[ HH:MM[:SS]]', Unix seconds, or 'now' (default)"`
+	From           string `json:"from,omitempty" jsonschema:"IANA timezone for timestamps without an offset (default: the session default from set_defaults, else UTC)"`
+	To             string `json:"to,omitempty" jsonschema:"IANA timezone to convert to (default: same as from)"`
+	Add            string `json:"add,omitempty" jsonschema:"Duration to add, e.g. '90m', '1d2h', '-2w', '1mo' (y, mo, w, d, h, m, s, ms); days and larger follow the calendar"`
+	DiffTo         string `json:"diff_to,omitempty" jsonschema:"Second timestamp; the result includes the difference from the converted time to it"`
+	DSTTransitions int    `json:"dst_transitions,omitempty" jsonschema:"List this many upcoming DST/offset transitions in the target zone (max 10)"`
+}
+
+// TimeDiff is the difference reported for diff_to.
+type TimeDiff struct {
+	Seconds int64  `json:"seconds" jsonschema:"diff_to minus the result time, in seconds"`
+	Human   string `json:"human" jsonschema:"The same difference as days, hours, minutes and seconds"`
+}
+
+// DSTTransition is one change of UTC offset in the target zone.
+type DSTTransition struct {
+	At         string `json:"at" jsonschema:"Instant of the change, RFC 3339 in UTC"`
+	FromOffset string `json:"from_offset"`
+	ToOffset   string `json:"to_offset"`
+	FromAbbrev string `json:"from_abbrev"`
+	ToAbbrev   string `json:"to_abbrev"`
+}
+
+// TimeConvertResult is the structured output of the time_convert tool.
+type TimeConvertResult struct {
+	Input          string          `json:"input"`
+	Source         string          `json:"source" jsonschema:"Parsed input time in the from zone, RFC 3339"`
+	Result         string          `json:"result" jsonschema:"Converted (and shifted) time in the to zone, RFC 3339"`
+	Timezone       string          `json:"timezone"`
+	Abbrev         string          `json:"abbrev" jsonschema:"Zone abbreviation at the result time, e.g. CEST"`
+	UTCOffset      string          `json:"utc_offset"`
+	IsDST          bool            `json:"is_dst"`
+	Weekday        string          `json:"weekday"`
+	Unix           int64           `json:"unix"`
+	Added          string          `json:"added,omitempty"`
+	Difference     *TimeDiff       `json:"difference,omitempty"`
+	DSTTransitions []DSTTransition `json:"dst_transitions,omitempty"`
+}
+
+// parseTimestamp interprets s in loc unless it carries its own offset.
+func parseTimestamp(s string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.EqualFold(s, "now") {
+		return time.Now().In(loc), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	if unix, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(unix, 0).In(loc), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q (use RFC 3339, YYYY-MM-DD[ HH:MM[:SS]] or Unix seconds)", s)
+}
+
+// addCalendarDuration applies a duration like "1mo-2d3h". Years, months,
+// weeks and days use AddDate so they keep the wall-clock time across DST
+// changes; smaller units add exact elapsed time.
+func addCalendarDuration(t time.Time, s string) (time.Time, error) {
+	compact := strings.ReplaceAll(s, " ", "")
+	matches := durationPart.FindAllStringSubmatchIndex(compact, -1)
+	covered := 0
+	for _, m := range matches {
+		if m[0] != covered {
+			break
+		}
+		covered = m[1]
+	}
+	if len(matches) == 0 || covered != len(compact) {
+		return t, fmt.Errorf("invalid duration %q (e.g. 90m, 1d2h, -2w, 1mo)", s)
+	}
+
+	sign := 1
+	for _, m := range matches {
+		if compact[m[2]:m[3]] == "-" {
+			sign = -1
+		} else if compact[m[2]:m[3]] == "+" {
+			sign = 1
+		}
+		n, err := strconv.Atoi(compact[m[4]:m[5]])
+		if err != nil {
+			return t, err
+		}
+		n *= sign
+		switch compact[m[6]:m[7]] {
+		case "y":
+			t = t.AddDate(n, 0, 0)
+		case "mo":
+			t = t.AddDate(0, n, 0)
+		case "w":
+			t = t.AddDate(0, 0, 7*n)
+		case "d":
+			t = t.AddDate(0, 0, n)
+		case "h":
+			t = t.Add(time.Duration(n) * time.Hour)
+		case "m":
+			t = t.Add(time.Duration(n) * time.Minute)
+		case "s":
+			t = t.Add(time.Duration(n) * time.Second)
+		case "ms":
+			t = t.Add(time.Duration(n) * time.Millisecond)
+		}
+	}
+	return t, nil
+}
+
+// humanDuration formats d as e.g. "-2d 3h 4m 5s".
+func humanDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	d = d.Round(time.Second)
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	h, m, s := d/time.Hour, (d%time.Hour)/time.Minute, (d%time.Minute)/time.Second
+	var parts []string
+	if days > 0 {
+		parts = append(parts, fmt.Sprintf("%dd", days))
+	}
+	if h > 0 || days > 0 {
+		parts = append(parts, fmt.Sprintf("%dh", h))
+	}
+	if m > 0 || h > 0 || days > 0 {
+		parts = append(parts, fmt.Sprintf("%dm", m))
+	}
+	parts = append(parts, fmt.Sprintf("%ds", s))
+	return sign + strings.Join(parts, " ")
+}
+
+// formatOffset renders a UTC offset in seconds as ±HH:MM.
+func formatOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign, seconds = '-', -seconds
+	}
+	return fmt.Sprintf("%c%02d:%02d", sign, seconds/3600, seconds%3600/60)
+}
+
+// dstTransitions finds the next n offset changes in loc after t by
+// scanning day by day and bisecting to the second.
+func dstTransitions(t time.Time, loc *time.Location, n int) []DSTTransition {
+	var out []DSTTransition
+	end := t.AddDate(dstSearchYears, 0, 0)
+	cur := t.In(loc)
+	for len(out) < n && cur.Before(end) {
+		next := cur.Add(24 * time.Hour)
+		_, curOff := cur.Zone()
+		_, nextOff := next.Zone()
+		if curOff == nextOff {
+			cur = next
+			continue
+		}
+		lo, hi := cur, next
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2)
+			if _, off := mid.Zone(); off == curOff {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		fromAbbrev, _ := lo.Zone()
+		toAbbrev, toOff := hi.Zone()
+		out = append(out, DSTTransition{
+			At:         hi.UTC().Truncate(time.Second).Format(time.RFC3339),
+			FromOffset: formatOffset(curOff),
+			ToOffset:   formatOffset(toOff),
+			FromAbbrev: fromAbbrev,
+			ToAbbrev:   toAbbrev,
+		})
+		cur = hi
+	}
+	return out
+}
+
+func TimeConvertTool(ctx context.Context, req *mcp.CallToolRequest, in TimeConvertArgs) (*mcp.CallToolResult, any, error) {
+	from, err := sessionLocation(req, in.From, time.UTC)
+	if err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+	to := from
+	if in.To != "" {
+		if to, err = time.LoadLocation(in.To); err != nil {
+			return errorResult(fmt.Sprintf("invalid timezone %q: %v", in.To, err)), nil, nil
+		}
+	}
+	if in.DSTTransitions < 0 || in.DSTTransitions > maxDSTTransitions {
+		return errorResult(fmt.Sprintf("dst_transitions must be between 0 and %d", maxDSTTransitions)), nil, nil
+	}
+
+	src, err := parseTimestamp(in.Time, from)
+	if err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+	// Calendar arithmetic happens in the target zone, so "1d" means the
+	// same wall-clock time tomorrow there.
+	result := src.In(to)
+	if in.Add != "" {
+		if result, err = addCalendarDuration(result, in.Add); err != nil {
+			return errorResult(err.Error()), nil, nil
+		}
+	}
+
+	abbrev, offset := result.Zone()
+	input := in.Time
+	if input == "" {
+		input = "now"
+	}
+	out := TimeConvertResult{
+		Input:     input,
+		Source:    src.Format(time.RFC3339Nano),
+		Result:    result.Format(time.RFC3339Nano),
+		Timezone:  to.String(),
+		Abbrev:    abbrev,
+		UTCOffset: formatOffset(offset),
+		IsDST:     result.IsDST(),
+		Weekday:   result.Weekday().String(),
+		Unix:      result.Unix(),
+		Added:     in.Add,
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "source=%s\nresult=%s (tz=%s %s, %s)\nweekday=%s\nunix=%d",
+		out.Source, out.Result, out.Timezone, out.Abbrev, out.UTCOffset, out.Weekday, out.Unix)
+	if in.Add != "" {
+		fmt.Fprintf(&b, "\nadded=%s", in.Add)
+	}
+
+	if in.DiffTo != "" {
+		other, err := parseTimestamp(in.DiffTo, from)
+		if err != nil {
+			return errorResult("diff_to: " + err.Error()), nil, nil
+		}
+		d := other.Sub(result)
+		out.Difference = &TimeDiff{Seconds: int64(d / time.Second), Human: humanDuration(d)}
+		fmt.Fprintf(&b, "\ndiff_to=%s\ndifference=%s (%d seconds)", other.Format(time.RFC3339), out.Difference.Human, out.Difference.Seconds)
+	}
+
+	if in.DSTTransitions > 0 {
+		out.DSTTransitions = dstTransitions(result, to, in.DSTTransitions)
+		if len(out.DSTTransitions) == 0 {
+			fmt.Fprintf(&b, "\nno offset transitions in %s within %d years", out.Timezone, dstSearchYears)
+		}
+		for _, tr := range out.DSTTransitions {
+			fmt.Fprintf(&b, "\ntransition %s: %s (%s) -> %s (%s)", tr.At, tr.FromAbbrev, tr.FromOffset, tr.ToAbbrev, tr.ToOffset)
+		}
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: b.String()}},
+	}, out, nil
+}
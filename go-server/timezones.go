@@ -0,0 +1,181 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Tool: list_timezones ---------- */
+
+const (
+	defaultTimezoneLimit = 50
+	maxTimezoneLimit     = 600
+)
+
+// zoneinfoDirs are searched in order for the IANA database, after
+// $ZONEINFO (a directory or a zip such as Go's lib/time/zoneinfo.zip).
+var zoneinfoDirs = []string{
+	"/usr/share/zoneinfo",
+	"/usr/share/lib/zoneinfo",
+	"/usr/lib/locale/TZ",
+	"/etc/zoneinfo",
+}
+
+// zoneName accepts database entries that are timezone names, skipping
+// files such as zone.tab, leapseconds or posixrules.
+var zoneName = regexp.MustCompile(`^[A-Z][A-Za-z0-9_+-]*(/[A-Za-z0-9_+-]+)*$`)
+
+// timezoneNames lists the available IANA names once per process.
+var timezoneNames = sync.OnceValues(loadTimezoneNames)
+
+func loadTimezoneNames() ([]string, error) {
+	if env := os.Getenv("ZONEINFO"); strings.HasSuffix(env, ".zip") {
+		if names := zoneNamesFromZip(env); len(names) > 0 {
+			return names, nil
+		}
+	} else if env != "" {
+		if names := zoneNamesFromDir(env); len(names) > 0 {
+			return names, nil
+		}
+	}
+	for _, dir := range zoneinfoDirs {
+		if names := zoneNamesFromDir(dir); len(names) > 0 {
+			return names, nil
+		}
+	}
+	return nil, fmt.Errorf("no timezone database found (looked in $ZONEINFO and %s)", strings.Join(zoneinfoDirs, ", "))
+}
+
+// zoneNamesFromDir walks a zoneinfo tree, keeping files that start with
+// the TZif magic.
+func zoneNamesFromDir(dir string) []string {
+	var names []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			// posix/ and right/ duplicate the whole database.
+			if rel == "posix" || rel == "right" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if zoneName.MatchString(rel) && rel != "Factory" && isTZif(path) {
+			names = append(names, rel)
+		}
+		return nil
+	})
+	sort.Strings(names)
+	return names
+}
+
+func isTZif(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	_, err = io.ReadFull(f, magic)
+	return err == nil && string(magic) == "TZif"
+}
+
+func zoneNamesFromZip(path string) []string {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+	var names []string
+	for _, f := range r.File {
+		if zoneName.MatchString(f.Name) && f.Name != "Factory" {
+			names = append(names, f.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+type ListTimezonesArgs struct {
+	Query string `json:"query,omitempty" jsonschema:"Case-insensitive text to look for, e.g. Kyiv, new york or America/ (default: all)"`
+	Limit int    `json:"limit,omitempty" jsonschema:"Maximum zones to return (default 50, max 600)"`
+}
+
+// TimezoneInfo is one matching zone with its current offset.
+type TimezoneInfo struct {
+	Name      string `json:"name"`
+	UTCOffset string `json:"utc_offset" jsonschema:"Current offset from UTC as +HH:MM"`
+	Abbrev    string `json:"abbrev" jsonschema:"Current zone abbreviation"`
+}
+
+// ListTimezonesResult is the structured output of the list_timezones tool.
+type ListTimezonesResult struct {
+	Query     string         `json:"query,omitempty"`
+	Total     int            `json:"total" jsonschema:"Number of matching zones, before limit"`
+	Truncated bool           `json:"truncated"`
+	Zones     []TimezoneInfo `json:"zones"`
+}
+
+func ListTimezonesTool(ctx context.Context, req *mcp.CallToolRequest, in ListTimezonesArgs) (*mcp.CallToolResult, any, error) {
+	limit := in.Limit
+	if limit <= 0 {
+		limit = defaultTimezoneLimit
+	}
+	if limit > maxTimezoneLimit {
+		return errorResult(fmt.Sprintf("limit must be at most %d", maxTimezoneLimit)), nil, nil
+	}
+	names, err := timezoneNames()
+	if err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+
+	// "new york" should find America/New_York.
+	query := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(in.Query), " ", "_"))
+	out := ListTimezonesResult{Query: in.Query, Zones: []TimezoneInfo{}}
+	now := time.Now()
+	for _, name := range names {
+		if query != "" && !strings.Contains(strings.ToLower(name), query) {
+			continue
+		}
+		out.Total++
+		if len(out.Zones) == limit {
+			out.Truncated = true
+			continue
+		}
+		info := TimezoneInfo{Name: name}
+		if loc, err := time.LoadLocation(name); err == nil {
+			abbrev, offset := now.In(loc).Zone()
+			info.Abbrev, info.UTCOffset = abbrev, formatOffset(offset)
+		}
+		out.Zones = append(out.Zones, info)
+	}
+
+	var b strings.Builder
+	if out.Total == 0 {
+		fmt.Fprintf(&b, "no timezones match %q", in.Query)
+	}
+	for _, z := range out.Zones {
+		fmt.Fprintf(&b, "%s (%s, %s)\n", z.Name, z.UTCOffset, z.Abbrev)
+	}
+	if out.Truncated {
+		fmt.Fprintf(&b, "[%d of %d matches; narrow the query or raise limit]", len(out.Zones), out.Total)
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: strings.TrimRight(b.String(), "\n")}},
+	}, out, nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Tool middleware ---------- */
+
+// ToolFunc is a tool handler with its arguments already decoded and
+// validated. The second result is the structured output, if any.
+type ToolFunc func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, any, error)
+
+// ToolMiddleware wraps the handler of one tool. It is applied once, when
+// the tool is registered with addTool, and receives the tool's definition
+// so it can keep per-tool state or skip tools it does not apply to.
+type ToolMiddleware func(tool *mcp.Tool, next ToolFunc) ToolFunc
+
+// toolMiddleware is applied to every tool registered after it is added;
+// the first entry is the outermost.
+var toolMiddleware []ToolMiddleware
+
+// useToolMiddleware appends to the chain used by later addTool calls.
+func useToolMiddleware(mw ...ToolMiddleware) {
+	toolMiddleware = append(toolMiddleware, mw...)
+}
+
+// addTool registers a tool like mcp.AddTool, wrapping its handler in the
+// tool middleware chain, unless the tool selection leaves it out.
+// Middleware runs after the SDK has validated and decoded the arguments,
+// and before it validates the structured output.
+func addTool[In, Out any](server *mcp.Server, tool *mcp.Tool, h mcp.ToolHandlerFor[In, Out]) {
+	wrapped := wrapTool(tool, toolMiddleware, h)
+	add := func(s *mcp.Server) { mcp.AddTool(s, tool, wrapped) }
+	if toolSet.offer(tool.Name, add) {
+		add(server)
+	}
+}
+
+func wrapTool[In, Out any](tool *mcp.Tool, chain []ToolMiddleware, h mcp.ToolHandlerFor[In, Out]) mcp.ToolHandlerFor[In, Out] {
+	if len(chain) == 0 {
+		return h
+	}
+	return func(ctx context.Context, req *mcp.CallToolRequest, in In) (*mcp.CallToolResult, Out, error) {
+		var next ToolFunc = func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, any, error) {
+			res, out, err := h(ctx, req, in)
+			return res, out, err
+		}
+		for i := len(chain) - 1; i >= 0; i-- {
+			next = chain[i](tool, next)
+		}
+		res, out, err := next(ctx, req)
+		// Middleware that answers by itself (e.g. with errorResult) returns
+		// no output; that becomes Out's zero value.
+		typed, _ := out.(Out)
+		return res, typed, err
+	}
+}
+
+// toolStats counts calls per tool for /metrics.
+type toolStats struct {
+	mu    sync.Mutex
+	tools map[string]*toolCounters
+}
+
+type toolCounters struct {
+	calls     int
+	errors    int
+	cancelled int
+	duration  time.Duration
+}
+
+var toolCallStats = &toolStats{tools: make(map[string]*toolCounters)}
+
+// metricsToolMiddleware records the count, failures, cancellations and
+// total duration of each tool's calls. A call fails if it returns an error
+// or an error result.
+func metricsToolMiddleware(tool *mcp.Tool, next ToolFunc) ToolFunc {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, any, error) {
+		start := time.Now()
+		res, out, err := next(ctx, req)
+		toolCallStats.record(tool.Name, time.Since(start), err != nil || (res != nil && res.IsError), cancelled(ctx))
+		return res, out, err
+	}
+}
+
+// cancelled reports whether the client cancelled the call ctx belongs to,
+// with notifications/cancelled or by closing the session. The SDK cancels
+// the context, which stops outbound requests, exec'd commands and waits.
+func cancelled(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.Canceled)
+}
+
+func (s *toolStats) record(name string, d time.Duration, failed, cancelled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.tools[name]
+	if c == nil {
+		c = &toolCounters{}
+		s.tools[name] = c
+	}
+	c.calls++
+	c.duration += d
+	if failed {
+		c.errors++
+	}
+	if cancelled {
+		c.cancelled++
+	}
+}
+
+// collectMetrics exports per-tool call counters for /metrics.
+func (s *toolStats) collectMetrics(w *metricsWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := sortedKeys(s.tools)
+	w.family("mcp_tool_calls_total", "counter", "Tool calls handled, per tool")
+	for _, name := range names {
+		w.sample("mcp_tool_calls_total", float64(s.tools[name].calls), "tool", name)
+	}
+	w.family("mcp_tool_errors_total", "counter", "Tool calls that returned an error, per tool")
+	for _, name := range names {
+		w.sample("mcp_tool_errors_total", float64(s.tools[name].errors), "tool", name)
+	}
+	w.family("mcp_tool_cancelled_total", "counter", "Tool calls cancelled by the client, per tool")
+	for _, name := range names {
+		w.sample("mcp_tool_cancelled_total", float64(s.tools[name].cancelled), "tool", name)
+	}
+	w.family("mcp_tool_duration_seconds_total", "counter", "Time spent in tool handlers, per tool")
+	for _, name := range names {
+		w.sample("mcp_tool_duration_seconds_total", s.tools[name].duration.Seconds(), "tool", name)
+	}
+}
+
+// logToolCalls is set by -log-tool-calls; the debug log level also logs
+// tool calls.
+var logToolCalls bool
+
+// logToolMiddleware logs one line per tool call with its outcome and
+// duration. Arguments are not logged: they may hold secrets or, in
+// -public-demo mode, visitors' input.
+func logToolMiddleware(tool *mcp.Tool, next ToolFunc) ToolFunc {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, any, error) {
+		if !logToolCalls && !logEnabled(logDebug) {
+			return next(ctx, req)
+		}
+		start := time.Now()
+		res, out, err := next(ctx, req)
+		outcome := "ok"
+		switch {
+		case cancelled(ctx):
+			outcome = "cancelled"
+		case err != nil:
+			outcome = "error: " + err.Error()
+		case res != nil && res.IsError:
+			outcome = "error result"
+		}
+		log.Printf("[TOOL] %s %s in %s (request %s)", tool.Name, outcome, time.Since(start).Round(time.Millisecond), callRequestID(ctx))
+		return res, out, err
+	}
+}
+
+// recoverToolMiddleware turns a panic in a tool handler into an error
+// result, so that one bad call cannot take down the stdio session or the
+// HTTP server. The caller only sees a reference; the panic value and
+// stack go to the log under the same reference.
+func recoverToolMiddleware(tool *mcp.Tool, next ToolFunc) ToolFunc {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (res *mcp.CallToolResult, out any, err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				ref := make([]byte, 4)
+				rand.Read(ref)
+				id := hex.EncodeToString(ref)
+				log.Printf("[PANIC] tool %s (ref %s): %v\n%s", tool.Name, id, v, debug.Stack())
+				res, out, err = errorResult(fmt.Sprintf("internal error in tool %s (ref %s)", tool.Name, id)), nil, nil
+			}
+		}()
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Tool selection ---------- */
+
+// toolSelection decides which of the tools this run offers are
+// registered, from -enable-tools / -disable-tools or the config file.
+type toolSelection struct {
+	mu      sync.Mutex
+	enable  map[string]bool // empty: all
+	disable map[string]bool
+
+	offered    []string // every tool addTool was called for, in order
+	registered map[string]bool
+	// add registers an offered tool again after a reload enabled it.
+	add map[string]func(*mcp.Server)
+}
+
+var toolSet = &toolSelection{registered: make(map[string]bool), add: make(map[string]func(*mcp.Server))}
+
+// set replaces the selection before the tools are registered.
+func (s *toolSelection) set(cfg toolsConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enable, s.disable = nameSet(cfg.Enable), nameSet(cfg.Disable)
+}
+
+// offer records a tool and reports whether the selection registers it;
+// add registers it, now or after a later reload.
+func (s *toolSelection) offer(name string, add func(*mcp.Server)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offered = append(s.offered, name)
+	s.add[name] = add
+	if !s.allows(name) {
+		return false
+	}
+	s.registered[name] = true
+	return true
+}
+
+// apply switches a running server to a new selection, adding and
+// removing tools as needed. An invalid selection changes nothing.
+func (s *toolSelection) apply(server *mcp.Server, cfg toolsConfig) (added, removed []string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := &toolSelection{enable: nameSet(cfg.Enable), disable: nameSet(cfg.Disable), offered: s.offered, registered: make(map[string]bool)}
+	for _, name := range s.offered {
+		if next.allows(name) {
+			next.registered[name] = true
+		}
+	}
+	if err := next.check(); err != nil {
+		return nil, nil, err
+	}
+	for _, name := range s.offered {
+		switch {
+		case next.registered[name] && !s.registered[name]:
+			s.add[name](server)
+			added = append(added, name)
+		case !next.registered[name] && s.registered[name]:
+			removed = append(removed, name)
+		}
+	}
+	if len(removed) > 0 {
+		server.RemoveTools(removed...)
+	}
+	s.enable, s.disable, s.registered = next.enable, next.disable, next.registered
+	return added, removed, nil
+}
+
+func nameSet(names []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			set[name] = true
+		}
+	}
+	return set
+}
+
+// allows reports whether the tool called name is selected.
+func (s *toolSelection) allows(name string) bool {
+	if len(s.enable) > 0 && !s.enable[name] {
+		return false
+	}
+	return !s.disable[name]
+}
+
+// isRegistered reports whether the tool called name is registered.
+func (s *toolSelection) isRegistered(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.registered[name]
+}
+
+// check rejects selections naming tools this run does not offer, which
+// are typos or tools whose own flag (e.g. -enable-exec) is missing.
+func (s *toolSelection) check() error {
+	offered := nameSet(s.offered)
+	var unknown []string
+	for _, set := range []map[string]bool{s.enable, s.disable} {
+		for name := range set {
+			if !offered[name] {
+				unknown = append(unknown, name)
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown or unavailable tools: %s (available: %s)", strings.Join(unknown, ", "), strings.Join(s.offered, ", "))
+	}
+	if len(s.registered) == 0 {
+		return fmt.Errorf("the selection leaves no tools")
+	}
+	return nil
+}
+
+// logTools prints the tools left out by the selection.
+func logTools() {
+	toolSet.mu.Lock()
+	defer toolSet.mu.Unlock()
+	var skipped []string
+	for _, name := range toolSet.offered {
+		if !toolSet.registered[name] {
+			skipped = append(skipped, name)
+		}
+	}
+	if len(skipped) > 0 {
+		log.Printf("Tools: %d registered, disabled %s", len(toolSet.registered), strings.Join(skipped, ","))
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Tool: transform ---------- */
+
+// maxTransformInputBytes caps the input read from a URL.
+const maxTransformInputBytes = 1 << 20
+
+// transformHashes are the supported digest operations.
+var transformHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// transformCodecs are the supported encode/decode operations.
+var transformCodecs = map[string]func([]byte) ([]byte, error){
+	"base64_encode": func(b []byte) ([]byte, error) { return []byte(base64.StdEncoding.EncodeToString(b)), nil },
+	"base64_decode": decodeBase64,
+	"hex_encode":    func(b []byte) ([]byte, error) { return []byte(hex.EncodeToString(b)), nil },
+	"hex_decode": func(b []byte) ([]byte, error) {
+		return hex.DecodeString(strings.TrimSpace(string(b)))
+	},
+	"url_encode": func(b []byte) ([]byte, error) { return []byte(url.QueryEscape(string(b))), nil },
+	"url_decode": func(b []byte) ([]byte, error) {
+		s, err := url.QueryUnescape(string(b))
+		return []byte(s), err
+	},
+}
+
+// decodeBase64 accepts standard and URL-safe alphabets, with or without
+// padding, ignoring surrounding whitespace.
+func decodeBase64(b []byte) ([]byte, error) {
+	s := strings.TrimSpace(string(b))
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if out, err := enc.DecodeString(s); err == nil {
+			return out, nil
+		}
+	}
+	_, err := base64.StdEncoding.DecodeString(s)
+	return nil, err
+}
+
+func transformOperations() string {
+	var ops []string
+	for op := range transformHashes {
+		ops = append(ops, op)
+	}
+	for op := range transformCodecs {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	return strings.Join(ops, ", ")
+}
+
+type TransformArgs struct {
+	Operation string `json:"operation" jsonschema:"md5, sha1, sha256, sha512, base64_encode, base64_decode, hex_encode, hex_decode, url_encode or url_decode"`
+	Input     string `json:"input,omitempty" jsonschema:"Input text (use either input or url)"`
+	URL       string `json:"url,omitempty" jsonschema:"Fetch the input from this http(s) URL instead (max 1 MiB)"`
+}
+
+// TransformResult is the structured output of the transform tool.
+type TransformResult struct {
+	Operation  string `json:"operation"`
+	Source     string `json:"source" jsonschema:"input, or the URL the input was fetched from"`
+	InputBytes int    `json:"input_bytes"`
+	Output     string `json:"output"`
+	Encoding   string `json:"encoding" jsonschema:"utf-8, or hex when a decode produced binary data"`
+}
+
+func TransformTool(ctx context.Context, req *mcp.CallToolRequest, in TransformArgs) (*mcp.CallToolResult, any, error) {
+	op := strings.ToLower(in.Operation)
+	newHash, isHash := transformHashes[op]
+	codec, isCodec := transformCodecs[op]
+	if !isHash && !isCodec {
+		return errorResult(fmt.Sprintf("unknown operation %q (want one of: %s)", in.Operation, transformOperations())), nil, nil
+	}
+	if (in.Input != "") == (in.URL != "") {
+		return errorResult("provide exactly one of input or url"), nil, nil
+	}
+
+	data, source := []byte(in.Input), "input"
+	if in.URL != "" {
+		var err error
+		if data, err = fetchTransformInput(ctx, in.URL); err != nil {
+			return errorResult(err.Error()), nil, nil
+		}
+		source = in.URL
+	}
+
+	out := TransformResult{Operation: op, Source: source, InputBytes: len(data), Encoding: "utf-8"}
+	if isHash {
+		h := newHash()
+		h.Write(data)
+		out.Output = hex.EncodeToString(h.Sum(nil))
+	} else {
+		result, err := codec(data)
+		if err != nil {
+			return errorResult(fmt.Sprintf("%s failed: %v", op, err)), nil, nil
+		}
+		out.Output = string(result)
+		if !utf8.Valid(result) {
+			out.Encoding = "hex"
+			out.Output = hex.EncodeToString(result)
+		}
+	}
+
+	text := out.Output
+	if out.Encoding == "hex" && !isHash {
+		text = fmt.Sprintf("[binary result, %d bytes, shown as hex]\n%s", len(out.Output)/2, out.Output)
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: text}},
+	}, out, nil
+}
+
+// fetchTransformInput downloads the body of rawURL under the egress
+// policy, rejecting bodies larger than maxTransformInputBytes rather than
+// hashing a silently truncated prefix.
+func fetchTransformInput(ctx context.Context, rawURL string) ([]byte, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid URL: %v", err)
+	}
+	if err := egress.Check(ctx, target); err != nil {
+		return nil, fmt.Errorf("URL not allowed: %v", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Request error: %v", err)
+	}
+	httpReq.Header.Set("User-Agent", fetchUserAgent)
+	var redirects []string
+	resp, err := redirectingClient(true, defaultMaxRedirects, &redirects).Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("Fetch error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("Fetch error: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxTransformInputBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("Read error: %v", err)
+	}
+	if len(data) > maxTransformInputBytes {
+		return nil, fmt.Errorf("response exceeds %d bytes", maxTransformInputBytes)
+	}
+	return data, nil
+}
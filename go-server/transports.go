@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Transports ---------- */
+
+// Transports (-mode). stdio and http can run in one process at once, for a
+// local client and remote ones, sharing the server and its tools; browser
+// runs alone.
+const (
+	modeStdio   = "stdio"
+	modeHTTP    = "http"
+	modeBrowser = "browser"
+)
+
+// parseModes validates -mode, a comma-separated list of transports.
+func parseModes(s string) (map[string]bool, error) {
+	modes := make(map[string]bool)
+	for _, m := range strings.Split(s, ",") {
+		switch m = strings.TrimSpace(m); m {
+		case "":
+		case modeStdio, modeHTTP, modeBrowser:
+			modes[m] = true
+		default:
+			return nil, fmt.Errorf("unknown transport %q (want stdio, http or browser)", m)
+		}
+	}
+	switch {
+	case len(modes) == 0:
+		return nil, errors.New("no transport")
+	case modes[modeBrowser] && len(modes) > 1:
+		return nil, errors.New("browser cannot be combined with other transports")
+	}
+	return modes, nil
+}
+
+// serverTransport is one of the ways the server is served.
+type serverTransport struct {
+	name   string
+	status string       // what it serves on, for the service manager
+	serve  func() error // returns when the transport is done
+}
+
+// runTransports serves the transports at once and returns when all of
+// them are done. Each has its own lifecycle: when the stdio client goes
+// away, HTTP keeps serving until the process is stopped, and an error on
+// one transport is logged without stopping the others.
+func runTransports(ctx context.Context, transports []serverTransport) error {
+	var mu sync.Mutex
+	var errs []error
+	running := make(map[string]bool, len(transports))
+	for _, t := range transports {
+		running[t.name] = true
+	}
+	var wg sync.WaitGroup
+	for _, t := range transports {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := t.serve()
+			if ctx.Err() != nil && errors.Is(err, context.Canceled) {
+				err = nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			delete(running, t.name)
+			if err != nil && len(transports) > 1 {
+				err = fmt.Errorf("%s: %w", t.name, err)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if ctx.Err() == nil && len(running) > 0 {
+				if err != nil {
+					log.Printf("Transport %v; still serving %s", err, strings.Join(sortedKeys(running), ", "))
+				} else {
+					log.Printf("Transport %s closed; still serving %s", t.name, strings.Join(sortedKeys(running), ", "))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// stdioSessionID names the stdio client's session, which the SDK leaves
+// unnamed, so that it has its own entry in /admin/sessions next to the
+// HTTP sessions.
+const stdioSessionID = "stdio"
+
+// stdioTransport is mcp.StdioTransport with a session ID.
+type stdioTransport struct{ mcp.StdioTransport }
+
+func (t *stdioTransport) Connect(ctx context.Context) (mcp.Connection, error) {
+	conn, err := t.StdioTransport.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return stdioConn{conn}, nil
+}
+
+type stdioConn struct{ mcp.Connection }
+
+func (stdioConn) SessionID() string { return stdioSessionID }
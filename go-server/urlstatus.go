@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Tool: url_status ---------- */
+
+type URLStatusArgs struct {
+	// URL to check
+	URL string `json:"url" jsonschema:"URL to check (must be http or https)"`
+}
+
+// URLStatusResult is the structured output of the url_status tool.
+type URLStatusResult struct {
+	URL           string `json:"url"`
+	FinalURL      string `json:"final_url"`
+	Method        string `json:"method" jsonschema:"HEAD, or GET when the server rejected HEAD"`
+	StatusCode    int    `json:"status_code"`
+	Status        string `json:"status"`
+	OK            bool   `json:"ok" jsonschema:"True for 2xx and 3xx status codes"`
+	ContentType   string `json:"content_type"`
+	ContentLength int64  `json:"content_length" jsonschema:"Declared Content-Length, or -1 when unknown"`
+	LatencyMs     int64  `json:"latency_ms" jsonschema:"Time until response headers were received, in milliseconds"`
+}
+
+// URLStatusTool reports the status of one link, checked by probeURL.
+func URLStatusTool(ctx context.Context, req *mcp.CallToolRequest, in URLStatusArgs) (*mcp.CallToolResult, any, error) {
+	if in.URL == "" {
+		return errorResult("URL is required"), nil, nil
+	}
+	target, err := url.Parse(in.URL)
+	if err != nil {
+		return errorResult("Invalid URL: " + err.Error()), nil, nil
+	}
+	if err := egress.Check(ctx, target); err != nil {
+		return errorResult("URL not allowed: " + err.Error()), nil, nil
+	}
+
+	probe, err := probeURL(ctx, in.URL)
+	if err != nil {
+		return errorResult("Request error: " + err.Error()), nil, nil
+	}
+	resp := probe.resp
+
+	out := URLStatusResult{
+		URL:           in.URL,
+		FinalURL:      resp.Request.URL.String(),
+		Method:        probe.method,
+		StatusCode:    resp.StatusCode,
+		Status:        resp.Status,
+		OK:            resp.StatusCode < 400,
+		ContentType:   resp.Header.Get("Content-Type"),
+		ContentLength: resp.ContentLength,
+		LatencyMs:     probe.latency.Milliseconds(),
+	}
+
+	text := fmt.Sprintf("URL: %s\nFinal URL: %s\nMethod: %s\nStatus: %s\nContent-Type: %s\nContent-Length: %d\nLatency: %dms",
+		out.URL, out.FinalURL, out.Method, out.Status, out.ContentType, out.ContentLength, out.LatencyMs)
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: text}},
+	}, out, nil
+}
+
+// urlProbe is the outcome of checking a URL.
+type urlProbe struct {
+	resp      *http.Response // body already closed
+	method    string
+	latency   time.Duration
+	redirects []string
+}
+
+// probeURL checks a link with a HEAD request, following redirects. Servers
+// that reject HEAD (405, 501) are retried with GET, closing the body
+// unread. The caller checks rawURL against the egress policy; redirect
+// hops are checked here.
+func probeURL(ctx context.Context, rawURL string) (*urlProbe, error) {
+	p := &urlProbe{}
+	client := redirectingClient(true, defaultMaxRedirects, &p.redirects)
+
+	do := func(method string) (*http.Response, time.Duration, error) {
+		httpReq, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		httpReq.Header.Set("User-Agent", fetchUserAgent)
+		start := time.Now()
+		resp, err := client.Do(httpReq)
+		if err != nil {
+			return nil, 0, err
+		}
+		resp.Body.Close()
+		return resp, time.Since(start), nil
+	}
+
+	var err error
+	p.method = http.MethodHead
+	p.resp, p.latency, err = do(p.method)
+	if err == nil && (p.resp.StatusCode == http.StatusMethodNotAllowed || p.resp.StatusCode == http.StatusNotImplemented) {
+		p.method = http.MethodGet
+		p.redirects = nil
+		p.resp, p.latency, err = do(p.method)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,16 @@
+// Runs the Go MCP server (server.wasm, built with GOOS=js GOARCH=wasm) in
+// a Web Worker. The page talks to it with postMessage: each message is one
+// JSON-RPC message. Server flags come from the worker URL, one per "arg"
+// parameter, e.g. new Worker("worker.js?arg=-public-demo").
+importScripts("wasm_exec.js");
+
+// Messages posted before the server listens wait here; it takes them when
+// it starts.
+self.mcpPending = [];
+self.onmessage = (event) => self.mcpPending.push(event.data);
+
+const go = new Go();
+go.argv = ["mcp-server-demo-go", ...new URLSearchParams(location.search).getAll("arg")];
+WebAssembly.instantiateStreaming(fetch("server.wasm"), go.importObject)
+  .then((result) => go.run(result.instance))
+  .catch((err) => console.error("mcp-server-demo-go:", err));
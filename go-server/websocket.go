@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"mcp-demo-server/pkg/wstransport"
+)
+
+/* ---------- WebSocket transport ---------- */
+
+// webSocketEndpoint serves MCP over WebSocket at /ws (-websocket), for
+// clients behind gateways that buffer or cut Server-Sent Events. Each
+// connection is one session of the same server /mcp serves.
+type webSocketEndpoint struct {
+	server       *mcp.Server
+	cors         *corsPolicy // other origins browsers may connect from
+	pingInterval time.Duration
+	maxMessage   int64
+
+	mu       sync.Mutex
+	sessions map[*mcp.ServerSession]bool
+}
+
+func newWebSocketEndpoint(server *mcp.Server, cors *corsPolicy, pingInterval time.Duration, maxMessage int64) *webSocketEndpoint {
+	return &webSocketEndpoint{
+		server:       server,
+		cors:         cors,
+		pingInterval: pingInterval,
+		maxMessage:   maxMessage,
+		sessions:     make(map[*mcp.ServerSession]bool),
+	}
+}
+
+func (e *webSocketEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if refuseSession(w, r) {
+		return
+	}
+	opts := &websocket.AcceptOptions{Subprotocols: []string{wstransport.Subprotocol}}
+	// Browsers on another origin than the server's need -cors-origins.
+	if origin := r.Header.Get("Origin"); origin != "" && e.cors != nil && e.cors.allowed(origin) {
+		opts.InsecureSkipVerify = true
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	sessionID := "ws-" + hex.EncodeToString(id)
+	w.Header().Set(sessionIDHeader, sessionID)
+	ws, err := websocket.Accept(w, r, opts)
+	if err != nil {
+		// Accept has answered the request.
+		log.Printf("[WS] Handshake from %s failed: %v", r.RemoteAddr, err)
+		return
+	}
+	ws.SetReadLimit(e.maxMessage)
+	conn := wstransport.NewConn(ws, sessionID, e.pingInterval)
+	ss, err := e.server.Connect(r.Context(), conn.Transport(), nil)
+	if err != nil {
+		log.Printf("[WS] Session %s: %v", sessionID, err)
+		conn.Close()
+		return
+	}
+	liveSessions.open(ss, "websocket", r)
+	e.mu.Lock()
+	e.sessions[ss] = true
+	e.mu.Unlock()
+	// The connection is hijacked: the handler holds it until the session
+	// ends.
+	ss.Wait()
+	e.mu.Lock()
+	delete(e.sessions, ss)
+	e.mu.Unlock()
+}
+
+// shutdown closes the open WebSocket sessions, which http.Server.Shutdown
+// does not track because their connections are hijacked, and waits for
+// their close handshakes until ctx is done.
+func (e *webSocketEndpoint) shutdown(ctx context.Context) {
+	e.mu.Lock()
+	var wg sync.WaitGroup
+	for ss := range e.sessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ss.Close()
+		}()
+	}
+	e.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+/* ---------- Workshop mode ---------- */
+
+const workshopProgressURI = "workshop://progress"
+
+// exercise is one guided step of the workshop. It counts as completed
+// once the session calls Tool successfully.
+type exercise struct {
+	ID           string
+	Tool         string
+	Title        string
+	Instructions string
+}
+
+// available reports whether the exercise's tool is registered in this
+// run; flags, -public-demo and the tool selection all affect that.
+func (ex exercise) available() bool { return toolSet.isRegistered(ex.Tool) }
+
+// workshopExercises are offered in order; unavailable ones are skipped.
+var workshopExercises = []exercise{
+	{"echo", "echotest", "Your first tool call",
+		"Call the `echotest` tool with a message of your choice and check that the same text comes back."},
+	{"time", "timeserver", "Time around the world",
+		"Call `timeserver` with `timezone` set to a city you'd like to visit (an IANA name such as `Asia/Tokyo`)."},
+	{"fetch", "fetch", "Reading the web",
+		"Use `fetch` on `https://example.com` with `extract` set to `text`, then try `markdown`. Compare the structured `status_code` and `content_type` fields."},
+	{"status", "url_status", "Checking links",
+		"Use `url_status` on a page and on a URL that does not exist. Which fields tell you the link is broken?"},
+	{"random", "random", "Randomness on demand",
+		"Generate three `uuid7` values with `random`, then repeat an `int` request with the same `seed` and confirm the output is identical."},
+	{"transform", "transform", "Hashes and encodings",
+		"The file `data/secret.txt` contains base64. Decode it with `transform` (`base64_decode`), then `sha256` the result."},
+	{"list", "list_dir", "Exploring files",
+		"Call `list_dir` with no arguments to see the workshop files, then list `data`."},
+	{"read", "read_file", "Reading files",
+		"Read `data/cities.csv` with `read_file`. Try `max_bytes` 40 and then `offset` to page through it."},
+	{"write", "write_file", "Writing files",
+		"Save your answers to `notes/answers.md` with `write_file` (`create_dirs: true`), then read the file back."},
+}
+
+// workshopSeedFiles are written to a temporary sandbox root when -workshop
+// is used without -fs-root.
+var workshopSeedFiles = map[string]string{
+	"README.md":       "# MCP workshop\n\nThese files belong to the guided exercises. Read the `workshop` prompt to begin,\nand the `workshop://progress` resource to see how far you've come.\n",
+	"data/cities.csv": "city,country,timezone\nKyiv,Ukraine,Europe/Kyiv\nTokyo,Japan,Asia/Tokyo\nNew York,USA,America/New_York\nSydney,Australia,Australia/Sydney\nLisbon,Portugal,Europe/Lisbon\n",
+	"data/secret.txt": "WW91IGRlY29kZWQgdGhlIHdvcmtzaG9wIHNlY3JldCE=\n",
+	"notes/.keep":     "",
+}
+
+// seedWorkshopDir creates a temporary directory holding the workshop files.
+func seedWorkshopDir() (string, error) {
+	dir, err := os.MkdirTemp("", "mcp-workshop-")
+	if err != nil {
+		return "", err
+	}
+	for name, content := range workshopSeedFiles {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// workshopProgress records completed exercises per session.
+type workshopProgress struct {
+	mu   sync.Mutex
+	done map[string]map[string]time.Time // session ID -> exercise ID -> completion time
+}
+
+var workshop = &workshopProgress{done: make(map[string]map[string]time.Time)}
+
+func (p *workshopProgress) complete(sessionID, tool string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, ex := range workshopExercises {
+		if ex.Tool != tool || !ex.available() {
+			continue
+		}
+		if p.done[sessionID] == nil {
+			p.done[sessionID] = make(map[string]time.Time)
+		}
+		if _, ok := p.done[sessionID][ex.ID]; !ok {
+			p.done[sessionID][ex.ID] = time.Now().UTC()
+		}
+	}
+}
+
+// retain drops the progress of sessions not in live, returning how many.
+func (p *workshopProgress) retain(live map[string]bool) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for id := range p.done {
+		if !live[id] {
+			delete(p.done, id)
+			n++
+		}
+	}
+	return n
+}
+
+// workshopStatus is the JSON body of the progress resource.
+type workshopStatus struct {
+	Completed int              `json:"completed"`
+	Total     int              `json:"total"`
+	Next      string           `json:"next,omitempty"`
+	Exercises []exerciseStatus `json:"exercises"`
+}
+
+type exerciseStatus struct {
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	Tool        string     `json:"tool"`
+	Prompt      string     `json:"prompt"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+func (p *workshopProgress) status(sessionID string) workshopStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := workshopStatus{Exercises: []exerciseStatus{}}
+	for _, ex := range workshopExercises {
+		if !ex.available() {
+			continue
+		}
+		es := exerciseStatus{ID: ex.ID, Title: ex.Title, Tool: ex.Tool, Prompt: "workshop_" + ex.ID}
+		if at, ok := p.done[sessionID][ex.ID]; ok {
+			es.CompletedAt = &at
+			st.Completed++
+		} else if st.Next == "" {
+			st.Next = es.Prompt
+		}
+		st.Exercises = append(st.Exercises, es)
+	}
+	st.Total = len(st.Exercises)
+	return st
+}
+
+// workshopMiddleware marks exercises complete after successful tool calls.
+func workshopMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		result, err := next(ctx, method, req)
+		if method != "tools/call" || err != nil {
+			return result, err
+		}
+		call, ok := req.(*mcp.CallToolRequest)
+		if res, isTool := result.(*mcp.CallToolResult); ok && isTool && !res.IsError {
+			workshop.complete(call.Session.ID(), call.Params.Name)
+		}
+		return result, err
+	}
+}
+
+// addWorkshop registers the workshop prompts, the progress resource and
+// the progress-tracking middleware.
+func addWorkshop(server *mcp.Server) {
+	server.AddReceivingMiddleware(workshopMiddleware)
+
+	server.AddPrompt(&mcp.Prompt{
+		Name:        "workshop",
+		Title:       "MCP workshop: start here",
+		Description: "Overview of the guided exercises and how progress is tracked",
+	}, func(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		var b strings.Builder
+		b.WriteString("Welcome to the MCP workshop! Work through these exercises in order; each one has its own prompt.\n\n")
+		n := 0
+		for _, ex := range workshopExercises {
+			if ex.available() {
+				n++
+				fmt.Fprintf(&b, "%d. %s (prompt `workshop_%s`, tool `%s`)\n", n, ex.Title, ex.ID, ex.Tool)
+			}
+		}
+		fmt.Fprintf(&b, "\nRead the `%s` resource at any time to see which exercises you have completed.", workshopProgressURI)
+		return workshopPromptResult("MCP workshop overview", b.String()), nil
+	})
+
+	for _, ex := range workshopExercises {
+		if !ex.available() {
+			continue
+		}
+		server.AddPrompt(&mcp.Prompt{
+			Name:        "workshop_" + ex.ID,
+			Title:       "Workshop: " + ex.Title,
+			Description: fmt.Sprintf("Guided exercise for the %s tool", ex.Tool),
+		}, func(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			text := fmt.Sprintf("Exercise: %s\n\n%s\n\nWhen the `%s` call succeeds, the exercise is marked complete in `%s`.",
+				ex.Title, ex.Instructions, ex.Tool, workshopProgressURI)
+			return workshopPromptResult(ex.Title, text), nil
+		})
+	}
+
+	server.AddResource(&mcp.Resource{
+		URI:         workshopProgressURI,
+		Name:        "progress",
+		Title:       "Workshop progress",
+		Description: "Exercises this session has completed",
+		MIMEType:    "application/json",
+	}, func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		data, err := json.MarshalIndent(workshop.status(req.Session.ID()), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return &mcp.ReadResourceResult{
+			Contents: []*mcp.ResourceContents{{URI: workshopProgressURI, MIMEType: "application/json", Text: string(data)}},
+		}, nil
+	})
+}
+
+func workshopPromptResult(description, text string) *mcp.GetPromptResult {
+	return &mcp.GetPromptResult{
+		Description: description,
+		Messages: []*mcp.PromptMessage{{
+			Role:    "user",
+			Content: &mcp.TextContent{Text: text},
+		}},
+	}
+}
+
+// logWorkshop prints where the seeded files live.
+func logWorkshop(seeded string) {
+	if seeded != "" {
+		log.Printf("Workshop mode: seeded exercise files in %s", seeded)
+	}
+	log.Printf("Workshop mode: prompts workshop, workshop_<exercise>; progress at %s", workshopProgressURI)
+}
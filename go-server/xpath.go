@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"golang.org/x/net/html"
+)
+
+/* ---------- Tool: xpath ---------- */
+
+const (
+	// defaultQueryLimit and maxQueryLimit bound the limit argument.
+	defaultQueryLimit = 20
+	maxQueryLimit     = 200
+	// maxMatchTextBytes caps the text, and maxMatchMarkupBytes the markup,
+	// returned per match.
+	maxMatchTextBytes   = 2000
+	maxMatchMarkupBytes = 4000
+)
+
+type XPathArgs struct {
+	Content    string   `json:"content,omitempty" jsonschema:"XML or HTML document to query (use either content or url)"`
+	URL        string   `json:"url,omitempty" jsonschema:"Fetch the document from this http(s) URL instead (max 2 MiB)"`
+	Expression string   `json:"expression,omitempty" jsonschema:"XPath 1.0 expression, e.g. //a/@href or count(//item); use either expression or selector"`
+	Selector   string   `json:"selector,omitempty" jsonschema:"CSS selector, e.g. article h2 > a (HTML only)"`
+	Format     string   `json:"format,omitempty" jsonschema:"How to parse the document: html, xml or auto (default; from the Content-Type, or xml when the content starts with an XML declaration)"`
+	Attributes []string `json:"attributes,omitempty" jsonschema:"Attribute names to return for matched elements (default: all)"`
+	Markup     bool     `json:"markup,omitempty" jsonschema:"Also return the outer markup of matched elements"`
+	Limit      int      `json:"limit,omitempty" jsonschema:"Maximum matches to return (default 20, max 200)"`
+}
+
+// XPathMatch is one node matched by a query.
+type XPathMatch struct {
+	Name       string            `json:"name" jsonschema:"Element name, @attribute for attribute nodes, #text or #comment"`
+	Text       string            `json:"text" jsonschema:"Text content with whitespace collapsed, or the attribute value"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Markup     string            `json:"markup,omitempty"`
+	Truncated  bool              `json:"truncated,omitempty" jsonschema:"True when text or markup was cut"`
+}
+
+// XPathResult is the structured output of the xpath tool.
+type XPathResult struct {
+	Source  string       `json:"source" jsonschema:"content, or the URL the document was fetched from"`
+	Format  string       `json:"format" jsonschema:"html or xml"`
+	Query   string       `json:"query"`
+	Kind    string       `json:"kind" jsonschema:"xpath or css"`
+	Count   int          `json:"count" jsonschema:"Number of matched nodes, before limit"`
+	Matches []XPathMatch `json:"matches"`
+	Value   any          `json:"value,omitempty" jsonschema:"Result of an XPath expression that does not select nodes, e.g. count() or string()"`
+}
+
+// queryDoc is a parsed document: exactly one of the fields is set.
+type queryDoc struct {
+	html *html.Node
+	xml  *xmlquery.Node
+}
+
+func XPathTool(ctx context.Context, req *mcp.CallToolRequest, in XPathArgs) (*mcp.CallToolResult, any, error) {
+	if (in.Content != "") == (in.URL != "") {
+		return errorResult("provide exactly one of content or url"), nil, nil
+	}
+	if (in.Expression != "") == (in.Selector != "") {
+		return errorResult("provide exactly one of expression or selector"), nil, nil
+	}
+	format := strings.ToLower(strings.TrimSpace(in.Format))
+	switch format {
+	case "", "auto", "html", "xml":
+	default:
+		return errorResult(fmt.Sprintf("unknown format %q (want html, xml or auto)", in.Format)), nil, nil
+	}
+	if in.Selector != "" && format == "xml" {
+		return errorResult("CSS selectors need HTML; use an XPath expression for XML"), nil, nil
+	}
+	if len(in.Content) > maxExtractInputBytes {
+		return errorResult(fmt.Sprintf("content exceeds %d bytes", maxExtractInputBytes)), nil, nil
+	}
+
+	// Compile first, so a bad query fails before anything is downloaded.
+	var expr *xpath.Expr
+	var sel cascadia.SelectorGroup
+	var err error
+	if in.Expression != "" {
+		if expr, err = xpath.Compile(in.Expression); err != nil {
+			return errorResult("Invalid XPath expression: " + err.Error()), nil, nil
+		}
+	} else if sel, err = cascadia.ParseGroup(in.Selector); err != nil {
+		return errorResult("Invalid CSS selector: " + err.Error()), nil, nil
+	}
+
+	data, source, contentType := []byte(in.Content), "content", ""
+	if in.URL != "" {
+		if data, contentType, err = fetchQueryDocument(ctx, in.URL); err != nil {
+			return errorResult(err.Error()), nil, nil
+		}
+		source = in.URL
+	}
+	if format == "" || format == "auto" {
+		format = documentFormat(data, contentType)
+	}
+	if in.Selector != "" && format == "xml" {
+		return errorResult("the document is XML and CSS selectors need HTML; use an XPath expression or format=html"), nil, nil
+	}
+
+	var doc queryDoc
+	if format == "xml" {
+		doc.xml, err = xmlquery.ParseWithOptions(bytes.NewReader(data), xmlquery.ParserOptions{
+			Decoder: &xmlquery.DecoderOptions{
+				Strict: true,
+				// Charset conversion already happened; accept the declared encoding.
+				CharsetReader: func(_ string, r io.Reader) (io.Reader, error) { return r, nil },
+			},
+		})
+	} else {
+		doc.html, err = html.Parse(bytes.NewReader(data))
+	}
+	if err != nil {
+		return errorResult(fmt.Sprintf("Parse error (%s): %v", format, err)), nil, nil
+	}
+
+	limit := in.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	limit = min(limit, maxQueryLimit)
+	m := matcher{attrs: nameSet(in.Attributes), markup: in.Markup}
+
+	out := XPathResult{Source: source, Format: format, Query: in.Expression, Kind: "xpath", Matches: []XPathMatch{}}
+	if expr != nil {
+		var nav xpath.NodeNavigator = htmlquery.CreateXPathNavigator(doc.html)
+		if doc.xml != nil {
+			nav = xmlquery.CreateXPathNavigator(doc.xml)
+		}
+		switch v := expr.Evaluate(nav).(type) {
+		case *xpath.NodeIterator:
+			for v.MoveNext() {
+				if out.Count++; out.Count <= limit {
+					out.Matches = append(out.Matches, m.node(v.Current()))
+				}
+			}
+		default:
+			out.Value = v
+		}
+	} else {
+		out.Query, out.Kind = in.Selector, "css"
+		nodes := cascadia.QueryAll(doc.html, sel)
+		out.Count = len(nodes)
+		for _, n := range nodes[:min(len(nodes), limit)] {
+			out.Matches = append(out.Matches, m.html(n))
+		}
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: formatQueryResult(out)}},
+	}, out, nil
+}
+
+// fetchQueryDocument downloads a document under the egress policy,
+// decompressed and converted to UTF-8. Bodies over maxExtractInputBytes
+// are rejected: a cut-off XML document would not parse, and a cut-off
+// page would silently miss matches.
+func fetchQueryDocument(ctx context.Context, rawURL string) ([]byte, string, error) {
+	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+		return nil, "", fmt.Errorf("URL must start with http:// or https://")
+	}
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("Invalid URL: %v", err)
+	}
+	if err := egress.Check(ctx, target); err != nil {
+		return nil, "", fmt.Errorf("URL not allowed: %v", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("Request error: %v", err)
+	}
+	httpReq.Header.Set("User-Agent", fetchUserAgent)
+	httpReq.Header.Set("Accept-Encoding", fetchAcceptEncoding)
+	httpReq.Header.Set("Accept", "text/html, application/xhtml+xml, application/xml;q=0.9, */*;q=0.5")
+	var redirects []string
+	resp, err := redirectingClient(true, defaultMaxRedirects, &redirects).Do(httpReq)
+	if err != nil {
+		return nil, "", fmt.Errorf("Fetch error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, "", fmt.Errorf("Fetch error: %s", resp.Status)
+	}
+	decoded, err := decodeBody(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, "", fmt.Errorf("Decode error: %v", err)
+	}
+	defer decoded.Close()
+	data, err := io.ReadAll(io.LimitReader(decoded, maxExtractInputBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("Read error: %v", err)
+	}
+	if len(data) > maxExtractInputBytes {
+		return nil, "", fmt.Errorf("response exceeds %d bytes", maxExtractInputBytes)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if data, _, err = toUTF8(data, contentType); err != nil {
+		return nil, "", fmt.Errorf("Decode error: %v", err)
+	}
+	return data, contentType, nil
+}
+
+// documentFormat picks the parser for format=auto: the Content-Type when
+// there is one, else xml for documents starting with an XML declaration
+// that are not XHTML. Everything else is parsed as HTML, which accepts
+// fragments and broken markup.
+func documentFormat(data []byte, contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch {
+		case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+			return "html"
+		case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+			return "xml"
+		}
+	}
+	head := bytes.ToLower(data[:min(len(data), 1024)])
+	if bytes.HasPrefix(bytes.TrimSpace(head), []byte("<?xml")) && !bytes.Contains(head, []byte("<html")) {
+		return "xml"
+	}
+	return "html"
+}
+
+// matcher turns matched nodes into XPathMatch values.
+type matcher struct {
+	attrs  map[string]bool // empty: all
+	markup bool
+}
+
+// node converts a node selected by an XPath expression. Attribute and
+// text nodes carry their value; elements are converted like CSS matches.
+func (m matcher) node(nav xpath.NodeNavigator) XPathMatch {
+	switch nav.NodeType() {
+	case xpath.AttributeNode:
+		return m.text("@"+nav.LocalName(), nav.Value())
+	case xpath.TextNode:
+		return m.text("#text", nav.Value())
+	case xpath.CommentNode:
+		return m.text("#comment", nav.Value())
+	}
+	switch n := nav.(type) {
+	case *htmlquery.NodeNavigator:
+		return m.html(n.Current())
+	case *xmlquery.NodeNavigator:
+		return m.xml(n.Current())
+	}
+	return m.text(nav.LocalName(), nav.Value())
+}
+
+func (m matcher) text(name, value string) XPathMatch {
+	match := XPathMatch{Name: name}
+	match.Text, match.Truncated = capText(strings.TrimSpace(spaceRun.ReplaceAllString(value, " ")), maxMatchTextBytes)
+	return match
+}
+
+func (m matcher) html(n *html.Node) XPathMatch {
+	if n.Type == html.DocumentNode {
+		return m.text("#document", htmlquery.InnerText(n))
+	}
+	match := m.text(n.Data, htmlquery.InnerText(n))
+	for _, a := range n.Attr {
+		match.addAttr(m, a.Key, a.Val)
+	}
+	if m.markup {
+		var cut bool
+		match.Markup, cut = capText(htmlquery.OutputHTML(n, true), maxMatchMarkupBytes)
+		match.Truncated = match.Truncated || cut
+	}
+	return match
+}
+
+func (m matcher) xml(n *xmlquery.Node) XPathMatch {
+	if n.Type == xmlquery.DocumentNode {
+		return m.text("#document", n.InnerText())
+	}
+	name := n.Data
+	if n.Prefix != "" {
+		name = n.Prefix + ":" + n.Data
+	}
+	match := m.text(name, n.InnerText())
+	for _, a := range n.Attr {
+		key := a.Name.Local
+		if a.Name.Space != "" {
+			key = a.Name.Space + ":" + key
+		}
+		match.addAttr(m, key, a.Value)
+	}
+	if m.markup {
+		var cut bool
+		match.Markup, cut = capText(n.OutputXML(true), maxMatchMarkupBytes)
+		match.Truncated = match.Truncated || cut
+	}
+	return match
+}
+
+func (match *XPathMatch) addAttr(m matcher, key, value string) {
+	if len(m.attrs) > 0 && !m.attrs[key] {
+		return
+	}
+	if match.Attributes == nil {
+		match.Attributes = make(map[string]string)
+	}
+	match.Attributes[key] = value
+}
+
+// capText cuts s to at most n bytes on a character boundary.
+func capText(s string, n int) (string, bool) {
+	if len(s) <= n {
+		return s, false
+	}
+	return string(truncateUTF8([]byte(s), n)), true
+}
+
+// formatQueryResult renders the matches as text, one block per match.
+func formatQueryResult(out XPathResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Source: %s (%s)\nQuery (%s): %s\n", out.Source, out.Format, out.Kind, out.Query)
+	if out.Value != nil {
+		fmt.Fprintf(&b, "Value: %v", out.Value)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Matches: %d", out.Count)
+	if out.Count > len(out.Matches) {
+		fmt.Fprintf(&b, " (first %d shown)", len(out.Matches))
+	}
+	for i, match := range out.Matches {
+		fmt.Fprintf(&b, "\n\n[%d] %s", i+1, match.Name)
+		for _, key := range sortedKeys(match.Attributes) {
+			fmt.Fprintf(&b, " %s=%q", key, match.Attributes[key])
+		}
+		if match.Text != "" {
+			b.WriteString("\n" + match.Text)
+		}
+		if match.Markup != "" {
+			b.WriteString("\n" + match.Markup)
+		}
+		if match.Truncated {
+			b.WriteString("\n(truncated)")
+		}
+	}
+	return b.String()
+}